@@ -0,0 +1,483 @@
+package msgpack
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReaderAdapter turns a ReaderCore into a Reader by implementing the
+// NillableReader and ReaderExtras methods with the core ones, so a custom
+// reader only has to implement ReaderCore:
+//
+//	var r Reader = ReaderAdapter{myCore}
+//
+// The methods that read whole values of any kind, ReadRaw, ReadAny,
+// Skip and the like, need the core to have a ReadRaw method too, as the
+// core methods cannot tell what kind of value comes next. Without one
+// they return an error.
+type ReaderAdapter struct {
+	ReaderCore
+}
+
+var _ Reader = ReaderAdapter{}
+
+// rawReaderCore is a ReaderCore that can also read raw values.
+type rawReaderCore interface {
+	ReadRaw() (Raw, error)
+}
+
+// errNoReadRaw is returned by the ReaderAdapter methods that need a core
+// with ReadRaw.
+var errNoReadRaw = ReadError{"msgpack: the ReaderCore cannot read raw values"}
+
+// ReadRaw reads the next value with the ReadRaw method of the core.
+func (a ReaderAdapter) ReadRaw() (Raw, error) {
+	core, ok := a.ReaderCore.(rawReaderCore)
+	if !ok {
+		return nil, errNoReadRaw
+	}
+	return core.ReadRaw()
+}
+
+func (a ReaderAdapter) IsNextNil() (bool, error) {
+	return readNil(a.ReaderCore)
+}
+
+func (a ReaderAdapter) ReadNillableBool() (*bool, error) {
+	return readNillableWith(a.ReaderCore, a.ReadBool)
+}
+
+func (a ReaderAdapter) ReadNillableInt8() (*int8, error) {
+	return readNillableWith(a.ReaderCore, a.ReadInt8)
+}
+
+func (a ReaderAdapter) ReadNillableInt16() (*int16, error) {
+	return readNillableWith(a.ReaderCore, a.ReadInt16)
+}
+
+func (a ReaderAdapter) ReadNillableInt32() (*int32, error) {
+	return readNillableWith(a.ReaderCore, a.ReadInt32)
+}
+
+func (a ReaderAdapter) ReadNillableInt64() (*int64, error) {
+	return readNillableWith(a.ReaderCore, a.ReadInt64)
+}
+
+func (a ReaderAdapter) ReadNillableUint8() (*uint8, error) {
+	return readNillableWith(a.ReaderCore, a.ReadUint8)
+}
+
+func (a ReaderAdapter) ReadNillableUint16() (*uint16, error) {
+	return readNillableWith(a.ReaderCore, a.ReadUint16)
+}
+
+func (a ReaderAdapter) ReadNillableUint32() (*uint32, error) {
+	return readNillableWith(a.ReaderCore, a.ReadUint32)
+}
+
+func (a ReaderAdapter) ReadNillableUint64() (*uint64, error) {
+	return readNillableWith(a.ReaderCore, a.ReadUint64)
+}
+
+func (a ReaderAdapter) ReadNillableFloat32() (*float32, error) {
+	return readNillableWith(a.ReaderCore, a.ReadFloat32)
+}
+
+func (a ReaderAdapter) ReadNillableFloat64() (*float64, error) {
+	return readNillableWith(a.ReaderCore, a.ReadFloat64)
+}
+
+func (a ReaderAdapter) ReadNillableString() (*string, error) {
+	return readNillableWith(a.ReaderCore, a.ReadString)
+}
+
+func (a ReaderAdapter) ReadNillableTime() (*time.Time, error) {
+	return readNillableWith(a.ReaderCore, a.ReadTime)
+}
+
+func (a ReaderAdapter) ReadNillableByteArray() ([]byte, error) {
+	if isNil, err := readNil(a.ReaderCore); isNil || err != nil {
+		return nil, err
+	}
+	return a.ReadByteArray()
+}
+
+func (a ReaderAdapter) ReadNillableRaw() (Raw, error) {
+	if isNil, err := readNil(a.ReaderCore); isNil || err != nil {
+		return nil, err
+	}
+	return a.ReadRaw()
+}
+
+// ReadNillableByteArrayStrict reads the value with ReadRaw, since
+// ReaderCore cannot tell what kind of value comes next.
+func (a ReaderAdapter) ReadNillableByteArrayStrict() ([]byte, bool, error) {
+	raw, err := a.ReadRaw()
+	if err != nil {
+		return nil, false, err
+	}
+	d := NewDecoder(raw)
+	return d.ReadNillableByteArrayStrict()
+}
+
+// ReadAny reads the value with ReadRaw and decodes it with a Decoder.
+func (a ReaderAdapter) ReadAny() (any, error) {
+	raw, err := a.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	d := NewDecoder(raw)
+	return d.ReadAny()
+}
+
+// ReadAnyWithRaw reads the value with ReadRaw and decodes it with a
+// Decoder.
+func (a ReaderAdapter) ReadAnyWithRaw() (any, Raw, error) {
+	raw, err := a.ReadRaw()
+	if err != nil {
+		return nil, nil, err
+	}
+	d := NewDecoder(raw)
+	value, err := d.ReadAny()
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, raw, nil
+}
+
+func (a ReaderAdapter) Skip() error {
+	_, err := a.ReadRaw()
+	return err
+}
+
+// WriterAdapter turns a WriterCore into a Writer by implementing the
+// NillableWriter and WriterExtras methods with the core ones, so a custom
+// writer only has to implement WriterCore:
+//
+//	var w Writer = &WriterAdapter{WriterCore: myCore}
+//
+// A core that also has a WriteRawBytes method is given the bytes of
+// WriteAny and WriteRaw as they are. Otherwise they are passed to the core
+// one element at a time, so integers come out in the smallest format for
+// their value whatever format they had, and extension values other than
+// timestamps cannot be passed on and make Err return an error. The Reserve
+// and Patch methods, and WriteRawBytes, need the core to have them and
+// otherwise make Err return an error.
+type WriterAdapter struct {
+	WriterCore
+	err error
+}
+
+var _ Writer = &WriterAdapter{}
+
+// rawBytesWriterCore is a WriterCore that can also write bytes as they
+// are.
+type rawBytesWriterCore interface {
+	WriteRawBytes(value []byte)
+}
+
+// headerWriterCore is a WriterCore that can also reserve headers and
+// patch them.
+type headerWriterCore interface {
+	ReserveArraySize() HeaderMark
+	PatchArraySize(mark HeaderMark, length uint32)
+	ReserveMapSize() HeaderMark
+	PatchMapSize(mark HeaderMark, length uint32)
+	ReserveStringHeader() HeaderMark
+	PatchStringHeader(mark HeaderMark, length uint32)
+	ReserveBinHeader() HeaderMark
+	PatchBinHeader(mark HeaderMark, length uint32)
+}
+
+var errNoReservedHeaders = WriteError{message: "msgpack: the WriterCore cannot write reserved headers"}
+
+// headers returns the core as a headerWriterCore, or records an error.
+func (a *WriterAdapter) headers() (headerWriterCore, bool) {
+	core, ok := a.WriterCore.(headerWriterCore)
+	if !ok {
+		a.setErr(errNoReservedHeaders)
+	}
+	return core, ok
+}
+
+func (a *WriterAdapter) ReserveArraySize() HeaderMark {
+	if core, ok := a.headers(); ok {
+		return core.ReserveArraySize()
+	}
+	return HeaderMark{}
+}
+
+func (a *WriterAdapter) PatchArraySize(mark HeaderMark, length uint32) {
+	if core, ok := a.headers(); ok {
+		core.PatchArraySize(mark, length)
+	}
+}
+
+func (a *WriterAdapter) ReserveMapSize() HeaderMark {
+	if core, ok := a.headers(); ok {
+		return core.ReserveMapSize()
+	}
+	return HeaderMark{}
+}
+
+func (a *WriterAdapter) PatchMapSize(mark HeaderMark, length uint32) {
+	if core, ok := a.headers(); ok {
+		core.PatchMapSize(mark, length)
+	}
+}
+
+func (a *WriterAdapter) ReserveStringHeader() HeaderMark {
+	if core, ok := a.headers(); ok {
+		return core.ReserveStringHeader()
+	}
+	return HeaderMark{}
+}
+
+func (a *WriterAdapter) PatchStringHeader(mark HeaderMark, length uint32) {
+	if core, ok := a.headers(); ok {
+		core.PatchStringHeader(mark, length)
+	}
+}
+
+func (a *WriterAdapter) ReserveBinHeader() HeaderMark {
+	if core, ok := a.headers(); ok {
+		return core.ReserveBinHeader()
+	}
+	return HeaderMark{}
+}
+
+func (a *WriterAdapter) PatchBinHeader(mark HeaderMark, length uint32) {
+	if core, ok := a.headers(); ok {
+		core.PatchBinHeader(mark, length)
+	}
+}
+
+func (a *WriterAdapter) WriteRawBytes(value []byte) {
+	core, ok := a.WriterCore.(rawBytesWriterCore)
+	if !ok {
+		a.setErr(WriteError{message: "msgpack: the WriterCore cannot write raw bytes"})
+		return
+	}
+	core.WriteRawBytes(value)
+}
+
+// Err returns the first error from the adapter or the core writer.
+func (a *WriterAdapter) Err() error {
+	if a.err != nil {
+		return a.err
+	}
+	return a.WriterCore.Err()
+}
+
+func writeNillableWith[T any](w WriterCore, value *T, write func(T)) {
+	if value == nil {
+		w.WriteNil()
+		return
+	}
+	write(*value)
+}
+
+func (a *WriterAdapter) WriteNillableBool(value *bool) {
+	writeNillableWith(a.WriterCore, value, a.WriteBool)
+}
+
+func (a *WriterAdapter) WriteNillableInt8(value *int8) {
+	writeNillableWith(a.WriterCore, value, a.WriteInt8)
+}
+
+func (a *WriterAdapter) WriteNillableInt16(value *int16) {
+	writeNillableWith(a.WriterCore, value, a.WriteInt16)
+}
+
+func (a *WriterAdapter) WriteNillableInt32(value *int32) {
+	writeNillableWith(a.WriterCore, value, a.WriteInt32)
+}
+
+func (a *WriterAdapter) WriteNillableInt64(value *int64) {
+	writeNillableWith(a.WriterCore, value, a.WriteInt64)
+}
+
+func (a *WriterAdapter) WriteNillableUint8(value *uint8) {
+	writeNillableWith(a.WriterCore, value, a.WriteUint8)
+}
+
+func (a *WriterAdapter) WriteNillableUint16(value *uint16) {
+	writeNillableWith(a.WriterCore, value, a.WriteUint16)
+}
+
+func (a *WriterAdapter) WriteNillableUint32(value *uint32) {
+	writeNillableWith(a.WriterCore, value, a.WriteUint32)
+}
+
+func (a *WriterAdapter) WriteNillableUint64(value *uint64) {
+	writeNillableWith(a.WriterCore, value, a.WriteUint64)
+}
+
+func (a *WriterAdapter) WriteNillableFloat32(value *float32) {
+	writeNillableWith(a.WriterCore, value, a.WriteFloat32)
+}
+
+func (a *WriterAdapter) WriteNillableFloat64(value *float64) {
+	writeNillableWith(a.WriterCore, value, a.WriteFloat64)
+}
+
+func (a *WriterAdapter) WriteNillableString(value *string) {
+	writeNillableWith(a.WriterCore, value, a.WriteString)
+}
+
+func (a *WriterAdapter) WriteNillableTime(value *time.Time) {
+	writeNillableWith(a.WriterCore, value, a.WriteTime)
+}
+
+func (a *WriterAdapter) WriteNillableByteArray(value []byte) {
+	if value == nil {
+		a.WriteNil()
+		return
+	}
+	a.WriteByteArray(value)
+}
+
+func (a *WriterAdapter) WriteNillableRaw(value Raw) {
+	if value == nil {
+		a.WriteNil()
+		return
+	}
+	a.WriteRaw(value)
+}
+
+func (a *WriterAdapter) WriteByteArrayVec(segments ...[]byte) {
+	var joined []byte
+	for _, segment := range segments {
+		joined = append(joined, segment...)
+	}
+	a.WriteByteArray(joined)
+}
+
+func (a *WriterAdapter) WriteStringVec(segments ...string) {
+	a.WriteString(strings.Join(segments, ""))
+}
+
+// WriteAny encodes `value` with an Encoder and passes it on as WriteRaw
+// does.
+func (a *WriterAdapter) WriteAny(value any) {
+	var sizer Sizer
+	sizer.WriteAny(value)
+	encoder := NewEncoder(make([]byte, sizer.Len()))
+	encoder.WriteAny(value)
+	if err := encoder.Err(); err != nil {
+		a.setErr(err)
+		return
+	}
+	a.WriteRaw(encoder.Bytes())
+}
+
+func (a *WriterAdapter) WriteStringAnyMap(value map[string]any) {
+	a.WriteMapSize(uint32(len(value)))
+	for k, v := range value {
+		a.WriteString(k)
+		a.WriteAny(v)
+	}
+}
+
+// WriteRaw passes `value` to the WriteRawBytes method of the core when it
+// has one, and otherwise decodes it and writes each of its elements with
+// the core writer.
+func (a *WriterAdapter) WriteRaw(value Raw) {
+	if len(value) == 0 {
+		a.setErr(errEmptyRaw)
+		return
+	}
+	if core, ok := a.WriterCore.(rawBytesWriterCore); ok {
+		core.WriteRawBytes(value)
+		return
+	}
+	d := NewDecoder(value)
+	for d.Remaining() > 0 {
+		if err := copyValue(a.WriterCore, &d); err != nil {
+			a.setErr(err)
+			return
+		}
+	}
+}
+
+func (a *WriterAdapter) setErr(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+// copyValue reads the next value from `d` and writes it to `w`.
+func copyValue(w WriterCore, d *Decoder) error {
+	prefix, err := d.PeekFormat()
+	if err != nil {
+		return err
+	}
+	switch formatKind(prefix) {
+	case "array":
+		size, err := d.ReadArraySize()
+		if err != nil {
+			return err
+		}
+		w.WriteArraySize(size)
+		for i := uint32(0); i < size; i++ {
+			if err := copyValue(w, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "map":
+		size, err := d.ReadMapSize()
+		if err != nil {
+			return err
+		}
+		w.WriteMapSize(size)
+		for i := uint32(0); i < 2*size; i++ {
+			if err := copyValue(w, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "string":
+		s, err := d.ReadString()
+		w.WriteString(s)
+		return err
+	case "bin":
+		b, err := d.ReadByteArray()
+		w.WriteByteArray(b)
+		return err
+	case "float":
+		if prefix == FormatFloat32 {
+			f, err := d.ReadFloat32()
+			w.WriteFloat32(f)
+			return err
+		}
+		f, err := d.ReadFloat64()
+		w.WriteFloat64(f)
+		return err
+	case "ext":
+		start := d.Offset()
+		t, err := d.ReadTime()
+		if err != nil {
+			return WriteError{message: "msgpack: cannot pass on the ext value at offset " +
+				strconv.FormatUint(uint64(start), 10) + " through a WriterCore: " + err.Error()}
+		}
+		w.WriteTime(t)
+		return nil
+	}
+	v, err := readNormalizedValue(d)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		w.WriteNil()
+	case bool:
+		w.WriteBool(v)
+	case int64:
+		w.WriteInt64(v)
+	case uint64:
+		w.WriteUint64(v)
+	}
+	return nil
+}
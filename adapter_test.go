@@ -0,0 +1,175 @@
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// coreReader and coreWriter hide everything but the core methods, like a
+// third-party implementation would. coreReader keeps ReadRaw, which
+// ReaderAdapter needs for the values of any kind.
+type coreReader struct{ msgpack.ReaderCore }
+
+func (r coreReader) ReadRaw() (msgpack.Raw, error) {
+	return r.ReaderCore.(msgpack.Reader).ReadRaw()
+}
+
+type coreWriter struct{ msgpack.WriterCore }
+
+// rawBytesWriter is a coreWriter that can also write bytes as they are.
+type rawBytesWriter struct{ coreWriter }
+
+func (w rawBytesWriter) WriteRawBytes(value []byte) {
+	w.WriterCore.(msgpack.Writer).WriteRawBytes(value)
+}
+
+func TestAdapterRoundTrip(t *testing.T) {
+	email := "ada@example.com"
+	person := Person{
+		Name:     "Ada",
+		Age:      36,
+		Email:    &email,
+		Tags:     []string{"math", "engines"},
+		Scores:   []int64{-1, 300, 70000},
+		Joined:   time.Unix(1700000000, 0),
+		Home:     Address{Street: "1 Main St", City: "London"},
+		Metadata: msgpack.Raw{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x92, 0xc3, 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0},
+	}
+	expected := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, personCodec.Encode(w, &person))
+	})
+
+	encoder := msgpack.NewEncoder(make([]byte, len(expected)))
+	writer := &msgpack.WriterAdapter{WriterCore: coreWriter{&encoder}}
+	require.NoError(t, personCodec.Encode(writer, &person))
+	require.NoError(t, writer.Err())
+	assert.Equal(t, expected, encoder.Bytes())
+
+	decoder := msgpack.NewDecoder(expected)
+	reader := msgpack.ReaderAdapter{ReaderCore: coreReader{&decoder}}
+	var decoded Person
+	require.NoError(t, personCodec.Decode(reader, &decoded))
+	assert.Equal(t, person.Name, decoded.Name)
+	assert.Equal(t, person.Email, decoded.Email)
+	assert.Equal(t, person.Tags, decoded.Tags)
+	assert.Equal(t, person.Scores, decoded.Scores)
+	assert.True(t, person.Joined.Equal(decoded.Joined))
+	assert.Equal(t, person.Home, decoded.Home)
+	assert.Nil(t, decoded.Work)
+	assert.Equal(t, person.Metadata, decoded.Metadata)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+}
+
+func TestReaderAdapterExtras(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(6)
+		w.WriteNil()
+		w.WriteInt32(-7)
+		w.WriteNil()
+		w.WriteByteArray([]byte{})
+		w.WriteMapSize(1)
+		w.WriteString("k")
+		w.WriteString("v")
+		w.WriteString("skipped")
+	})
+	decoder := msgpack.NewDecoder(data)
+	reader := msgpack.ReaderAdapter{ReaderCore: coreReader{&decoder}}
+
+	_, err := reader.ReadArraySize()
+	require.NoError(t, err)
+	isNil, err := reader.IsNextNil()
+	require.NoError(t, err)
+	assert.True(t, isNil)
+	i, err := reader.ReadNillableInt32()
+	require.NoError(t, err)
+	assert.Equal(t, int32(-7), *i)
+	s, err := reader.ReadNillableString()
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	b, isNil, err := reader.ReadNillableByteArrayStrict()
+	require.NoError(t, err)
+	assert.False(t, isNil)
+	assert.Equal(t, []byte{}, b)
+	m, err := reader.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, map[any]any{"k": "v"}, m)
+	require.NoError(t, reader.Skip())
+	assert.Equal(t, uint32(0), decoder.Remaining())
+}
+
+func TestWriterAdapterExtras(t *testing.T) {
+	write := func(w msgpack.Writer) {
+		n := int64(5)
+		w.WriteArraySize(5)
+		w.WriteNillableInt64(&n)
+		w.WriteNillableString(nil)
+		w.WriteByteArrayVec([]byte("ab"), []byte("cd"))
+		w.WriteStringVec("ab", "cd")
+		w.WriteAny([]any{"x", 1.5, true, nil, []byte{1}})
+	}
+	expected := encodeWith(t, write)
+
+	encoder := msgpack.NewEncoder(make([]byte, len(expected)))
+	writer := &msgpack.WriterAdapter{WriterCore: coreWriter{&encoder}}
+	write(writer)
+	require.NoError(t, writer.Err())
+	assert.Equal(t, expected, encoder.Bytes())
+
+	// Sizing goes through the adapter the same way.
+	var sizer msgpack.Sizer
+	write(&msgpack.WriterAdapter{WriterCore: coreWriter{&sizer}})
+	assert.Equal(t, uint32(len(expected)), sizer.Len())
+}
+
+func TestWriterAdapterExt(t *testing.T) {
+	var sizer msgpack.Sizer
+	writer := &msgpack.WriterAdapter{WriterCore: coreWriter{&sizer}}
+	writer.WriteRaw(msgpack.Raw{msgpack.FormatFixExt1, 7, 0})
+	assert.EqualError(t, writer.Err(),
+		"msgpack: cannot pass on the ext value at offset 0 through a WriterCore: msgpack: invalid time ext id=7")
+}
+
+func TestWriterAdapterRawBytes(t *testing.T) {
+	// An int16 holding 1 and an ext value, neither of which survive being
+	// passed on one element at a time.
+	raw := msgpack.Raw{0x92, 0xd1, 0x00, 0x01, msgpack.FormatFixExt1, 7, 0}
+
+	encoder := msgpack.NewEncoder(make([]byte, 64))
+	writer := &msgpack.WriterAdapter{WriterCore: rawBytesWriter{coreWriter{&encoder}}}
+	writer.WriteRaw(raw)
+	mark := writer.ReserveStringHeader()
+	assert.EqualError(t, writer.Err(), "msgpack: the WriterCore cannot write reserved headers")
+	assert.Equal(t, msgpack.HeaderMark{}, mark)
+	assert.Equal(t, []byte(raw), encoder.Bytes())
+
+	encoder = msgpack.NewEncoder(make([]byte, 64))
+	writer = &msgpack.WriterAdapter{WriterCore: &encoder}
+	mark = writer.ReserveArraySize()
+	writer.WriteRawBytes([]byte{0x01, 0x02})
+	writer.PatchArraySize(mark, 2)
+	require.NoError(t, writer.Err())
+	assert.Equal(t, []byte{0xdd, 0, 0, 0, 2, 0x01, 0x02}, encoder.Bytes())
+
+	writer = &msgpack.WriterAdapter{WriterCore: coreWriter{&encoder}}
+	writer.WriteRawBytes([]byte{0xc0})
+	assert.EqualError(t, writer.Err(), "msgpack: the WriterCore cannot write raw bytes")
+}
+
+func TestReaderAdapterWithoutReadRaw(t *testing.T) {
+	type minimalReader struct{ msgpack.ReaderCore }
+	decoder := msgpack.NewDecoder([]byte{0x92, 0x01, 0x02})
+	reader := msgpack.ReaderAdapter{ReaderCore: minimalReader{&decoder}}
+	_, err := reader.ReadAny()
+	assert.EqualError(t, err, "msgpack: the ReaderCore cannot read raw values")
+	assert.EqualError(t, reader.Skip(), "msgpack: the ReaderCore cannot read raw values")
+
+	// The core methods still work.
+	size, err := reader.ReadArraySize()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), size)
+}
@@ -0,0 +1,515 @@
+//go:build !tinygo
+// +build !tinygo
+
+// Package analysis reports what MessagePack payloads are made of, to help
+// decide whether encodings such as positional structs, string tables or
+// narrower floats are worth adopting.
+package analysis
+
+import (
+	"encoding/json"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// DefaultTopStrings is the number of repeated strings listed by String and
+// the JSON rendering of a Report.
+const DefaultTopStrings = 10
+
+// Stats counts values and the bytes they take.
+type Stats struct {
+	Count uint64 `json:"count"`
+	Bytes uint64 `json:"bytes"`
+}
+
+func (s *Stats) add(bytes uint64) {
+	s.Count++
+	s.Bytes += bytes
+}
+
+// Report describes the values in one or more payloads.
+//
+// Every byte is attributed to exactly one value: a scalar's bytes are the
+// whole encoded value and an array or map's bytes are only its header.
+// The Bytes of all Families, and KeyBytes+ValueBytes+HeaderBytes, add up to
+// Bytes.
+type Report struct {
+	// Documents is the number of top level values.
+	Documents uint64 `json:"documents"`
+	// Values is the number of values at any depth.
+	Values uint64 `json:"values"`
+	// Bytes is the total size of the payloads.
+	Bytes uint64 `json:"bytes"`
+
+	// Families groups values by kind: nil, bool, int, float, string, bin,
+	// array, map and ext.
+	Families map[string]Stats `json:"families"`
+	// Formats groups values by exact format, such as fixint or uint32.
+	Formats map[string]Stats `json:"formats"`
+
+	// KeyBytes is the size of map keys, including anything nested in them.
+	KeyBytes uint64 `json:"keyBytes"`
+	// ValueBytes is the size of scalars that are not map keys.
+	ValueBytes uint64 `json:"valueBytes"`
+	// HeaderBytes is the size of array and map headers outside map keys.
+	HeaderBytes uint64 `json:"headerBytes"`
+
+	// Depths counts values by nesting depth; top level values are at 0.
+	Depths []uint64 `json:"depths"`
+	// StringLengths is a histogram of string lengths in powers of two.
+	// Bucket 0 counts empty strings and bucket i counts lengths from
+	// 2^(i-1) to 2^i-1.
+	StringLengths []uint64 `json:"stringLengths"`
+	// Strings counts the occurrences of every string, keys included.
+	Strings map[string]uint64 `json:"-"`
+
+	// WastedBytes is how many bytes a minimal encoding would have saved:
+	// integers, lengths and ext sizes in wider formats than needed, and
+	// float64 values that a float32 holds exactly.
+	WastedBytes uint64 `json:"wastedBytes"`
+}
+
+// StringCount is a string and the number of times it occurs.
+type StringCount struct {
+	Value string `json:"value"`
+	Count uint64 `json:"count"`
+}
+
+// Analyze reports on `data`, which holds one or more values back to back.
+func Analyze(data []byte) (Report, error) {
+	r := newReport()
+	r.Bytes = uint64(len(data))
+	d := msgpack.NewDecoder(data)
+	for d.Remaining() > 0 {
+		r.Documents++
+		if err := r.value(&d, 0, false); err != nil {
+			return Report{}, err
+		}
+	}
+	return r, nil
+}
+
+// MergeReports combines reports, such as one per payload of a corpus.
+func MergeReports(reports ...Report) Report {
+	merged := newReport()
+	for _, r := range reports {
+		merged.Documents += r.Documents
+		merged.Values += r.Values
+		merged.Bytes += r.Bytes
+		mergeStats(merged.Families, r.Families)
+		mergeStats(merged.Formats, r.Formats)
+		merged.KeyBytes += r.KeyBytes
+		merged.ValueBytes += r.ValueBytes
+		merged.HeaderBytes += r.HeaderBytes
+		merged.Depths = mergeCounts(merged.Depths, r.Depths)
+		merged.StringLengths = mergeCounts(merged.StringLengths, r.StringLengths)
+		for s, n := range r.Strings {
+			merged.Strings[s] += n
+		}
+		merged.WastedBytes += r.WastedBytes
+	}
+	return merged
+}
+
+// TopStrings returns the `n` strings that occur most often, among those
+// occurring more than once, most frequent first. Ties are ordered by value.
+func (r Report) TopStrings(n int) []StringCount {
+	var top []StringCount
+	for s, count := range r.Strings {
+		if count > 1 {
+			top = append(top, StringCount{s, count})
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Value < top[j].Value
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// MarshalJSON renders the report with its DefaultTopStrings most repeated
+// strings in place of the full string counts.
+func (r Report) MarshalJSON() ([]byte, error) {
+	type report Report
+	return json.Marshal(struct {
+		report
+		TopStrings []StringCount `json:"topStrings"`
+	}{report(r), r.TopStrings(DefaultTopStrings)})
+}
+
+// String renders the report as text.
+func (r Report) String() string {
+	var b strings.Builder
+	line := func(label string, value uint64) {
+		b.WriteString(label)
+		b.WriteString(": ")
+		b.WriteString(strconv.FormatUint(value, 10))
+		b.WriteByte('\n')
+	}
+	line("documents", r.Documents)
+	line("values", r.Values)
+	line("bytes", r.Bytes)
+	line("key bytes", r.KeyBytes)
+	line("value bytes", r.ValueBytes)
+	line("header bytes", r.HeaderBytes)
+	line("wasted bytes", r.WastedBytes)
+	writeStats(&b, "families", r.Families)
+	writeStats(&b, "formats", r.Formats)
+	b.WriteString("depths:\n")
+	for depth, n := range r.Depths {
+		b.WriteString("  " + strconv.Itoa(depth) + ": " + strconv.FormatUint(n, 10) + "\n")
+	}
+	b.WriteString("string lengths:\n")
+	for i, n := range r.StringLengths {
+		if n == 0 {
+			continue
+		}
+		b.WriteString("  " + lengthBucket(i) + ": " + strconv.FormatUint(n, 10) + "\n")
+	}
+	b.WriteString("top strings:\n")
+	for _, s := range r.TopStrings(DefaultTopStrings) {
+		b.WriteString("  " + strconv.Quote(s.Value) + ": " + strconv.FormatUint(s.Count, 10) + "\n")
+	}
+	return b.String()
+}
+
+func newReport() Report {
+	return Report{
+		Families: map[string]Stats{},
+		Formats:  map[string]Stats{},
+		Strings:  map[string]uint64{},
+	}
+}
+
+func (r *Report) value(d *msgpack.Decoder, depth int, inKey bool) error {
+	start := d.Offset()
+	prefix, err := d.PeekFormat()
+	if err != nil {
+		return err
+	}
+	family, format := classify(prefix)
+
+	var length uint32
+	var wasted uint64
+	switch family {
+	case "array":
+		if length, err = d.ReadArraySize(); err == nil {
+			wasted = headerWaste(d.Offset()-start, containerHeaderSize(length))
+		}
+	case "map":
+		if length, err = d.ReadMapSize(); err == nil {
+			wasted = headerWaste(d.Offset()-start, containerHeaderSize(length))
+		}
+	case "int":
+		wasted, err = intWaste(d, prefix)
+	case "float":
+		var f float64
+		if f, err = d.ReadFloat64(); err == nil && prefix == msgpack.FormatFloat64 &&
+			float64(float32(f)) == f {
+			wasted = 4
+		}
+	case "string":
+		var s string
+		if s, err = d.ReadString(); err == nil {
+			r.Strings[s]++
+			r.StringLengths = addCount(r.StringLengths, bits.Len(uint(len(s))))
+			wasted = headerWaste(d.Offset()-start-uint32(len(s)), strHeaderSize(len(s)))
+		}
+	case "bin":
+		var b []byte
+		if b, err = d.ReadByteArray(); err == nil {
+			wasted = headerWaste(d.Offset()-start-uint32(len(b)), binHeaderSize(len(b)))
+		}
+	case "ext":
+		var raw msgpack.Raw
+		if raw, err = d.ReadRaw(); err == nil {
+			wasted = extWaste(prefix, len(raw))
+		}
+	default:
+		_, err = d.ReadRaw()
+	}
+	if err != nil {
+		return err
+	}
+
+	size := uint64(d.Offset() - start)
+	r.Values++
+	r.Depths = addCount(r.Depths, depth)
+	r.WastedBytes += wasted
+	stats := r.Families[family]
+	stats.add(size)
+	r.Families[family] = stats
+	stats = r.Formats[format]
+	stats.add(size)
+	r.Formats[format] = stats
+	switch {
+	case inKey:
+		r.KeyBytes += size
+	case family == "array" || family == "map":
+		r.HeaderBytes += size
+	default:
+		r.ValueBytes += size
+	}
+
+	switch family {
+	case "array":
+		for i := uint32(0); i < length; i++ {
+			if err := r.value(d, depth+1, inKey); err != nil {
+				return err
+			}
+		}
+	case "map":
+		for i := uint32(0); i < length; i++ {
+			if err := r.value(d, depth+1, true); err != nil {
+				return err
+			}
+			if err := r.value(d, depth+1, inKey); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// classify returns the family and format names of `prefix`.
+func classify(prefix byte) (family, format string) {
+	switch {
+	case prefix <= 0x7f:
+		return "int", "fixint"
+	case prefix >= msgpack.FormatNegativeFixInt:
+		return "int", "negfixint"
+	case prefix&0xf0 == msgpack.FormatFixMap:
+		return "map", "fixmap"
+	case prefix&0xf0 == msgpack.FormatFixArray:
+		return "array", "fixarray"
+	case prefix&0xe0 == msgpack.FormatFixString:
+		return "string", "fixstr"
+	}
+	switch prefix {
+	case msgpack.FormatNil:
+		return "nil", "nil"
+	case msgpack.FormatTrue, msgpack.FormatFalse:
+		return "bool", "bool"
+	case msgpack.FormatBin8:
+		return "bin", "bin8"
+	case msgpack.FormatBin16:
+		return "bin", "bin16"
+	case msgpack.FormatBin32:
+		return "bin", "bin32"
+	case msgpack.FormatExt8:
+		return "ext", "ext8"
+	case msgpack.FormatExt16:
+		return "ext", "ext16"
+	case msgpack.FormatExt32:
+		return "ext", "ext32"
+	case msgpack.FormatFloat32:
+		return "float", "float32"
+	case msgpack.FormatFloat64:
+		return "float", "float64"
+	case msgpack.FormatUint8:
+		return "int", "uint8"
+	case msgpack.FormatUint16:
+		return "int", "uint16"
+	case msgpack.FormatUint32:
+		return "int", "uint32"
+	case msgpack.FormatUint64:
+		return "int", "uint64"
+	case msgpack.FormatInt8:
+		return "int", "int8"
+	case msgpack.FormatInt16:
+		return "int", "int16"
+	case msgpack.FormatInt32:
+		return "int", "int32"
+	case msgpack.FormatInt64:
+		return "int", "int64"
+	case msgpack.FormatFixExt1:
+		return "ext", "fixext1"
+	case msgpack.FormatFixExt2:
+		return "ext", "fixext2"
+	case msgpack.FormatFixExt4:
+		return "ext", "fixext4"
+	case msgpack.FormatFixExt8:
+		return "ext", "fixext8"
+	case msgpack.FormatFixExt16:
+		return "ext", "fixext16"
+	case msgpack.FormatString8:
+		return "string", "str8"
+	case msgpack.FormatString16:
+		return "string", "str16"
+	case msgpack.FormatString32:
+		return "string", "str32"
+	case msgpack.FormatArray16:
+		return "array", "array16"
+	case msgpack.FormatArray32:
+		return "array", "array32"
+	case msgpack.FormatMap16:
+		return "map", "map16"
+	case msgpack.FormatMap32:
+		return "map", "map32"
+	}
+	return "reserved", "reserved"
+}
+
+// intWaste reads an integer and returns how many bytes larger its encoding
+// is than the smallest one for its value.
+func intWaste(d *msgpack.Decoder, prefix byte) (uint64, error) {
+	start := d.Offset()
+	var minimal uint32
+	switch prefix {
+	case msgpack.FormatUint8, msgpack.FormatUint16, msgpack.FormatUint32, msgpack.FormatUint64:
+		v, err := d.ReadUint64()
+		if err != nil {
+			return 0, err
+		}
+		minimal = uintSize(v)
+	default:
+		v, err := d.ReadInt64()
+		if err != nil {
+			return 0, err
+		}
+		if v >= 0 {
+			minimal = uintSize(uint64(v))
+		} else {
+			minimal = negativeIntSize(v)
+		}
+	}
+	return headerWaste(d.Offset()-start, minimal), nil
+}
+
+func uintSize(v uint64) uint32 {
+	switch {
+	case v <= 0x7f:
+		return 1
+	case v <= math.MaxUint8:
+		return 2
+	case v <= math.MaxUint16:
+		return 3
+	case v <= math.MaxUint32:
+		return 5
+	}
+	return 9
+}
+
+func negativeIntSize(v int64) uint32 {
+	switch {
+	case v >= -32:
+		return 1
+	case v >= math.MinInt8:
+		return 2
+	case v >= math.MinInt16:
+		return 3
+	case v >= math.MinInt32:
+		return 5
+	}
+	return 9
+}
+
+func containerHeaderSize(length uint32) uint32 {
+	switch {
+	case length < 16:
+		return 1
+	case length <= math.MaxUint16:
+		return 3
+	}
+	return 5
+}
+
+func strHeaderSize(length int) uint32 {
+	switch {
+	case length < 32:
+		return 1
+	case length <= math.MaxUint8:
+		return 2
+	case length <= math.MaxUint16:
+		return 3
+	}
+	return 5
+}
+
+func binHeaderSize(length int) uint32 {
+	switch {
+	case length <= math.MaxUint8:
+		return 2
+	case length <= math.MaxUint16:
+		return 3
+	}
+	return 5
+}
+
+// extWaste returns the bytes an ext8 holding a fixext length wastes.
+func extWaste(prefix byte, size int) uint64 {
+	if prefix != msgpack.FormatExt8 {
+		return 0
+	}
+	switch size - 3 {
+	case 1, 2, 4, 8, 16:
+		return 1
+	}
+	return 0
+}
+
+func headerWaste(actual, minimal uint32) uint64 {
+	if actual <= minimal {
+		return 0
+	}
+	return uint64(actual - minimal)
+}
+
+func addCount(counts []uint64, i int) []uint64 {
+	for len(counts) <= i {
+		counts = append(counts, 0)
+	}
+	counts[i]++
+	return counts
+}
+
+func mergeCounts(dst, src []uint64) []uint64 {
+	for len(dst) < len(src) {
+		dst = append(dst, 0)
+	}
+	for i, n := range src {
+		dst[i] += n
+	}
+	return dst
+}
+
+func mergeStats(dst, src map[string]Stats) {
+	for name, s := range src {
+		d := dst[name]
+		d.Count += s.Count
+		d.Bytes += s.Bytes
+		dst[name] = d
+	}
+}
+
+func writeStats(b *strings.Builder, title string, stats map[string]Stats) {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b.WriteString(title + ":\n")
+	for _, name := range names {
+		s := stats[name]
+		b.WriteString("  " + name + ": " + strconv.FormatUint(s.Count, 10) + " values, " +
+			strconv.FormatUint(s.Bytes, 10) + " bytes\n")
+	}
+}
+
+func lengthBucket(i int) string {
+	if i == 0 {
+		return "0"
+	}
+	low := uint64(1) << (i - 1)
+	return strconv.FormatUint(low, 10) + "-" + strconv.FormatUint(2*low-1, 10)
+}
@@ -0,0 +1,133 @@
+//go:build !tinygo
+// +build !tinygo
+
+package analysis_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/analysis"
+)
+
+// {"id": uint64(1), "name": "ok", "tags": ["ok", "ok"], "score": 1.5}
+var document = []byte{
+	0x84,
+	0xa2, 'i', 'd', 0xcf, 0, 0, 0, 0, 0, 0, 0, 1,
+	0xa4, 'n', 'a', 'm', 'e', 0xa2, 'o', 'k',
+	0xa4, 't', 'a', 'g', 's', 0x92, 0xa2, 'o', 'k', 0xa2, 'o', 'k',
+	0xa5, 's', 'c', 'o', 'r', 'e', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+}
+
+func TestAnalyze(t *testing.T) {
+	r, err := analysis.Analyze(document)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), r.Documents)
+	assert.Equal(t, uint64(11), r.Values)
+	assert.Equal(t, uint64(48), r.Bytes)
+	assert.Equal(t, map[string]analysis.Stats{
+		"map":    {Count: 1, Bytes: 1},
+		"array":  {Count: 1, Bytes: 1},
+		"string": {Count: 7, Bytes: 28},
+		"int":    {Count: 1, Bytes: 9},
+		"float":  {Count: 1, Bytes: 9},
+	}, r.Families)
+	assert.Equal(t, analysis.Stats{Count: 1, Bytes: 9}, r.Formats["uint64"])
+	assert.Equal(t, analysis.Stats{Count: 7, Bytes: 28}, r.Formats["fixstr"])
+	assert.Equal(t, uint64(19), r.KeyBytes)
+	assert.Equal(t, uint64(27), r.ValueBytes)
+	assert.Equal(t, uint64(2), r.HeaderBytes)
+	assert.Equal(t, []uint64{1, 8, 2}, r.Depths)
+	assert.Equal(t, []uint64{0, 0, 4, 3}, r.StringLengths)
+	// 8 bytes from the uint64 that fits a fixint, 4 from the float64.
+	assert.Equal(t, uint64(12), r.WastedBytes)
+	assert.Equal(t, []analysis.StringCount{{Value: "ok", Count: 3}}, r.TopStrings(10))
+}
+
+func TestAnalyzeWaste(t *testing.T) {
+	tests := []struct {
+		data   []byte
+		wasted uint64
+	}{
+		{[]byte{0xd9, 0x02, 'o', 'k'}, 1},
+		{[]byte{0xc5, 0x00, 0x01, 0xff}, 1},
+		{[]byte{0xdc, 0x00, 0x01, 0x01}, 2},
+		{[]byte{0xd1, 0xff, 0x80}, 1},
+		{[]byte{0xd2, 0xff, 0xff, 0xff, 0xff}, 4},
+		{[]byte{0xcb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}, 0},
+		{[]byte{0xc7, 0x01, 0x05, 0x00}, 1},
+		{[]byte{0xd0, 0x80}, 0},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r, err := analysis.Analyze(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wasted, r.WastedBytes)
+		})
+	}
+}
+
+func TestMergeReportsTopStrings(t *testing.T) {
+	var reports []analysis.Report
+	for i := 0; i < 3; i++ {
+		buffer := make([]byte, 256)
+		encoder := msgpack.NewEncoder(buffer)
+		encoder.WriteArraySize(uint32(3 + i))
+		encoder.WriteString("common")
+		encoder.WriteString("common")
+		encoder.WriteString("once-" + strconv.Itoa(i))
+		for j := 0; j < i; j++ {
+			encoder.WriteString("growing")
+		}
+		require.NoError(t, encoder.Err())
+		r, err := analysis.Analyze(encoder.Bytes())
+		require.NoError(t, err)
+		reports = append(reports, r)
+	}
+
+	merged := analysis.MergeReports(reports...)
+	assert.Equal(t, uint64(3), merged.Documents)
+	assert.Equal(t, uint64(3+3+4+5), merged.Values)
+	assert.Equal(t, reports[0].Bytes+reports[1].Bytes+reports[2].Bytes, merged.Bytes)
+	assert.Equal(t, []analysis.StringCount{
+		{Value: "common", Count: 6},
+		{Value: "growing", Count: 3},
+	}, merged.TopStrings(5))
+	assert.Equal(t, []analysis.StringCount{{Value: "common", Count: 6}}, merged.TopStrings(1))
+
+	var total uint64
+	for _, s := range merged.Families {
+		total += s.Bytes
+	}
+	assert.Equal(t, merged.Bytes, total)
+}
+
+func TestReportRendering(t *testing.T) {
+	r, err := analysis.Analyze(document)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	data, err := json.Marshal(r)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, float64(48), decoded["bytes"])
+	assert.Equal(t, []any{map[string]any{"value": "ok", "count": float64(3)}}, decoded["topStrings"])
+	assert.NotContains(t, decoded, "Strings")
+
+	text := r.String()
+	assert.Contains(t, text, "bytes: 48\n")
+	assert.Contains(t, text, "  string: 7 values, 28 bytes\n")
+	assert.Contains(t, text, "  4-7: 3\n")
+	assert.Contains(t, text, "  \"ok\": 3\n")
+}
+
+func TestAnalyzeTruncated(t *testing.T) {
+	_, err := analysis.Analyze(document[:len(document)-1])
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
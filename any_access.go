@@ -0,0 +1,112 @@
+package msgpack
+
+import "math"
+
+// The Any accessors read values out of the trees ReadAny and NormalizeAny
+// return without type assertions that can panic. Each reports false when
+// the value has another type or the element does not exist.
+
+// AnyIndex returns element `i` of a []any.
+func AnyIndex(v any, i int) (any, bool) {
+	array, ok := v.([]any)
+	if !ok || i < 0 || i >= len(array) {
+		return nil, false
+	}
+	return array[i], true
+}
+
+// AnyKey returns the value stored under the string key `key` in a
+// map[string]any or map[any]any.
+func AnyKey(v any, key string) (any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		value, ok := m[key]
+		return value, ok
+	case map[any]any:
+		value, ok := m[key]
+		return value, ok
+	}
+	return nil, false
+}
+
+// AnyPath follows `path` through `v` with AnyKey for string segments and
+// AnyIndex for int segments, as GetPath does for encoded data.
+func AnyPath(v any, path ...any) (any, bool) {
+	for _, segment := range path {
+		var ok bool
+		switch s := segment.(type) {
+		case string:
+			v, ok = AnyKey(v, s)
+		case int:
+			v, ok = AnyIndex(v, s)
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// AnyString returns the value of a string.
+func AnyString(v any) (string, bool) {
+	s, ok := v.(string)
+	return s, ok
+}
+
+// AnyInt returns the value of an integer of any Go integer type that fits
+// in an int64, which covers every integer ReadAny and NormalizeAny return.
+func AnyInt(v any) (int64, bool) {
+	switch i := v.(type) {
+	case int:
+		return int64(i), true
+	case int8:
+		return int64(i), true
+	case int16:
+		return int64(i), true
+	case int32:
+		return int64(i), true
+	case int64:
+		return i, true
+	case uint:
+		return uintToInt64(uint64(i))
+	case uint8:
+		return int64(i), true
+	case uint16:
+		return int64(i), true
+	case uint32:
+		return int64(i), true
+	case uint64:
+		return uintToInt64(i)
+	}
+	return 0, false
+}
+
+func uintToInt64(u uint64) (int64, bool) {
+	if u > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(u), true
+}
+
+// AnyFloat returns the value of a float32 or float64.
+func AnyFloat(v any) (float64, bool) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), true
+	case float64:
+		return f, true
+	}
+	return 0, false
+}
+
+// AnyBool returns the value of a bool.
+func AnyBool(v any) (bool, bool) {
+	b, ok := v.(bool)
+	return b, ok
+}
+
+// AnyBytes returns the value of a []byte.
+func AnyBytes(v any) ([]byte, bool) {
+	b, ok := v.([]byte)
+	return b, ok
+}
@@ -0,0 +1,177 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestAnyIndex(t *testing.T) {
+	array := []any{"a", nil}
+	v, ok := msgpack.AnyIndex(array, 0)
+	assert.True(t, ok)
+	assert.Equal(t, "a", v)
+	v, ok = msgpack.AnyIndex(array, 1)
+	assert.True(t, ok)
+	assert.Nil(t, v)
+
+	for _, i := range []int{-1, 2, 100} {
+		_, ok = msgpack.AnyIndex(array, i)
+		assert.False(t, ok, "index %d", i)
+	}
+	_, ok = msgpack.AnyIndex([]string{"a"}, 0)
+	assert.False(t, ok)
+	_, ok = msgpack.AnyIndex(nil, 0)
+	assert.False(t, ok)
+}
+
+func TestAnyKey(t *testing.T) {
+	for _, m := range []any{
+		map[string]any{"a": int64(1), "n": nil},
+		map[any]any{"a": int64(1), "n": nil, int64(2): "two"},
+	} {
+		v, ok := msgpack.AnyKey(m, "a")
+		assert.True(t, ok)
+		assert.Equal(t, int64(1), v)
+		v, ok = msgpack.AnyKey(m, "n")
+		assert.True(t, ok, "a key holding nil exists")
+		assert.Nil(t, v)
+		_, ok = msgpack.AnyKey(m, "missing")
+		assert.False(t, ok)
+		_, ok = msgpack.AnyKey(m, "2")
+		assert.False(t, ok)
+	}
+	_, ok := msgpack.AnyKey(map[string]string{"a": "b"}, "a")
+	assert.False(t, ok)
+	_, ok = msgpack.AnyKey([]any{"a"}, "a")
+	assert.False(t, ok)
+}
+
+func TestAnyPath(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("records")
+		w.WriteArraySize(2)
+		w.WriteNil()
+		w.WriteMapSize(1)
+		w.WriteString("name")
+		w.WriteString("ada")
+	})
+	decoder := msgpack.NewDecoder(data)
+	tree, err := decoder.ReadAny()
+	require.NoError(t, err)
+
+	v, ok := msgpack.AnyPath(tree, "records", 1, "name")
+	assert.True(t, ok)
+	assert.Equal(t, "ada", v)
+	v, ok = msgpack.AnyPath(tree)
+	assert.True(t, ok)
+	assert.Equal(t, tree, v)
+
+	for _, path := range [][]any{
+		{"records", 2},
+		{"records", 0, "name"},
+		{"records", "1"},
+		{"records", 1, "name", 0},
+		{0},
+		{"records", int64(1)},
+	} {
+		_, ok := msgpack.AnyPath(tree, path...)
+		assert.False(t, ok, "path %v", path)
+	}
+}
+
+func TestAnyInt(t *testing.T) {
+	accepted := []struct {
+		value any
+		want  int64
+	}{
+		{int(-1), -1},
+		{int8(math.MinInt8), math.MinInt8},
+		{int16(math.MinInt16), math.MinInt16},
+		{int32(math.MinInt32), math.MinInt32},
+		{int64(math.MinInt64), math.MinInt64},
+		{uint(7), 7},
+		{uint8(math.MaxUint8), math.MaxUint8},
+		{uint16(math.MaxUint16), math.MaxUint16},
+		{uint32(math.MaxUint32), math.MaxUint32},
+		{uint64(math.MaxInt64), math.MaxInt64},
+	}
+	for _, tt := range accepted {
+		got, ok := msgpack.AnyInt(tt.value)
+		assert.True(t, ok, "%T", tt.value)
+		assert.Equal(t, tt.want, got, "%T", tt.value)
+	}
+
+	for _, value := range []any{
+		uint64(math.MaxInt64 + 1), uint(math.MaxUint64),
+		float64(1), float32(1), "1", true, nil, []byte{1},
+	} {
+		_, ok := msgpack.AnyInt(value)
+		assert.False(t, ok, "%T %v", value, value)
+	}
+}
+
+func TestAnyIntReadAnyFormats(t *testing.T) {
+	// Every integer format ReadAny can return, before and after
+	// normalization.
+	values := []int64{1, -1, math.MinInt8, math.MaxUint8, math.MinInt16, math.MaxUint16,
+		math.MinInt32, math.MaxUint32, math.MinInt64, math.MaxInt64}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(uint32(len(values)))
+		for _, v := range values {
+			if v > 0 {
+				w.WriteUint64(uint64(v))
+			} else {
+				w.WriteInt64(v)
+			}
+		}
+	})
+	decoder := msgpack.NewDecoder(data)
+	tree, err := decoder.ReadAny()
+	require.NoError(t, err)
+	normalized, err := msgpack.NormalizeAny(tree)
+	require.NoError(t, err)
+	for i, want := range values {
+		for _, root := range []any{tree, normalized} {
+			v, ok := msgpack.AnyIndex(root, i)
+			require.True(t, ok)
+			got, ok := msgpack.AnyInt(v)
+			assert.True(t, ok, "%T", v)
+			assert.Equal(t, want, got, "%T", v)
+		}
+	}
+}
+
+func TestAnyScalars(t *testing.T) {
+	s, ok := msgpack.AnyString("a")
+	assert.True(t, ok)
+	assert.Equal(t, "a", s)
+	_, ok = msgpack.AnyString([]byte("a"))
+	assert.False(t, ok)
+
+	f, ok := msgpack.AnyFloat(float32(0.5))
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, f)
+	f, ok = msgpack.AnyFloat(1.25)
+	assert.True(t, ok)
+	assert.Equal(t, 1.25, f)
+	_, ok = msgpack.AnyFloat(int64(1))
+	assert.False(t, ok)
+
+	b, ok := msgpack.AnyBool(false)
+	assert.True(t, ok)
+	assert.False(t, b)
+	_, ok = msgpack.AnyBool(nil)
+	assert.False(t, ok)
+
+	bytes, ok := msgpack.AnyBytes([]byte{1})
+	assert.True(t, ok)
+	assert.Equal(t, []byte{1}, bytes)
+	_, ok = msgpack.AnyBytes("a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,81 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// writeFiveLevels writes {"a": [1, {"b": [2, {"c": 3}]}]}.
+func writeFiveLevels(w msgpack.Writer) {
+	w.WriteMapSize(1)
+	w.WriteString("a")
+	w.WriteArraySize(2)
+	w.WriteInt64(1)
+	w.WriteMapSize(1)
+	w.WriteString("b")
+	w.WriteArraySize(2)
+	w.WriteInt64(2)
+	w.WriteMapSize(1)
+	w.WriteString("c")
+	w.WriteInt64(3)
+}
+
+func TestAnyDepthLimit(t *testing.T) {
+	data := encodeWith(t, writeFiveLevels)
+
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithAnyDepthLimit(2))
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+
+	top, ok := v.(map[any]any)
+	require.True(t, ok)
+	a, ok := top["a"].([]any)
+	require.True(t, ok)
+	assert.Equal(t, int64(1), a[0])
+	raw, ok := a[1].(msgpack.Raw)
+	require.True(t, ok)
+	assert.Equal(t, msgpack.Raw(data[5:]), raw)
+
+	// The Raw subtree reads like any other document.
+	inner := msgpack.NewDecoder(raw)
+	b, err := inner.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, map[any]any{"b": []any{int64(2), map[any]any{"c": int64(3)}}}, b)
+
+	reencoded := encodeWith(t, func(w msgpack.Writer) { w.WriteAny(v) })
+	assert.Equal(t, data, reencoded)
+}
+
+func TestAnyDepthLimitOne(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(3)
+		w.WriteString("x")
+		w.WriteArraySize(0)
+		w.WriteMapSize(0)
+		w.WriteArraySize(1)
+		w.WriteNil()
+	})
+
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithAnyDepthLimit(1))
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, []any{"x", msgpack.Raw{0x90}, msgpack.Raw{0x80}}, v)
+	// Each call to ReadAny starts again at the top level.
+	v, err = decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, []any{nil}, v)
+
+	assert.Panics(t, func() { msgpack.WithAnyDepthLimit(0) })
+}
+
+func TestAnyDepthLimitTruncated(t *testing.T) {
+	data := encodeWith(t, writeFiveLevels)
+	decoder := msgpack.NewDecoderWithOptions(data[:len(data)-1], msgpack.WithAnyDepthLimit(2))
+	_, err := decoder.ReadAny()
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
@@ -0,0 +1,174 @@
+package msgpack_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// dispatchCase returns a minimal message starting with `lead` and what
+// ReadAny returns for it: the value, or the error when `ok` is false.
+func dispatchCase(lead byte) (message []byte, value any, ok bool) {
+	switch {
+	case lead <= 0x7f:
+		return []byte{lead}, int64(lead), true
+	case lead <= 0x8f:
+		n := int(lead & 0x0f)
+		message = []byte{lead}
+		m := map[any]any{}
+		for i := 0; i < n; i++ {
+			message = append(message, byte(i), msgpack.FormatNil)
+			m[int64(i)] = nil
+		}
+		return message, m, true
+	case lead <= 0x9f:
+		n := int(lead & 0x0f)
+		message = []byte{lead}
+		a := make([]any, n)
+		for i := range a {
+			message = append(message, byte(i))
+			a[i] = int64(i)
+		}
+		return message, a, true
+	case lead <= 0xbf:
+		n := int(lead & 0x1f)
+		message = []byte{lead}
+		for i := 0; i < n; i++ {
+			message = append(message, 'a'+byte(i))
+		}
+		return message, string(message[1:]), true
+	case lead >= 0xe0:
+		return []byte{lead}, int64(int8(lead)), true
+	}
+
+	switch lead {
+	case msgpack.FormatNil:
+		return []byte{lead}, nil, true
+	case msgpack.FormatTrue:
+		return []byte{lead}, true, true
+	case msgpack.FormatFalse:
+		return []byte{lead}, false, true
+	case msgpack.FormatBin8:
+		return []byte{lead, 2, 0xbe, 0xef}, []byte{0xbe, 0xef}, true
+	case msgpack.FormatBin16:
+		return []byte{lead, 0, 2, 0xbe, 0xef}, []byte{0xbe, 0xef}, true
+	case msgpack.FormatBin32:
+		return []byte{lead, 0, 0, 0, 2, 0xbe, 0xef}, []byte{0xbe, 0xef}, true
+	case msgpack.FormatFloat32:
+		return []byte{lead, 0x3f, 0xc0, 0, 0}, float32(1.5), true
+	case msgpack.FormatFloat64:
+		return []byte{lead, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, float64(1.5), true
+	case msgpack.FormatUint8:
+		return []byte{lead, 0xff}, uint8(math.MaxUint8), true
+	case msgpack.FormatUint16:
+		return []byte{lead, 0xff, 0xff}, uint16(math.MaxUint16), true
+	case msgpack.FormatUint32:
+		return []byte{lead, 0xff, 0xff, 0xff, 0xff}, uint32(math.MaxUint32), true
+	case msgpack.FormatUint64:
+		return []byte{lead, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, uint64(math.MaxUint64), true
+	case msgpack.FormatInt8:
+		return []byte{lead, 0x80}, int8(math.MinInt8), true
+	case msgpack.FormatInt16:
+		return []byte{lead, 0x80, 0}, int16(math.MinInt16), true
+	case msgpack.FormatInt32:
+		return []byte{lead, 0x80, 0, 0, 0}, int32(math.MinInt32), true
+	case msgpack.FormatInt64:
+		return []byte{lead, 0x80, 0, 0, 0, 0, 0, 0, 0}, int64(math.MinInt64), true
+	case msgpack.FormatString8:
+		return []byte{lead, 2, 'h', 'i'}, "hi", true
+	case msgpack.FormatString16:
+		return []byte{lead, 0, 2, 'h', 'i'}, "hi", true
+	case msgpack.FormatString32:
+		return []byte{lead, 0, 0, 0, 2, 'h', 'i'}, "hi", true
+	case msgpack.FormatArray16:
+		return []byte{lead, 0, 1, 0x07}, []any{int64(7)}, true
+	case msgpack.FormatArray32:
+		return []byte{lead, 0, 0, 0, 1, 0x07}, []any{int64(7)}, true
+	case msgpack.FormatMap16:
+		return []byte{lead, 0, 1, 0x07, 0xc3}, map[any]any{int64(7): true}, true
+	case msgpack.FormatMap32:
+		return []byte{lead, 0, 0, 0, 1, 0x07, 0xc3}, map[any]any{int64(7): true}, true
+	case msgpack.FormatFixExt1:
+		return []byte{lead, 42, 1}, nil, false
+	case msgpack.FormatFixExt2:
+		return []byte{lead, 42, 1, 2}, nil, false
+	case msgpack.FormatFixExt4:
+		return []byte{lead, 42, 1, 2, 3, 4}, nil, false
+	case msgpack.FormatFixExt8:
+		return []byte{lead, 42, 1, 2, 3, 4, 5, 6, 7, 8}, nil, false
+	case msgpack.FormatFixExt16:
+		return append([]byte{lead, 42}, make([]byte, 16)...), nil, false
+	case msgpack.FormatExt8:
+		return []byte{lead, 1, 42, 1}, nil, false
+	case msgpack.FormatExt16:
+		return []byte{lead, 0, 1, 42, 1}, nil, false
+	case msgpack.FormatExt32:
+		return []byte{lead, 0, 0, 0, 1, 42, 1}, nil, false
+	}
+	// Only 0xc1, which is never used, is left.
+	return []byte{lead}, nil, false
+}
+
+// TestReadAnyDispatch pins what ReadAny does for each of the 256 lead
+// bytes. A value is read whole and nothing after it; an unsupported
+// format is an error naming it, with only the format byte consumed.
+func TestReadAnyDispatch(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		lead := byte(i)
+		message, expected, ok := dispatchCase(lead)
+		t.Run(fmt.Sprintf("0x%02x", lead), func(t *testing.T) {
+			// The message follows a nil, so that offsets are not 0, and is
+			// followed by a true.
+			data := append(append([]byte{msgpack.FormatNil}, message...), msgpack.FormatTrue)
+			decoder := msgpack.NewDecoder(data)
+			require.NoError(t, decoder.ConsumeNil())
+
+			value, err := decoder.ReadAny()
+			if !ok {
+				assert.EqualError(t, err, fmt.Sprintf("bad prefix: got %s (0x%02x) at offset 1", msgpack.FormatName(lead), lead))
+				assert.Nil(t, value)
+				assert.Equal(t, uint32(2), decoder.Offset())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, expected, value)
+			assert.Equal(t, uint32(1+len(message)), decoder.Offset())
+			next, err := decoder.ReadBool()
+			require.NoError(t, err)
+			assert.True(t, next)
+
+			// Every truncation of the message is ErrRange and no value.
+			for n := 1; n < len(message); n++ {
+				truncated := msgpack.NewDecoder(message[:n])
+				value, err := truncated.ReadAny()
+				assert.ErrorIs(t, err, msgpack.ErrRange, "%d bytes", n)
+				assert.Nil(t, value, "%d bytes", n)
+			}
+		})
+	}
+}
+
+// A map key that decodes to a slice or map cannot be a key of a Go map.
+func TestReadAnyUnhashableKey(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"array": {0x81, 0x90, 0x01},
+		"map":   {0x82, 0x01, 0x02, 0x80, 0x01},
+		"bin":   {0x81, 0xc4, 0x01, 0xff, 0x01},
+	} {
+		t.Run(name, func(t *testing.T) {
+			decoder := msgpack.NewDecoder(data)
+			value, err := decoder.ReadAny()
+			assert.Nil(t, value)
+			offset := 1
+			if name == "map" {
+				offset = 3
+			}
+			assert.EqualError(t, err, fmt.Sprintf("msgpack: map key at offset %d is %s, which cannot be a key of a Go map", offset, name))
+		})
+	}
+}
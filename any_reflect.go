@@ -0,0 +1,22 @@
+//go:build !tinygo
+// +build !tinygo
+
+package msgpack
+
+import (
+	"reflect"
+)
+
+// writeReflectedAny writes the values WriteAny has no case for that can
+// be recognized by their kind: byte arrays of any length, including named
+// ones such as `type Hash [20]byte`, are written as bin. Anything else is
+// skipped, as it is in TinyGo builds.
+func writeReflectedAny(w Writer, value any) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Array || rv.Type().Elem().Kind() != reflect.Uint8 {
+		return
+	}
+	b := make([]byte, rv.Len())
+	reflect.Copy(reflect.ValueOf(b), rv)
+	w.WriteByteArray(b)
+}
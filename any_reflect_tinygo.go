@@ -0,0 +1,10 @@
+//go:build tinygo
+// +build tinygo
+
+package msgpack
+
+// writeReflectedAny does nothing in TinyGo builds, where WriteAny does
+// not look values up by their kind, although other parts of the package
+// still use reflect. Only [16]byte and [32]byte arrays have their own
+// WriteAny cases there; write other arrays as a slice with `v[:]`.
+func writeReflectedAny(w Writer, value any) {}
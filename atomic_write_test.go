@@ -0,0 +1,90 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// atomicWrites write one value each, with the size of its header.
+var atomicWrites = []struct {
+	name   string
+	header int
+	write  func(w msgpack.Writer)
+}{
+	{"fixstr", 1, func(w msgpack.Writer) { w.WriteString("hello") }},
+	{"str8", 2, func(w msgpack.Writer) { w.WriteString(strings.Repeat("a", 40)) }},
+	{"str16", 3, func(w msgpack.Writer) { w.WriteString(strings.Repeat("a", 300)) }},
+	{"bin8", 2, func(w msgpack.Writer) { w.WriteByteArray([]byte("payload")) }},
+	{"bin16", 3, func(w msgpack.Writer) { w.WriteByteArray(make([]byte, 300)) }},
+	{"time32", 2, func(w msgpack.Writer) { w.WriteTime(time.Unix(1700000000, 0)) }},
+	{"time64", 2, func(w msgpack.Writer) { w.WriteTime(time.Unix(1700000000, 5)) }},
+	{"time96", 3, func(w msgpack.Writer) { w.WriteTime(time.Unix(-1, 5)) }},
+	{"bin vec", 2, func(w msgpack.Writer) { w.WriteByteArrayVec([]byte("pay"), []byte("load")) }},
+	{"str vec", 1, func(w msgpack.Writer) { w.WriteStringVec("hel", "lo") }},
+	{"int64", 1, func(w msgpack.Writer) { w.WriteInt64(math.MinInt64) }},
+	{"uint32", 1, func(w msgpack.Writer) { w.WriteUint32(math.MaxUint32) }},
+	{"float64", 1, func(w msgpack.Writer) { w.WriteFloat64(1.5) }},
+	{"array16", 1, func(w msgpack.Writer) { w.WriteArraySize(1000) }},
+	{"map32", 1, func(w msgpack.Writer) { w.WriteMapSize(70000) }},
+}
+
+// A value that does not fit writes nothing, even when its header would, and
+// the message written so far can be continued in a larger buffer.
+func TestEncoderAtomicWrites(t *testing.T) {
+	for _, tc := range atomicWrites {
+		t.Run(tc.name, func(t *testing.T) {
+			value := encodeWith(t, tc.write)
+			require.Greater(t, len(value), tc.header)
+
+			buffer := bytes.Repeat([]byte{0xee}, 1+tc.header)
+			encoder := msgpack.NewEncoder(buffer)
+			encoder.WriteNil()
+			tc.write(&encoder)
+			assert.True(t, errors.Is(encoder.Err(), msgpack.ErrRange))
+			assert.Equal(t, uint32(1), encoder.Len())
+			assert.Equal(t, []byte{0xc0}, encoder.Bytes())
+			assert.Equal(t, bytes.Repeat([]byte{0xee}, tc.header), buffer[1:])
+
+			larger := make([]byte, 1+len(value))
+			copy(larger, encoder.Bytes())
+			rest := msgpack.NewEncoder(larger[encoder.Len():])
+			tc.write(&rest)
+			require.NoError(t, rest.Err())
+			assert.Equal(t, append([]byte{0xc0}, value...), larger)
+		})
+	}
+}
+
+// After a value that does not fit, a Rollback lets smaller values be
+// written in its place.
+func TestEncoderAtomicWritesRollback(t *testing.T) {
+	for _, opts := range [][]msgpack.EncOption{nil, {msgpack.WithStringTable(stringTableExt)}} {
+		expected := encodeWithOptions(t, func(w msgpack.Writer) {
+			w.WriteString("hello")
+			w.WriteString("hello")
+		}, opts...)
+
+		buffer := make([]byte, len(expected))
+		encoder := msgpack.NewEncoderWithOptions(buffer, opts...)
+		encoder.WriteString("hello")
+		mark := encoder.Mark()
+		encoder.WriteString(strings.Repeat("a", 20))
+		require.True(t, errors.Is(encoder.Err(), msgpack.ErrRange))
+		assert.Equal(t, uint32(6), encoder.Len())
+		assert.Equal(t, make([]byte, len(expected)-6), buffer[6:])
+
+		encoder.Rollback(mark)
+		encoder.WriteString("hello")
+		require.NoError(t, encoder.Err())
+		assert.Equal(t, expected, encoder.Bytes())
+	}
+}
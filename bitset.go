@@ -0,0 +1,78 @@
+package msgpack
+
+import "strconv"
+
+// WriteBoolBitset writes `bits` packed eight to a byte. The layout is a two
+// element array holding the number of bits as an unsigned integer followed
+// by a bin of (count+7)/8 bytes. Bit i is stored in byte i/8 at position
+// i%8, counting from the least significant bit, and unused bits in the last
+// byte are zero.
+func WriteBoolBitset(w Writer, bits []bool) {
+	w.WriteArraySize(2)
+	w.WriteUint32(uint32(len(bits)))
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	w.WriteByteArray(packed)
+}
+
+// ReadBoolBitset reads a bitset written by WriteBoolBitset.
+func ReadBoolBitset(r Reader) ([]bool, error) {
+	size, err := r.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	if size != 2 {
+		return nil, ReadError{"msgpack: bool bitset must be an array of 2, got " + strconv.FormatUint(uint64(size), 10)}
+	}
+	return readBoolBitsetBody(r)
+}
+
+// ReadBools reads a []bool stored either as a bitset written by
+// WriteBoolBitset or as a plain array of booleans, so producers can move to
+// the packed layout without breaking consumers. The two forms are told
+// apart by the first element, which is an integer only in a bitset.
+func ReadBools(r Reader) ([]bool, error) {
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	decoder := NewDecoder(raw)
+	size, err := decoder.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	if size == 2 {
+		format, err := decoder.PeekFormat()
+		if err != nil {
+			return nil, err
+		}
+		if format != FormatTrue && format != FormatFalse {
+			return readBoolBitsetBody(&decoder)
+		}
+	}
+	return readSliceElements(&decoder, size, nil, decoder.ReadBool)
+}
+
+func readBoolBitsetBody(r Reader) ([]bool, error) {
+	count, err := r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	packed, err := r.ReadByteArray()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(packed)) != (uint64(count)+7)/8 {
+		return nil, ReadError{"msgpack: bool bitset of " + strconv.FormatUint(uint64(count), 10) +
+			" bits has " + strconv.Itoa(len(packed)) + " bytes"}
+	}
+	bits := make([]bool, count)
+	for i := range bits {
+		bits[i] = packed[i/8]&(1<<(i%8)) != 0
+	}
+	return bits, nil
+}
@@ -0,0 +1,87 @@
+package msgpack_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestBoolBitset(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 8, 9, 4096} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			bits := make([]bool, n)
+			for i := range bits {
+				bits[i] = i%3 == 0
+			}
+			data := encodeWith(t, func(w msgpack.Writer) { msgpack.WriteBoolBitset(w, bits) })
+
+			decoder := msgpack.NewDecoder(data)
+			actual, err := msgpack.ReadBoolBitset(&decoder)
+			require.NoError(t, err)
+			assert.Equal(t, bits, actual)
+
+			decoder = msgpack.NewDecoder(data)
+			actual, err = msgpack.ReadBools(&decoder)
+			require.NoError(t, err)
+			assert.Equal(t, bits, actual)
+			assert.Equal(t, uint32(len(data)), decoder.Offset())
+		})
+	}
+}
+
+func TestBoolBitsetLayout(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteBoolBitset(w, []bool{true, false, false, true, false, false, false, false, true})
+	})
+	assert.Equal(t, []byte{0x92, 0x09, 0xc4, 0x02, 0x09, 0x01}, data)
+}
+
+func TestBoolBitsetSavings(t *testing.T) {
+	bits := make([]bool, 4096)
+	var packed, plain msgpack.Sizer
+	msgpack.WriteBoolBitset(&packed, bits)
+	plain.WriteArraySize(uint32(len(bits)))
+	for _, bit := range bits {
+		plain.WriteBool(bit)
+	}
+	assert.Equal(t, uint32(1+3+3+512), packed.Len())
+	assert.Equal(t, uint32(3+4096), plain.Len())
+}
+
+func TestReadBoolsPlainArray(t *testing.T) {
+	for _, bits := range [][]bool{{}, {true}, {false, true}, {true, true, false}} {
+		data := encodeWith(t, func(w msgpack.Writer) {
+			w.WriteArraySize(uint32(len(bits)))
+			for _, bit := range bits {
+				w.WriteBool(bit)
+			}
+		})
+		decoder := msgpack.NewDecoder(data)
+		actual, err := msgpack.ReadBools(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, bits, actual)
+	}
+}
+
+func TestBoolBitsetErrors(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{0x92, 0x09, 0xc4, 0x01, 0x09})
+	_, err := msgpack.ReadBoolBitset(&decoder)
+	assert.EqualError(t, err, "msgpack: bool bitset of 9 bits has 1 bytes")
+
+	decoder = msgpack.NewDecoder([]byte{0x93, 0x09, 0xc4, 0x01, 0x09, 0xc0})
+	_, err = msgpack.ReadBoolBitset(&decoder)
+	assert.EqualError(t, err, "msgpack: bool bitset must be an array of 2, got 3")
+
+	decoder = msgpack.NewDecoder([]byte{0x92, 0xc3, 0x01})
+	_, err = msgpack.ReadBools(&decoder)
+	assert.Error(t, err)
+
+	// A truncated array32 header fails without allocating for its size.
+	decoder = msgpack.NewDecoder([]byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0xc3})
+	_, err = msgpack.ReadBools(&decoder)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
@@ -0,0 +1,114 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// fixedWidthLen is the encoded size of a float64, int64 or uint64: its
+// format byte and 8 bytes of value.
+const fixedWidthLen = 9
+
+// ReadFloat64SliceFast reads an array of float64 values. When every
+// element is a float64 the array is converted in one pass over the buffer
+// without decoding the elements one by one; otherwise each is read with
+// ReadFloat64.
+func (d *Decoder) ReadFloat64SliceFast() ([]float64, error) {
+	size, payload, err := d.readFixedWidthArray(FormatFloat64)
+	if err != nil || payload == nil {
+		return readSliceElements(d, size, err, d.ReadFloat64)
+	}
+	values := make([]float64, size)
+	for i := range values {
+		values[i] = math.Float64frombits(binary.BigEndian.Uint64(payload[i*fixedWidthLen+1:]))
+	}
+	return values, nil
+}
+
+// ReadInt64SliceFast reads an array of int64 values, converting it in one
+// pass when every element is an int64 and reading each with ReadInt64
+// otherwise. See ReadFloat64SliceFast.
+func (d *Decoder) ReadInt64SliceFast() ([]int64, error) {
+	size, payload, err := d.readFixedWidthArray(FormatInt64)
+	if err != nil || payload == nil {
+		return readSliceElements(d, size, err, d.ReadInt64)
+	}
+	values := make([]int64, size)
+	for i := range values {
+		values[i] = int64(binary.BigEndian.Uint64(payload[i*fixedWidthLen+1:]))
+	}
+	return values, nil
+}
+
+// ReadUint64SliceFast reads an array of uint64 values, converting it in
+// one pass when every element is a uint64 and reading each with
+// ReadUint64 otherwise. See ReadFloat64SliceFast.
+func (d *Decoder) ReadUint64SliceFast() ([]uint64, error) {
+	size, payload, err := d.readFixedWidthArray(FormatUint64)
+	if err != nil || payload == nil {
+		return readSliceElements(d, size, err, d.ReadUint64)
+	}
+	values := make([]uint64, size)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint64(payload[i*fixedWidthLen+1:])
+	}
+	return values, nil
+}
+
+// readFixedWidthArray reads an array header and, when the elements that
+// follow are all of format `prefix`, consumes them and returns their
+// bytes. Only the first byte of each 9 byte step is checked: it is the
+// format byte of an element as long as all the elements before it were
+// `prefix`, so a value byte equal to `prefix` can never be taken for one.
+func (d *Decoder) readFixedWidthArray(prefix byte) (uint32, []byte, error) {
+	size, err := d.ReadArraySize()
+	if err != nil {
+		return 0, nil, err
+	}
+	n := uint64(size) * fixedWidthLen
+	if size == 0 || n > uint64(d.reader.Remaining()) {
+		return size, nil, nil
+	}
+	payload := d.reader.buffer[d.reader.byteOffset : uint64(d.reader.byteOffset)+n]
+	for i := 0; i < len(payload); i += fixedWidthLen {
+		if payload[i] != prefix {
+			return size, nil, nil
+		}
+	}
+	d.reader.byteOffset += uint32(n)
+	return size, payload, nil
+}
+
+// sizeHint returns how many of `size` elements, as read from a container
+// header of `r`, to allocate for up front. Every element takes at least
+// one byte, so a Decoder allocates no more than its remaining bytes; other
+// readers cannot tell, and get no hint rather than one a bad size could
+// make huge.
+func sizeHint(r Reader, size uint32) uint32 {
+	d, ok := r.(*Decoder)
+	if !ok {
+		return 0
+	}
+	if remaining := d.reader.Remaining(); size > remaining {
+		return remaining
+	}
+	return size
+}
+
+// readSliceElements reads `size` elements with `read`. Every element takes
+// at least one byte, so no more than the remaining bytes are allocated for
+// up front.
+func readSliceElements[T any](d *Decoder, size uint32, err error, read func() (T, error)) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	values := make([]T, 0, sizeHint(d, size))
+	for i := uint32(0); i < size; i++ {
+		v, err := read()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
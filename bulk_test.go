@@ -0,0 +1,195 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// readFloat64Elements is the element by element reference for
+// ReadFloat64SliceFast.
+func readFloat64Elements(d *msgpack.Decoder) ([]float64, error) {
+	size, err := d.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]float64, 0)
+	for i := uint32(0); i < size; i++ {
+		v, err := d.ReadFloat64()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func readInt64Elements(d *msgpack.Decoder) ([]int64, error) {
+	size, err := d.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]int64, 0)
+	for i := uint32(0); i < size; i++ {
+		v, err := d.ReadInt64()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func TestReadSliceFast(t *testing.T) {
+	floats := []float64{0, -1.5, math.Inf(1), math.MaxFloat64, math.SmallestNonzeroFloat64}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(uint32(len(floats)))
+		for _, f := range floats {
+			w.WriteFloat64(f)
+		}
+		w.WriteString("after")
+	})
+	decoder := msgpack.NewDecoder(data)
+	values, err := decoder.ReadFloat64SliceFast()
+	require.NoError(t, err)
+	assert.Equal(t, floats, values)
+	after, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "after", after)
+
+	// WriteInt64 picks the smallest format, so build int64 elements by hand.
+	ints := []byte{0x93,
+		msgpack.FormatInt64, 0x80, 0, 0, 0, 0, 0, 0, 0,
+		msgpack.FormatInt64, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		msgpack.FormatInt64, 0, 0, 0, 0, 0, 0, 0, 7}
+	decoder = msgpack.NewDecoder(ints)
+	int64s, err := decoder.ReadInt64SliceFast()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{math.MinInt64, -1, 7}, int64s)
+
+	uints := []byte{0x92,
+		msgpack.FormatUint64, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		msgpack.FormatUint64, 0, 0, 0, 0, 0, 0, 0, 1}
+	decoder = msgpack.NewDecoder(uints)
+	uint64s, err := decoder.ReadUint64SliceFast()
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{math.MaxUint64, 1}, uint64s)
+
+	decoder = msgpack.NewDecoder([]byte{0x90})
+	empty, err := decoder.ReadInt64SliceFast()
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestReadSliceFastFallsBack(t *testing.T) {
+	// Mixed integer formats are read one by one.
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteAny([]int64{1, -200, math.MaxInt64, math.MinInt64})
+	})
+	decoder := msgpack.NewDecoder(data)
+	values, err := decoder.ReadInt64SliceFast()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, -200, math.MaxInt64, math.MinInt64}, values)
+
+	// A fixint followed by bytes that would look like float64 elements if
+	// the first element were taken to be 9 bytes long.
+	crafted := []byte{0x92, 0x01,
+		msgpack.FormatFloat64, 0, 0, 0, 0, 0, 0, 0, msgpack.FormatFloat64,
+		0, 0, 0, 0, 0, 0, 0, 0}
+	decoder = msgpack.NewDecoder(crafted)
+	_, err = decoder.ReadFloat64SliceFast()
+	assert.EqualError(t, err, "bad prefix for float64: got fixint(1) (0x01) at offset 1")
+
+	// A truncated array is a range error and allocates no more than the
+	// input holds.
+	decoder = msgpack.NewDecoder([]byte{0xdd, 0xff, 0xff, 0xff, 0xff, msgpack.FormatFloat64})
+	_, err = decoder.ReadFloat64SliceFast()
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
+
+func FuzzReadSliceFast(f *testing.F) {
+	f.Add([]byte{0x92, 0xcb, 0, 0, 0, 0, 0, 0, 0, 0xcb, 0xcb, 0, 0, 0, 0, 0, 0, 0, 0})
+	f.Add([]byte{0x92, 0xd3, 0xd3, 0xd3, 0xd3, 0xd3, 0xd3, 0xd3, 0xd3, 0xd3, 0x01})
+	f.Add([]byte{0x93, 0x01, 0xd3, 0, 0, 0, 0, 0, 0, 0, 0xd3, 0, 0, 0, 0, 0, 0, 0, 0, 0xd3})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fast := msgpack.NewDecoder(data)
+		slow := msgpack.NewDecoder(data)
+		fastFloats, fastErr := fast.ReadFloat64SliceFast()
+		slowFloats, slowErr := readFloat64Elements(&slow)
+		require.Equal(t, slowErr == nil, fastErr == nil, "float64: %x", data)
+		if fastErr == nil {
+			require.Equal(t, len(slowFloats), len(fastFloats))
+			for i := range fastFloats {
+				require.Equal(t, math.Float64bits(slowFloats[i]), math.Float64bits(fastFloats[i]))
+			}
+			require.Equal(t, slow.Offset(), fast.Offset())
+		}
+
+		fast = msgpack.NewDecoder(data)
+		slow = msgpack.NewDecoder(data)
+		fastInts, fastErr := fast.ReadInt64SliceFast()
+		slowInts, slowErr := readInt64Elements(&slow)
+		require.Equal(t, slowErr == nil, fastErr == nil, "int64: %x", data)
+		if fastErr == nil {
+			require.Equal(t, slowInts, fastInts)
+			require.Equal(t, slow.Offset(), fast.Offset())
+		}
+	})
+}
+
+// int64ArrayData returns an array of 100000 int64 elements. With
+// `fixintLast`, the last element is a fixint, so the fast path scans the
+// whole array before falling back, which is its worst case.
+func int64ArrayData(fixintLast bool) []byte {
+	data := []byte{msgpack.FormatArray32, 0, 0x01, 0x86, 0xa0}
+	for i := 0; i < 100000; i++ {
+		if fixintLast && i == 99999 {
+			data = append(data, 0x01)
+			continue
+		}
+		data = append(data, msgpack.FormatInt64, 0, 0, 0, 0, 0, 0, byte(i>>8), byte(i))
+	}
+	return data
+}
+
+func BenchmarkReadInt64Slice(b *testing.B) {
+	cases := map[string][]byte{
+		"homogeneous": int64ArrayData(false),
+		"fixint-last": int64ArrayData(true),
+	}
+	var sizer msgpack.Sizer
+	mixed := make([]int64, 100000)
+	for i := range mixed {
+		mixed[i] = int64(i) * 1000
+	}
+	sizer.WriteAny(mixed)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	encoder.WriteAny(mixed)
+	cases["mixed"] = encoder.Bytes()
+
+	for name, data := range cases {
+		data := data
+		b.Run(name+"/elements", func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				decoder := msgpack.NewDecoder(data)
+				if _, err := readInt64Elements(&decoder); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/fast", func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				decoder := msgpack.NewDecoder(data)
+				if _, err := decoder.ReadInt64SliceFast(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
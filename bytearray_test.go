@@ -0,0 +1,50 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestReadNillableByteArrayStrict(t *testing.T) {
+	values := [][]byte{nil, {}, {1, 2, 3}}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		for _, v := range values {
+			w.WriteNillableByteArray(v)
+		}
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	var r msgpack.Reader = &decoder
+	for _, expected := range values {
+		value, isNil, err := r.ReadNillableByteArrayStrict()
+		require.NoError(t, err)
+		assert.Equal(t, expected == nil, isNil)
+		assert.Equal(t, expected == nil, value == nil)
+		assert.Equal(t, expected, value)
+	}
+	assert.Equal(t, uint32(0), decoder.Remaining())
+
+	// The non-strict method still returns nil only for a nil value.
+	decoder = msgpack.NewDecoder(data)
+	value, err := decoder.ReadNillableByteArray()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+	value, err = decoder.ReadNillableByteArray()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{}, value)
+}
+
+func TestReadNillableByteArrayStrictRejectsOtherFormats(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{0x92, 0x01, 0x02})
+	_, _, err := decoder.ReadNillableByteArrayStrict()
+	assert.EqualError(t, err, "msgpack: expected bin or nil, found array at offset 0")
+	assert.Equal(t, uint32(0), decoder.Offset())
+
+	// ReadByteArray keeps accepting it.
+	_, err = decoder.ReadByteArray()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,63 @@
+package msgpack_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestEncoderCapacity(t *testing.T) {
+	// "hello" encodes as a 1 byte fixstr header plus 5 bytes.
+	buffer := make([]byte, 8)
+	encoder := msgpack.NewEncoder(buffer)
+	assert.Equal(t, uint32(8), encoder.Remaining())
+	encoder.WriteBool(true)
+	encoder.WriteBool(false)
+	assert.Equal(t, uint32(6), encoder.Remaining())
+	assert.True(t, encoder.Fits(6))
+	assert.False(t, encoder.Fits(7))
+
+	// Exactly fills the buffer.
+	encoder.WriteString("hello")
+	require.NoError(t, encoder.Err())
+	assert.Equal(t, uint32(0), encoder.Remaining())
+	assert.True(t, encoder.Fits(0))
+	assert.False(t, encoder.Fits(1))
+}
+
+func TestEncoderRangeErrorDetails(t *testing.T) {
+	// One byte short of "hello".
+	buffer := make([]byte, 6)
+	encoder := msgpack.NewEncoder(buffer)
+	encoder.WriteBool(true)
+	encoder.WriteString("hello")
+
+	err := encoder.Err()
+	require.True(t, errors.Is(err, msgpack.ErrRange))
+	var rangeErr msgpack.RangeError
+	require.True(t, errors.As(err, &rangeErr))
+	// The string is checked as a whole, header and payload.
+	assert.Equal(t, uint32(1), rangeErr.Offset)
+	assert.Equal(t, uint32(6), rangeErr.Requested)
+	assert.Equal(t, uint32(5), rangeErr.Available)
+	assert.Equal(t, "range error at offset 1: requested 6 bytes, 5 available", err.Error())
+	assert.False(t, encoder.Fits(0), "a failed encoder fits nothing")
+}
+
+func TestDecoderRemaining(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{0xc3, 0xa2, 'h', 'i'})
+	assert.Equal(t, uint32(4), decoder.Remaining())
+	_, err := decoder.ReadBool()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), decoder.Remaining())
+	_, err = decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+
+	_, err = decoder.ReadBool()
+	assert.True(t, errors.Is(err, msgpack.ErrRange))
+}
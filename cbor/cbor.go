@@ -0,0 +1,624 @@
+// Package cbor translates between MessagePack and CBOR (RFC 8949).
+//
+// The translation is streamed value by value over the shared data model:
+// integers, floats, strings, byte strings, arrays, maps, booleans and null.
+// The MessagePack timestamp extension maps to CBOR tag 1 (epoch seconds)
+// when it has no fractional part and tag 0 (RFC 3339 text) otherwise. Other
+// extension types are only translated when mapped to a CBOR tag with
+// WithExtTag; all other tags and extensions are rejected.
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+const (
+	majorUint    = 0
+	majorNegInt  = 1
+	majorBytes   = 2
+	majorText    = 3
+	majorArray   = 4
+	majorMap     = 5
+	majorTag     = 6
+	majorSimple  = 7
+	indefinite   = 31
+	breakCode    = 0xff
+	tagDateTime  = 0
+	tagEpochTime = 1
+	maxDepth     = 512
+)
+
+var ErrTrailingData = errors.New("cbor: trailing data after value")
+
+type options struct {
+	extToTag map[int8]uint64
+	tagToExt map[uint64]int8
+}
+
+// Option configures a translation.
+type Option func(*options)
+
+// WithExtTag maps the MessagePack extension type `ext` to the CBOR tag
+// `tag` in both directions. The extension payload is carried as the tagged
+// byte string.
+func WithExtTag(ext int8, tag uint64) Option {
+	return func(o *options) {
+		if o.extToTag == nil {
+			o.extToTag = make(map[int8]uint64)
+			o.tagToExt = make(map[uint64]int8)
+		}
+		o.extToTag[ext] = tag
+		o.tagToExt[tag] = ext
+	}
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type TranslateError struct {
+	Offset  uint32
+	Message string
+}
+
+func (e *TranslateError) Error() string {
+	return "cbor: " + e.Message + " at offset " + strconv.FormatUint(uint64(e.Offset), 10)
+}
+
+// FromCBOR translates a single CBOR data item to MessagePack.
+func FromCBOR(data []byte, opts ...Option) ([]byte, error) {
+	o := applyOptions(opts)
+	var sizer msgpack.Sizer
+	if err := fromCBOR(data, &sizer, &o); err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, sizer.Len())
+	encoder := msgpack.NewEncoder(buffer)
+	if err := fromCBOR(data, &encoder, &o); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), encoder.Err()
+}
+
+func fromCBOR(data []byte, w msgpack.Writer, o *options) error {
+	p := parser{data: data, w: w, o: o}
+	if err := p.item(0); err != nil {
+		return err
+	}
+	if p.offset != uint32(len(data)) {
+		return ErrTrailingData
+	}
+	return w.Err()
+}
+
+type parser struct {
+	data   []byte
+	offset uint32
+	w      msgpack.Writer
+	o      *options
+}
+
+func (p *parser) fail(message string) error {
+	return &TranslateError{Offset: p.offset, Message: message}
+}
+
+func (p *parser) next(n uint32) ([]byte, error) {
+	if uint64(p.offset)+uint64(n) > uint64(len(p.data)) {
+		return nil, p.fail("unexpected end of data")
+	}
+	b := p.data[p.offset : p.offset+n]
+	p.offset += n
+	return b, nil
+}
+
+// head reads an initial byte and its argument. For indefinite lengths the
+// returned `indef` is true.
+func (p *parser) head() (major byte, info byte, arg uint64, indef bool, err error) {
+	b, err := p.next(1)
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		b, err = p.next(1)
+		if err == nil {
+			arg = uint64(b[0])
+		}
+	case info == 25:
+		b, err = p.next(2)
+		if err == nil {
+			arg = uint64(binary.BigEndian.Uint16(b))
+		}
+	case info == 26:
+		b, err = p.next(4)
+		if err == nil {
+			arg = uint64(binary.BigEndian.Uint32(b))
+		}
+	case info == 27:
+		b, err = p.next(8)
+		if err == nil {
+			arg = binary.BigEndian.Uint64(b)
+		}
+	case info == indefinite:
+		indef = true
+	default:
+		p.offset--
+		err = p.fail("reserved additional information " + strconv.Itoa(int(info)))
+	}
+	return major, info, arg, indef, err
+}
+
+func (p *parser) isBreak() bool {
+	return p.offset < uint32(len(p.data)) && p.data[p.offset] == breakCode
+}
+
+func (p *parser) item(depth int) error {
+	if depth > maxDepth {
+		return p.fail("nesting too deep")
+	}
+	start := p.offset
+	major, info, arg, indef, err := p.head()
+	if err != nil {
+		return err
+	}
+	if indef && (major == majorUint || major == majorNegInt || major == majorTag) {
+		p.offset = start
+		return p.fail("indefinite length not allowed for major type " + strconv.Itoa(int(major)))
+	}
+
+	switch major {
+	case majorUint:
+		p.w.WriteUint64(arg)
+	case majorNegInt:
+		if arg > math.MaxInt64 {
+			p.offset = start
+			return p.fail("negative integer out of range")
+		}
+		p.w.WriteInt64(-1 - int64(arg))
+	case majorBytes, majorText:
+		b, err := p.stringBytes(major, arg, indef)
+		if err != nil {
+			return err
+		}
+		if major == majorBytes {
+			p.w.WriteByteArray(b)
+		} else {
+			p.w.WriteString(string(b))
+		}
+	case majorArray:
+		if indef {
+			mark := p.w.ReserveArraySize()
+			count := uint32(0)
+			for !p.isBreak() {
+				if err := p.item(depth + 1); err != nil {
+					return err
+				}
+				count++
+			}
+			if _, err := p.next(1); err != nil {
+				return err
+			}
+			p.w.PatchArraySize(mark, count)
+			return nil
+		}
+		if arg > math.MaxUint32 {
+			p.offset = start
+			return p.fail("array too long")
+		}
+		p.w.WriteArraySize(uint32(arg))
+		for i := uint64(0); i < arg; i++ {
+			if err := p.item(depth + 1); err != nil {
+				return err
+			}
+		}
+	case majorMap:
+		if indef {
+			mark := p.w.ReserveMapSize()
+			count := uint32(0)
+			for !p.isBreak() {
+				if err := p.item(depth + 1); err != nil {
+					return err
+				}
+				if err := p.item(depth + 1); err != nil {
+					return err
+				}
+				count++
+			}
+			if _, err := p.next(1); err != nil {
+				return err
+			}
+			p.w.PatchMapSize(mark, count)
+			return nil
+		}
+		if arg > math.MaxUint32 {
+			p.offset = start
+			return p.fail("map too long")
+		}
+		p.w.WriteMapSize(uint32(arg))
+		for i := uint64(0); i < 2*arg; i++ {
+			if err := p.item(depth + 1); err != nil {
+				return err
+			}
+		}
+	case majorTag:
+		return p.tag(start, arg)
+	case majorSimple:
+		return p.simple(start, info, arg)
+	}
+	return nil
+}
+
+func (p *parser) stringBytes(major byte, arg uint64, indef bool) ([]byte, error) {
+	if !indef {
+		if arg > math.MaxUint32 {
+			return nil, p.fail("string too long")
+		}
+		return p.next(uint32(arg))
+	}
+	// Indefinite length strings are a sequence of definite chunks of the
+	// same major type, buffered and written as one value.
+	var buf []byte
+	for !p.isBreak() {
+		chunkStart := p.offset
+		chunkMajor, _, chunkLen, chunkIndef, err := p.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkIndef || chunkLen > math.MaxUint32 {
+			p.offset = chunkStart
+			return nil, p.fail("invalid chunk in indefinite length string")
+		}
+		chunk, err := p.next(uint32(chunkLen))
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, chunk...)
+	}
+	if _, err := p.next(1); err != nil {
+		return nil, err
+	}
+	if buf == nil {
+		buf = []byte{}
+	}
+	return buf, nil
+}
+
+func (p *parser) tag(start uint32, tag uint64) error {
+	switch tag {
+	case tagDateTime:
+		major, _, arg, indef, err := p.head()
+		if err != nil {
+			return err
+		}
+		if major != majorText || indef {
+			p.offset = start
+			return p.fail("tag 0 must contain a text string")
+		}
+		b, err := p.next(uint32(arg))
+		if err != nil {
+			return err
+		}
+		tm, err := time.Parse(time.RFC3339Nano, string(b))
+		if err != nil {
+			p.offset = start
+			return p.fail("invalid tag 0 time: " + err.Error())
+		}
+		p.w.WriteTime(tm)
+		return nil
+	case tagEpochTime:
+		major, info, arg, indef, err := p.head()
+		if err != nil {
+			return err
+		}
+		switch {
+		case indef:
+		case major == majorUint && arg <= math.MaxInt64:
+			p.w.WriteTime(time.Unix(int64(arg), 0))
+			return nil
+		case major == majorNegInt && arg <= math.MaxInt64:
+			p.w.WriteTime(time.Unix(-1-int64(arg), 0))
+			return nil
+		case major == majorSimple && info >= 25 && info <= 27:
+			f := simpleFloat(info, arg)
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				break
+			}
+			sec, frac := math.Modf(f)
+			p.w.WriteTime(time.Unix(int64(sec), int64(frac*1e9)))
+			return nil
+		}
+		p.offset = start
+		return p.fail("tag 1 must contain a number")
+	}
+
+	ext, ok := p.o.tagToExt[tag]
+	if !ok {
+		p.offset = start
+		return p.fail("unsupported tag " + strconv.FormatUint(tag, 10))
+	}
+	major, _, arg, indef, err := p.head()
+	if err != nil {
+		return err
+	}
+	if major != majorBytes || indef {
+		p.offset = start
+		return p.fail("tag " + strconv.FormatUint(tag, 10) + " must contain a byte string")
+	}
+	b, err := p.next(uint32(arg))
+	if err != nil {
+		return err
+	}
+	p.w.WriteRaw(extValue(ext, b))
+	return nil
+}
+
+func (p *parser) simple(start uint32, info byte, arg uint64) error {
+	switch info {
+	case 20:
+		p.w.WriteBool(false)
+	case 21:
+		p.w.WriteBool(true)
+	case 22, 23:
+		// null and undefined both map to nil.
+		p.w.WriteNil()
+	case 25, 26:
+		p.w.WriteFloat32(float32(simpleFloat(info, arg)))
+	case 27:
+		p.w.WriteFloat64(simpleFloat(info, arg))
+	default:
+		p.offset = start
+		return p.fail("unsupported simple value " + strconv.FormatUint(arg, 10))
+	}
+	return nil
+}
+
+func simpleFloat(info byte, arg uint64) float64 {
+	switch info {
+	case 25:
+		return halfToFloat64(uint16(arg))
+	case 26:
+		return float64(math.Float32frombits(uint32(arg)))
+	}
+	return math.Float64frombits(arg)
+}
+
+func halfToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var val float64
+	switch exp {
+	case 0:
+		val = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			val = math.Inf(1)
+		} else {
+			val = math.NaN()
+		}
+	default:
+		val = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -val
+	}
+	return val
+}
+
+func extValue(ext int8, data []byte) msgpack.Raw {
+	var raw msgpack.Raw
+	switch l := len(data); {
+	case l == 1:
+		raw = append(raw, msgpack.FormatFixExt1)
+	case l == 2:
+		raw = append(raw, msgpack.FormatFixExt2)
+	case l == 4:
+		raw = append(raw, msgpack.FormatFixExt4)
+	case l == 8:
+		raw = append(raw, msgpack.FormatFixExt8)
+	case l == 16:
+		raw = append(raw, msgpack.FormatFixExt16)
+	case l <= math.MaxUint8:
+		raw = append(raw, msgpack.FormatExt8, byte(l))
+	case l <= math.MaxUint16:
+		raw = append(raw, msgpack.FormatExt16, byte(l>>8), byte(l))
+	default:
+		raw = append(raw, msgpack.FormatExt32, byte(l>>24), byte(l>>16), byte(l>>8), byte(l))
+	}
+	raw = append(raw, byte(ext))
+	return append(raw, data...)
+}
+
+// ToCBOR translates a single MessagePack value to CBOR.
+func ToCBOR(data []byte, opts ...Option) ([]byte, error) {
+	o := applyOptions(opts)
+	decoder := msgpack.NewDecoder(data)
+	out := make([]byte, 0, len(data))
+	out, err := toCBOR(&decoder, out, &o, 0)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.Offset() != uint32(len(data)) {
+		return nil, ErrTrailingData
+	}
+	return out, nil
+}
+
+func appendHead(dst []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return append(dst, m|byte(n))
+	case n <= math.MaxUint8:
+		return append(dst, m|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(dst, m|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return appendUint32(append(dst, m|26), uint32(n))
+	}
+	return appendUint64(append(dst, m|27), n)
+}
+
+func appendUint32(dst []byte, n uint32) []byte {
+	return append(dst, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendUint64(dst []byte, n uint64) []byte {
+	return appendUint32(appendUint32(dst, uint32(n>>32)), uint32(n))
+}
+
+func appendInt(dst []byte, v int64) []byte {
+	if v < 0 {
+		return appendHead(dst, majorNegInt, uint64(-1-v))
+	}
+	return appendHead(dst, majorUint, uint64(v))
+}
+
+func toCBOR(d *msgpack.Decoder, dst []byte, o *options, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, &TranslateError{Offset: d.Offset(), Message: "nesting too deep"}
+	}
+	start := d.Offset()
+	prefix, err := d.PeekFormat()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case prefix <= 0x7f || prefix >= msgpack.FormatNegativeFixInt ||
+		prefix >= msgpack.FormatInt8 && prefix <= msgpack.FormatInt64:
+		v, err := d.ReadInt64()
+		if err != nil {
+			return nil, err
+		}
+		return appendInt(dst, v), nil
+	case prefix >= msgpack.FormatUint8 && prefix <= msgpack.FormatUint64:
+		v, err := d.ReadUint64()
+		if err != nil {
+			return nil, err
+		}
+		return appendHead(dst, majorUint, v), nil
+	case prefix&0xe0 == msgpack.FormatFixString ||
+		prefix >= msgpack.FormatString8 && prefix <= msgpack.FormatString32:
+		v, err := d.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		dst = appendHead(dst, majorText, uint64(len(v)))
+		return append(dst, v...), nil
+	case prefix >= msgpack.FormatBin8 && prefix <= msgpack.FormatBin32:
+		v, err := d.ReadByteArray()
+		if err != nil {
+			return nil, err
+		}
+		dst = appendHead(dst, majorBytes, uint64(len(v)))
+		return append(dst, v...), nil
+	case prefix&0xf0 == msgpack.FormatFixArray || prefix == msgpack.FormatArray16 || prefix == msgpack.FormatArray32:
+		size, err := d.ReadArraySize()
+		if err != nil {
+			return nil, err
+		}
+		dst = appendHead(dst, majorArray, uint64(size))
+		for i := uint32(0); i < size; i++ {
+			if dst, err = toCBOR(d, dst, o, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return dst, nil
+	case prefix&0xf0 == msgpack.FormatFixMap || prefix == msgpack.FormatMap16 || prefix == msgpack.FormatMap32:
+		size, err := d.ReadMapSize()
+		if err != nil {
+			return nil, err
+		}
+		dst = appendHead(dst, majorMap, uint64(size))
+		for i := uint32(0); i < 2*size; i++ {
+			if dst, err = toCBOR(d, dst, o, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return dst, nil
+	}
+
+	switch prefix {
+	case msgpack.FormatNil:
+		d.Skip()
+		return append(dst, majorSimple<<5|22), nil
+	case msgpack.FormatFalse:
+		d.Skip()
+		return append(dst, majorSimple<<5|20), nil
+	case msgpack.FormatTrue:
+		d.Skip()
+		return append(dst, majorSimple<<5|21), nil
+	case msgpack.FormatFloat32:
+		v, err := d.ReadFloat32()
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, majorSimple<<5|26)
+		return appendUint32(dst, math.Float32bits(v)), nil
+	case msgpack.FormatFloat64:
+		v, err := d.ReadFloat64()
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, majorSimple<<5|27)
+		return appendUint64(dst, math.Float64bits(v)), nil
+	case msgpack.FormatFixExt1, msgpack.FormatFixExt2, msgpack.FormatFixExt4,
+		msgpack.FormatFixExt8, msgpack.FormatFixExt16,
+		msgpack.FormatExt8, msgpack.FormatExt16, msgpack.FormatExt32:
+		raw, err := d.ReadRaw()
+		if err != nil {
+			return nil, err
+		}
+		ext, data := splitExt(raw)
+		if ext == -1 {
+			timeDecoder := msgpack.NewDecoder(raw)
+			tm, err := timeDecoder.ReadTime()
+			if err != nil {
+				return nil, err
+			}
+			if tm.Nanosecond() == 0 {
+				dst = appendHead(dst, majorTag, tagEpochTime)
+				return appendInt(dst, tm.Unix()), nil
+			}
+			text := tm.UTC().Format(time.RFC3339Nano)
+			dst = appendHead(dst, majorTag, tagDateTime)
+			dst = appendHead(dst, majorText, uint64(len(text)))
+			return append(dst, text...), nil
+		}
+		tag, ok := o.extToTag[ext]
+		if !ok {
+			return nil, &TranslateError{Offset: start, Message: "unsupported ext type " + strconv.Itoa(int(ext))}
+		}
+		dst = appendHead(dst, majorTag, tag)
+		dst = appendHead(dst, majorBytes, uint64(len(data)))
+		return append(dst, data...), nil
+	}
+
+	return nil, &TranslateError{Offset: start, Message: "unsupported format 0x" + strconv.FormatUint(uint64(prefix), 16)}
+}
+
+// splitExt returns the type and payload of an encoded ext value.
+func splitExt(raw msgpack.Raw) (int8, []byte) {
+	switch raw[0] {
+	case msgpack.FormatExt8:
+		return int8(raw[2]), raw[3:]
+	case msgpack.FormatExt16:
+		return int8(raw[3]), raw[4:]
+	case msgpack.FormatExt32:
+		return int8(raw[5]), raw[6:]
+	}
+	return int8(raw[1]), raw[2:]
+}
@@ -0,0 +1,150 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/cbor"
+)
+
+// Vectors from RFC 8949 Appendix A.
+var rfcVectors = []struct {
+	cbor     string
+	expected any
+}{
+	{"00", int64(0)},
+	{"17", int64(23)},
+	{"1818", int64(24)},
+	{"1903e8", uint16(1000)},
+	{"1a000f4240", uint32(1000000)},
+	{"1b000000e8d4a51000", uint64(1000000000000)},
+	{"1bffffffffffffffff", uint64(math.MaxUint64)},
+	{"20", int64(-1)},
+	{"3863", int8(-100)},
+	{"3903e7", int16(-1000)},
+	{"fb3ff199999999999a", 1.1},
+	{"f93e00", float32(1.5)},
+	{"f97c00", float32(math.Inf(1))},
+	{"fa47c35000", float32(100000.0)},
+	{"f4", false},
+	{"f5", true},
+	{"f6", nil},
+	{"40", []byte{}},
+	{"4401020304", []byte{1, 2, 3, 4}},
+	{"60", ""},
+	{"6449455446", "IETF"},
+	{"80", []any{}},
+	{"8301820203820405", []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+	{"a201020304", map[any]any{int64(1): int64(2), int64(3): int64(4)}},
+	{"a26161016162820203", map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+	{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+	{"7f657374726561646d696e67ff", "streaming"},
+	{"9fff", []any{}},
+	{"9f018202039f0405ffff", []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+	{"bf61610161629f0203ffff", map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+}
+
+func TestFromCBOR(t *testing.T) {
+	for _, tt := range rfcVectors {
+		t.Run(tt.cbor, func(t *testing.T) {
+			input, err := hex.DecodeString(tt.cbor)
+			require.NoError(t, err)
+			data, err := cbor.FromCBOR(input)
+			require.NoError(t, err)
+			decoder := msgpack.NewDecoder(data)
+			actual, err := decoder.ReadAny()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestCBORTimes(t *testing.T) {
+	tests := map[string]time.Time{
+		"c074323031332d30332d32315432303a30343a30305a": time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC),
+		"c11a514b67b0":         time.Unix(1363896240, 0),
+		"c1fb41d452d9ec200000": time.Unix(1363896240, 5e8),
+	}
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			b, err := hex.DecodeString(input)
+			require.NoError(t, err)
+			data, err := cbor.FromCBOR(b)
+			require.NoError(t, err)
+			decoder := msgpack.NewDecoder(data)
+			actual, err := decoder.ReadTime()
+			require.NoError(t, err)
+			assert.True(t, expected.Equal(actual), "%v != %v", expected, actual)
+
+			back, err := cbor.ToCBOR(data)
+			require.NoError(t, err)
+			again, err := cbor.FromCBOR(back)
+			require.NoError(t, err)
+			assert.Equal(t, data, again)
+		})
+	}
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		"00", "17", "1818", "1903e8", "1a000f4240", "1b000000e8d4a51000",
+		"20", "3863", "3903e7", "fb3ff199999999999a", "fa47c35000",
+		"f4", "f5", "f6", "40", "4401020304", "60", "6449455446", "80",
+		"8301820203820405", "a201020304", "a26161016162820203",
+	} {
+		t.Run(input, func(t *testing.T) {
+			b, err := hex.DecodeString(input)
+			require.NoError(t, err)
+			data, err := cbor.FromCBOR(b)
+			require.NoError(t, err)
+			back, err := cbor.ToCBOR(data)
+			require.NoError(t, err)
+			assert.Equal(t, input, hex.EncodeToString(back))
+		})
+	}
+}
+
+func TestCBORExtMapping(t *testing.T) {
+	// ext type 5 with a 2 byte payload.
+	data := []byte{msgpack.FormatFixExt2, 5, 0xab, 0xcd}
+	_, err := cbor.ToCBOR(data)
+	assert.ErrorContains(t, err, "unsupported ext type 5")
+
+	out, err := cbor.ToCBOR(data, cbor.WithExtTag(5, 40000))
+	require.NoError(t, err)
+	assert.Equal(t, "d99c4042abcd", hex.EncodeToString(out))
+
+	back, err := cbor.FromCBOR(out, cbor.WithExtTag(5, 40000))
+	require.NoError(t, err)
+	assert.Equal(t, data, back)
+}
+
+func TestCBORErrors(t *testing.T) {
+	tests := map[string]string{
+		"d99c4042abcd":       "unsupported tag 40000",
+		"1c":                 "reserved additional information 28",
+		"3bffffffffffffffff": "negative integer out of range",
+		"f0":                 "unsupported simple value 16",
+		"8201":               "unexpected end of data",
+		"5f6161ff":           "invalid chunk in indefinite length string",
+	}
+	for input, contains := range tests {
+		t.Run(input, func(t *testing.T) {
+			b, err := hex.DecodeString(input)
+			require.NoError(t, err)
+			_, err = cbor.FromCBOR(b)
+			assert.ErrorContains(t, err, contains)
+		})
+	}
+
+	_, err := cbor.FromCBOR([]byte{0x01, 0x02})
+	assert.ErrorIs(t, err, cbor.ErrTrailingData)
+	_, err = cbor.ToCBOR([]byte{msgpack.FormatNil + 1})
+	assert.ErrorContains(t, err, "unsupported format 0xc1")
+}
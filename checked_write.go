@@ -0,0 +1,86 @@
+package msgpack
+
+import "strconv"
+
+// WriteChecked writes `v` with `write` when `check` accepts it. When
+// `check` returns an error, nothing is written and the error is recorded
+// on `w`, so that the Sizer pass of an Encode method fails as the Encoder
+// pass does and the first error stays the one Err reports. The error is
+// returned too, for Encode methods that return early. It is a WriteError,
+// as the errors of the other checked writes are, that names the value
+// with `describe`, such as "port", and wraps the error of `check`:
+// `msgpack: port: must be even`.
+//
+// Through a PathWriter the recorded error is an EncodePathError with the
+// path of the value. An UpperBoundSizer records no errors, so there the
+// error is only returned.
+func WriteChecked[T any](w Writer, v T, check func(T) error, write func(Writer, T), describe string) error {
+	if err := check(v); err != nil {
+		return failWrite(w, WriteError{message: "msgpack: " + describe + ": " + err.Error(), err: err})
+	}
+	write(w, v)
+	return w.Err()
+}
+
+// WriteInt64InRange writes `v` when it is between `min` and `max`
+// inclusive, and otherwise records a WriteError and writes nothing, as
+// WriteChecked does. The error names the value with `describe`, such as
+// "port": `msgpack: port -1 is not in the range 0 to 65535`.
+func WriteInt64InRange(w Writer, v, min, max int64, describe string) error {
+	if v < min || v > max {
+		return failWrite(w, WriteError{message: "msgpack: " + describe + " " + strconv.FormatInt(v, 10) +
+			" is not in the range " + strconv.FormatInt(min, 10) + " to " + strconv.FormatInt(max, 10)})
+	}
+	w.WriteInt64(v)
+	return w.Err()
+}
+
+// WriteStringNonEmpty writes `v` when it is not empty, and otherwise
+// records a WriteError and writes nothing, as WriteChecked does. The error
+// names the value with `describe`, such as "name": `msgpack: name is
+// empty`.
+func WriteStringNonEmpty(w Writer, v string, describe string) error {
+	if v == "" {
+		return failWrite(w, WriteError{message: "msgpack: " + describe + " is empty"})
+	}
+	w.WriteString(v)
+	return w.Err()
+}
+
+// WriteStringMatching writes `v` when `ok` accepts it, and otherwise
+// records a WriteError and writes nothing, as WriteChecked does. The error
+// names the value and what it should have been, `describe`, such as
+// "a hostname": `msgpack: "a b" is not a hostname`.
+func WriteStringMatching(w Writer, v string, ok func(string) bool, describe string) error {
+	if !ok(v) {
+		return failWrite(w, WriteError{message: "msgpack: " + strconv.Quote(v) + " is not " + describe})
+	}
+	w.WriteString(v)
+	return w.Err()
+}
+
+// failWrite records `err` on `w` unless it has failed already, and
+// returns the error `w` now reports, or `err` when `w` cannot record one.
+func failWrite(w Writer, err error) error {
+	switch w := w.(type) {
+	case *Encoder:
+		if w.reader.err == nil {
+			w.reader.err = err
+		}
+	case *Sizer:
+		if w.err == nil {
+			w.err = err
+		}
+	case *WriterAdapter:
+		w.setErr(err)
+	case *hashWriter:
+		failWrite(&w.enc, err)
+	case *PathWriter:
+		failWrite(w.w, err)
+		w.check()
+	}
+	if recorded := w.Err(); recorded != nil {
+		return recorded
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package msgpack_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// checkedWriters returns a new Sizer and a new Encoder, with the functions
+// that report how much each has written.
+func checkedWriters() map[string]func() (msgpack.Writer, func() uint32) {
+	return map[string]func() (msgpack.Writer, func() uint32){
+		"Sizer": func() (msgpack.Writer, func() uint32) {
+			var sizer msgpack.Sizer
+			return &sizer, sizer.Len
+		},
+		"Encoder": func() (msgpack.Writer, func() uint32) {
+			encoder := msgpack.NewEncoder(make([]byte, 64))
+			return &encoder, encoder.Len
+		},
+	}
+}
+
+func isHostname(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " /")
+}
+
+var errOdd = errors.New("must be even")
+
+func checkEven(v int64) error {
+	if v%2 != 0 {
+		return errOdd
+	}
+	return nil
+}
+
+func writeInt64(w msgpack.Writer, v int64) {
+	w.WriteInt64(v)
+}
+
+func TestCheckedWrites(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ok   func(w msgpack.Writer) error
+		fail func(w msgpack.Writer) error
+		// message is the error of fail.
+		message string
+	}{
+		"WriteInt64InRange": {
+			ok:      func(w msgpack.Writer) error { return msgpack.WriteInt64InRange(w, 300, 0, 65535, "port") },
+			fail:    func(w msgpack.Writer) error { return msgpack.WriteInt64InRange(w, -1, 0, 65535, "port") },
+			message: "msgpack: port -1 is not in the range 0 to 65535",
+		},
+		"WriteStringNonEmpty": {
+			ok:      func(w msgpack.Writer) error { return msgpack.WriteStringNonEmpty(w, "a", "name") },
+			fail:    func(w msgpack.Writer) error { return msgpack.WriteStringNonEmpty(w, "", "name") },
+			message: "msgpack: name is empty",
+		},
+		"WriteStringMatching": {
+			ok: func(w msgpack.Writer) error {
+				return msgpack.WriteStringMatching(w, "a.example.com", isHostname, "a hostname")
+			},
+			fail: func(w msgpack.Writer) error {
+				return msgpack.WriteStringMatching(w, "a b", isHostname, "a hostname")
+			},
+			message: `msgpack: "a b" is not a hostname`,
+		},
+		"WriteChecked": {
+			ok: func(w msgpack.Writer) error {
+				return msgpack.WriteChecked(w, 8080, checkEven, writeInt64, "port")
+			},
+			fail: func(w msgpack.Writer) error {
+				return msgpack.WriteChecked(w, 8081, checkEven, writeInt64, "port")
+			},
+			message: "msgpack: port: must be even",
+		},
+	} {
+		for writerName, newWriter := range checkedWriters() {
+			t.Run(name+"/"+writerName, func(t *testing.T) {
+				w, length := newWriter()
+				require.NoError(t, tc.ok(w))
+				written := length()
+				assert.Positive(t, written)
+
+				// A failing check writes nothing and the error sticks.
+				err := tc.fail(w)
+				assert.EqualError(t, err, tc.message)
+				var writeErr msgpack.WriteError
+				assert.ErrorAs(t, err, &writeErr)
+				assert.Equal(t, written, length())
+				assert.Equal(t, err, w.Err())
+
+				// The first error is the one reported.
+				assert.Equal(t, err, msgpack.WriteStringNonEmpty(w, "", "name"))
+				assert.Equal(t, err, w.Err())
+			})
+		}
+	}
+
+	var sizer msgpack.Sizer
+	err := msgpack.WriteInt64InRange(&sizer, 70000, 0, 65535, "port")
+	var writeErr msgpack.WriteError
+	assert.ErrorAs(t, err, &writeErr)
+	assert.ErrorIs(t, msgpack.WriteChecked(&sizer, 1, checkEven, writeInt64, "port"), err)
+
+	// The error of the check is wrapped in a WriteError.
+	sizer = msgpack.Sizer{}
+	err = msgpack.WriteChecked(&sizer, 1, checkEven, writeInt64, "port")
+	assert.ErrorIs(t, err, errOdd)
+	assert.IsType(t, msgpack.WriteError{}, err)
+}
+
+func TestCheckedWritesPath(t *testing.T) {
+	write := func(w msgpack.Writer) error {
+		w.WriteMapSize(1)
+		w.WriteString("hosts")
+		w.WriteArraySize(2)
+		msgpack.WriteStringMatching(w, "a.example.com", isHostname, "a hostname")
+		return msgpack.WriteStringMatching(w, "a b", isHostname, "a hostname")
+	}
+	for writerName, newWriter := range checkedWriters() {
+		t.Run(writerName, func(t *testing.T) {
+			w, _ := newWriter()
+			err := write(msgpack.NewPathWriter(w))
+			var pathErr msgpack.EncodePathError
+			require.ErrorAs(t, err, &pathErr)
+			assert.Equal(t, "hosts[1]", pathErr.Path)
+			assert.EqualError(t, err, `msgpack: encoding hosts[1]: msgpack: "a b" is not a hostname`)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package msgpack
+
+// SizerMark records the state of a Sizer so it can be restored by
+// Rollback.
+type SizerMark struct {
+	length  uint32
+	strings int
+	err     error
+}
+
+// Mark records the current length so that values sized after it can be
+// undone with Rollback.
+func (s *Sizer) Mark() SizerMark {
+	return SizerMark{s.length, len(s.strings.order), s.err}
+}
+
+// Rollback undoes everything sized since `mark` was taken, including any
+// error. Marks taken after `mark` must not be used afterwards.
+func (s *Sizer) Rollback(mark SizerMark) {
+	s.length = mark.length
+	s.strings.truncate(mark.strings)
+	s.err = mark.err
+}
+
+// EncoderMark records the state of an Encoder so it can be restored by
+// Rollback.
+type EncoderMark struct {
+	offset  uint32
+	strings int
+	err     error
+}
+
+// Mark records the current position so that values written after it can
+// be undone with Rollback. Together with Sizer.Mark this allows writing a
+// block speculatively and dropping it if it turns out not to be wanted.
+func (e *Encoder) Mark() EncoderMark {
+	return EncoderMark{e.reader.byteOffset, len(e.strings.order), e.reader.err}
+}
+
+// Rollback moves the encoder back to `mark`, undoing everything written
+// since, including any error. The bytes written after the mark stay in the
+// buffer as dead data and are overwritten by the next writes. Marks taken
+// after `mark` must not be used afterwards.
+func (e *Encoder) Rollback(mark EncoderMark) {
+	e.reader.byteOffset = mark.offset
+	e.strings.truncate(mark.strings)
+	e.reader.err = mark.err
+}
@@ -0,0 +1,87 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// writeReport starts writing an optional "debug" entry and bails out half
+// way through unless `keep` is set. The strings it wrote must be forgotten
+// by the string table too, or the "trace-id" after it becomes a reference
+// to a string the decoder never sees.
+func writeReport(w msgpack.Writer, mark func() func(), keep bool) {
+	w.WriteMapSize(2)
+	w.WriteString("status")
+	w.WriteString("ok")
+
+	rollback := mark()
+	w.WriteString("debug")
+	w.WriteArraySize(2)
+	w.WriteString("trace-id")
+	if !keep {
+		rollback()
+	}
+
+	w.WriteString("trace-id")
+	w.WriteInt64(42)
+}
+
+func writeReportWithout(w msgpack.Writer) {
+	w.WriteMapSize(2)
+	w.WriteString("status")
+	w.WriteString("ok")
+	w.WriteString("trace-id")
+	w.WriteInt64(42)
+}
+
+func TestMarkRollback(t *testing.T) {
+	for _, opts := range [][]msgpack.EncOption{nil, {msgpack.WithStringTable(stringTableExt)}} {
+		expected := encodeWithOptions(t, writeReportWithout, opts...)
+
+		sizer := msgpack.NewSizerWithOptions(opts...)
+		writeReport(&sizer, func() func() {
+			mark := sizer.Mark()
+			return func() { sizer.Rollback(mark) }
+		}, false)
+		assert.Equal(t, uint32(len(expected)), sizer.Len())
+
+		encoder := msgpack.NewEncoderWithOptions(make([]byte, 64), opts...)
+		writeReport(&encoder, func() func() {
+			mark := encoder.Mark()
+			return func() { encoder.Rollback(mark) }
+		}, false)
+		require.NoError(t, encoder.Err())
+		assert.Equal(t, expected, encoder.Bytes())
+	}
+}
+
+func TestMarkWithoutRollback(t *testing.T) {
+	sizer := msgpack.NewSizer()
+	writeReport(&sizer, func() func() {
+		mark := sizer.Mark()
+		return func() { sizer.Rollback(mark) }
+	}, true)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	writeReport(&encoder, func() func() {
+		mark := encoder.Mark()
+		return func() { encoder.Rollback(mark) }
+	}, true)
+	require.NoError(t, encoder.Err())
+	assert.Equal(t, sizer.Len(), encoder.Len())
+}
+
+func TestRollbackClearsError(t *testing.T) {
+	encoder := msgpack.NewEncoder(make([]byte, 4))
+	encoder.WriteArraySize(1)
+	mark := encoder.Mark()
+	encoder.WriteString("does not fit")
+	require.ErrorIs(t, encoder.Err(), msgpack.ErrRange)
+	encoder.Rollback(mark)
+	require.NoError(t, encoder.Err())
+	encoder.WriteNil()
+	assert.Equal(t, []byte{0x91, msgpack.FormatNil}, encoder.Bytes())
+}
@@ -0,0 +1,116 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ChecksumExtType is the default ext type of the trailer written by
+// ToBytesChecked.
+const ChecksumExtType int8 = 101
+
+// checksumTrailerLen is the size of the trailer: a fixext4 header and type
+// followed by the CRC.
+const checksumTrailerLen = 6
+
+var (
+	// ErrChecksum is returned by FromBytesChecked when the CRC in the
+	// trailer does not match the message.
+	ErrChecksum = errors.New("msgpack: checksum mismatch")
+	// ErrMissingChecksum is returned by FromBytesChecked when the message
+	// does not end with a checksum trailer.
+	ErrMissingChecksum = errors.New("msgpack: missing checksum")
+)
+
+type checksumOptions struct {
+	extType        int8
+	allowUnchecked bool
+}
+
+// ChecksumOption configures ToBytesChecked and FromBytesChecked.
+type ChecksumOption func(*checksumOptions)
+
+// WithChecksumExtType sets the ext type of the checksum trailer. Both sides
+// must use the same type. The default is ChecksumExtType.
+func WithChecksumExtType(extType int8) ChecksumOption {
+	return func(o *checksumOptions) {
+		o.extType = extType
+	}
+}
+
+// WithUncheckedAllowed makes FromBytesChecked decode a message without a
+// trailer instead of returning ErrMissingChecksum, for use while senders
+// are moved over to ToBytesChecked. A trailer that is present is still
+// verified.
+func WithUncheckedAllowed() ChecksumOption {
+	return func(o *checksumOptions) {
+		o.allowUnchecked = true
+	}
+}
+
+func checksumOptionsOf(opts []ChecksumOption) checksumOptions {
+	o := checksumOptions{extType: ChecksumExtType}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ToBytesChecked creates a `[]byte` from `codec` as ToBytes does and
+// appends a trailer holding the CRC-32 (IEEE) of the encoded value. The
+// trailer is a fixext4 value, so a decoder that does not know about it can
+// read or Skip it as a second value.
+func ToBytesChecked(codec Codec, opts ...ChecksumOption) ([]byte, error) {
+	o := checksumOptionsOf(opts)
+	var sizer Sizer
+	if err := codec.Encode(&sizer); err != nil {
+		return nil, err
+	}
+	n := sizer.Len()
+	buffer := make([]byte, n+checksumTrailerLen)
+	encoder := NewEncoder(buffer[:n])
+	if err := codec.Encode(&encoder); err != nil {
+		return nil, err
+	}
+	if err := encoder.Err(); err != nil {
+		return nil, err
+	}
+	trailer := appendExtHeader(buffer[n:n], o.extType, 4)
+	binary.BigEndian.PutUint32(trailer[2:checksumTrailerLen], checksum(buffer[:n]))
+	return buffer, nil
+}
+
+// FromBytesChecked verifies the trailer written by ToBytesChecked and
+// decodes the value before it into `target`. It returns ErrMissingChecksum
+// when `data` does not end with a trailer of the expected ext type and
+// ErrChecksum when the CRC does not match; `target` is not touched in
+// either case. The value must use all the bytes before the trailer.
+func FromBytesChecked(data []byte, target Decodable, opts ...ChecksumOption) error {
+	o := checksumOptionsOf(opts)
+	payload, sum, ok := splitChecksum(data, o.extType)
+	switch {
+	case !ok && !o.allowUnchecked:
+		return ErrMissingChecksum
+	case !ok:
+		payload = data
+	case checksum(payload) != sum:
+		return ErrChecksum
+	}
+
+	d := NewDecoder(payload)
+	if err := checkDecoded(&d, target.Decode(&d)); err != nil {
+		return err
+	}
+	return d.ExpectEOF()
+}
+
+// splitChecksum splits `data` into the bytes before its checksum trailer
+// and the CRC in the trailer. It reports false when `data` does not end
+// with a trailer of type `extType`.
+func splitChecksum(data []byte, extType int8) ([]byte, uint32, bool) {
+	n := len(data) - checksumTrailerLen
+	if n < 0 || data[n] != FormatFixExt4 || int8(data[n+1]) != extType {
+		return nil, 0, false
+	}
+	return data[:n], binary.BigEndian.Uint32(data[n+2:]), true
+}
@@ -0,0 +1,11 @@
+//go:build !tinygo
+// +build !tinygo
+
+package msgpack
+
+import "hash/crc32"
+
+// checksum returns the CRC-32 (IEEE) of `b`.
+func checksum(b []byte) uint32 {
+	return crc32.ChecksumIEEE(b)
+}
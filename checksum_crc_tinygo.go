@@ -0,0 +1,32 @@
+//go:build tinygo
+// +build tinygo
+
+package msgpack
+
+// crcTable is a single 256 entry table for the IEEE polynomial. The
+// hash/crc32 package builds an 8 KiB slicing table on targets without
+// hardware support, which is more than a small module should carry.
+var crcTable [256]uint32
+
+func init() {
+	for i := range crcTable {
+		c := uint32(i)
+		for j := 0; j < 8; j++ {
+			if c&1 == 1 {
+				c = c>>1 ^ 0xedb88320
+			} else {
+				c >>= 1
+			}
+		}
+		crcTable[i] = c
+	}
+}
+
+// checksum returns the CRC-32 (IEEE) of `b`.
+func checksum(b []byte) uint32 {
+	c := ^uint32(0)
+	for _, v := range b {
+		c = crcTable[byte(c)^v] ^ c>>8
+	}
+	return ^c
+}
@@ -0,0 +1,112 @@
+package msgpack_test
+
+import (
+	"hash/crc32"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestChecksumRoundTrip(t *testing.T) {
+	person := Person{Name: "Ada", Age: 36, Tags: []string{"math"}, Metadata: msgpack.Raw{0xc0}}
+	plain, err := msgpack.ToBytes(personCodec.Bind(&person))
+	require.NoError(t, err)
+	data, err := msgpack.ToBytesChecked(personCodec.Bind(&person))
+	require.NoError(t, err)
+
+	require.Len(t, data, len(plain)+6)
+	assert.Equal(t, plain, data[:len(plain)])
+	sum := crc32.ChecksumIEEE(plain)
+	assert.Equal(t, []byte{0xd6, byte(msgpack.ChecksumExtType), byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum)},
+		data[len(plain):])
+
+	var decoded Person
+	require.NoError(t, msgpack.FromBytesChecked(data, personCodec.Bind(&decoded)))
+	assert.Equal(t, person, decoded)
+
+	// A decoder that does not know about the trailer reads it as an ext.
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, decoder.Skip())
+	require.NoError(t, decoder.Skip())
+	assert.Equal(t, uint32(0), decoder.Remaining())
+}
+
+func TestChecksumCorruption(t *testing.T) {
+	person := Person{Name: "Ada", Age: 36, Tags: []string{"math", "logic"}}
+	data, err := msgpack.ToBytesChecked(personCodec.Bind(&person))
+	require.NoError(t, err)
+	n := len(data) - 6
+
+	tests := []struct {
+		name   string
+		offset int
+		err    error
+	}{
+		{"start", 0, msgpack.ErrChecksum},
+		{"middle", n / 2, msgpack.ErrChecksum},
+		{"end", n - 1, msgpack.ErrChecksum},
+		{"trailer crc", len(data) - 1, msgpack.ErrChecksum},
+		{"trailer type", n + 1, msgpack.ErrMissingChecksum},
+		{"trailer header", n, msgpack.ErrMissingChecksum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := append([]byte(nil), data...)
+			corrupted[tt.offset] ^= 0x01
+			decoded := Person{Name: "untouched"}
+			err := msgpack.FromBytesChecked(corrupted, personCodec.Bind(&decoded))
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, Person{Name: "untouched"}, decoded)
+		})
+	}
+
+	// A message cut short loses its trailer.
+	for i := 0; i < len(data); i++ {
+		var decoded Person
+		err := msgpack.FromBytesChecked(data[:i], personCodec.Bind(&decoded))
+		assert.ErrorIs(t, err, msgpack.ErrMissingChecksum, "length %d", i)
+	}
+}
+
+func TestChecksumOptions(t *testing.T) {
+	person := Person{Name: "Ada", Age: 36, Metadata: msgpack.Raw{0xc0}}
+	plain, err := msgpack.ToBytes(personCodec.Bind(&person))
+	require.NoError(t, err)
+	var decoded Person
+
+	assert.ErrorIs(t, msgpack.FromBytesChecked(plain, personCodec.Bind(&decoded)), msgpack.ErrMissingChecksum)
+	require.NoError(t, msgpack.FromBytesChecked(plain, personCodec.Bind(&decoded), msgpack.WithUncheckedAllowed()))
+	assert.Equal(t, person, decoded)
+
+	// A trailer is still verified when unchecked messages are allowed.
+	data, err := msgpack.ToBytesChecked(personCodec.Bind(&person))
+	require.NoError(t, err)
+	data[0] ^= 0x01
+	assert.ErrorIs(t, msgpack.FromBytesChecked(data, personCodec.Bind(&decoded), msgpack.WithUncheckedAllowed()),
+		msgpack.ErrChecksum)
+
+	data, err = msgpack.ToBytesChecked(personCodec.Bind(&person), msgpack.WithChecksumExtType(7))
+	require.NoError(t, err)
+	assert.Equal(t, byte(7), data[len(data)-5])
+	assert.ErrorIs(t, msgpack.FromBytesChecked(data, personCodec.Bind(&decoded)), msgpack.ErrMissingChecksum)
+	decoded = Person{}
+	require.NoError(t, msgpack.FromBytesChecked(data, personCodec.Bind(&decoded), msgpack.WithChecksumExtType(7)))
+	assert.Equal(t, person, decoded)
+}
+
+func TestChecksumTrailingBytes(t *testing.T) {
+	person := Person{Name: "Ada"}
+	plain, err := msgpack.ToBytes(personCodec.Bind(&person))
+	require.NoError(t, err)
+	data := append(append([]byte(nil), plain...), 0xc0)
+	sum := crc32.ChecksumIEEE(data)
+	data = append(data, 0xd6, byte(msgpack.ChecksumExtType), byte(sum>>24), byte(sum>>16), byte(sum>>8), byte(sum))
+
+	var decoded Person
+	err = msgpack.FromBytesChecked(data, personCodec.Bind(&decoded))
+	assert.EqualError(t, err, "msgpack: 1 trailing bytes at offset "+strconv.Itoa(len(plain)))
+}
@@ -1,10 +1,99 @@
 package msgpack
 
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// ErrBufferTooSmall is matched by the BufferTooSmallError ToBytesIn
+// returns.
+var ErrBufferTooSmall = errors.New("msgpack: buffer too small")
+
+// BufferTooSmallError is returned by ToBytesIn when the encoding does not
+// fit in the buffer it is given. It matches ErrBufferTooSmall with
+// errors.Is.
+type BufferTooSmallError struct {
+	// Required is the size of the encoding, which a buffer must have to
+	// retry with.
+	Required uint32
+	// Available is the length of the buffer given.
+	Available uint32
+}
+
+func (e BufferTooSmallError) Error() string {
+	return "msgpack: encoding of " + strconv.FormatUint(uint64(e.Required), 10) +
+		" bytes does not fit in a buffer of " + strconv.FormatUint(uint64(e.Available), 10)
+}
+
+func (e BufferTooSmallError) Unwrap() error {
+	return ErrBufferTooSmall
+}
+
+// Decodable is implemented by data structures that can decode themselves
+// from the MessagePack format.
+type Decodable interface {
+	Decode(Reader) error
+}
+
+// Encodable is implemented by data structures that can encode themselves
+// to the MessagePack format.
+type Encodable interface {
+	Encode(Writer) error
+}
+
 // Codec is the interface that applies to data structures that can
 // encode to and decode from the MessagPack format.
 type Codec interface {
-	Decode(Reader) error
-	Encode(Writer) error
+	Decodable
+	Encodable
+}
+
+// WriteEncodableOrNil writes nil when `value` is nil, including a nil
+// pointer stored in the interface, and otherwise encodes it with its Encode
+// method. It is meant for interface-typed fields such as `Payload
+// Encodable`; read them back with DecodeIfPresent.
+func WriteEncodableOrNil(w Writer, value Encodable) error {
+	if isNil(value) {
+		w.WriteNil()
+		return nil
+	}
+	return value.Encode(w)
+}
+
+// DecodeIfPresent reads nil as nothing and anything else as a new T, which
+// it passes to `assign`. Unlike DecodeNillable it does not return a typed
+// nil pointer, so a nil on the wire leaves an interface field it assigns
+// to untouched:
+//
+//	err := msgpack.DecodeIfPresent(r, func(p *Payload) { m.Payload = p })
+func DecodeIfPresent[T any, PT interface {
+	*T
+	Decodable
+}](r Reader, assign func(PT)) error {
+	if isNil, err := readNil(r); isNil || err != nil {
+		return err
+	}
+	value := PT(new(T))
+	if err := checkDecoded(r, value.Decode(r)); err != nil {
+		return err
+	}
+	assign(value)
+	return nil
+}
+
+// isNil reports whether `value` is nil or holds a nil pointer, map, slice,
+// channel or function, which a plain comparison with nil does not catch.
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
 }
 
 // ToBytes creates a `[]byte` from `codec`.
@@ -18,7 +107,44 @@ func ToBytes(codec Codec) ([]byte, error) {
 	if err := codec.Encode(&encoder); err != nil {
 		return nil, err
 	}
-	return buffer, nil
+	return encoder.Bytes(), nil
+}
+
+// RequiredSize returns the size of the encoding of `codec`, for allocating
+// the buffer passed to ToBytesIn.
+func RequiredSize(codec Codec) (uint32, error) {
+	var sizer Sizer
+	if err := codec.Encode(&sizer); err != nil {
+		return 0, err
+	}
+	if err := sizer.Err(); err != nil {
+		return 0, err
+	}
+	return sizer.Len(), nil
+}
+
+// ToBytesIn encodes `codec` into `buf`, such as a region of an arena the
+// caller owns, and returns `buf` sliced to the encoding. If `buf` is too
+// short, it returns a BufferTooSmallError with the size needed and leaves
+// `buf` untouched, so that the caller can retry with a larger buffer. The
+// result aliases `buf`, which may be reused once the result is no longer
+// needed.
+func ToBytesIn(buf []byte, codec Codec) ([]byte, error) {
+	size, err := RequiredSize(codec)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(buf)) < uint64(size) {
+		return nil, BufferTooSmallError{Required: size, Available: uint32(len(buf))}
+	}
+	encoder := NewEncoder(buf[:size])
+	if err := codec.Encode(&encoder); err != nil {
+		return nil, err
+	}
+	if err := encoder.Err(); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
 }
 
 // AnyToBytes creates a `[]byte` from `value`.
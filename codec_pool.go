@@ -0,0 +1,82 @@
+//go:build !tinygo
+// +build !tinygo
+
+package msgpack
+
+import (
+	"errors"
+	"sync"
+)
+
+// CodecPool encodes and decodes using pooled encoders, decoders and scratch
+// buffers so that busy hosts do not allocate them for every message. It is
+// safe for concurrent use.
+//
+// TinyGo builds get a CodecPool with the same methods that does no pooling.
+type CodecPool struct {
+	bufSize int
+	pool    sync.Pool
+}
+
+type pooledCodec struct {
+	buffer  []byte
+	encoder Encoder
+	decoder Decoder
+}
+
+// NewCodecPool creates a pool whose scratch buffers start at `bufSize`
+// bytes. Buffers grow as larger messages are encoded.
+func NewCodecPool(bufSize int) *CodecPool {
+	p := &CodecPool{bufSize: bufSize}
+	p.pool.New = func() any {
+		return &pooledCodec{buffer: make([]byte, bufSize)}
+	}
+	return p
+}
+
+// Encode encodes `value` and returns a copy of the bytes owned by the
+// caller. The value is encoded straight into a pooled buffer, and is sized
+// first only when it does not fit.
+func (p *CodecPool) Encode(value Encodable) ([]byte, error) {
+	pc := p.pool.Get().(*pooledCodec)
+	defer p.pool.Put(pc)
+
+	pc.encoder = NewEncoder(pc.buffer)
+	err := value.Encode(&pc.encoder)
+	if err == nil {
+		err = pc.encoder.Err()
+	}
+	if errors.Is(err, ErrRange) {
+		var sizer Sizer
+		if err := value.Encode(&sizer); err != nil {
+			return nil, err
+		}
+		pc.buffer = make([]byte, sizer.Len())
+		pc.encoder = NewEncoder(pc.buffer)
+		err = value.Encode(&pc.encoder)
+		if err == nil {
+			err = pc.encoder.Err()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := pc.encoder.Bytes()
+	result := make([]byte, len(encoded))
+	copy(result, encoded)
+	pc.encoder = Encoder{}
+	return result, nil
+}
+
+// Decode decodes `data` into `target` with a pooled decoder. As with any
+// Decoder, strings and byte slices in `target` alias `data`.
+func (p *CodecPool) Decode(data []byte, target Decodable) error {
+	pc := p.pool.Get().(*pooledCodec)
+	defer p.pool.Put(pc)
+
+	pc.decoder = NewDecoder(data)
+	err := target.Decode(&pc.decoder)
+	pc.decoder = Decoder{}
+	return err
+}
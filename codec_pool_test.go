@@ -0,0 +1,89 @@
+package msgpack_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type poolMessage struct {
+	ID   int64
+	Body string
+}
+
+func (m *poolMessage) Encode(w msgpack.Writer) error {
+	w.WriteArraySize(2)
+	w.WriteInt64(m.ID)
+	w.WriteString(m.Body)
+	return nil
+}
+
+func (m *poolMessage) Decode(r msgpack.Reader) error {
+	if _, err := r.ReadArraySize(); err != nil {
+		return err
+	}
+	var err error
+	if m.ID, err = r.ReadInt64(); err != nil {
+		return err
+	}
+	m.Body, err = r.ReadString()
+	return err
+}
+
+func TestCodecPool(t *testing.T) {
+	pool := msgpack.NewCodecPool(16)
+
+	small := &poolMessage{ID: 1, Body: "hi"}
+	data, err := pool.Encode(small)
+	require.NoError(t, err)
+	expected, err := msgpack.ToBytes(small)
+	require.NoError(t, err)
+	assert.Equal(t, expected, data)
+
+	// Larger than the initial buffer.
+	large := &poolMessage{ID: 2, Body: strings.Repeat("x", 1000)}
+	data, err = pool.Encode(large)
+	require.NoError(t, err)
+	var decoded poolMessage
+	require.NoError(t, pool.Decode(data, &decoded))
+	assert.Equal(t, *large, decoded)
+
+	// Returned bytes are owned by the caller and not reused by the pool.
+	first, err := pool.Encode(&poolMessage{ID: 3, Body: "first"})
+	require.NoError(t, err)
+	_, err = pool.Encode(&poolMessage{ID: 4, Body: "second"})
+	require.NoError(t, err)
+	require.NoError(t, pool.Decode(first, &decoded))
+	assert.Equal(t, poolMessage{ID: 3, Body: "first"}, decoded)
+
+	assert.Error(t, pool.Decode([]byte{0x92, 0x01}, &decoded))
+}
+
+func TestCodecPoolConcurrent(t *testing.T) {
+	pool := msgpack.NewCodecPool(32)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				in := &poolMessage{ID: int64(g*1000 + i), Body: strings.Repeat("y", (g*i)%100)}
+				data, err := pool.Encode(in)
+				if !assert.NoError(t, err) {
+					return
+				}
+				var out poolMessage
+				if !assert.NoError(t, pool.Decode(data, &out)) {
+					return
+				}
+				assert.Equal(t, *in, out)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
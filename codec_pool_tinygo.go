@@ -0,0 +1,33 @@
+//go:build tinygo
+// +build tinygo
+
+package msgpack
+
+// CodecPool provides the CodecPool API without pooling. TinyGo programs
+// are single threaded, so each call allocates what it needs.
+type CodecPool struct{}
+
+// NewCodecPool creates a CodecPool. `bufSize` is ignored.
+func NewCodecPool(bufSize int) *CodecPool {
+	return &CodecPool{}
+}
+
+// Encode encodes `value` and returns the bytes.
+func (p *CodecPool) Encode(value Encodable) ([]byte, error) {
+	var sizer Sizer
+	if err := value.Encode(&sizer); err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, sizer.Len())
+	encoder := NewEncoder(buffer)
+	if err := value.Encode(&encoder); err != nil {
+		return nil, err
+	}
+	return buffer, encoder.Err()
+}
+
+// Decode decodes `data` into `target`.
+func (p *CodecPool) Decode(data []byte, target Decodable) error {
+	decoder := NewDecoder(data)
+	return target.Decode(&decoder)
+}
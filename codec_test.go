@@ -0,0 +1,88 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func greeting(name string) writeCodec {
+	return func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("hello")
+		w.WriteString(name)
+	}
+}
+
+func TestToBytesIn(t *testing.T) {
+	expected, err := msgpack.ToBytes(greeting("world"))
+	require.NoError(t, err)
+	size, err := msgpack.RequiredSize(greeting("world"))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(len(expected)), size)
+
+	exact := make([]byte, size)
+	data, err := msgpack.ToBytesIn(exact, greeting("world"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, data)
+	assert.Equal(t, &exact[0], &data[0])
+
+	large := bytes.Repeat([]byte{0xee}, 64)
+	data, err = msgpack.ToBytesIn(large, greeting("world"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, data)
+	assert.Equal(t, &large[0], &data[0])
+	assert.Equal(t, bytes.Repeat([]byte{0xee}, 64-len(expected)), large[len(expected):])
+}
+
+func TestToBytesInTooSmall(t *testing.T) {
+	small := bytes.Repeat([]byte{0xee}, 4)
+	data, err := msgpack.ToBytesIn(small, greeting("world"))
+	assert.Nil(t, data)
+	assert.EqualError(t, err, "msgpack: encoding of 13 bytes does not fit in a buffer of 4")
+	assert.True(t, errors.Is(err, msgpack.ErrBufferTooSmall))
+	var tooSmall msgpack.BufferTooSmallError
+	require.ErrorAs(t, err, &tooSmall)
+	assert.Equal(t, uint32(13), tooSmall.Required)
+	assert.Equal(t, bytes.Repeat([]byte{0xee}, 4), small)
+
+	data, err = msgpack.ToBytesIn(make([]byte, tooSmall.Required), greeting("world"))
+	require.NoError(t, err)
+	assert.Len(t, data, 13)
+
+	_, err = msgpack.ToBytesIn(nil, greeting("world"))
+	assert.ErrorIs(t, err, msgpack.ErrBufferTooSmall)
+}
+
+func TestToBytesInReuse(t *testing.T) {
+	buf := make([]byte, 32)
+	for i, name := range []string{"a much longer name", "b", "a medium name"} {
+		expected, err := msgpack.ToBytes(greeting(name))
+		require.NoError(t, err)
+		data, err := msgpack.ToBytesIn(buf, greeting(name))
+		require.NoError(t, err, strconv.Itoa(i))
+		assert.Equal(t, expected, data)
+
+		decoder := msgpack.NewDecoder(data)
+		decoded, err := decoder.ReadAny()
+		require.NoError(t, err)
+		assert.Equal(t, map[any]any{"hello": name}, decoded)
+		require.NoError(t, decoder.ExpectEOF())
+	}
+}
+
+func TestToBytesInErrors(t *testing.T) {
+	buf := bytes.Repeat([]byte{0xee}, 16)
+	_, err := msgpack.ToBytesIn(buf, recordsDoc{n: -1})
+	assert.EqualError(t, err, "recordsDoc cannot encode")
+	assert.Equal(t, bytes.Repeat([]byte{0xee}, 16), buf)
+
+	_, err = msgpack.RequiredSize(recordsDoc{n: -1})
+	assert.EqualError(t, err, "recordsDoc cannot encode")
+}
@@ -0,0 +1,94 @@
+package msgpack
+
+import "strconv"
+
+// WithStringToNumberCoercion makes ReadInt64, ReadUint64, ReadFloat32,
+// ReadFloat64 and the reads built on them accept a string holding a
+// number, for producers that do not keep track of types. Integers are
+// parsed in base 10 with strconv.ParseInt and strconv.ParseUint, and
+// floats with strconv.ParseFloat, so surrounding whitespace is rejected.
+// A string that does not parse is a ReadError naming the string. ReadAny
+// still returns the string. The default is to reject strings.
+func WithStringToNumberCoercion() DecOption {
+	return func(o *decOptions) {
+		o.stringToNumber = true
+	}
+}
+
+// WithNumberToStringCoercion makes ReadString and the reads built on it
+// accept an integer or a float, formatted in base 10 as by
+// strconv.FormatInt and strconv.FormatUint or in the shortest form that
+// reads back as the same float. ReadAny still returns the number. The
+// default is to reject numbers.
+func WithNumberToStringCoercion() DecOption {
+	return func(o *decOptions) {
+		o.numberToString = true
+	}
+}
+
+// coerce is the ReadHook that applies the coercion options of the
+// Decoder `r`, which hookRead tries before the hook of the Decoder.
+func coerce(requested, actual ValueKind, r ReadHookReader, result *ReadHookResult) (bool, error) {
+	d := r.(*Decoder)
+	switch requested {
+	case KindInt, KindUint, KindFloat:
+		if d.options.stringToNumber && (actual == KindString || actual == KindExt && d.options.stringTable) {
+			return true, d.parseNumber(requested, result)
+		}
+	case KindString:
+		if d.options.numberToString && (actual == KindInt || actual == KindUint || actual == KindFloat) {
+			return true, d.formatNumber(result)
+		}
+	}
+	return false, nil
+}
+
+// parseNumber reads a string and parses it as a number of kind `kind`.
+func (d *Decoder) parseNumber(kind ValueKind, result *ReadHookResult) error {
+	offset := d.reader.byteOffset
+	s, err := d.ReadString()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case KindInt:
+		result.Int, err = strconv.ParseInt(s, 10, 64)
+	case KindUint:
+		result.Uint, err = strconv.ParseUint(s, 10, 64)
+	default:
+		result.Float, err = strconv.ParseFloat(s, 64)
+	}
+	if err == nil {
+		return nil
+	}
+	if numErr, ok := err.(*strconv.NumError); ok {
+		err = numErr.Err
+	}
+	return ReadError{"msgpack: cannot coerce string " + strconv.Quote(s) + " at offset " +
+		strconv.FormatUint(uint64(offset), 10) + " to " + kind.String() + ": " + err.Error()}
+}
+
+// formatNumber reads an integer or a float and formats it.
+func (d *Decoder) formatNumber(result *ReadHookResult) error {
+	prefix, err := d.PeekFormat()
+	if err != nil {
+		return err
+	}
+	switch prefix {
+	case FormatFloat32:
+		f, err := d.ReadFloat32()
+		result.String = strconv.FormatFloat(float64(f), 'g', -1, 32)
+		return err
+	case FormatFloat64:
+		f, err := d.ReadFloat64()
+		result.String = strconv.FormatFloat(f, 'g', -1, 64)
+		return err
+	}
+	v, u, unsigned, err := d.readInteger()
+	if unsigned {
+		result.String = strconv.FormatUint(u, 10)
+	} else {
+		result.String = strconv.FormatInt(v, 10)
+	}
+	return err
+}
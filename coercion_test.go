@@ -0,0 +1,244 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func stringsDecoder(t *testing.T, opts []msgpack.DecOption, values ...string) msgpack.Decoder {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		for _, v := range values {
+			w.WriteString(v)
+		}
+	})
+	return msgpack.NewDecoderWithOptions(data, opts...)
+}
+
+func TestStringToNumberCoercion(t *testing.T) {
+	opts := []msgpack.DecOption{msgpack.WithStringToNumberCoercion()}
+	d := stringsDecoder(t, opts, "-8", "-16", "-32", "-64", "8", "16", "32", "64", "1.5", "-0.25", "1e3")
+
+	i8, err := d.ReadInt8()
+	require.NoError(t, err)
+	assert.Equal(t, int8(-8), i8)
+	i16, err := d.ReadInt16()
+	require.NoError(t, err)
+	assert.Equal(t, int16(-16), i16)
+	i32, err := d.ReadInt32()
+	require.NoError(t, err)
+	assert.Equal(t, int32(-32), i32)
+	i64, err := d.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(-64), i64)
+	u8, err := d.ReadUint8()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(8), u8)
+	u16, err := d.ReadUint16()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(16), u16)
+	u32, err := d.ReadUint32()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(32), u32)
+	u64, err := d.ReadUint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(64), u64)
+	f32, err := d.ReadFloat32()
+	require.NoError(t, err)
+	assert.Equal(t, float32(1.5), f32)
+	f64, err := d.ReadFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, -0.25, f64)
+	f64, err = d.ReadFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, 1000.0, f64)
+	require.NoError(t, d.ExpectEOF())
+
+	d = stringsDecoder(t, opts, "18446744073709551615", "-9223372036854775808")
+	u64, err = d.ReadUint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), u64)
+	i64, err = d.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt64), i64)
+
+	// Numbers are still read as they are.
+	d = msgpack.NewDecoderWithOptions([]byte{0x05, 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, opts...)
+	i64, err = d.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), i64)
+	f64, err = d.ReadFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, f64)
+}
+
+func TestStringToNumberCoercionErrors(t *testing.T) {
+	opts := []msgpack.DecOption{msgpack.WithStringToNumberCoercion()}
+	for _, tc := range []struct {
+		name, value string
+		read        func(d *msgpack.Decoder) error
+		err         string
+	}{
+		{"syntax", "4x2", readWith((*msgpack.Decoder).ReadInt64),
+			`msgpack: cannot coerce string "4x2" at offset 0 to int: invalid syntax`},
+		{"negative uint", "-1", readWith((*msgpack.Decoder).ReadUint32),
+			`msgpack: cannot coerce string "-1" at offset 0 to uint: invalid syntax`},
+		{"float as int", "1.5", readWith((*msgpack.Decoder).ReadInt32),
+			`msgpack: cannot coerce string "1.5" at offset 0 to int: invalid syntax`},
+		{"range", "9223372036854775808", readWith((*msgpack.Decoder).ReadInt64),
+			`msgpack: cannot coerce string "9223372036854775808" at offset 0 to int: value out of range`},
+		{"width", "300", readWith((*msgpack.Decoder).ReadInt8),
+			"interger overflow: value = 300; bits = 8"},
+		{"empty", "", readWith((*msgpack.Decoder).ReadFloat64),
+			`msgpack: cannot coerce string "" at offset 0 to float: invalid syntax`},
+		{"leading space", " 42", readWith((*msgpack.Decoder).ReadInt64),
+			`msgpack: cannot coerce string " 42" at offset 0 to int: invalid syntax`},
+		{"trailing space", "42 ", readWith((*msgpack.Decoder).ReadUint64),
+			`msgpack: cannot coerce string "42 " at offset 0 to uint: invalid syntax`},
+		{"trailing newline", "1.5\n", readWith((*msgpack.Decoder).ReadFloat32),
+			`msgpack: cannot coerce string "1.5\n" at offset 0 to float: invalid syntax`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := stringsDecoder(t, opts, tc.value)
+			assert.EqualError(t, tc.read(&d), tc.err)
+		})
+	}
+}
+
+func readWith[T any](read func(d *msgpack.Decoder) (T, error)) func(d *msgpack.Decoder) error {
+	return func(d *msgpack.Decoder) error {
+		_, err := read(d)
+		return err
+	}
+}
+
+func TestNumberToStringCoercion(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteInt64(42)
+		w.WriteInt64(-7)
+		w.WriteInt64(math.MinInt64)
+		w.WriteUint64(math.MaxUint64)
+		w.WriteFloat32(0.1)
+		w.WriteFloat64(0.1)
+		w.WriteFloat64(1e21)
+		w.WriteString("text")
+	})
+	d := msgpack.NewDecoderWithOptions(data, msgpack.WithNumberToStringCoercion())
+	for _, expected := range []string{"42", "-7", "-9223372036854775808", "18446744073709551615", "0.1", "0.1", "1e+21", "text"} {
+		s, err := d.ReadString()
+		require.NoError(t, err)
+		assert.Equal(t, expected, s)
+	}
+	require.NoError(t, d.ExpectEOF())
+
+	d = msgpack.NewDecoderWithOptions([]byte{0xc3}, msgpack.WithNumberToStringCoercion())
+	_, err := d.ReadString()
+	var mismatch msgpack.TypeMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, msgpack.KindBool, mismatch.Actual)
+}
+
+func TestCoercionNillableAndElements(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteNil()
+		w.WriteString("12")
+		w.WriteNil()
+		w.WriteInt64(12)
+		w.WriteArraySize(3)
+		w.WriteInt64(1)
+		w.WriteString("2")
+		w.WriteString("3")
+		w.WriteMapSize(2)
+		w.WriteString("a")
+		w.WriteString("1.5")
+		w.WriteString("b")
+		w.WriteFloat64(2.5)
+	})
+	d := msgpack.NewDecoderWithOptions(data, msgpack.WithStringToNumberCoercion(), msgpack.WithNumberToStringCoercion())
+
+	n, err := d.ReadNillableInt32()
+	require.NoError(t, err)
+	assert.Nil(t, n)
+	n, err = d.ReadNillableInt32()
+	require.NoError(t, err)
+	require.NotNil(t, n)
+	assert.Equal(t, int32(12), *n)
+	s, err := d.ReadNillableString()
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	s, err = d.ReadNillableString()
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, "12", *s)
+
+	ints, err := d.ReadInt64SliceFast()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ints)
+	floats, err := msgpack.ReadFilteredMap(&d, func(string) bool { return true }, func(r msgpack.Reader) (float64, error) {
+		return r.ReadFloat64()
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"a": 1.5, "b": 2.5}, floats)
+}
+
+// ReadAny returns the values as they are on the wire.
+func TestCoercionReadAny(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("42")
+		w.WriteInt64(42)
+	})
+	d := msgpack.NewDecoderWithOptions(data, msgpack.WithStringToNumberCoercion(), msgpack.WithNumberToStringCoercion())
+	v, err := d.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, "42", v)
+	v, err = d.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), v)
+}
+
+func TestCoercionOptionsAreIndependent(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("42")
+		w.WriteInt64(42)
+	})
+	var mismatch msgpack.TypeMismatchError
+
+	d := msgpack.NewDecoderWithOptions(data, msgpack.WithStringToNumberCoercion())
+	i, err := d.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), i)
+	_, err = d.ReadString()
+	assert.ErrorAs(t, err, &mismatch)
+
+	d = msgpack.NewDecoderWithOptions(data, msgpack.WithNumberToStringCoercion())
+	_, err = d.ReadInt64()
+	assert.ErrorAs(t, err, &mismatch)
+	d = msgpack.NewDecoderWithOptions(data[3:], msgpack.WithNumberToStringCoercion())
+	s, err := d.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "42", s)
+
+	d = msgpack.NewDecoder(data)
+	_, err = d.ReadInt64()
+	assert.ErrorAs(t, err, &mismatch)
+}
+
+// Coercion comes before the read hook, which sees the values it leaves.
+func TestCoercionBeforeReadHook(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("7")
+		w.WriteInt64(3)
+	})
+	d := msgpack.NewDecoderWithOptions(data, msgpack.WithStringToNumberCoercion())
+	d.SetReadHook(intToFloat)
+	f, err := d.ReadFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, 7.0, f)
+	f, err = d.ReadFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, f)
+}
@@ -0,0 +1,138 @@
+package msgpack
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// WithCollectErrors makes ObjectCodec.Decode go on past fields that fail to
+// decode, so that one pass reports every problem with a message rather
+// than only the first. The value of a failing field is skipped and the
+// next field is read; once the whole object is read, the errors are
+// returned together in a MultiError. Fields of nested objects are
+// collected too, under their dotted path such as "home.city", and a
+// missing required field is one more error.
+//
+// Decoding stops once `max` errors are collected, which bounds the work
+// spent on garbage, and when a failing value cannot be skipped, such as an
+// array whose header claims more elements than the input holds, as the
+// fields after it cannot be found. A max below 1 leaves the option off.
+// The fields of a value that failed may be partly set.
+func WithCollectErrors(max int) DecOption {
+	return func(o *decOptions) {
+		o.collectErrors = max
+	}
+}
+
+// MultiError holds the field errors collected by a Decoder with
+// WithCollectErrors.
+type MultiError struct {
+	errs []FieldError
+}
+
+// Errors returns the field errors in the order they were found.
+func (e MultiError) Errors() []FieldError {
+	return e.errs
+}
+
+func (e MultiError) Error() string {
+	var b strings.Builder
+	b.WriteString("msgpack: ")
+	b.WriteString(strconv.Itoa(len(e.errs)))
+	b.WriteString(" decode errors")
+	for _, err := range e.errs {
+		b.WriteString("; ")
+		if err.Field != "" {
+			b.WriteString("field ")
+			b.WriteString(strconv.Quote(err.Field))
+			b.WriteString(" at offset ")
+			b.WriteString(strconv.FormatUint(uint64(err.Offset), 10))
+			b.WriteString(": ")
+		}
+		b.WriteString(err.Err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the errors of the fields, for errors.Is and errors.As.
+func (e MultiError) Unwrap() []error {
+	errs := make([]error, len(e.errs))
+	for i, err := range e.errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// errStopCollecting ends decoding once an errorCollector has enough
+// errors or lost its place in the input.
+var errStopCollecting = errors.New("msgpack: stopped collecting errors")
+
+// errorCollector gathers the field errors of an ObjectCodec.Decode on a
+// Decoder with WithCollectErrors, including those of nested objects.
+type errorCollector struct {
+	errs []FieldError
+	max  int
+	// path holds the keys of the fields being decoded, outermost first.
+	path []string
+}
+
+// collectorOf returns the collector of `r` when it is collecting errors.
+func collectorOf(r Reader) (*Decoder, *errorCollector) {
+	if d, ok := r.(*Decoder); ok && d.collector != nil {
+		return d, d.collector
+	}
+	return nil, nil
+}
+
+// add records an error of field `key`, found at `offset`, and returns
+// errStopCollecting once there are enough.
+func (c *errorCollector) add(key string, offset uint32, err error) error {
+	field := key
+	if len(c.path) > 0 {
+		field = strings.Join(c.path, ".") + "." + key
+	}
+	c.errs = append(c.errs, FieldError{Field: field, Err: err, Offset: offset})
+	if len(c.errs) >= c.max {
+		return errStopCollecting
+	}
+	return nil
+}
+
+// skipFailed records the error of field `key` and moves `d` past its
+// value, which starts at `start` with `frames` strict containers open.
+// It returns errStopCollecting when decoding cannot go on.
+func (c *errorCollector) skipFailed(d *Decoder, key string, start uint32, frames int, err error) error {
+	if err == errStopCollecting {
+		// A nested object has already recorded why.
+		return err
+	}
+	if err := c.add(key, start, err); err != nil {
+		return err
+	}
+	d.reader.byteOffset = start
+	d.containers = d.containers[:frames]
+	if d.Skip() != nil {
+		return errStopCollecting
+	}
+	return nil
+}
+
+// decodeCollecting runs `decode` with a collector on `d` and returns the
+// collected errors, with any error that ended decoding early last.
+func decodeCollecting(d *Decoder, decode func() error) error {
+	c := &errorCollector{max: d.options.collectErrors}
+	d.collector = c
+	err := decode()
+	d.collector = nil
+	if err != nil && err != errStopCollecting {
+		c.errs = append(c.errs, FieldError{Err: err, Offset: d.reader.byteOffset})
+	}
+	switch {
+	case len(c.errs) == 0:
+		return nil
+	case len(c.errs) == 1 && c.errs[0].Field == "":
+		return c.errs[0].Err
+	}
+	return MultiError{c.errs}
+}
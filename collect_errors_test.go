@@ -0,0 +1,130 @@
+package msgpack_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// personWithErrors writes a person whose age, home city and tags have the
+// wrong types, and whose name is missing.
+func personWithErrors(w msgpack.Writer) {
+	w.WriteMapSize(5)
+	w.WriteString("age")
+	w.WriteString("old")
+	w.WriteString("home")
+	w.WriteMapSize(2)
+	w.WriteString("city")
+	w.WriteInt64(7)
+	w.WriteString("street")
+	w.WriteString("1 Main St")
+	w.WriteString("tags")
+	w.WriteArraySize(2)
+	w.WriteString("math")
+	w.WriteBool(true)
+	w.WriteString("email")
+	w.WriteString("ada@example.com")
+	w.WriteString("nickname")
+	w.WriteString("Countess")
+}
+
+func TestCollectErrors(t *testing.T) {
+	data := encodeWith(t, personWithErrors)
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithCollectErrors(10))
+	var person Person
+	err := personCodec.Decode(&decoder, &person)
+
+	var multi msgpack.MultiError
+	require.ErrorAs(t, err, &multi)
+	errs := multi.Errors()
+	require.Len(t, errs, 4)
+	assert.Equal(t, "age", errs[0].Field)
+	assert.Equal(t, uint32(5), errs[0].Offset)
+	assert.Equal(t, "home.city", errs[1].Field)
+	assert.Equal(t, "tags", errs[2].Field)
+	assert.Equal(t, "name", errs[3].Field)
+	assert.EqualError(t, err, `msgpack: 4 decode errors; `+
+		`field "age" at offset 5: bad prefix for int64: got fixstr(3) (0xa3) at offset 5; `+
+		`field "home.city" at offset 20: bad prefix for string length: got fixint(7) (0x07) at offset 20; `+
+		`field "tags" at offset 43: bad prefix for string length: got true (0xc3) at offset 49; `+
+		`field "name" at offset 90: msgpack: missing required field "name"`)
+	assert.ErrorIs(t, err, msgpack.ErrBadPrefix)
+
+	// Decoding went on after each error, and the whole map was read.
+	require.NotNil(t, person.Email)
+	assert.Equal(t, "ada@example.com", *person.Email)
+	assert.Equal(t, "1 Main St", person.Home.Street)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+}
+
+func TestCollectErrorsLimit(t *testing.T) {
+	data := encodeWith(t, personWithErrors)
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithCollectErrors(2))
+	var person Person
+	err := personCodec.Decode(&decoder, &person)
+	var multi msgpack.MultiError
+	require.ErrorAs(t, err, &multi)
+	require.Len(t, multi.Errors(), 2)
+	assert.Equal(t, "home.city", multi.Errors()[1].Field)
+	// Nothing after the last error was read.
+	assert.Nil(t, person.Email)
+}
+
+// A value that cannot be skipped leaves nothing to find the next field
+// by, so collection stops there.
+func TestCollectErrorsStructural(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("age")
+		w.WriteString("old")
+		w.WriteString("tags")
+		// An array claiming more elements than the input holds.
+		w.WriteRaw(msgpack.Raw{msgpack.FormatArray16, 0xff, 0xff})
+		w.WriteString("x")
+		w.WriteString("name")
+		w.WriteString("Ada")
+	})
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithCollectErrors(10))
+	var person Person
+	err := personCodec.Decode(&decoder, &person)
+	var multi msgpack.MultiError
+	require.ErrorAs(t, err, &multi)
+	require.Len(t, multi.Errors(), 2)
+	assert.Equal(t, "age", multi.Errors()[0].Field)
+	assert.Equal(t, "tags", multi.Errors()[1].Field)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.Empty(t, person.Name)
+
+	// An error in the object itself is returned as it is.
+	decoder = msgpack.NewDecoderWithOptions([]byte{0x91, 0x01}, msgpack.WithCollectErrors(10))
+	err = personCodec.Decode(&decoder, &person)
+	assert.EqualError(t, err, "bad prefix for map length: got fixarray(1) (0x91) at offset 0")
+	assert.False(t, errors.As(err, &multi))
+}
+
+// A valid message decodes as it does without the option.
+func TestCollectErrorsValid(t *testing.T) {
+	email := "ada@example.com"
+	person := Person{Name: "Ada", Age: 36, Email: &email, Tags: []string{"math"}, Home: Address{City: "London"}}
+	data := encodeWith(t, func(w msgpack.Writer) { require.NoError(t, personCodec.Encode(w, &person)) })
+
+	var plain, collected Person
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, personCodec.Decode(&decoder, &plain))
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithCollectErrors(10))
+	require.NoError(t, personCodec.Decode(&decoder, &collected))
+	assert.Equal(t, plain, collected)
+	assert.Equal(t, person.Tags, collected.Tags)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+
+	// So does an invalid one without it: the first error is returned.
+	decoder = msgpack.NewDecoder(encodeWith(t, personWithErrors))
+	err := personCodec.Decode(&decoder, &plain)
+	var fieldErr msgpack.FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "age", fieldErr.Field)
+}
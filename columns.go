@@ -0,0 +1,232 @@
+package msgpack
+
+import (
+	"strconv"
+	"time"
+)
+
+// ColumnKind is the Go type of a column read by ReadColumns.
+type ColumnKind uint8
+
+const (
+	ColumnInt64 ColumnKind = iota + 1
+	ColumnFloat64
+	ColumnString
+	ColumnBool
+	ColumnTime
+)
+
+func (k ColumnKind) String() string {
+	switch k {
+	case ColumnInt64:
+		return "int64"
+	case ColumnFloat64:
+		return "float64"
+	case ColumnString:
+		return "string"
+	case ColumnBool:
+		return "bool"
+	case ColumnTime:
+		return "time"
+	}
+	return "invalid"
+}
+
+// ColumnSpec maps the fields of the rows read by ReadColumns to the kind
+// of their column.
+type ColumnSpec map[string]ColumnKind
+
+// Column holds the values of one field, one per row, in the slice of its
+// Kind. The other slices are nil.
+type Column struct {
+	Kind    ColumnKind
+	Int64   []int64
+	Float64 []float64
+	String  []string
+	Bool    []bool
+	Time    []time.Time
+	// Present has a bit set for each row that has the field, laid out as
+	// in WriteBoolBitset: the bit of row i is in byte i/8 at position i%8.
+	Present []byte
+}
+
+// Has reports whether row `row` has the field. The value of a row
+// without it is the zero value.
+func (c *Column) Has(row int) bool {
+	return c.Present[row/8]&(1<<(row%8)) != 0
+}
+
+// Columns are the columns ReadColumns reads.
+type Columns struct {
+	// Rows is the number of rows read, and the length of every column.
+	Rows    int
+	columns map[string]*Column
+}
+
+// Column returns the column of `field`, or nil when it is not in the
+// ColumnSpec.
+func (c Columns) Column(field string) *Column {
+	return c.columns[field]
+}
+
+// ColumnError reports the row and field that ReadColumns failed on. Field
+// is empty when the row itself could not be read.
+type ColumnError struct {
+	Row   int
+	Field string
+	Err   error
+}
+
+func (e ColumnError) Error() string {
+	message := "msgpack: row " + strconv.Itoa(e.Row)
+	if e.Field != "" {
+		message += " field " + strconv.Quote(e.Field)
+	}
+	return message + ": " + e.Err.Error()
+}
+
+func (e ColumnError) Unwrap() error {
+	return e.Err
+}
+
+// ReadColumns reads an array of maps, such as the rows of a table, into a
+// column for each field of `spec`, in one pass and without decoding the
+// rows on their own. The fields of each row are looked up by their key
+// bytes, and those not in `spec` are skipped. A row without a field, or
+// where it is nil, gets the zero value and its presence bit is left unset.
+// A value of another type is a ColumnError naming the row and field.
+//
+// A nil value reads as no rows. Strings alias the input buffer, as those
+// ReadString returns do. ReadColumns panics when `spec` has a kind that
+// is not one of the ColumnKind constants.
+func ReadColumns(r Reader, spec ColumnSpec) (Columns, error) {
+	columns := Columns{columns: make(map[string]*Column, len(spec))}
+	// The columns are also kept in a slice, which is quicker to go through
+	// for each row than the map.
+	list := make([]*Column, 0, len(spec))
+	for field, kind := range spec {
+		if kind < ColumnInt64 || kind > ColumnTime {
+			panic("msgpack: column " + strconv.Quote(field) + " has unknown kind " + strconv.Itoa(int(kind)))
+		}
+		c := &Column{Kind: kind}
+		columns.columns[field] = c
+		list = append(list, c)
+	}
+
+	d, ok := r.(*Decoder)
+	if !ok {
+		raw, err := r.ReadRaw()
+		if err != nil {
+			return Columns{}, err
+		}
+		decoder := NewDecoder(raw)
+		d = &decoder
+	}
+	isNil, err := readNil(d)
+	if isNil || err != nil {
+		return columns, err
+	}
+	rows, err := d.ReadArraySize()
+	if err != nil {
+		return Columns{}, err
+	}
+	// Every row takes at least a byte, which bounds what a bad size can
+	// make us allocate.
+	capacity := int(rows)
+	if remaining := d.reader.Remaining(); uint32(capacity) > remaining {
+		capacity = int(remaining)
+	}
+	for _, c := range list {
+		c.grow(capacity)
+	}
+
+	for row := 0; row < int(rows); row++ {
+		size, err := d.ReadMapSize()
+		if err != nil {
+			return Columns{}, ColumnError{Row: row, Err: err}
+		}
+		for _, c := range list {
+			c.appendZero(row)
+		}
+		for i := uint32(0); i < size; i++ {
+			field, err := readStringKey(d)
+			if err != nil {
+				return Columns{}, ColumnError{Row: row, Err: err}
+			}
+			c := columns.columns[field]
+			if c == nil {
+				err = d.Skip()
+			} else {
+				err = c.read(d, row)
+			}
+			if err != nil {
+				return Columns{}, ColumnError{Row: row, Field: field, Err: err}
+			}
+		}
+	}
+	columns.Rows = int(rows)
+	return columns, nil
+}
+
+// grow makes room for `n` rows.
+func (c *Column) grow(n int) {
+	switch c.Kind {
+	case ColumnInt64:
+		c.Int64 = make([]int64, 0, n)
+	case ColumnFloat64:
+		c.Float64 = make([]float64, 0, n)
+	case ColumnString:
+		c.String = make([]string, 0, n)
+	case ColumnBool:
+		c.Bool = make([]bool, 0, n)
+	case ColumnTime:
+		c.Time = make([]time.Time, 0, n)
+	}
+	c.Present = make([]byte, 0, (n+7)/8)
+}
+
+// appendZero adds row `row`, which is the length of the column, with the
+// zero value and without its presence bit.
+func (c *Column) appendZero(row int) {
+	switch c.Kind {
+	case ColumnInt64:
+		c.Int64 = append(c.Int64, 0)
+	case ColumnFloat64:
+		c.Float64 = append(c.Float64, 0)
+	case ColumnString:
+		c.String = append(c.String, "")
+	case ColumnBool:
+		c.Bool = append(c.Bool, false)
+	case ColumnTime:
+		c.Time = append(c.Time, time.Time{})
+	}
+	if row%8 == 0 {
+		c.Present = append(c.Present, 0)
+	}
+}
+
+// read reads the value of row `row`, leaving the row absent when it is
+// nil.
+func (c *Column) read(d *Decoder, row int) error {
+	isNil, err := readNil(d)
+	if isNil || err != nil {
+		return err
+	}
+	switch c.Kind {
+	case ColumnInt64:
+		c.Int64[row], err = d.ReadInt64()
+	case ColumnFloat64:
+		c.Float64[row], err = d.ReadFloat64()
+	case ColumnString:
+		c.String[row], err = d.ReadString()
+	case ColumnBool:
+		c.Bool[row], err = d.ReadBool()
+	case ColumnTime:
+		c.Time[row], err = d.ReadTime()
+	}
+	if err != nil {
+		return err
+	}
+	c.Present[row/8] |= 1 << (row % 8)
+	return nil
+}
@@ -0,0 +1,234 @@
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+var metricSpec = msgpack.ColumnSpec{
+	"ts":  msgpack.ColumnTime,
+	"val": msgpack.ColumnFloat64,
+	"n":   msgpack.ColumnInt64,
+	"tag": msgpack.ColumnString,
+	"ok":  msgpack.ColumnBool,
+}
+
+func TestReadColumns(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rows := []any{
+		map[string]any{"ts": ts, "val": 1.5, "n": int64(1), "tag": "a", "ok": true},
+		// Ragged: fields are missing, nil or in another order.
+		map[string]any{"tag": "b", "val": nil},
+		// Extra fields of any shape are skipped.
+		map[string]any{"n": int64(-3), "extra": map[string]any{"deep": []any{int64(1), "x"}}, "ok": false, "more": "y"},
+		map[string]any{},
+	}
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteAny(rows) })
+
+	readers := map[string]func() msgpack.Reader{
+		"Decoder": func() msgpack.Reader {
+			d := msgpack.NewDecoder(data)
+			return &d
+		},
+		"ReaderAdapter": func() msgpack.Reader {
+			d := msgpack.NewDecoder(data)
+			return msgpack.ReaderAdapter{ReaderCore: coreReader{&d}}
+		},
+	}
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			columns, err := msgpack.ReadColumns(newReader(), metricSpec)
+			require.NoError(t, err)
+			assert.Equal(t, 4, columns.Rows)
+			assert.Nil(t, columns.Column("extra"))
+
+			tsColumn := columns.Column("ts")
+			assert.Equal(t, msgpack.ColumnTime, tsColumn.Kind)
+			require.Len(t, tsColumn.Time, 4)
+			assert.True(t, ts.Equal(tsColumn.Time[0]))
+			assert.True(t, tsColumn.Time[1].IsZero())
+			assert.Equal(t, []float64{1.5, 0, 0, 0}, columns.Column("val").Float64)
+			assert.Equal(t, []int64{1, 0, -3, 0}, columns.Column("n").Int64)
+			assert.Equal(t, []string{"a", "b", "", ""}, columns.Column("tag").String)
+			assert.Equal(t, []bool{true, false, false, false}, columns.Column("ok").Bool)
+			assert.Nil(t, columns.Column("ok").Int64)
+
+			for field, present := range map[string][]bool{
+				"ts":  {true, false, false, false},
+				"val": {true, false, false, false},
+				"n":   {true, false, true, false},
+				"tag": {true, true, false, false},
+				"ok":  {true, false, true, false},
+			} {
+				c := columns.Column(field)
+				assert.Len(t, c.Present, 1, field)
+				for row, expected := range present {
+					assert.Equal(t, expected, c.Has(row), "%s row %d", field, row)
+				}
+			}
+		})
+	}
+}
+
+func TestReadColumnsPresenceBitmap(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(10)
+		for i := 0; i < 10; i++ {
+			if i%3 == 0 {
+				w.WriteMapSize(1)
+				w.WriteString("n")
+				w.WriteInt64(int64(i))
+			} else {
+				w.WriteMapSize(0)
+			}
+		}
+	})
+	decoder := msgpack.NewDecoder(data)
+	columns, err := msgpack.ReadColumns(&decoder, msgpack.ColumnSpec{"n": msgpack.ColumnInt64})
+	require.NoError(t, err)
+	n := columns.Column("n")
+	assert.Equal(t, []int64{0, 0, 0, 3, 0, 0, 6, 0, 0, 9}, n.Int64)
+	assert.Equal(t, []byte{0b01001001, 0b10}, n.Present)
+}
+
+func TestReadColumnsEmpty(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty array": {0x90},
+		"nil":         {msgpack.FormatNil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			decoder := msgpack.NewDecoder(data)
+			columns, err := msgpack.ReadColumns(&decoder, metricSpec)
+			require.NoError(t, err)
+			assert.Equal(t, 0, columns.Rows)
+			assert.Empty(t, columns.Column("val").Float64)
+			assert.Empty(t, columns.Column("val").Present)
+			assert.Equal(t, uint32(1), decoder.Offset())
+		})
+	}
+}
+
+func TestReadColumnsErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		write   func(w msgpack.Writer)
+		message string
+	}{
+		"wrong type": {
+			write: func(w msgpack.Writer) {
+				w.WriteAny([]any{
+					map[string]any{"val": 1.5},
+					map[string]any{"val": "high"},
+				})
+			},
+			message: `msgpack: row 1 field "val": bad prefix for float64: got fixstr(4) (0xa4) at offset 20`,
+		},
+		"row not a map": {
+			write: func(w msgpack.Writer) {
+				w.WriteArraySize(1)
+				w.WriteArraySize(0)
+			},
+			message: "msgpack: row 0: bad prefix for map length: got fixarray(0) (0x90) at offset 1",
+		},
+		"key not a string": {
+			write: func(w msgpack.Writer) {
+				w.WriteArraySize(1)
+				w.WriteMapSize(1)
+				w.WriteInt64(7)
+				w.WriteInt64(7)
+			},
+			message: "msgpack: row 0: msgpack: map key is not a string, found format 0x7 at offset 2",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			decoder := msgpack.NewDecoder(encodeWith(t, tc.write))
+			_, err := msgpack.ReadColumns(&decoder, metricSpec)
+			var columnErr msgpack.ColumnError
+			require.ErrorAs(t, err, &columnErr)
+			assert.EqualError(t, err, tc.message)
+		})
+	}
+
+	// A size larger than the input fails without allocating for it.
+	decoder := msgpack.NewDecoder([]byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0x80})
+	_, err := msgpack.ReadColumns(&decoder, metricSpec)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+
+	assert.PanicsWithValue(t, `msgpack: column "x" has unknown kind 0`, func() {
+		decoder := msgpack.NewDecoder([]byte{0x90})
+		msgpack.ReadColumns(&decoder, msgpack.ColumnSpec{"x": 0})
+	})
+}
+
+func metricRows(n int) []byte {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).UnixNano()
+	write := func(w msgpack.Writer) {
+		w.WriteArraySize(uint32(n))
+		for i := 0; i < n; i++ {
+			w.WriteMapSize(4)
+			w.WriteString("ts")
+			w.WriteInt64(ts + int64(i)*int64(time.Second))
+			w.WriteString("val")
+			w.WriteFloat64(float64(i) / 4)
+			w.WriteString("tag")
+			w.WriteString("sensor")
+			w.WriteString("host")
+			w.WriteString("a")
+		}
+	}
+	var sizer msgpack.Sizer
+	write(&sizer)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	write(&encoder)
+	return encoder.Bytes()
+}
+
+// BenchmarkReadColumns compares ReadColumns with reading the rows as maps
+// and pivoting them afterwards.
+func BenchmarkReadColumns(b *testing.B) {
+	data := metricRows(50000)
+	spec := msgpack.ColumnSpec{
+		"ts":  msgpack.ColumnInt64,
+		"val": msgpack.ColumnFloat64,
+		"tag": msgpack.ColumnString,
+	}
+	b.Run("columns", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			decoder := msgpack.NewDecoder(data)
+			if _, err := msgpack.ReadColumns(&decoder, spec); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("rows-then-pivot", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			decoder := msgpack.NewDecoder(data)
+			size, err := decoder.ReadArraySize()
+			if err != nil {
+				b.Fatal(err)
+			}
+			rows := make([]map[string]any, size)
+			for j := range rows {
+				if rows[j], err = msgpack.ReadStringAnyMap(&decoder); err != nil {
+					b.Fatal(err)
+				}
+			}
+			ts := make([]int64, len(rows))
+			val := make([]float64, len(rows))
+			tag := make([]string, len(rows))
+			for j, row := range rows {
+				ts[j], _ = row["ts"].(int64)
+				val[j], _ = row["val"].(float64)
+				tag[j], _ = row["tag"].(string)
+			}
+		}
+	})
+}
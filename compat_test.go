@@ -0,0 +1,175 @@
+package msgpack_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// compatMessages produced the frozen corpus in testdata/compat/v0.1 when
+// run against the v0.1 encoder. Never change a message here without
+// regenerating its file from that encoder; changing the encoder so that
+// these tests fail is a wire format change.
+var compatMessages = []struct {
+	name  string
+	write func(w msgpack.Writer)
+}{
+	{"scalars", func(w msgpack.Writer) {
+		w.WriteArraySize(26)
+		w.WriteNil()
+		w.WriteBool(true)
+		w.WriteBool(false)
+		w.WriteInt8(-1)
+		w.WriteInt8(-32)
+		w.WriteInt8(-33)
+		w.WriteInt8(127)
+		w.WriteInt8(-128)
+		w.WriteInt16(-129)
+		w.WriteInt16(32767)
+		w.WriteInt32(-32769)
+		w.WriteInt32(2147483647)
+		w.WriteInt64(-2147483649)
+		w.WriteInt64(math.MaxInt64)
+		w.WriteUint8(0)
+		w.WriteUint8(127)
+		w.WriteUint8(128)
+		w.WriteUint8(255)
+		w.WriteUint16(256)
+		w.WriteUint16(65535)
+		w.WriteUint32(65536)
+		w.WriteUint32(math.MaxUint32)
+		w.WriteUint64(math.MaxUint32 + 1)
+		w.WriteUint64(math.MaxUint64)
+		w.WriteFloat32(1.5)
+		w.WriteFloat64(-2.25)
+	}},
+	{"strings", func(w msgpack.Writer) {
+		w.WriteArraySize(10)
+		w.WriteString("")
+		w.WriteString("a")
+		w.WriteString(strings.Repeat("x", 31))
+		w.WriteString(strings.Repeat("x", 32))
+		w.WriteString(strings.Repeat("x", 255))
+		w.WriteString(strings.Repeat("x", 256))
+		w.WriteString(strings.Repeat("x", 65536))
+		w.WriteByteArray([]byte{})
+		w.WriteByteArray([]byte{1})
+		w.WriteByteArray(make([]byte, 256))
+	}},
+	{"times", func(w msgpack.Writer) {
+		w.WriteArraySize(3)
+		w.WriteTime(time.Unix(0, 0))
+		w.WriteTime(time.Unix(1, 5))
+		w.WriteTime(time.Unix(1<<34, 1))
+	}},
+	{"record", func(w msgpack.Writer) {
+		id := uint64(4096)
+		var missing *string
+		w.WriteMapSize(6)
+		w.WriteString("id")
+		w.WriteNillableUint64(&id)
+		w.WriteString("label")
+		w.WriteNillableString(missing)
+		w.WriteString("tags")
+		w.WriteAny([]string{"a", "b"})
+		w.WriteString("meta")
+		w.WriteAny(map[string]string{"k": "v"})
+		w.WriteString("samples")
+		w.WriteAny([]int64{-1, 0, 300})
+		w.WriteString("payload")
+		w.WriteNillableByteArray(nil)
+	}},
+	{"containers", func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteArraySize(16)
+		for i := 0; i < 16; i++ {
+			w.WriteInt32(int32(i))
+		}
+		w.WriteMapSize(16)
+		for i := 0; i < 16; i++ {
+			w.WriteUint16(uint16(i))
+			w.WriteBool(i%2 == 0)
+		}
+	}},
+}
+
+func readCompatCorpus(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "compat", "v0.1", name+".msgpack"))
+	require.NoError(t, err)
+	return data
+}
+
+func TestCompatV01Decode(t *testing.T) {
+	for _, m := range compatMessages {
+		t.Run(m.name, func(t *testing.T) {
+			data := readCompatCorpus(t, m.name)
+			require.NoError(t, msgpack.Validate(data))
+			decoder := msgpack.NewDecoder(data)
+			require.NoError(t, decoder.Skip())
+			assert.Equal(t, uint32(len(data)), decoder.Offset())
+		})
+	}
+
+	decoder := msgpack.NewDecoder(readCompatCorpus(t, "scalars"))
+	size, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(26), size)
+	require.NoError(t, decoder.Skip())
+	b, err := decoder.ReadBool()
+	require.NoError(t, err)
+	assert.True(t, b)
+
+	decoder = msgpack.NewDecoder(readCompatCorpus(t, "times"))
+	_, err = decoder.ReadArraySize()
+	require.NoError(t, err)
+	for _, expected := range []time.Time{time.Unix(0, 0).UTC(), time.Unix(1, 5), time.Unix(1<<34, 1)} {
+		actual, err := decoder.ReadTime()
+		require.NoError(t, err)
+		assert.True(t, expected.Equal(actual), "%v != %v", expected, actual)
+	}
+
+	decoder = msgpack.NewDecoder(readCompatCorpus(t, "record"))
+	v, err := msgpack.ReadStringAnyMap(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"id":      uint16(4096),
+		"label":   nil,
+		"tags":    []any{"a", "b"},
+		"meta":    map[any]any{"k": "v"},
+		"samples": []any{int64(-1), int64(0), int16(300)},
+		"payload": nil,
+	}, v)
+}
+
+func TestCompatV01Encode(t *testing.T) {
+	for _, m := range compatMessages {
+		t.Run(m.name, func(t *testing.T) {
+			expected := readCompatCorpus(t, m.name)
+			var sizer msgpack.Sizer
+			m.write(&sizer)
+			require.Equal(t, uint32(len(expected)), sizer.Len())
+			encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), msgpack.CompatV01())
+			m.write(&encoder)
+			require.NoError(t, encoder.Err())
+			assert.Equal(t, expected, encoder.Bytes())
+		})
+	}
+}
+
+// v0.1 decoders read array headers as string headers and treat the
+// following bytes as the string.
+func TestCompatV01ArrayAsString(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{0x92, 'h', 'i'})
+	s, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "hi", s)
+}
@@ -0,0 +1,149 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// CompressedExtType is the ext type used by WriteCompressedByteArray.
+const CompressedExtType int8 = 100
+
+// CompressionCodec compresses byte arrays written by
+// WriteCompressedByteArray. Implementations live outside this package; see
+// the flatecodec package for one based on compress/flate.
+type CompressionCodec interface {
+	// Compress returns the compressed form of `src`.
+	Compress(src []byte) ([]byte, error)
+	// Decompress returns the `originalLen` bytes that `src` decompresses to.
+	Decompress(src []byte, originalLen int) ([]byte, error)
+	// MaxCompressedLen returns the largest size Compress can produce for
+	// an input of `srcLen` bytes.
+	MaxCompressedLen(srcLen int) int
+}
+
+// WriteCompressedByteArray writes `data` compressed with `codec`, leaving the
+// rest of the message uncompressed. The value is an ext of type
+// CompressedExtType holding the uncompressed length as a 4 byte big-endian
+// integer followed by the compressed bytes. When compression does not make
+// `data` smaller it is written as a plain bin instead, which
+// ReadCompressedByteArray also accepts.
+//
+// Sizers do not compress: a Sizer or UpperBoundSizer counts len(data)+9
+// bytes, the most either form can take, so the encoder may write fewer
+// bytes than sized. Use Encoder.Bytes for the encoded message.
+func WriteCompressedByteArray(w Writer, data []byte, codec CompressionCodec) error {
+	if data == nil {
+		w.WriteNil()
+		return nil
+	}
+	switch s := w.(type) {
+	case *Sizer:
+		s.length += compressedUpperBound(len(data))
+		return nil
+	case *UpperBoundSizer:
+		s.length += compressedUpperBound(len(data))
+		return nil
+	}
+
+	compressed, err := codec.Compress(data)
+	if err != nil {
+		return err
+	}
+	if len(compressed) > codec.MaxCompressedLen(len(data)) {
+		return WriteError{message: "msgpack: codec produced more than MaxCompressedLen bytes"}
+	}
+	if len(compressed)+4 >= len(data) {
+		w.WriteByteArray(data)
+		return w.Err()
+	}
+
+	var header [10]byte
+	b := appendExtHeader(header[:0], CompressedExtType, uint32(4+len(compressed)))
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(data)))
+	w.WriteRawBytes(b)
+	w.WriteRawBytes(compressed)
+	return w.Err()
+}
+
+// ReadCompressedByteArray reads a byte array written by
+// WriteCompressedByteArray, decompressing it with `codec` when it was
+// stored compressed. A plain bin is returned as is and nil returns nil.
+func ReadCompressedByteArray(r Reader, codec CompressionCodec) ([]byte, error) {
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	d := NewDecoder(raw)
+	prefix := raw[0]
+	switch prefix {
+	case FormatNil:
+		return nil, nil
+	case FormatBin8, FormatBin16, FormatBin32:
+		return d.ReadByteArray()
+	}
+
+	d.reader.Discard(1)
+	extType, extLen, err := d.extHeader(prefix)
+	if err != nil {
+		return nil, ReadError{"msgpack: expected compressed byte array, found " + formatKind(prefix)}
+	}
+	if extType != CompressedExtType {
+		return nil, ReadError{"msgpack: expected compressed byte array, found ext type " + strconv.Itoa(int(extType))}
+	}
+	if extLen < 4 {
+		return nil, ReadError{"msgpack: compressed byte array too short"}
+	}
+	originalLen, err := d.reader.GetUint32()
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := d.reader.GetBytes(extLen - 4)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(compressed)) > uint64(codec.MaxCompressedLen(int(originalLen))) {
+		return nil, ReadError{"msgpack: compressed byte array larger than its codec allows for " +
+			strconv.FormatUint(uint64(originalLen), 10) + " bytes"}
+	}
+	data, err := codec.Decompress(compressed, int(originalLen))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) != uint64(originalLen) {
+		return nil, ReadError{"msgpack: compressed byte array decompressed to " + strconv.Itoa(len(data)) +
+			" bytes, expected " + strconv.FormatUint(uint64(originalLen), 10)}
+	}
+	return data, nil
+}
+
+// compressedUpperBound is the most WriteCompressedByteArray writes for
+// `n` bytes: either a bin of at most 5+n bytes, or an ext32 header, the
+// length and fewer than n-4 compressed bytes.
+func compressedUpperBound(n int) uint32 {
+	return uint32(n) + 9
+}
+
+func appendExtHeader(b []byte, extType int8, length uint32) []byte {
+	switch length {
+	case 1:
+		b = append(b, FormatFixExt1)
+	case 2:
+		b = append(b, FormatFixExt2)
+	case 4:
+		b = append(b, FormatFixExt4)
+	case 8:
+		b = append(b, FormatFixExt8)
+	case 16:
+		b = append(b, FormatFixExt16)
+	default:
+		if length <= 0xff {
+			b = append(b, FormatExt8, byte(length))
+		} else if length <= 0xffff {
+			b = append(b, FormatExt16, byte(length>>8), byte(length))
+		} else {
+			b = append(b, FormatExt32, byte(length>>24), byte(length>>16), byte(length>>8), byte(length))
+		}
+	}
+	return append(b, byte(extType))
+}
@@ -0,0 +1,102 @@
+//go:build !tinygo
+// +build !tinygo
+
+package msgpack_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/flatecodec"
+)
+
+func encodeCompressed(t *testing.T, data []byte) ([]byte, msgpack.Sizer) {
+	write := func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteString("payload")
+		require.NoError(t, msgpack.WriteCompressedByteArray(w, data, flatecodec.Default))
+	}
+	var sizer msgpack.Sizer
+	write(&sizer)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	write(&encoder)
+	require.NoError(t, encoder.Err())
+	return encoder.Bytes(), sizer
+}
+
+func readCompressed(t *testing.T, encoded []byte) []byte {
+	decoder := msgpack.NewDecoder(encoded)
+	_, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+	_, err = decoder.ReadString()
+	require.NoError(t, err)
+	data, err := msgpack.ReadCompressedByteArray(&decoder, flatecodec.Default)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+	return data
+}
+
+func TestCompressedByteArrayRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("log line: request served in 12ms\n"), 200)
+	encoded, sizer := encodeCompressed(t, data)
+	assert.Less(t, len(encoded), len(data)/4)
+	// The sizer does not compress, so it counts the upper bound.
+	assert.Equal(t, uint32(1+8+len(data)+9), sizer.Len())
+	assert.Equal(t, data, readCompressed(t, encoded))
+}
+
+func TestCompressedByteArrayIncompressible(t *testing.T) {
+	data := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(data)
+	encoded, sizer := encodeCompressed(t, data)
+	// Written as a plain bin16.
+	assert.Equal(t, 1+8+3+len(data), len(encoded))
+	assert.LessOrEqual(t, uint32(len(encoded)), sizer.Len())
+	assert.Equal(t, data, readCompressed(t, encoded))
+
+	for _, small := range [][]byte{{}, {1}, []byte("abc")} {
+		encoded, _ := encodeCompressed(t, small)
+		assert.Equal(t, small, readCompressed(t, encoded))
+	}
+}
+
+func TestCompressedByteArrayNil(t *testing.T) {
+	encoded, sizer := encodeCompressed(t, nil)
+	assert.Equal(t, uint32(len(encoded)), sizer.Len())
+	assert.Nil(t, readCompressed(t, encoded))
+}
+
+func TestCompressedByteArrayUpperBoundSizer(t *testing.T) {
+	data := bytes.Repeat([]byte{0}, 1000)
+	var sizer msgpack.UpperBoundSizer
+	require.NoError(t, msgpack.WriteCompressedByteArray(&sizer, data, flatecodec.Default))
+	assert.Equal(t, uint32(len(data)+9), sizer.Len())
+}
+
+func TestReadCompressedByteArrayErrors(t *testing.T) {
+	data := bytes.Repeat([]byte("abcd"), 100)
+	encoded, _ := encodeCompressed(t, data)
+
+	// A length that does not match what the payload decompresses to.
+	bad := append([]byte(nil), encoded...)
+	lengthAt := 1 + 8 + 3
+	bad[lengthAt+3]++
+	decoder := msgpack.NewDecoder(bad)
+	decoder.ReadArraySize()
+	decoder.ReadString()
+	_, err := msgpack.ReadCompressedByteArray(&decoder, flatecodec.Default)
+	assert.Error(t, err)
+
+	decoder = msgpack.NewDecoder([]byte{msgpack.FormatFixExt1, 7, 0})
+	_, err = msgpack.ReadCompressedByteArray(&decoder, flatecodec.Default)
+	assert.EqualError(t, err, "msgpack: expected compressed byte array, found ext type 7")
+
+	decoder = msgpack.NewDecoder([]byte{0xa1, 'a'})
+	_, err = msgpack.ReadCompressedByteArray(&decoder, flatecodec.Default)
+	assert.EqualError(t, err, "msgpack: expected compressed byte array, found string")
+}
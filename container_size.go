@@ -0,0 +1,62 @@
+package msgpack
+
+import (
+	"math"
+	"strconv"
+)
+
+// ContainerSizeError is recorded by Encoder.WriteArraySize and
+// Encoder.WriteMapSize when the declared number of elements cannot fit in
+// what is left of the buffer, even at one byte per array element or two
+// per map entry. The message could never be completed, so the error is
+// reported at the header instead of at the element that runs out of room.
+// It matches ErrRange with errors.Is.
+type ContainerSizeError struct {
+	// Kind is "array" or "map".
+	Kind string
+	// Length is the declared number of elements or entries.
+	Length uint32
+	// Remaining is the number of bytes left in the buffer after the header.
+	Remaining uint32
+}
+
+func (e ContainerSizeError) Error() string {
+	return "msgpack: " + e.Kind + " of " + strconv.FormatUint(uint64(e.Length), 10) +
+		" elements cannot fit in " + strconv.FormatUint(uint64(e.Remaining), 10) + " remaining bytes"
+}
+
+func (e ContainerSizeError) Unwrap() error {
+	return ErrRange
+}
+
+// WithContainerLenCheck makes WriteArraySize and WriteMapSize fail at the
+// header when the declared length can never be completed. An Encoder
+// records a ContainerSizeError when the length cannot fit in the rest of
+// its buffer. A Sizer has no buffer to compare against, so it, like the
+// Encoder, records a WriteError for lengths above `max`, or for lengths
+// that would take the message past 4 GiB when `max` is 0.
+func WithContainerLenCheck(max uint32) EncOption {
+	return func(o *encOptions) {
+		o.containerLenCheck = true
+		o.maxContainerLen = max
+	}
+}
+
+// checkContainerLen returns the error for a container of `length` elements
+// of at least `minSize` bytes each, given `written` bytes so far and
+// `remaining` bytes of capacity, or nil if it may fit.
+func (o *encOptions) checkContainerLen(kind string, length, minSize uint32, written uint32, remaining uint64) error {
+	if o.maxContainerLen != 0 && length > o.maxContainerLen {
+		return WriteError{message: "msgpack: " + kind + " of " + strconv.FormatUint(uint64(length), 10) +
+			" elements exceeds the limit of " + strconv.FormatUint(uint64(o.maxContainerLen), 10)}
+	}
+	needed := uint64(length) * uint64(minSize)
+	if needed > math.MaxUint32-uint64(written) {
+		return WriteError{message: "msgpack: " + kind + " of " + strconv.FormatUint(uint64(length), 10) +
+			" elements exceeds the maximum message size"}
+	}
+	if needed > remaining {
+		return ContainerSizeError{Kind: kind, Length: length, Remaining: uint32(remaining)}
+	}
+	return nil
+}
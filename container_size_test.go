@@ -0,0 +1,70 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestContainerLenCheckEncoder(t *testing.T) {
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, 16), msgpack.WithContainerLenCheck(0))
+	encoder.WriteArraySize(100000)
+	err := encoder.Err()
+	assert.EqualError(t, err, "msgpack: array of 100000 elements cannot fit in 11 remaining bytes")
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	var sizeErr msgpack.ContainerSizeError
+	require.ErrorAs(t, err, &sizeErr)
+	assert.Equal(t, msgpack.ContainerSizeError{Kind: "array", Length: 100000, Remaining: 11}, sizeErr)
+
+	// A map needs at least two bytes per entry.
+	encoder = msgpack.NewEncoderWithOptions(make([]byte, 4), msgpack.WithContainerLenCheck(0))
+	encoder.WriteMapSize(2)
+	assert.EqualError(t, encoder.Err(), "msgpack: map of 2 elements cannot fit in 3 remaining bytes")
+
+	// Containers that may still fit are accepted.
+	encoder = msgpack.NewEncoderWithOptions(make([]byte, 9), msgpack.WithContainerLenCheck(0))
+	encoder.WriteMapSize(4)
+	encoder.WriteArraySize(0)
+	require.NoError(t, encoder.Err())
+
+	// A rejected container writes no header.
+	encoder = msgpack.NewEncoderWithOptions(make([]byte, 16), msgpack.WithContainerLenCheck(0))
+	encoder.WriteString("k")
+	encoder.WriteMapSize(100)
+	require.Error(t, encoder.Err())
+	assert.Equal(t, []byte{0xa1, 'k'}, encoder.Bytes())
+	sizer := msgpack.NewSizerWithOptions(msgpack.WithContainerLenCheck(10))
+	sizer.WriteString("k")
+	sizer.WriteArraySize(100)
+	require.Error(t, sizer.Err())
+	assert.Equal(t, uint32(2), sizer.Len())
+
+	// Without the option the failure comes later, as a range error.
+	encoder = msgpack.NewEncoder(make([]byte, 16))
+	encoder.WriteArraySize(100000)
+	require.NoError(t, encoder.Err())
+}
+
+func TestContainerLenCheckLimit(t *testing.T) {
+	sizer := msgpack.NewSizerWithOptions(msgpack.WithContainerLenCheck(1000))
+	sizer.WriteArraySize(1000)
+	require.NoError(t, sizer.Err())
+	sizer.WriteMapSize(1001)
+	assert.EqualError(t, sizer.Err(), "msgpack: map of 1001 elements exceeds the limit of 1000")
+
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, 4096), msgpack.WithContainerLenCheck(1000))
+	encoder.WriteArraySize(1001)
+	assert.EqualError(t, encoder.Err(), "msgpack: array of 1001 elements exceeds the limit of 1000")
+
+	sizer = msgpack.NewSizerWithOptions(msgpack.WithContainerLenCheck(0))
+	sizer.WriteArraySize(math.MaxUint32)
+	assert.EqualError(t, sizer.Err(), "msgpack: array of 4294967295 elements exceeds the maximum message size")
+
+	var plain msgpack.Sizer
+	plain.WriteArraySize(math.MaxUint32)
+	assert.NoError(t, plain.Err())
+}
@@ -0,0 +1,294 @@
+package msgpack
+
+import (
+	"hash"
+	"time"
+)
+
+// contentHashChunk is the size of the buffer ContentHash encodes into
+// before passing the bytes to the hash.
+const contentHashChunk = 4 << 10
+
+// ContentHash returns the hash with `h` of the encoding of `c` with `opts`,
+// the same as hashing the bytes of ToBytes with those options, without
+// holding the whole encoding in memory. Use WithSortedStringMaps and
+// WithSortedIntMaps so that values holding Go maps always hash the same.
+//
+// The value is encoded into a small buffer that is passed to the hash
+// whenever it fills. A single value that does not fit, such as a large
+// string or a map written with WriteAny, which has to be sorted whole,
+// grows the buffer to its size. So does a container written with a
+// reserved header, until the header is patched. Memory therefore grows
+// with the largest such value rather than with the message. With
+// WithContainerLenCheck, the buffer also grows to the smallest size of
+// each array and map, which the check compares with it.
+func ContentHash(c Codec, h hash.Hash, opts ...EncOption) ([]byte, error) {
+	w := hashWriter{h: h, enc: NewEncoderWithOptions(make([]byte, contentHashChunk), opts...)}
+	if err := c.Encode(&w); err != nil {
+		return nil, err
+	}
+	if err := w.Err(); err != nil {
+		return nil, err
+	}
+	w.flush()
+	return h.Sum(nil), nil
+}
+
+// hashWriter is the Writer of ContentHash. It encodes with an Encoder and
+// passes the bytes written to the hash once the Encoder runs out of room.
+type hashWriter struct {
+	h   hash.Hash
+	enc Encoder
+	// open counts the reserved headers not yet patched, whose marks
+	// point into the buffer, so it cannot be flushed.
+	open int
+}
+
+var _ Writer = &hashWriter{}
+
+// write calls `fn` to write a value with the Encoder. If the value does not
+// fit, it is written again after flushing the buffer, or into a larger one.
+func (w *hashWriter) write(fn func()) {
+	mark := w.enc.Mark()
+	fn()
+	for mark.err == nil && w.full() {
+		w.enc.Rollback(mark)
+		if w.open == 0 && w.enc.Len() > 0 {
+			w.flush()
+		} else {
+			w.grow()
+		}
+		mark = w.enc.Mark()
+		fn()
+	}
+}
+
+// full reports whether the Encoder has run out of room.
+func (w *hashWriter) full() bool {
+	switch w.enc.reader.err.(type) {
+	case RangeError, ContainerSizeError:
+		return true
+	}
+	return false
+}
+
+// flush passes the bytes written to the hash and empties the buffer.
+func (w *hashWriter) flush() {
+	w.h.Write(w.enc.Bytes())
+	w.enc.reader.byteOffset = 0
+}
+
+// grow doubles the buffer, keeping the bytes written.
+func (w *hashWriter) grow() {
+	buffer := make([]byte, 2*len(w.enc.reader.buffer))
+	copy(buffer, w.enc.Bytes())
+	w.enc.reader.buffer = buffer
+}
+
+func (w *hashWriter) Err() error {
+	return w.enc.Err()
+}
+
+func (w *hashWriter) WriteNil() {
+	w.write(w.enc.WriteNil)
+}
+
+func (w *hashWriter) WriteBool(value bool) {
+	w.write(func() { w.enc.WriteBool(value) })
+}
+
+func (w *hashWriter) WriteInt8(value int8) {
+	w.write(func() { w.enc.WriteInt8(value) })
+}
+
+func (w *hashWriter) WriteInt16(value int16) {
+	w.write(func() { w.enc.WriteInt16(value) })
+}
+
+func (w *hashWriter) WriteInt32(value int32) {
+	w.write(func() { w.enc.WriteInt32(value) })
+}
+
+func (w *hashWriter) WriteInt64(value int64) {
+	w.write(func() { w.enc.WriteInt64(value) })
+}
+
+func (w *hashWriter) WriteUint8(value uint8) {
+	w.write(func() { w.enc.WriteUint8(value) })
+}
+
+func (w *hashWriter) WriteUint16(value uint16) {
+	w.write(func() { w.enc.WriteUint16(value) })
+}
+
+func (w *hashWriter) WriteUint32(value uint32) {
+	w.write(func() { w.enc.WriteUint32(value) })
+}
+
+func (w *hashWriter) WriteUint64(value uint64) {
+	w.write(func() { w.enc.WriteUint64(value) })
+}
+
+func (w *hashWriter) WriteFloat32(value float32) {
+	w.write(func() { w.enc.WriteFloat32(value) })
+}
+
+func (w *hashWriter) WriteFloat64(value float64) {
+	w.write(func() { w.enc.WriteFloat64(value) })
+}
+
+func (w *hashWriter) WriteString(value string) {
+	w.write(func() { w.enc.WriteString(value) })
+}
+
+func (w *hashWriter) WriteTime(value time.Time) {
+	w.write(func() { w.enc.WriteTime(value) })
+}
+
+func (w *hashWriter) WriteByteArray(value []byte) {
+	w.write(func() { w.enc.WriteByteArray(value) })
+}
+
+func (w *hashWriter) WriteArraySize(length uint32) {
+	w.write(func() { w.enc.WriteArraySize(length) })
+}
+
+func (w *hashWriter) WriteMapSize(length uint32) {
+	w.write(func() { w.enc.WriteMapSize(length) })
+}
+
+func (w *hashWriter) reserve(reserve func() HeaderMark) HeaderMark {
+	var mark HeaderMark
+	w.write(func() { mark = reserve() })
+	w.open++
+	return mark
+}
+
+func (w *hashWriter) patch(patch func()) {
+	patch()
+	if w.open > 0 {
+		w.open--
+	}
+}
+
+func (w *hashWriter) ReserveArraySize() HeaderMark {
+	return w.reserve(w.enc.ReserveArraySize)
+}
+
+func (w *hashWriter) PatchArraySize(mark HeaderMark, length uint32) {
+	w.patch(func() { w.enc.PatchArraySize(mark, length) })
+}
+
+func (w *hashWriter) ReserveMapSize() HeaderMark {
+	return w.reserve(w.enc.ReserveMapSize)
+}
+
+func (w *hashWriter) PatchMapSize(mark HeaderMark, length uint32) {
+	w.patch(func() { w.enc.PatchMapSize(mark, length) })
+}
+
+func (w *hashWriter) ReserveStringHeader() HeaderMark {
+	return w.reserve(w.enc.ReserveStringHeader)
+}
+
+func (w *hashWriter) PatchStringHeader(mark HeaderMark, length uint32) {
+	w.patch(func() { w.enc.PatchStringHeader(mark, length) })
+}
+
+func (w *hashWriter) ReserveBinHeader() HeaderMark {
+	return w.reserve(w.enc.ReserveBinHeader)
+}
+
+func (w *hashWriter) PatchBinHeader(mark HeaderMark, length uint32) {
+	w.patch(func() { w.enc.PatchBinHeader(mark, length) })
+}
+
+func (w *hashWriter) WriteRawBytes(value []byte) {
+	w.write(func() { w.enc.WriteRawBytes(value) })
+}
+
+func (w *hashWriter) WriteNillableBool(value *bool) {
+	w.write(func() { w.enc.WriteNillableBool(value) })
+}
+
+func (w *hashWriter) WriteNillableInt8(value *int8) {
+	w.write(func() { w.enc.WriteNillableInt8(value) })
+}
+
+func (w *hashWriter) WriteNillableInt16(value *int16) {
+	w.write(func() { w.enc.WriteNillableInt16(value) })
+}
+
+func (w *hashWriter) WriteNillableInt32(value *int32) {
+	w.write(func() { w.enc.WriteNillableInt32(value) })
+}
+
+func (w *hashWriter) WriteNillableInt64(value *int64) {
+	w.write(func() { w.enc.WriteNillableInt64(value) })
+}
+
+func (w *hashWriter) WriteNillableUint8(value *uint8) {
+	w.write(func() { w.enc.WriteNillableUint8(value) })
+}
+
+func (w *hashWriter) WriteNillableUint16(value *uint16) {
+	w.write(func() { w.enc.WriteNillableUint16(value) })
+}
+
+func (w *hashWriter) WriteNillableUint32(value *uint32) {
+	w.write(func() { w.enc.WriteNillableUint32(value) })
+}
+
+func (w *hashWriter) WriteNillableUint64(value *uint64) {
+	w.write(func() { w.enc.WriteNillableUint64(value) })
+}
+
+func (w *hashWriter) WriteNillableFloat32(value *float32) {
+	w.write(func() { w.enc.WriteNillableFloat32(value) })
+}
+
+func (w *hashWriter) WriteNillableFloat64(value *float64) {
+	w.write(func() { w.enc.WriteNillableFloat64(value) })
+}
+
+func (w *hashWriter) WriteNillableString(value *string) {
+	w.write(func() { w.enc.WriteNillableString(value) })
+}
+
+func (w *hashWriter) WriteNillableTime(value *time.Time) {
+	w.write(func() { w.enc.WriteNillableTime(value) })
+}
+
+func (w *hashWriter) WriteNillableByteArray(value []byte) {
+	w.write(func() { w.enc.WriteNillableByteArray(value) })
+}
+
+func (w *hashWriter) WriteNillableRaw(value Raw) {
+	w.write(func() { w.enc.WriteNillableRaw(value) })
+}
+
+func (w *hashWriter) WriteByteArrayVec(segments ...[]byte) {
+	w.write(func() { w.enc.WriteByteArrayVec(segments...) })
+}
+
+func (w *hashWriter) WriteStringVec(segments ...string) {
+	w.write(func() { w.enc.WriteStringVec(segments...) })
+}
+
+// WriteAny encodes a Codec with the hashWriter, as the Encoder does with
+// itself, so that it is not buffered whole.
+func (w *hashWriter) WriteAny(value any) {
+	if c, ok := value.(Codec); ok {
+		c.Encode(w)
+		return
+	}
+	w.write(func() { w.enc.WriteAny(value) })
+}
+
+func (w *hashWriter) WriteStringAnyMap(value map[string]any) {
+	w.write(func() { w.enc.WriteStringAnyMap(value) })
+}
+
+func (w *hashWriter) WriteRaw(value Raw) {
+	w.write(func() { w.enc.WriteRaw(value) })
+}
@@ -0,0 +1,152 @@
+package msgpack_test
+
+import (
+	"crypto/sha256"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/msgpacktest"
+)
+
+// writeCodec encodes by calling its function.
+type writeCodec func(w msgpack.Writer)
+
+func (c writeCodec) Encode(w msgpack.Writer) error {
+	c(w)
+	return w.Err()
+}
+
+func (c writeCodec) Decode(r msgpack.Reader) error {
+	return errors.New("writeCodec cannot decode")
+}
+
+var canonical = []msgpack.EncOption{msgpack.WithSortedStringMaps(), msgpack.WithSortedIntMaps()}
+
+func assertContentHash(t *testing.T, write func(w msgpack.Writer), opts ...msgpack.EncOption) {
+	t.Helper()
+	want := sha256.Sum256(encodeWithOptions(t, write, opts...))
+	got, err := msgpack.ContentHash(writeCodec(write), sha256.New(), opts...)
+	require.NoError(t, err)
+	assert.Equal(t, want[:], got)
+}
+
+func TestContentHashCorpus(t *testing.T) {
+	for _, msg := range compatMessages {
+		t.Run(msg.name, func(t *testing.T) {
+			assertContentHash(t, msg.write, canonical...)
+		})
+	}
+}
+
+// Generated documents are written a value at a time, as WriterAdapter
+// passes on a raw value, since maps with keys of type any are written in
+// Go's random map order.
+func TestContentHashProperty(t *testing.T) {
+	g := msgpacktest.NewGenerator(11, msgpacktest.WithTimes(), msgpacktest.WithMaxDepth(4),
+		msgpacktest.WithMaxContainerLen(40))
+	for i := 0; i < 200; i++ {
+		raw := g.NextRaw()
+		assertContentHash(t, func(w msgpack.Writer) {
+			adapter := msgpack.WriterAdapter{WriterCore: w}
+			adapter.WriteRaw(raw)
+		}, canonical...)
+	}
+}
+
+func TestContentHashWriters(t *testing.T) {
+	long := strings.Repeat("long value ", 2000)
+	email := "ada@example.com"
+	person := Person{Name: "Ada", Email: &email, Tags: []string{"x"}, Joined: time.Unix(1700000000, 0)}
+	write := func(w msgpack.Writer) {
+		w.WriteArraySize(8)
+		w.WriteString(long)
+		mark := w.ReserveArraySize()
+		for i := 0; i < 1000; i++ {
+			w.WriteString("element " + strconv.Itoa(i))
+		}
+		w.PatchArraySize(mark, 1000)
+		mark = w.ReserveStringHeader()
+		w.WriteRawBytes([]byte(long))
+		w.PatchStringHeader(mark, uint32(len(long)))
+		w.WriteAny(map[string]any{"b": long, "a": []any{int64(1), "two"}})
+		w.WriteAny(writeCodec(func(w msgpack.Writer) { w.WriteByteArray([]byte(long)) }))
+		require.NoError(t, personCodec.Encode(w, &person))
+		msgpack.WriteDuration(w, 1500*time.Millisecond)
+		w.WriteStringVec(long, long)
+	}
+	assertContentHash(t, write, canonical...)
+	assertContentHash(t, write, append(canonical, msgpack.WithStringTable(stringTableExt), msgpack.WithDurationAsString())...)
+	assertContentHash(t, write, append(canonical, msgpack.WithContainerLenCheck(0))...)
+}
+
+func TestContentHashErrors(t *testing.T) {
+	_, err := msgpack.ContentHash(recordsDoc{n: -1}, sha256.New())
+	assert.EqualError(t, err, "recordsDoc cannot encode")
+
+	_, err = msgpack.ContentHash(writeCodec(func(w msgpack.Writer) { w.WriteArraySize(2) }), sha256.New(),
+		msgpack.WithContainerLenCheck(1))
+	assert.EqualError(t, err, "msgpack: array of 2 elements exceeds the limit of 1")
+}
+
+// recordsDoc is a large document of many small maps, and one larger map
+// written with WriteAny.
+type recordsDoc struct {
+	n      int
+	labels map[string]any
+}
+
+func (d recordsDoc) Encode(w msgpack.Writer) error {
+	if d.n < 0 {
+		return errors.New("recordsDoc cannot encode")
+	}
+	w.WriteArraySize(uint32(d.n) + 1)
+	for i := 0; i < d.n; i++ {
+		w.WriteMapSize(3)
+		w.WriteString("id")
+		w.WriteInt64(int64(i))
+		w.WriteString("name")
+		w.WriteString("a record name of some length")
+		w.WriteString("score")
+		w.WriteFloat64(float64(i) / 7)
+	}
+	w.WriteAny(d.labels)
+	return w.Err()
+}
+
+func (d recordsDoc) Decode(r msgpack.Reader) error {
+	return errors.New("recordsDoc cannot decode")
+}
+
+// The memory ContentHash uses grows with the largest map written with
+// WriteAny, not with the message.
+func TestContentHashMemory(t *testing.T) {
+	for _, labels := range []int{0, 5000} {
+		doc := recordsDoc{n: 100000, labels: map[string]any{}}
+		for i := 0; i < labels; i++ {
+			doc.labels["label "+strconv.Itoa(i)] = "value " + strconv.Itoa(i)
+		}
+		full := encodeWithOptions(t, func(w msgpack.Writer) { require.NoError(t, doc.Encode(w)) }, canonical...)
+		want := sha256.Sum256(full)
+		labelsSize := len(encodeWithOptions(t, func(w msgpack.Writer) { w.WriteAny(doc.labels) }, canonical...))
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		got, err := msgpack.ContentHash(doc, sha256.New(), canonical...)
+		runtime.ReadMemStats(&after)
+		require.NoError(t, err)
+		assert.Equal(t, want[:], got)
+
+		allocated := after.TotalAlloc - before.TotalAlloc
+		assert.Less(t, allocated, uint64(64<<10+8*labelsSize),
+			"allocated %d bytes for a %d byte message with a %d byte map", allocated, len(full), labelsSize)
+	}
+}
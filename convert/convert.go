@@ -1,6 +1,8 @@
 package convert
 
 import (
+	"errors"
+	"strconv"
 	"time"
 )
 
@@ -99,6 +101,64 @@ func NillableNumeric[T, I numberic](value *I, err error) (*T, error) {
 	return &ret, err
 }
 
+// ErrOutOfRange is wrapped by the InputError NumericChecked returns for a
+// value that T cannot hold.
+var ErrOutOfRange = errors.New("value out of range")
+
+// NumericChecked is Numeric for conversions that must not lose the value:
+// one that does not survive a round trip through T, or changes sign on the
+// way, is an error instead of being truncated or wrapped. Converting a
+// float with a fraction to an integer type, or a float64 to a float32
+// that cannot represent it exactly, is an error too. NaN converts between
+// float types.
+func NumericChecked[T, I numberic](value I, err error) (T, error) {
+	if err != nil {
+		return 0, err
+	}
+	return checkedNumeric[T](value)
+}
+
+// NillableNumericChecked is NumericChecked for nillable values.
+func NillableNumericChecked[T, I numberic](value *I, err error) (*T, error) {
+	if value == nil || err != nil {
+		return nil, err
+	}
+	ret, err := checkedNumeric[T](*value)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func checkedNumeric[T, I numberic](value I) (T, error) {
+	ret := T(value)
+	if value != value && isFloat[T]() {
+		return ret, nil
+	}
+	if I(ret) != value || (ret < 0) != (value < 0) {
+		return 0, newInputError(formatNumber(value), ErrOutOfRange)
+	}
+	return ret, nil
+}
+
+// isFloat reports whether T is a float type, as only those keep the
+// fraction of 1/2.
+func isFloat[T numberic]() bool {
+	var half T = 1
+	half /= 2
+	return half != 0
+}
+
+func formatNumber[I numberic](value I) string {
+	switch {
+	case isFloat[I]():
+		return strconv.FormatFloat(float64(value), 'g', -1, 64)
+	case value < 0:
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return strconv.FormatUint(uint64(value), 10)
+}
+
 func ByteArray[T, I ~[]byte](value I, err error) (T, error) {
 	return T(value), err
 }
@@ -0,0 +1,167 @@
+package convert_test
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wapc/tinygo-msgpack/convert"
+)
+
+type number interface {
+	int | int8 | int16 | int32 | int64 |
+		uint | uint8 | uint16 | uint32 | uint64 |
+		float32 | float64
+}
+
+// exactValue returns the exact value of `v`, or nil for NaN and the
+// infinities.
+func exactValue[I number](v I) *big.Rat {
+	switch v := any(v).(type) {
+	case float32:
+		if math.IsInf(float64(v), 0) || v != v {
+			return nil
+		}
+		return new(big.Rat).SetFloat64(float64(v))
+	case float64:
+		if math.IsInf(v, 0) || v != v {
+			return nil
+		}
+		return new(big.Rat).SetFloat64(v)
+	}
+	if v < 0 {
+		return new(big.Rat).SetInt64(int64(v))
+	}
+	return new(big.Rat).SetInt(new(big.Int).SetUint64(uint64(v)))
+}
+
+// holds is the oracle for NumericChecked: it reports whether T can hold
+// `v` exactly, worked out with arbitrary precision arithmetic.
+func holds[T, I number](v I) bool {
+	r := exactValue(v)
+	var lo, hi int64
+	var hiU uint64
+	switch any(T(0)).(type) {
+	case float32, float64:
+		if r == nil {
+			return true
+		}
+		f := new(big.Float).SetRat(r)
+		var acc big.Accuracy
+		if _, ok := any(T(0)).(float32); ok {
+			_, acc = f.Float32()
+		} else {
+			_, acc = f.Float64()
+		}
+		return acc == big.Exact
+	case int:
+		lo, hi = math.MinInt, math.MaxInt
+	case int8:
+		lo, hi = math.MinInt8, math.MaxInt8
+	case int16:
+		lo, hi = math.MinInt16, math.MaxInt16
+	case int32:
+		lo, hi = math.MinInt32, math.MaxInt32
+	case int64:
+		lo, hi = math.MinInt64, math.MaxInt64
+	case uint:
+		hiU = math.MaxUint
+	case uint8:
+		hiU = math.MaxUint8
+	case uint16:
+		hiU = math.MaxUint16
+	case uint32:
+		hiU = math.MaxUint32
+	case uint64:
+		hiU = math.MaxUint64
+	}
+	if r == nil || !r.IsInt() {
+		return false
+	}
+	n := r.Num()
+	if hiU != 0 {
+		return n.Sign() >= 0 && n.Cmp(new(big.Int).SetUint64(hiU)) <= 0
+	}
+	return n.Cmp(big.NewInt(lo)) >= 0 && n.Cmp(big.NewInt(hi)) <= 0
+}
+
+func expectChecked[T, I number](t *testing.T, v I) {
+	t.Helper()
+	ret, err := convert.NumericChecked[T](v, nil)
+	if !holds[T](v) {
+		assert.ErrorIs(t, err, convert.ErrOutOfRange, "%T(%v) as %T", v, v, ret)
+		assert.Zero(t, ret)
+		return
+	}
+	require.NoError(t, err, "%T(%v) as %T", v, v, ret)
+	if v == v {
+		assert.Equal(t, T(v), ret)
+	}
+	ptr, err := convert.NillableNumericChecked[T](&v, nil)
+	require.NoError(t, err)
+	require.NotNil(t, ptr)
+}
+
+func expectCheckedAll[I number](t *testing.T, values ...I) {
+	for _, v := range values {
+		expectChecked[int](t, v)
+		expectChecked[int8](t, v)
+		expectChecked[int16](t, v)
+		expectChecked[int32](t, v)
+		expectChecked[int64](t, v)
+		expectChecked[uint](t, v)
+		expectChecked[uint8](t, v)
+		expectChecked[uint16](t, v)
+		expectChecked[uint32](t, v)
+		expectChecked[uint64](t, v)
+		expectChecked[float32](t, v)
+		expectChecked[float64](t, v)
+	}
+}
+
+func TestNumericCheckedBoundaries(t *testing.T) {
+	expectCheckedAll[int](t, 0, 1, -1, math.MinInt, math.MaxInt)
+	expectCheckedAll[int8](t, 0, -1, math.MinInt8, math.MaxInt8)
+	expectCheckedAll[int16](t, -1, math.MinInt8-1, math.MaxInt8+1, math.MaxUint8+1, math.MinInt16, math.MaxInt16)
+	expectCheckedAll[int32](t, -1, math.MinInt16-1, math.MaxUint16, math.MaxUint16+1, 1<<24+1, math.MinInt32, math.MaxInt32)
+	expectCheckedAll[int64](t, -1, math.MinInt32-1, math.MaxUint32, math.MaxUint32+1, 1<<53+1, math.MinInt64, math.MaxInt64)
+	expectCheckedAll[uint](t, 0, math.MaxUint)
+	expectCheckedAll[uint8](t, 0, math.MaxInt8+1, math.MaxUint8)
+	expectCheckedAll[uint16](t, math.MaxInt16, math.MaxInt16+1, math.MaxUint16)
+	expectCheckedAll[uint32](t, math.MaxInt32, math.MaxInt32+1, math.MaxUint32)
+	expectCheckedAll[uint64](t, math.MaxInt64, math.MaxInt64+1, 1<<63+1, math.MaxUint64)
+	expectCheckedAll[float32](t, 0, 0.5, -1, 255, 256, -129, float32(math.Inf(1)), float32(math.NaN()),
+		math.MaxFloat32, math.SmallestNonzeroFloat32, 1<<31, 1<<63, 1<<64)
+	expectCheckedAll[float64](t, 0, 0.1, -0.5, -1, 65535, 65536, math.Inf(-1), math.NaN(), 1<<53,
+		1<<63, -1<<63, 1<<64, math.MaxFloat64, math.SmallestNonzeroFloat64, math.MaxFloat32)
+}
+
+func TestNumericCheckedErrors(t *testing.T) {
+	v, err := convert.NumericChecked[int64, uint64](math.MaxUint64, nil)
+	assert.Zero(t, v)
+	assert.EqualError(t, err, `convert: invalid input "18446744073709551615": value out of range`)
+
+	_, err = convert.NumericChecked[uint8, int32](-1, nil)
+	var inputErr *convert.InputError
+	require.ErrorAs(t, err, &inputErr)
+	assert.Equal(t, "-1", inputErr.Input)
+
+	_, err = convert.NumericChecked[int32, float64](1.5, nil)
+	assert.EqualError(t, err, `convert: invalid input "1.5": value out of range`)
+
+	decodeErr := errors.New("decode failed")
+	_, err = convert.NumericChecked[int8, int64](1, decodeErr)
+	assert.Same(t, decodeErr, err)
+	ptr, err := convert.NillableNumericChecked[int8, int64](nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, ptr)
+
+	// The unchecked conversion is kept for callers that want it.
+	wrapped, err := convert.Numeric[int64, uint64](math.MaxUint64, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), wrapped)
+}
@@ -0,0 +1,176 @@
+package convert
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// maxInputLen is the most of an offending input an InputError quotes.
+const maxInputLen = 64
+
+// InputError is returned when a wire value cannot be converted. It quotes
+// the input, truncated to a safe length, and wraps the underlying error.
+type InputError struct {
+	Input string
+	Err   error
+}
+
+func newInputError(input string, err error) *InputError {
+	if len(input) > maxInputLen {
+		input = input[:maxInputLen] + "..."
+	}
+	return &InputError{Input: input, Err: err}
+}
+
+func (e *InputError) Error() string {
+	return Package + ": invalid input " + strconv.Quote(e.Input) + ": " + e.Err.Error()
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+var errDurationOverflow = errors.New("duration out of range")
+
+// StringToTimeLayout returns a converter parsing strings with `layout`.
+func StringToTimeLayout(layout string) func(string, error) (time.Time, error) {
+	return func(value string, err error) (time.Time, error) {
+		if err != nil {
+			return time.Time{}, err
+		}
+		return parseTime(layout, value)
+	}
+}
+
+// StringToTimePtrLayout is StringToTimeLayout for nillable values.
+func StringToTimePtrLayout(layout string) func(*string, error) (*time.Time, error) {
+	return func(value *string, err error) (*time.Time, error) {
+		if value == nil || err != nil {
+			return nil, err
+		}
+		t, err := parseTime(layout, *value)
+		if err != nil {
+			return nil, err
+		}
+		return &t, nil
+	}
+}
+
+// TimeToStringLayout returns a formatter writing times with `layout`.
+func TimeToStringLayout(layout string) func(time.Time) string {
+	return func(t time.Time) string {
+		return t.Format(layout)
+	}
+}
+
+// TimeToStringPtrLayout is TimeToStringLayout for nillable values.
+func TimeToStringPtrLayout(layout string) func(*time.Time) *string {
+	return func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		val := t.Format(layout)
+		return &val
+	}
+}
+
+func parseTime(layout, value string) (time.Time, error) {
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, newInputError(value, err)
+	}
+	return t, nil
+}
+
+// StringToDuration parses durations such as "1h30m".
+func StringToDuration(value string, err error) (time.Duration, error) {
+	if err != nil {
+		return 0, err
+	}
+	return parseDuration(value)
+}
+
+func StringToDurationPtr(value *string, err error) (*time.Duration, error) {
+	if value == nil || err != nil {
+		return nil, err
+	}
+	d, err := parseDuration(*value)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func DurationToString(d time.Duration) string {
+	return d.String()
+}
+
+func DurationToStringPtr(d *time.Duration) *string {
+	if d == nil {
+		return nil
+	}
+	val := d.String()
+	return &val
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, newInputError(value, err)
+	}
+	return d, nil
+}
+
+// Int64ToDuration returns a converter for durations sent as integer counts
+// of `unit`, such as time.Millisecond. Counts that overflow a
+// time.Duration are an error.
+func Int64ToDuration(unit time.Duration) func(int64, error) (time.Duration, error) {
+	return func(value int64, err error) (time.Duration, error) {
+		if err != nil {
+			return 0, err
+		}
+		return toDuration(value, unit)
+	}
+}
+
+// Int64ToDurationPtr is Int64ToDuration for nillable values.
+func Int64ToDurationPtr(unit time.Duration) func(*int64, error) (*time.Duration, error) {
+	return func(value *int64, err error) (*time.Duration, error) {
+		if value == nil || err != nil {
+			return nil, err
+		}
+		d, err := toDuration(*value, unit)
+		if err != nil {
+			return nil, err
+		}
+		return &d, nil
+	}
+}
+
+// DurationToInt64 returns a formatter writing durations as integer counts
+// of `unit`, truncating any remainder.
+func DurationToInt64(unit time.Duration) func(time.Duration) int64 {
+	return func(d time.Duration) int64 {
+		return int64(d / unit)
+	}
+}
+
+// DurationToInt64Ptr is DurationToInt64 for nillable values.
+func DurationToInt64Ptr(unit time.Duration) func(*time.Duration) *int64 {
+	return func(d *time.Duration) *int64 {
+		if d == nil {
+			return nil
+		}
+		val := int64(*d / unit)
+		return &val
+	}
+}
+
+func toDuration(value int64, unit time.Duration) (time.Duration, error) {
+	if unit > 0 && (value > math.MaxInt64/int64(unit) || value < math.MinInt64/int64(unit)) {
+		return 0, newInputError(strconv.FormatInt(value, 10), errDurationOverflow)
+	}
+	return time.Duration(value) * unit, nil
+}
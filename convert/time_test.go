@@ -0,0 +1,96 @@
+package convert_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wapc/tinygo-msgpack/convert"
+)
+
+func TestTimeLayoutRoundTrip(t *testing.T) {
+	value := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	for _, layout := range []string{time.RFC1123, "2006-01-02 15:04:05", time.RFC3339Nano} {
+		t.Run(layout, func(t *testing.T) {
+			s := convert.TimeToStringLayout(layout)(value)
+			parsed, err := convert.StringToTimeLayout(layout)(s, nil)
+			require.NoError(t, err)
+			assert.True(t, value.Equal(parsed), "%v != %v", value, parsed)
+
+			ptr, err := convert.StringToTimePtrLayout(layout)(convert.TimeToStringPtrLayout(layout)(&value), nil)
+			require.NoError(t, err)
+			require.NotNil(t, ptr)
+			assert.True(t, value.Equal(*ptr))
+		})
+	}
+}
+
+func TestTimeLayoutErrors(t *testing.T) {
+	_, err := convert.StringToTimeLayout(time.RFC1123)("2023-04-05", nil)
+	var inputErr *convert.InputError
+	require.ErrorAs(t, err, &inputErr)
+	assert.Equal(t, "2023-04-05", inputErr.Input)
+	var parseErr *time.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+
+	long := strings.Repeat("x", 1000)
+	_, err = convert.StringToTimePtrLayout(time.RFC1123)(&long, nil)
+	require.ErrorAs(t, err, &inputErr)
+	assert.Equal(t, strings.Repeat("x", 64)+"...", inputErr.Input)
+
+	decodeErr := errors.New("decode failed")
+	_, err = convert.StringToTimeLayout(time.RFC1123)("", decodeErr)
+	assert.Equal(t, decodeErr, err)
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	d := 90 * time.Minute
+	parsed, err := convert.StringToDuration(convert.DurationToString(d), nil)
+	require.NoError(t, err)
+	assert.Equal(t, d, parsed)
+
+	parsedPtr, err := convert.StringToDurationPtr(convert.DurationToStringPtr(&d), nil)
+	require.NoError(t, err)
+	assert.Equal(t, d, *parsedPtr)
+
+	_, err = convert.StringToDuration("1 hour", nil)
+	assert.EqualError(t, err, `convert: invalid input "1 hour": time: unknown unit " hour" in duration "1 hour"`)
+
+	for _, unit := range []time.Duration{time.Nanosecond, time.Millisecond, time.Second, time.Hour} {
+		t.Run(unit.String(), func(t *testing.T) {
+			wire := convert.DurationToInt64(unit)(d)
+			back, err := convert.Int64ToDuration(unit)(wire, nil)
+			require.NoError(t, err)
+			assert.Equal(t, d.Truncate(unit), back)
+
+			backPtr, err := convert.Int64ToDurationPtr(unit)(convert.DurationToInt64Ptr(unit)(&d), nil)
+			require.NoError(t, err)
+			assert.Equal(t, d.Truncate(unit), *backPtr)
+		})
+	}
+
+	_, err = convert.Int64ToDuration(time.Hour)(math.MaxInt64/int64(time.Hour)+1, nil)
+	assert.EqualError(t, err, `convert: invalid input "2562048": duration out of range`)
+}
+
+func TestNilPropagation(t *testing.T) {
+	tm, err := convert.StringToTimePtrLayout(time.RFC1123)(nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, tm)
+	assert.Nil(t, convert.TimeToStringPtrLayout(time.RFC1123)(nil))
+
+	d, err := convert.StringToDurationPtr(nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+	assert.Nil(t, convert.DurationToStringPtr(nil))
+
+	d, err = convert.Int64ToDurationPtr(time.Second)(nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+	assert.Nil(t, convert.DurationToInt64Ptr(time.Second)(nil))
+}
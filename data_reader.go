@@ -4,10 +4,35 @@ import (
 	"encoding/binary"
 	"errors"
 	"math"
+	"strconv"
 )
 
 var ErrRange = errors.New("range error")
 
+// RangeError is returned when a read or write would go past the end of the
+// buffer. It matches ErrRange with errors.Is.
+type RangeError struct {
+	// Offset is the position in the buffer at which the access was attempted.
+	Offset uint32
+	// Requested is the number of bytes the access needed.
+	Requested uint32
+	// Available is the number of bytes left in the buffer at Offset.
+	Available uint32
+}
+
+func (e RangeError) Error() string {
+	msg := "range error at offset " + strconv.FormatUint(uint64(e.Offset), 10)
+	if e.Requested == 0 {
+		return msg
+	}
+	return msg + ": requested " + strconv.FormatUint(uint64(e.Requested), 10) +
+		" bytes, " + strconv.FormatUint(uint64(e.Available), 10) + " available"
+}
+
+func (e RangeError) Unwrap() error {
+	return ErrRange
+}
+
 type DataReader struct {
 	buffer     []byte
 	byteOffset uint32
@@ -20,6 +45,15 @@ func NewDataReader(buffer []byte) DataReader {
 	}
 }
 
+// Remaining returns the number of bytes between the current offset and the
+// end of the buffer.
+func (d *DataReader) Remaining() uint32 {
+	if int(d.byteOffset) >= len(d.buffer) {
+		return 0
+	}
+	return uint32(len(d.buffer)) - d.byteOffset
+}
+
 func (d *DataReader) GetBytes(length uint32) ([]byte, error) {
 	if err := d.checkBufferSize(length); err != nil {
 		return nil, err
@@ -240,9 +274,13 @@ func (d *DataReader) checkBufferSize(length uint32) error {
 	if d.err != nil {
 		return d.err
 	}
-	if d.byteOffset+length > uint32(len(d.buffer)) {
-		d.err = ErrRange
-		return ErrRange
+	if uint64(d.byteOffset)+uint64(length) > uint64(len(d.buffer)) {
+		d.err = RangeError{
+			Offset:    d.byteOffset,
+			Requested: length,
+			Available: d.Remaining(),
+		}
+		return d.err
 	}
 
 	return nil
@@ -0,0 +1,67 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// sloppyPair drops the errors of its reads, as hand-written codecs
+// sometimes do.
+type sloppyPair struct {
+	A, B int64
+}
+
+func (p *sloppyPair) Encode(w msgpack.Writer) error {
+	w.WriteArraySize(2)
+	w.WriteInt64(p.A)
+	w.WriteInt64(p.B)
+	return nil
+}
+
+func (p *sloppyPair) Decode(r msgpack.Reader) error {
+	r.ReadArraySize()
+	p.A, _ = r.ReadInt64()
+	p.B, _ = r.ReadInt64()
+	return nil
+}
+
+func TestDecodeReportsDroppedReaderError(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		(&sloppyPair{A: 1, B: 1000}).Encode(w)
+	})
+	truncated := data[:len(data)-1]
+
+	decoder := msgpack.NewDecoder(truncated)
+	_, err := msgpack.Decode[sloppyPair](&decoder)
+	var unchecked msgpack.UncheckedReadError
+	require.ErrorAs(t, err, &unchecked)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.Contains(t, err.Error(), "msgpack: decode completed but reader reported: ")
+
+	decoder = msgpack.NewDecoder(truncated)
+	_, err = msgpack.DecodeNillable[sloppyPair](&decoder)
+	assert.ErrorAs(t, err, &unchecked)
+
+	_, err = msgpack.DecodeFromBase64[sloppyPair]("kgHRAw==")
+	assert.ErrorAs(t, err, &unchecked)
+}
+
+func TestDecodeWellBehavedCodec(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		(&sloppyPair{A: 1, B: 1000}).Encode(w)
+		w.WriteNil()
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	p, err := msgpack.Decode[sloppyPair](&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, sloppyPair{A: 1, B: 1000}, p)
+	nilPair, err := msgpack.DecodeNillable[sloppyPair](&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, nilPair)
+
+}
@@ -8,7 +8,16 @@ import (
 )
 
 type Decoder struct {
-	reader DataReader
+	reader   DataReader
+	options  decOptions
+	strings  *stringTableReader
+	readHook ReadHook
+	// containers are the arrays and maps being read with
+	// ReadArrayStrict and ReadMapStrict.
+	containers []containerFrame
+	// collector gathers field errors during an ObjectCodec.Decode with
+	// WithCollectErrors.
+	collector *errorCollector
 }
 
 func NewDecoder(buffer []byte) Decoder {
@@ -17,16 +26,98 @@ func NewDecoder(buffer []byte) Decoder {
 	}
 }
 
+// NewDecoderAt creates a decoder over the window `[offset, offset+length)`
+// of `buffer`. Offsets reported by the decoder and its errors are positions
+// in `buffer`, and reads never go past the end of the window. Slices the
+// decoder returns have no capacity past the window either, so appending to
+// them does not overwrite the bytes that follow it.
+func NewDecoderAt(buffer []byte, offset, length uint32) Decoder {
+	end := uint64(offset) + uint64(length)
+	if end > uint64(len(buffer)) {
+		reader := NewDataReader(buffer)
+		reader.err = RangeError{Offset: offset, Requested: length}
+		if uint64(offset) < uint64(len(buffer)) {
+			reader.err = RangeError{
+				Offset:    offset,
+				Requested: length,
+				Available: uint32(len(buffer)) - offset,
+			}
+		}
+		return Decoder{reader: reader}
+	}
+	reader := NewDataReader(buffer[:end:end])
+	reader.byteOffset = offset
+	return Decoder{reader: reader}
+}
+
+// Offset returns the position of the next byte to be read.
+func (d *Decoder) Offset() uint32 {
+	return d.reader.byteOffset
+}
+
+// Remaining returns the number of bytes left to read.
+func (d *Decoder) Remaining() uint32 {
+	return d.reader.Remaining()
+}
+
+// AliasesInput reports whether strings, byte arrays and raw values returned
+// by the decoder share memory with the input buffer. See the package
+// documentation on lifetime.
+func (d *Decoder) AliasesInput() bool {
+	return unsafeStrings
+}
+
+// InputBuffer returns the buffer the decoder reads from.
+func (d *Decoder) InputBuffer() []byte {
+	return d.reader.buffer
+}
+
+// PeekFormat returns the format byte of the next value without consuming it.
+func (d *Decoder) PeekFormat() (byte, error) {
+	return d.reader.PeekUint8()
+}
+
+// IsNextNil reports whether the next value is nil, consuming it only when
+// it is.
+//
+// Deprecated: IsNextNil advances the decoder for a nil and not otherwise.
+// Use PeekIsNil to test without consuming and ConsumeNil to consume.
 func (d *Decoder) IsNextNil() (bool, error) {
+	return readNil(d)
+}
+
+// PeekIsNil reports whether the next value is nil without consuming it.
+func (d *Decoder) PeekIsNil() (bool, error) {
 	prefix, err := d.reader.PeekUint8()
 	if err != nil {
 		return false, err
 	}
-	if prefix == FormatNil {
-		d.reader.Discard(1)
-		return true, nil
+	return prefix == FormatNil, nil
+}
+
+// ConsumeNil consumes the next value, which must be nil. Nothing is
+// consumed when it is not.
+func (d *Decoder) ConsumeNil() error {
+	offset := d.reader.byteOffset
+	prefix, err := d.reader.PeekUint8()
+	if err != nil {
+		return err
+	}
+	if prefix != FormatNil {
+		return ReadError{"msgpack: expected nil, found " + formatKind(prefix) +
+			" at offset " + strconv.FormatUint(uint64(offset), 10)}
+	}
+	return d.reader.Discard(1)
+}
+
+// readNil consumes the next value of `r` and returns true if it is nil,
+// otherwise it leaves `r` where it was and returns false.
+func readNil(r ReaderCore) (bool, error) {
+	isNil, err := r.PeekIsNil()
+	if isNil && err == nil {
+		err = r.ConsumeNil()
 	}
-	return false, nil
+	return isNil, err
 }
 
 func (d *Decoder) ReadBool() (bool, error) {
@@ -39,11 +130,11 @@ func (d *Decoder) ReadBool() (bool, error) {
 	} else if prefix == FormatFalse {
 		return false, nil
 	}
-	return false, ReadError{"bad value for bool"}
+	return false, d.typeMismatch("bool", KindBool, prefix, d.reader.byteOffset-1)
 }
 
 func (d *Decoder) ReadNillableBool() (*bool, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -55,6 +146,7 @@ func (d *Decoder) ReadNillableBool() (*bool, error) {
 }
 
 func (d *Decoder) ReadInt8() (int8, error) {
+	start := d.reader.byteOffset
 	v, err := d.ReadInt64()
 	if err != nil {
 		return 0, err
@@ -62,15 +154,11 @@ func (d *Decoder) ReadInt8() (int8, error) {
 	if v <= math.MaxInt8 && v >= math.MinInt8 {
 		return int8(v), nil
 	}
-	return 0, ReadError{
-		"interger overflow: value = " +
-			strconv.FormatInt(v, 10) +
-			"; bits = 8",
-	}
+	return 0, d.overflow(OverflowError{Int: v, Bits: 8, Offset: start})
 }
 
 func (d *Decoder) ReadNillableInt8() (*int8, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -82,6 +170,7 @@ func (d *Decoder) ReadNillableInt8() (*int8, error) {
 }
 
 func (d *Decoder) ReadInt16() (int16, error) {
+	start := d.reader.byteOffset
 	v, err := d.ReadInt64()
 	if err != nil {
 		return 0, err
@@ -89,15 +178,11 @@ func (d *Decoder) ReadInt16() (int16, error) {
 	if v <= math.MaxInt16 && v >= math.MinInt16 {
 		return int16(v), nil
 	}
-	return 0, ReadError{
-		"interger overflow: value = " +
-			strconv.FormatInt(v, 10) +
-			"; bits = 16",
-	}
+	return 0, d.overflow(OverflowError{Int: v, Bits: 16, Offset: start})
 }
 
 func (d *Decoder) ReadNillableInt16() (*int16, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -109,6 +194,7 @@ func (d *Decoder) ReadNillableInt16() (*int16, error) {
 }
 
 func (d *Decoder) ReadInt32() (int32, error) {
+	start := d.reader.byteOffset
 	v, err := d.ReadInt64()
 	if err != nil {
 		return 0, err
@@ -116,15 +202,11 @@ func (d *Decoder) ReadInt32() (int32, error) {
 	if v <= math.MaxInt32 && v >= math.MinInt32 {
 		return int32(v), nil
 	}
-	return 0, ReadError{
-		"interger overflow: value = " +
-			strconv.FormatInt(v, 10) +
-			"; bits = 32",
-	}
+	return 0, d.overflow(OverflowError{Int: v, Bits: 32, Offset: start})
 }
 
 func (d *Decoder) ReadNillableInt32() (*int32, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -158,12 +240,12 @@ func (d *Decoder) ReadInt64() (int64, error) {
 		v, err := d.reader.GetInt64()
 		return int64(v), err
 	default:
-		return 0, ReadError{"bad prefix for int64"}
+		return hookRead(d, d.typeMismatch("int64", KindInt, prefix, d.reader.byteOffset-1), hookInt)
 	}
 }
 
 func (d *Decoder) ReadNillableInt64() (*int64, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -175,6 +257,7 @@ func (d *Decoder) ReadNillableInt64() (*int64, error) {
 }
 
 func (d *Decoder) ReadUint8() (uint8, error) {
+	start := d.reader.byteOffset
 	v, err := d.ReadUint64()
 	if err != nil {
 		return 0, err
@@ -182,15 +265,11 @@ func (d *Decoder) ReadUint8() (uint8, error) {
 	if v <= math.MaxUint8 {
 		return uint8(v), nil
 	}
-	return 0, ReadError{
-		"interger overflow: value = " +
-			strconv.FormatUint(v, 10) +
-			"; bits = 8",
-	}
+	return 0, d.overflow(OverflowError{Uint: v, Unsigned: true, Bits: 8, Offset: start})
 }
 
 func (d *Decoder) ReadNillableUint8() (*uint8, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -202,6 +281,7 @@ func (d *Decoder) ReadNillableUint8() (*uint8, error) {
 }
 
 func (d *Decoder) ReadUint16() (uint16, error) {
+	start := d.reader.byteOffset
 	v, err := d.ReadUint64()
 	if err != nil {
 		return 0, err
@@ -209,15 +289,11 @@ func (d *Decoder) ReadUint16() (uint16, error) {
 	if v <= math.MaxUint16 {
 		return uint16(v), nil
 	}
-	return 0, ReadError{
-		"interger overflow: value = " +
-			strconv.FormatUint(v, 10) +
-			"; bits = 16",
-	}
+	return 0, d.overflow(OverflowError{Uint: v, Unsigned: true, Bits: 16, Offset: start})
 }
 
 func (d *Decoder) ReadNillableUint16() (*uint16, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -229,6 +305,7 @@ func (d *Decoder) ReadNillableUint16() (*uint16, error) {
 }
 
 func (d *Decoder) ReadUint32() (uint32, error) {
+	start := d.reader.byteOffset
 	v, err := d.ReadUint64()
 	if err != nil {
 		return 0, err
@@ -236,15 +313,11 @@ func (d *Decoder) ReadUint32() (uint32, error) {
 	if v <= math.MaxUint32 {
 		return uint32(v), nil
 	}
-	return 0, ReadError{
-		"interger overflow: value = " +
-			strconv.FormatUint(v, 10) +
-			"; bits = 32",
-	}
+	return 0, d.overflow(OverflowError{Uint: v, Unsigned: true, Bits: 32, Offset: start})
 }
 
 func (d *Decoder) ReadNillableUint32() (*uint32, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -256,6 +329,7 @@ func (d *Decoder) ReadNillableUint32() (*uint32, error) {
 }
 
 func (d *Decoder) ReadUint64() (uint64, error) {
+	offset := d.reader.byteOffset
 	prefix, err := d.reader.GetUint8()
 	if err != nil {
 		return 0, err
@@ -266,7 +340,7 @@ func (d *Decoder) ReadUint64() (uint64, error) {
 	} else if isNegativeFixedInt(prefix) {
 		v := int8(prefix)
 		if v < 0 {
-			return 0, ReadError{"bad prefix for uint"}
+			return hookRead(d, d.typeMismatch("uint", KindUint, prefix, offset), hookUint)
 		}
 		return uint64(v), err
 	}
@@ -286,34 +360,34 @@ func (d *Decoder) ReadUint64() (uint64, error) {
 	case FormatInt8:
 		v, err := d.reader.GetInt8()
 		if v < 0 {
-			return 0, ReadError{"bad prefix for uint"}
+			return hookRead(d, d.typeMismatch("uint", KindUint, prefix, offset), hookUint)
 		}
 		return uint64(v), err
 	case FormatInt16:
 		v, err := d.reader.GetInt16()
 		if v < 0 {
-			return 0, ReadError{"bad prefix for uint"}
+			return hookRead(d, d.typeMismatch("uint", KindUint, prefix, offset), hookUint)
 		}
 		return uint64(v), err
 	case FormatInt32:
 		v, err := d.reader.GetInt32()
 		if v < 0 {
-			return 0, ReadError{"bad prefix for uint"}
+			return hookRead(d, d.typeMismatch("uint", KindUint, prefix, offset), hookUint)
 		}
 		return uint64(v), err
 	case FormatInt64:
 		v, err := d.reader.GetInt64()
 		if v < 0 {
-			return 0, ReadError{"bad prefix for uint"}
+			return hookRead(d, d.typeMismatch("uint", KindUint, prefix, offset), hookUint)
 		}
 		return uint64(v), err
 	default:
-		return 0, ReadError{"bad prefix for uint"}
+		return hookRead(d, d.typeMismatch("uint", KindUint, prefix, offset), hookUint)
 	}
 }
 
 func (d *Decoder) ReadNillableUint64() (*uint64, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -336,11 +410,11 @@ func (d *Decoder) ReadFloat32() (float32, error) {
 		v, err := d.reader.GetFloat64()
 		return float32(v), err
 	}
-	return 0, ReadError{"bad prefix for float32"}
+	return hookRead(d, d.typeMismatch("float32", KindFloat, prefix, d.reader.byteOffset-1), hookFloat32)
 }
 
 func (d *Decoder) ReadNillableFloat32() (*float32, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -360,11 +434,11 @@ func (d *Decoder) ReadFloat64() (float64, error) {
 	if prefix == FormatFloat64 {
 		return d.reader.GetFloat64()
 	}
-	return 0, ReadError{"bad prefix for float64"}
+	return hookRead(d, d.typeMismatch("float64", KindFloat, prefix, d.reader.byteOffset-1), hookFloat)
 }
 
 func (d *Decoder) ReadNillableFloat64() (*float64, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -381,12 +455,18 @@ func (d *Decoder) ReadTime() (time.Time, error) {
 		return time.Time{}, err
 	}
 
-	if isString(prefix) {
+	strictness := d.options.timeStrictness
+	switch kind := formatKind(prefix); {
+	case kind == "string" && strictness != TimeExtOnly:
 		str, err := d.ReadString()
 		if err != nil {
 			return time.Time{}, err
 		}
 		return time.Parse(time.RFC3339Nano, str)
+	case kind == "int" && strictness == TimeAny:
+		return d.readUnixTime(prefix)
+	case kind != "ext":
+		return time.Time{}, d.typeMismatch("time ("+strictness.String()+")", KindTime, prefix, d.reader.byteOffset)
 	}
 
 	d.reader.Discard(1)
@@ -414,7 +494,7 @@ func (d *Decoder) ReadTime() (time.Time, error) {
 }
 
 func (d *Decoder) ReadNillableTime() (*time.Time, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -450,12 +530,20 @@ func (d *Decoder) decodeTime(extLen uint32) (time.Time, error) {
 }
 
 func (d *Decoder) ReadString() (string, error) {
+	if d.options.stringTable {
+		if str, ok, err := d.readStringRef(); ok || err != nil {
+			return str, err
+		}
+	}
 	strLen, err := d.readStringLength()
+	if err != nil {
+		return hookRead(d, err, hookString)
+	}
 	return d.readString(strLen, err)
 }
 
 func (d *Decoder) ReadNillableString() (*string, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
@@ -466,7 +554,21 @@ func (d *Decoder) ReadNillableString() (*string, error) {
 	return &val, err
 }
 
+// readStringLength reads a string header and checks its length against the
+// configured limit.
 func (d *Decoder) readStringLength() (uint32, error) {
+	start := d.reader.byteOffset
+	length, err := d.readStringHeader()
+	if err == nil {
+		err = d.checkLen(kindString, length, start)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return length, nil
+}
+
+func (d *Decoder) readStringHeader() (uint32, error) {
 	prefix, err := d.reader.GetUint8()
 	if err != nil {
 		return 0, err
@@ -490,7 +592,29 @@ func (d *Decoder) readStringLength() (uint32, error) {
 		return v, err
 	}
 
-	return 0, ReadError{"bad prefix for string length"}
+	return 0, d.typeMismatch("string length", KindString, prefix, d.reader.byteOffset-1)
+}
+
+// ReadStringBytes reads a string and returns its bytes, which alias the
+// input buffer. Comparing them to a known value avoids creating a string.
+func (d *Decoder) ReadStringBytes() ([]byte, error) {
+	if d.options.stringTable {
+		if str, ok, err := d.readStringRef(); ok || err != nil {
+			return UnsafeBytes(str), err
+		}
+	}
+	strLen, err := d.readStringLength()
+	if err != nil {
+		return nil, err
+	}
+	strBytes, err := d.reader.GetBytes(strLen)
+	if err != nil {
+		return nil, err
+	}
+	if d.options.stringTable {
+		d.stringTable().add(bytesToString(strBytes))
+	}
+	return binBytes(strBytes), nil
 }
 
 func (d *Decoder) readString(strLen uint32, err error) (string, error) {
@@ -501,30 +625,179 @@ func (d *Decoder) readString(strLen uint32, err error) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return UnsafeString(strBytes), nil
+	str := bytesToString(strBytes)
+	if d.options.stringTable {
+		d.stringTable().add(str)
+	}
+	return str, nil
+}
+
+// emptyBin is returned for every empty bin value, so that empty values
+// neither keep the input buffer alive nor allocate. Its capacity is zero,
+// so appending to it cannot write into memory shared with other values.
+var emptyBin = []byte{}
+
+// emptyBinAny is emptyBin in an interface, which ReadAny returns without
+// the allocation of converting the slice each time.
+var emptyBinAny any = emptyBin
+
+// bytesToString returns `b` as a string that aliases it, or "" when `b` is
+// empty.
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return UnsafeString(b)
+}
+
+// binBytes returns `b`, or emptyBin when `b` is empty.
+func binBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return emptyBin
+	}
+	return b
+}
+
+func (d *Decoder) readAnyBin(start, binLen uint32) (any, error) {
+	if err := d.checkLen(kindBin, binLen, start); err != nil {
+		return nil, err
+	}
+	b, err := d.reader.GetBytes(binLen)
+	if err != nil {
+		return nil, err
+	}
+	if binLen == 0 {
+		return emptyBinAny, nil
+	}
+	return b, nil
+}
+
+func (d *Decoder) readAnyString(start, strLen uint32, err error) (any, error) {
+	if err == nil {
+		err = d.checkLen(kindString, strLen, start)
+	}
+	str, err := d.readString(strLen, err)
+	if err != nil {
+		return nil, err
+	}
+	if d.options.timeStringDetection && looksLikeRFC3339(str) {
+		if tm, err := time.Parse(time.RFC3339Nano, str); err == nil {
+			return tm, nil
+		}
+	}
+	return str, nil
+}
+
+// looksLikeRFC3339 is a cheap shape check run before attempting to parse a
+// string as a time.
+func looksLikeRFC3339(s string) bool {
+	if len(s) < 20 || len(s) > 35 {
+		return false
+	}
+	for i := 0; i < 19; i++ {
+		c := s[i]
+		switch i {
+		case 4, 7:
+			if c != '-' {
+				return false
+			}
+		case 10:
+			if c != 'T' {
+				return false
+			}
+		case 13, 16:
+			if c != ':' {
+				return false
+			}
+		default:
+			if c < '0' || c > '9' {
+				return false
+			}
+		}
+	}
+	rest := s[19:]
+	if rest[0] == '.' {
+		i := 1
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 1 {
+			return false
+		}
+		rest = rest[i:]
+	}
+	if rest == "Z" {
+		return true
+	}
+	return len(rest) == 6 && (rest[0] == '+' || rest[0] == '-') && rest[3] == ':'
 }
 
 func (d *Decoder) ReadByteArray() ([]byte, error) {
 	binLen, err := d.readBinLength()
 	if err != nil {
-		return nil, err
+		return hookRead(d, err, hookBytes)
 	}
-	binBytes, err := d.reader.GetBytes(binLen)
+	b, err := d.reader.GetBytes(binLen)
 	if err != nil {
 		return nil, err
 	}
-	return binBytes, nil
+	return binBytes(b), nil
 }
 
+// ReadNillableByteArray reads a bin value or nil, returning a nil slice for
+// nil and for errors alike. Like ReadByteArray it also accepts fixarray
+// headers as lengths. Use ReadNillableByteArrayStrict to tell nil, empty
+// and populated values apart explicitly.
 func (d *Decoder) ReadNillableByteArray() ([]byte, error) {
-	isNil, err := d.IsNextNil()
+	isNil, err := readNil(d)
 	if isNil || err != nil {
 		return nil, err
 	}
 	return d.ReadByteArray()
 }
 
+// ReadNillableByteArrayStrict reads a bin value or nil. A nil value returns
+// a nil slice with `isNil` set, and an empty bin returns an empty, non-nil
+// slice. Any other format is an error.
+func (d *Decoder) ReadNillableByteArrayStrict() (value []byte, isNil bool, err error) {
+	prefix, err := d.reader.PeekUint8()
+	if err != nil {
+		return nil, false, err
+	}
+	switch prefix {
+	case FormatNil:
+		d.reader.Discard(1)
+		return nil, true, nil
+	case FormatBin8, FormatBin16, FormatBin32:
+	default:
+		return nil, false, ReadError{"msgpack: expected bin or nil, found " + formatKind(prefix) +
+			" at offset " + strconv.FormatUint(uint64(d.reader.byteOffset), 10)}
+	}
+	value, err = d.ReadByteArray()
+	if err != nil {
+		return nil, false, err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, false, nil
+}
+
+// readBinLength reads a bin header and checks its length against the
+// configured limit.
 func (d *Decoder) readBinLength() (uint32, error) {
+	start := d.reader.byteOffset
+	length, err := d.readBinHeader()
+	if err == nil {
+		err = d.checkLen(kindBin, length, start)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return length, nil
+}
+
+func (d *Decoder) readBinHeader() (uint32, error) {
 	prefix, err := d.reader.GetUint8()
 	if err != nil {
 		return 0, err
@@ -546,10 +819,11 @@ func (d *Decoder) readBinLength() (uint32, error) {
 		v, err := d.reader.GetUint32()
 		return v, err
 	}
-	return 0, ReadError{"bad prefix for binary length"}
+	return 0, d.typeMismatch("binary length", KindBin, prefix, d.reader.byteOffset-1)
 }
 
 func (d *Decoder) ReadArraySize() (uint32, error) {
+	offset := d.reader.byteOffset
 	prefix, err := d.reader.GetUint8()
 	if err != nil {
 		return 0, err
@@ -564,12 +838,16 @@ func (d *Decoder) ReadArraySize() (uint32, error) {
 		v, err := d.reader.GetUint32()
 		return v, err
 	} else if prefix == FormatNil {
+		if d.options.nilCollectionsAsError {
+			return 0, nilCollectionError("array", offset)
+		}
 		return 0, nil
 	}
-	return 0, ReadError{"bad prefix for array length"}
+	return 0, d.typeMismatch("array length", KindArray, prefix, offset)
 }
 
 func (d *Decoder) ReadMapSize() (uint32, error) {
+	offset := d.reader.byteOffset
 	prefix, err := d.reader.GetUint8()
 	if err != nil {
 		return 0, err
@@ -584,131 +862,231 @@ func (d *Decoder) ReadMapSize() (uint32, error) {
 		v, err := d.reader.GetUint32()
 		return v, err
 	} else if prefix == FormatNil {
+		if d.options.nilCollectionsAsError {
+			return 0, nilCollectionError("map", offset)
+		}
 		return 0, nil
 	}
-	return 0, ReadError{"bad prefix for map length"}
+	return 0, d.typeMismatch("map length", KindMap, prefix, offset)
 }
 
-func (d *Decoder) Skip() error {
-	numberOfObjectsToDiscard, err := d.getSize()
-	if err != nil {
-		return err
-	}
+// skipStackSize is the nesting depth Skip tracks without allocating.
+const skipStackSize = 32
 
-	for numberOfObjectsToDiscard > 0 {
-		err = d.Skip() // Skip recursively
+// Skip discards the next value. Arrays and maps are walked with an explicit
+// stack of element counts rather than by recursion, so deeply nested input
+// costs no call stack.
+func (d *Decoder) Skip() error {
+	var stack [skipStackSize]uint64
+	// pending holds the number of values left in each open container,
+	// innermost last. It only moves to the heap past skipStackSize levels.
+	// The counts are 64-bit as a map32 holds up to twice 2^32-1 values.
+	pending := stack[:0]
+	for {
+		if err := d.checkDepth(len(pending)); err != nil {
+			return err
+		}
+		numberOfObjectsToDiscard, err := d.getSize()
 		if err != nil {
 			return err
 		}
-		numberOfObjectsToDiscard--
+		if numberOfObjectsToDiscard > 0 {
+			pending = append(pending, numberOfObjectsToDiscard)
+		}
+		for len(pending) > 0 && pending[len(pending)-1] == 0 {
+			pending = pending[:len(pending)-1]
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		pending[len(pending)-1]--
 	}
-	return nil
 }
 
-func (d *Decoder) getSize() (uint32, error) {
+func (d *Decoder) getSize() (uint64, error) {
+	start := d.reader.byteOffset
 	leadByte, err := d.reader.GetUint8()
 	if err != nil {
 		return 0, err
 	}
-	var objectsToDiscard uint32 = 0
+	var objectsToDiscard uint64 = 0
 
 	if isNegativeFixedInt(leadByte) || isFixedInt(leadByte) {
 		// Noop, will just discard the leadbyte
 	} else if isFixedString(leadByte) {
 		strLen := uint32(leadByte & 0x1f)
-		d.reader.Discard(strLen)
+		if err = d.checkLen(kindString, strLen, start); err == nil {
+			err = d.skipString(strLen)
+		}
 	} else if isFixedArray(leadByte) {
-		objectsToDiscard = uint32(leadByte & FormatFourLeastSigBitsInByte)
+		objectsToDiscard = uint64(leadByte & FormatFourLeastSigBitsInByte)
 	} else if isFixedMap(leadByte) {
-		objectsToDiscard = 2 * uint32(leadByte&FormatFourLeastSigBitsInByte)
+		objectsToDiscard = 2 * uint64(leadByte&FormatFourLeastSigBitsInByte)
 	} else {
 		switch leadByte {
 		case FormatNil, FormatTrue, FormatFalse:
-		case FormatString8, FormatBin8:
+		case FormatString8:
 			length, err := d.reader.GetUint8()
 			if err != nil {
 				return 0, err
 			}
-			err = d.reader.Discard(uint32(length))
+			if err = d.checkLen(kindString, uint32(length), start); err == nil {
+				err = d.skipString(uint32(length))
+			}
 			if err != nil {
 				return 0, err
 			}
-		case FormatString16, FormatBin16:
+		case FormatString16:
 			length, err := d.reader.GetUint16()
 			if err != nil {
 				return 0, err
 			}
-			err = d.reader.Discard(uint32(length))
+			if err = d.checkLen(kindString, uint32(length), start); err == nil {
+				err = d.skipString(uint32(length))
+			}
 			if err != nil {
 				return 0, err
 			}
-		case FormatString32, FormatBin32:
+		case FormatString32:
 			length, err := d.reader.GetUint32()
 			if err != nil {
 				return 0, err
 			}
-			err = d.reader.Discard(length)
+			if err = d.checkLen(kindString, length, start); err == nil {
+				err = d.skipString(length)
+			}
+			if err != nil {
+				return 0, err
+			}
+		case FormatBin8:
+			length, err := d.reader.GetUint8()
+			if err != nil {
+				return 0, err
+			}
+			if err = d.checkLen(kindBin, uint32(length), start); err == nil {
+				err = d.reader.Discard(uint32(length))
+			}
+			if err != nil {
+				return 0, err
+			}
+		case FormatBin16:
+			length, err := d.reader.GetUint16()
+			if err != nil {
+				return 0, err
+			}
+			if err = d.checkLen(kindBin, uint32(length), start); err == nil {
+				err = d.reader.Discard(uint32(length))
+			}
+			if err != nil {
+				return 0, err
+			}
+		case FormatBin32:
+			length, err := d.reader.GetUint32()
+			if err != nil {
+				return 0, err
+			}
+			if err = d.checkLen(kindBin, length, start); err == nil {
+				err = d.reader.Discard(length)
+			}
 			if err != nil {
 				return 0, err
 			}
 		case FormatFloat32:
-			d.reader.Discard(4)
+			err = d.reader.Discard(4)
 		case FormatFloat64:
-			d.reader.Discard(8)
+			err = d.reader.Discard(8)
 		case FormatUint8, FormatInt8:
-			d.reader.Discard(1)
+			err = d.reader.Discard(1)
 		case FormatUint16, FormatInt16:
-			d.reader.Discard(2)
+			err = d.reader.Discard(2)
 		case FormatUint32, FormatInt32:
-			d.reader.Discard(4)
+			err = d.reader.Discard(4)
 		case FormatUint64, FormatInt64:
-			d.reader.Discard(8)
+			err = d.reader.Discard(8)
 		case FormatFixExt1:
-			d.reader.Discard(1)
+			err = d.reader.Discard(2)
 		case FormatFixExt2:
-			d.reader.Discard(3)
+			err = d.reader.Discard(3)
 		case FormatFixExt4:
-			d.reader.Discard(5)
+			err = d.reader.Discard(5)
 		case FormatFixExt8:
-			d.reader.Discard(9)
+			err = d.reader.Discard(9)
 		case FormatFixExt16:
-			d.reader.Discard(17)
+			err = d.reader.Discard(17)
+		case FormatExt8, FormatExt16, FormatExt32:
+			extLen, err := d.parseExtLen(leadByte)
+			if err != nil {
+				return 0, err
+			}
+			err = d.reader.Discard(extLen + 1)
+			if err != nil {
+				return 0, err
+			}
 		case FormatArray16:
 			v, err := d.reader.GetUint16()
 			if err != nil {
 				return 0, err
 			}
-			objectsToDiscard = uint32(v)
+			objectsToDiscard = uint64(v)
 		case FormatArray32:
 			v, err := d.reader.GetUint32()
 			if err != nil {
 				return 0, err
 			}
-			objectsToDiscard = v
+			objectsToDiscard = uint64(v)
 		case FormatMap16:
 			v, err := d.reader.GetUint16()
 			if err != nil {
 				return 0, err
 			}
-			objectsToDiscard = 2 * uint32(v)
+			objectsToDiscard = 2 * uint64(v)
 		case FormatMap32:
 			v, err := d.reader.GetUint32()
 			if err != nil {
 				return 0, err
 			}
-			objectsToDiscard = 2 * v
+			objectsToDiscard = 2 * uint64(v)
 		default:
-			return 0, ReadError{"bad prefix"}
+			return 0, badPrefix("", leadByte, start)
 		}
 	}
+	if err != nil {
+		return 0, err
+	}
 
 	return objectsToDiscard, nil
 }
 
+// ReadAny reads the next value whatever its type. Integers come back as
+// int64 for the fixint formats and in the type of their format otherwise,
+// such as uint16 for uint16; strings as string, bin as []byte, arrays as
+// []any and maps as map[any]any. Ext values and the reserved format 0xc1
+// are an error naming the format and its offset, except the string table
+// references of WithStringTableDecoding. A value that fails part way
+// returns nil.
 func (d *Decoder) ReadAny() (any, error) {
+	value, err := d.readAny(0)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// readAny reads a value nested in `depth` arrays and maps. Containers at
+// the depth limit are returned as Raw.
+func (d *Decoder) readAny(depth int) (any, error) {
+	if err := d.checkDepth(depth); err != nil {
+		return nil, err
+	}
+	if limit := d.options.anyDepthLimit; limit > 0 && depth >= limit {
+		if prefix, err := d.reader.PeekUint8(); err == nil && isContainer(prefix) {
+			return d.ReadRaw()
+		}
+	}
+	start := d.reader.byteOffset
 	prefix, err := d.reader.GetUint8()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	if isFixedInt(prefix) || isNegativeFixedInt(prefix) {
@@ -717,21 +1095,17 @@ func (d *Decoder) ReadAny() (any, error) {
 
 	if isFixedString(prefix) {
 		strLen := uint32(prefix & 0x1f)
-		return d.readString(strLen, nil)
+		return d.readAnyString(start, strLen, nil)
 	}
 
 	if isFixedArray(prefix) {
 		aryLen := uint32(prefix & FormatFourLeastSigBitsInByte)
-		ary := make([]any, aryLen)
-		err := d.readArray(ary)
-		return ary, err
+		return d.readArray(aryLen, depth)
 	}
 
 	if isFixedMap(prefix) {
 		mapLen := uint32(prefix & FormatFourLeastSigBitsInByte)
-		m := make(map[any]any, mapLen)
-		err := d.readMap(m, mapLen)
-		return m, err
+		return d.readAnyMap(mapLen, depth)
 	}
 
 	switch prefix {
@@ -763,86 +1137,91 @@ func (d *Decoder) ReadAny() (any, error) {
 		return d.reader.GetFloat64()
 	case FormatString8:
 		v, err := d.reader.GetUint8()
-		return d.readString(uint32(v), err)
+		return d.readAnyString(start, uint32(v), err)
 	case FormatString16:
 		v, err := d.reader.GetUint16()
-		return d.readString(uint32(v), err)
+		return d.readAnyString(start, uint32(v), err)
 	case FormatString32:
 		v, err := d.reader.GetUint32()
-		return d.readString(uint32(v), err)
+		return d.readAnyString(start, uint32(v), err)
 	case FormatArray16:
 		v, err := d.reader.GetUint16()
 		if err != nil {
 			return nil, err
 		}
-		ary := make([]any, v)
-		err = d.readArray(ary)
-		return ary, err
+		return d.readArray(uint32(v), depth)
 	case FormatArray32:
 		v, err := d.reader.GetUint32()
 		if err != nil {
 			return nil, err
 		}
-		ary := make([]any, v)
-		err = d.readArray(ary)
-		return ary, err
+		return d.readArray(v, depth)
 	case FormatMap16:
 		v, err := d.reader.GetUint16()
 		if err != nil {
 			return nil, err
 		}
-		m := make(map[any]any, v)
-		err = d.readMap(m, uint32(v))
-		return m, err
+		return d.readAnyMap(uint32(v), depth)
 	case FormatMap32:
 		v, err := d.reader.GetUint32()
 		if err != nil {
 			return nil, err
 		}
-		m := make(map[any]any, v)
-		err = d.readMap(m, v)
-		return m, err
+		return d.readAnyMap(v, depth)
 	case FormatBin8:
 		binLen, err := d.reader.GetUint8()
 		if err != nil {
 			return nil, err
 		}
-		return d.reader.GetBytes(uint32(binLen))
+		return d.readAnyBin(start, uint32(binLen))
 	case FormatBin16:
 		binLen, err := d.reader.GetUint16()
 		if err != nil {
 			return nil, err
 		}
-		return d.reader.GetBytes(uint32(binLen))
+		return d.readAnyBin(start, uint32(binLen))
 	case FormatBin32:
 		binLen, err := d.reader.GetUint32()
 		if err != nil {
 			return nil, err
 		}
-		return d.reader.GetBytes(binLen)
+		return d.readAnyBin(start, binLen)
 	}
 
-	return nil, ReadError{"bad value for bool"}
+	if d.options.stringTable {
+		if str, ok, err := d.resolveStringRef(prefix); ok || err != nil {
+			return str, err
+		}
+	}
+
+	return nil, badPrefix("", prefix, start)
 }
 
-func (d *Decoder) readArray(array []any) error {
-	for i := 0; i < len(array); i++ {
-		value, err := d.ReadAny()
+// readArray reads the `length` elements of an array for ReadAny.
+func (d *Decoder) readArray(length uint32, depth int) ([]any, error) {
+	array := make([]any, 0, sizeHint(d, length))
+	for i := uint32(0); i < length; i++ {
+		value, err := d.readAny(depth + 1)
 		if err != nil {
-			return err
+			return array, err
 		}
-		array[i] = value
+		array = append(array, value)
 	}
-	return nil
+	return array, nil
 }
 
-func (d *Decoder) readMap(m map[any]any, length uint32) error {
+func (d *Decoder) readMap(m map[any]any, length uint32, depth int) error {
 	for i := uint32(0); i < length; i++ {
-		key, err := d.ReadAny()
+		offset := d.reader.byteOffset
+		key, err := d.readAny(depth + 1)
 		if err != nil {
 			return err
 		}
-		value, err := d.ReadAny()
+		if !isHashable(key) {
+			return ReadError{"msgpack: map key at offset " + strconv.FormatUint(uint64(offset), 10) +
+				" is " + formatKind(d.reader.buffer[offset]) + ", which cannot be a key of a Go map"}
+		}
+		value, err := d.readAny(depth + 1)
 		if err != nil {
 			return err
 		}
@@ -851,6 +1230,16 @@ func (d *Decoder) readMap(m map[any]any, length uint32) error {
 	return nil
 }
 
+// isHashable reports whether a value returned by readAny can be a key of a
+// Go map, which the slices and maps it returns cannot.
+func isHashable(value any) bool {
+	switch value.(type) {
+	case []any, map[any]any, map[string]any, []byte, Raw:
+		return false
+	}
+	return true
+}
+
 func (d *Decoder) extHeader(c byte) (int8, uint32, error) {
 	extLen, err := d.parseExtLen(c)
 	if err != nil {
@@ -886,13 +1275,13 @@ func (d *Decoder) parseExtLen(c byte) (uint32, error) {
 	case FormatFixExt16:
 		return 16, nil
 	case FormatExt8:
-		n, err := d.ReadUint8()
+		n, err := d.reader.GetUint8()
 		return uint32(n), err
 	case FormatExt16:
-		n, err := d.ReadUint16()
+		n, err := d.reader.GetUint16()
 		return uint32(n), err
 	case FormatExt32:
-		n, err := d.ReadUint32()
+		n, err := d.reader.GetUint32()
 		return n, err
 	default:
 		return 0, ReadError{"msgpack: invalid code=" + strconv.FormatUint(uint64(c), 16) + " decoding ext len"}
@@ -903,26 +1292,55 @@ func (d *Decoder) Err() error {
 	return d.reader.Err()
 }
 
+// UncheckedReadError is returned by Decode and DecodeNillable when the
+// value's Decode method returned nil although the reader had failed, which
+// means the method dropped an error and the value is only half read.
+type UncheckedReadError struct {
+	Err error
+}
+
+func (e UncheckedReadError) Error() string {
+	return "msgpack: decode completed but reader reported: " + e.Err.Error()
+}
+
+func (e UncheckedReadError) Unwrap() error {
+	return e.Err
+}
+
+// checkDecoded returns `err`, or the error latched in `r` wrapped in an
+// UncheckedReadError if Decode returned nil.
+func checkDecoded(r ReaderCore, err error) error {
+	if err != nil {
+		return err
+	}
+	if err := r.Err(); err != nil {
+		return UncheckedReadError{err}
+	}
+	return nil
+}
+
+// Decode reads a T with its Decode method.
 func Decode[T any, PT interface {
 	*T
 	Codec
 }](decoder Reader) (T, error) {
 	var inst T
 	err := ((PT)(&inst)).Decode(decoder)
-	return inst, err
+	return inst, checkDecoded(decoder, err)
 }
 
+// DecodeNillable reads nil as a nil pointer and anything else as a T.
 func DecodeNillable[T any, PT interface {
 	*T
 	Codec
 }](decoder Reader) (PT, error) {
-	if isNil, err := decoder.IsNextNil(); isNil || err != nil {
+	if isNil, err := readNil(decoder); isNil || err != nil {
 		return nil, err
 	}
 
 	codec := PT(new(T))
 	err := codec.Decode(decoder)
-	return codec, err
+	return codec, checkDecoded(decoder, err)
 }
 
 ////////////////////
@@ -937,6 +1355,12 @@ func isNegativeFixedInt(u byte) bool {
 	return (u & 0xe0) == FormatNegativeFixInt
 }
 
+//go:inline
+func isContainer(u byte) bool {
+	return isFixedArray(u) || isFixedMap(u) ||
+		u == FormatArray16 || u == FormatArray32 || u == FormatMap16 || u == FormatMap32
+}
+
 //go:inline
 func isFixedMap(u byte) bool {
 	return (u & 0xf0) == FormatFixMap
@@ -952,14 +1376,71 @@ func isFixedString(u byte) bool {
 	return (u & 0xe0) == FormatFixString
 }
 
-func isString(u byte) bool {
-	return isFixedString(u) ||
-		u == FormatString8 ||
-		u == FormatString16 ||
-		u == FormatString32 ||
-		isFixedArray(u) ||
-		u == FormatArray16 ||
-		u == FormatArray32
+// badPrefix reports that the format byte `prefix` at `offset` cannot be
+// read as `what`.
+func badPrefix(what string, prefix byte, offset uint32) error {
+	return ReadError{badPrefixMessage(what, prefix, offset)}
+}
+
+func badPrefixMessage(what string, prefix byte, offset uint32) string {
+	message := "bad prefix"
+	if what != "" {
+		message += " for " + what
+	}
+	return message + ": got " + FormatName(prefix) + " (0x" + hexByte(prefix) +
+		") at offset " + strconv.FormatUint(uint64(offset), 10)
+}
+
+// TypeMismatchError is returned by the typed reads, such as ReadBool,
+// ReadInt64, ReadString or ReadArraySize, when the next value has a
+// different kind than the one asked for. Check for it with errors.As. It
+// matches ErrBadPrefix with errors.Is.
+type TypeMismatchError struct {
+	// Expected is the kind the read asked for.
+	Expected ValueKind
+	// Actual is the kind of the value found. Extensions are KindExt, since
+	// only the format byte is looked at.
+	Actual ValueKind
+	// ActualPrefix is the format byte of the value found.
+	ActualPrefix byte
+	// Offset is the position of ActualPrefix in the buffer.
+	Offset uint32
+
+	what    string
+	context string
+}
+
+// Unwrap returns ErrBadPrefix, so that errors.Is matches it.
+func (e TypeMismatchError) Unwrap() error {
+	return ErrBadPrefix
+}
+
+func (e TypeMismatchError) Error() string {
+	what := e.what
+	if what == "" {
+		what = e.Expected.String()
+	}
+	return e.context + badPrefixMessage(what, e.ActualPrefix, e.Offset)
+}
+
+// typeMismatch returns a TypeMismatchError for a read of `what`, such as
+// "string length", that expected a value of kind `expected`, or
+// ErrBadPrefix with WithTerseErrors.
+func (d *Decoder) typeMismatch(what string, expected ValueKind, prefix byte, offset uint32) error {
+	if d.terseMismatch() {
+		return ErrBadPrefix
+	}
+	return TypeMismatchError{
+		Expected:     expected,
+		Actual:       prefixKind(prefix),
+		ActualPrefix: prefix,
+		Offset:       offset,
+		what:         what,
+	}
+}
+
+func hexByte(b byte) string {
+	return string([]byte{hexDigits[b>>4], hexDigits[b&0x0f]})
 }
 
 type ReadError struct {
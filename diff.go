@@ -0,0 +1,448 @@
+package msgpack
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"time"
+)
+
+// DifferenceKind classifies a Difference.
+type DifferenceKind int
+
+const (
+	// DiffKind means the values are of different kinds, such as a string
+	// and an int.
+	DiffKind DifferenceKind = iota
+	// DiffValue means the values are of the same kind but not equal.
+	DiffValue
+	// DiffLength means two arrays have different lengths. Elements up to
+	// the shorter length are still compared.
+	DiffLength
+	// DiffOnlyInA means a map key is present only in the first document.
+	DiffOnlyInA
+	// DiffOnlyInB means a map key is present only in the second document.
+	DiffOnlyInB
+	// DiffFormat means the values are equal but encoded with different
+	// formats, such as fixint and uint16.
+	DiffFormat
+)
+
+func (k DifferenceKind) String() string {
+	switch k {
+	case DiffKind:
+		return "kind"
+	case DiffValue:
+		return "value"
+	case DiffLength:
+		return "length"
+	case DiffOnlyInA:
+		return "only in a"
+	case DiffOnlyInB:
+		return "only in b"
+	case DiffFormat:
+		return "format"
+	}
+	return "unknown"
+}
+
+// Difference is one difference reported by Diff.
+type Difference struct {
+	// Path locates the value. An int segment is an array index and any
+	// other segment is a map key, with integer keys as int64 and keys that
+	// are arrays, maps, bins or exts as their Raw encoding.
+	Path []any
+	Kind DifferenceKind
+	// A and B are the decoded values, normalized so that integers are
+	// int64 (or uint64 above math.MaxInt64), floats are float64 and
+	// timestamps are UTC time.Time values.
+	// Nested arrays and maps are only described by their length. For
+	// DiffLength, A and B are the two lengths as uint32.
+	A, B any
+	// FormatA and FormatB are the format bytes of the two values.
+	FormatA, FormatB byte
+}
+
+func (d Difference) String() string {
+	path := formatDiffPath(d.Path)
+	switch d.Kind {
+	case DiffLength:
+		return path + ": array length " + renderDiffValue(d.A) + " != " + renderDiffValue(d.B)
+	case DiffOnlyInA:
+		return path + ": only in a: " + renderDiffValue(d.A)
+	case DiffOnlyInB:
+		return path + ": only in b: " + renderDiffValue(d.B)
+	case DiffFormat:
+		return path + ": " + renderDiffValue(d.A) + " encoded as " + formatName(d.FormatA) + " != " + formatName(d.FormatB)
+	}
+	return path + ": " + renderDiffValue(d.A) + " != " + renderDiffValue(d.B)
+}
+
+// SemanticallyEqual reports whether `diffs` holds only DiffFormat
+// differences, meaning the documents decode to the same values.
+func SemanticallyEqual(diffs []Difference) bool {
+	for _, d := range diffs {
+		if d.Kind != DiffFormat {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff walks the documents `a` and `b` in parallel and reports how they
+// differ, in document order. Maps are compared by key regardless of entry
+// order. Equal values written with different formats are reported as
+// DiffFormat, so SemanticallyEqual can tell whether anything else differs.
+// An error is returned only if a document is malformed.
+func Diff(a, b []byte) ([]Difference, error) {
+	da := NewDecoder(a)
+	db := NewDecoder(b)
+	var diffs []Difference
+	if err := diffValues(&da, &db, nil, &diffs); err != nil {
+		return nil, err
+	}
+	for _, d := range []*Decoder{&da, &db} {
+		if d.Remaining() != 0 {
+			return nil, ReadError{"msgpack: " + strconv.FormatUint(uint64(d.Remaining()), 10) +
+				" trailing bytes at offset " + strconv.FormatUint(uint64(d.Offset()), 10)}
+		}
+	}
+	return diffs, nil
+}
+
+func diffValues(a, b *Decoder, path []any, diffs *[]Difference) error {
+	pa, err := a.PeekFormat()
+	if err != nil {
+		return err
+	}
+	pb, err := b.PeekFormat()
+	if err != nil {
+		return err
+	}
+	kind := formatKind(pa)
+	if kind != formatKind(pb) {
+		va, err := readNormalizedValue(a)
+		if err != nil {
+			return err
+		}
+		vb, err := readNormalizedValue(b)
+		if err != nil {
+			return err
+		}
+		*diffs = append(*diffs, Difference{copyPath(path), DiffKind, va, vb, pa, pb})
+		return nil
+	}
+
+	switch kind {
+	case "array":
+		return diffArrays(a, b, pa, pb, path, diffs)
+	case "map":
+		return diffMaps(a, b, pa, pb, path, diffs)
+	}
+	va, err := readNormalizedValue(a)
+	if err != nil {
+		return err
+	}
+	vb, err := readNormalizedValue(b)
+	if err != nil {
+		return err
+	}
+	if !equalDiffValues(va, vb) {
+		*diffs = append(*diffs, Difference{copyPath(path), DiffValue, va, vb, pa, pb})
+	} else if pa != pb {
+		*diffs = append(*diffs, Difference{copyPath(path), DiffFormat, va, vb, pa, pb})
+	}
+	return nil
+}
+
+func diffArrays(a, b *Decoder, pa, pb byte, path []any, diffs *[]Difference) error {
+	na, err := a.ReadArraySize()
+	if err != nil {
+		return err
+	}
+	nb, err := b.ReadArraySize()
+	if err != nil {
+		return err
+	}
+	if na != nb {
+		*diffs = append(*diffs, Difference{copyPath(path), DiffLength, na, nb, pa, pb})
+	} else if pa != pb {
+		*diffs = append(*diffs, Difference{copyPath(path), DiffFormat, diffContainer{"array", na}, diffContainer{"array", nb}, pa, pb})
+	}
+	i := uint32(0)
+	for ; i < na && i < nb; i++ {
+		if err := diffValues(a, b, append(path, int(i)), diffs); err != nil {
+			return err
+		}
+	}
+	for j := i; j < na; j++ {
+		if err := a.Skip(); err != nil {
+			return err
+		}
+	}
+	for j := i; j < nb; j++ {
+		if err := b.Skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type diffEntry struct {
+	key   any
+	value Raw
+}
+
+func diffMaps(a, b *Decoder, pa, pb byte, path []any, diffs *[]Difference) error {
+	entriesA, err := readDiffEntries(a)
+	if err != nil {
+		return err
+	}
+	entriesB, err := readDiffEntries(b)
+	if err != nil {
+		return err
+	}
+	if len(entriesA) == len(entriesB) && pa != pb {
+		n := uint32(len(entriesA))
+		*diffs = append(*diffs, Difference{copyPath(path), DiffFormat, diffContainer{"map", n}, diffContainer{"map", n}, pa, pb})
+	}
+	indexB := make(map[any]int, len(entriesB))
+	for i, e := range entriesB {
+		if _, exists := indexB[e.key]; !exists {
+			indexB[e.key] = i
+		}
+	}
+	seen := make(map[any]bool, len(entriesA))
+	for _, e := range entriesA {
+		if seen[e.key] {
+			continue
+		}
+		seen[e.key] = true
+		keyPath := append(path, diffPathKey(e.key))
+		i, found := indexB[e.key]
+		if !found {
+			v, err := readRawNormalizedValue(e.value)
+			if err != nil {
+				return err
+			}
+			*diffs = append(*diffs, Difference{copyPath(keyPath), DiffOnlyInA, v, nil, e.value[0], 0})
+			continue
+		}
+		va := NewDecoder(e.value)
+		vb := NewDecoder(entriesB[i].value)
+		if err := diffValues(&va, &vb, keyPath, diffs); err != nil {
+			return err
+		}
+	}
+	for _, e := range entriesB {
+		if seen[e.key] {
+			continue
+		}
+		seen[e.key] = true
+		v, err := readRawNormalizedValue(e.value)
+		if err != nil {
+			return err
+		}
+		*diffs = append(*diffs, Difference{copyPath(append(path, diffPathKey(e.key))), DiffOnlyInB, nil, v, 0, e.value[0]})
+	}
+	return nil
+}
+
+func readDiffEntries(d *Decoder) ([]diffEntry, error) {
+	size, err := d.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]diffEntry, 0, sizeHint(d, size))
+	for i := uint32(0); i < size; i++ {
+		start := d.Offset()
+		key, err := readNormalizedValue(d)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case []byte, diffContainer, diffExt:
+			// Not comparable, so keyed by encoding instead.
+			key = diffRawKey(d.InputBuffer()[start:d.Offset()])
+		}
+		value, err := d.ReadRaw()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, diffEntry{key, value})
+	}
+	return entries, nil
+}
+
+// diffContainer describes an array or map by its length.
+type diffContainer struct {
+	kind   string
+	length uint32
+}
+
+type diffExt struct {
+	extType int8
+	data    []byte
+}
+
+type diffRawKey string
+
+func readRawNormalizedValue(raw Raw) (any, error) {
+	d := NewDecoder(raw)
+	return readNormalizedValue(&d)
+}
+
+// readNormalizedValue reads the next value with integers as int64 (or
+// uint64 above math.MaxInt64), floats as float64 and timestamps as UTC
+// times. Arrays and maps are skipped and described by their length.
+func readNormalizedValue(d *Decoder) (any, error) {
+	prefix, err := d.PeekFormat()
+	if err != nil {
+		return nil, err
+	}
+	switch formatKind(prefix) {
+	case "array":
+		size, err := d.ReadArraySize()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < size; i++ {
+			if err := d.Skip(); err != nil {
+				return nil, err
+			}
+		}
+		return diffContainer{"array", size}, nil
+	case "map":
+		size, err := d.ReadMapSize()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < 2*size; i++ {
+			if err := d.Skip(); err != nil {
+				return nil, err
+			}
+		}
+		return diffContainer{"map", size}, nil
+	case "ext":
+		if isTimestamp(d) {
+			// The same instant can be written in three forms.
+			t, err := d.ReadTime()
+			return t.UTC(), err
+		}
+		if _, err := d.reader.GetUint8(); err != nil {
+			return nil, err
+		}
+		extType, length, err := d.extHeader(prefix)
+		if err != nil {
+			return nil, err
+		}
+		data, err := d.reader.GetBytes(length)
+		return diffExt{extType, data}, err
+	}
+	v, err := d.ReadAny()
+	if err != nil {
+		return nil, err
+	}
+	switch n := v.(type) {
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+	case float32:
+		return float64(n), nil
+	}
+	return v, nil
+}
+
+func equalDiffValues(a, b any) bool {
+	switch va := a.(type) {
+	case []byte:
+		vb, ok := b.([]byte)
+		return ok && bytes.Equal(va, vb)
+	case diffExt:
+		vb, ok := b.(diffExt)
+		return ok && va.extType == vb.extType && bytes.Equal(va.data, vb.data)
+	case time.Time:
+		vb, ok := b.(time.Time)
+		return ok && va.Equal(vb)
+	case float64:
+		// NaN is equal to itself here: the documents hold the same value.
+		vb, ok := b.(float64)
+		return ok && (va == vb || va != va && vb != vb)
+	}
+	return a == b
+}
+
+func diffPathKey(key any) any {
+	if k, ok := key.(diffRawKey); ok {
+		return Raw(k)
+	}
+	return key
+}
+
+func copyPath(path []any) []any {
+	return append([]any(nil), path...)
+}
+
+func formatDiffPath(path []any) string {
+	s := ""
+	for _, segment := range path {
+		switch v := segment.(type) {
+		case int:
+			s += "[" + strconv.Itoa(v) + "]"
+		case string:
+			if s != "" {
+				s += "."
+			}
+			s += v
+		default:
+			s += "[" + renderDiffValue(v) + "]"
+		}
+	}
+	if s == "" {
+		return "$"
+	}
+	return s
+}
+
+func renderDiffValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return "int64(" + strconv.FormatInt(v, 10) + ")"
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return "uint64(" + strconv.FormatUint(v, 10) + ")"
+	case float64:
+		return "float64(" + strconv.FormatFloat(v, 'g', -1, 64) + ")"
+	case string:
+		return strconv.Quote(v)
+	case []byte:
+		return "bin(" + strconv.Itoa(len(v)) + " bytes)"
+	case diffContainer:
+		return v.kind + "(" + strconv.FormatUint(uint64(v.length), 10) + ")"
+	case diffExt:
+		return "ext(" + strconv.Itoa(int(v.extType)) + ", " + strconv.Itoa(len(v.data)) + " bytes)"
+	case time.Time:
+		return "time(" + v.Format(time.RFC3339Nano) + ")"
+	case Raw:
+		return "raw(" + strconv.Itoa(len(v)) + " bytes)"
+	}
+	return "?"
+}
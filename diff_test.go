@@ -0,0 +1,156 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func diffStrings(t *testing.T, a, b func(w msgpack.Writer)) []string {
+	t.Helper()
+	diffs, err := msgpack.Diff(encodeWith(t, a), encodeWith(t, b))
+	require.NoError(t, err)
+	s := make([]string, len(diffs))
+	for i, d := range diffs {
+		s[i] = d.String()
+	}
+	return s
+}
+
+func order(w msgpack.Writer, price func(w msgpack.Writer), extra bool) {
+	size := uint32(2)
+	if extra {
+		size++
+	}
+	w.WriteMapSize(size)
+	w.WriteString("id")
+	w.WriteString("order-1")
+	w.WriteString("items")
+	w.WriteArraySize(4)
+	for i := 0; i < 4; i++ {
+		w.WriteMapSize(1)
+		w.WriteString("price")
+		if i == 3 {
+			price(w)
+		} else {
+			w.WriteFloat64(1.5)
+		}
+	}
+	if extra {
+		w.WriteString("note")
+		w.WriteString("gift")
+	}
+}
+
+func TestDiffEqual(t *testing.T) {
+	write := func(w msgpack.Writer) { order(w, func(w msgpack.Writer) { w.WriteFloat64(9.99) }, true) }
+	diffs, err := msgpack.Diff(encodeWith(t, write), encodeWith(t, write))
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+	assert.True(t, msgpack.SemanticallyEqual(diffs))
+}
+
+func TestDiffKindAndValue(t *testing.T) {
+	assert.Equal(t, []string{"items[3].price: float64(9.99) != int64(9)"}, diffStrings(t,
+		func(w msgpack.Writer) { order(w, func(w msgpack.Writer) { w.WriteFloat64(9.99) }, false) },
+		func(w msgpack.Writer) { order(w, func(w msgpack.Writer) { w.WriteInt64(9) }, false) }))
+
+	assert.Equal(t, []string{"items[3].price: int64(9) != int64(10)"}, diffStrings(t,
+		func(w msgpack.Writer) { order(w, func(w msgpack.Writer) { w.WriteInt64(9) }, false) },
+		func(w msgpack.Writer) { order(w, func(w msgpack.Writer) { w.WriteInt64(10) }, false) }))
+
+	assert.Equal(t, []string{`items[3].price: "9.99" != map(0)`}, diffStrings(t,
+		func(w msgpack.Writer) { order(w, func(w msgpack.Writer) { w.WriteString("9.99") }, false) },
+		func(w msgpack.Writer) { order(w, func(w msgpack.Writer) { w.WriteMapSize(0) }, false) }))
+}
+
+func TestDiffArrayLength(t *testing.T) {
+	assert.Equal(t, []string{"$: array length 3 != 2", "[1]: int64(2) != int64(5)"}, diffStrings(t,
+		func(w msgpack.Writer) {
+			w.WriteArraySize(3)
+			w.WriteInt64(1)
+			w.WriteInt64(2)
+			w.WriteInt64(3)
+		},
+		func(w msgpack.Writer) {
+			w.WriteArraySize(2)
+			w.WriteInt64(1)
+			w.WriteInt64(5)
+		}))
+}
+
+func TestDiffMapKeys(t *testing.T) {
+	price := func(w msgpack.Writer) { w.WriteFloat64(9.99) }
+	assert.Equal(t, []string{`note: only in a: "gift"`}, diffStrings(t,
+		func(w msgpack.Writer) { order(w, price, true) },
+		func(w msgpack.Writer) { order(w, price, false) }))
+	assert.Equal(t, []string{`note: only in b: "gift"`}, diffStrings(t,
+		func(w msgpack.Writer) { order(w, price, false) },
+		func(w msgpack.Writer) { order(w, price, true) }))
+
+	// Entry order does not matter, and integer keys are not indexes.
+	diffs := diffStrings(t,
+		func(w msgpack.Writer) {
+			w.WriteMapSize(2)
+			w.WriteInt64(1)
+			w.WriteString("one")
+			w.WriteString("two")
+			w.WriteInt64(2)
+		},
+		func(w msgpack.Writer) {
+			w.WriteMapSize(2)
+			w.WriteString("two")
+			w.WriteInt64(2)
+			w.WriteInt64(1)
+			w.WriteString("uno")
+		})
+	assert.Equal(t, []string{`[int64(1)]: "one" != "uno"`}, diffs)
+}
+
+func TestDiffFormatOnly(t *testing.T) {
+	a := []byte{
+		0x82,            // fixmap(2)
+		0xa1, 'n', 0x05, // "n": fixint 5
+		0xa1, 'l', 0x92, 0xa1, 'x', 0xca, 0x3f, 0xc0, 0x00, 0x00, // "l": ["x", float32 1.5]
+	}
+	b := []byte{
+		0xde, 0x00, 0x02, // map16(2)
+		0xa1, 'l', 0xdc, 0x00, 0x02, 0xd9, 0x01, 'x', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xa1, 'n', 0xcd, 0x00, 0x05, // "n": uint16 5
+	}
+	diffs, err := msgpack.Diff(a, b)
+	require.NoError(t, err)
+	assert.True(t, msgpack.SemanticallyEqual(diffs))
+	s := make([]string, len(diffs))
+	for i, d := range diffs {
+		assert.Equal(t, msgpack.DiffFormat, d.Kind)
+		s[i] = d.String()
+	}
+	assert.Equal(t, []string{
+		"$: map(2) encoded as fixmap != map16",
+		"n: int64(5) encoded as fixint != uint16",
+		"l: array(2) encoded as fixarray != array16",
+		`l[0]: "x" encoded as fixstr != str8`,
+		"l[1]: float64(1.5) encoded as float32 != float64",
+	}, s)
+
+	b[len(b)-1] = 0x06
+	diffs, err = msgpack.Diff(a, b)
+	require.NoError(t, err)
+	assert.False(t, msgpack.SemanticallyEqual(diffs))
+}
+
+func TestDiffMalformed(t *testing.T) {
+	_, err := msgpack.Diff([]byte{0x92, 0x01}, []byte{0x92, 0x01, 0x02})
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+
+	_, err = msgpack.Diff([]byte{0x01, 0x02}, []byte{0x01})
+	assert.EqualError(t, err, "msgpack: 1 trailing bytes at offset 1")
+
+	// A truncated map32 header fails without allocating for its size.
+	_, err = msgpack.Diff([]byte{0xdf, 0xff, 0xff, 0xff, 0xff}, []byte{0x80})
+	assert.EqualError(t, err, "range error at offset 5: requested 1 bytes, 0 available")
+}
@@ -0,0 +1,29 @@
+// Package msgpack implements the MessagePack format for TinyGo and Go.
+//
+// # Lifetime
+//
+// A Decoder never copies out of the buffer it was created with. Strings
+// returned by ReadString and ReadNillableString, slices returned by
+// ReadByteArray and ReadNillableByteArray, values returned by ReadRaw and
+// ReadNillableRaw, and the strings and bin values inside ReadAny results
+// all alias the input buffer. They remain valid for as long as the buffer
+// is neither modified nor reused; mutating the buffer changes the decoded
+// values. Nothing in the Decoder itself invalidates them. Callers that need
+// to retain values beyond the life of the buffer must copy them.
+// Decoder.AliasesInput reports this behavior and Decoder.InputBuffer
+// exposes the backing buffer for callers managing its lifetime explicitly.
+// Builds tagged purego or appengine, which TinyGo sets for targets other
+// than WebAssembly, convert with copies, so their strings do not alias the
+// buffer and AliasesInput reports false.
+//
+// Because ReadByteArray already returns an alias, there is no separate
+// no-copy variant of it. ReadRawCopyBounded is the one read that returns a
+// copy, for raw values that are kept after the message is handled.
+//
+// # Concurrency
+//
+// Encoder, Decoder and Sizer values hold a position in their buffer and
+// are not safe for concurrent use. Give each goroutine its own values, or
+// use a CodecPool, which hands out pooled encoders, decoders and scratch
+// buffers and is safe for concurrent use.
+package msgpack
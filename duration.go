@@ -0,0 +1,124 @@
+package msgpack
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// WithDurationUnit makes WriteDuration write durations as an integer count
+// of `unit`, such as time.Millisecond, instead of nanoseconds. Any
+// remainder is truncated. The Sizer needs the option too.
+func WithDurationUnit(unit time.Duration) EncOption {
+	return func(o *encOptions) {
+		o.durationUnit = unit
+	}
+}
+
+// WithDurationAsString makes WriteDuration write durations as strings
+// such as "1h30m0s", as formatted by time.Duration.String. It takes
+// precedence over WithDurationUnit. The Sizer needs the option too.
+func WithDurationAsString() EncOption {
+	return func(o *encOptions) {
+		o.durationAsString = true
+	}
+}
+
+// WithDurationUnitDecoding makes ReadDuration read integers as counts of
+// `unit` instead of nanoseconds.
+func WithDurationUnitDecoding(unit time.Duration) DecOption {
+	return func(o *decOptions) {
+		o.durationUnit = unit
+	}
+}
+
+// WriteDuration writes `d` as an int64 count of nanoseconds, or in the
+// form chosen with WithDurationUnit or WithDurationAsString when `w` is an
+// Encoder, Sizer or UpperBoundSizer created with them.
+func WriteDuration(w Writer, d time.Duration) {
+	o := encOptionsOf(w)
+	switch {
+	case o.durationAsString:
+		w.WriteString(d.String())
+	case o.durationUnit > 0:
+		w.WriteInt64(int64(d / o.durationUnit))
+	default:
+		w.WriteInt64(int64(d))
+	}
+}
+
+// WriteNillableDuration writes nil for a nil `d` and is otherwise
+// WriteDuration.
+func WriteNillableDuration(w Writer, d *time.Duration) {
+	if d == nil {
+		w.WriteNil()
+		return
+	}
+	WriteDuration(w, *d)
+}
+
+// ReadDuration reads a duration in any of the forms producers use for
+// them: an integer count of nanoseconds, or of the unit given with
+// WithDurationUnitDecoding when `r` is a Decoder, a float count of
+// seconds, or a string accepted by time.ParseDuration. A value outside
+// the range of time.Duration is an error.
+func ReadDuration(r Reader) (time.Duration, error) {
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return 0, err
+	}
+	d := NewDecoder(raw)
+	switch formatKind(raw[0]) {
+	case "int":
+		unit := decOptionsOf(r).durationUnit
+		if unit <= 0 {
+			unit = time.Nanosecond
+		}
+		v, err := readNormalizedValue(&d)
+		if err != nil {
+			return 0, err
+		}
+		n, ok := v.(int64)
+		if !ok {
+			return 0, durationRangeError(strconv.FormatUint(v.(uint64), 10) + " x " + unit.String())
+		}
+		if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+			return 0, durationRangeError(strconv.FormatInt(n, 10) + " x " + unit.String())
+		}
+		return time.Duration(n) * unit, nil
+	case "float":
+		v, err := readNormalizedValue(&d)
+		if err != nil {
+			return 0, err
+		}
+		seconds := v.(float64)
+		ns := seconds * float64(time.Second)
+		// float64(math.MaxInt64) rounds up to 2^63, which is already out
+		// of range.
+		if ns != ns || ns >= float64(math.MaxInt64) || ns < float64(math.MinInt64) {
+			return 0, durationRangeError(strconv.FormatFloat(seconds, 'g', -1, 64) + "s")
+		}
+		return time.Duration(ns), nil
+	case "string":
+		s, err := d.ReadString()
+		if err != nil {
+			return 0, err
+		}
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, ReadError{"msgpack: " + err.Error()}
+		}
+		return duration, nil
+	}
+	return 0, ReadError{"msgpack: expected duration, got " + FormatName(raw[0])}
+}
+
+// ReadNillableDuration reads nil as a nil pointer and is otherwise
+// ReadDuration.
+func ReadNillableDuration(r Reader) (*time.Duration, error) {
+	return readNillableWith(r, func() (time.Duration, error) { return ReadDuration(r) })
+}
+
+func durationRangeError(value string) error {
+	return ReadError{"msgpack: duration " + value + " is out of range"}
+}
@@ -0,0 +1,123 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+var testDurations = []time.Duration{0, 1500 * time.Millisecond, -90 * time.Minute, 36 * time.Hour}
+
+func TestDurationRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		enc  []msgpack.EncOption
+		dec  []msgpack.DecOption
+	}{
+		{"nanoseconds", nil, nil},
+		{"milliseconds", []msgpack.EncOption{msgpack.WithDurationUnit(time.Millisecond)},
+			[]msgpack.DecOption{msgpack.WithDurationUnitDecoding(time.Millisecond)}},
+		{"string", []msgpack.EncOption{msgpack.WithDurationAsString()}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := encodeWithOptions(t, func(w msgpack.Writer) {
+				for _, d := range testDurations {
+					msgpack.WriteDuration(w, d)
+					msgpack.WriteNillableDuration(w, &d)
+				}
+				msgpack.WriteNillableDuration(w, nil)
+			}, tc.enc...)
+
+			decoder := msgpack.NewDecoderWithOptions(data, tc.dec...)
+			for _, expected := range testDurations {
+				d, err := msgpack.ReadDuration(&decoder)
+				require.NoError(t, err)
+				assert.Equal(t, expected, d)
+				p, err := msgpack.ReadNillableDuration(&decoder)
+				require.NoError(t, err)
+				require.NotNil(t, p)
+				assert.Equal(t, expected, *p)
+			}
+			p, err := msgpack.ReadNillableDuration(&decoder)
+			require.NoError(t, err)
+			assert.Nil(t, p)
+			assert.Equal(t, uint32(0), decoder.Remaining())
+		})
+	}
+}
+
+func TestWriteDurationWireForms(t *testing.T) {
+	d := 1500*time.Millisecond + 7
+	data := encodeWithOptions(t, func(w msgpack.Writer) { msgpack.WriteDuration(w, d) },
+		msgpack.WithDurationUnit(time.Millisecond))
+	decoder := msgpack.NewDecoder(data)
+	n, err := decoder.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), n)
+
+	data = encodeWithOptions(t, func(w msgpack.Writer) { msgpack.WriteDuration(w, 90*time.Minute) },
+		msgpack.WithDurationAsString())
+	decoder = msgpack.NewDecoder(data)
+	s, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "1h30m0s", s)
+}
+
+func TestReadDurationAcceptsEveryForm(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteInt64(int64(2 * time.Second))
+		w.WriteUint32(2000)
+		w.WriteFloat64(2.5)
+		w.WriteFloat32(0.25)
+		w.WriteString("1h30m")
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	for _, expected := range []time.Duration{2 * time.Second, 2000, 2500 * time.Millisecond,
+		250 * time.Millisecond, 90 * time.Minute} {
+		d, err := msgpack.ReadDuration(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, d)
+	}
+
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithDurationUnitDecoding(time.Millisecond))
+	d, err := msgpack.ReadDuration(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second*time.Millisecond, d)
+	d, err = msgpack.ReadDuration(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, d)
+}
+
+func TestReadDurationOutOfRange(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteFloat64(1e10)
+		w.WriteFloat64(-1e300)
+		w.WriteFloat64(math.NaN())
+		w.WriteUint64(math.MaxUint64)
+		w.WriteInt64(math.MaxInt64 / 1000)
+		w.WriteBool(true)
+		w.WriteString("soon")
+	})
+
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithDurationUnitDecoding(time.Second))
+	_, err := msgpack.ReadDuration(&decoder)
+	assert.EqualError(t, err, "msgpack: duration 1e+10s is out of range")
+	_, err = msgpack.ReadDuration(&decoder)
+	assert.EqualError(t, err, "msgpack: duration -1e+300s is out of range")
+	_, err = msgpack.ReadDuration(&decoder)
+	assert.EqualError(t, err, "msgpack: duration NaNs is out of range")
+	_, err = msgpack.ReadDuration(&decoder)
+	assert.EqualError(t, err, "msgpack: duration 18446744073709551615 x 1s is out of range")
+	_, err = msgpack.ReadDuration(&decoder)
+	assert.EqualError(t, err, "msgpack: duration 9223372036854775 x 1s is out of range")
+	_, err = msgpack.ReadDuration(&decoder)
+	assert.EqualError(t, err, "msgpack: expected duration, got true")
+	_, err = msgpack.ReadDuration(&decoder)
+	assert.EqualError(t, err, `msgpack: time: invalid duration "soon"`)
+}
@@ -0,0 +1,105 @@
+package msgpack
+
+import "strconv"
+
+// EmbeddedError wraps an error decoding a document embedded with
+// WriteEmbedded. Offsets in Err are relative to the embedded document;
+// Offset is where the document starts in the outer buffer.
+type EmbeddedError struct {
+	Offset uint32
+	Err    error
+}
+
+func (e EmbeddedError) Error() string {
+	return "msgpack: embedded document at offset " + strconv.FormatUint(uint64(e.Offset), 10) +
+		": " + e.Err.Error()
+}
+
+func (e EmbeddedError) Unwrap() error {
+	return e.Err
+}
+
+// WriteEmbedded writes `value` as a complete MessagePack document stored in
+// a bin, so intermediaries can pass it on without knowing its schema. The
+// value is sized first and, when `w` is an Encoder, encoded straight into
+// the outer buffer after the bin header.
+func WriteEmbedded(w Writer, value Encodable) error {
+	switch w := w.(type) {
+	case *Sizer:
+		return SizeEmbedded(w, value)
+	case *Encoder:
+		sizer := Sizer{options: w.options}
+		if err := value.Encode(&sizer); err != nil {
+			return err
+		}
+		n := sizer.Len()
+		w.writeBinLength(n)
+		sub := w.SubEncoder(n)
+		if err := sub.reader.err; err != nil {
+			return err
+		}
+		if err := value.Encode(&sub); err != nil {
+			return err
+		}
+		if err := sub.Err(); err != nil {
+			return err
+		}
+		if sub.Len() != n {
+			return WriteError{message: "msgpack: embedded value encoded to " + strconv.FormatUint(uint64(sub.Len()), 10) +
+				" bytes but sized to " + strconv.FormatUint(uint64(n), 10)}
+		}
+		return nil
+	}
+
+	options := encOptionsOf(w)
+	sizer := Sizer{options: options}
+	if err := value.Encode(&sizer); err != nil {
+		return err
+	}
+	encoder := NewEncoder(make([]byte, sizer.Len()))
+	encoder.options = options
+	if err := value.Encode(&encoder); err != nil {
+		return err
+	}
+	if err := encoder.Err(); err != nil {
+		return err
+	}
+	w.WriteByteArray(encoder.Bytes())
+	return w.Err()
+}
+
+// SizeEmbedded adds the size WriteEmbedded writes for `value`.
+func SizeEmbedded(s *Sizer, value Encodable) error {
+	inner := Sizer{options: s.options}
+	if err := value.Encode(&inner); err != nil {
+		return err
+	}
+	s.length++
+	s.writeBinLength(inner.Len())
+	s.length += inner.Len()
+	return nil
+}
+
+// ReadEmbedded reads a document written by WriteEmbedded and decodes it
+// into `target` with a decoder limited to the document. The document must
+// hold exactly one value. Errors from the document are wrapped in an
+// EmbeddedError when `r` is a Decoder.
+func ReadEmbedded(r Reader, target Decodable) error {
+	d, isDecoder := r.(*Decoder)
+	payload, err := r.ReadByteArray()
+	if err != nil {
+		return err
+	}
+	inner := NewDecoder(payload)
+	if isDecoder {
+		inner.options = d.options
+	}
+	err = checkDecoded(&inner, target.Decode(&inner))
+	if err == nil {
+		err = inner.ExpectEOF()
+	}
+	if err != nil && isDecoder {
+		return EmbeddedError{Offset: d.Offset() - uint32(len(payload)), Err: err}
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package msgpack_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func envelope(w msgpack.Writer, person *Person) error {
+	w.WriteMapSize(2)
+	w.WriteString("type")
+	w.WriteString("person")
+	w.WriteString("body")
+	return msgpack.WriteEmbedded(w, personCodec.Bind(person))
+}
+
+func TestEmbeddedRoundTrip(t *testing.T) {
+	person := Person{Name: "Ada", Age: 36, Tags: []string{"math"}, Metadata: msgpack.Raw{0xc0}}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, envelope(w, &person))
+	})
+
+	// The body is a bin holding the encoded person.
+	body := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, personCodec.Encode(w, &person))
+	})
+	assert.Equal(t, byte(0xc4), data[len(data)-len(body)-2])
+	assert.Equal(t, body, data[len(data)-len(body):])
+
+	decoder := msgpack.NewDecoder(data)
+	_, err := decoder.ReadMapSize()
+	require.NoError(t, err)
+	require.NoError(t, decoder.Skip())
+	require.NoError(t, decoder.Skip())
+	key, err := decoder.ReadString()
+	require.NoError(t, err)
+	require.Equal(t, "body", key)
+	var decoded Person
+	require.NoError(t, msgpack.ReadEmbedded(&decoder, personCodec.Bind(&decoded)))
+	assert.Equal(t, person, decoded)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+
+	// A reader that does not know the schema sees an opaque bin.
+	decoder = msgpack.NewDecoder(data)
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, body, v.(map[any]any)["body"])
+}
+
+func TestEmbeddedUpperBoundSizer(t *testing.T) {
+	person := Person{Name: "Ada"}
+	var sizer msgpack.UpperBoundSizer
+	require.NoError(t, envelope(&sizer, &person))
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, envelope(w, &person))
+	})
+	assert.GreaterOrEqual(t, sizer.Len(), uint32(len(data)))
+}
+
+func TestEmbeddedBufferTooSmall(t *testing.T) {
+	person := Person{Name: "Ada"}
+	encoder := msgpack.NewEncoder(make([]byte, 4))
+	err := msgpack.WriteEmbedded(&encoder, personCodec.Bind(&person))
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
+
+func TestEmbeddedCorrupted(t *testing.T) {
+	person := Person{Name: "Ada", Age: 36}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, envelope(w, &person))
+	})
+	// Cut the inner document short without touching the bin header.
+	inner := len(data) - 1
+	data[inner] = 0xd9 // str8 with its length byte missing
+
+	decoder := msgpack.NewDecoder(data)
+	_, err := decoder.ReadMapSize()
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, decoder.Skip())
+	}
+	start := decoder.Offset() + 2
+	var decoded Person
+	err = msgpack.ReadEmbedded(&decoder, personCodec.Bind(&decoded))
+	var embedded msgpack.EmbeddedError
+	require.ErrorAs(t, err, &embedded)
+	assert.Equal(t, start, embedded.Offset)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.Contains(t, err.Error(), "embedded document at offset 20")
+}
+
+func TestEmbeddedTrailingBytes(t *testing.T) {
+	encoder := msgpack.NewEncoder(make([]byte, 3))
+	encoder.WriteByteArray([]byte{0x80})
+	data := append(encoder.Bytes()[:1:1], 0x02, 0x80, 0xc0)
+
+	decoder := msgpack.NewDecoder(data)
+	var decoded Address
+	err := msgpack.ReadEmbedded(&decoder, addressCodec.Bind(&decoded))
+	var embedded msgpack.EmbeddedError
+	require.True(t, errors.As(err, &embedded))
+	assert.EqualError(t, embedded.Err, "msgpack: 1 trailing bytes at offset 1")
+}
+
+func BenchmarkEmbedded(b *testing.B) {
+	person := Person{Name: "Ada", Age: 36, Tags: []string{"math", "engines"}}
+	var sizer msgpack.Sizer
+	if err := envelope(&sizer, &person); err != nil {
+		b.Fatal(err)
+	}
+	buffer := make([]byte, sizer.Len())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		encoder := msgpack.NewEncoder(buffer)
+		if err := envelope(&encoder, &person); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEmbeddedNoIntermediateBuffer(t *testing.T) {
+	person := Person{Name: "Ada", Age: 36, Tags: []string{"math", "engines"}}
+	var sizer msgpack.Sizer
+	require.NoError(t, envelope(&sizer, &person))
+	buffer := make([]byte, sizer.Len())
+	codec := personCodec.Bind(&person)
+
+	direct := testing.AllocsPerRun(100, func() {
+		encoder := msgpack.NewEncoder(buffer)
+		_ = codec.Encode(&encoder)
+	})
+	embedded := testing.AllocsPerRun(100, func() {
+		encoder := msgpack.NewEncoder(buffer)
+		_ = msgpack.WriteEmbedded(&encoder, codec)
+	})
+	t.Logf("direct: %v allocs, embedded: %v allocs", direct, embedded)
+	// The sizing pass and sub-encoder may escape, but nothing scales with
+	// the size of the embedded document.
+	assert.LessOrEqual(t, embedded, direct+2)
+}
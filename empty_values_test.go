@@ -0,0 +1,100 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Empty strings and bin values in every format the readers accept.
+var emptyStrings = [][]byte{
+	{0xa0},
+	{msgpack.FormatString8, 0},
+	{msgpack.FormatString16, 0, 0},
+	{msgpack.FormatString32, 0, 0, 0, 0},
+}
+
+var emptyBins = [][]byte{
+	{msgpack.FormatBin8, 0},
+	{msgpack.FormatBin16, 0, 0},
+	{msgpack.FormatBin32, 0, 0, 0, 0},
+}
+
+func TestEmptyValuesDoNotAllocate(t *testing.T) {
+	for _, data := range emptyStrings {
+		allocs := testing.AllocsPerRun(100, func() {
+			decoder := msgpack.NewDecoder(data)
+			s, err := decoder.ReadString()
+			if err != nil || s != "" {
+				t.Fatal(s, err)
+			}
+			decoder = msgpack.NewDecoder(data)
+			if b, err := decoder.ReadStringBytes(); err != nil || len(b) != 0 {
+				t.Fatal(b, err)
+			}
+			decoder = msgpack.NewDecoder(data)
+			if v, err := decoder.ReadAny(); err != nil || v != "" {
+				t.Fatal(v, err)
+			}
+		})
+		assert.Zero(t, allocs, "% x", data)
+	}
+	for _, data := range emptyBins {
+		allocs := testing.AllocsPerRun(100, func() {
+			decoder := msgpack.NewDecoder(data)
+			if b, err := decoder.ReadByteArray(); err != nil || len(b) != 0 {
+				t.Fatal(b, err)
+			}
+			decoder = msgpack.NewDecoder(data)
+			if b, isNil, err := decoder.ReadNillableByteArrayStrict(); err != nil || isNil || b == nil {
+				t.Fatal(b, err)
+			}
+			decoder = msgpack.NewDecoder(data)
+			if v, err := decoder.ReadAny(); err != nil || len(v.([]byte)) != 0 {
+				t.Fatal(v, err)
+			}
+		})
+		assert.Zero(t, allocs, "% x", data)
+	}
+}
+
+// Empty bin values have no capacity, so appending to one cannot write into
+// the input buffer or into another decoded value.
+func TestEmptyBinHasNoCapacity(t *testing.T) {
+	data := []byte{msgpack.FormatBin8, 0, 0xa1, 'x'}
+	decoder := msgpack.NewDecoder(data)
+	b, err := decoder.ReadByteArray()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cap(b))
+	assert.NotNil(t, b)
+	b = append(b, 'y')
+	assert.Equal(t, []byte{msgpack.FormatBin8, 0, 0xa1, 'x'}, data)
+
+	decoder = msgpack.NewDecoder(data)
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cap(v.([]byte)))
+	decoder = msgpack.NewDecoder(data)
+	again, err := decoder.ReadByteArray()
+	require.NoError(t, err)
+	assert.Empty(t, again, "appending to an empty value does not change the next one")
+
+	s, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "x", s)
+}
+
+func TestEmptyStringKeys(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("")
+		w.WriteString("")
+	})
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithStringTableDecoding(stringTableExt))
+	m, err := msgpack.ReadFilteredMap(&decoder, func(string) bool { return true }, msgpack.Reader.ReadString)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"": ""}, m)
+}
@@ -0,0 +1,82 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type notePayload struct {
+	Text string
+}
+
+func (p *notePayload) Encode(w msgpack.Writer) error {
+	w.WriteString(p.Text)
+	return w.Err()
+}
+
+func (p *notePayload) Decode(r msgpack.Reader) (err error) {
+	p.Text, err = r.ReadString()
+	return err
+}
+
+type levelPayload int
+
+func (l levelPayload) Encode(w msgpack.Writer) error {
+	w.WriteInt64(int64(l))
+	return w.Err()
+}
+
+type envelopeMessage struct {
+	Payload msgpack.Encodable
+}
+
+func TestWriteEncodableOrNil(t *testing.T) {
+	var typedNil *notePayload
+	tests := []struct {
+		name     string
+		value    msgpack.Encodable
+		expected []byte
+	}{
+		{"nil interface", nil, []byte{msgpack.FormatNil}},
+		{"typed nil pointer", typedNil, []byte{msgpack.FormatNil}},
+		{"pointer", &notePayload{Text: "hi"}, []byte{0xa2, 'h', 'i'}},
+		{"zero value type", levelPayload(0), []byte{0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeWith(t, func(w msgpack.Writer) {
+				require.NoError(t, msgpack.WriteEncodableOrNil(w, tt.value))
+			})
+			assert.Equal(t, tt.expected, data)
+		})
+	}
+
+	// A typed nil pointer is not equal to nil and panics in Encode.
+	message := envelopeMessage{Payload: typedNil}
+	require.True(t, message.Payload != nil)
+	assert.Panics(t, func() {
+		var sizer msgpack.Sizer
+		_ = message.Payload.Encode(&sizer)
+	})
+}
+
+func TestDecodeIfPresent(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{msgpack.FormatNil, 0xa2, 'h', 'i', 0x01})
+
+	var message envelopeMessage
+	assign := func(p *notePayload) { message.Payload = p }
+	require.NoError(t, msgpack.DecodeIfPresent(&decoder, assign))
+	assert.Nil(t, message.Payload)
+
+	require.NoError(t, msgpack.DecodeIfPresent(&decoder, assign))
+	assert.Equal(t, &notePayload{Text: "hi"}, message.Payload)
+
+	message.Payload = nil
+	err := msgpack.DecodeIfPresent(&decoder, assign)
+	assert.EqualError(t, err, "bad prefix for string length: got fixint(1) (0x01) at offset 4")
+	assert.Nil(t, message.Payload)
+}
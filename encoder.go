@@ -7,7 +7,9 @@ import (
 )
 
 type Encoder struct {
-	reader DataReader
+	reader  DataReader
+	options encOptions
+	strings stringTableWriter
 }
 
 func NewEncoder(buffer []byte) Encoder {
@@ -16,6 +18,35 @@ func NewEncoder(buffer []byte) Encoder {
 	}
 }
 
+// Len returns the number of bytes written so far.
+func (e *Encoder) Len() uint32 {
+	return e.reader.byteOffset
+}
+
+// Bytes returns the portion of the buffer written so far.
+func (e *Encoder) Bytes() []byte {
+	return e.reader.buffer[:e.reader.byteOffset]
+}
+
+// Remaining returns the number of bytes that can still be written before the
+// buffer is full.
+func (e *Encoder) Remaining() uint32 {
+	return e.reader.Remaining()
+}
+
+// Fits reports whether n more bytes can be written without a range error.
+func (e *Encoder) Fits(n uint32) bool {
+	return e.reader.err == nil && n <= e.reader.Remaining()
+}
+
+// fits checks that the `n` bytes of a value can be written before any of
+// them are, recording a RangeError for the whole value otherwise. Values
+// made of a header and a payload are checked with it so that a value that
+// does not fit leaves the buffer and the offset as they were.
+func (e *Encoder) fits(n uint32) bool {
+	return e.reader.checkBufferSize(n) == nil
+}
+
 func (e *Encoder) WriteNil() {
 	e.reader.SetUint8(FormatNil)
 }
@@ -29,7 +60,7 @@ func (e *Encoder) WriteBool(value bool) {
 }
 
 func (e *Encoder) WriteNillableBool(value *bool) {
-	if value == nil {
+	if value == nil || e.options.omitZero && !*value {
 		e.WriteNil()
 	} else {
 		e.WriteBool(*value)
@@ -41,7 +72,7 @@ func (e *Encoder) WriteInt8(value int8) {
 }
 
 func (e *Encoder) WriteNillableInt8(value *int8) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteInt8(*value)
@@ -53,7 +84,7 @@ func (e *Encoder) WriteInt16(value int16) {
 }
 
 func (e *Encoder) WriteNillableInt16(value *int16) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteInt16(*value)
@@ -65,7 +96,7 @@ func (e *Encoder) WriteInt32(value int32) {
 }
 
 func (e *Encoder) WriteNillableInt32(value *int32) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteInt32(*value)
@@ -78,22 +109,18 @@ func (e *Encoder) WriteInt64(value int64) {
 	} else if value < 0 && value >= -(1<<5) {
 		e.reader.SetUint8(uint8(value) | FormatNegativeFixInt)
 	} else if value <= math.MaxInt8 && value >= math.MinInt8 {
-		e.reader.SetUint8(FormatInt8)
-		e.reader.SetInt8(int8(value))
+		e.write1(FormatInt8, uint8(value))
 	} else if value <= math.MaxInt16 && value >= math.MinInt16 {
-		e.reader.SetUint8(FormatInt16)
-		e.reader.SetInt16(int16(value))
+		e.write2(FormatInt16, uint16(value))
 	} else if value <= math.MaxInt32 && value >= math.MinInt32 {
-		e.reader.SetUint8(FormatInt32)
-		e.reader.SetInt32(int32(value))
+		e.write4(FormatInt32, uint32(value))
 	} else {
-		e.reader.SetUint8(FormatInt64)
-		e.reader.SetInt64(value)
+		e.write8(FormatInt64, uint64(value))
 	}
 }
 
 func (e *Encoder) WriteNillableInt64(value *int64) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteInt64(*value)
@@ -105,7 +132,7 @@ func (e *Encoder) WriteUint8(value uint8) {
 }
 
 func (e *Encoder) WriteNillableUint8(value *uint8) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteUint8(*value)
@@ -117,7 +144,7 @@ func (e *Encoder) WriteUint16(value uint16) {
 }
 
 func (e *Encoder) WriteNillableUint16(value *uint16) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteUint16(*value)
@@ -129,7 +156,7 @@ func (e *Encoder) WriteUint32(value uint32) {
 }
 
 func (e *Encoder) WriteNillableUint32(value *uint32) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteUint32(*value)
@@ -140,22 +167,18 @@ func (e *Encoder) WriteUint64(value uint64) {
 	if value < 1<<7 {
 		e.reader.SetUint8(uint8(value))
 	} else if value <= math.MaxUint8 {
-		e.reader.SetUint8(FormatUint8)
-		e.reader.SetUint8(uint8(value))
+		e.write1(FormatUint8, uint8(value))
 	} else if value <= math.MaxUint16 {
-		e.reader.SetUint8(FormatUint16)
-		e.reader.SetUint16(uint16(value))
+		e.write2(FormatUint16, uint16(value))
 	} else if value <= math.MaxUint32 {
-		e.reader.SetUint8(FormatUint32)
-		e.reader.SetUint32(uint32(value))
+		e.write4(FormatUint32, uint32(value))
 	} else {
-		e.reader.SetUint8(FormatUint64)
-		e.reader.SetUint64(value)
+		e.write8(FormatUint64, value)
 	}
 }
 
 func (e *Encoder) WriteNillableUint64(value *uint64) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteUint64(*value)
@@ -163,12 +186,11 @@ func (e *Encoder) WriteNillableUint64(value *uint64) {
 }
 
 func (e *Encoder) WriteFloat32(value float32) {
-	e.reader.SetUint8(FormatFloat32)
-	e.reader.SetFloat32(value)
+	e.write4(FormatFloat32, math.Float32bits(value))
 }
 
 func (e *Encoder) WriteNillableFloat32(value *float32) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteFloat32(*value)
@@ -176,12 +198,11 @@ func (e *Encoder) WriteNillableFloat32(value *float32) {
 }
 
 func (e *Encoder) WriteFloat64(value float64) {
-	e.reader.SetUint8(FormatFloat64)
-	e.reader.SetFloat64(value)
+	e.write8(FormatFloat64, math.Float64bits(value))
 }
 
 func (e *Encoder) WriteNillableFloat64(value *float64) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteFloat64(*value)
@@ -192,25 +213,39 @@ func (e *Encoder) writeStringLength(length uint32) {
 	if length < 32 {
 		e.reader.SetUint8(uint8(length) | FormatFixString)
 	} else if length <= math.MaxUint8 {
-		e.reader.SetUint8(FormatString8)
-		e.reader.SetUint8(uint8(length))
+		e.write1(FormatString8, uint8(length))
 	} else if length <= math.MaxUint16 {
-		e.reader.SetUint8(FormatString16)
-		e.reader.SetUint16(uint16(length))
+		e.write2(FormatString16, uint16(length))
 	} else {
-		e.reader.SetUint8(FormatString32)
-		e.reader.SetUint32(length)
+		e.write4(FormatString32, length)
 	}
 }
 
 func (e *Encoder) WriteString(value string) {
-	valueBytes := UnsafeBytes(value)
-	e.writeStringLength(uint32(len(valueBytes)))
-	e.reader.SetBytes(valueBytes)
+	length := uint32(len(value))
+	if e.options.stringTable {
+		seen := len(e.strings.order)
+		index, ok := e.strings.ref(value)
+		if ok {
+			if e.fits(stringRefSize(index)) {
+				e.writeStringRef(index)
+			}
+			return
+		}
+		if !e.fits(stringSize(length)) {
+			// A string that is not written must not be referred to.
+			e.strings.truncate(seen)
+			return
+		}
+	} else if !e.fits(stringSize(length)) {
+		return
+	}
+	e.writeStringLength(length)
+	e.reader.SetBytes(UnsafeBytes(value))
 }
 
 func (e *Encoder) WriteNillableString(value *string) {
-	if value == nil {
+	if value == nil || e.options.omitZero && *value == "" {
 		e.WriteNil()
 	} else {
 		e.WriteString(*value)
@@ -220,13 +255,22 @@ func (e *Encoder) WriteNillableString(value *string) {
 func (e *Encoder) WriteTime(tm time.Time) {
 	var timeBuf [12]byte
 	b := e.encodeTime(tm, timeBuf[:])
+	// The 4 and 8 byte forms are fixext values, and the 12 byte form an
+	// ext8 with its length byte.
+	size := 2 + uint32(len(b))
+	if len(b) == 12 {
+		size++
+	}
+	if !e.fits(size) {
+		return
+	}
 	e.encodeExtLen(len(b))
 	e.reader.SetInt8(-1)
 	e.reader.SetBytes(b)
 }
 
 func (e *Encoder) WriteNillableTime(value *time.Time) {
-	if value == nil {
+	if value == nil || e.options.omitZero && value.IsZero() {
 		e.WriteNil()
 	} else {
 		e.WriteTime(*value)
@@ -257,22 +301,31 @@ func (e *Encoder) encodeTime(tm time.Time, timeBuf []byte) []byte {
 
 func (e *Encoder) writeBinLength(length uint32) {
 	if length <= math.MaxUint8 {
-		e.reader.SetUint8(FormatBin8)
-		e.reader.SetUint8(uint8(length))
+		e.write1(FormatBin8, uint8(length))
 	} else if length <= math.MaxUint16 {
-		e.reader.SetUint8(FormatBin16)
-		e.reader.SetUint16(uint16(length))
+		e.write2(FormatBin16, uint16(length))
 	} else {
-		e.reader.SetUint8(FormatBin32)
-		e.reader.SetUint32(length)
+		e.write4(FormatBin32, length)
+	}
+}
+
+// binSize returns the size of a bin value of `length` bytes.
+func binSize(length uint32) uint32 {
+	if length <= math.MaxUint8 {
+		return 2 + length
+	} else if length <= math.MaxUint16 {
+		return 3 + length
 	}
+	return 5 + length
 }
 
 func (e *Encoder) WriteByteArray(value []byte) {
 	valueLen := uint32(len(value))
 	if valueLen == 0 {
-		e.reader.SetUint8(FormatBin8)
-		e.reader.SetUint8(0)
+		e.write1(FormatBin8, 0)
+		return
+	}
+	if !e.fits(binSize(valueLen)) {
 		return
 	}
 	e.writeBinLength(valueLen)
@@ -280,7 +333,7 @@ func (e *Encoder) WriteByteArray(value []byte) {
 }
 
 func (e *Encoder) WriteNillableByteArray(value []byte) {
-	if value == nil {
+	if value == nil || e.options.omitZero && len(value) == 0 {
 		e.WriteNil()
 	} else {
 		e.WriteByteArray(value)
@@ -288,33 +341,61 @@ func (e *Encoder) WriteNillableByteArray(value []byte) {
 }
 
 func (e *Encoder) WriteArraySize(length uint32) {
+	if !e.checkContainerLen("array", length, 1) {
+		return
+	}
 	if length < 16 {
 		e.reader.SetUint8(uint8(length) | FormatFixArray)
 	} else if length <= math.MaxUint16 {
-		e.reader.SetUint8(FormatArray16)
-		e.reader.SetUint16(uint16(length))
+		e.write2(FormatArray16, uint16(length))
 	} else {
-		e.reader.SetUint8(FormatArray32)
-		e.reader.SetUint32(length)
+		e.write4(FormatArray32, length)
 	}
 }
 
 func (e *Encoder) WriteMapSize(length uint32) {
+	if !e.checkContainerLen("map", length, 2) {
+		return
+	}
 	if length < 16 {
 		e.reader.SetUint8(uint8(length) | FormatFixMap)
 	} else if length <= math.MaxUint16 {
-		e.reader.SetUint8(FormatMap16)
-		e.reader.SetUint16(uint16(length))
+		e.write2(FormatMap16, uint16(length))
 	} else {
-		e.reader.SetUint8(FormatMap32)
-		e.reader.SetUint32(length)
+		e.write4(FormatMap32, length)
 	}
 }
 
-func (e *Encoder) WriteAny(value any) {
-	if value == nil {
-		e.WriteNil()
+// checkContainerLen records an error when `length` elements of at least
+// `minSize` bytes each cannot be written to the rest of the buffer after
+// the header, and reports whether the header may be written. It runs
+// before the header, so that a failed check writes nothing.
+func (e *Encoder) checkContainerLen(kind string, length, minSize uint32) bool {
+	if !e.options.containerLenCheck || e.reader.err != nil || length == 0 {
+		return true
+	}
+	header := containerHeaderSize(length)
+	remaining := e.reader.Remaining()
+	if header > remaining {
+		// Writing the header records the range error.
+		return true
+	}
+	e.reader.err = e.options.checkContainerLen(kind, length, minSize, e.reader.byteOffset+header, uint64(remaining-header))
+	return e.reader.err == nil
+}
+
+// containerHeaderSize returns the size of the header of an array or map
+// of `length` elements.
+func containerHeaderSize(length uint32) uint32 {
+	if length < 16 {
+		return 1
+	} else if length <= math.MaxUint16 {
+		return 3
 	}
+	return 5
+}
+
+func (e *Encoder) WriteAny(value any) {
 	switch v := value.(type) {
 	case nil:
 		e.WriteNil()
@@ -348,8 +429,16 @@ func (e *Encoder) WriteAny(value any) {
 		e.WriteFloat64(v)
 	case string:
 		e.WriteString(v)
+	case time.Time:
+		e.WriteTime(v)
+	case Raw:
+		e.WriteNillableRaw(v)
 	case []byte:
 		e.WriteByteArray(v)
+	case [16]byte:
+		e.WriteByteArray(v[:])
+	case [32]byte:
+		e.WriteByteArray(v[:])
 	case []interface{}:
 		size := uint32(len(v))
 		e.WriteArraySize(size)
@@ -362,6 +451,12 @@ func (e *Encoder) WriteAny(value any) {
 		for _, v := range v {
 			e.WriteString(v)
 		}
+	case []time.Time:
+		size := uint32(len(v))
+		e.WriteArraySize(size)
+		for _, v := range v {
+			e.WriteTime(v)
+		}
 	case []bool:
 		size := uint32(len(v))
 		e.WriteArraySize(size)
@@ -425,89 +520,35 @@ func (e *Encoder) WriteAny(value any) {
 		}
 
 	case map[string]string:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteString(k)
-			e.WriteString(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedStringMaps, e.WriteString, e.WriteString)
 	case map[string]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteString(k)
-			e.WriteAny(v)
-		}
+		e.WriteStringAnyMap(v)
+	case map[string]time.Time:
+		writeKeyedMap(e, v, e.options.sortedStringMaps, e.WriteString, e.WriteTime)
+	case map[int]string:
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k int) { e.WriteInt64(int64(k)) }, e.WriteString)
 	case map[int]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteInt64(int64(k))
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k int) { e.WriteInt64(int64(k)) }, e.WriteAny)
 	case map[int8]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteInt8(k)
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k int8) { e.WriteInt8(k) }, e.WriteAny)
 	case map[int16]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteInt16(k)
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k int16) { e.WriteInt16(k) }, e.WriteAny)
 	case map[int32]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteInt32(k)
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k int32) { e.WriteInt32(k) }, e.WriteAny)
+	case map[int64]string:
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k int64) { e.WriteInt64(k) }, e.WriteString)
 	case map[int64]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteInt64(k)
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k int64) { e.WriteInt64(k) }, e.WriteAny)
 	case map[uint]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteUint64(uint64(k))
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k uint) { e.WriteUint64(uint64(k)) }, e.WriteAny)
 	case map[uint8]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteUint8(k)
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k uint8) { e.WriteUint8(k) }, e.WriteAny)
 	case map[uint16]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteUint16(k)
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k uint16) { e.WriteUint16(k) }, e.WriteAny)
 	case map[uint32]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteUint32(k)
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k uint32) { e.WriteUint32(k) }, e.WriteAny)
 	case map[uint64]interface{}:
-		size := uint32(len(v))
-		e.WriteMapSize(size)
-		for k, v := range v {
-			e.WriteUint64(k)
-			e.WriteAny(v)
-		}
+		writeKeyedMap(e, v, e.options.sortedIntMaps, func(k uint64) { e.WriteUint64(k) }, e.WriteAny)
 	case map[interface{}]interface{}:
 		size := uint32(len(v))
 		e.WriteMapSize(size)
@@ -515,6 +556,8 @@ func (e *Encoder) WriteAny(value any) {
 			e.WriteAny(k)
 			e.WriteAny(v)
 		}
+	default:
+		writeReflectedAny(e, value)
 	}
 }
 
@@ -565,6 +608,39 @@ func (e *Encoder) write4(code byte, n uint32) error {
 	return e.reader.SetBytes(buf[:])
 }
 
+func (e *Encoder) write8(code byte, n uint64) error {
+	var buf [9]byte
+	buf[0] = code
+	binary.BigEndian.PutUint64(buf[1:], n)
+	return e.reader.SetBytes(buf[:])
+}
+
+// WriteStringAnyMap writes `value` as a map with string keys, writing each
+// value with WriteAny.
+func (e *Encoder) WriteStringAnyMap(value map[string]any) {
+	writeKeyedMap(e, value, e.options.sortedStringMaps, e.WriteString, e.WriteAny)
+}
+
+// Err returns the first error of the writes so far. Writes after an error
+// do nothing. A value that does not fit in the buffer is a RangeError and
+// writes none of its bytes, so Bytes always ends with the last value that
+// fit, and the message can be continued in a larger buffer or after a
+// Rollback.
 func (e *Encoder) Err() error {
 	return e.reader.Err()
 }
+
+// WriteError is the error of a value that cannot be written, such as a
+// value that fails the check of WriteChecked, whose error it wraps.
+type WriteError struct {
+	message string
+	err     error
+}
+
+func (e WriteError) Error() string {
+	return e.message
+}
+
+func (e WriteError) Unwrap() error {
+	return e.err
+}
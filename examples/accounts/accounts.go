@@ -0,0 +1,371 @@
+// Package accounts is a guest that serves sign-ups over msgpack-RPC. Its
+// clients span several releases, so it reads what older ones send, such
+// as numbers where strings are now expected, and settles on the encoding
+// features to use with each in a handshake. The host pads messages with
+// nil bytes to its block size.
+//
+// The methods are:
+//
+//	hello [features]       the features both sides support
+//	signup [user, token]   the user as stored, after checking it
+//	verify [token]         whether the token is the API token
+//
+// and the notification
+//
+//	log [level, message]
+//
+// which is answered with an empty payload. Failed calls are answered with
+// an error {"code": "...", "message": "..."}, which may also hold the
+// path of the field that failed a check, or a description of the value
+// found where another kind was expected.
+package accounts
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Roles of users, written as their index, and as strings by older
+// clients.
+var Roles = msgpack.NewStringEnum("member", "moderator", "admin")
+
+// adminToken is the token that allows signing up admins. A real guest
+// would be configured with it by the host.
+const adminToken = "s3cr3t-admin"
+
+// apiToken is the token verify checks.
+var apiToken = []byte("s3cr3t-api")
+
+// User is a user to sign up.
+type User struct {
+	Name  string
+	Age   uint8
+	Email string
+	// Zip is empty for users outside of the postal areas served.
+	Zip  string
+	Role string
+}
+
+// Encode writes the user, checking each field. The errors name the field.
+func (u *User) Encode(w msgpack.Writer) error {
+	msgpack.WriteMapFromPairs(w,
+		msgpack.Pair("name", func(w msgpack.Writer) { msgpack.WriteStringNonEmpty(w, u.Name, "name") }),
+		msgpack.Pair("age", func(w msgpack.Writer) { msgpack.WriteInt64InRange(w, int64(u.Age), 13, 150, "age") }),
+		msgpack.Pair("email", func(w msgpack.Writer) {
+			msgpack.WriteStringMatching(w, u.Email, isEmail, "an email address")
+		}),
+		msgpack.PairIf(u.Zip != "", "zip", func(w msgpack.Writer) {
+			msgpack.WriteChecked(w, u.Zip, checkZip, msgpack.Writer.WriteString, "zip")
+		}),
+		msgpack.Pair("role", func(w msgpack.Writer) {
+			msgpack.WriteChecked(w, u.Role, checkRole, writeRole, "role")
+		}),
+	)
+	return w.Err()
+}
+
+// Decode reads a user. Only the name and email are required.
+func (u *User) Decode(r msgpack.Reader) error {
+	u.Role = "member"
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "name":
+			u.Name, err = r.ReadString()
+		case "age":
+			u.Age, err = r.ReadUint8()
+		case "email":
+			u.Email, err = r.ReadString()
+		case "zip":
+			u.Zip, err = r.ReadString()
+		case "role":
+			u.Role, err = Roles.Read(r)
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isEmail(s string) bool {
+	at := strings.IndexByte(s, '@')
+	return at > 0 && strings.IndexByte(s[at+1:], '.') > 0
+}
+
+// errZip is the error of a zip code that is not five digits.
+var errZip = errors.New("must be five digits")
+
+func checkZip(zip string) error {
+	if len(zip) != 5 || strings.Trim(zip, "0123456789") != "" {
+		return errZip
+	}
+	return nil
+}
+
+var errRole = errors.New("no such role")
+
+func checkRole(role string) error {
+	for _, value := range Roles.Values() {
+		if role == value {
+			return nil
+		}
+	}
+	return errRole
+}
+
+func writeRole(w msgpack.Writer, role string) {
+	Roles.Write(w, role)
+}
+
+// integralFloats returns a ReadHook that reads whole floats up to `max`
+// where an unsigned integer is expected, as clients in JavaScript send
+// them.
+func integralFloats(max float64) msgpack.ReadHook {
+	return func(requested, actual msgpack.ValueKind, r msgpack.ReadHookReader, result *msgpack.ReadHookResult) (bool, error) {
+		if requested != msgpack.KindUint || actual != msgpack.KindFloat {
+			return false, nil
+		}
+		f, err := r.ReadFloat64()
+		if err != nil || f < 0 || f > max || f != math.Trunc(f) {
+			return false, err
+		}
+		result.Uint = uint64(f)
+		return true, nil
+	}
+}
+
+// Call is the handler of the "rpc" operation. The payload is a request or
+// notification, followed by nil bytes.
+func Call(payload []byte) ([]byte, error) {
+	payload = msgpack.TrimNilPadding(payload)
+	decoder := msgpack.NewDecoder(payload)
+	message, err := msgpack.DecodeMessage(&decoder)
+	if err == nil {
+		err = decoder.ExpectEOF()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch message.Type {
+	case msgpack.RPCNotification:
+		if message.Method == "log" {
+			logged++
+		}
+		return nil, nil
+	case msgpack.RPCResponse:
+		return nil, unexpected(message.Type, msgpack.RPCRequest)
+	}
+
+	var result func(msgpack.Writer) error
+	switch message.Method {
+	case "hello":
+		result, err = hello(message.Params)
+	case "signup":
+		result, err = signup(message.Params)
+	case "verify":
+		result, err = verify(message.Params)
+	default:
+		err = callError{code: "unknown_method", message: "no method " + message.Method}
+	}
+	return respond(message.MsgID, result, err)
+}
+
+// logged counts the log notifications.
+var logged uint32
+
+// Logged returns the number of log notifications received.
+func Logged() uint32 {
+	return logged
+}
+
+// callError is the error of a call, as it is sent back.
+type callError struct {
+	code    string
+	message string
+	// path is the path of the field that failed a check.
+	path string
+	// found describes the value found where another kind was expected.
+	found string
+}
+
+func (e callError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func (e callError) write(w msgpack.Writer) error {
+	msgpack.WriteMapFromPairs(w,
+		msgpack.Pair("code", func(w msgpack.Writer) { w.WriteString(e.code) }),
+		msgpack.Pair("message", func(w msgpack.Writer) { w.WriteString(e.message) }),
+		msgpack.PairIf(e.path != "", "path", func(w msgpack.Writer) { w.WriteString(e.path) }),
+		msgpack.PairIf(e.found != "", "found", func(w msgpack.Writer) { w.WriteString(e.found) }),
+	)
+	return w.Err()
+}
+
+// respond returns the response to call `msgid`: `result`, or the error
+// `err` as a callError. Errors of the params are classified by what went
+// wrong.
+func respond(msgid uint32, result func(msgpack.Writer) error, err error) ([]byte, error) {
+	var (
+		call      callError
+		mismatch  msgpack.TypeMismatchError
+		truncated msgpack.RangeError
+		unchecked msgpack.UncheckedReadError
+		malformed msgpack.ReadError
+	)
+	switch {
+	case err == nil:
+		return encode(func(w msgpack.Writer) error {
+			return msgpack.EncodeResponse(w, msgid, nil, result)
+		})
+	case errors.As(err, &call):
+	case errors.As(err, &mismatch), errors.Is(err, msgpack.ErrBadPrefix):
+		call = callError{code: "bad_params", message: err.Error()}
+	case errors.As(err, &truncated):
+		call = callError{code: "truncated", message: err.Error()}
+	case errors.As(err, &unchecked):
+		call = callError{code: "internal", message: err.Error()}
+	case errors.As(err, &malformed):
+		call = callError{code: "malformed", message: err.Error()}
+	default:
+		return nil, err
+	}
+	return encode(func(w msgpack.Writer) error {
+		return msgpack.EncodeResponse(w, msgid, call.write, nil)
+	})
+}
+
+// params returns a decoder of the array of `params`, positioned at its
+// first element, which must be one of `size` elements.
+func params(params msgpack.Raw, size uint32, opts ...msgpack.DecOption) (msgpack.Decoder, error) {
+	opts = append(opts, msgpack.WithNilCollectionsAsError())
+	decoder := msgpack.NewDecoderWithOptions(params, opts...)
+	n, err := decoder.ReadArraySize()
+	if err != nil {
+		return decoder, callError{code: "bad_params",
+			message: "params must be an array, found " + msgpack.FormatName(params[0])}
+	}
+	if n != size {
+		return decoder, callError{code: "bad_params", message: "wrong number of params"}
+	}
+	return decoder, nil
+}
+
+// describe returns a callError for the param of `raw` that a read failed
+// on with `mismatch`, describing the value found.
+func describe(raw msgpack.Raw, mismatch msgpack.TypeMismatchError) error {
+	decoder := msgpack.NewDecoderAt(raw, mismatch.Offset, uint32(len(raw))-mismatch.Offset)
+	found, err := msgpack.DescribeNext(&decoder)
+	if err != nil {
+		return err
+	}
+	return callError{code: "bad_params", message: mismatch.Error(), found: found}
+}
+
+// required are the features the guest needs from a client.
+const required msgpack.Feature = msgpack.FeatureUUID | msgpack.FeatureZonedTime
+
+func hello(raw msgpack.Raw) (func(msgpack.Writer) error, error) {
+	decoder, err := params(raw, 1)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := msgpack.DecodeFeatureSet(&decoder)
+	if err != nil {
+		return nil, err
+	}
+	common, err := negotiate(remote)
+	if err != nil {
+		return nil, err
+	}
+	return common.Encode, nil
+}
+
+// negotiate returns the features the guest may use with a client that
+// supports `remote`.
+func negotiate(remote msgpack.FeatureSet) (msgpack.FeatureSet, error) {
+	common := msgpack.Negotiate(msgpack.Features(), remote)
+	if !common.Has(required) {
+		return common, callError{code: "unsupported", message: "client lacks " + (required &^ common.Features).String()}
+	}
+	return common, nil
+}
+
+func signup(raw msgpack.Raw) (func(msgpack.Writer) error, error) {
+	// Older clients send zip codes as numbers.
+	decoder, err := params(raw, 2, msgpack.WithNumberToStringCoercion())
+	if err != nil {
+		return nil, err
+	}
+	decoder.SetReadHook(integralFloats(math.MaxUint8))
+	user, err := msgpack.Decode[User](&decoder)
+	var mismatch msgpack.TypeMismatchError
+	if errors.As(err, &mismatch) {
+		return nil, describe(raw, mismatch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	token, err := decoder.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == "admin" && !msgpack.SecureCompareString(token, adminToken) {
+		return nil, callError{code: "forbidden", message: "signing up an admin needs the admin token"}
+	}
+
+	// The checks of Encode are run once, through a PathWriter, so that a
+	// failed one is reported with the path of its field.
+	var sizer msgpack.Sizer
+	checked := msgpack.NewPathWriter(&sizer)
+	user.Encode(checked)
+	var pathErr msgpack.EncodePathError
+	if err := checked.Err(); errors.As(err, &pathErr) {
+		return nil, callError{code: "invalid", message: err.Error(), path: pathErr.Path}
+	}
+	return user.Encode, nil
+}
+
+func verify(raw msgpack.Raw) (func(msgpack.Writer) error, error) {
+	// Errors are not shown for tokens, so they need not say much.
+	decoder, err := params(raw, 1, msgpack.WithTerseErrors())
+	if err != nil {
+		return nil, err
+	}
+	token, err := decoder.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	ok := msgpack.SecureCompareBytes(msgpack.UnsafeBytes(token), apiToken)
+	return func(w msgpack.Writer) error {
+		w.WriteBool(ok)
+		return w.Err()
+	}, nil
+}
+
+// encode sizes and then encodes what `write` writes.
+func encode(write func(w msgpack.Writer) error) ([]byte, error) {
+	sizer := msgpack.NewSizer()
+	if err := write(&sizer); err != nil {
+		return nil, err
+	}
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	if err := write(&encoder); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
@@ -0,0 +1,64 @@
+package accounts
+
+import (
+	"errors"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// blockSize is the block size of the host, which pads messages to a
+// multiple of it.
+const blockSize = 16
+
+// Request returns the request of call `msgid` to `method`, padded as the
+// host pads it. `params` writes the array of params.
+func Request(msgid uint32, method string, params func(w msgpack.Writer) error) ([]byte, error) {
+	return pad(func(w msgpack.Writer) error {
+		return msgpack.EncodeRequest(w, msgid, method, params)
+	})
+}
+
+// Log returns a log notification, padded as the host pads it.
+func Log(level, message string) ([]byte, error) {
+	return pad(func(w msgpack.Writer) error {
+		return msgpack.EncodeNotification(w, "log", func(w msgpack.Writer) error {
+			msgpack.WriteArrayFromFuncs(w,
+				func(w msgpack.Writer) { w.WriteString(level) },
+				func(w msgpack.Writer) { w.WriteString(message) })
+			return w.Err()
+		})
+	})
+}
+
+func pad(write func(w msgpack.Writer) error) ([]byte, error) {
+	message, err := encode(write)
+	if err != nil {
+		return nil, err
+	}
+	padded := make([]byte, (len(message)+blockSize-1)/blockSize*blockSize)
+	copy(padded, message)
+	for i := len(message); i < len(padded); i++ {
+		padded[i] = msgpack.FormatNil
+	}
+	return padded, nil
+}
+
+// Response reads the response to a call. Exactly one of its Error and
+// Result is an encoded nil.
+func Response(payload []byte) (msgpack.RPCMessage, error) {
+	decoder := msgpack.NewDecoder(payload)
+	message, err := msgpack.DecodeMessage(&decoder)
+	if err != nil {
+		return message, err
+	}
+	if message.Type != msgpack.RPCResponse {
+		return message, unexpected(message.Type, msgpack.RPCResponse)
+	}
+	return message, nil
+}
+
+// unexpected returns the error of a message of type `got` where one of
+// type `want` was expected.
+func unexpected(got, want msgpack.RPCType) error {
+	return errors.New("accounts: got a " + got.String() + " where a " + want.String() + " was expected")
+}
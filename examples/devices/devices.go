@@ -0,0 +1,349 @@
+// Package devices is a guest that keeps the records of a fleet of devices,
+// declared field by field with ObjectCodec. It accepts the records older
+// firmware sends, with numbers as strings and times as Unix seconds, and
+// answers a record with problems with every one of them at once rather
+// than with the first.
+package devices
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Location is where a device is installed.
+type Location struct {
+	Site string
+	Room string
+}
+
+// Overrides are the settings of a device that differ from those of its
+// fleet. A nil setting is inherited.
+type Overrides struct {
+	Muted       *bool
+	LogLevel    *int8
+	TempOffset  *int16
+	Zone        *int32
+	Retention   *int64
+	Channel     *uint8
+	Port        *uint16
+	SampleMS    *uint32
+	Quota       *uint64
+	Gain        *float32
+	Altitude    *float64
+	Label       *string
+	Maintenance *time.Time
+}
+
+// Device is the record of a device.
+type Device struct {
+	ID         string
+	Model      string
+	Online     bool
+	RSSI       int8
+	Temp       int16
+	TZOffset   int32
+	Uptime     int64
+	Revision   uint8
+	Port       uint16
+	Boots      uint32
+	Serial     uint64
+	Battery    float32
+	Latitude   float64
+	Installed  time.Time
+	Key        []byte
+	Config     msgpack.Raw
+	Tags       []string
+	Thresholds []int32
+	Samples    []int64
+	Location   Location
+	Overrides  *Overrides
+}
+
+// DefaultPort is the MQTT port of the devices registered before the port
+// was configurable.
+const DefaultPort = 8883
+
+var (
+	keys     = msgpack.NewKeyRegistry()
+	keyID    = keys.MustKey("id")
+	keyModel = keys.MustKey("model")
+)
+
+// The keys of the payload of "update", matched as bytes.
+var keyDevice, keyUpdate msgpack.Key = keys.MustKey("device"), keys.MustKey("update")
+
+var locationCodec = msgpack.Object[Location]().
+	Field("site", msgpack.StringField(func(l *Location) *string { return &l.Site })).
+	Field("room", msgpack.StringField(func(l *Location) *string { return &l.Room }))
+
+var overridesCodec = msgpack.Object[Overrides]().
+	Field("muted", msgpack.NillableBoolField(func(o *Overrides) **bool { return &o.Muted })).
+	Field("log_level", msgpack.NillableInt8Field(func(o *Overrides) **int8 { return &o.LogLevel })).
+	Field("temp_offset", msgpack.NillableInt16Field(func(o *Overrides) **int16 { return &o.TempOffset })).
+	Field("zone", msgpack.NillableInt32Field(func(o *Overrides) **int32 { return &o.Zone })).
+	Field("retention", msgpack.NillableInt64Field(func(o *Overrides) **int64 { return &o.Retention })).
+	Field("channel", msgpack.NillableUint8Field(func(o *Overrides) **uint8 { return &o.Channel })).
+	Field("port", msgpack.NillableUint16Field(func(o *Overrides) **uint16 { return &o.Port })).
+	Field("sample_ms", msgpack.NillableUint32Field(func(o *Overrides) **uint32 { return &o.SampleMS })).
+	Field("quota", msgpack.NillableUint64Field(func(o *Overrides) **uint64 { return &o.Quota })).
+	Field("gain", msgpack.NillableFloat32Field(func(o *Overrides) **float32 { return &o.Gain })).
+	Field("altitude", msgpack.NillableFloat64Field(func(o *Overrides) **float64 { return &o.Altitude })).
+	Field("label", msgpack.NillableStringField(func(o *Overrides) **string { return &o.Label })).
+	Field("maintenance", msgpack.NillableTimeField(func(o *Overrides) **time.Time { return &o.Maintenance }))
+
+// portField defaults the port of the records written before it existed.
+var portField msgpack.FieldCodec[Device] = msgpack.Uint16Field(func(d *Device) *uint16 { return &d.Port }).
+	Default(func(d *Device) error {
+		d.Port = DefaultPort
+		return nil
+	})
+
+var deviceCodec = msgpack.Object[Device]().
+	KeyField(keyID, msgpack.StringField(func(d *Device) *string { return &d.ID }).Required()).
+	KeyField(keyModel, msgpack.StringField(func(d *Device) *string { return &d.Model })).
+	Field("online", msgpack.BoolField(func(d *Device) *bool { return &d.Online })).
+	Field("rssi", msgpack.Int8Field(func(d *Device) *int8 { return &d.RSSI })).
+	Field("temp", msgpack.Int16Field(func(d *Device) *int16 { return &d.Temp })).
+	Field("tz_offset", msgpack.Int32Field(func(d *Device) *int32 { return &d.TZOffset })).
+	Field("uptime", msgpack.Int64Field(func(d *Device) *int64 { return &d.Uptime })).
+	Field("revision", msgpack.Uint8Field(func(d *Device) *uint8 { return &d.Revision })).
+	Field("port", portField).
+	Field("boots", msgpack.Uint32Field(func(d *Device) *uint32 { return &d.Boots })).
+	Field("serial", msgpack.Uint64Field(func(d *Device) *uint64 { return &d.Serial })).
+	Field("battery", msgpack.Float32Field(func(d *Device) *float32 { return &d.Battery })).
+	Field("latitude", msgpack.Float64Field(func(d *Device) *float64 { return &d.Latitude })).
+	Field("installed", msgpack.TimeField(func(d *Device) *time.Time { return &d.Installed })).
+	Field("key", msgpack.BytesField(func(d *Device) *[]byte { return &d.Key })).
+	Field("config", msgpack.RawField(func(d *Device) *msgpack.Raw { return &d.Config })).
+	Field("tags", msgpack.StringSliceField(func(d *Device) *[]string { return &d.Tags })).
+	Field("thresholds", msgpack.Int32SliceField(func(d *Device) *[]int32 { return &d.Thresholds })).
+	Field("samples", msgpack.Int64SliceField(func(d *Device) *[]int64 { return &d.Samples })).
+	Field("location", msgpack.ObjectField(func(d *Device) *Location { return &d.Location }, locationCodec)).
+	Field("overrides", msgpack.NillableObjectField(func(d *Device) **Overrides { return &d.Overrides }, overridesCodec))
+
+func (d *Device) Encode(w msgpack.Writer) error {
+	return deviceCodec.Encode(w, d)
+}
+
+func (d *Device) Decode(r msgpack.Reader) error {
+	return deviceCodec.Decode(r, d)
+}
+
+// Keys returns the top-level keys of the messages of this package, for
+// schema documentation.
+func Keys() []string {
+	return keys.Dump()
+}
+
+// Problem is why a field of a record was rejected.
+type Problem struct {
+	Field  string
+	Reason string
+}
+
+// Problem reasons.
+const (
+	ReasonTooLong    = "too_long"
+	ReasonOutOfRange = "out_of_range"
+	ReasonWrongType  = "wrong_type"
+	ReasonInvalid    = "invalid"
+)
+
+// Registration is the response of the "register" operation: the device as
+// stored with the sizes of the keys it was sent with that were dropped,
+// or the problems of the record.
+type Registration struct {
+	Device   *Device
+	Dropped  map[string]msgpack.SkipStats
+	Problems []Problem
+}
+
+func (r *Registration) Encode(w msgpack.Writer) error {
+	if r.Device == nil {
+		w.WriteMapSize(1)
+		w.WriteString("problems")
+		w.WriteArraySize(uint32(len(r.Problems)))
+		for _, p := range r.Problems {
+			w.WriteMapSize(2)
+			w.WriteString("field")
+			w.WriteString(p.Field)
+			w.WriteString("reason")
+			w.WriteString(p.Reason)
+		}
+		return w.Err()
+	}
+
+	w.WriteMapSize(2)
+	w.WriteString("device")
+	if err := r.Device.Encode(w); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(r.Dropped))
+	for key := range r.Dropped {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	w.WriteString("dropped")
+	w.WriteMapSize(uint32(len(keys)))
+	for _, key := range keys {
+		w.WriteString(key)
+		w.WriteUint64(r.Dropped[key].Bytes)
+	}
+	return w.Err()
+}
+
+// maxProblems bounds the problems reported for one record.
+const maxProblems = 8
+
+// decodeOptions are the options of the decoders of records. Older firmware
+// sends numbers as strings and times as Unix seconds.
+func decodeOptions(reporter *msgpack.UnknownKeyReporter) []msgpack.DecOption {
+	return []msgpack.DecOption{
+		msgpack.WithCollectErrors(maxProblems),
+		msgpack.WithUnknownKeyReporter(reporter),
+		msgpack.WithStringToNumberCoercion(),
+		msgpack.WithTimeFormatStrict(msgpack.TimeAny),
+		msgpack.WithMaxStringLen(64),
+		msgpack.WithMaxBinLen(32),
+	}
+}
+
+// dropped counts the keys dropped from the records of all calls.
+var dropped = msgpack.NewSkipAggregator(32)
+
+// Dropped returns the keys dropped from the records since the guest
+// started, the first 32 by name and the others under msgpack.OverflowKey.
+func Dropped() map[string]msgpack.SkipStats {
+	return dropped.Stats()
+}
+
+// Register is the handler of the "register" operation. The payload is a
+// device record, and the response a Registration.
+func Register(payload []byte) ([]byte, error) {
+	var reporter msgpack.UnknownKeyReporter
+	decoder := msgpack.NewDecoderWithOptions(payload, decodeOptions(&reporter)...)
+	device, err := msgpack.Decode[Device](&decoder)
+	if err == nil {
+		err = decoder.ExpectEOF()
+	}
+	var multi msgpack.MultiError
+	if errors.As(err, &multi) {
+		registration := Registration{Problems: problems(multi.Errors())}
+		return toBytes(registration.Encode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dropped.Add(&reporter)
+	registration := Registration{Device: &device, Dropped: reporter.Stats()}
+	return toBytes(registration.Encode)
+}
+
+func problems(errs []msgpack.FieldError) []Problem {
+	problems := make([]Problem, len(errs))
+	for i, err := range errs {
+		var (
+			tooLarge msgpack.ValueTooLargeError
+			overflow msgpack.OverflowError
+			mismatch msgpack.TypeMismatchError
+		)
+		reason := ReasonInvalid
+		switch {
+		case errors.As(err.Err, &tooLarge):
+			reason = ReasonTooLong
+		case errors.As(err.Err, &overflow):
+			reason = ReasonOutOfRange
+		case errors.As(err.Err, &mismatch):
+			reason = ReasonWrongType
+		}
+		problems[i] = Problem{Field: err.Field, Reason: reason}
+	}
+	return problems
+}
+
+// ErrNoDevice is returned for an update without a device.
+var ErrNoDevice = errors.New("devices: update has no device")
+
+// Update is the handler of the "update" operation. The payload is a map
+//
+//	{"device": {...}, "update": {"set": {...}, "clear": [...]}}
+//
+// of a device record and an update written by EncodeUpdate, and the
+// response the updated record. Without "update" the record is returned as
+// it is.
+func Update(payload []byte) ([]byte, error) {
+	var (
+		device    *Device
+		update    msgpack.Raw
+		reporter  msgpack.UnknownKeyReporter
+		options   = decodeOptions(&reporter)
+		decoder   = msgpack.NewDecoderWithOptions(payload, options...)
+		size, err = decoder.ReadMapSize()
+	)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := decoder.ReadStringBytes()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case msgpack.MatchKey(key, keyDevice):
+			device, err = msgpack.DecodeNillable[Device](&decoder)
+		case msgpack.MatchKey(key, keyUpdate):
+			update, err = decoder.ReadRaw()
+		default:
+			err = reporter.Skip(&decoder, string(key))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := decoder.ExpectEOF(); err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, ErrNoDevice
+	}
+
+	if update != nil {
+		decoder = msgpack.NewDecoderWithOptions(update, options...)
+		if err := deviceCodec.ApplyUpdate(&decoder, device); err != nil {
+			return nil, err
+		}
+	}
+	dropped.Add(&reporter)
+	return msgpack.ToBytes(device)
+}
+
+// EncodeUpdate returns the payload of an "update" call that sets the
+// fields named in `set` to their values in `changed` and clears the fields
+// named in `clear` of `device`.
+func EncodeUpdate(device, changed *Device, set, clear []string) ([]byte, error) {
+	return toBytes(func(w msgpack.Writer) error {
+		w.WriteMapSize(2)
+		msgpack.WriteKey(w, keyDevice)
+		if err := device.Encode(w); err != nil {
+			return err
+		}
+		msgpack.WriteKey(w, keyUpdate)
+		return deviceCodec.EncodeUpdate(w, changed, set, clear)
+	})
+}
+
+// toBytes sizes and then encodes what `write` writes.
+func toBytes(write func(w msgpack.Writer) error) ([]byte, error) {
+	sizer := msgpack.NewSizer()
+	if err := write(&sizer); err != nil {
+		return nil, err
+	}
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	if err := write(&encoder); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
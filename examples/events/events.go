@@ -0,0 +1,258 @@
+// Package events is a guest that totals the analytics events of a log:
+// schemaless maps, as decoded from JSON by the producers, which it reads
+// into map[string]any trees and inspects with the Any accessors. The log
+// is a sequence of events padded with nil bytes to the block size of the
+// host, and a corrupt event is skipped rather than failing the whole log.
+package events
+
+import (
+	"errors"
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// decodeOptions are the options of the decoders of events. Producers send
+// timestamps as RFC 3339 strings and, for some, maps keyed by numbers,
+// which the totals are keyed by strings for.
+var decodeOptions = []msgpack.DecOption{
+	msgpack.WithTrailingNilPadding(),
+	msgpack.WithMaxDepth(16),
+	msgpack.WithTimeStringDetection(),
+	msgpack.WithStringifiedMapKeys(),
+	// Only the top of "data" is looked at; deeper values stay encoded.
+	msgpack.WithAnyDepthLimit(3),
+}
+
+// encodeOptions make the totals encode the same whatever the order Go
+// iterates their maps in.
+var encodeOptions = []msgpack.EncOption{
+	msgpack.WithSortedStringMaps(),
+	msgpack.WithSortedIntMaps(),
+}
+
+// Keys of the events of older producers, which number the keys of an event
+// rather than naming them.
+const (
+	keyType = iota
+	keyData
+	keyLabels
+	keyMetrics
+)
+
+// labels are the labels totals are kept for; the others are dropped.
+var labels = map[string]bool{"region": true, "plan": true}
+
+// maxMetrics bounds the metrics read from an event.
+const maxMetrics = 8
+
+// Event is an event of a log.
+type Event struct {
+	Type    string
+	Data    map[string]any
+	Labels  map[string]string
+	Metrics map[string]float64
+}
+
+// readEvent reads an event
+//
+//	{"type": "purchase", "data": {...}, "labels": {...}, "metrics": {...}}
+//
+// whose keys may also be the numbers of the keys.
+func readEvent(r msgpack.Reader) (Event, error) {
+	var event Event
+	err := msgpack.ReadMapEntriesAny(r, func(key any, r msgpack.Reader) error {
+		var err error
+		switch key {
+		case "type", int64(keyType):
+			event.Type, err = r.ReadString()
+		case "data", int64(keyData):
+			event.Data, err = msgpack.ReadStringAnyMap(r)
+		case "labels", int64(keyLabels):
+			event.Labels, err = msgpack.ReadFilteredMap(r, func(key string) bool {
+				return labels[key]
+			}, msgpack.Reader.ReadString)
+		case "metrics", int64(keyMetrics):
+			event.Metrics, err = msgpack.ReadMapLimit(r, func(string) bool {
+				return true
+			}, msgpack.Reader.ReadFloat64, maxMetrics)
+		}
+		return err
+	})
+	return event, err
+}
+
+// Totals are the totals of the events of a log.
+type Totals struct {
+	// Types counts the events of each type.
+	Types map[string]uint32
+	// Amount is the sum of the amounts of the purchases.
+	Amount float64
+	// Users counts the events of each user.
+	Users map[int64]uint32
+	// Labels counts the events with each value of each label, keyed by
+	// "label=value".
+	Labels map[string]uint32
+	// Metrics is the sum of each metric.
+	Metrics map[string]float64
+	// Items counts the items bought under each SKU.
+	Items map[string]uint32
+	// Traced counts the events with a trace ID.
+	Traced uint32
+	// First is the time of the earliest event.
+	First time.Time
+	// Skipped counts the corrupt events.
+	Skipped uint32
+}
+
+func (t *Totals) add(event Event) {
+	// Tests do not count.
+	if test, _ := msgpack.AnyBool(event.Data["test"]); test {
+		return
+	}
+	t.Types[event.Type]++
+	if amount, ok := msgpack.AnyKey(event.Data, "amount"); ok && event.Type == "purchase" {
+		if value, ok := msgpack.AnyFloat(amount); ok {
+			t.Amount += value
+		} else if value, ok := msgpack.AnyInt(amount); ok {
+			t.Amount += float64(value)
+		}
+	}
+	if user, ok := msgpack.AnyPath(event.Data, "user", "id"); ok {
+		if id, ok := msgpack.AnyInt(user); ok {
+			t.Users[id]++
+		}
+	}
+	items := event.Data["items"]
+	for i := 0; ; i++ {
+		item, ok := msgpack.AnyIndex(items, i)
+		if !ok {
+			break
+		}
+		sku, _ := msgpack.AnyKey(item, "sku")
+		if sku, ok := msgpack.AnyString(sku); ok {
+			t.Items[sku]++
+		}
+	}
+	if trace, ok := msgpack.AnyBytes(event.Data["trace"]); ok && len(trace) == traceIDSize {
+		t.Traced++
+	}
+	if at, ok := event.Data["at"].(time.Time); ok && (t.First.IsZero() || at.Before(t.First)) {
+		t.First = at
+	}
+	for label, value := range event.Labels {
+		t.Labels[label+"="+value]++
+	}
+	for metric, value := range event.Metrics {
+		t.Metrics[metric] += value
+	}
+}
+
+// traceIDSize is the size of a trace ID.
+const traceIDSize = 16
+
+func (t *Totals) write(w msgpack.Writer) {
+	users := make(map[int64]any, len(t.Users))
+	for id, n := range t.Users {
+		users[id] = n
+	}
+	metrics := make(map[string]any, len(t.Metrics))
+	for metric, sum := range t.Metrics {
+		metrics[metric] = sum
+	}
+	w.WriteStringAnyMap(map[string]any{
+		"types":   counts(t.Types),
+		"amount":  t.Amount,
+		"users":   users,
+		"labels":  counts(t.Labels),
+		"metrics": metrics,
+		"items":   counts(t.Items),
+		"traced":  t.Traced,
+		"first":   t.First,
+		"skipped": t.Skipped,
+	})
+}
+
+// counts returns `m` as a map WriteAny writes.
+func counts(m map[string]uint32) map[string]any {
+	c := make(map[string]any, len(m))
+	for key, n := range m {
+		c[key] = n
+	}
+	return c
+}
+
+// Ingest is the handler of the "ingest" operation. The payload is a log of
+// events and the response their Totals, as a map keyed by the names of its
+// fields in lower case. Events with the "test" flag set in their data are
+// left out.
+func Ingest(payload []byte) ([]byte, error) {
+	totals := Totals{
+		Types:   map[string]uint32{},
+		Users:   map[int64]uint32{},
+		Labels:  map[string]uint32{},
+		Metrics: map[string]float64{},
+		Items:   map[string]uint32{},
+	}
+	log := msgpack.NewMultiDecoderWithOptions(payload, decodeOptions...)
+	for log.More() {
+		decoder, err := log.Next()
+		var event Event
+		if err == nil {
+			event, err = readEvent(&decoder)
+		}
+		if err == nil {
+			totals.add(event)
+			continue
+		}
+
+		// Resume at the next value that looks like an event.
+		totals.Skipped++
+		offset, err := msgpack.FindNextValidOffset(payload, log.Offset()+1, msgpack.WithMinMapEntries(2))
+		if errors.Is(err, msgpack.ErrNoValidOffset) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		log.Seek(offset)
+	}
+
+	sizer := msgpack.NewSizerWithOptions(encodeOptions...)
+	totals.write(&sizer)
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), encodeOptions...)
+	totals.write(&encoder)
+	return encoder.Bytes(), encoder.Err()
+}
+
+// Log encodes `events`, such as the values encoding/json decodes events
+// into, as a log, normalizing each so that the numbers JSON decodes as
+// floats are written as integers where they are whole. An event holding
+// a value of a type that has no MessagePack encoding is left out, and
+// counted in `dropped`.
+func Log(events []any) (log []byte, dropped int, err error) {
+	normalized := make([]any, 0, len(events))
+	for _, event := range events {
+		event, err := msgpack.NormalizeAny(event, msgpack.WithIntegralFloatsAsInt())
+		var normalizeErr msgpack.NormalizeError
+		if errors.As(err, &normalizeErr) {
+			dropped++
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		normalized = append(normalized, event)
+	}
+
+	write := func(w msgpack.Writer) {
+		for _, event := range normalized {
+			w.WriteAny(event)
+		}
+	}
+	sizer := msgpack.NewSizerWithOptions(encodeOptions...)
+	write(&sizer)
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), encodeOptions...)
+	write(&encoder)
+	return encoder.Bytes(), dropped, encoder.Err()
+}
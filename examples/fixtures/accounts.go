@@ -0,0 +1,47 @@
+package fixtures
+
+import msgpack "github.com/wapc/tinygo-msgpack"
+
+// signupRequest writes a signup call from an older client in JavaScript,
+// which sends the age as a float, the zip code as a number and the role
+// as its index, padded with nil bytes.
+func signupRequest(w msgpack.Writer) {
+	w.WriteArraySize(4)
+	w.WriteUint8(0)
+	w.WriteUint32(7)
+	w.WriteString("signup")
+	w.WriteArraySize(2)
+	w.WriteMapSize(5)
+	w.WriteString("name")
+	w.WriteString("Ada")
+	w.WriteString("age")
+	w.WriteFloat64(36)
+	w.WriteString("email")
+	w.WriteString("ada@example.com")
+	w.WriteString("zip")
+	w.WriteUint16(10115)
+	w.WriteString("role")
+	w.WriteUint8(1)
+	w.WriteString("")
+	for i := 0; i < 5; i++ {
+		w.WriteNil()
+	}
+}
+
+func signupResponse(w msgpack.Writer) {
+	w.WriteArraySize(4)
+	w.WriteUint8(1)
+	w.WriteUint32(7)
+	w.WriteNil()
+	w.WriteMapSize(5)
+	w.WriteString("name")
+	w.WriteString("Ada")
+	w.WriteString("age")
+	w.WriteUint8(36)
+	w.WriteString("email")
+	w.WriteString("ada@example.com")
+	w.WriteString("zip")
+	w.WriteString("10115")
+	w.WriteString("role")
+	w.WriteUint8(1)
+}
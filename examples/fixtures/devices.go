@@ -0,0 +1,152 @@
+package fixtures
+
+import (
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Installed is the time the device of the register call was installed.
+// Its firmware sends it in Unix seconds.
+var Installed = time.Unix(1700000000, 0).UTC()
+
+func registerRequest(w msgpack.Writer) {
+	w.WriteMapSize(10)
+	w.WriteString("id")
+	w.WriteString("th-0042")
+	w.WriteString("model")
+	w.WriteString("TH-2")
+	w.WriteString("online")
+	w.WriteBool(true)
+	w.WriteString("rssi")
+	w.WriteInt8(-67)
+	w.WriteString("serial")
+	w.WriteString("90210")
+	w.WriteString("battery")
+	w.WriteString("3.5")
+	w.WriteString("installed")
+	w.WriteInt64(Installed.Unix())
+	w.WriteString("legacy_mode")
+	w.WriteString("compat")
+	w.WriteString("location")
+	w.WriteMapSize(2)
+	w.WriteString("site")
+	w.WriteString("berlin")
+	w.WriteString("room")
+	w.WriteString("lab")
+	w.WriteString("overrides")
+	w.WriteMapSize(2)
+	w.WriteString("muted")
+	w.WriteBool(true)
+	w.WriteString("sample_ms")
+	w.WriteUint32(500)
+}
+
+// writeDevice writes the device of the register call as stored, with
+// `port`, `tags` and overrides only when `overrides` is set.
+func writeDevice(w msgpack.Writer, port uint16, tags []string, overrides bool) {
+	w.WriteMapSize(21)
+	w.WriteString("id")
+	w.WriteString("th-0042")
+	w.WriteString("model")
+	w.WriteString("TH-2")
+	w.WriteString("online")
+	w.WriteBool(true)
+	w.WriteString("rssi")
+	w.WriteInt8(-67)
+	w.WriteString("temp")
+	w.WriteInt16(0)
+	w.WriteString("tz_offset")
+	w.WriteInt32(0)
+	w.WriteString("uptime")
+	w.WriteInt64(0)
+	w.WriteString("revision")
+	w.WriteUint8(0)
+	w.WriteString("port")
+	w.WriteUint16(port)
+	w.WriteString("boots")
+	w.WriteUint32(0)
+	w.WriteString("serial")
+	w.WriteUint64(90210)
+	w.WriteString("battery")
+	w.WriteFloat32(3.5)
+	w.WriteString("latitude")
+	w.WriteFloat64(0)
+	w.WriteString("installed")
+	w.WriteTime(Installed)
+	w.WriteString("key")
+	w.WriteByteArray(nil)
+	w.WriteString("config")
+	w.WriteNil()
+	w.WriteString("tags")
+	if tags == nil {
+		w.WriteNil()
+	} else {
+		w.WriteArraySize(uint32(len(tags)))
+		for _, tag := range tags {
+			w.WriteString(tag)
+		}
+	}
+	w.WriteString("thresholds")
+	w.WriteNil()
+	w.WriteString("samples")
+	w.WriteNil()
+	w.WriteString("location")
+	w.WriteMapSize(2)
+	w.WriteString("site")
+	w.WriteString("berlin")
+	w.WriteString("room")
+	w.WriteString("lab")
+	w.WriteString("overrides")
+	if !overrides {
+		w.WriteNil()
+		return
+	}
+	w.WriteMapSize(13)
+	for _, key := range []string{
+		"muted", "log_level", "temp_offset", "zone", "retention", "channel", "port",
+		"sample_ms", "quota", "gain", "altitude", "label", "maintenance",
+	} {
+		w.WriteString(key)
+		switch key {
+		case "muted":
+			w.WriteBool(true)
+		case "sample_ms":
+			w.WriteUint32(500)
+		default:
+			w.WriteNil()
+		}
+	}
+}
+
+func registerResponse(w msgpack.Writer) {
+	w.WriteMapSize(2)
+	w.WriteString("device")
+	writeDevice(w, 8883, nil, true)
+	w.WriteString("dropped")
+	w.WriteMapSize(1)
+	w.WriteString("legacy_mode")
+	w.WriteUint64(7)
+}
+
+func updateRequest(w msgpack.Writer) {
+	w.WriteMapSize(2)
+	w.WriteString("device")
+	writeDevice(w, 8883, nil, true)
+	w.WriteString("update")
+	w.WriteMapSize(2)
+	w.WriteString("set")
+	w.WriteMapSize(2)
+	w.WriteString("port")
+	w.WriteUint16(1883)
+	w.WriteString("tags")
+	w.WriteArraySize(1)
+	w.WriteString("pilot")
+	w.WriteString("clear")
+	w.WriteArraySize(1)
+	w.WriteString("overrides")
+}
+
+func updateResponse(w msgpack.Writer) {
+	writeDevice(w, 1883, []string{"pilot"}, false)
+}
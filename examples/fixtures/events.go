@@ -0,0 +1,148 @@
+package fixtures
+
+import (
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// First is the time of the earliest event of the ingest call.
+var First = time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+// eventLog writes a log of five events, the second of which is corrupt,
+// padded with nil bytes.
+func eventLog(w msgpack.Writer) {
+	w.WriteMapSize(4)
+	w.WriteString("type")
+	w.WriteString("purchase")
+	w.WriteString("data")
+	w.WriteMapSize(5)
+	w.WriteString("user")
+	w.WriteMapSize(1)
+	w.WriteString("id")
+	w.WriteInt64(7)
+	w.WriteString("amount")
+	w.WriteFloat64(12.5)
+	w.WriteString("at")
+	w.WriteString("2023-11-14T22:15:00Z")
+	w.WriteString("items")
+	w.WriteArraySize(2)
+	w.WriteMapSize(1)
+	w.WriteString("sku")
+	w.WriteString("A1")
+	w.WriteMapSize(1)
+	w.WriteString("sku")
+	w.WriteString("B2")
+	w.WriteString("trace")
+	w.WriteByteArray(make([]byte, 16))
+	w.WriteString("labels")
+	w.WriteMapSize(2)
+	w.WriteString("region")
+	w.WriteString("eu")
+	w.WriteString("debug")
+	w.WriteString("on")
+	w.WriteString("metrics")
+	w.WriteMapSize(1)
+	w.WriteString("latency_ms")
+	w.WriteFloat64(30)
+
+	// A map of two entries whose second key is a reserved format.
+	w.WriteRawBytes([]byte{0x82, 0xa4, 't', 'y', 'p', 'e', 0xc1})
+
+	// Keys by number, and a map keyed by a number in the data.
+	w.WriteMapSize(3)
+	w.WriteInt64(0)
+	w.WriteString("signup")
+	w.WriteInt64(1)
+	w.WriteMapSize(3)
+	w.WriteString("user")
+	w.WriteMapSize(1)
+	w.WriteString("id")
+	w.WriteInt64(9)
+	w.WriteString("at")
+	w.WriteString(First.Format(time.RFC3339))
+	w.WriteString("steps")
+	w.WriteMapSize(1)
+	w.WriteInt64(1)
+	w.WriteString("email")
+	w.WriteInt64(2)
+	w.WriteMapSize(1)
+	w.WriteString("plan")
+	w.WriteString("pro")
+
+	w.WriteMapSize(3)
+	w.WriteString("type")
+	w.WriteString("purchase")
+	w.WriteString("data")
+	w.WriteMapSize(3)
+	w.WriteString("user")
+	w.WriteMapSize(1)
+	w.WriteString("id")
+	w.WriteInt64(7)
+	w.WriteString("amount")
+	w.WriteInt64(3)
+	w.WriteString("items")
+	w.WriteArraySize(1)
+	w.WriteMapSize(1)
+	w.WriteString("sku")
+	w.WriteString("A1")
+	w.WriteString("metrics")
+	w.WriteMapSize(1)
+	w.WriteString("latency_ms")
+	w.WriteFloat64(12)
+
+	w.WriteMapSize(2)
+	w.WriteString("type")
+	w.WriteString("purchase")
+	w.WriteString("data")
+	w.WriteMapSize(2)
+	w.WriteString("test")
+	w.WriteBool(true)
+	w.WriteString("amount")
+	w.WriteInt64(100)
+
+	for i := 0; i < 5; i++ {
+		w.WriteNil()
+	}
+}
+
+func eventTotals(w msgpack.Writer) {
+	// The keys are sorted.
+	w.WriteMapSize(9)
+	w.WriteString("amount")
+	w.WriteFloat64(15.5)
+	w.WriteString("first")
+	w.WriteTime(First)
+	w.WriteString("items")
+	w.WriteMapSize(2)
+	w.WriteString("A1")
+	w.WriteUint32(2)
+	w.WriteString("B2")
+	w.WriteUint32(1)
+	w.WriteString("labels")
+	w.WriteMapSize(2)
+	w.WriteString("plan=pro")
+	w.WriteUint32(1)
+	w.WriteString("region=eu")
+	w.WriteUint32(1)
+	w.WriteString("metrics")
+	w.WriteMapSize(1)
+	w.WriteString("latency_ms")
+	w.WriteFloat64(42)
+	w.WriteString("skipped")
+	w.WriteUint32(1)
+	w.WriteString("traced")
+	w.WriteUint32(1)
+	w.WriteString("types")
+	w.WriteMapSize(2)
+	w.WriteString("purchase")
+	w.WriteUint32(2)
+	w.WriteString("signup")
+	w.WriteUint32(1)
+	w.WriteString("users")
+	w.WriteMapSize(2)
+	w.WriteInt64(7)
+	w.WriteUint32(2)
+	w.WriteInt64(9)
+	w.WriteUint32(1)
+}
@@ -0,0 +1,159 @@
+// Package fixtures holds the calls the example guests are checked with,
+// shared by the self test of the guest command and the tests of the main
+// module. The messages are written value by value rather than with the
+// codecs of the examples, so that they check those codecs.
+package fixtures
+
+import (
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/examples/accounts"
+	"github.com/wapc/tinygo-msgpack/examples/devices"
+	"github.com/wapc/tinygo-msgpack/examples/events"
+	"github.com/wapc/tinygo-msgpack/examples/greeter"
+	"github.com/wapc/tinygo-msgpack/examples/meters"
+	"github.com/wapc/tinygo-msgpack/examples/objects"
+	"github.com/wapc/tinygo-msgpack/examples/passthrough"
+	"github.com/wapc/tinygo-msgpack/examples/schedule"
+	"github.com/wapc/tinygo-msgpack/examples/sensors"
+	"github.com/wapc/tinygo-msgpack/examples/settings"
+)
+
+// Call is a call of the handler of a guest operation and the response it
+// returns.
+type Call struct {
+	Operation string
+	Handle    func(payload []byte) ([]byte, error)
+	Request   []byte
+	Response  []byte
+}
+
+// Calls returns a call of each example operation.
+func Calls() []Call {
+	return []Call{
+		{"greet", greeter.Greet, encode(greetRequest), encode(greetResponse)},
+		{"summarize", sensors.Summarize, encode(sensorBatch), encode(sensorSummary)},
+		{"route", passthrough.Route, encode(envelope), encode(routedEnvelope)},
+		{"register", devices.Register, encode(registerRequest), encode(registerResponse)},
+		{"update", devices.Update, encode(updateRequest), encode(updateResponse)},
+		{"configure", settings.Configure, encode(configureRequest), encode(configureResponse)},
+		{"ingest", events.Ingest, encode(eventLog), encode(eventTotals)},
+		{"plan", schedule.Plan, encode(jobBatch), encode(jobPlan)},
+		{"stats", schedule.Stats, encode(runRecords), encode(runStats)},
+		{"rpc", accounts.Call, encode(signupRequest), encode(signupResponse)},
+		{"store", objects.Store, encode(storeRequest), encode(storeResponse)},
+		{"list", objects.List, encode(listRequest), encode(listResponse, msgpack.WithStringTable(7))},
+		{"patch", objects.Patch, encode(patchRequest), encode(patchResponse)},
+		{"collect", meters.Collect, encode(collectRequest), encode(collectResponse)},
+	}
+}
+
+// Sent is the time of the greet call.
+var Sent = time.Unix(1700000000, 250000000).UTC()
+
+func greetRequest(w msgpack.Writer) {
+	w.WriteMapSize(4)
+	w.WriteString("name")
+	w.WriteString("Ada")
+	w.WriteString("language")
+	w.WriteString("fr")
+	w.WriteString("tags")
+	w.WriteArraySize(2)
+	w.WriteString("math")
+	w.WriteString("engines")
+	w.WriteString("sent")
+	w.WriteTime(Sent)
+}
+
+func greetResponse(w msgpack.Writer) {
+	w.WriteMapSize(3)
+	w.WriteString("message")
+	w.WriteString("Bonjour, Ada!")
+	w.WriteString("tags")
+	w.WriteUint32(2)
+	w.WriteString("sent")
+	w.WriteTime(Sent)
+}
+
+// Readings returns the readings of the summarize call, in milliseconds
+// since the Unix epoch and degrees.
+func Readings() (times []int64, values []float64) {
+	return []int64{1700000000000, 1700000060000, 1700000120000, 1700000180000},
+		[]float64{20.5, 21, 19.5, 23}
+}
+
+func sensorBatch(w msgpack.Writer) {
+	times, values := Readings()
+	w.WriteArraySize(3)
+	w.WriteString("kitchen")
+	w.WriteArraySize(uint32(len(times)))
+	for _, t := range times {
+		w.WriteInt64(t)
+	}
+	w.WriteArraySize(uint32(len(values)))
+	for _, v := range values {
+		w.WriteFloat64(v)
+	}
+}
+
+func sensorSummary(w msgpack.Writer) {
+	w.WriteMapSize(7)
+	w.WriteString("sensor")
+	w.WriteString("kitchen")
+	w.WriteString("count")
+	w.WriteUint32(4)
+	w.WriteString("first")
+	w.WriteInt64(1700000000000)
+	w.WriteString("last")
+	w.WriteInt64(1700000180000)
+	w.WriteString("min")
+	w.WriteFloat64(19.5)
+	w.WriteString("max")
+	w.WriteFloat64(23)
+	w.WriteString("mean")
+	w.WriteFloat64(21)
+}
+
+func envelopeBody(w msgpack.Writer) {
+	w.WriteMapSize(2)
+	w.WriteString("invoice")
+	w.WriteUint32(1042)
+	w.WriteString("lines")
+	w.WriteArraySize(2)
+	w.WriteAny([]any{"widget", int64(3), 9.99})
+	w.WriteAny([]any{"gadget", int64(1), nil})
+}
+
+func envelope(w msgpack.Writer) {
+	w.WriteMapSize(4)
+	w.WriteString("trace")
+	w.WriteByteArray([]byte{0xde, 0xad, 0xbe, 0xef})
+	w.WriteString("body")
+	envelopeBody(w)
+	w.WriteString("hops")
+	w.WriteUint32(2)
+	w.WriteString("to")
+	w.WriteString("billing")
+}
+
+func routedEnvelope(w msgpack.Writer) {
+	w.WriteMapSize(3)
+	w.WriteString("to")
+	w.WriteString("billing")
+	w.WriteString("hops")
+	w.WriteUint32(3)
+	w.WriteString("body")
+	envelopeBody(w)
+}
+
+func encode(write func(w msgpack.Writer), opts ...msgpack.EncOption) []byte {
+	sizer := msgpack.NewSizerWithOptions(opts...)
+	write(&sizer)
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), opts...)
+	write(&encoder)
+	if err := encoder.Err(); err != nil {
+		panic(err)
+	}
+	return encoder.Bytes()
+}
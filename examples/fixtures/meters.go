@@ -0,0 +1,91 @@
+package fixtures
+
+import (
+	"encoding/binary"
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+var night = "night"
+
+// meterReadings are the readings of the collect call, the first two from
+// the rows of old meters and the others from the log.
+var meterReadings = []struct {
+	serial    string
+	at        int64
+	wattHours uint32
+	tariff    *string
+}{
+	{"MTR-0001", 1700000000, 1200, nil},
+	{"MTR-0002", 1700000060, 830, nil},
+	{"NX-17", 1700000120, 410, &night},
+	{"NX-18", 1700000180, 95, nil},
+}
+
+// meterRows returns the rows of the old meters.
+func meterRows() []byte {
+	rows := make([]byte, 32)
+	for i, reading := range meterReadings[:2] {
+		row := rows[i*16:]
+		copy(row, reading.serial)
+		binary.BigEndian.PutUint32(row[8:], uint32(reading.at))
+		binary.BigEndian.PutUint32(row[12:], reading.wattHours)
+	}
+	return rows
+}
+
+// meterLog writes the log of the new meters. The last reading has a field
+// the guest does not know.
+func meterLog(w msgpack.Writer) {
+	w.WriteArraySize(4)
+	w.WriteString("NX-17")
+	w.WriteTime(time.Unix(1700000120, 0))
+	w.WriteUint16(410)
+	w.WriteString("night")
+	w.WriteArraySize(5)
+	w.WriteString("NX-18")
+	w.WriteTime(time.Unix(1700000180, 0))
+	w.WriteUint8(95)
+	w.WriteNil()
+	w.WriteString("signal")
+}
+
+func collectRequest(w msgpack.Writer) {
+	w.WriteMapSize(2)
+	w.WriteString("rows")
+	w.WriteByteArray(meterRows())
+	w.WriteString("log")
+	w.WriteByteArray(encode(meterLog))
+}
+
+// collectResponse holds two frames: the first four readings take 78 bytes
+// in a frame, more than FrameSize, and the first three 63.
+func collectResponse(w msgpack.Writer) {
+	w.WriteMapSize(3)
+	w.WriteString("csv")
+	w.WriteString("MTR-0001,2023-11-14T22:13:20Z,1200,\n" +
+		"MTR-0002,2023-11-14T22:14:20Z,830,\n" +
+		"NX-17,2023-11-14T22:15:20Z,410,night\n" +
+		"NX-18,2023-11-14T22:16:20Z,95,\n")
+	w.WriteString("audit")
+	w.WriteString("MTR-0001 1200\nMTR-0002 830\nNX-17 410\nNX-18 95\n")
+	w.WriteString("frames")
+	w.WriteArraySize(2)
+	w.WriteByteArray(encode(meterFrame(0, 3), msgpack.CompatV01()))
+	w.WriteByteArray(encode(meterFrame(3, 4), msgpack.CompatV01()))
+}
+
+// meterFrame writes a frame of the readings from `from` to `to`.
+func meterFrame(from, to int) func(w msgpack.Writer) {
+	return func(w msgpack.Writer) {
+		w.WriteArraySize(uint32(to - from))
+		for _, reading := range meterReadings[from:to] {
+			w.WriteArraySize(4)
+			w.WriteString(reading.serial)
+			w.WriteTime(time.Unix(reading.at, 0))
+			w.WriteUint32(reading.wattHours)
+			w.WriteNillableString(reading.tariff)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package fixtures
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// catImage is the data of the photo of the store call: a run of 200 zero
+// bytes and three more.
+var catImage = append(make([]byte, 200), 1, 2, 3)
+
+func catObject(w msgpack.Writer) {
+	w.WriteMapSize(6)
+	w.WriteString("id")
+	w.WriteUint8(1)
+	w.WriteString("key")
+	w.WriteString("cat.png")
+	w.WriteString("type")
+	w.WriteString("image/png")
+	w.WriteString("data")
+	w.WriteByteArray(catImage)
+	w.WriteString("attrs")
+	w.WriteMapSize(4)
+	w.WriteString("camera")
+	w.WriteString("X100")
+	w.WriteString("flash")
+	w.WriteBool(false)
+	w.WriteString("offset")
+	w.WriteInt8(-3)
+	w.WriteString("width")
+	w.WriteUint16(640)
+	w.WriteString("meta")
+	catMeta(w)
+}
+
+func catMeta(w msgpack.Writer) {
+	w.WriteMapSize(1)
+	w.WriteString("album")
+	w.WriteString("pets")
+}
+
+func notesObject(w msgpack.Writer) {
+	w.WriteMapSize(6)
+	w.WriteString("id")
+	w.WriteUint8(2)
+	w.WriteString("key")
+	w.WriteString("notes.txt")
+	w.WriteString("type")
+	w.WriteString("text/plain")
+	w.WriteString("data")
+	w.WriteByteArray([]byte("hello"))
+	w.WriteString("attrs")
+	w.WriteMapSize(1)
+	w.WriteString("pages")
+	w.WriteUint8(1)
+	w.WriteString("meta")
+	w.WriteMapSize(0)
+}
+
+// bannerObject is rejected, as its width does not fit in 16 bits.
+func bannerObject(w msgpack.Writer) {
+	w.WriteMapSize(6)
+	w.WriteString("id")
+	w.WriteUint8(3)
+	w.WriteString("key")
+	w.WriteString("banner.png")
+	w.WriteString("type")
+	w.WriteString("image/png")
+	w.WriteString("data")
+	w.WriteByteArray([]byte{7})
+	w.WriteString("attrs")
+	w.WriteMapSize(1)
+	w.WriteString("width")
+	w.WriteUint32(70000)
+	w.WriteString("meta")
+	w.WriteMapSize(0)
+}
+
+func storeRequest(w msgpack.Writer) {
+	w.WriteMapSize(3)
+	w.WriteString("bucket")
+	w.WriteString("photos")
+	w.WriteString("owner")
+	w.WriteMapSize(2)
+	w.WriteString("name")
+	w.WriteString("Ada")
+	w.WriteString("team")
+	w.WriteString("eng")
+	w.WriteString("objects")
+	w.WriteArraySize(3)
+	catObject(w)
+	notesObject(w)
+	bannerObject(w)
+}
+
+func storeResponse(w msgpack.Writer) {
+	w.WriteMapSize(3)
+	w.WriteString("records")
+	w.WriteArraySize(2)
+	w.WriteByteArray(checked(catRecord))
+	w.WriteByteArray(checked(notesRecord("notes.txt", true)))
+	w.WriteString("index")
+	w.WriteMapSize(2)
+	w.WriteUint8(1)
+	w.WriteString("cat.png")
+	w.WriteUint8(2)
+	w.WriteString("notes.txt")
+	w.WriteString("rejected")
+	w.WriteArraySize(1)
+	w.WriteArraySize(2)
+	bannerObject(w)
+	w.WriteString("attribute width is not of its kind")
+}
+
+func catRecord(w msgpack.Writer) {
+	hash := sha256.Sum256(encode(catObject))
+	w.WriteMapSize(10)
+	w.WriteString("id")
+	w.WriteUint8(1)
+	w.WriteString("bucket")
+	w.WriteString("photos")
+	w.WriteString("key")
+	w.WriteString("cat.png")
+	w.WriteString("type")
+	w.WriteString("image/png")
+	w.WriteString("size")
+	w.WriteUint8(203)
+	w.WriteString("hash")
+	w.WriteByteArray(hash[:])
+	w.WriteString("owner")
+	ada(w)
+	// The data is run-length encoded in an ext of type 100, after its
+	// length.
+	w.WriteString("data")
+	w.WriteRawBytes([]byte{msgpack.FormatExt8, 12, 100, 0, 0, 0, 203, 200, 0, 1, 1, 1, 2, 1, 3})
+	w.WriteString("attrs")
+	w.WriteMapSize(4)
+	w.WriteString("camera")
+	w.WriteByteArray(encode(func(w msgpack.Writer) { w.WriteString("X100") }))
+	w.WriteString("flash")
+	w.WriteByteArray(encode(func(w msgpack.Writer) { w.WriteBool(false) }))
+	w.WriteString("offset")
+	w.WriteByteArray(encode(func(w msgpack.Writer) { w.WriteInt8(-3) }))
+	w.WriteString("width")
+	w.WriteByteArray(encode(func(w msgpack.Writer) { w.WriteUint16(640) }))
+	w.WriteString("meta")
+	w.WriteByteArray(encode(catMeta))
+}
+
+// notesRecord writes the record of the notes with `key`, owned by Ada when
+// `owned`. Its data takes fewer bytes as it is than run-length encoded.
+func notesRecord(key string, owned bool) func(w msgpack.Writer) {
+	return func(w msgpack.Writer) {
+		hash := sha256.Sum256(encode(notesObject))
+		w.WriteMapSize(10)
+		w.WriteString("id")
+		w.WriteUint8(2)
+		w.WriteString("bucket")
+		w.WriteString("photos")
+		w.WriteString("key")
+		w.WriteString(key)
+		w.WriteString("type")
+		w.WriteString("text/plain")
+		w.WriteString("size")
+		w.WriteUint8(5)
+		w.WriteString("hash")
+		w.WriteByteArray(hash[:])
+		w.WriteString("owner")
+		if owned {
+			ada(w)
+		} else {
+			w.WriteNil()
+		}
+		w.WriteString("data")
+		w.WriteByteArray([]byte("hello"))
+		w.WriteString("attrs")
+		w.WriteMapSize(1)
+		w.WriteString("pages")
+		w.WriteByteArray([]byte{1})
+		w.WriteString("meta")
+		w.WriteByteArray([]byte{0x80})
+	}
+}
+
+func ada(w msgpack.Writer) {
+	w.WriteMapSize(2)
+	w.WriteString("name")
+	w.WriteString("Ada")
+	w.WriteString("team")
+	w.WriteString("eng")
+}
+
+// checked returns what `write` writes, followed by its CRC-32 in an ext
+// of type 42.
+func checked(write func(w msgpack.Writer)) []byte {
+	message := encode(write)
+	trailer := []byte{msgpack.FormatFixExt4, 42, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(trailer[2:], crc32.ChecksumIEEE(message))
+	return append(message, trailer...)
+}
+
+// listRequest lists the records of the store call and a copy of the first
+// with a byte changed.
+func listRequest(w msgpack.Writer) {
+	damaged := checked(catRecord)
+	damaged[len(damaged)-20]++
+	w.WriteMapSize(2)
+	w.WriteString("records")
+	w.WriteArraySize(3)
+	w.WriteByteArray(checked(catRecord))
+	w.WriteByteArray(checked(notesRecord("notes.txt", true)))
+	w.WriteByteArray(damaged)
+	w.WriteString("from")
+	w.WriteNil()
+}
+
+// listResponse is written with a string table of ext type 7. The
+// entries are counted once they are written, so their array has a
+// reserved header, which takes five bytes.
+func listResponse(w msgpack.Writer) {
+	w.WriteMapSize(3)
+	w.WriteString("entries")
+	w.WriteRawBytes([]byte{msgpack.FormatArray32, 0, 0, 0, 2})
+	w.WriteMapSize(6)
+	w.WriteString("key")
+	w.WriteString("cat.png")
+	w.WriteString("type")
+	w.WriteString("image/png")
+	w.WriteString("size")
+	w.WriteUint8(203)
+	w.WriteString("owner")
+	w.WriteString("Ada")
+	w.WriteString("attrs")
+	w.WriteArraySize(4)
+	w.WriteString("camera")
+	w.WriteString("flash")
+	w.WriteString("offset")
+	w.WriteString("width")
+	w.WriteString("meta")
+	w.WriteUint8(14)
+	w.WriteMapSize(6)
+	w.WriteString("key")
+	w.WriteString("notes.txt")
+	w.WriteString("type")
+	w.WriteString("text/plain")
+	w.WriteString("size")
+	w.WriteUint8(5)
+	w.WriteString("owner")
+	w.WriteString("Ada")
+	w.WriteString("attrs")
+	w.WriteArraySize(1)
+	w.WriteString("pages")
+	w.WriteString("meta")
+	w.WriteUint8(3)
+	w.WriteString("corrupt")
+	w.WriteArraySize(1)
+	w.WriteArraySize(2)
+	w.WriteUint8(2)
+	w.WriteString("msgpack: checksum mismatch")
+	w.WriteString("next")
+	w.WriteNil()
+}
+
+// patchRequest renames the notes and clears their owner.
+func patchRequest(w msgpack.Writer) {
+	w.WriteMapSize(2)
+	w.WriteString("record")
+	w.WriteByteArray(checked(notesRecord("notes.txt", true)))
+	w.WriteString("update")
+	w.WriteMapSize(2)
+	w.WriteString("set")
+	w.WriteMapSize(1)
+	w.WriteString("key")
+	w.WriteString("notes/hello.txt")
+	w.WriteString("clear")
+	w.WriteArraySize(1)
+	w.WriteString("owner")
+}
+
+func patchResponse(w msgpack.Writer) {
+	w.WriteRawBytes(checked(notesRecord("notes/hello.txt", false)))
+}
@@ -0,0 +1,185 @@
+package fixtures
+
+import (
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// CET is the zone of the backup job of the plan call.
+var CET = time.FixedZone("+01:00", 3600)
+
+// The IDs of the jobs of the plan call.
+var (
+	BackupID  = [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	ReportID  = [16]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x00, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	CleanupID = [16]byte{15: 0xcc}
+)
+
+// weekdays are the days of the report and cleanup jobs, Monday to Friday.
+var weekdays = []bool{false, true, true, true, true, true, false}
+
+// jobBatch writes a batch of three jobs from as many producers: a backup
+// job whose next run is on the following day, as its day of the year is a
+// blackout day, a report job that next runs on the Monday after its start
+// on a Saturday, and a cleanup job that runs once, on a Saturday, which
+// is not one of its days.
+func jobBatch(w msgpack.Writer) {
+	w.WriteArraySize(2)
+	w.WriteUint32(2)
+	w.WriteArraySize(3)
+
+	start := time.Date(2023, 11, 14, 0, 30, 0, 0, CET)
+	w.WriteMapSize(10)
+	w.WriteString("id")
+	w.WriteRawBytes(append([]byte{msgpack.FormatFixExt16, 4}, BackupID[:]...))
+	w.WriteString("kind")
+	w.WriteString("Backup")
+	w.WriteString("priority")
+	w.WriteUint8(2)
+	w.WriteString("pool")
+	w.WriteString("Dedicated")
+	w.WriteString("start")
+	msgpack.WriteZonedTime(w, start)
+	w.WriteString("every")
+	w.WriteUint32(3600)
+	w.WriteString("days")
+	w.WriteArraySize(7)
+	for _, day := range []bool{false, true, true, true, true, true, true} {
+		w.WriteBool(day)
+	}
+	w.WriteString("blackout")
+	blackout := make([]bool, start.YearDay())
+	blackout[start.YearDay()-1] = true
+	msgpack.WriteBoolBitset(w, blackout)
+	w.WriteString("window")
+	w.WriteArraySize(2)
+	w.WriteUint8(2)
+	w.WriteUint8(6)
+	w.WriteString("weight")
+	msgpack.WriteFloat16Ext(w, 1.5, 5)
+
+	w.WriteMapSize(7)
+	w.WriteString("id")
+	w.WriteArraySize(2)
+	w.WriteInt64(0x1122334455667788)
+	w.WriteInt64(0x0099aabbccddeeff)
+	w.WriteString("kind")
+	w.WriteString("report")
+	w.WriteString("queue")
+	w.WriteString("batch")
+	w.WriteString("start")
+	msgpack.WriteZonedTime(w, time.Date(2023, 11, 18, 8, 0, 0, 0, time.UTC))
+	w.WriteString("deadline")
+	msgpack.WriteZonedTime(w, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC))
+	w.WriteString("every")
+	w.WriteString("24h")
+	w.WriteString("days")
+	msgpack.WriteBoolBitset(w, weekdays)
+
+	w.WriteMapSize(13)
+	w.WriteString("id")
+	w.WriteByteArray(CleanupID[:])
+	w.WriteString("parent")
+	w.WriteByteArray(BackupID[:])
+	w.WriteString("kind")
+	w.WriteString("cleanup")
+	w.WriteString("priority")
+	w.WriteString("low")
+	w.WriteString("start")
+	msgpack.WriteZonedTime(w, time.Date(2023, 11, 18, 3, 0, 0, 0, time.UTC))
+	w.WriteString("every")
+	w.WriteNil()
+	w.WriteString("timeout")
+	w.WriteString("10m")
+	w.WriteString("max_runs")
+	w.WriteUint32(1)
+	w.WriteString("days")
+	msgpack.WriteBoolBitset(w, weekdays)
+	w.WriteString("owner")
+	w.WriteArraySize(3)
+	w.WriteString("storage")
+	w.WriteString("Grace")
+	w.WriteString("grace@example.com")
+	w.WriteString("retry")
+	w.WriteArraySize(3)
+	w.WriteUint8(3)
+	w.WriteUint32(30)
+	w.WriteFloat64(2)
+	w.WriteString("notify")
+	w.WriteArraySize(4)
+	w.WriteString("email")
+	w.WriteString("storage@example.com")
+	w.WriteBool(true)
+	w.WriteUint32(4)
+	w.WriteString("cron")
+	w.WriteArraySize(5)
+	for _, field := range []string{"0", "3", "*", "*", "1-5"} {
+		w.WriteString(field)
+	}
+}
+
+func jobPlan(w msgpack.Writer) {
+	w.WriteMapSize(1)
+	w.WriteString("runs")
+	w.WriteArraySize(2)
+	w.WriteArraySize(5)
+	w.WriteString("01020304-0506-0708-090a-0b0c0d0e0f10")
+	w.WriteString("backup")
+	msgpack.WriteZonedTime(w, time.Date(2023, 11, 15, 2, 30, 0, 0, CET))
+	w.WriteString("1h0m0s")
+	w.WriteUint16(0x3e00)
+	w.WriteArraySize(5)
+	w.WriteString("11223344-5566-7788-0099-aabbccddeeff")
+	w.WriteString("report")
+	msgpack.WriteZonedTime(w, time.Date(2023, 11, 20, 8, 0, 0, 0, time.UTC))
+	w.WriteString("24h0m0s")
+	w.WriteUint16(0x3c00)
+}
+
+// runRecords writes the records of three runs: two backups, one of which
+// failed after a retry, and a report that was skipped, so has no time.
+func runRecords(w msgpack.Writer) {
+	w.WriteArraySize(3)
+	w.WriteMapSize(4)
+	w.WriteString("kind")
+	w.WriteString("backup")
+	w.WriteString("took")
+	w.WriteFloat64(1)
+	w.WriteString("ok")
+	w.WriteBool(true)
+	w.WriteString("at")
+	w.WriteTime(time.Date(2023, 11, 15, 1, 30, 0, 0, time.UTC))
+	w.WriteMapSize(5)
+	w.WriteString("kind")
+	w.WriteString("backup")
+	w.WriteString("took")
+	w.WriteFloat64(2)
+	w.WriteString("ok")
+	w.WriteBool(false)
+	w.WriteString("tries")
+	w.WriteUint8(2)
+	w.WriteString("at")
+	w.WriteTime(time.Date(2023, 11, 16, 1, 30, 0, 0, time.UTC))
+	w.WriteMapSize(2)
+	w.WriteString("kind")
+	w.WriteString("report")
+	w.WriteString("host")
+	w.WriteString("worker-3")
+}
+
+func runStats(w msgpack.Writer) {
+	w.WriteMapSize(5)
+	w.WriteString("runs")
+	w.WriteUint32(3)
+	w.WriteString("failed")
+	w.WriteUint32(1)
+	w.WriteString("retried")
+	w.WriteUint32(1)
+	w.WriteString("took")
+	w.WriteMapSize(1)
+	w.WriteString("backup")
+	w.WriteFloat64(1.5)
+	w.WriteString("last")
+	w.WriteTime(time.Date(2023, 11, 16, 1, 30, 0, 0, time.UTC))
+}
@@ -0,0 +1,130 @@
+package fixtures
+
+import msgpack "github.com/wapc/tinygo-msgpack"
+
+func configureRequest(w msgpack.Writer) {
+	w.WriteMapSize(4)
+	w.WriteString("request")
+	w.WriteString("cfg-7")
+	w.WriteString("defaults")
+	w.WriteMapSize(3)
+	w.WriteString("service")
+	w.WriteMapSize(2)
+	w.WriteString("name")
+	w.WriteString("api")
+	w.WriteString("port")
+	w.WriteUint16(8080)
+	w.WriteString("limits")
+	w.WriteMapSize(2)
+	w.WriteString("rps")
+	w.WriteUint32(100)
+	w.WriteString("burst")
+	w.WriteUint32(20)
+	w.WriteString("debug")
+	w.WriteBool(false)
+	w.WriteString("site")
+	w.WriteMapSize(3)
+	w.WriteString("service")
+	w.WriteMapSize(1)
+	w.WriteString("priority")
+	w.WriteInt8(-5)
+	w.WriteString("limits")
+	w.WriteMapSize(1)
+	w.WriteString("rps")
+	w.WriteUint32(250)
+	w.WriteString("hosts")
+	w.WriteArraySize(1)
+	w.WriteString("a.example.com")
+	w.WriteString("patch")
+	w.WriteArraySize(2)
+	w.WriteMapSize(2)
+	w.WriteString("op")
+	w.WriteString(msgpack.PatchRemove)
+	w.WriteString("path")
+	w.WriteArraySize(1)
+	w.WriteString("debug")
+	w.WriteMapSize(3)
+	w.WriteString("op")
+	w.WriteString(msgpack.PatchAdd)
+	w.WriteString("path")
+	w.WriteArraySize(2)
+	w.WriteString("hosts")
+	w.WriteInt64(1)
+	w.WriteString("value")
+	w.WriteString("b.example.com")
+}
+
+// writePatchOp writes an operation of a patch, without a value for nil.
+func writePatchOp(w msgpack.Writer, op string, value func(w msgpack.Writer), path ...string) {
+	if value == nil {
+		w.WriteMapSize(2)
+	} else {
+		w.WriteMapSize(3)
+	}
+	w.WriteString("op")
+	w.WriteString(op)
+	w.WriteString("path")
+	w.WriteArraySize(uint32(len(path)))
+	for _, segment := range path {
+		w.WriteString(segment)
+	}
+	if value != nil {
+		w.WriteString("value")
+		value(w)
+	}
+}
+
+func configureResponse(w msgpack.Writer) {
+	priority := func(w msgpack.Writer) { w.WriteInt8(-5) }
+	rps := func(w msgpack.Writer) { w.WriteUint8(250) }
+	hosts := func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteString("a.example.com")
+		w.WriteString("b.example.com")
+	}
+
+	// The keys are sorted at every level.
+	w.WriteMapSize(5)
+	w.WriteString("changes")
+	w.WriteArraySize(4)
+	writePatchOp(w, msgpack.PatchAdd, priority, "service", "priority")
+	writePatchOp(w, msgpack.PatchReplace, rps, "limits", "rps")
+	writePatchOp(w, msgpack.PatchRemove, nil, "debug")
+	writePatchOp(w, msgpack.PatchAdd, hosts, "hosts")
+	w.WriteString("config")
+	w.WriteMapSize(3)
+	w.WriteString("hosts")
+	hosts(w)
+	w.WriteString("limits")
+	w.WriteMapSize(2)
+	w.WriteString("burst")
+	w.WriteUint8(20)
+	w.WriteString("rps")
+	rps(w)
+	w.WriteString("service")
+	w.WriteMapSize(3)
+	w.WriteString("name")
+	w.WriteString("api")
+	w.WriteString("port")
+	w.WriteUint16(8080)
+	w.WriteString("priority")
+	priority(w)
+	w.WriteString("differences")
+	w.WriteMapSize(3)
+	w.WriteString(msgpack.DiffValue.String())
+	w.WriteUint8(1)
+	w.WriteString(msgpack.DiffOnlyInA.String())
+	w.WriteUint8(1)
+	w.WriteString(msgpack.DiffOnlyInB.String())
+	w.WriteUint8(2)
+	w.WriteString("request")
+	w.WriteString("cfg-7")
+	w.WriteString("summary")
+	w.WriteMapSize(3)
+	w.WriteString("name")
+	w.WriteString("api")
+	w.WriteString("priority")
+	priority(w)
+	w.WriteString("rps")
+	rps(w)
+}
@@ -0,0 +1,84 @@
+// Package greeter is a waPC-style guest handler: it decodes a request
+// struct from the payload of a call, and encodes the response struct it
+// builds from it, with codecs declared through ObjectCodec.
+package greeter
+
+import (
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Request asks for a greeting.
+type Request struct {
+	Name     string
+	Language *string
+	Tags     []string
+	Sent     time.Time
+}
+
+// Response is the greeting for a Request.
+type Response struct {
+	Message string
+	Tags    uint32
+	Sent    time.Time
+}
+
+var requestCodec = msgpack.Object[Request]().
+	Field("name", msgpack.StringField(func(r *Request) *string { return &r.Name }).Required()).
+	Field("language", msgpack.NillableStringField(func(r *Request) **string { return &r.Language })).
+	Field("tags", msgpack.StringSliceField(func(r *Request) *[]string { return &r.Tags })).
+	Field("sent", msgpack.TimeField(func(r *Request) *time.Time { return &r.Sent }))
+
+var responseCodec = msgpack.Object[Response]().
+	Field("message", msgpack.StringField(func(r *Response) *string { return &r.Message })).
+	Field("tags", msgpack.Uint32Field(func(r *Response) *uint32 { return &r.Tags })).
+	Field("sent", msgpack.TimeField(func(r *Response) *time.Time { return &r.Sent }))
+
+func (r *Request) Encode(w msgpack.Writer) error {
+	return requestCodec.Encode(w, r)
+}
+
+func (r *Request) Decode(rd msgpack.Reader) error {
+	return requestCodec.Decode(rd, r)
+}
+
+func (r *Response) Encode(w msgpack.Writer) error {
+	return responseCodec.Encode(w, r)
+}
+
+func (r *Response) Decode(rd msgpack.Reader) error {
+	return responseCodec.Decode(rd, r)
+}
+
+var greetings = map[string]string{
+	"de": "Hallo",
+	"en": "Hello",
+	"fr": "Bonjour",
+}
+
+// Greet is the handler of the "greet" operation. It greets the sender of
+// the Request in its language, English by default.
+func Greet(payload []byte) ([]byte, error) {
+	decoder := msgpack.NewDecoder(payload)
+	request, err := msgpack.Decode[Request](&decoder)
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.ExpectEOF(); err != nil {
+		return nil, err
+	}
+
+	greeting := greetings["en"]
+	if request.Language != nil {
+		if g, ok := greetings[*request.Language]; ok {
+			greeting = g
+		}
+	}
+	response := Response{
+		Message: greeting + ", " + request.Name + "!",
+		Tags:    uint32(len(request.Tags)),
+		Sent:    request.Sent,
+	}
+	return msgpack.ToBytes(&response)
+}
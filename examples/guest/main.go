@@ -0,0 +1,105 @@
+// Command guest serves the example operations to a host through standard
+// input and output. It reads one call, an array of the operation name and
+// its payload as a bin value, and writes the payload the operation
+// returns. It builds with both Go and TinyGo:
+//
+//	tinygo build -o guest.wasm -target wasi ./examples/guest
+//
+// With the argument "selftest" it makes the calls of package fixtures
+// instead and reports whether each returns the expected response, which
+// makes it a smoke test of a build:
+//
+//	wasmtime guest.wasm selftest
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/examples/accounts"
+	"github.com/wapc/tinygo-msgpack/examples/devices"
+	"github.com/wapc/tinygo-msgpack/examples/events"
+	"github.com/wapc/tinygo-msgpack/examples/fixtures"
+	"github.com/wapc/tinygo-msgpack/examples/greeter"
+	"github.com/wapc/tinygo-msgpack/examples/meters"
+	"github.com/wapc/tinygo-msgpack/examples/objects"
+	"github.com/wapc/tinygo-msgpack/examples/passthrough"
+	"github.com/wapc/tinygo-msgpack/examples/schedule"
+	"github.com/wapc/tinygo-msgpack/examples/sensors"
+	"github.com/wapc/tinygo-msgpack/examples/settings"
+)
+
+var operations = map[string]func(payload []byte) ([]byte, error){
+	"greet":     greeter.Greet,
+	"summarize": sensors.Summarize,
+	"route":     passthrough.Route,
+	"register":  devices.Register,
+	"update":    devices.Update,
+	"configure": settings.Configure,
+	"ingest":    events.Ingest,
+	"plan":      schedule.Plan,
+	"stats":     schedule.Stats,
+	"rpc":       accounts.Call,
+	"store":     objects.Store,
+	"list":      objects.List,
+	"patch":     objects.Patch,
+	"collect":   meters.Collect,
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		if !selftest(os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+	if err := serve(os.Stdin, os.Stdout); err != nil {
+		os.Stderr.WriteString("guest: " + err.Error() + "\n")
+		os.Exit(1)
+	}
+}
+
+func serve(in io.Reader, out io.Writer) error {
+	call, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	decoder := msgpack.NewDecoder(call)
+	operation, payload, err := msgpack.ReadTuple2(&decoder, msgpack.Reader.ReadString, msgpack.Reader.ReadByteArray)
+	if err != nil {
+		return err
+	}
+	handle, ok := operations[operation]
+	if !ok {
+		return errors.New("unknown operation " + operation)
+	}
+	response, err := handle(payload)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(response)
+	return err
+}
+
+func selftest(out io.Writer) bool {
+	passed := true
+	for _, call := range fixtures.Calls() {
+		response, err := call.Handle(call.Request)
+		var report []byte
+		switch {
+		case err != nil:
+			report = append(report, "FAIL "+call.Operation+": "+err.Error()+"\n"...)
+		case !bytes.Equal(response, call.Response):
+			report = append(report, "FAIL "+call.Operation+": returned\n"...)
+			report = msgpack.AppendHexDump(report, response)
+		default:
+			report = append(report, "ok   "+call.Operation+"\n"...)
+		}
+		out.Write(report)
+		passed = passed && err == nil && bytes.Equal(response, call.Response)
+	}
+	return passed
+}
@@ -0,0 +1,61 @@
+//go:build !tinygo
+// +build !tinygo
+
+package meters
+
+import (
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Archived is a reading as the tools of the archive, which run on hosts,
+// read it from a frame. Its fields are those of Reading, by position.
+type Archived struct {
+	Serial    string    `msgpack:"0"`
+	At        time.Time `msgpack:"1"`
+	WattHours uint32    `msgpack:"2"`
+	Tariff    *string   `msgpack:"3"`
+}
+
+// ReadFrame reads the readings of a frame of the response of "collect".
+func ReadFrame(frame []byte) ([]Archived, error) {
+	decoder := msgpack.NewDecoder(frame)
+	n, err := decoder.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	readings := make([]Archived, 0, n)
+	for i := uint32(0); i < n; i++ {
+		var reading Archived
+		if err := msgpack.DecodePositional(&decoder, &reading); err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+	return readings, nil
+}
+
+// WriteFrame returns a frame of `readings` as "collect" writes it, for
+// tools that rewrite the archive. A frame holds at most MaxPerFrame
+// readings.
+func WriteFrame(readings []Archived) ([]byte, error) {
+	write := func(w msgpack.Writer) error {
+		w.WriteArraySize(uint32(len(readings)))
+		for i := range readings {
+			if err := msgpack.EncodePositional(w, &readings[i]); err != nil {
+				return err
+			}
+		}
+		return w.Err()
+	}
+	sizer := msgpack.NewSizerWithOptions(archiveOptions...)
+	if err := write(&sizer); err != nil {
+		return nil, err
+	}
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), archiveOptions...)
+	if err := write(&encoder); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
@@ -0,0 +1,211 @@
+// Package meters is a guest that collects the readings of electricity
+// meters for a billing system older than most of them. Old meters send
+// fixed-width binary rows and new ones a log of MessagePack readings, and
+// both are read with Reading.Decode, the rows through a ReaderCore of
+// their own.
+//
+// The readings are written three times, with writers of three ages: as
+// CSV for the billing system, with a WriterCore; as lines of the audit
+// log, with the LegacyWriter the first release of the guest wrote CSV
+// with; and as frames for an archive whose readers were built with v0.1
+// of this package, so the frames are written as v0.1 wrote them.
+package meters
+
+import (
+	"errors"
+	"strconv"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Limits of the frames of the archive.
+const (
+	// FrameSize is the most bytes a frame takes, the size of the blocks
+	// the archive is stored in.
+	FrameSize = 64
+	// MaxPerFrame is the most readings a frame holds, as the readers of
+	// the archive count them in a byte.
+	MaxPerFrame = 255
+)
+
+var _ msgpack.Codec = &Reading{}
+
+// Collect is the handler of the "collect" operation. The payload is a map
+//
+//	{"rows": bin, "log": bin}
+//
+// of the rows of old meters, 16 bytes each, and the log of new ones, a
+// sequence of readings [serial, at, watt-hours, tariff, ...]. Either may
+// be left out. The response is a map
+//
+//	{"csv": "...", "audit": "...", "frames": [frame, ...]}
+//
+// of the readings, rows first, as CSV for billing, as lines of the serial
+// and watt-hours for the audit log, and as frames for the archive, each a
+// bin holding an array of readings in the encoding of v0.1.
+func Collect(payload []byte) ([]byte, error) {
+	decoder := msgpack.NewDecoder(payload)
+	size, err := decoder.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	var rows, log []byte
+	for i := uint32(0); i < size; i++ {
+		key, err := decoder.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "rows":
+			rows, err = decoder.ReadByteArray()
+		case "log":
+			log, err = decoder.ReadByteArray()
+		default:
+			err = decoder.Skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	readings, err := readRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	if readings, err = readLog(readings, log); err != nil {
+		return nil, err
+	}
+
+	csv := csvWriter{fields{sep: ','}}
+	billing := &msgpack.WriterAdapter{WriterCore: &csv}
+	audit := fields{sep: ' '}
+	auditing := msgpack.UpgradeWriter(&audit)
+	for i := range readings {
+		if err := readings[i].Encode(billing); err != nil {
+			return nil, err
+		}
+		auditing.WriteArraySize(2)
+		auditing.WriteString(readings[i].Serial)
+		auditing.WriteUint32(readings[i].WattHours)
+	}
+	frames, err := archive(readings)
+	if err != nil {
+		return nil, err
+	}
+
+	write := func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("csv")
+		w.WriteString(string(csv.text))
+		w.WriteString("audit")
+		w.WriteString(string(audit.text))
+		w.WriteString("frames")
+		w.WriteArraySize(uint32(len(frames)))
+		for _, frame := range frames {
+			w.WriteByteArray(frame)
+		}
+	}
+	sizer := msgpack.NewSizer()
+	write(&sizer)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	write(&encoder)
+	return encoder.Bytes(), encoder.Err()
+}
+
+func readRows(rows []byte) ([]Reading, error) {
+	if len(rows)%rowSize != 0 {
+		return nil, errors.New("meters: rows of " + strconv.Itoa(len(rows)) + " bytes")
+	}
+	readings := make([]Reading, len(rows)/rowSize)
+	r := msgpack.ReaderAdapter{ReaderCore: newRowReader(rows)}
+	for i := range readings {
+		if err := readings[i].Decode(r); err != nil {
+			return nil, err
+		}
+	}
+	return readings, nil
+}
+
+// readLog appends the readings of `log` to `readings`.
+func readLog(readings []Reading, log []byte) ([]Reading, error) {
+	values := msgpack.NewMultiDecoder(log)
+	for values.More() {
+		decoder, err := values.Next()
+		if err != nil {
+			return nil, err
+		}
+		reading, err := msgpack.Decode[Reading](&decoder)
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+	return readings, nil
+}
+
+// archive returns the frames of `readings`, as many to a frame as fit.
+func archive(readings []Reading) ([][]byte, error) {
+	var frames [][]byte
+	for len(readings) > 0 {
+		frame, n, err := fill(readings)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+		readings = readings[n:]
+	}
+	return frames, nil
+}
+
+// archiveOptions write as v0.1 did, and check the number of readings of a
+// frame at its header.
+var archiveOptions = []msgpack.EncOption{msgpack.CompatV01(), msgpack.WithContainerLenCheck(MaxPerFrame)}
+
+// minReadingSize is the fewest bytes a reading takes.
+const minReadingSize = 10
+
+// fill returns a frame of as many of `readings` as fit in one, and their
+// number. A frame is allocated at the bound of its readings when that is
+// less than FrameSize.
+func fill(readings []Reading) ([]byte, int, error) {
+	n := len(readings)
+	for {
+		bound := msgpack.NewUpperBoundSizerWithOptions(archiveOptions...)
+		writeFrame(&bound, readings[:n])
+		size := uint32(FrameSize)
+		if bound.Len() < size {
+			size = bound.Len()
+		}
+		encoder := msgpack.NewEncoderWithOptions(make([]byte, size), archiveOptions...)
+		err := writeFrame(&encoder, readings[:n])
+		var (
+			tooMany  msgpack.WriteError
+			tooLarge msgpack.ContainerSizeError
+		)
+		switch {
+		case err == nil:
+			return encoder.Bytes(), n, nil
+		case errors.As(err, &tooLarge) && tooLarge.Remaining >= minReadingSize:
+			// The header found that the readings cannot fit even at a
+			// byte each, so the count is cut to what the room left could
+			// hold at once.
+			n = int(tooLarge.Remaining / minReadingSize)
+		case errors.As(err, &tooMany):
+			n = MaxPerFrame
+		case errors.Is(err, msgpack.ErrRange) && n > 1:
+			n--
+		default:
+			return nil, 0, err
+		}
+	}
+}
+
+func writeFrame(w msgpack.Writer, readings []Reading) error {
+	w.WriteArraySize(uint32(len(readings)))
+	for i := range readings {
+		if err := readings[i].Encode(w); err != nil {
+			return err
+		}
+	}
+	return w.Err()
+}
@@ -0,0 +1,211 @@
+package meters
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Reading is a reading of a meter.
+type Reading struct {
+	Serial    string
+	At        time.Time
+	WattHours uint32
+	// Tariff is the tariff the energy is billed at, or nil for the
+	// standard one. Old meters have no tariffs.
+	Tariff *string
+}
+
+// Encode writes the reading as an array of its fields.
+func (rd *Reading) Encode(w msgpack.Writer) error {
+	w.WriteArraySize(4)
+	w.WriteString(rd.Serial)
+	w.WriteTime(rd.At)
+	w.WriteUint32(rd.WattHours)
+	w.WriteNillableString(rd.Tariff)
+	return w.Err()
+}
+
+// Decode reads a reading of any meter: old meters send three fields, and
+// newer ones may send more than this guest knows.
+func (rd *Reading) Decode(r msgpack.Reader) error {
+	n, err := r.ReadArraySize()
+	if err != nil {
+		return err
+	}
+	if n < 3 {
+		return errors.New("meters: a reading has at least 3 fields, found " + strconv.FormatUint(uint64(n), 10))
+	}
+	if rd.Serial, err = r.ReadString(); err != nil {
+		return err
+	}
+	if rd.At, err = r.ReadTime(); err != nil {
+		return err
+	}
+	if rd.WattHours, err = r.ReadUint32(); err != nil {
+		return err
+	}
+	if rd.Tariff, err = readTariff(r, n); err != nil {
+		return err
+	}
+	return skipNewer(r, n)
+}
+
+// readTariff reads the tariff of a reading of `n` fields, which it only
+// has from the fourth on.
+func readTariff(r msgpack.NillableReader, n uint32) (*string, error) {
+	if n < 4 {
+		return nil, nil
+	}
+	return r.ReadNillableString()
+}
+
+// skipNewer skips the fields of a reading of `n` fields that meters newer
+// than this guest add after those it knows.
+func skipNewer(r msgpack.ReaderExtras, n uint32) error {
+	for i := uint32(4); i < n; i++ {
+		if err := r.Skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowSize is the size of the rows of old meters: the serial, padded with
+// NUL bytes to 8, and the time in seconds since the Unix epoch and the
+// watt-hours, as big-endian 32 bit integers.
+const rowSize = 16
+
+// rowReader reads the rows of old meters as readings of three fields, so
+// that they are read with Reading.Decode through a ReaderAdapter. Strings
+// alias the rows, as they do from a Decoder.
+type rowReader struct {
+	data msgpack.DataReader
+	// field is the index of the next field of the row, or -1 before
+	// the row is started with ReadArraySize.
+	field int
+	err   error
+}
+
+var _ msgpack.ReaderCore = &rowReader{}
+
+func newRowReader(rows []byte) *rowReader {
+	return &rowReader{data: msgpack.NewDataReader(rows), field: -1}
+}
+
+// next checks that the next field of the row is field `index`, read as
+// `kind`.
+func (r *rowReader) next(index int, kind string) error {
+	if r.err == nil && r.field != index {
+		r.err = errors.New("meters: field " + strconv.Itoa(r.field) + " of a row is not a " + kind)
+	}
+	r.field++
+	return r.err
+}
+
+func (r *rowReader) ReadArraySize() (uint32, error) {
+	if err := r.next(-1, "row"); err != nil {
+		return 0, err
+	}
+	if r.data.Remaining() < rowSize {
+		r.err = errors.New("meters: a row of " + strconv.FormatUint(uint64(r.data.Remaining()), 10) + " bytes")
+		return 0, r.err
+	}
+	return 3, nil
+}
+
+func (r *rowReader) ReadString() (string, error) {
+	if err := r.next(0, "string"); err != nil {
+		return "", err
+	}
+	serial, err := r.data.GetBytes(8)
+	for len(serial) > 0 && serial[len(serial)-1] == 0 {
+		serial = serial[:len(serial)-1]
+	}
+	return msgpack.UnsafeString(serial), err
+}
+
+func (r *rowReader) ReadTime() (time.Time, error) {
+	if err := r.next(1, "time"); err != nil {
+		return time.Time{}, err
+	}
+	seconds, err := r.data.GetUint32()
+	return time.Unix(int64(seconds), 0).UTC(), err
+}
+
+func (r *rowReader) ReadUint32() (uint32, error) {
+	if err := r.next(2, "uint32"); err != nil {
+		return 0, err
+	}
+	r.field = -1
+	return r.data.GetUint32()
+}
+
+func (r *rowReader) unsupported(kind string) error {
+	if r.err == nil {
+		r.err = errors.New("meters: rows hold no " + kind)
+	}
+	return r.err
+}
+
+func (r *rowReader) PeekIsNil() (bool, error) {
+	return false, r.err
+}
+
+func (r *rowReader) ConsumeNil() error {
+	return r.unsupported("nil")
+}
+
+func (r *rowReader) ReadBool() (bool, error) {
+	return false, r.unsupported("bool")
+}
+
+func (r *rowReader) ReadInt8() (int8, error) {
+	return 0, r.unsupported("int8")
+}
+
+func (r *rowReader) ReadInt16() (int16, error) {
+	return 0, r.unsupported("int16")
+}
+
+func (r *rowReader) ReadInt32() (int32, error) {
+	return 0, r.unsupported("int32")
+}
+
+func (r *rowReader) ReadInt64() (int64, error) {
+	return 0, r.unsupported("int64")
+}
+
+func (r *rowReader) ReadUint8() (uint8, error) {
+	return 0, r.unsupported("uint8")
+}
+
+func (r *rowReader) ReadUint16() (uint16, error) {
+	return 0, r.unsupported("uint16")
+}
+
+func (r *rowReader) ReadUint64() (uint64, error) {
+	return 0, r.unsupported("uint64")
+}
+
+func (r *rowReader) ReadFloat32() (float32, error) {
+	return 0, r.unsupported("float32")
+}
+
+func (r *rowReader) ReadFloat64() (float64, error) {
+	return 0, r.unsupported("float64")
+}
+
+func (r *rowReader) ReadByteArray() ([]byte, error) {
+	return nil, r.unsupported("byte array")
+}
+
+func (r *rowReader) ReadMapSize() (uint32, error) {
+	return 0, r.unsupported("map")
+}
+
+func (r *rowReader) Err() error {
+	return r.err
+}
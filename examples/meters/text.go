@@ -0,0 +1,134 @@
+package meters
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// fields writes arrays as lines of text, their elements separated by
+// `sep`, with nil as an empty field. It is the LegacyWriter the guest
+// wrote CSV with before Writer had WriteTime.
+type fields struct {
+	sep  byte
+	text []byte
+	// size is the number of elements of the line, and left the number
+	// not yet written.
+	size, left uint32
+	err        error
+}
+
+var _ msgpack.LegacyWriter = &fields{}
+
+// field appends `value` to the line.
+func (f *fields) field(value string) {
+	if f.left == 0 {
+		f.fail("a value outside of a line")
+		return
+	}
+	if f.left < f.size {
+		f.text = append(f.text, f.sep)
+	}
+	f.text = append(f.text, value...)
+	if f.left--; f.left == 0 {
+		f.text = append(f.text, '\n')
+	}
+}
+
+func (f *fields) fail(what string) {
+	if f.err == nil {
+		f.err = errors.New("meters: a line cannot hold " + what)
+	}
+}
+
+func (f *fields) WriteArraySize(length uint32) {
+	if f.left > 0 || length == 0 {
+		f.fail("an array")
+		return
+	}
+	f.size, f.left = length, length
+}
+
+func (f *fields) WriteMapSize(length uint32) {
+	f.fail("a map")
+}
+
+func (f *fields) WriteNil() {
+	f.field("")
+}
+
+func (f *fields) WriteBool(value bool) {
+	f.field(strconv.FormatBool(value))
+}
+
+func (f *fields) WriteInt8(value int8) {
+	f.WriteInt64(int64(value))
+}
+
+func (f *fields) WriteInt16(value int16) {
+	f.WriteInt64(int64(value))
+}
+
+func (f *fields) WriteInt32(value int32) {
+	f.WriteInt64(int64(value))
+}
+
+func (f *fields) WriteInt64(value int64) {
+	f.field(strconv.FormatInt(value, 10))
+}
+
+func (f *fields) WriteUint8(value uint8) {
+	f.WriteUint64(uint64(value))
+}
+
+func (f *fields) WriteUint16(value uint16) {
+	f.WriteUint64(uint64(value))
+}
+
+func (f *fields) WriteUint32(value uint32) {
+	f.WriteUint64(uint64(value))
+}
+
+func (f *fields) WriteUint64(value uint64) {
+	f.field(strconv.FormatUint(value, 10))
+}
+
+func (f *fields) WriteFloat32(value float32) {
+	f.field(strconv.FormatFloat(float64(value), 'g', -1, 32))
+}
+
+func (f *fields) WriteFloat64(value float64) {
+	f.field(strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (f *fields) WriteString(value string) {
+	f.field(value)
+}
+
+func (f *fields) WriteByteArray(value []byte) {
+	f.fail("a byte array")
+}
+
+// csvWriter adds the methods of WriterCore to fields, writing times in
+// RFC 3339, so that a WriterAdapter of it is a Writer. The nillable
+// methods of Reading.Encode and the others of NillableWriter and
+// WriterExtras are those of the adapter.
+type csvWriter struct {
+	fields
+}
+
+var (
+	_ msgpack.WriterCore     = &csvWriter{}
+	_ msgpack.NillableWriter = &msgpack.WriterAdapter{WriterCore: &csvWriter{}}
+	_ msgpack.WriterExtras   = &msgpack.WriterAdapter{WriterCore: &csvWriter{}}
+)
+
+func (w *csvWriter) WriteTime(value time.Time) {
+	w.field(value.Format(time.RFC3339))
+}
+
+func (w *csvWriter) Err() error {
+	return w.err
+}
@@ -0,0 +1,206 @@
+package objects
+
+import (
+	"errors"
+	"io"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Upload encodes `request` into `block`, the region of guest memory the
+// host writes payloads to, and returns the payload. A request that does
+// not fit is encoded into a new block of the size it needs, which hosts
+// lend for large requests.
+func Upload(block []byte, request *StoreRequest) ([]byte, error) {
+	payload, err := msgpack.ToBytesIn(block, request)
+	var tooSmall msgpack.BufferTooSmallError
+	if errors.As(err, &tooSmall) {
+		return msgpack.ToBytesIn(make([]byte, tooSmall.Required), request)
+	}
+	return payload, err
+}
+
+// Block returns a block that `request` fits in, sized with an
+// UpperBoundSizer, which does not go over the data of the objects as a
+// Sizer does.
+func Block(request *StoreRequest) ([]byte, error) {
+	bound := msgpack.NewUpperBoundSizer()
+	if err := request.Encode(&bound); err != nil {
+		return nil, err
+	}
+	return make([]byte, bound.Len()), nil
+}
+
+// EncodePatch returns the payload of "patch" that updates `record`, setting
+// the fields in `set`, each written by its function, and clearing those in
+// `clear`.
+func EncodePatch(record []byte, set map[string]func(msgpack.Writer), clear []string) ([]byte, error) {
+	write := func(w msgpack.Writer) error {
+		w.WriteMapSize(2)
+		w.WriteString("record")
+		w.WriteByteArray(record)
+		w.WriteString("update")
+		return msgpack.WriteFieldMaskUpdate(w, set, clear)
+	}
+	sizer := msgpack.NewSizer()
+	if err := write(&sizer); err != nil {
+		return nil, err
+	}
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	if err := write(&encoder); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
+
+// ReadRecord reads a record returned by "store" or "patch".
+func ReadRecord(blob []byte) (*Record, error) {
+	record := &Record{}
+	if err := unseal(blob, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Entry is an entry of a listing.
+type Entry struct {
+	Key   string
+	Type  string
+	Size  uint32
+	Owner string
+	Attrs []string
+	// Meta is the number of bytes the metadata of the record takes.
+	Meta uint32
+}
+
+// Listing is a response of "list".
+type Listing struct {
+	Entries []Entry
+	// Corrupt are the reasons the records that could not be read were
+	// not, by index.
+	Corrupt map[uint32]string
+	// Next is the cursor of the next page, or empty after the last.
+	Next string
+}
+
+// ReadListing reads a response of "list".
+func ReadListing(response []byte) (Listing, error) {
+	listing := Listing{Corrupt: map[uint32]string{}}
+	decoder := msgpack.NewDecoderWithOptions(response, msgpack.WithStringTableDecoding(stringExt))
+	size, err := decoder.ReadMapSize()
+	if err != nil {
+		return listing, err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := decoder.ReadString()
+		if err != nil {
+			return listing, err
+		}
+		switch key {
+		case "entries":
+			err = msgpack.ReadArrayStrict(&decoder, func(_ uint32, r msgpack.Reader) error {
+				entry, err := readEntry(r)
+				listing.Entries = append(listing.Entries, entry)
+				return err
+			})
+		case "corrupt":
+			err = msgpack.ReadArrayStrict(&decoder, func(_ uint32, r msgpack.Reader) error {
+				index, reason, err := msgpack.ReadTuple2(r, msgpack.Reader.ReadUint32, msgpack.Reader.ReadString)
+				listing.Corrupt[index] = reason
+				return err
+			})
+		case "next":
+			var next *string
+			if next, err = decoder.ReadNillableString(); next != nil {
+				listing.Next = *next
+			}
+		default:
+			err = decoder.Skip()
+		}
+		if err != nil {
+			return listing, err
+		}
+	}
+	return listing, nil
+}
+
+func readEntry(r msgpack.Reader) (Entry, error) {
+	var entry Entry
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return entry, err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := r.ReadString()
+		if err != nil {
+			return entry, err
+		}
+		switch key {
+		case "key":
+			entry.Key, err = r.ReadString()
+		case "type":
+			entry.Type, err = r.ReadString()
+		case "size":
+			entry.Size, err = r.ReadUint32()
+		case "owner":
+			var owner *string
+			if owner, err = r.ReadNillableString(); owner != nil {
+				entry.Owner = *owner
+			}
+		case "attrs":
+			err = msgpack.ReadArrayStrict(r, func(_ uint32, r msgpack.Reader) error {
+				name, err := r.ReadString()
+				entry.Attrs = append(entry.Attrs, name)
+				return err
+			})
+		case "meta":
+			entry.Meta, err = r.ReadUint32()
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return entry, err
+		}
+	}
+	return entry, nil
+}
+
+// chunkSize is the size of the reads of Scan.
+const chunkSize = 256
+
+// Scan calls `fn` with each record of `log`, a sequence of the bins of
+// records, such as a host appends them to a file, reading it in chunks
+// and decoding each record as soon as its last chunk is read.
+func Scan(log io.Reader, fn func(record *Record) error) error {
+	decoder := msgpack.NewSuspendableDecoder()
+	chunk := make([]byte, chunkSize)
+	for {
+		n, err := log.Read(chunk)
+		decoder.AppendData(chunk[:n])
+		for decoder.Buffered() > 0 {
+			blob, readErr := decoder.ReadByteArray()
+			if errors.Is(readErr, msgpack.ErrNeedMore) {
+				break
+			}
+			if readErr != nil {
+				return readErr
+			}
+			record, readErr := ReadRecord(blob)
+			if readErr != nil {
+				return readErr
+			}
+			if readErr = fn(record); readErr != nil {
+				return readErr
+			}
+		}
+		if err == io.EOF {
+			if decoder.Buffered() > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package objects
+
+import (
+	"errors"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// PageSize bounds the bytes of the entries of a page of "list".
+const PageSize = 512
+
+// stringExt is the ext type of the string table of listings, in which the
+// types, owners and attribute names of records repeat.
+const stringExt = 7
+
+// Cursor is where a listing continues, as "list" returns it: an opaque
+// base64 string.
+type Cursor struct {
+	// Next is the index of the first record of the next page.
+	Next uint32
+}
+
+func (c *Cursor) Encode(w msgpack.Writer) error {
+	w.WriteArraySize(1)
+	w.WriteUint32(c.Next)
+	return w.Err()
+}
+
+func (c *Cursor) Decode(r msgpack.Reader) error {
+	if _, err := r.ReadArraySize(); err != nil {
+		return err
+	}
+	var err error
+	c.Next, err = r.ReadUint32()
+	return err
+}
+
+// listRequest is the payload of "list".
+type listRequest struct {
+	records [][]byte
+	from    Cursor
+}
+
+func (req *listRequest) Decode(r msgpack.Reader) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "records":
+			err = msgpack.ReadArrayStrict(r, func(_ uint32, r msgpack.Reader) error {
+				blob, err := r.ReadByteArray()
+				req.records = append(req.records, blob)
+				return err
+			})
+		case "from":
+			var from *string
+			if from, err = r.ReadNillableString(); err == nil && from != nil {
+				req.from, err = msgpack.DecodeFromBase64[Cursor](*from)
+			}
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List is the handler of the "list" operation. The payload is a map
+//
+//	{"records": [record, ...], "from": cursor}
+//
+// of stored records and the cursor of the page to list, or nil for the
+// first, and the response a map
+//
+//	{"entries": [entry, ...], "corrupt": [[index, reason], ...], "next": cursor}
+//
+// written with a string table of ext type 7. Each entry is a map
+//
+//	{"key": "...", "type": "...", "size": n, "owner": "...", "attrs": ["...", ...], "meta": n}
+//
+// of the key, type and size of a record, the name of its owner or nil,
+// the names of its attributes and the bytes its metadata takes. The
+// entries of a page take at most PageSize bytes, and "next" is the cursor
+// of the next page, or nil after the last. Records that fail their
+// checksum or cannot be read are listed in "corrupt" with their index and
+// a reason, which starts with "meta:" when only their metadata is broken.
+func List(payload []byte) ([]byte, error) {
+	var request listRequest
+	if err := pool.Decode(payload, &request); err != nil {
+		return nil, err
+	}
+	records := make([]*Record, len(request.records))
+	var corrupt []corruption
+	for i, blob := range request.records {
+		record := &Record{}
+		err := unseal(blob, record)
+		var embedded msgpack.EmbeddedError
+		switch {
+		case errors.As(err, &embedded):
+			corrupt = append(corrupt, corruption{i, "meta: " + embedded.Err.Error()})
+		case err != nil:
+			corrupt = append(corrupt, corruption{i, err.Error()})
+		default:
+			records[i] = record
+		}
+	}
+
+	write := func(w msgpack.Writer, page func(from int) int) error {
+		w.WriteMapSize(3)
+		w.WriteString("entries")
+		next := page(int(request.from.Next))
+		w.WriteString("corrupt")
+		w.WriteArraySize(uint32(len(corrupt)))
+		for _, c := range corrupt {
+			msgpack.WriteArrayFromFuncs(w,
+				func(w msgpack.Writer) { w.WriteUint32(uint32(c.index)) },
+				func(w msgpack.Writer) { w.WriteString(c.reason) })
+		}
+		w.WriteString("next")
+		if next >= len(records) {
+			w.WriteNil()
+			return w.Err()
+		}
+		cursor, err := msgpack.EncodeToBase64(&Cursor{Next: uint32(next)})
+		if err != nil {
+			return err
+		}
+		w.WriteString(cursor)
+		return w.Err()
+	}
+	option := msgpack.WithStringTable(stringExt)
+	sizer := msgpack.NewSizerWithOptions(option)
+	err := write(&sizer, func(from int) int { return writePage[msgpack.SizerMark](&sizer, records, from) })
+	if err != nil {
+		return nil, err
+	}
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), option)
+	err = write(&encoder, func(from int) int { return writePage[msgpack.EncoderMark](&encoder, records, from) })
+	return encoder.Bytes(), err
+}
+
+// corruption is a record "list" could not read.
+type corruption struct {
+	index  int
+	reason string
+}
+
+// marker is a writer that can take back what it wrote since a mark: a
+// Sizer, whose marks are SizerMarks, or an Encoder, whose marks are
+// EncoderMarks.
+type marker[M any] interface {
+	msgpack.Writer
+	Len() uint32
+	Mark() M
+	Rollback(mark M)
+}
+
+// page writes an array of the entries of a page of a listing. Each entry is
+// written speculatively and the one that takes the page past PageSize is
+// taken back. The Sizer and the Encoder agree on where a page ends as they
+// count the same bytes.
+type page[M any] struct {
+	w      marker[M]
+	header msgpack.HeaderMark
+	limit  uint32
+	count  uint32
+}
+
+func newPage[M any](w marker[M]) *page[M] {
+	header := w.ReserveArraySize()
+	return &page[M]{w: w, header: header, limit: w.Len() + PageSize}
+}
+
+// add writes the entry of `record` and reports whether it fits.
+func (p *page[M]) add(record *Record) bool {
+	mark := p.w.Mark()
+	writeEntry(p.w, record)
+	if p.w.Len() > p.limit {
+		p.w.Rollback(mark)
+		return false
+	}
+	p.count++
+	return true
+}
+
+// writePage writes the page of `records` that starts at index `from`,
+// skipping the corrupt records, which are nil, and returns the index of
+// the first record left out.
+func writePage[M any](w marker[M], records []*Record, from int) int {
+	p := newPage(w)
+	next := from
+	for ; next < len(records); next++ {
+		if records[next] != nil && !p.add(records[next]) {
+			break
+		}
+	}
+	w.PatchArraySize(p.header, p.count)
+	return next
+}
+
+func writeEntry(w msgpack.Writer, record *Record) {
+	var meta msgpack.Sizer
+	msgpack.SizeEmbedded(&meta, record.Meta)
+	msgpack.WriteMapFromPairs(w,
+		msgpack.Pair("key", func(w msgpack.Writer) { w.WriteString(record.Key) }),
+		msgpack.Pair("type", func(w msgpack.Writer) { w.WriteString(record.Type) }),
+		msgpack.Pair("size", func(w msgpack.Writer) { w.WriteUint32(record.Size) }),
+		msgpack.Pair("owner", func(w msgpack.Writer) {
+			if record.Owner != nil {
+				w.WriteString(record.Owner.Name)
+			} else {
+				w.WriteNil()
+			}
+		}),
+		msgpack.Pair("attrs", func(w msgpack.Writer) {
+			names := sortedKeys(record.Attrs)
+			w.WriteArraySize(uint32(len(names)))
+			for _, name := range names {
+				w.WriteString(name)
+			}
+		}),
+		msgpack.Pair("meta", func(w msgpack.Writer) { w.WriteUint32(meta.Len()) }),
+	)
+}
@@ -0,0 +1,342 @@
+package objects
+
+import (
+	"math"
+	"sort"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Types are the content types the store accepts.
+var Types = []string{"image/png", "text/plain", "application/octet-stream"}
+
+// Object is an object sent to be stored.
+type Object struct {
+	ID   uint64
+	Key  string
+	Type string
+	Data []byte
+	// Attrs are attributes the host indexes, such as the width of an
+	// image. Those in attrKinds must be of their kind.
+	Attrs map[string]any
+	// Meta is metadata the store keeps for the host without reading it.
+	Meta Meta
+}
+
+// Encode writes the object as a map. Attrs and Meta are written in the
+// order of their keys when the writer sorts maps.
+func (o *Object) Encode(w msgpack.Writer) error {
+	w.WriteMapSize(6)
+	w.WriteString("id")
+	w.WriteUint64(o.ID)
+	w.WriteString("key")
+	w.WriteString(o.Key)
+	w.WriteString("type")
+	w.WriteString(o.Type)
+	w.WriteString("data")
+	w.WriteByteArray(o.Data)
+	w.WriteString("attrs")
+	w.WriteStringAnyMap(o.Attrs)
+	w.WriteString("meta")
+	w.WriteStringAnyMap(o.Meta)
+	return w.Err()
+}
+
+// Decode reads an object. The type must be one of Types.
+func (o *Object) Decode(r msgpack.Reader) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "id":
+			o.ID, err = r.ReadUint64()
+		case "key":
+			o.Key, err = r.ReadString()
+		case "type":
+			o.Type, err = msgpack.ReadStringEnum(r, Types...)
+		case "data":
+			o.Data, err = r.ReadByteArray()
+		case "attrs":
+			o.Attrs, err = msgpack.ReadStringAnyMap(r)
+		case "meta":
+			o.Meta, err = msgpack.ReadStringAnyMap(r)
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Meta is the metadata of an object. Records hold it as an embedded
+// document.
+type Meta map[string]any
+
+func (m Meta) Encode(w msgpack.Writer) error {
+	w.WriteStringAnyMap(m)
+	return w.Err()
+}
+
+func (m *Meta) Decode(r msgpack.Reader) error {
+	var err error
+	*m, err = msgpack.ReadStringAnyMap(r)
+	return err
+}
+
+// Owner is the owner of the objects of a request.
+type Owner struct {
+	Name string
+	Team string
+}
+
+func (o *Owner) Encode(w msgpack.Writer) error {
+	w.WriteMapSize(2)
+	w.WriteString("name")
+	w.WriteString(o.Name)
+	w.WriteString("team")
+	w.WriteString(o.Team)
+	return w.Err()
+}
+
+func (o *Owner) Decode(r msgpack.Reader) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "name":
+			o.Name, err = r.ReadString()
+		case "team":
+			o.Team, err = r.ReadString()
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record is an object as the store keeps it.
+type Record struct {
+	ID     uint64
+	Bucket string
+	Key    string
+	Type   string
+	// Size is the length of the data.
+	Size uint32
+	// Hash is the SHA-256 of the object as it was sent, with its maps in
+	// key order.
+	Hash []byte
+	// Owner is the owner of the record, or nil.
+	Owner *Owner
+	// Data is the data as WriteCompressedByteArray writes it. Read it
+	// with Content.
+	Data msgpack.Raw
+	// Attrs holds each attribute as a document of its own, so that the
+	// host can index it without reading the record.
+	Attrs map[string][]byte
+	Meta  Meta
+
+	// shared is Owner, shared by the records of a request, which then
+	// size and encode it once.
+	shared *msgpack.SharedCodec
+}
+
+// Encode writes the record as a map, with its attributes in the order of
+// their names and its metadata embedded.
+func (rec *Record) Encode(w msgpack.Writer) error {
+	w.WriteMapSize(10)
+	w.WriteString("id")
+	w.WriteUint64(rec.ID)
+	w.WriteString("bucket")
+	w.WriteString(rec.Bucket)
+	w.WriteString("key")
+	w.WriteString(rec.Key)
+	w.WriteString("type")
+	w.WriteString(rec.Type)
+	w.WriteString("size")
+	w.WriteUint32(rec.Size)
+	w.WriteString("hash")
+	w.WriteByteArray(rec.Hash)
+	w.WriteString("owner")
+	var owner msgpack.Encodable = rec.Owner
+	if rec.shared != nil {
+		owner = rec.shared
+	}
+	if err := msgpack.WriteEncodableOrNil(w, owner); err != nil {
+		return err
+	}
+	w.WriteString("data")
+	w.WriteRaw(rec.Data)
+	w.WriteString("attrs")
+	w.WriteMapSize(uint32(len(rec.Attrs)))
+	for _, name := range sortedKeys(rec.Attrs) {
+		w.WriteString(name)
+		w.WriteByteArray(rec.Attrs[name])
+	}
+	w.WriteString("meta")
+	if err := msgpack.WriteEmbedded(w, rec.Meta); err != nil {
+		return err
+	}
+	return w.Err()
+}
+
+// Decode reads a record.
+func (rec *Record) Decode(r msgpack.Reader) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "id":
+			rec.ID, err = r.ReadUint64()
+		case "bucket":
+			rec.Bucket, err = r.ReadString()
+		case "key":
+			rec.Key, err = r.ReadString()
+		case "type":
+			rec.Type, err = r.ReadString()
+		case "size":
+			rec.Size, err = r.ReadUint32()
+		case "hash":
+			rec.Hash, err = r.ReadByteArray()
+		case "owner":
+			rec.Owner, rec.shared = nil, nil
+			err = msgpack.DecodeIfPresent(r, func(o *Owner) { rec.Owner = o })
+		case "data":
+			rec.Data, err = r.ReadRaw()
+		case "attrs":
+			rec.Attrs, err = readAttrs(r)
+		case "meta":
+			err = msgpack.ReadEmbedded(r, &rec.Meta)
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Content returns the data of the record.
+func (rec *Record) Content() ([]byte, error) {
+	decoder := msgpack.NewDecoder(rec.Data)
+	return msgpack.ReadCompressedByteArray(&decoder, compression)
+}
+
+func readAttrs(r msgpack.Reader) (map[string][]byte, error) {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	attrs := make(map[string][]byte)
+	for i := uint32(0); i < size; i++ {
+		name, err := r.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		if attrs[name], err = r.ReadByteArray(); err != nil {
+			return nil, err
+		}
+	}
+	return attrs, nil
+}
+
+// attrKind returns the document of an attribute value, or false when the
+// value is not of the kind.
+type attrKind func(value any) ([]byte, bool)
+
+// attrKinds are the kinds of the attributes the host indexes. Each is
+// stored in the smallest form of its kind, whatever form it was sent in,
+// so that equal values have equal documents.
+var attrKinds = map[string]attrKind{
+	"depth":    intKind(0, math.MaxUint8, func(n int64) ([]byte, error) { return msgpack.U8ToBytes(uint8(n)) }),
+	"width":    intKind(0, math.MaxUint16, u16),
+	"height":   intKind(0, math.MaxUint16, u16),
+	"frames":   intKind(0, math.MaxUint32, func(n int64) ([]byte, error) { return msgpack.U32ToBytes(uint32(n)) }),
+	"serial":   intKind(0, math.MaxInt64, func(n int64) ([]byte, error) { return msgpack.U64ToBytes(uint64(n)) }),
+	"offset":   intKind(math.MinInt8, math.MaxInt8, func(n int64) ([]byte, error) { return msgpack.I8ToBytes(int8(n)) }),
+	"rotation": intKind(-359, 359, func(n int64) ([]byte, error) { return msgpack.I16ToBytes(int16(n)) }),
+	"altitude": intKind(math.MinInt32, math.MaxInt32, func(n int64) ([]byte, error) { return msgpack.I32ToBytes(int32(n)) }),
+	"taken":    intKind(math.MinInt64, math.MaxInt64, msgpack.I64ToBytes),
+	"exposure": func(value any) ([]byte, bool) {
+		f, ok := msgpack.AnyFloat(value)
+		return document(ok, func() ([]byte, error) { return msgpack.F32ToBytes(float32(f)) })
+	},
+	"aperture": func(value any) ([]byte, bool) {
+		f, ok := msgpack.AnyFloat(value)
+		return document(ok, func() ([]byte, error) { return msgpack.F64ToBytes(f) })
+	},
+	"flash": func(value any) ([]byte, bool) {
+		b, ok := msgpack.AnyBool(value)
+		return document(ok, func() ([]byte, error) { return msgpack.BoolToBytes(b) })
+	},
+	"camera": func(value any) ([]byte, bool) {
+		s, ok := msgpack.AnyString(value)
+		return document(ok, func() ([]byte, error) { return msgpack.StringToBytes(s) })
+	},
+	"thumbnail": func(value any) ([]byte, bool) {
+		b, ok := msgpack.AnyBytes(value)
+		return document(ok, func() ([]byte, error) { return msgpack.BytesToBytes(b) })
+	},
+}
+
+func u16(n int64) ([]byte, error) {
+	return msgpack.U16ToBytes(uint16(n))
+}
+
+// intKind is the kind of integers from `min` to `max`.
+func intKind(min, max int64, toBytes func(n int64) ([]byte, error)) attrKind {
+	return func(value any) ([]byte, bool) {
+		n, ok := msgpack.AnyInt(value)
+		return document(ok && n >= min && n <= max, func() ([]byte, error) { return toBytes(n) })
+	}
+}
+
+func document(ok bool, toBytes func() ([]byte, error)) ([]byte, bool) {
+	if !ok {
+		return nil, false
+	}
+	doc, err := toBytes()
+	return doc, err == nil
+}
+
+// attrDocument returns the document of attribute `name`. An attribute
+// whose kind the host does not index is stored as it was sent.
+func attrDocument(name string, value any) ([]byte, bool) {
+	if kind, ok := attrKinds[name]; ok {
+		return kind(value)
+	}
+	doc, err := msgpack.AnyToBytes(value)
+	return doc, err == nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,299 @@
+// Package objects is a guest that prepares objects for a host's object
+// store. It turns the objects it is sent into checksummed records, which
+// the host stores as they are, lists pages of stored records and applies
+// partial updates to them. The host indexes the attributes of a record
+// without decoding it, as each is a document of its own.
+//
+// TinyGo guests have no compress/flate, so data is compressed with a run
+// length encoding, which suits the large runs of a single byte found in
+// images.
+package objects
+
+import (
+	"crypto/sha256"
+	"errors"
+	"strconv"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Limits of a store request.
+const (
+	// MaxObjects is the most objects a request may hold.
+	MaxObjects = 64
+	// MaxObjectSize is the largest encoded object the store accepts.
+	MaxObjectSize = 1 << 20
+)
+
+// checksumExt is the ext type of the checksum of records. Records written
+// before records were checksummed have none.
+const checksumExt = 42
+
+// pool holds the decoders of requests.
+var pool = msgpack.NewCodecPool(4 << 10)
+
+// StoreRequest is the payload of "store".
+type StoreRequest struct {
+	Bucket string
+	// Owner is the owner of the objects, or nil.
+	Owner   *Owner
+	Objects []Object
+
+	// raws are the objects as they were sent.
+	raws []msgpack.Raw
+}
+
+// Encode writes the request. Sizing it with an UpperBoundSizer, which does
+// not look at the data of the objects, bounds it cheaply.
+func (req *StoreRequest) Encode(w msgpack.Writer) error {
+	w.WriteMapSize(3)
+	w.WriteString("bucket")
+	w.WriteString(req.Bucket)
+	w.WriteString("owner")
+	if err := msgpack.WriteEncodableOrNil(w, req.Owner); err != nil {
+		return err
+	}
+	w.WriteString("objects")
+	w.WriteArraySize(uint32(len(req.Objects)))
+	for i := range req.Objects {
+		if err := req.Objects[i].Encode(w); err != nil {
+			return err
+		}
+	}
+	return w.Err()
+}
+
+// Decode reads a request, keeping each object as it was sent too.
+func (req *StoreRequest) Decode(r msgpack.Reader) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "bucket":
+			req.Bucket, err = r.ReadString()
+		case "owner":
+			req.Owner = nil
+			err = msgpack.DecodeIfPresent(r, func(o *Owner) { req.Owner = o })
+		case "objects":
+			err = req.readObjects(r)
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (req *StoreRequest) readObjects(r msgpack.Reader) error {
+	n, err := r.ReadArraySize()
+	if err != nil {
+		return err
+	}
+	if n > MaxObjects {
+		return errors.New("objects: a request holds at most " + strconv.Itoa(MaxObjects) + " objects")
+	}
+	req.Objects, req.raws = make([]Object, n), make([]msgpack.Raw, n)
+	for i := range req.Objects {
+		if req.Objects[i], req.raws[i], err = msgpack.DecodeWithRaw[Object](r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rejection is an object the store rejected, as it was sent.
+type rejection struct {
+	raw    msgpack.Raw
+	reason string
+}
+
+// Store is the handler of the "store" operation. The payload is a
+// StoreRequest
+//
+//	{"bucket": "photos", "owner": {"name": "...", "team": "..."}, "objects": [...]}
+//
+// whose owner may be nil, and the response a map
+//
+//	{"records": [record, ...], "index": {id: key, ...}, "rejected": [[object, reason], ...]}
+//
+// of the records of the objects, each a bin holding a Record with a
+// checksum, the keys of the records by ID, and the objects the store
+// rejected, as they were sent, with the reason. An object is rejected
+// when it is larger than MaxObjectSize or an attribute is not of its
+// kind.
+func Store(payload []byte) ([]byte, error) {
+	var request StoreRequest
+	if err := pool.Decode(payload, &request); err != nil {
+		return nil, err
+	}
+
+	var (
+		owner    *msgpack.SharedCodec
+		records  [][]byte
+		index    []msgpack.MapPair
+		rejected []rejection
+	)
+	if request.Owner != nil {
+		owner = msgpack.Shared(request.Owner)
+	}
+	for i := range request.Objects {
+		object := &request.Objects[i]
+		record, reason, err := newRecord(request.Bucket, object)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			rejected = append(rejected, rejection{request.raws[i], reason})
+			continue
+		}
+		record.Owner, record.shared = request.Owner, owner
+		blob, err := msgpack.ToBytesChecked(record, msgpack.WithChecksumExtType(checksumExt))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, blob)
+		id, key := object.ID, object.Key
+		index = append(index, msgpack.PairFunc(
+			func(w msgpack.Writer) { w.WriteUint64(id) },
+			func(w msgpack.Writer) { w.WriteString(key) }))
+	}
+
+	// The response is laid out from the lengths of its parts rather than
+	// sized, which would go over the records once more.
+	size := msgpack.WorstCase{}.Maps(2).Arrays(2).Strings(3, uint32(len("rejected"))).Ints(uint32(len(index))).Total()
+	for _, blob := range records {
+		size += uint64(msgpack.MaxBinSize(uint32(len(blob))))
+	}
+	for i := range request.Objects {
+		size += uint64(msgpack.MaxStringSize(uint32(len(request.Objects[i].Key))))
+	}
+	for _, r := range rejected {
+		size += msgpack.WorstCase{}.Arrays(1).Bytes(uint32(len(r.raw))).Strings(1, uint32(len(r.reason))).Total()
+	}
+
+	encoder := msgpack.NewEncoder(make([]byte, size))
+	encoder.WriteMapSize(3)
+	encoder.WriteString("records")
+	encoder.WriteArraySize(uint32(len(records)))
+	for _, blob := range records {
+		encoder.WriteByteArray(blob)
+	}
+	encoder.WriteString("index")
+	msgpack.WriteMapFromPairs(&encoder, index...)
+	encoder.WriteString("rejected")
+	encoder.WriteArraySize(uint32(len(rejected)))
+	for _, r := range rejected {
+		msgpack.WriteArrayFromFuncs(&encoder,
+			func(w msgpack.Writer) { w.WriteRaw(r.raw) },
+			func(w msgpack.Writer) { w.WriteString(r.reason) })
+	}
+	return encoder.Bytes(), encoder.Err()
+}
+
+// newRecord returns the record of `object`, or the reason it is rejected.
+func newRecord(bucket string, object *Object) (*Record, string, error) {
+	size, err := msgpack.RequiredSize(object)
+	if err != nil {
+		return nil, "", err
+	}
+	if size > MaxObjectSize {
+		return nil, "larger than " + strconv.Itoa(MaxObjectSize) + " bytes", nil
+	}
+	attrs := make(map[string][]byte, len(object.Attrs))
+	for _, name := range sortedKeys(object.Attrs) {
+		doc, ok := attrDocument(name, object.Attrs[name])
+		if !ok {
+			return nil, "attribute " + name + " is not of its kind", nil
+		}
+		attrs[name] = doc
+	}
+	hash, err := msgpack.ContentHash(object, sha256.New(), msgpack.WithSortedStringMaps())
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := pack(object.Data)
+	if err != nil {
+		return nil, "", err
+	}
+	meta := object.Meta
+	if meta == nil {
+		meta = Meta{}
+	}
+	return &Record{
+		ID:     object.ID,
+		Bucket: bucket,
+		Key:    object.Key,
+		Type:   object.Type,
+		Size:   uint32(len(object.Data)),
+		Hash:   hash,
+		Data:   data,
+		Attrs:  attrs,
+		Meta:   meta,
+	}, "", nil
+}
+
+// pack returns `data` as WriteCompressedByteArray writes it. Records hold
+// their data packed, as a Sizer counts a compressed array at the most it
+// could take, and a record must encode to the size it was sized to.
+func pack(data []byte) (msgpack.Raw, error) {
+	encoder := msgpack.NewEncoder(make([]byte, msgpack.MaxBinSize(uint32(len(data)))+4))
+	if err := msgpack.WriteCompressedByteArray(&encoder, data, compression); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), encoder.Err()
+}
+
+// unseal decodes the checksummed record `blob` into `record`. Records
+// written before they were checksummed are read as they are.
+func unseal(blob []byte, record msgpack.Decodable) error {
+	return msgpack.FromBytesChecked(blob, record,
+		msgpack.WithChecksumExtType(checksumExt), msgpack.WithUncheckedAllowed())
+}
+
+// compression is the codec of the data of records.
+var compression msgpack.CompressionCodec = runLength{}
+
+// runLength compresses data into pairs of a count of up to 255 and the
+// byte repeated.
+type runLength struct{}
+
+func (runLength) Compress(src []byte) ([]byte, error) {
+	var dst []byte
+	for i := 0; i < len(src); {
+		n := 1
+		for i+n < len(src) && n < 255 && src[i+n] == src[i] {
+			n++
+		}
+		dst = append(dst, byte(n), src[i])
+		i += n
+	}
+	return dst, nil
+}
+
+func (runLength) Decompress(src []byte, originalLen int) ([]byte, error) {
+	if len(src)%2 != 0 {
+		return nil, errors.New("objects: odd length of run-length data")
+	}
+	dst := make([]byte, 0, originalLen)
+	for i := 0; i < len(src); i += 2 {
+		if len(dst)+int(src[i]) > originalLen {
+			return nil, errors.New("objects: run-length data longer than " + strconv.Itoa(originalLen) + " bytes")
+		}
+		for n := 0; n < int(src[i]); n++ {
+			dst = append(dst, src[i+1])
+		}
+	}
+	return dst, nil
+}
+
+func (runLength) MaxCompressedLen(srcLen int) int {
+	return 2 * srcLen
+}
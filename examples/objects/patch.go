@@ -0,0 +1,89 @@
+package objects
+
+import (
+	"errors"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// errNotClearable is the error of clearing a field that a record needs.
+var errNotClearable = errors.New("cannot be cleared")
+
+// Patch is the handler of the "patch" operation. The payload is a map
+//
+//	{"record": record, "update": {"set": {"key": "..."}, "clear": ["owner"]}}
+//
+// of a stored record and an update of it in the form of
+// WriteFieldMaskUpdate, and the response the record after the update,
+// with a checksum. The key, type, owner and metadata may be set, and the
+// owner and metadata cleared. An update of any other field fails.
+func Patch(payload []byte) ([]byte, error) {
+	decoder := msgpack.NewDecoder(payload)
+	size, err := decoder.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		record Record
+		update msgpack.Raw
+	)
+	for i := uint32(0); i < size; i++ {
+		key, err := decoder.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "record":
+			var blob []byte
+			if blob, err = decoder.ReadByteArray(); err == nil {
+				err = unseal(blob, &record)
+			}
+		case "update":
+			update, err = decoder.ReadRaw()
+		default:
+			err = decoder.Skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The update is applied after the record is read, whatever order the
+	// two come in.
+	updates := msgpack.NewDecoder(update)
+	err = msgpack.ReadFieldMaskUpdate(&updates, record.set, record.clear)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.ToBytesChecked(&record, msgpack.WithChecksumExtType(checksumExt))
+}
+
+func (rec *Record) set(field string, r msgpack.Reader) error {
+	var err error
+	switch field {
+	case "key":
+		rec.Key, err = r.ReadString()
+	case "type":
+		rec.Type, err = msgpack.ReadStringEnum(r, Types...)
+	case "owner":
+		rec.Owner = &Owner{}
+		err = rec.Owner.Decode(r)
+	case "meta":
+		err = rec.Meta.Decode(r)
+	default:
+		err = errors.New("no such field")
+	}
+	return err
+}
+
+func (rec *Record) clear(field string) error {
+	switch field {
+	case "owner":
+		rec.Owner = nil
+	case "meta":
+		rec.Meta = Meta{}
+	default:
+		return errNotClearable
+	}
+	return nil
+}
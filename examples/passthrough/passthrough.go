@@ -0,0 +1,80 @@
+// Package passthrough is a guest that forwards messages without decoding
+// them: it reads the envelope of a message, copies its body through as a
+// Raw value of any type, and skips the keys it does not know.
+package passthrough
+
+import (
+	"errors"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// ErrNoDestination is returned for a message without a "to" key.
+var ErrNoDestination = errors.New("passthrough: message has no destination")
+
+// Route is the handler of the "route" operation. The message is a map
+//
+//	{"to": "billing", "hops": 2, "body": ...}
+//
+// which is returned with "hops" incremented and with the keys other than
+// these three dropped. A message without "hops" has made none, and one
+// without "body" has a nil body.
+func Route(payload []byte) ([]byte, error) {
+	var (
+		to   string
+		hops uint32
+		body msgpack.Raw
+	)
+	decoder := msgpack.NewDecoder(payload)
+	size, err := decoder.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := decoder.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "to":
+			to, err = decoder.ReadString()
+		case "hops":
+			hops, err = decoder.ReadUint32()
+		case "body":
+			body, err = decoder.ReadRaw()
+		default:
+			err = decoder.Skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := decoder.ExpectEOF(); err != nil {
+		return nil, err
+	}
+	if to == "" {
+		return nil, ErrNoDestination
+	}
+
+	write := func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("to")
+		w.WriteString(to)
+		w.WriteString("hops")
+		w.WriteUint32(hops + 1)
+		w.WriteString("body")
+		if body == nil {
+			w.WriteNil()
+		} else {
+			w.WriteRaw(body)
+		}
+	}
+	sizer := msgpack.NewSizer()
+	write(&sizer)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	write(&encoder)
+	if err := encoder.Err(); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
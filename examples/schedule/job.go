@@ -0,0 +1,271 @@
+package schedule
+
+import (
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Job is a job to run on a schedule.
+type Job struct {
+	ID     [16]byte
+	Parent *[16]byte
+	// Kind is one of Kinds.
+	Kind string
+	// Priority is one of Priorities.
+	Priority string
+	// Queue is one of Queues, and Pool one of Pools; nil is the default.
+	Queue *string
+	Pool  *string
+	// Start is the first run, in the zone the job runs in.
+	Start    time.Time
+	Deadline *time.Time
+	// Every is the time between runs, 0 for a job that runs once.
+	Every   time.Duration
+	Timeout *time.Duration
+	// MaxRuns bounds the runs when Limited is set.
+	MaxRuns uint32
+	Limited bool
+	// Days are the weekdays the job runs on, indexed by time.Weekday.
+	Days [7]bool
+	// Blackout are the days of the year the job does not run on, indexed
+	// by the day of the year less one.
+	Blackout []bool
+	// Weight is a half-precision share of the workers.
+	Weight float32
+	// Script is the SHA-256 hash of the script the job runs.
+	Script [32]byte
+	// Token is the credential the job runs with.
+	Token  [16]byte
+	Owner  Owner
+	Window *Window
+	Retry  *Retry
+	Notify *Notify
+	// Cron is a cron expression, from the minute to the weekday field,
+	// that replaces Every.
+	Cron *[5]string
+}
+
+// Owner is who to ask about a job.
+type Owner struct {
+	Team  string
+	Name  string
+	Email string
+}
+
+// Window is the hours of the day, in the zone of Start, a job may run in.
+type Window struct {
+	From uint8
+	To   uint8
+}
+
+// Retry is how a failed run is retried.
+type Retry struct {
+	Attempts uint8
+	Backoff  time.Duration
+	Factor   float64
+}
+
+// Notify is who is told about the runs of a job.
+type Notify struct {
+	Channel   string
+	Address   string
+	OnFailure bool
+	PerHour   uint32
+}
+
+// Values of the enumerated fields of a Job. The values of Priorities are
+// only ever appended to, as their index is written.
+var (
+	Kinds      = []string{"backup", "report", "cleanup"}
+	Priorities = msgpack.NewStringEnum("low", "normal", "high")
+	Queues     = []string{"default", "batch"}
+	Pools      = []string{"shared", "dedicated"}
+)
+
+// float16Ext is the ext type of the weight.
+const float16Ext int8 = 5
+
+// uuidExt is the ext type of the IDs of the producers that write them as
+// ext values.
+const uuidExt int8 = 4
+
+// uuidOptions accept the IDs of the producers in Java, which write them as
+// pairs of int64 values, and of those that write them as ext values.
+var uuidOptions = []msgpack.UUIDOption{
+	msgpack.WithUUIDExtType(uuidExt),
+	msgpack.WithUUIDInt64Pair(),
+}
+
+func (j *Job) Encode(w msgpack.Writer) error {
+	w.WriteMapSize(21)
+	w.WriteString("id")
+	msgpack.WriteUUID(w, j.ID)
+	w.WriteString("parent")
+	msgpack.WriteNillableUUID(w, j.Parent)
+	w.WriteString("kind")
+	w.WriteString(j.Kind)
+	w.WriteString("priority")
+	if err := Priorities.Write(w, j.Priority); err != nil {
+		return err
+	}
+	w.WriteString("queue")
+	w.WriteNillableString(j.Queue)
+	w.WriteString("pool")
+	w.WriteNillableString(j.Pool)
+	w.WriteString("start")
+	msgpack.WriteZonedTime(w, j.Start)
+	w.WriteString("deadline")
+	msgpack.WriteNillableZonedTime(w, j.Deadline)
+	w.WriteString("every")
+	msgpack.WriteZeroAsNil(w, j.Every, msgpack.WriteDuration)
+	w.WriteString("timeout")
+	msgpack.WriteNillableDuration(w, j.Timeout)
+	w.WriteString("max_runs")
+	msgpack.WriteNullable(w, j.MaxRuns, j.Limited, msgpack.Writer.WriteUint32)
+	w.WriteString("days")
+	msgpack.WriteBoolBitset(w, j.Days[:])
+	w.WriteString("blackout")
+	msgpack.WriteBoolBitset(w, j.Blackout)
+	w.WriteString("weight")
+	msgpack.WriteFloat16Ext(w, j.Weight, float16Ext)
+	w.WriteString("script")
+	w.WriteByteArray(j.Script[:])
+	w.WriteString("token")
+	w.WriteByteArray(j.Token[:])
+	w.WriteString("owner")
+	msgpack.WriteTuple3(w,
+		j.Owner.Team, msgpack.Writer.WriteString,
+		j.Owner.Name, msgpack.Writer.WriteString,
+		j.Owner.Email, msgpack.Writer.WriteString)
+	w.WriteString("window")
+	if j.Window == nil {
+		w.WriteNil()
+	} else {
+		msgpack.WriteTuple2(w, j.Window.From, msgpack.Writer.WriteUint8, j.Window.To, msgpack.Writer.WriteUint8)
+	}
+	w.WriteString("retry")
+	if j.Retry == nil {
+		w.WriteNil()
+	} else {
+		msgpack.WriteTuple3(w,
+			j.Retry.Attempts, msgpack.Writer.WriteUint8,
+			j.Retry.Backoff, msgpack.WriteDuration,
+			j.Retry.Factor, msgpack.Writer.WriteFloat64)
+	}
+	w.WriteString("notify")
+	if j.Notify == nil {
+		w.WriteNil()
+	} else {
+		msgpack.WriteTuple4(w,
+			j.Notify.Channel, msgpack.Writer.WriteString,
+			j.Notify.Address, msgpack.Writer.WriteString,
+			j.Notify.OnFailure, msgpack.Writer.WriteBool,
+			j.Notify.PerHour, msgpack.Writer.WriteUint32)
+	}
+	w.WriteString("cron")
+	if j.Cron == nil {
+		w.WriteNil()
+	} else {
+		msgpack.WriteTuple5(w,
+			j.Cron[0], msgpack.Writer.WriteString,
+			j.Cron[1], msgpack.Writer.WriteString,
+			j.Cron[2], msgpack.Writer.WriteString,
+			j.Cron[3], msgpack.Writer.WriteString,
+			j.Cron[4], msgpack.Writer.WriteString)
+	}
+	return w.Err()
+}
+
+// Decode reads a job, checking that the map holds as many entries as it
+// declares. Missing fields keep their value, and unknown ones are skipped.
+func (j *Job) Decode(r msgpack.Reader) error {
+	return msgpack.ReadMapStrict(r, func(_ uint32, r msgpack.Reader) error {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "id":
+			j.ID, err = msgpack.ReadUUID(r, uuidOptions...)
+		case "parent":
+			var parent [16]byte
+			var ok bool
+			if parent, ok, err = msgpack.ReadNillableUUID(r, uuidOptions...); ok {
+				j.Parent = &parent
+			}
+		case "kind":
+			j.Kind, err = msgpack.ReadStringEnumFold(r, Kinds...)
+		case "priority":
+			j.Priority, err = Priorities.Read(r)
+		case "queue":
+			j.Queue, err = msgpack.ReadNillableStringEnum(r, Queues...)
+		case "pool":
+			j.Pool, err = msgpack.ReadNillableStringEnumFold(r, Pools...)
+		case "start":
+			j.Start, err = msgpack.ReadZonedTime(r)
+		case "deadline":
+			j.Deadline, err = msgpack.ReadNillableZonedTime(r)
+		case "every":
+			j.Every, _, err = msgpack.ReadNullable(r, msgpack.ReadDuration)
+		case "timeout":
+			j.Timeout, err = msgpack.ReadNillableDuration(r)
+		case "max_runs":
+			j.MaxRuns, j.Limited, err = msgpack.ReadNullable(r, msgpack.Reader.ReadUint32)
+		case "days":
+			// Older producers write an array of seven bools.
+			var days []bool
+			if days, err = msgpack.ReadBools(r); err == nil {
+				j.Days = [7]bool{}
+				copy(j.Days[:], days)
+			}
+		case "blackout":
+			j.Blackout, err = msgpack.ReadBoolBitset(r)
+		case "weight":
+			j.Weight, err = msgpack.ReadFloat16Ext(r, float16Ext)
+		case "script":
+			j.Script, err = msgpack.ReadByteArrayInto32(r)
+		case "token":
+			j.Token, err = msgpack.ReadByteArrayInto16(r)
+		case "owner":
+			j.Owner.Team, j.Owner.Name, j.Owner.Email, err = msgpack.ReadTuple3(r,
+				msgpack.Reader.ReadString, msgpack.Reader.ReadString, msgpack.Reader.ReadString)
+		case "window":
+			var window Window
+			var isNil bool
+			window.From, window.To, isNil, err = msgpack.ReadNillableTuple2(r,
+				msgpack.Reader.ReadUint8, msgpack.Reader.ReadUint8)
+			if !isNil {
+				j.Window = &window
+			}
+		case "retry":
+			var retry Retry
+			var isNil bool
+			retry.Attempts, retry.Backoff, retry.Factor, isNil, err = msgpack.ReadNillableTuple3(r,
+				msgpack.Reader.ReadUint8, msgpack.ReadDuration, msgpack.Reader.ReadFloat64)
+			if !isNil {
+				j.Retry = &retry
+			}
+		case "notify":
+			var notify Notify
+			var isNil bool
+			notify.Channel, notify.Address, notify.OnFailure, notify.PerHour, isNil, err = msgpack.ReadNillableTuple4(r,
+				msgpack.Reader.ReadString, msgpack.Reader.ReadString, msgpack.Reader.ReadBool, msgpack.Reader.ReadUint32)
+			if !isNil {
+				j.Notify = &notify
+			}
+		case "cron":
+			var cron [5]string
+			var isNil bool
+			read := msgpack.Reader.ReadString
+			cron[0], cron[1], cron[2], cron[3], cron[4], isNil, err = msgpack.ReadNillableTuple5(r,
+				read, read, read, read, read)
+			if !isNil {
+				j.Cron = &cron
+			}
+		default:
+			err = r.Skip()
+		}
+		return err
+	})
+}
@@ -0,0 +1,229 @@
+// Package schedule is a guest that plans the next run of scheduled jobs.
+// A job holds most of the scalar types the package writes beyond those of
+// MessagePack itself, such as UUIDs, durations, times with their zone and
+// half-precision floats, and its small fixed groups of values, such as a
+// cron expression, are written as tuples.
+//
+// Batches of jobs are versioned: version 1 is an array of tuples of the
+// ID, kind, start and interval of a job, and version 2 an array of Job
+// maps.
+package schedule
+
+import (
+	"errors"
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Versions of a batch of jobs.
+const (
+	V1 = 1
+	V2 = 2
+)
+
+// EncodeBatch returns a batch of `jobs` in the current version. Intervals
+// are written in seconds, and empty queue and pool names as nil.
+func EncodeBatch(jobs []Job) ([]byte, error) {
+	write := func(w msgpack.Writer) error {
+		return msgpack.EncodeVersioned(w, V2, func(w msgpack.Writer) error {
+			w.WriteArraySize(uint32(len(jobs)))
+			for i := range jobs {
+				if err := jobs[i].Encode(w); err != nil {
+					return err
+				}
+			}
+			return w.Err()
+		})
+	}
+	options := []msgpack.EncOption{msgpack.WithOmitZeroAsNil(), msgpack.WithDurationUnit(time.Second)}
+	sizer := msgpack.NewSizerWithOptions(options...)
+	if err := write(&sizer); err != nil {
+		return nil, err
+	}
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), options...)
+	if err := write(&encoder); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
+
+// readBatch reads a batch of any version. Integer durations are seconds.
+func readBatch(r msgpack.Reader) ([]Job, error) {
+	var jobs []Job
+	err := msgpack.DecodeVersioned(r, map[uint32]func(msgpack.Reader) error{
+		V1: func(r msgpack.Reader) error {
+			return msgpack.ReadArrayStrict(r, func(_ uint32, r msgpack.Reader) error {
+				job := Job{Priority: "normal", Days: everyDay, Weight: 1}
+				var err error
+				job.ID, job.Kind, job.Start, job.Every, err = msgpack.ReadTuple4(r,
+					func(r msgpack.Reader) ([16]byte, error) { return msgpack.ReadUUID(r, uuidOptions...) },
+					msgpack.Reader.ReadString, msgpack.Reader.ReadTime, msgpack.ReadDuration)
+				jobs = append(jobs, job)
+				return err
+			})
+		},
+		V2: func(r msgpack.Reader) error {
+			return msgpack.ReadArrayStrict(r, func(_ uint32, r msgpack.Reader) error {
+				job := Job{Priority: "normal", Days: everyDay, Weight: 1}
+				err := job.Decode(r)
+				jobs = append(jobs, job)
+				return err
+			})
+		},
+	}, nil)
+	return jobs, err
+}
+
+var everyDay = [7]bool{true, true, true, true, true, true, true}
+
+// maxSteps bounds the intervals Next steps over.
+const maxSteps = 1000
+
+// Next returns the first run of `job` on one of its days, outside of its
+// blackout days and in its window, and false when there is none within
+// 1000 intervals.
+func Next(job *Job) (time.Time, bool) {
+	at := job.Start
+	for i := 0; i < maxSteps; i++ {
+		day := at.YearDay() - 1
+		blackout := day < len(job.Blackout) && job.Blackout[day]
+		inWindow := job.Window == nil || (uint8(at.Hour()) >= job.Window.From && uint8(at.Hour()) < job.Window.To)
+		if job.Days[at.Weekday()] && !blackout && inWindow {
+			return at, job.Deadline == nil || !at.After(*job.Deadline)
+		}
+		if job.Every == 0 {
+			break
+		}
+		at = at.Add(job.Every)
+	}
+	return time.Time{}, false
+}
+
+// Run is the next run of a job, as "plan" returns it.
+type Run struct {
+	ID    [16]byte
+	Kind  string
+	At    time.Time
+	Every time.Duration
+	// Weight is the weight of the job, rounded to half precision.
+	Weight float32
+}
+
+// Plan is the handler of the "plan" operation. The payload is a batch of
+// jobs, and the response a map
+//
+//	{"runs": [[id, kind, at, every, weight], ...]}
+//
+// of the next run of each job that has one, with the ID as a string, the
+// time in the zone of the job, the interval as a string such as "1h0m0s"
+// and the weight as the bits of a half-precision float. A batch of a
+// version this guest does not know, or whose arrays and maps hold more or
+// fewer values than they declare, is answered with
+//
+//	{"error": "...", "versions": [1, 2]}
+//
+// or with "path" in place of "versions", the path of the value in the
+// batch.
+func Plan(payload []byte) ([]byte, error) {
+	decoder := msgpack.NewDecoderWithOptions(payload, msgpack.WithDurationUnitDecoding(time.Second))
+	jobs, err := readBatch(&decoder)
+	var (
+		unsupported msgpack.UnsupportedVersionError
+		structure   msgpack.StructureError
+	)
+	switch {
+	case errors.As(err, &unsupported):
+		return encode(func(w msgpack.Writer) {
+			w.WriteMapSize(2)
+			w.WriteString("error")
+			w.WriteString(unsupported.Error())
+			w.WriteString("versions")
+			w.WriteArraySize(uint32(len(unsupported.Known)))
+			for _, version := range unsupported.Known {
+				w.WriteUint32(version)
+			}
+		})
+	case errors.As(err, &structure):
+		return encode(func(w msgpack.Writer) {
+			w.WriteMapSize(2)
+			w.WriteString("error")
+			w.WriteString(structure.Reason)
+			w.WriteString("path")
+			w.WriteString(structure.Path)
+		})
+	case err != nil:
+		return nil, err
+	}
+
+	runs := make([]Run, 0, len(jobs))
+	for i := range jobs {
+		if at, ok := Next(&jobs[i]); ok {
+			runs = append(runs, Run{jobs[i].ID, jobs[i].Kind, at, jobs[i].Every, jobs[i].Weight})
+		}
+	}
+	return encode(func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("runs")
+		w.WriteArraySize(uint32(len(runs)))
+		for _, run := range runs {
+			weight, _ := msgpack.Float16Bits(run.Weight)
+			msgpack.WriteTuple5(w,
+				run.ID, writeID,
+				run.Kind, msgpack.Writer.WriteString,
+				run.At, msgpack.WriteZonedTime,
+				run.Every, msgpack.WriteDuration,
+				weight, msgpack.Writer.WriteUint16)
+		}
+	}, msgpack.WithDurationAsString())
+}
+
+func writeID(w msgpack.Writer, id [16]byte) {
+	msgpack.WriteUUID(w, id, msgpack.WithUUIDString())
+}
+
+// ReadRuns reads the runs of a response of "plan".
+func ReadRuns(response []byte) ([]Run, error) {
+	decoder := msgpack.NewDecoder(response)
+	size, err := decoder.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	var runs []Run
+	for i := uint32(0); i < size; i++ {
+		key, err := decoder.ReadString()
+		if err != nil {
+			return nil, err
+		}
+		if key != "runs" {
+			if err := decoder.Skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		err = msgpack.ReadArrayStrict(&decoder, func(_ uint32, r msgpack.Reader) error {
+			var run Run
+			var weight uint16
+			var err error
+			run.ID, run.Kind, run.At, run.Every, weight, err = msgpack.ReadTuple5(r,
+				func(r msgpack.Reader) ([16]byte, error) { return msgpack.ReadUUID(r) },
+				msgpack.Reader.ReadString, msgpack.ReadZonedTime, msgpack.ReadDuration, msgpack.Reader.ReadUint16)
+			run.Weight = msgpack.Float16FromBits(weight)
+			runs = append(runs, run)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return runs, nil
+}
+
+// encode sizes and then encodes what `write` writes, with `opts`.
+func encode(write func(w msgpack.Writer), opts ...msgpack.EncOption) ([]byte, error) {
+	sizer := msgpack.NewSizerWithOptions(opts...)
+	write(&sizer)
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), opts...)
+	write(&encoder)
+	return encoder.Bytes(), encoder.Err()
+}
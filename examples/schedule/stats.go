@@ -0,0 +1,105 @@
+package schedule
+
+import (
+	"errors"
+	"time"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// runSpec are the fields of the run records "stats" reads.
+var runSpec = msgpack.ColumnSpec{
+	"kind":  msgpack.ColumnString,
+	"took":  msgpack.ColumnFloat64,
+	"ok":    msgpack.ColumnBool,
+	"tries": msgpack.ColumnInt64,
+	"at":    msgpack.ColumnTime,
+}
+
+// Stats is the handler of the "stats" operation. The payload is an array of
+// the records of past runs
+//
+//	[{"kind": "backup", "took": 1.5, "ok": true, "tries": 1, "at": t}, ...]
+//
+// where every field but "kind" may be left out, and the response a map
+//
+//	{"runs": 3, "failed": 1, "retried": 1, "took": {"backup": 1.5}, "last": t}
+//
+// of the number of runs, of those that failed and of those retried, the
+// mean seconds the runs of each kind took and the time of the latest run.
+// A run whose "ok" is left out did not fail. A record with a field of the
+// wrong type is answered with {"error": "...", "row": index}.
+func Stats(payload []byte) ([]byte, error) {
+	decoder := msgpack.NewDecoder(payload)
+	columns, err := msgpack.ReadColumns(&decoder, runSpec)
+	var columnErr msgpack.ColumnError
+	if errors.As(err, &columnErr) {
+		return encode(func(w msgpack.Writer) {
+			w.WriteMapSize(2)
+			w.WriteString("error")
+			w.WriteString(columnErr.Error())
+			w.WriteString("row")
+			w.WriteInt64(int64(columnErr.Row))
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return encode(func(w msgpack.Writer) { writeStats(w, columns) })
+}
+
+func writeStats(w msgpack.Writer, columns msgpack.Columns) {
+	kinds, took, ok := columns.Column("kind"), columns.Column("took"), columns.Column("ok")
+	tries := columns.Column("tries")
+	var (
+		failed, retried uint32
+		sums            = map[string]float64{}
+		counts          = map[string]float64{}
+		order           []string
+	)
+	for row := 0; row < columns.Rows; row++ {
+		if ok.Has(row) && !ok.Bool[row] {
+			failed++
+		}
+		if tries.Int64[row] > 1 {
+			retried++
+		}
+		if !took.Has(row) {
+			continue
+		}
+		kind := kinds.String[row]
+		if _, seen := counts[kind]; !seen {
+			order = append(order, kind)
+		}
+		sums[kind] += took.Float64[row]
+		counts[kind]++
+	}
+
+	w.WriteMapSize(5)
+	w.WriteString("runs")
+	w.WriteUint32(uint32(columns.Rows))
+	w.WriteString("failed")
+	w.WriteUint32(failed)
+	w.WriteString("retried")
+	w.WriteUint32(retried)
+	w.WriteString("took")
+	w.WriteMapSize(uint32(len(order)))
+	for _, kind := range order {
+		w.WriteString(kind)
+		w.WriteFloat64(sums[kind] / counts[kind])
+	}
+	w.WriteString("last")
+	w.WriteTime(latest(columns.Column("at"), columns.Rows))
+}
+
+// latest returns the latest time of the `rows` of `at`, or the zero time
+// when it has none.
+func latest(at *msgpack.Column, rows int) time.Time {
+	var last time.Time
+	for row := 0; row < rows; row++ {
+		if at.Has(row) && at.Time[row].After(last) {
+			last = at.Time[row]
+		}
+	}
+	return last
+}
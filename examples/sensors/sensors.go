@@ -0,0 +1,177 @@
+// Package sensors is a guest that batches the readings of a sensor into a
+// column of timestamps and a column of values, and summarizes the batches
+// sent to it. The columns are arrays of int64 and float64 values, which
+// ReadInt64SliceFast and ReadFloat64SliceFast convert in one pass.
+package sensors
+
+import (
+	"errors"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// ErrBatch is returned for a batch that is not a sensor name followed by
+// two columns of the same length.
+var ErrBatch = errors.New("sensors: malformed batch")
+
+// Batcher collects the readings of one sensor.
+type Batcher struct {
+	Sensor string
+	times  []int64
+	values []float64
+}
+
+// Add adds a reading taken at `unixMilli`, in milliseconds since the Unix
+// epoch.
+func (b *Batcher) Add(unixMilli int64, value float64) {
+	b.times = append(b.times, unixMilli)
+	b.values = append(b.values, value)
+}
+
+// Len returns the number of readings added since the last Flush.
+func (b *Batcher) Len() int {
+	return len(b.times)
+}
+
+// Flush returns the readings added since the last Flush as a batch
+//
+//	[sensor, [time, ...], [value, ...]]
+//
+// and starts a new one. Times of this century are past the int32 range, so
+// every time is written as an int64, and every value as a float64.
+func (b *Batcher) Flush() ([]byte, error) {
+	sizer := msgpack.NewSizer()
+	b.write(&sizer)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	b.write(&encoder)
+	if err := encoder.Err(); err != nil {
+		return nil, err
+	}
+	b.times = b.times[:0]
+	b.values = b.values[:0]
+	return encoder.Bytes(), nil
+}
+
+func (b *Batcher) write(w msgpack.Writer) {
+	w.WriteArraySize(3)
+	w.WriteString(b.Sensor)
+	w.WriteArraySize(uint32(len(b.times)))
+	for _, t := range b.times {
+		w.WriteInt64(t)
+	}
+	w.WriteArraySize(uint32(len(b.values)))
+	for _, v := range b.values {
+		w.WriteFloat64(v)
+	}
+}
+
+// Summary describes a batch.
+type Summary struct {
+	Sensor string
+	Count  uint32
+	First  int64
+	Last   int64
+	Min    float64
+	Max    float64
+	Mean   float64
+}
+
+func (s *Summary) Encode(w msgpack.Writer) error {
+	w.WriteMapSize(7)
+	w.WriteString("sensor")
+	w.WriteString(s.Sensor)
+	w.WriteString("count")
+	w.WriteUint32(s.Count)
+	w.WriteString("first")
+	w.WriteInt64(s.First)
+	w.WriteString("last")
+	w.WriteInt64(s.Last)
+	w.WriteString("min")
+	w.WriteFloat64(s.Min)
+	w.WriteString("max")
+	w.WriteFloat64(s.Max)
+	w.WriteString("mean")
+	w.WriteFloat64(s.Mean)
+	return w.Err()
+}
+
+func (s *Summary) Decode(r msgpack.Reader) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "sensor":
+			s.Sensor, err = r.ReadString()
+		case "count":
+			s.Count, err = r.ReadUint32()
+		case "first":
+			s.First, err = r.ReadInt64()
+		case "last":
+			s.Last, err = r.ReadInt64()
+		case "min":
+			s.Min, err = r.ReadFloat64()
+		case "max":
+			s.Max, err = r.ReadFloat64()
+		case "mean":
+			s.Mean, err = r.ReadFloat64()
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Summarize is the handler of the "summarize" operation. It reads a batch
+// written by Flush and returns its Summary.
+func Summarize(payload []byte) ([]byte, error) {
+	decoder := msgpack.NewDecoder(payload)
+	size, err := decoder.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	if size != 3 {
+		return nil, ErrBatch
+	}
+	sensor, err := decoder.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	times, err := decoder.ReadInt64SliceFast()
+	if err != nil {
+		return nil, err
+	}
+	values, err := decoder.ReadFloat64SliceFast()
+	if err != nil {
+		return nil, err
+	}
+	if len(times) != len(values) {
+		return nil, ErrBatch
+	}
+
+	summary := Summary{Sensor: sensor, Count: uint32(len(values))}
+	if len(values) > 0 {
+		summary.First, summary.Last = times[0], times[len(times)-1]
+		summary.Min, summary.Max = values[0], values[0]
+		sum := 0.0
+		for _, v := range values {
+			if v < summary.Min {
+				summary.Min = v
+			}
+			if v > summary.Max {
+				summary.Max = v
+			}
+			sum += v
+		}
+		summary.Mean = sum / float64(len(values))
+	}
+	return msgpack.ToBytes(&summary)
+}
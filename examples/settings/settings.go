@@ -0,0 +1,229 @@
+// Package settings is a guest that resolves the configuration of a service
+// from layers of encoded documents without decoding them: it merges the
+// settings of a site over the defaults, applies a patch, and reports what
+// changed, all on Raw values.
+package settings
+
+import (
+	"errors"
+	"sort"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// ErrNoDefaults is returned for a request without defaults.
+var ErrNoDefaults = errors.New("settings: request has no defaults")
+
+// validateOptions bound the documents of a request.
+var validateOptions = []msgpack.ValidateOption{
+	msgpack.WithValidateMaxDepth(8),
+	msgpack.WithValidateMaxElements(1024),
+}
+
+// Request keys. The other keys of a request, such as an ID, are returned
+// in the response.
+const (
+	keyDefaults = "defaults"
+	keySite     = "site"
+	keyPatch    = "patch"
+)
+
+// Configure is the handler of the "configure" operation. The payload is a
+// map
+//
+//	{"defaults": {...}, "site": {...}, "patch": [...]}
+//
+// of the default configuration, the settings of a site, which override
+// the defaults key by key at every level, and a patch applied to the
+// result, as ApplyPatch takes it. "site" and "patch" may be left out. The
+// response is a map holding, with the keys of the request it does not
+// know,
+//
+//	{"config": {...}, "changes": [...], "differences": {...}, "summary": {...}}
+//
+// the configuration with its keys sorted, the patch that turns the defaults
+// into it, the number of differences from the defaults of each kind, and
+// the name, priority and rate limit of the service. A patch that does not apply is
+// left out, and the index of the operation that failed is returned under
+// "rejected".
+func Configure(payload []byte) ([]byte, error) {
+	decoder := msgpack.NewDecoder(payload)
+	request, err := msgpack.ReadRawMap(&decoder)
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.ExpectEOF(); err != nil {
+		return nil, err
+	}
+	defaults, site, patch := request[keyDefaults], request[keySite], request[keyPatch]
+	if defaults == nil {
+		return nil, ErrNoDefaults
+	}
+	for _, document := range []msgpack.Raw{defaults, site, patch} {
+		if document == nil {
+			continue
+		}
+		if err := msgpack.Validate(document, validateOptions...); err != nil {
+			return nil, err
+		}
+	}
+
+	config := defaults
+	if site != nil {
+		if config, err = msgpack.MergeRaw(config, site, msgpack.WithDeepMerge()); err != nil {
+			return nil, err
+		}
+	}
+	var rejected msgpack.Raw
+	if patch != nil {
+		patched, err := msgpack.ApplyPatch(config, patch)
+		var patchErr msgpack.PatchError
+		switch {
+		case errors.As(err, &patchErr):
+			if rejected, err = encode(func(w msgpack.Writer) error {
+				w.WriteInt64(int64(patchErr.Index))
+				return w.Err()
+			}); err != nil {
+				return nil, err
+			}
+		case err != nil:
+			return nil, err
+		default:
+			config = patched
+		}
+	}
+	if config, err = msgpack.Transcode(config, msgpack.WithSortedStringMaps()); err != nil {
+		return nil, err
+	}
+	changes, err := msgpack.DiffToPatch(defaults, config)
+	if err != nil {
+		return nil, err
+	}
+	diffs, err := msgpack.Diff(defaults, config)
+	if err != nil {
+		return nil, err
+	}
+	differences, err := encode(func(w msgpack.Writer) error {
+		return writeDifferences(w, diffs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	summary, err := summarize(config)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(request, keyDefaults)
+	delete(request, keySite)
+	delete(request, keyPatch)
+	response := msgpack.MergeRawMaps(request, map[string]msgpack.Raw{
+		"config":      config,
+		"changes":     changes,
+		"differences": differences,
+		"summary":     summary,
+	})
+	if rejected != nil {
+		response["rejected"] = rejected
+	}
+	// The values were all validated or written here.
+	return encode(func(w msgpack.Writer) error {
+		return msgpack.WriteRawMap(w, response, msgpack.WithUnvalidatedRaw())
+	}, msgpack.WithSortedStringMaps())
+}
+
+// writeDifferences writes the number of `diffs` of each kind other than
+// DiffFormat, keyed by the name of the kind.
+func writeDifferences(w msgpack.Writer, diffs []msgpack.Difference) error {
+	counts := map[msgpack.DifferenceKind]uint32{}
+	if !msgpack.SemanticallyEqual(diffs) {
+		for _, diff := range diffs {
+			if diff.Kind != msgpack.DiffFormat {
+				counts[diff.Kind]++
+			}
+		}
+	}
+	kinds := make([]msgpack.DifferenceKind, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	w.WriteMapSize(uint32(len(kinds)))
+	for _, kind := range kinds {
+		w.WriteString(kind.String())
+		w.WriteUint32(counts[kind])
+	}
+	return w.Err()
+}
+
+// ErrRate is returned for a rate limit that is not a count.
+var ErrRate = errors.New("settings: limits.rps is not a count")
+
+// summarize returns the name of the service, copied as it is encoded, its
+// priority, and its rate limit, 0 for none.
+func summarize(config msgpack.Raw) (msgpack.Raw, error) {
+	name, err := msgpack.GetPath(config, "service", "name")
+	if err != nil {
+		return nil, err
+	}
+	// Priorities are nice levels, from -20 to 19, so they always fit the
+	// formats ReadInt64 reads.
+	priority, err := msgpack.GetInt64(config, "service", "priority")
+	if notFound(err) {
+		priority, err = 0, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rps uint64
+	limit, err := msgpack.GetPath(config, "limits", "rps")
+	switch {
+	case notFound(err):
+	case err != nil:
+		return nil, err
+	default:
+		// Transcode wrote the limit in an unsigned format, which only
+		// Value reads whatever the sign of the format.
+		value, err := msgpack.DecodeValue(limit)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if rps, ok = value.Uint(); !ok {
+			return nil, ErrRate
+		}
+	}
+	return encode(func(w msgpack.Writer) error {
+		return msgpack.WriteMapWithRawValues(w, []msgpack.RawEntry{
+			{Key: "name", Raw: name},
+			{Key: "priority", Write: func(w msgpack.Writer) { w.WriteInt64(priority) }},
+			{Key: "rps", Write: func(w msgpack.Writer) { w.WriteUint64(rps) }},
+		})
+	})
+}
+
+// notFound reports whether `err` is from a path that does not exist, as
+// opposed to one that goes through a value of the wrong kind.
+func notFound(err error) bool {
+	var pathErr msgpack.PathError
+	return errors.As(err, &pathErr) && errors.Is(pathErr.Err, msgpack.ErrNotFound)
+}
+
+// ServiceName returns the name of the service of a response of
+// "configure".
+func ServiceName(response []byte) (string, error) {
+	return msgpack.GetString(response, "summary", "name")
+}
+
+// encode sizes and then encodes what `write` writes, with `opts`.
+func encode(write func(w msgpack.Writer) error, opts ...msgpack.EncOption) (msgpack.Raw, error) {
+	sizer := msgpack.NewSizerWithOptions(opts...)
+	if err := write(&sizer); err != nil {
+		return nil, err
+	}
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), opts...)
+	if err := write(&encoder); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
@@ -0,0 +1,80 @@
+//go:build !tinygo
+// +build !tinygo
+
+package msgpack_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/examples/meters"
+)
+
+// The frames of the meters example are read by host tools, with
+// DecodePositional.
+func TestExampleMeters(t *testing.T) {
+	frame, err := msgpack.GetPath(exampleCall(t, "collect").Response, "frames", 0)
+	require.NoError(t, err)
+	decoder := msgpack.NewDecoder(frame)
+	frame, err = decoder.ReadByteArray()
+	require.NoError(t, err)
+	readings, err := meters.ReadFrame(frame)
+	require.NoError(t, err)
+	require.Len(t, readings, 3)
+	assert.Equal(t, "MTR-0001", readings[0].Serial)
+	assert.Equal(t, int64(1700000000), readings[0].At.Unix())
+	assert.Nil(t, readings[0].Tariff)
+	require.NotNil(t, readings[2].Tariff)
+	assert.Equal(t, "night", *readings[2].Tariff)
+	rewritten, err := meters.WriteFrame(readings)
+	require.NoError(t, err)
+	assert.Equal(t, frame, msgpack.Raw(rewritten))
+
+	// More readings than MaxPerFrame, and than bytes in a frame, are cut
+	// to those that fit.
+	log := encodeWith(t, func(w msgpack.Writer) {
+		for i := 0; i < 300; i++ {
+			w.WriteArraySize(3)
+			w.WriteString("M")
+			w.WriteTime(time.Unix(int64(i), 0))
+			w.WriteUint8(uint8(i))
+		}
+	})
+	payload, err := meters.Collect(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("log")
+		w.WriteByteArray(log)
+	}))
+	require.NoError(t, err)
+	var frames, total int
+	for i := 0; ; i++ {
+		frame, err := msgpack.GetPath(payload, "frames", i)
+		if errors.Is(err, msgpack.ErrNotFound) {
+			break
+		}
+		require.NoError(t, err)
+		decoder := msgpack.NewDecoder(frame)
+		frame, err = decoder.ReadByteArray()
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(frame), meters.FrameSize)
+		readings, err := meters.ReadFrame(frame)
+		require.NoError(t, err)
+		assert.Len(t, readings, 5)
+		frames++
+		total += len(readings)
+	}
+	assert.Equal(t, 60, frames)
+	assert.Equal(t, 300, total)
+
+	_, err = meters.Collect(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("rows")
+		w.WriteByteArray(make([]byte, 20))
+	}))
+	assert.Error(t, err)
+}
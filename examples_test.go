@@ -0,0 +1,491 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/examples/accounts"
+	"github.com/wapc/tinygo-msgpack/examples/devices"
+	"github.com/wapc/tinygo-msgpack/examples/events"
+	"github.com/wapc/tinygo-msgpack/examples/fixtures"
+	"github.com/wapc/tinygo-msgpack/examples/greeter"
+	"github.com/wapc/tinygo-msgpack/examples/objects"
+	"github.com/wapc/tinygo-msgpack/examples/passthrough"
+	"github.com/wapc/tinygo-msgpack/examples/schedule"
+	"github.com/wapc/tinygo-msgpack/examples/sensors"
+	"github.com/wapc/tinygo-msgpack/examples/settings"
+)
+
+func TestExampleCalls(t *testing.T) {
+	for _, call := range fixtures.Calls() {
+		t.Run(call.Operation, func(t *testing.T) {
+			response, err := call.Handle(call.Request)
+			require.NoError(t, err)
+			assert.Equal(t, call.Response, response)
+		})
+	}
+}
+
+func TestExampleGreeter(t *testing.T) {
+	language := "de"
+	payload, err := msgpack.ToBytes(&greeter.Request{Name: "Ada", Language: &language, Sent: fixtures.Sent})
+	require.NoError(t, err)
+	payload, err = greeter.Greet(payload)
+	require.NoError(t, err)
+	decoder := msgpack.NewDecoder(payload)
+	response, err := msgpack.Decode[greeter.Response](&decoder)
+	require.NoError(t, err)
+	assert.True(t, fixtures.Sent.Equal(response.Sent))
+	response.Sent = fixtures.Sent
+	assert.Equal(t, greeter.Response{Message: "Hallo, Ada!", Sent: fixtures.Sent}, response)
+
+	// The name is required.
+	payload, err = msgpack.ToBytes(&greeter.Response{Message: "Hi"})
+	require.NoError(t, err)
+	_, err = greeter.Greet(payload)
+	assert.Error(t, err)
+}
+
+func TestExampleSensors(t *testing.T) {
+	times, values := fixtures.Readings()
+	batcher := sensors.Batcher{Sensor: "kitchen"}
+	for i := range times {
+		batcher.Add(times[i], values[i])
+	}
+	assert.Equal(t, 4, batcher.Len())
+	batch, err := batcher.Flush()
+	require.NoError(t, err)
+	assert.Equal(t, fixtures.Calls()[1].Request, batch)
+	assert.Equal(t, 0, batcher.Len())
+
+	payload, err := sensors.Summarize(batch)
+	require.NoError(t, err)
+	decoder := msgpack.NewDecoder(payload)
+	summary, err := msgpack.Decode[sensors.Summary](&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, sensors.Summary{
+		Sensor: "kitchen",
+		Count:  4,
+		First:  1700000000000,
+		Last:   1700000180000,
+		Min:    19.5,
+		Max:    23,
+		Mean:   21,
+	}, summary)
+
+	batch, err = batcher.Flush()
+	require.NoError(t, err)
+	payload, err = sensors.Summarize(batch)
+	require.NoError(t, err)
+	decoder = msgpack.NewDecoder(payload)
+	summary, err = msgpack.Decode[sensors.Summary](&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, sensors.Summary{Sensor: "kitchen"}, summary)
+
+	_, err = sensors.Summarize(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(3)
+		w.WriteString("kitchen")
+		w.WriteArraySize(1)
+		w.WriteInt64(1)
+		w.WriteArraySize(0)
+	}))
+	assert.ErrorIs(t, err, sensors.ErrBatch)
+}
+
+func TestExamplePassthrough(t *testing.T) {
+	payload, err := passthrough.Route(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("to")
+		w.WriteString("audit")
+	}))
+	require.NoError(t, err)
+	routed, err := msgpack.DecodeValue(payload)
+	require.NoError(t, err)
+	hops, _ := routed.MapIndex("hops")
+	n, ok := hops.Uint()
+	require.True(t, ok)
+	assert.Equal(t, uint64(1), n)
+	body, _ := routed.MapIndex("body")
+	assert.Equal(t, msgpack.KindNil, body.Kind())
+
+	_, err = passthrough.Route(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("hops")
+		w.WriteUint32(1)
+	}))
+	assert.ErrorIs(t, err, passthrough.ErrNoDestination)
+}
+
+// exampleCall returns the fixture call of `operation`.
+func exampleCall(t *testing.T, operation string) fixtures.Call {
+	for _, call := range fixtures.Calls() {
+		if call.Operation == operation {
+			return call
+		}
+	}
+	t.Fatalf("no call of %q", operation)
+	return fixtures.Call{}
+}
+
+func TestExampleDevices(t *testing.T) {
+	device := &devices.Device{ID: "d-1", Model: "t100"}
+	payload, err := devices.EncodeUpdate(device, &devices.Device{Model: "t200"}, []string{"model"}, nil)
+	require.NoError(t, err)
+	payload, err = devices.Update(payload)
+	require.NoError(t, err)
+	decoder := msgpack.NewDecoder(payload)
+	updated, err := msgpack.Decode[devices.Device](&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, "d-1", updated.ID)
+	assert.Equal(t, "t200", updated.Model)
+	assert.Contains(t, devices.Keys(), "update")
+
+	_, err = devices.Update(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(0)
+	}))
+	assert.ErrorIs(t, err, devices.ErrNoDevice)
+}
+
+func TestExampleSettings(t *testing.T) {
+	name, err := settings.ServiceName(exampleCall(t, "configure").Response)
+	require.NoError(t, err)
+	assert.Equal(t, "api", name)
+
+	_, err = settings.Configure(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("request")
+		w.WriteString("cfg-8")
+	}))
+	assert.ErrorIs(t, err, settings.ErrNoDefaults)
+}
+
+func TestExampleEvents(t *testing.T) {
+	log, dropped, err := events.Log([]any{
+		map[string]any{"type": "view", "data": map[string]any{"user": 7.0}},
+		map[string]any{"type": "view", "data": map[string]any{"user": func() {}}},
+		map[string]any{"type": "click"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+	totals, err := events.Ingest(log)
+	require.NoError(t, err)
+	views, err := msgpack.GetInt64(totals, "types", "view")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), views)
+	clicks, err := msgpack.GetInt64(totals, "types", "click")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), clicks)
+}
+
+func TestExampleSchedule(t *testing.T) {
+	runs, err := schedule.ReadRuns(exampleCall(t, "plan").Response)
+	require.NoError(t, err)
+	require.NotEmpty(t, runs)
+	for _, run := range runs {
+		assert.NotEmpty(t, run.Kind)
+	}
+
+	batch, err := schedule.EncodeBatch(nil)
+	require.NoError(t, err)
+	payload, err := schedule.Plan(batch)
+	require.NoError(t, err)
+	runs, err = schedule.ReadRuns(payload)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestExampleAccounts(t *testing.T) {
+	request, err := accounts.Request(7, "hello", func(w msgpack.Writer) error {
+		w.WriteArraySize(1)
+		features := msgpack.Features()
+		return features.Encode(w)
+	})
+	require.NoError(t, err)
+	assert.Zero(t, len(request)%16)
+	payload, err := accounts.Call(request)
+	require.NoError(t, err)
+	response, err := accounts.Response(payload)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), response.MsgID)
+	assert.Equal(t, msgpack.Raw{msgpack.FormatNil}, response.Error)
+
+	logged := accounts.Logged()
+	notification, err := accounts.Log("info", "signed up")
+	require.NoError(t, err)
+	payload, err = accounts.Call(notification)
+	require.NoError(t, err)
+	assert.Nil(t, payload)
+	assert.Equal(t, logged+1, accounts.Logged())
+
+	_, err = accounts.Response(notification)
+	assert.Error(t, err)
+}
+
+func TestExampleObjects(t *testing.T) {
+	data := make([]byte, 600)
+	for i := range data {
+		data[i] = byte(i % 7)
+	}
+	request := &objects.StoreRequest{
+		Bucket: "docs",
+		Owner:  &objects.Owner{Name: "Ada", Team: "eng"},
+		Objects: []objects.Object{
+			{ID: 1, Key: "a.txt", Type: "text/plain", Data: data},
+			{ID: 2, Key: "b.txt", Type: "text/plain", Data: []byte("b")},
+		},
+	}
+	block, err := objects.Block(request)
+	require.NoError(t, err)
+	payload, err := objects.Upload(block, request)
+	require.NoError(t, err)
+	grown, err := objects.Upload(make([]byte, 8), request)
+	require.NoError(t, err)
+	assert.Equal(t, payload, grown)
+
+	response, err := objects.Store(payload)
+	require.NoError(t, err)
+	var blobs [][]byte
+	var log []byte
+	for i := 0; i < 2; i++ {
+		raw, err := msgpack.GetPath(response, "records", i)
+		require.NoError(t, err)
+		decoder := msgpack.NewDecoder(raw)
+		blob, err := decoder.ReadByteArray()
+		require.NoError(t, err)
+		blobs = append(blobs, blob)
+		log = append(log, raw...)
+	}
+	record, err := objects.ReadRecord(blobs[0])
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", record.Key)
+	content, err := record.Content()
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+
+	// Records are scanned from a log across the chunks they span.
+	var keys []string
+	err = objects.Scan(bytes.NewReader(log), func(record *objects.Record) error {
+		keys = append(keys, record.Key)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "b.txt"}, keys)
+	err = objects.Scan(bytes.NewReader(log[:len(log)-1]), func(*objects.Record) error { return nil })
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	payload, err = objects.EncodePatch(blobs[1], map[string]func(msgpack.Writer){
+		"key": func(w msgpack.Writer) { w.WriteString("c.txt") },
+	}, []string{"owner"})
+	require.NoError(t, err)
+	patched, err := objects.Patch(payload)
+	require.NoError(t, err)
+	record, err = objects.ReadRecord(patched)
+	require.NoError(t, err)
+	assert.Equal(t, "c.txt", record.Key)
+	assert.Nil(t, record.Owner)
+
+	payload = encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("records")
+		w.WriteArraySize(2)
+		w.WriteByteArray(blobs[0])
+		w.WriteByteArray(patched)
+	})
+	response, err = objects.List(payload)
+	require.NoError(t, err)
+	listing, err := objects.ReadListing(response)
+	require.NoError(t, err)
+	require.Len(t, listing.Entries, 2)
+	assert.Equal(t, objects.Entry{Key: "a.txt", Type: "text/plain", Size: 600, Owner: "Ada", Meta: 3}, listing.Entries[0])
+	assert.Equal(t, "", listing.Entries[1].Owner)
+	assert.Empty(t, listing.Corrupt)
+	assert.Empty(t, listing.Next)
+}
+
+// The examples use every exported function and type, so that building
+// them with the tags of a constrained build finds any the build lacks. A
+// type is used when the examples name it or call one of its constructors,
+// the functions whose first result is the type, or use one of its
+// constants.
+func TestExamplesCoverAPI(t *testing.T) {
+	fset := token.NewFileSet()
+	declared := map[string]bool{}
+	constructors := map[string][]string{}
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		require.NoError(t, err)
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil && decl.Name.IsExported() {
+					declared[decl.Name.Name] = true
+					if results := decl.Type.Results; results != nil {
+						typeName := baseTypeName(results.List[0].Type)
+						constructors[typeName] = append(constructors[typeName], decl.Name.Name)
+					}
+				}
+			case *ast.GenDecl:
+				typeName := ""
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						if spec.Name.IsExported() {
+							declared[spec.Name.Name] = true
+						}
+					case *ast.ValueSpec:
+						// Constants without a type repeat the one
+						// before them, as with iota.
+						if decl.Tok != token.CONST {
+							continue
+						}
+						if spec.Type != nil || spec.Values != nil {
+							typeName = baseTypeName(spec.Type)
+						}
+						for _, name := range spec.Names {
+							constructors[typeName] = append(constructors[typeName], name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	referenced := map[string]bool{}
+	err = filepath.Walk("examples", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		pkgName := ""
+		for _, spec := range file.Imports {
+			if p, _ := strconv.Unquote(spec.Path.Value); p == "github.com/wapc/tinygo-msgpack" {
+				pkgName = "msgpack"
+				if spec.Name != nil {
+					pkgName = spec.Name.Name
+				}
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := sel.X.(*ast.Ident); ok && id.Name == pkgName {
+					referenced[sel.Sel.Name] = true
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	require.NoError(t, err)
+
+	var missing []string
+	for name := range declared {
+		used := referenced[name]
+		for _, constructor := range constructors[name] {
+			used = used || referenced[constructor]
+		}
+		if !used {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	assert.Empty(t, missing, "use these in an example guest")
+}
+
+// baseTypeName returns the name of the type `expr`, without pointers and
+// type arguments, or "" for other types.
+func baseTypeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.StarExpr:
+		return baseTypeName(expr.X)
+	case *ast.IndexExpr:
+		return baseTypeName(expr.X)
+	case *ast.IndexListExpr:
+		return baseTypeName(expr.X)
+	}
+	return ""
+}
+
+// The examples build with the tags TinyGo sets, and the guest passes its
+// self test in host builds.
+func TestExamplesBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the examples with the go command")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command")
+	}
+	for _, build := range []struct {
+		name string
+		tags string
+		env  []string
+		run  bool
+	}{
+		{"host", "", nil, true},
+		{"purego", "purego", nil, true},
+		{"tinygo", "tinygo purego", nil, true},
+		{"tinygo wasm", "tinygo tinygo.wasm purego", []string{"GOOS=wasip1", "GOARCH=wasm"}, false},
+	} {
+		t.Run(build.name, func(t *testing.T) {
+			commands := [][]string{{"build", "-tags", build.tags, "./examples/..."}}
+			if build.run {
+				commands = append(commands, []string{"run", "-tags", build.tags, "./examples/guest", "selftest"})
+			}
+			for _, args := range commands {
+				cmd := exec.Command(goCmd, args...)
+				cmd.Env = append(os.Environ(), build.env...)
+				output, err := cmd.CombinedOutput()
+				require.NoError(t, err, string(output))
+			}
+		})
+	}
+}
+
+// TestBuildTags runs the tests of this package as built for TinyGo and
+// with strings copied rather than aliased, so that a test assuming the
+// host build fails here rather than only when someone builds that way.
+func TestBuildTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the tests with the go command")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command")
+	}
+	for _, tags := range []string{"purego", "tinygo"} {
+		t.Run(tags, func(t *testing.T) {
+			for _, args := range [][]string{
+				{"vet", "-tags", tags, "."},
+				// -short keeps this test from running itself.
+				{"test", "-short", "-tags", tags, "."},
+			} {
+				output, err := exec.Command(goCmd, args...).CombinedOutput()
+				require.NoError(t, err, string(output))
+			}
+		})
+	}
+}
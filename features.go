@@ -0,0 +1,295 @@
+package msgpack
+
+// FeatureVersion is the version of the feature list of this package. It
+// grows by one whenever a Feature is added, so that a peer can tell which
+// features an older build did not know about.
+const FeatureVersion = 1
+
+// Feature is a set of encoding behaviors a build of this package supports.
+// The features a host and a guest may rely on are the ones both support,
+// as computed by Negotiate.
+type Feature uint64
+
+const (
+	// FeatureStringTable is WithStringTable and WithStringTableDecoding.
+	FeatureStringTable Feature = 1 << iota
+	// FeatureSortedMaps is WithSortedStringMaps and WithSortedIntMaps,
+	// which make encodings canonical.
+	FeatureSortedMaps
+	// FeatureDurationString is WithDurationAsString and the reading of
+	// durations written as strings.
+	FeatureDurationString
+	// FeatureZonedTime is WriteZonedTime and ReadZonedTime.
+	FeatureZonedTime
+	// FeatureUUID is WriteUUID and ReadUUID.
+	FeatureUUID
+	// FeatureFloat16 is the half-precision float encoding.
+	FeatureFloat16
+	// FeatureChecksum is ToBytesChecked and its checksum ext value.
+	FeatureChecksum
+	// FeatureCoercion is WithStringToNumberCoercion and
+	// WithNumberToStringCoercion.
+	FeatureCoercion
+	// FeatureUnsafeStrings is UnsafeString and UnsafeBytes converting
+	// without copying, which builds tagged purego or appengine do not. It
+	// describes the local build only.
+	FeatureUnsafeStrings
+)
+
+// featureNames are the names features are encoded as, by bit.
+var featureNames = []string{
+	"string_table",
+	"sorted_maps",
+	"duration_string",
+	"zoned_time",
+	"uuid",
+	"float16",
+	"checksum",
+	"coercion",
+	"unsafe_strings",
+}
+
+// String returns the names of the features in `f`, separated by "|".
+func (f Feature) String() string {
+	s := ""
+	for i, name := range featureNames {
+		if f&(1<<i) == 0 {
+			continue
+		}
+		if s != "" {
+			s += "|"
+		}
+		s += name
+	}
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// FeatureSet describes what a build of this package supports, for hosts
+// and guests that settle on the encoding features they use in a
+// handshake. Features returns the set of this build, Encode writes it and
+// DecodeFeatureSet reads the set of the peer.
+type FeatureSet struct {
+	// Version is the FeatureVersion of the build.
+	Version uint32
+	// Features are the features the build supports.
+	Features Feature
+	// TimeExtTypes are the ext types ReadTime accepts as timestamps.
+	// Times are written with type -1.
+	TimeExtTypes []int8
+	// AnyExtTypes are the ext types ReadAny decodes without options.
+	// Others are an error, and string table references need
+	// WithStringTableDecoding.
+	AnyExtTypes []int8
+	// MaxDepth is the nesting depth a Decoder allows by default, where 0
+	// is no limit. See WithMaxDepth.
+	MaxDepth uint32
+}
+
+// Features returns the FeatureSet of this build.
+func Features() FeatureSet {
+	features := Feature(1)<<len(featureNames) - 1
+	if !unsafeStrings {
+		features &^= FeatureUnsafeStrings
+	}
+	return FeatureSet{
+		Version:      FeatureVersion,
+		Features:     features,
+		TimeExtTypes: []int8{-1, 13},
+		AnyExtTypes:  []int8{},
+	}
+}
+
+// Has reports whether the set includes every feature in `f`.
+func (fs FeatureSet) Has(f Feature) bool {
+	return fs.Features&f == f
+}
+
+// Negotiate returns the features `local` may use with `remote`:
+//
+//   - Version is the lower of the two.
+//   - Features are those both sides support. Each side must understand
+//     what the other writes: string tables and durations written as
+//     strings need a decoder that reads them, and sorted maps only make
+//     encodings comparable when both sides sort. FeatureUnsafeStrings
+//     never affects the wire, and is kept only when both builds have it.
+//   - TimeExtTypes and AnyExtTypes are the ext types both sides accept, in
+//     the order of `local`.
+//   - MaxDepth is the lower of the two limits, ignoring a side without
+//     one, so that values written for the peer fit both.
+func Negotiate(local, remote FeatureSet) FeatureSet {
+	fs := FeatureSet{
+		Version:      local.Version,
+		Features:     local.Features & remote.Features,
+		TimeExtTypes: intersectExtTypes(local.TimeExtTypes, remote.TimeExtTypes),
+		AnyExtTypes:  intersectExtTypes(local.AnyExtTypes, remote.AnyExtTypes),
+		MaxDepth:     local.MaxDepth,
+	}
+	if remote.Version < fs.Version {
+		fs.Version = remote.Version
+	}
+	if remote.MaxDepth != 0 && (fs.MaxDepth == 0 || remote.MaxDepth < fs.MaxDepth) {
+		fs.MaxDepth = remote.MaxDepth
+	}
+	return fs
+}
+
+func intersectExtTypes(a, b []int8) []int8 {
+	common := []int8{}
+	for _, t := range a {
+		for _, u := range b {
+			if t == u {
+				common = append(common, t)
+				break
+			}
+		}
+	}
+	return common
+}
+
+// featureSetFormat is the version of the envelope FeatureSet.Encode
+// writes.
+const featureSetFormat = 1
+
+// Encode writes `fs` in a versioned envelope (see EncodeVersioned) around
+// a map, with the features as a list of names:
+//
+//	[1, {"version": 1, "features": ["string_table", ...],
+//	     "time_ext_types": [-1, 13], "any_ext_types": [], "max_depth": 0}]
+//
+// DecodeFeatureSet reads it back in later builds, which may add keys and
+// feature names.
+func (fs FeatureSet) Encode(w Writer) error {
+	return EncodeVersioned(w, featureSetFormat, func(w Writer) error {
+		w.WriteMapSize(5)
+		w.WriteString("version")
+		w.WriteUint32(fs.Version)
+		w.WriteString("features")
+		w.WriteArraySize(uint32(countFeatures(fs.Features)))
+		for i, name := range featureNames {
+			if fs.Features&(1<<i) != 0 {
+				w.WriteString(name)
+			}
+		}
+		w.WriteString("time_ext_types")
+		writeExtTypes(w, fs.TimeExtTypes)
+		w.WriteString("any_ext_types")
+		writeExtTypes(w, fs.AnyExtTypes)
+		w.WriteString("max_depth")
+		w.WriteUint32(fs.MaxDepth)
+		return w.Err()
+	})
+}
+
+func countFeatures(f Feature) int {
+	n := 0
+	for i := range featureNames {
+		if f&(1<<i) != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func writeExtTypes(w Writer, types []int8) {
+	w.WriteArraySize(uint32(len(types)))
+	for _, t := range types {
+		w.WriteInt8(t)
+	}
+}
+
+// DecodeFeatureSet reads a FeatureSet written by FeatureSet.Encode. Sets
+// written by newer builds are read too: keys and feature names this build
+// does not know are skipped. Keys that older builds did not write get
+// their defaults: no features, the timestamp type -1 for TimeExtTypes, no
+// AnyExtTypes and no depth limit.
+func DecodeFeatureSet(r Reader) (FeatureSet, error) {
+	fs := FeatureSet{TimeExtTypes: []int8{-1}, AnyExtTypes: []int8{}}
+	decode := func(r Reader) error {
+		return fs.decodeFields(r)
+	}
+	err := DecodeVersioned(r, map[uint32]func(Reader) error{featureSetFormat: decode},
+		func(version uint32, r Reader) error {
+			if version < featureSetFormat {
+				if err := r.Skip(); err != nil {
+					return err
+				}
+				return UnsupportedVersionError{Version: version, Known: []uint32{featureSetFormat}}
+			}
+			return decode(r)
+		})
+	if err != nil {
+		return FeatureSet{}, err
+	}
+	return fs, nil
+}
+
+func (fs *FeatureSet) decodeFields(r Reader) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := readStringKey(r)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "version":
+			fs.Version, err = r.ReadUint32()
+		case "features":
+			fs.Features, err = readFeatureNames(r)
+		case "time_ext_types":
+			fs.TimeExtTypes, err = readExtTypes(r)
+		case "any_ext_types":
+			fs.AnyExtTypes, err = readExtTypes(r)
+		case "max_depth":
+			fs.MaxDepth, err = r.ReadUint32()
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return FieldError{Field: key, Err: err}
+		}
+	}
+	return nil
+}
+
+func readFeatureNames(r Reader) (Feature, error) {
+	size, err := r.ReadArraySize()
+	if err != nil {
+		return 0, err
+	}
+	var f Feature
+	for i := uint32(0); i < size; i++ {
+		name, err := r.ReadString()
+		if err != nil {
+			return 0, err
+		}
+		for bit, known := range featureNames {
+			if name == known {
+				f |= 1 << bit
+				break
+			}
+		}
+	}
+	return f, nil
+}
+
+func readExtTypes(r Reader) ([]int8, error) {
+	size, err := r.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	types := []int8{}
+	for i := uint32(0); i < size; i++ {
+		t, err := r.ReadInt8()
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
@@ -0,0 +1,178 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestFeaturesRoundTrip(t *testing.T) {
+	local := msgpack.Features()
+	assert.Equal(t, uint32(msgpack.FeatureVersion), local.Version)
+	assert.True(t, local.Has(msgpack.FeatureStringTable|msgpack.FeatureSortedMaps))
+	// Builds that copy strings leave out FeatureUnsafeStrings.
+	decoder := msgpack.NewDecoder(nil)
+	assert.Equal(t, decoder.AliasesInput(), local.Has(msgpack.FeatureUnsafeStrings))
+	assert.Equal(t, []int8{-1, 13}, local.TimeExtTypes)
+
+	for _, fs := range []msgpack.FeatureSet{
+		local,
+		{Version: 1, Features: msgpack.FeatureUUID, TimeExtTypes: []int8{-1}, AnyExtTypes: []int8{42}, MaxDepth: 64},
+		{TimeExtTypes: []int8{}, AnyExtTypes: []int8{}},
+	} {
+		data := encodeWith(t, func(w msgpack.Writer) {
+			require.NoError(t, fs.Encode(w))
+		})
+		decoder := msgpack.NewDecoder(data)
+		decoded, err := msgpack.DecodeFeatureSet(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, fs, decoded)
+		require.NoError(t, decoder.ExpectEOF())
+	}
+}
+
+func TestFeatureString(t *testing.T) {
+	assert.Equal(t, "string_table|uuid", (msgpack.FeatureStringTable | msgpack.FeatureUUID).String())
+	assert.Equal(t, "none", msgpack.Feature(0).String())
+}
+
+func TestNegotiate(t *testing.T) {
+	all := msgpack.Features()
+	for _, tc := range []struct {
+		name          string
+		local, remote msgpack.FeatureSet
+		expected      msgpack.FeatureSet
+	}{
+		{
+			name:     "same build",
+			local:    all,
+			remote:   all,
+			expected: all,
+		},
+		{
+			name:   "string tables need both sides",
+			local:  msgpack.FeatureSet{Version: 1, Features: msgpack.FeatureStringTable | msgpack.FeatureSortedMaps},
+			remote: msgpack.FeatureSet{Version: 1, Features: msgpack.FeatureSortedMaps},
+			expected: msgpack.FeatureSet{Version: 1, Features: msgpack.FeatureSortedMaps,
+				TimeExtTypes: []int8{}, AnyExtTypes: []int8{}},
+		},
+		{
+			name:   "older peer",
+			local:  all,
+			remote: msgpack.FeatureSet{Version: 0, Features: msgpack.FeatureUUID, TimeExtTypes: []int8{-1}},
+			expected: msgpack.FeatureSet{Version: 0, Features: msgpack.FeatureUUID,
+				TimeExtTypes: []int8{-1}, AnyExtTypes: []int8{}},
+		},
+		{
+			name:     "ext types in local order",
+			local:    msgpack.FeatureSet{TimeExtTypes: []int8{13, -1}, AnyExtTypes: []int8{1, 2, 3}},
+			remote:   msgpack.FeatureSet{TimeExtTypes: []int8{-1, 13, 5}, AnyExtTypes: []int8{3, 1}},
+			expected: msgpack.FeatureSet{TimeExtTypes: []int8{13, -1}, AnyExtTypes: []int8{1, 3}},
+		},
+		{
+			name:     "lower depth limit",
+			local:    msgpack.FeatureSet{MaxDepth: 64, TimeExtTypes: []int8{}, AnyExtTypes: []int8{}},
+			remote:   msgpack.FeatureSet{MaxDepth: 32},
+			expected: msgpack.FeatureSet{MaxDepth: 32, TimeExtTypes: []int8{}, AnyExtTypes: []int8{}},
+		},
+		{
+			name:     "one depth limit",
+			local:    msgpack.FeatureSet{},
+			remote:   msgpack.FeatureSet{MaxDepth: 16},
+			expected: msgpack.FeatureSet{MaxDepth: 16, TimeExtTypes: []int8{}, AnyExtTypes: []int8{}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, msgpack.Negotiate(tc.local, tc.remote))
+			reverse := msgpack.Negotiate(tc.remote, tc.local)
+			assert.Equal(t, tc.expected.Features, reverse.Features)
+			assert.Equal(t, tc.expected.Version, reverse.Version)
+			assert.Equal(t, tc.expected.MaxDepth, reverse.MaxDepth)
+		})
+	}
+}
+
+// A set from a build that predates the ext type and depth keys gets their
+// defaults.
+func TestDecodeFeatureSetOlderFormat(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteUint32(1)
+		w.WriteMapSize(2)
+		w.WriteString("version")
+		w.WriteUint32(0)
+		w.WriteString("features")
+		w.WriteArraySize(2)
+		w.WriteString("string_table")
+		w.WriteString("uuid")
+	})
+	decoder := msgpack.NewDecoder(data)
+	fs, err := msgpack.DecodeFeatureSet(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.FeatureSet{
+		Version:      0,
+		Features:     msgpack.FeatureStringTable | msgpack.FeatureUUID,
+		TimeExtTypes: []int8{-1},
+		AnyExtTypes:  []int8{},
+	}, fs)
+}
+
+// Keys, feature names and envelope versions of later builds are skipped.
+func TestDecodeFeatureSetNewerFormat(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteUint32(2)
+		w.WriteMapSize(4)
+		w.WriteString("version")
+		w.WriteUint32(9)
+		w.WriteString("features")
+		w.WriteArraySize(2)
+		w.WriteString("teleport")
+		w.WriteString("sorted_maps")
+		w.WriteString("compression")
+		w.WriteMapSize(1)
+		w.WriteString("codecs")
+		w.WriteArraySize(1)
+		w.WriteString("zstd")
+		w.WriteString("max_depth")
+		w.WriteUint32(128)
+	})
+	decoder := msgpack.NewDecoder(data)
+	fs, err := msgpack.DecodeFeatureSet(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.FeatureSet{
+		Version:      9,
+		Features:     msgpack.FeatureSortedMaps,
+		TimeExtTypes: []int8{-1},
+		AnyExtTypes:  []int8{},
+		MaxDepth:     128,
+	}, fs)
+	require.NoError(t, decoder.ExpectEOF())
+}
+
+func TestDecodeFeatureSetErrors(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteUint32(0)
+		w.WriteMapSize(0)
+	})
+	decoder := msgpack.NewDecoder(data)
+	_, err := msgpack.DecodeFeatureSet(&decoder)
+	assert.ErrorIs(t, err, msgpack.ErrUnsupportedVersion)
+
+	data = encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteUint32(1)
+		w.WriteMapSize(1)
+		w.WriteString("max_depth")
+		w.WriteString("deep")
+	})
+	decoder = msgpack.NewDecoder(data)
+	_, err = msgpack.DecodeFeatureSet(&decoder)
+	var fieldErr msgpack.FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "max_depth", fieldErr.Field)
+}
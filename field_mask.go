@@ -0,0 +1,167 @@
+package msgpack
+
+import "strconv"
+
+// The keys of a field mask update.
+const (
+	fieldMaskSet   = "set"
+	fieldMaskClear = "clear"
+)
+
+// WriteFieldMaskUpdate writes a partial update of a record as a map of the
+// fields that changed and a list of the fields being cleared:
+//
+//	{"set": {"name": "Ada", "age": 36}, "clear": ["email"]}
+//
+// Each value in "set" is written by its function, in ascending key order
+// so that an update has a single encoding. Read it with
+// ReadFieldMaskUpdate, or with ObjectCodec.ApplyUpdate.
+func WriteFieldMaskUpdate(w Writer, set map[string]func(Writer), clear []string) error {
+	w.WriteMapSize(2)
+	w.WriteString(fieldMaskSet)
+	writeKeyedMap(w, set, true, w.WriteString, func(write func(Writer)) {
+		write(w)
+	})
+	writeFieldMaskClear(w, clear)
+	return w.Err()
+}
+
+func writeFieldMaskClear(w Writer, clear []string) {
+	w.WriteString(fieldMaskClear)
+	w.WriteArraySize(uint32(len(clear)))
+	for _, field := range clear {
+		w.WriteString(field)
+	}
+}
+
+// ReadFieldMaskUpdate reads an update written by WriteFieldMaskUpdate. It
+// calls `apply` for each field in "set", with `r` positioned at the value,
+// which `apply` must read or Skip, and then `clearFn` for each field in
+// "clear". Clears are applied after all of the sets whatever order the
+// keys come in, so a field that is both set and cleared ends up cleared.
+//
+// A missing or nil "set" or "clear" is empty, and other keys are skipped.
+// A "set" that is not a map or a "clear" that is not an array of strings
+// is returned in a FieldError for that key, and an error from `apply` or
+// `clearFn` in a FieldError for the field.
+func ReadFieldMaskUpdate(r Reader, apply func(field string, r Reader) error, clearFn func(field string) error) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	var cleared []string
+	for i := uint32(0); i < size; i++ {
+		key, err := readStringKey(r)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case fieldMaskSet:
+			err = readFieldMaskSet(r, apply)
+		case fieldMaskClear:
+			cleared, err = readFieldMaskClear(r)
+		default:
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, field := range cleared {
+		if err := clearFn(field); err != nil {
+			return FieldError{Field: field, Err: err}
+		}
+	}
+	return nil
+}
+
+func readFieldMaskSet(r Reader, apply func(field string, r Reader) error) error {
+	isNil, err := readNil(r)
+	if err != nil || isNil {
+		return err
+	}
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return FieldError{Field: fieldMaskSet, Err: err}
+	}
+	for i := uint32(0); i < size; i++ {
+		field, err := readStringKey(r)
+		if err != nil {
+			return FieldError{Field: fieldMaskSet, Err: err}
+		}
+		if err := apply(field, r); err != nil {
+			return FieldError{Field: field, Err: err}
+		}
+	}
+	return nil
+}
+
+func readFieldMaskClear(r Reader) ([]string, error) {
+	isNil, err := readNil(r)
+	if err != nil || isNil {
+		return nil, err
+	}
+	size, err := r.ReadArraySize()
+	if err != nil {
+		return nil, FieldError{Field: fieldMaskClear, Err: err}
+	}
+	cleared := make([]string, 0, sizeHint(r, size))
+	for i := uint32(0); i < size; i++ {
+		name, err := r.ReadString()
+		if err != nil {
+			return nil, FieldError{Field: fieldMaskClear, Err: err}
+		}
+		cleared = append(cleared, name)
+	}
+	return cleared, nil
+}
+
+// EncodeUpdate writes an update in the form of WriteFieldMaskUpdate that
+// sets the fields named in `set` to their values in `v` and clears the
+// fields named in `clear`. The fields in "set" are written in the order
+// given. Naming a field that was not declared is a WriteError.
+func (o *ObjectCodec[T]) EncodeUpdate(w Writer, v *T, set, clear []string) error {
+	for _, names := range [][]string{set, clear} {
+		for _, name := range names {
+			if _, known := o.index[name]; !known {
+				return WriteError{message: "msgpack: update names unknown field " + strconv.Quote(name)}
+			}
+		}
+	}
+	w.WriteMapSize(2)
+	w.WriteString(fieldMaskSet)
+	w.WriteMapSize(uint32(len(set)))
+	for _, name := range set {
+		w.WriteString(name)
+		if err := o.fields[o.index[name]].encode(w, v); err != nil {
+			return err
+		}
+	}
+	writeFieldMaskClear(w, clear)
+	return w.Err()
+}
+
+// ApplyUpdate reads an update written by WriteFieldMaskUpdate or
+// EncodeUpdate into `v`. Each field in "set" is decoded as Decode does, and
+// then each field in "clear" is set to its zero value; see
+// ReadFieldMaskUpdate. Unknown fields are skipped, and reported like in
+// Decode. As an update names only the fields it changes, Required and
+// Default do not apply.
+func (o *ObjectCodec[T]) ApplyUpdate(r Reader, v *T) error {
+	reporter := decOptionsOf(r).unknownKeys
+	return ReadFieldMaskUpdate(r, func(field string, r Reader) error {
+		index, known := o.index[field]
+		if !known {
+			return reporter.Skip(r, field)
+		}
+		return o.fields[index].decode(r, v)
+	}, func(field string) error {
+		index, known := o.index[field]
+		if !known {
+			reporter.Unknown(field, 0)
+			return nil
+		}
+		o.fields[index].clear(v)
+		return nil
+	})
+}
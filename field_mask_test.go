@@ -0,0 +1,301 @@
+package msgpack_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// readUpdate reads an update into a log of "set <field>=<value>" and
+// "clear <field>" entries, in the order the calls are made.
+func readUpdate(t *testing.T, data []byte) ([]string, error) {
+	t.Helper()
+	var log []string
+	decoder := msgpack.NewDecoder(data)
+	err := msgpack.ReadFieldMaskUpdate(&decoder, func(field string, r msgpack.Reader) error {
+		value, err := r.ReadString()
+		log = append(log, "set "+field+"="+value)
+		return err
+	}, func(field string) error {
+		log = append(log, "clear "+field)
+		return nil
+	})
+	return log, err
+}
+
+func setString(value string) func(msgpack.Writer) {
+	return func(w msgpack.Writer) { w.WriteString(value) }
+}
+
+func TestFieldMaskUpdate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		set   map[string]func(msgpack.Writer)
+		clear []string
+		log   []string
+	}{
+		"set only": {
+			set: map[string]func(msgpack.Writer){"name": setString("Ada"), "city": setString("London")},
+			log: []string{"set city=London", "set name=Ada"},
+		},
+		"clear only": {
+			clear: []string{"email", "work"},
+			log:   []string{"clear email", "clear work"},
+		},
+		"both": {
+			set:   map[string]func(msgpack.Writer){"name": setString("Ada")},
+			clear: []string{"email"},
+			log:   []string{"set name=Ada", "clear email"},
+		},
+		// A field both set and cleared is cleared.
+		"overlap": {
+			set:   map[string]func(msgpack.Writer){"email": setString("ada@example.com")},
+			clear: []string{"email"},
+			log:   []string{"set email=ada@example.com", "clear email"},
+		},
+		"empty": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := encodeWith(t, func(w msgpack.Writer) {
+				require.NoError(t, msgpack.WriteFieldMaskUpdate(w, tc.set, tc.clear))
+			})
+			log, err := readUpdate(t, data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.log, log)
+		})
+	}
+
+	// The set keys are written in order.
+	data := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteFieldMaskUpdate(w, map[string]func(msgpack.Writer){"b": setString("2"), "a": setString("1")}, nil)
+	})
+	assert.Equal(t, encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("set")
+		w.WriteMapSize(2)
+		w.WriteString("a")
+		w.WriteString("1")
+		w.WriteString("b")
+		w.WriteString("2")
+		w.WriteString("clear")
+		w.WriteArraySize(0)
+	}), data)
+}
+
+func TestReadFieldMaskUpdateEnvelope(t *testing.T) {
+	// Clears come after the sets even when "clear" is written first, nil or
+	// missing keys are empty, and unknown keys are skipped.
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("clear")
+		w.WriteArraySize(1)
+		w.WriteString("email")
+		w.WriteString("version")
+		w.WriteAny(map[string]any{"major": int64(2)})
+		w.WriteString("set")
+		w.WriteMapSize(1)
+		w.WriteString("email")
+		w.WriteString("ada@example.com")
+	})
+	log, err := readUpdate(t, data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"set email=ada@example.com", "clear email"}, log)
+
+	data = encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("set")
+		w.WriteNil()
+		w.WriteString("clear")
+		w.WriteNil()
+	})
+	log, err = readUpdate(t, data)
+	require.NoError(t, err)
+	assert.Empty(t, log)
+
+	log, err = readUpdate(t, []byte{0x80})
+	require.NoError(t, err)
+	assert.Empty(t, log)
+}
+
+func TestReadFieldMaskUpdateMalformed(t *testing.T) {
+	for name, tc := range map[string]struct {
+		write func(w msgpack.Writer)
+		field string
+	}{
+		"not a map": {
+			write: func(w msgpack.Writer) { w.WriteArraySize(0) },
+		},
+		"set not a map": {
+			write: func(w msgpack.Writer) {
+				w.WriteMapSize(1)
+				w.WriteString("set")
+				w.WriteArraySize(0)
+			},
+			field: "set",
+		},
+		"set key not a string": {
+			write: func(w msgpack.Writer) {
+				w.WriteMapSize(1)
+				w.WriteString("set")
+				w.WriteMapSize(1)
+				w.WriteInt64(1)
+				w.WriteString("x")
+			},
+			field: "set",
+		},
+		"clear not an array": {
+			write: func(w msgpack.Writer) {
+				w.WriteMapSize(1)
+				w.WriteString("clear")
+				w.WriteString("email")
+			},
+			field: "clear",
+		},
+		"clear of non-strings": {
+			write: func(w msgpack.Writer) {
+				w.WriteMapSize(1)
+				w.WriteString("clear")
+				w.WriteArraySize(1)
+				w.WriteInt64(1)
+			},
+			field: "clear",
+		},
+		// The names are allocated as they are read, not for the
+		// declared length.
+		"clear longer than the input": {
+			write: func(w msgpack.Writer) {
+				w.WriteMapSize(1)
+				w.WriteString("clear")
+				w.WriteRawBytes([]byte{msgpack.FormatArray32, 0xff, 0xff, 0xff, 0xff})
+			},
+			field: "clear",
+		},
+		"set value of the wrong type": {
+			write: func(w msgpack.Writer) {
+				w.WriteMapSize(1)
+				w.WriteString("set")
+				w.WriteMapSize(1)
+				w.WriteString("age")
+				w.WriteInt64(36)
+			},
+			field: "age",
+		},
+		"truncated": {
+			write: func(w msgpack.Writer) {
+				w.WriteMapSize(2)
+				w.WriteString("set")
+				w.WriteMapSize(0)
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			log, err := readUpdate(t, encodeWith(t, tc.write))
+			require.Error(t, err)
+			var fieldErr msgpack.FieldError
+			if tc.field == "" {
+				assert.False(t, errors.As(err, &fieldErr), err.Error())
+			} else {
+				require.ErrorAs(t, err, &fieldErr)
+				assert.Equal(t, tc.field, fieldErr.Field)
+			}
+			// Nothing is cleared from a malformed update.
+			for _, entry := range log {
+				assert.NotContains(t, entry, "clear")
+			}
+		})
+	}
+
+	// Errors from the callbacks name the field.
+	data := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteFieldMaskUpdate(w, nil, []string{"name"})
+	})
+	decoder := msgpack.NewDecoder(data)
+	errRequired := errors.New("cannot clear a required field")
+	err := msgpack.ReadFieldMaskUpdate(&decoder, nil, func(field string) error {
+		return errRequired
+	})
+	assert.ErrorIs(t, err, errRequired)
+	assert.EqualError(t, err, `msgpack: field "name": cannot clear a required field`)
+}
+
+func TestObjectCodecUpdate(t *testing.T) {
+	email := "ada@example.com"
+	person := Person{
+		Name:  "Ada",
+		Age:   36,
+		Email: &email,
+		Tags:  []string{"math"},
+		Home:  Address{Street: "1 Main St", City: "London"},
+		Work:  &Address{City: "London"},
+	}
+	update := person
+	update.Age = 37
+	update.Tags = []string{"math", "engines"}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, personCodec.EncodeUpdate(w, &update, []string{"age", "tags"}, []string{"email", "home", "work"}))
+	})
+
+	// The update reads the same with ReadFieldMaskUpdate.
+	decoder := msgpack.NewDecoder(data)
+	var set, cleared []string
+	require.NoError(t, msgpack.ReadFieldMaskUpdate(&decoder, func(field string, r msgpack.Reader) error {
+		set = append(set, field)
+		return r.Skip()
+	}, func(field string) error {
+		cleared = append(cleared, field)
+		return nil
+	}))
+	assert.Equal(t, []string{"age", "tags"}, set)
+	assert.Equal(t, []string{"email", "home", "work"}, cleared)
+
+	decoder = msgpack.NewDecoder(data)
+	require.NoError(t, personCodec.ApplyUpdate(&decoder, &person))
+	assert.Equal(t, Person{
+		Name: "Ada",
+		Age:  37,
+		Tags: []string{"math", "engines"},
+	}, person)
+
+	// Unknown fields are skipped and reported, and a required field may be
+	// left out.
+	data = encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteFieldMaskUpdate(w, map[string]func(msgpack.Writer){
+			"age":      func(w msgpack.Writer) { w.WriteInt32(38) },
+			"nickname": setString("Countess"),
+		}, []string{"pronouns", "tags"})
+	})
+	var reporter msgpack.UnknownKeyReporter
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithUnknownKeyReporter(&reporter))
+	require.NoError(t, personCodec.ApplyUpdate(&decoder, &person))
+	assert.Equal(t, Person{Name: "Ada", Age: 38}, person)
+	stats := reporter.Stats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, uint64(1), stats["nickname"].Count)
+	assert.Equal(t, uint64(1), stats["pronouns"].Count)
+
+	// A field set and cleared by the same update ends up cleared.
+	data = encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, personCodec.EncodeUpdate(w, &update, []string{"age"}, []string{"age"}))
+	})
+	decoder = msgpack.NewDecoder(data)
+	require.NoError(t, personCodec.ApplyUpdate(&decoder, &person))
+	assert.Equal(t, int32(0), person.Age)
+
+	// Decode errors name the field.
+	data = encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteFieldMaskUpdate(w, map[string]func(msgpack.Writer){"age": setString("old")}, nil)
+	})
+	decoder = msgpack.NewDecoder(data)
+	err := personCodec.ApplyUpdate(&decoder, &person)
+	var fieldErr msgpack.FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "age", fieldErr.Field)
+
+	encoder := msgpack.NewEncoder(make([]byte, 64))
+	err = personCodec.EncodeUpdate(&encoder, &update, []string{"nickname"}, nil)
+	assert.EqualError(t, err, `msgpack: update names unknown field "nickname"`)
+	assert.Equal(t, 0, int(encoder.Len()))
+}
@@ -0,0 +1,68 @@
+package msgpack
+
+import (
+	"strconv"
+)
+
+// ReadByteArrayInto16 reads a bin that must hold exactly 16 bytes, such as
+// a hash or a UUID, into an array. The matching write is
+// `w.WriteByteArray(v[:])`.
+func ReadByteArrayInto16(r Reader) ([16]byte, error) {
+	var v [16]byte
+	return v, readFixedBytes(r, v[:])
+}
+
+// ReadByteArrayInto32 reads a bin that must hold exactly 32 bytes, such as
+// a SHA-256 hash or an Ed25519 public key, into an array.
+func ReadByteArrayInto32(r Reader) ([32]byte, error) {
+	var v [32]byte
+	return v, readFixedBytes(r, v[:])
+}
+
+func readFixedBytes(r Reader, dst []byte) error {
+	b, err := r.ReadByteArray()
+	if err != nil {
+		return err
+	}
+	if len(b) != len(dst) {
+		return ReadError{"msgpack: expected bin of " + strconv.Itoa(len(dst)) +
+			" bytes, got " + strconv.Itoa(len(b))}
+	}
+	copy(dst, b)
+	return nil
+}
+
+// ReadStringFixed reads a string into `dst` without allocating and
+// returns its length, for schemas that bound the length of a string by
+// the size of a buffer. A string longer than `dst` returns a
+// ValueTooLargeError with the declared length as Length and len(dst) as
+// Limit. The string is consumed either way, so the next value can still
+// be read, and `dst` is left unchanged.
+func (d *Decoder) ReadStringFixed(dst []byte) (int, error) {
+	offset := d.reader.byteOffset
+	s, err := d.ReadString()
+	if err != nil {
+		return 0, err
+	}
+	if len(s) > len(dst) {
+		return 0, tooLongForBuffer(kindString, len(s), dst, offset)
+	}
+	return copy(dst, s), nil
+}
+
+// ReadByteArrayFixed is ReadStringFixed for a bin.
+func (d *Decoder) ReadByteArrayFixed(dst []byte) (int, error) {
+	offset := d.reader.byteOffset
+	b, err := d.ReadByteArray()
+	if err != nil {
+		return 0, err
+	}
+	if len(b) > len(dst) {
+		return 0, tooLongForBuffer(kindBin, len(b), dst, offset)
+	}
+	return copy(dst, b), nil
+}
+
+func tooLongForBuffer(kind string, length int, dst []byte, offset uint32) error {
+	return ValueTooLargeError{Kind: kind, Length: uint32(length), Limit: uint32(len(dst)), Offset: offset}
+}
@@ -0,0 +1,46 @@
+//go:build !tinygo
+// +build !tinygo
+
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type digest [20]byte
+
+// Byte arrays of other lengths are found by reflection, which TinyGo
+// builds leave out of WriteAny.
+func TestWriteAnyNamedByteArray(t *testing.T) {
+	var sha1 digest
+	for i := range sha1 {
+		sha1[i] = byte(i)
+	}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteAny(sha1)
+	})
+	decoder := msgpack.NewDecoder(data)
+	b, err := decoder.ReadByteArray()
+	require.NoError(t, err)
+	assert.Equal(t, sha1[:], b)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+}
+
+func TestWriteAnyNamedByteArraySizes(t *testing.T) {
+	for _, value := range []any{digest{}, [0]byte{}} {
+		var sizer msgpack.Sizer
+		sizer.WriteAny(value)
+		var upper msgpack.UpperBoundSizer
+		upper.WriteAny(value)
+		encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+		encoder.WriteAny(value)
+		require.NoError(t, encoder.Err())
+		assert.Equal(t, sizer.Len(), uint32(len(encoder.Bytes())), "%T", value)
+		assert.GreaterOrEqual(t, upper.Len(), sizer.Len(), "%T", value)
+	}
+}
@@ -0,0 +1,156 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestWriteAnyFixedByteArrays(t *testing.T) {
+	var id [16]byte
+	var hash [32]byte
+	for i := range hash {
+		hash[i] = byte(i)
+	}
+	copy(id[:], hash[16:])
+
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteAny(id)
+		w.WriteAny(hash)
+		w.WriteAny([]any{id, hash})
+	})
+	assert.Equal(t, []byte{msgpack.FormatBin8, 16}, data[:2])
+
+	decoder := msgpack.NewDecoder(data)
+	readID, err := msgpack.ReadByteArrayInto16(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, id, readID)
+	readHash, err := msgpack.ReadByteArrayInto32(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, hash, readHash)
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, []any{id[:], hash[:]}, v)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+}
+
+func TestWriteAnyFixedByteArraySizes(t *testing.T) {
+	for _, value := range []any{[16]byte{}, [32]byte{}} {
+		var sizer msgpack.Sizer
+		sizer.WriteAny(value)
+		var upper msgpack.UpperBoundSizer
+		upper.WriteAny(value)
+		encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+		encoder.WriteAny(value)
+		require.NoError(t, encoder.Err())
+		assert.Equal(t, sizer.Len(), uint32(len(encoder.Bytes())), "%T", value)
+		assert.GreaterOrEqual(t, upper.Len(), sizer.Len(), "%T", value)
+	}
+}
+
+func TestReadByteArrayIntoWrongLength(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteByteArray(bytes.Repeat([]byte{1}, 15))
+		w.WriteByteArray(bytes.Repeat([]byte{1}, 16))
+		w.WriteString("not a bin")
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	_, err := msgpack.ReadByteArrayInto16(&decoder)
+	assert.EqualError(t, err, "msgpack: expected bin of 16 bytes, got 15")
+	_, err = msgpack.ReadByteArrayInto32(&decoder)
+	assert.EqualError(t, err, "msgpack: expected bin of 32 bytes, got 16")
+	_, err = msgpack.ReadByteArrayInto16(&decoder)
+	assert.Error(t, err)
+}
+
+func TestReadStringFixed(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("sensor-1")
+		w.WriteString("sensor-12")
+		w.WriteString("")
+		w.WriteUint8(7)
+	})
+	decoder := msgpack.NewDecoder(data)
+	var dst [8]byte
+
+	n, err := decoder.ReadStringFixed(dst[:])
+	require.NoError(t, err)
+	assert.Equal(t, "sensor-1", string(dst[:n]))
+
+	n, err = decoder.ReadStringFixed(dst[:])
+	assert.EqualError(t, err, "msgpack: string of 9 bytes exceeds the limit of 8 at offset 9")
+	assert.ErrorIs(t, err, msgpack.ErrValueTooLarge)
+	var tooLarge msgpack.ValueTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, msgpack.ValueTooLargeError{Kind: "string", Length: 9, Limit: 8, Offset: 9}, tooLarge)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, "sensor-1", string(dst[:]), "dst is unchanged")
+
+	n, err = decoder.ReadStringFixed(dst[:])
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// The value after the one that was too long is still aligned.
+	next, err := decoder.ReadUint8()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(7), next)
+
+	decoder = msgpack.NewDecoder([]byte{0xa0})
+	n, err = decoder.ReadStringFixed(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	decoder = msgpack.NewDecoder([]byte{msgpack.FormatBin8, 0})
+	_, err = decoder.ReadStringFixed(dst[:])
+	var mismatch msgpack.TypeMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+}
+
+func TestReadByteArrayFixed(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteByteArray([]byte{1, 2, 3, 4})
+		w.WriteByteArray([]byte{1, 2, 3, 4, 5})
+		w.WriteByteArray([]byte{})
+		w.WriteString("next")
+	})
+	decoder := msgpack.NewDecoder(data)
+	var dst [4]byte
+
+	n, err := decoder.ReadByteArrayFixed(dst[:])
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4}, dst[:n])
+
+	_, err = decoder.ReadByteArrayFixed(dst[:])
+	var tooLarge msgpack.ValueTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, msgpack.ValueTooLargeError{Kind: "bin", Length: 5, Limit: 4, Offset: 6}, tooLarge)
+
+	n, err = decoder.ReadByteArrayFixed(dst[:])
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	next, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "next", next)
+}
+
+func TestReadStringFixedAllocations(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("device")
+	})
+	decoder := msgpack.NewDecoder(data)
+	skipUnlessAliased(t, &decoder)
+	var dst [32]byte
+	allocs := testing.AllocsPerRun(100, func() {
+		decoder := msgpack.NewDecoder(data)
+		if _, err := decoder.ReadStringFixed(dst[:]); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Zero(t, allocs)
+}
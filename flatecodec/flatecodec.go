@@ -0,0 +1,57 @@
+//go:build !tinygo
+// +build !tinygo
+
+// Package flatecodec provides a msgpack.CompressionCodec based on
+// compress/flate for host builds.
+package flatecodec
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+)
+
+// Codec compresses with DEFLATE at Level.
+type Codec struct {
+	// Level is a compress/flate compression level.
+	Level int
+}
+
+// Default compresses at flate.DefaultCompression.
+var Default = Codec{Level: flate.DefaultCompression}
+
+func (c Codec) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(c.MaxCompressedLen(len(src)))
+	w, err := flate.NewWriter(&buf, c.Level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c Codec) Decompress(src []byte, originalLen int) ([]byte, error) {
+	data := make([]byte, originalLen)
+	r := flate.NewReader(bytes.NewReader(src))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	// Reject input that decompresses to more than originalLen bytes.
+	var extra [1]byte
+	if n, _ := r.Read(extra[:]); n != 0 {
+		return nil, io.ErrShortBuffer
+	}
+	return data, r.Close()
+}
+
+// MaxCompressedLen allows for DEFLATE falling back to stored blocks, which
+// add 5 bytes for every 16 KiB or less, plus the final block markers.
+func (c Codec) MaxCompressedLen(srcLen int) int {
+	return srcLen + 5*(srcLen/16384+1) + 16
+}
@@ -0,0 +1,109 @@
+package msgpack
+
+import (
+	"math"
+	"strconv"
+)
+
+// Float16FromBits returns the float32 value of the IEEE 754 half-precision
+// number with bits `u`. Every half-precision number, including subnormals,
+// infinities and NaNs with their payload, has an exact float32 value.
+func Float16FromBits(u uint16) float32 {
+	sign := uint32(u&0x8000) << 16
+	exp := uint32(u>>10) & 0x1f
+	mant := uint32(u & 0x3ff)
+	switch exp {
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// A subnormal is 0.mant × 2^-14; shift it up to 1.mant × 2^e.
+		e := uint32(127 - 14)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		return math.Float32frombits(sign | e<<23 | (mant&0x3ff)<<13)
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// Float16Bits returns the IEEE 754 half-precision bits nearest to `f`,
+// rounding halfway cases to even, and reports whether the conversion was
+// exact. Values too large for half precision become infinities and values
+// too small become zero or a subnormal. A NaN keeps its sign and the high
+// bits of its payload, and stays a NaN when those bits are all zero.
+func Float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	if exp == 0xff {
+		if mant == 0 {
+			return sign | 0x7c00, true
+		}
+		payload := uint16(mant >> 13)
+		exact := mant&0x1fff == 0
+		if payload == 0 {
+			payload, exact = 0x200, false
+		}
+		return sign | 0x7c00 | payload, exact
+	}
+
+	e := exp - 127 + 15
+	switch {
+	case e >= 0x1f:
+		return sign | 0x7c00, false
+	case e < -10:
+		// Below half the smallest subnormal, or a float32 subnormal.
+		return sign, exp == 0 && mant == 0
+	case e <= 0:
+		// The value is m × 2^(e-14) units of the smallest subnormal.
+		m := mant | 0x800000
+		shift := uint(14 - e)
+		half, rest := m>>shift, m&(1<<shift-1)
+		return sign | uint16(roundHalfEven(half, rest, 1<<(shift-1))), rest == 0
+	}
+	half, rest := uint32(e)<<10|mant>>13, mant&0x1fff
+	// A carry out of the mantissa moves to the next exponent, and past the
+	// largest finite value to infinity, as it should.
+	return sign | uint16(roundHalfEven(half, rest, 0x1000)), rest == 0
+}
+
+// roundHalfEven rounds `v` up when the discarded bits `rest` are above
+// `halfway`, or equal to it with `v` odd.
+func roundHalfEven(v, rest, halfway uint32) uint32 {
+	if rest > halfway || rest == halfway && v&1 == 1 {
+		return v + 1
+	}
+	return v
+}
+
+// WriteFloat16Ext writes `value` as a half-precision float in a fixext2 of
+// type `extType`, the convention some sensors use, and reports whether the
+// conversion was exact. See Float16Bits for how values are rounded.
+func WriteFloat16Ext(w Writer, value float32, extType int8) bool {
+	u, exact := Float16Bits(value)
+	w.WriteRawBytes([]byte{FormatFixExt2, byte(extType), byte(u >> 8), byte(u)})
+	return exact
+}
+
+// ReadFloat16Ext reads a half-precision float written by WriteFloat16Ext.
+// The value must be a fixext2 of type `extType`.
+func ReadFloat16Ext(r Reader, extType int8) (float32, error) {
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return 0, err
+	}
+	if raw[0] != FormatFixExt2 {
+		return 0, ReadError{"msgpack: expected float16 fixext2, found " + formatName(raw[0])}
+	}
+	if int8(raw[1]) != extType {
+		return 0, ReadError{"msgpack: expected float16 ext type " + strconv.Itoa(int(extType)) +
+			", found ext type " + strconv.Itoa(int(int8(raw[1])))}
+	}
+	return Float16FromBits(uint16(raw[2])<<8 | uint16(raw[3])), nil
+}
@@ -0,0 +1,151 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// float16Reference computes the value of half-precision bits from the
+// definition of the format.
+func float16Reference(u uint16) float64 {
+	sign := 1.0
+	if u&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(u>>10) & 0x1f
+	mant := float64(u & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(1024+mant, exp-25)
+}
+
+func TestFloat16Exhaustive(t *testing.T) {
+	for i := 0; i <= math.MaxUint16; i++ {
+		u := uint16(i)
+		f := msgpack.Float16FromBits(u)
+		expected := float16Reference(u)
+		if math.IsNaN(expected) {
+			require.True(t, math.IsNaN(float64(f)), "0x%04x", u)
+			// The payload and sign are kept.
+			require.Equal(t, uint32(u&0x3ff)<<13, math.Float32bits(f)&0x7fffff, "0x%04x", u)
+			require.Equal(t, u&0x8000 != 0, math.Signbit(float64(f)), "0x%04x", u)
+		} else {
+			require.Equal(t, expected, float64(f), "0x%04x", u)
+			require.Equal(t, u&0x8000 != 0, math.Signbit(float64(f)), "0x%04x", u)
+		}
+
+		back, exact := msgpack.Float16Bits(f)
+		require.Equal(t, u, back, "0x%04x", u)
+		require.True(t, exact, "0x%04x", u)
+	}
+}
+
+func TestFloat16Bits(t *testing.T) {
+	tests := []struct {
+		name  string
+		value float32
+		bits  uint16
+		exact bool
+	}{
+		{"zero", 0, 0x0000, true},
+		{"negative zero", float32(math.Copysign(0, -1)), 0x8000, true},
+		{"one", 1, 0x3c00, true},
+		{"minus two", -2, 0xc000, true},
+		{"max", 65504, 0x7bff, true},
+		{"just below rounding to infinity", 65519.99, 0x7bff, false},
+		{"rounds to infinity", 65520, 0x7c00, false},
+		{"overflow", 1e10, 0x7c00, false},
+		{"negative overflow", -1e10, 0xfc00, false},
+		{"infinity", float32(math.Inf(1)), 0x7c00, true},
+		{"negative infinity", float32(math.Inf(-1)), 0xfc00, true},
+		{"tie rounds to even down", 1 + 1.0/2048, 0x3c00, false},
+		{"tie rounds to even up", 1 + 3.0/2048, 0x3c02, false},
+		{"above tie rounds up", 1 + 1.0/2048 + 1.0/8192, 0x3c01, false},
+		{"smallest normal", float32(math.Ldexp(1, -14)), 0x0400, true},
+		{"largest subnormal", float32(math.Ldexp(1023, -24)), 0x03ff, true},
+		{"subnormal rounds to smallest normal", float32(math.Ldexp(1023.5, -24)), 0x0400, false},
+		{"smallest subnormal", float32(math.Ldexp(1, -24)), 0x0001, true},
+		{"subnormal tie rounds to even", float32(math.Ldexp(1.5, -24)), 0x0002, false},
+		{"subnormal rounds down", float32(math.Ldexp(1.25, -24)), 0x0001, false},
+		{"half the smallest subnormal ties to zero", float32(math.Ldexp(1, -25)), 0x0000, false},
+		{"above half the smallest subnormal", float32(math.Ldexp(1.0001, -25)), 0x0001, false},
+		{"underflow", 1e-10, 0x0000, false},
+		{"negative underflow", -1e-10, 0x8000, false},
+		{"float32 subnormal", math.SmallestNonzeroFloat32, 0x0000, false},
+		{"quiet nan", math.Float32frombits(0x7fc00000), 0x7e00, true},
+		{"nan payload", math.Float32frombits(0xff802000), 0xfc01, true},
+		{"nan payload in low bits", math.Float32frombits(0x7f800001), 0x7e00, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bits, exact := msgpack.Float16Bits(tt.value)
+			assert.Equal(t, tt.bits, bits, "0x%04x", bits)
+			assert.Equal(t, tt.exact, exact)
+		})
+	}
+}
+
+func TestFloat16BitsRoundsToNearest(t *testing.T) {
+	// Walk float32 values up to 65520, from where they round to infinity,
+	// and check that no neighbor of the result is closer.
+	for b := uint32(0); b < 0x477ff000; b += 0x1357 {
+		f := math.Float32frombits(b)
+		h, exact := msgpack.Float16Bits(f)
+		got := float64(msgpack.Float16FromBits(h))
+		dist := math.Abs(got - float64(f))
+		require.Equal(t, dist == 0, exact, "%g", f)
+		if h > 0 {
+			require.LessOrEqual(t, dist, math.Abs(float16Reference(h-1)-float64(f)), "%g", f)
+		}
+		if h < 0x7c00 {
+			next := math.Abs(float16Reference(h+1) - float64(f))
+			require.LessOrEqual(t, dist, next, "%g", f)
+			if dist == next {
+				require.Zero(t, h&1, "ties go to even: %g", f)
+			}
+		}
+	}
+}
+
+func TestFloat16Ext(t *testing.T) {
+	const sensorExt int8 = 0x20
+	var exact []bool
+	data := encodeWith(t, func(w msgpack.Writer) {
+		exact = append(exact, msgpack.WriteFloat16Ext(w, 1.5, sensorExt))
+		exact = append(exact, msgpack.WriteFloat16Ext(w, 0.1, sensorExt))
+	})
+	assert.Equal(t, []bool{true, false, true, false}, exact, "sizer and encoder")
+	assert.Equal(t, []byte{0xd5, 0x20, 0x3e, 0x00, 0xd5, 0x20, 0x2e, 0x66}, data)
+
+	decoder := msgpack.NewDecoder(data)
+	v, err := msgpack.ReadFloat16Ext(&decoder, sensorExt)
+	require.NoError(t, err)
+	assert.Equal(t, float32(1.5), v)
+	v, err = msgpack.ReadFloat16Ext(&decoder, sensorExt)
+	require.NoError(t, err)
+	assert.Equal(t, float32(0.0999755859375), v)
+
+	decoder = msgpack.NewDecoder(data)
+	_, err = msgpack.ReadFloat16Ext(&decoder, 0x21)
+	assert.EqualError(t, err, "msgpack: expected float16 ext type 33, found ext type 32")
+
+	decoder = msgpack.NewDecoder([]byte{0xd6, 0x20, 0, 0, 0, 0})
+	_, err = msgpack.ReadFloat16Ext(&decoder, sensorExt)
+	assert.EqualError(t, err, "msgpack: expected float16 fixext2, found fixext4")
+
+	decoder = msgpack.NewDecoder([]byte{0xd5, 0x20, 0x3e})
+	_, err = msgpack.ReadFloat16Ext(&decoder, sensorExt)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
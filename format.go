@@ -1,5 +1,7 @@
 package msgpack
 
+import "strconv"
+
 const (
 	FormatError                  = 0
 	FormatFourBytes              = 0xffffffff
@@ -42,3 +44,113 @@ const (
 	FormatMap32                  = 0xdf
 	FormatNegativeFixInt         = 0xe0
 )
+
+// FormatName returns the name the MessagePack specification gives the
+// format `prefix` selects, such as "str8" or "fixext4". For the fix
+// families the length or value held in the prefix is added, as in
+// "fixmap(3)" or "negfixint(-5)". The unused byte 0xc1 is "never used".
+func FormatName(prefix byte) string {
+	name := formatName(prefix)
+	switch {
+	case isFixedInt(prefix) || isNegativeFixedInt(prefix):
+		return name + "(" + strconv.Itoa(int(int8(prefix))) + ")"
+	case isFixedString(prefix):
+		return name + "(" + strconv.Itoa(int(prefix&0x1f)) + ")"
+	case isFixedArray(prefix) || isFixedMap(prefix):
+		return name + "(" + strconv.Itoa(int(prefix&FormatFourLeastSigBitsInByte)) + ")"
+	}
+	return name
+}
+
+// DescribeNext returns a one-line description of the next value without
+// consuming it, for error messages and debugging: the description
+// AppendHexDump gives it, such as `uint16 4096`, `str(4) "name"` or
+// `map(3)`. Strings are read to show them, and arrays and maps are only
+// described by their header.
+func DescribeNext(d *Decoder) (string, error) {
+	peek := NewDecoderAt(d.reader.buffer, d.reader.byteOffset, d.reader.Remaining())
+	description, _, err := describeNext(&peek)
+	return description, err
+}
+
+// formatName names the format `prefix` selects, such as "fixstr" or
+// "uint16".
+func formatName(prefix byte) string {
+	switch {
+	case isFixedInt(prefix):
+		return "fixint"
+	case isNegativeFixedInt(prefix):
+		return "negfixint"
+	case isFixedString(prefix):
+		return "fixstr"
+	case isFixedArray(prefix):
+		return "fixarray"
+	case isFixedMap(prefix):
+		return "fixmap"
+	}
+	switch prefix {
+	case FormatNil:
+		return "nil"
+	case FormatFalse:
+		return "false"
+	case FormatTrue:
+		return "true"
+	case FormatBin8:
+		return "bin8"
+	case FormatBin16:
+		return "bin16"
+	case FormatBin32:
+		return "bin32"
+	case FormatExt8:
+		return "ext8"
+	case FormatExt16:
+		return "ext16"
+	case FormatExt32:
+		return "ext32"
+	case FormatFloat32:
+		return "float32"
+	case FormatFloat64:
+		return "float64"
+	case FormatUint8:
+		return "uint8"
+	case FormatUint16:
+		return "uint16"
+	case FormatUint32:
+		return "uint32"
+	case FormatUint64:
+		return "uint64"
+	case FormatInt8:
+		return "int8"
+	case FormatInt16:
+		return "int16"
+	case FormatInt32:
+		return "int32"
+	case FormatInt64:
+		return "int64"
+	case FormatFixExt1:
+		return "fixext1"
+	case FormatFixExt2:
+		return "fixext2"
+	case FormatFixExt4:
+		return "fixext4"
+	case FormatFixExt8:
+		return "fixext8"
+	case FormatFixExt16:
+		return "fixext16"
+	case FormatString8:
+		return "str8"
+	case FormatString16:
+		return "str16"
+	case FormatString32:
+		return "str32"
+	case FormatArray16:
+		return "array16"
+	case FormatArray32:
+		return "array32"
+	case FormatMap16:
+		return "map16"
+	case FormatMap32:
+		return "map32"
+	}
+	return "never used"
+}
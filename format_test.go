@@ -0,0 +1,178 @@
+package msgpack_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestFormatName(t *testing.T) {
+	named := []string{
+		0xc0 - 0xc0: "nil", "never used", "false", "true",
+		"bin8", "bin16", "bin32",
+		"ext8", "ext16", "ext32",
+		"float32", "float64",
+		"uint8", "uint16", "uint32", "uint64",
+		"int8", "int16", "int32", "int64",
+		"fixext1", "fixext2", "fixext4", "fixext8", "fixext16",
+		"str8", "str16", "str32",
+		"array16", "array32",
+		"map16", "map32",
+	}
+	for i := 0; i <= 0xff; i++ {
+		prefix := byte(i)
+		var expected string
+		switch {
+		case i <= 0x7f:
+			expected = "fixint(" + strconv.Itoa(i) + ")"
+		case i <= 0x8f:
+			expected = "fixmap(" + strconv.Itoa(i-0x80) + ")"
+		case i <= 0x9f:
+			expected = "fixarray(" + strconv.Itoa(i-0x90) + ")"
+		case i <= 0xbf:
+			expected = "fixstr(" + strconv.Itoa(i-0xa0) + ")"
+		case i <= 0xdf:
+			expected = named[i-0xc0]
+		default:
+			expected = "negfixint(" + strconv.Itoa(i-0x100) + ")"
+		}
+		assert.Equal(t, expected, msgpack.FormatName(prefix), "0x%02x", i)
+	}
+	assert.Equal(t, "fixint(127)", msgpack.FormatName(0x7f))
+	assert.Equal(t, "negfixint(-32)", msgpack.FormatName(0xe0))
+	assert.Equal(t, "fixstr(31)", msgpack.FormatName(0xbf))
+}
+
+func TestDescribeNext(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("name")
+		w.WriteUint16(4096)
+		w.WriteString("ok")
+		w.WriteBool(true)
+		w.WriteString("tags")
+		w.WriteArraySize(0)
+	})
+	decoder := msgpack.NewDecoder(data)
+	for _, expected := range []string{
+		"map(3)",
+		`str(4) "name"`, "uint16 4096",
+		`str(2) "ok"`, "true",
+		`str(4) "tags"`, "array(0)",
+	} {
+		offset := decoder.Offset()
+		description, err := msgpack.DescribeNext(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, description)
+		assert.Equal(t, offset, decoder.Offset())
+		if expected == "map(3)" {
+			_, err = decoder.ReadMapSize()
+		} else {
+			err = decoder.Skip()
+		}
+		require.NoError(t, err)
+	}
+	_, err := msgpack.DescribeNext(&decoder)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
+
+func TestBadPrefixErrors(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{0x01, 0xd9, 0x00})
+	require.NoError(t, decoder.Skip())
+	_, err := decoder.ReadMapSize()
+	assert.EqualError(t, err, "bad prefix for map length: got str8 (0xd9) at offset 1")
+
+	decoder = msgpack.NewDecoder([]byte{0x83})
+	_, err = decoder.ReadArraySize()
+	assert.EqualError(t, err, "bad prefix for array length: got fixmap(3) (0x83) at offset 0")
+
+	decoder = msgpack.NewDecoder([]byte{0xc3})
+	_, err = decoder.ReadString()
+	assert.EqualError(t, err, "bad prefix for string length: got true (0xc3) at offset 0")
+
+	decoder = msgpack.NewDecoder([]byte{0xd0, 0xff})
+	_, err = decoder.ReadUint64()
+	assert.EqualError(t, err, "bad prefix for uint: got int8 (0xd0) at offset 0")
+
+	decoder = msgpack.NewDecoder([]byte{0xc1})
+	assert.EqualError(t, decoder.Skip(), "bad prefix: got never used (0xc1) at offset 0")
+}
+
+func TestTypeMismatchErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		read     func(d *msgpack.Decoder) error
+		expected msgpack.ValueKind
+		actual   msgpack.ValueKind
+		message  string
+	}{
+		{"ReadBool", []byte{0x01},
+			func(d *msgpack.Decoder) error { _, err := d.ReadBool(); return err },
+			msgpack.KindBool, msgpack.KindInt, "bad prefix for bool: got fixint(1) (0x01) at offset 0"},
+		{"ReadInt64", []byte{0xa1, 'a'},
+			func(d *msgpack.Decoder) error { _, err := d.ReadInt64(); return err },
+			msgpack.KindInt, msgpack.KindString, "bad prefix for int64: got fixstr(1) (0xa1) at offset 0"},
+		{"ReadUint64", []byte{0xc3},
+			func(d *msgpack.Decoder) error { _, err := d.ReadUint64(); return err },
+			msgpack.KindUint, msgpack.KindBool, "bad prefix for uint: got true (0xc3) at offset 0"},
+		{"ReadUint64 negative", []byte{0xd0, 0xff},
+			func(d *msgpack.Decoder) error { _, err := d.ReadUint64(); return err },
+			msgpack.KindUint, msgpack.KindInt, "bad prefix for uint: got int8 (0xd0) at offset 0"},
+		{"ReadFloat32", []byte{0xc0},
+			func(d *msgpack.Decoder) error { _, err := d.ReadFloat32(); return err },
+			msgpack.KindFloat, msgpack.KindNil, "bad prefix for float32: got nil (0xc0) at offset 0"},
+		{"ReadFloat64", []byte{0x90},
+			func(d *msgpack.Decoder) error { _, err := d.ReadFloat64(); return err },
+			msgpack.KindFloat, msgpack.KindArray, "bad prefix for float64: got fixarray(0) (0x90) at offset 0"},
+		{"ReadString", []byte{0xcc, 0x01},
+			func(d *msgpack.Decoder) error { _, err := d.ReadString(); return err },
+			msgpack.KindString, msgpack.KindUint, "bad prefix for string length: got uint8 (0xcc) at offset 0"},
+		{"ReadByteArray", []byte{0xcb, 0, 0, 0, 0, 0, 0, 0, 0},
+			func(d *msgpack.Decoder) error { _, err := d.ReadByteArray(); return err },
+			msgpack.KindBin, msgpack.KindFloat, "bad prefix for binary length: got float64 (0xcb) at offset 0"},
+		{"ReadArraySize", []byte{0x80},
+			func(d *msgpack.Decoder) error { _, err := d.ReadArraySize(); return err },
+			msgpack.KindArray, msgpack.KindMap, "bad prefix for array length: got fixmap(0) (0x80) at offset 0"},
+		{"ReadMapSize", []byte{0xc4, 0x00},
+			func(d *msgpack.Decoder) error { _, err := d.ReadMapSize(); return err },
+			msgpack.KindMap, msgpack.KindBin, "bad prefix for map length: got bin8 (0xc4) at offset 0"},
+		{"ReadTime", []byte{0x2a},
+			func(d *msgpack.Decoder) error { _, err := d.ReadTime(); return err },
+			msgpack.KindTime, msgpack.KindInt, "bad prefix for time (ext or string): got fixint(42) (0x2a) at offset 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := msgpack.NewDecoder(tt.data)
+			err := tt.read(&decoder)
+			assert.EqualError(t, err, tt.message)
+			var mismatch msgpack.TypeMismatchError
+			require.True(t, errors.As(err, &mismatch))
+			assert.Equal(t, tt.expected, mismatch.Expected)
+			assert.Equal(t, tt.actual, mismatch.Actual)
+			assert.Equal(t, tt.data[0], mismatch.ActualPrefix)
+			assert.Equal(t, uint32(0), mismatch.Offset)
+		})
+	}
+}
+
+func TestTypeMismatchErrorOffset(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{0x92, 0x01, 0xd7, 0x05, 0, 0, 0, 0, 0, 0, 0, 0})
+	_, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+	require.NoError(t, decoder.Skip())
+	_, err = decoder.ReadString()
+	var mismatch msgpack.TypeMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, msgpack.KindExt, mismatch.Actual)
+	assert.Equal(t, byte(0xd7), mismatch.ActualPrefix)
+	assert.Equal(t, uint32(2), mismatch.Offset)
+
+	decoder = msgpack.NewDecoder([]byte{0xc1})
+	assert.False(t, errors.As(decoder.Skip(), &mismatch), "an unknown format is not a mismatch")
+}
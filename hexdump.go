@@ -0,0 +1,185 @@
+package msgpack
+
+import (
+	"bytes"
+	"strconv"
+)
+
+const (
+	hexDumpLineBytes  = 16
+	hexDumpQuoteBytes = 32
+)
+
+// AppendHexDump appends an annotated hex dump of `data` to `dst` and returns
+// the extended slice. Each value starts a line holding its offset, its
+// bytes and a description, indented by nesting depth:
+//
+//	0000 82 | map(2)
+//	0001 a4 6e 61 6d 65 |   str(4) "name"
+//
+// Arrays and maps are shown by their header only, with their elements on
+// the lines that follow. Values longer than 16 bytes continue on lines
+// without a description, and runs of identical continuation lines are
+// collapsed to a single "*". `data` may hold several values in a row. From
+// the first value that cannot be read, the rest of `data` is dumped as
+// plain hex after a line starting with "!!" that gives the reason.
+func AppendHexDump(dst []byte, data []byte) []byte {
+	width := 4
+	if len(data) > 0xffff {
+		width = 8
+	}
+	d := NewDecoder(data)
+	// remaining holds the number of elements left in each open container.
+	var remaining []uint32
+	for d.Remaining() > 0 {
+		for len(remaining) > 0 && remaining[len(remaining)-1] == 0 {
+			remaining = remaining[:len(remaining)-1]
+		}
+		depth := len(remaining)
+		if depth > 0 {
+			remaining[depth-1]--
+		}
+
+		start := d.Offset()
+		description, children, err := describeNext(&d)
+		if err != nil {
+			dst = appendHexDumpOffset(dst, start, width)
+			dst = append(dst, " !! "...)
+			dst = append(dst, err.Error()...)
+			dst = append(dst, '\n')
+			return appendHexLines(dst, data[start:], start, width, "", 0)
+		}
+		if children > 0 {
+			remaining = append(remaining, children)
+		}
+		dst = appendHexLines(dst, data[start:d.Offset()], start, width, description, depth)
+	}
+	return dst
+}
+
+// describeNext reads the next value, or only the header of an array or
+// map, and returns its description and the number of elements that follow
+// the header.
+func describeNext(d *Decoder) (string, uint32, error) {
+	start := d.Offset()
+	prefix, err := d.PeekFormat()
+	if err != nil {
+		return "", 0, err
+	}
+	switch formatKind(prefix) {
+	case "array":
+		size, err := d.ReadArraySize()
+		return "array(" + strconv.FormatUint(uint64(size), 10) + ")", size, err
+	case "map":
+		size, err := d.ReadMapSize()
+		return "map(" + strconv.FormatUint(uint64(size), 10) + ")", 2 * size, err
+	}
+	if err := d.Skip(); err != nil {
+		return "", 0, err
+	}
+	value := NewDecoder(d.InputBuffer()[start:d.Offset()])
+	switch formatKind(prefix) {
+	case "string":
+		s, err := value.ReadStringBytes()
+		if err != nil {
+			return "", 0, err
+		}
+		quoted := strconv.Quote(string(truncateBytes(s, hexDumpQuoteBytes)))
+		if len(s) > hexDumpQuoteBytes {
+			quoted += "..."
+		}
+		return "str(" + strconv.Itoa(len(s)) + ") " + quoted, 0, nil
+	case "bin":
+		b, err := value.ReadByteArray()
+		return "bin(" + strconv.Itoa(len(b)) + ")", 0, err
+	case "ext":
+		value.reader.Discard(1)
+		extType, length, err := value.extHeader(prefix)
+		return "ext(" + strconv.Itoa(int(extType)) + ", " + strconv.FormatUint(uint64(length), 10) + ")", 0, err
+	case "nil", "bool":
+		return formatName(prefix), 0, nil
+	}
+	v, err := value.ReadAny()
+	if err != nil {
+		return "", 0, err
+	}
+	var s string
+	switch v := v.(type) {
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	case uint64:
+		s = strconv.FormatUint(v, 10)
+	default:
+		s = renderInteger(v)
+	}
+	return formatName(prefix) + " " + s, 0, nil
+}
+
+func renderInteger(v any) string {
+	switch v := v.(type) {
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	}
+	return "?"
+}
+
+func truncateBytes(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
+
+// appendHexLines dumps `b`, which starts at `offset`, 16 bytes per line.
+// The first line carries `description` unless it is empty.
+func appendHexLines(dst []byte, b []byte, offset uint32, width int, description string, depth int) []byte {
+	var previous []byte
+	elided := false
+	for i := 0; i < len(b); i += hexDumpLineBytes {
+		line := truncateBytes(b[i:], hexDumpLineBytes)
+		if i > 0 && bytes.Equal(line, previous) {
+			if !elided {
+				dst = append(dst, "*\n"...)
+				elided = true
+			}
+			continue
+		}
+		previous, elided = line, false
+		dst = appendHexDumpOffset(dst, offset+uint32(i), width)
+		for _, c := range line {
+			dst = append(dst, ' ', hexDigits[c>>4], hexDigits[c&0x0f])
+		}
+		if i == 0 && description != "" {
+			dst = append(dst, " | "...)
+			for j := 0; j < depth; j++ {
+				dst = append(dst, "  "...)
+			}
+			dst = append(dst, description...)
+		}
+		dst = append(dst, '\n')
+	}
+	return dst
+}
+
+const hexDigits = "0123456789abcdef"
+
+func appendHexDumpOffset(dst []byte, offset uint32, width int) []byte {
+	for shift := 4 * (width - 1); shift >= 0; shift -= 4 {
+		dst = append(dst, hexDigits[(offset>>shift)&0x0f])
+	}
+	return dst
+}
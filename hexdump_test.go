@@ -0,0 +1,70 @@
+package msgpack_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func checkGolden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "hexdump", name+".txt")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, actual, 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func TestHexDumpGolden(t *testing.T) {
+	for _, m := range compatMessages {
+		t.Run(m.name, func(t *testing.T) {
+			checkGolden(t, m.name, msgpack.AppendHexDump(nil, readCompatCorpus(t, m.name)))
+		})
+	}
+}
+
+func TestHexDumpCorruptTail(t *testing.T) {
+	data := readCompatCorpus(t, "record")
+	checkGolden(t, "record-truncated", msgpack.AppendHexDump(nil, data[:len(data)-20]))
+
+	dump := msgpack.AppendHexDump([]byte("prefix\n"), []byte{0x92, 0x01, 0xc1, 0x02})
+	assert.Equal(t, "prefix\n"+
+		"0000 92 | array(2)\n"+
+		"0001 01 |   fixint 1\n"+
+		"0002 !! bad prefix: got never used (0xc1) at offset 2\n"+
+		"0002 c1 02\n", string(dump))
+}
+
+func TestHexDumpSeveralValues(t *testing.T) {
+	dump := msgpack.AppendHexDump(nil, []byte{0x81, 0xa4, 'n', 'a', 'm', 'e', 0xc0, 0xc3})
+	assert.Equal(t, ""+
+		"0000 81 | map(1)\n"+
+		"0001 a4 6e 61 6d 65 |   str(4) \"name\"\n"+
+		"0006 c0 |   nil\n"+
+		"0007 c3 | true\n", string(dump))
+}
+
+func TestBase64RoundTrip(t *testing.T) {
+	message := poolMessage{ID: 7, Body: "hello"}
+	s, err := msgpack.EncodeToBase64(&message)
+	require.NoError(t, err)
+	assert.Equal(t, "kgelaGVsbG8=", s)
+
+	decoded, err := msgpack.DecodeFromBase64[poolMessage](s)
+	require.NoError(t, err)
+	assert.Equal(t, message, decoded)
+
+	_, err = msgpack.DecodeFromBase64[poolMessage]("not base64!")
+	assert.Error(t, err)
+}
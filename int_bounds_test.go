@@ -0,0 +1,83 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestInt64AndUint64Extremes(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteInt64(math.MinInt64)
+		w.WriteInt64(math.MaxInt64)
+		w.WriteUint64(math.MaxUint64)
+		w.WriteUint64(math.MaxInt64 + 1)
+	})
+	assert.Equal(t, []byte{msgpack.FormatInt64, 0x80, 0, 0, 0, 0, 0, 0, 0}, data[:9])
+
+	decoder := msgpack.NewDecoder(data)
+	i, err := decoder.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt64), i)
+	i, err = decoder.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), i)
+	u, err := decoder.ReadUint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), u)
+	u, err = decoder.ReadUint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxInt64+1), u)
+
+	// ReadAny keeps the full value in the type of the wire format.
+	decoder = msgpack.NewDecoder(data)
+	for _, expected := range []any{int64(math.MinInt64), int64(math.MaxInt64),
+		uint64(math.MaxUint64), uint64(math.MaxInt64 + 1)} {
+		v, err := decoder.ReadAny()
+		require.NoError(t, err)
+		assert.Equal(t, expected, v)
+	}
+
+	// So do WriteAny, Value and the WriterAdapter transcoding.
+	reencoded := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteAny(int64(math.MinInt64))
+		w.WriteAny(int64(math.MaxInt64))
+		w.WriteAny(uint64(math.MaxUint64))
+		w.WriteAny(uint64(math.MaxInt64 + 1))
+	})
+	assert.Equal(t, data, reencoded)
+	adapted := encodeWith(t, func(w msgpack.Writer) {
+		a := &msgpack.WriterAdapter{WriterCore: w}
+		a.WriteRaw(data)
+	})
+	assert.Equal(t, data, adapted)
+
+	v, err := msgpack.DecodeValue(data)
+	require.NoError(t, err)
+	i, ok := v.Int()
+	assert.True(t, ok)
+	assert.Equal(t, int64(math.MinInt64), i)
+	_, ok = v.Uint()
+	assert.False(t, ok)
+	v, err = msgpack.DecodeValue(data[18:])
+	require.NoError(t, err)
+	u, ok = v.Uint()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(math.MaxUint64), u)
+	_, ok = v.Int()
+	assert.False(t, ok)
+}
+
+func TestReadUint64NeverWrapsNegatives(t *testing.T) {
+	for _, value := range []int64{-1, math.MinInt8, math.MinInt16, math.MinInt32, math.MinInt64} {
+		data := encodeWith(t, func(w msgpack.Writer) { w.WriteInt64(value) })
+		decoder := msgpack.NewDecoder(data)
+		u, err := decoder.ReadUint64()
+		assert.Error(t, err, "%d", value)
+		assert.Zero(t, u)
+	}
+}
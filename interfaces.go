@@ -4,77 +4,138 @@ import (
 	"time"
 )
 
-// Reader is the interface for reading data from the MessagePack format.
+// Reader is the interface for reading data from the MessagePack format. It
+// is made of ReaderCore, which a custom reader must implement itself, and
+// NillableReader and ReaderExtras, which ReaderAdapter can provide in terms
+// of ReaderCore.
 type Reader interface {
-	IsNextNil() (bool, error)
+	ReaderCore
+	NillableReader
+	ReaderExtras
+}
+
+// ReaderCore holds the primitive read methods of Reader.
+type ReaderCore interface {
+	PeekIsNil() (bool, error)
+	ConsumeNil() error
 	ReadBool() (bool, error)
-	ReadNillableBool() (*bool, error)
 	ReadInt8() (int8, error)
-	ReadNillableInt8() (*int8, error)
 	ReadInt16() (int16, error)
-	ReadNillableInt16() (*int16, error)
 	ReadInt32() (int32, error)
-	ReadNillableInt32() (*int32, error)
 	ReadInt64() (int64, error)
-	ReadNillableInt64() (*int64, error)
 	ReadUint8() (uint8, error)
-	ReadNillableUint8() (*uint8, error)
 	ReadUint16() (uint16, error)
-	ReadNillableUint16() (*uint16, error)
 	ReadUint32() (uint32, error)
-	ReadNillableUint32() (*uint32, error)
 	ReadUint64() (uint64, error)
-	ReadNillableUint64() (*uint64, error)
 	ReadFloat32() (float32, error)
-	ReadNillableFloat32() (*float32, error)
 	ReadFloat64() (float64, error)
-	ReadNillableFloat64() (*float64, error)
 	ReadString() (string, error)
-	ReadNillableString() (*string, error)
 	ReadTime() (time.Time, error)
-	ReadNillableTime() (*time.Time, error)
 	ReadByteArray() ([]byte, error)
-	ReadNillableByteArray() ([]byte, error)
 	ReadArraySize() (uint32, error)
 	ReadMapSize() (uint32, error)
+	Err() error
+}
+
+// NillableReader holds the methods of Reader that accept nil in place of a
+// value.
+type NillableReader interface {
+	// Deprecated: use PeekIsNil and ConsumeNil.
+	IsNextNil() (bool, error)
+	ReadNillableBool() (*bool, error)
+	ReadNillableInt8() (*int8, error)
+	ReadNillableInt16() (*int16, error)
+	ReadNillableInt32() (*int32, error)
+	ReadNillableInt64() (*int64, error)
+	ReadNillableUint8() (*uint8, error)
+	ReadNillableUint16() (*uint16, error)
+	ReadNillableUint32() (*uint32, error)
+	ReadNillableUint64() (*uint64, error)
+	ReadNillableFloat32() (*float32, error)
+	ReadNillableFloat64() (*float64, error)
+	ReadNillableString() (*string, error)
+	ReadNillableTime() (*time.Time, error)
+	ReadNillableByteArray() ([]byte, error)
+	ReadNillableByteArrayStrict() (value []byte, isNil bool, err error)
+	ReadNillableRaw() (Raw, error)
+}
+
+// ReaderExtras holds the methods of Reader that read whole values of any
+// kind.
+type ReaderExtras interface {
+	ReadRaw() (Raw, error)
 	ReadAny() (any, error)
+	ReadAnyWithRaw() (any, Raw, error)
 	Skip() error
-	Err() error
 }
 
-// Writer is the interface for writing data to the MessagePack format.
+// Writer is the interface for writing data to the MessagePack format. It
+// is made of WriterCore, which a custom writer must implement itself, and
+// NillableWriter and WriterExtras, which WriterAdapter can provide in terms
+// of WriterCore.
 type Writer interface {
+	WriterCore
+	NillableWriter
+	WriterExtras
+}
+
+// WriterCore holds the primitive write methods of Writer.
+type WriterCore interface {
 	WriteNil()
 	WriteBool(value bool)
-	WriteNillableBool(value *bool)
 	WriteInt8(value int8)
-	WriteNillableInt8(value *int8)
 	WriteInt16(value int16)
-	WriteNillableInt16(value *int16)
 	WriteInt32(value int32)
-	WriteNillableInt32(value *int32)
 	WriteInt64(value int64)
-	WriteNillableInt64(value *int64)
 	WriteUint8(value uint8)
-	WriteNillableUint8(value *uint8)
 	WriteUint16(value uint16)
-	WriteNillableUint16(value *uint16)
 	WriteUint32(value uint32)
-	WriteNillableUint32(value *uint32)
 	WriteUint64(value uint64)
-	WriteNillableUint64(value *uint64)
 	WriteFloat32(value float32)
-	WriteNillableFloat32(value *float32)
 	WriteFloat64(value float64)
-	WriteNillableFloat64(value *float64)
 	WriteString(value string)
-	WriteNillableString(value *string)
 	WriteTime(value time.Time)
-	WriteNillableTime(value *time.Time)
 	WriteByteArray(value []byte)
-	WriteNillableByteArray(value []byte)
 	WriteArraySize(length uint32)
 	WriteMapSize(length uint32)
-	WriteAny(value any)
 	Err() error
 }
+
+// NillableWriter holds the methods of Writer that write nil for a nil
+// pointer.
+type NillableWriter interface {
+	WriteNillableBool(value *bool)
+	WriteNillableInt8(value *int8)
+	WriteNillableInt16(value *int16)
+	WriteNillableInt32(value *int32)
+	WriteNillableInt64(value *int64)
+	WriteNillableUint8(value *uint8)
+	WriteNillableUint16(value *uint16)
+	WriteNillableUint32(value *uint32)
+	WriteNillableUint64(value *uint64)
+	WriteNillableFloat32(value *float32)
+	WriteNillableFloat64(value *float64)
+	WriteNillableString(value *string)
+	WriteNillableTime(value *time.Time)
+	WriteNillableByteArray(value []byte)
+	WriteNillableRaw(value Raw)
+}
+
+// WriterExtras holds the methods of Writer that write assembled or
+// dynamically typed values, or that write bytes as they are.
+type WriterExtras interface {
+	WriteByteArrayVec(segments ...[]byte)
+	WriteStringVec(segments ...string)
+	WriteAny(value any)
+	WriteStringAnyMap(value map[string]any)
+	WriteRaw(value Raw)
+	ReserveArraySize() HeaderMark
+	PatchArraySize(mark HeaderMark, length uint32)
+	ReserveMapSize() HeaderMark
+	PatchMapSize(mark HeaderMark, length uint32)
+	ReserveStringHeader() HeaderMark
+	PatchStringHeader(mark HeaderMark, length uint32)
+	ReserveBinHeader() HeaderMark
+	PatchBinHeader(mark HeaderMark, length uint32)
+	WriteRawBytes(value []byte)
+}
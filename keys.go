@@ -0,0 +1,77 @@
+package msgpack
+
+import (
+	"strconv"
+	"sync"
+)
+
+// KeyRegistry hands out the map keys of a schema so that no key is used
+// for two fields. Declare the keys as package variables, so a duplicate
+// panics when the program starts rather than corrupting data later:
+//
+//	var (
+//		keys  = msgpack.NewKeyRegistry()
+//		keyTS = keys.MustKey("ts")
+//		keyID = keys.MustKey("id")
+//	)
+//
+// A registry is safe for concurrent use.
+type KeyRegistry struct {
+	mu    sync.Mutex
+	names map[string]struct{}
+	order []string
+}
+
+// Key is a map key registered with a KeyRegistry. Write it with WriteKey,
+// compare read keys against it with MatchKey, or declare an ObjectCodec
+// field with it using KeyField.
+type Key struct {
+	name string
+}
+
+// NewKeyRegistry creates an empty registry.
+func NewKeyRegistry() *KeyRegistry {
+	return &KeyRegistry{names: map[string]struct{}{}}
+}
+
+// MustKey registers `name` and returns its Key. Registering a name twice
+// panics.
+func (r *KeyRegistry) MustKey(name string) Key {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.names[name]; exists {
+		panic("msgpack: key " + strconv.Quote(name) + " registered twice")
+	}
+	r.names[name] = struct{}{}
+	r.order = append(r.order, name)
+	return Key{name}
+}
+
+// Dump returns the registered keys in the order they were registered, for
+// generating schema documentation.
+func (r *KeyRegistry) Dump() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.order...)
+}
+
+// String returns the key as it is written.
+func (k Key) String() string {
+	return k.name
+}
+
+// WriteKey writes `k` as a string.
+func WriteKey(w Writer, k Key) {
+	w.WriteString(k.name)
+}
+
+// MatchKey reports whether `keyBytes`, as returned by ReadStringBytes, are
+// the key `k`.
+func MatchKey(keyBytes []byte, k Key) bool {
+	return string(keyBytes) == k.name
+}
+
+// KeyField adds a field stored under the registered key `k`. See Field.
+func (o *ObjectCodec[T]) KeyField(k Key, field FieldCodec[T]) *ObjectCodec[T] {
+	return o.Field(k.name, field)
+}
@@ -0,0 +1,115 @@
+package msgpack_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+var (
+	sampleKeys     = msgpack.NewKeyRegistry()
+	sampleKeyTS    = sampleKeys.MustKey("ts")
+	sampleKeyValue = sampleKeys.MustKey("v")
+)
+
+type sample struct {
+	TS    int64
+	Value string
+}
+
+var sampleCodec = msgpack.Object[sample]().
+	KeyField(sampleKeyTS, msgpack.Int64Field(func(s *sample) *int64 { return &s.TS })).
+	KeyField(sampleKeyValue, msgpack.StringField(func(s *sample) *string { return &s.Value }))
+
+func TestKeyRegistryDuplicate(t *testing.T) {
+	keys := msgpack.NewKeyRegistry()
+	keys.MustKey("a")
+	assert.PanicsWithValue(t, `msgpack: key "a" registered twice`, func() { keys.MustKey("a") })
+	keys.MustKey("b")
+	assert.Equal(t, []string{"a", "b"}, keys.Dump())
+	assert.Equal(t, []string{"ts", "v"}, sampleKeys.Dump())
+}
+
+func TestKeyRegistryConcurrent(t *testing.T) {
+	keys := msgpack.NewKeyRegistry()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	panics := 0
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				if recover() != nil {
+					mu.Lock()
+					panics++
+					mu.Unlock()
+				}
+			}()
+			// Every name is registered by two goroutines.
+			keys.MustKey(strconv.Itoa(i / 2))
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 32, panics)
+	assert.Len(t, keys.Dump(), 32)
+}
+
+func TestKeyFieldDispatch(t *testing.T) {
+	value := sample{TS: 1700000000, Value: "up"}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, sampleCodec.Encode(w, &value))
+	})
+
+	var decoded sample
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, sampleCodec.Decode(&decoder, &decoded))
+	assert.Equal(t, value, decoded)
+
+	// The same keys dispatch a hand-written decode loop without creating
+	// strings for the keys.
+	decoded = sample{}
+	decoder = msgpack.NewDecoder(data)
+	size, err := decoder.ReadMapSize()
+	require.NoError(t, err)
+	for i := uint32(0); i < size; i++ {
+		key, err := decoder.ReadStringBytes()
+		require.NoError(t, err)
+		switch {
+		case msgpack.MatchKey(key, sampleKeyTS):
+			decoded.TS, err = decoder.ReadInt64()
+		case msgpack.MatchKey(key, sampleKeyValue):
+			decoded.Value, err = decoder.ReadString()
+		default:
+			err = decoder.Skip()
+		}
+		require.NoError(t, err)
+	}
+	assert.Equal(t, value, decoded)
+}
+
+func TestWriteKey(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		msgpack.WriteKey(w, sampleKeyTS)
+		w.WriteInt64(1)
+	})
+	decoder := msgpack.NewDecoder(data)
+	_, err := decoder.ReadMapSize()
+	require.NoError(t, err)
+	key, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, sampleKeyTS.String(), key)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if !msgpack.MatchKey([]byte{'t', 's'}, sampleKeyTS) {
+			t.Fatal("no match")
+		}
+	})
+	assert.Zero(t, allocs)
+}
@@ -0,0 +1,100 @@
+package msgpack
+
+import "time"
+
+// LegacyWriter is the minimal writer interface implemented by older
+// generated code, which predates nillable values, times, reserved headers,
+// raw values and Err.
+//
+// Deprecated: implement WriterCore and use WriterAdapter instead. Wrap an
+// existing LegacyWriter with UpgradeWriter to pass it where a Writer is
+// expected.
+type LegacyWriter interface {
+	WriteNil()
+	WriteBool(value bool)
+	WriteInt8(value int8)
+	WriteInt16(value int16)
+	WriteInt32(value int32)
+	WriteInt64(value int64)
+	WriteUint8(value uint8)
+	WriteUint16(value uint16)
+	WriteUint32(value uint32)
+	WriteUint64(value uint64)
+	WriteFloat32(value float32)
+	WriteFloat64(value float64)
+	WriteString(value string)
+	WriteByteArray(value []byte)
+	WriteArraySize(length uint32)
+	WriteMapSize(length uint32)
+}
+
+// UpgradeWriter returns a Writer that writes to `lw`, so that code written
+// against Writer accepts legacy implementations. The methods of
+// LegacyWriter are passed on as they are, and the others are written with
+// them as by WriterAdapter: nillable methods write nil or the value, and
+// WriteAny and WriteRaw write the elements of their value one at a time.
+//
+// A LegacyWriter cannot write times or extension values, reserve headers
+// or write raw bytes. Those calls, including WriteAny and WriteRaw of a
+// value that holds a time, leave the output incomplete and make Err return
+// a WriteError. As a LegacyWriter reports no errors of its own, Err
+// returns nil otherwise.
+func UpgradeWriter(lw LegacyWriter) Writer {
+	return &WriterAdapter{WriterCore: &legacyCore{LegacyWriter: lw}}
+}
+
+// legacyCore implements WriterCore with a LegacyWriter.
+type legacyCore struct {
+	LegacyWriter
+	err error
+}
+
+func (c *legacyCore) unsupported(what string) {
+	if c.err == nil {
+		c.err = WriteError{message: "msgpack: a LegacyWriter cannot write " + what}
+	}
+}
+
+func (c *legacyCore) WriteTime(value time.Time) {
+	c.unsupported("times")
+}
+
+func (c *legacyCore) ReserveArraySize() HeaderMark {
+	c.unsupported("reserved headers")
+	return HeaderMark{}
+}
+
+func (c *legacyCore) PatchArraySize(mark HeaderMark, length uint32) {
+	c.unsupported("reserved headers")
+}
+
+func (c *legacyCore) ReserveMapSize() HeaderMark {
+	c.unsupported("reserved headers")
+	return HeaderMark{}
+}
+
+func (c *legacyCore) PatchMapSize(mark HeaderMark, length uint32) {
+	c.unsupported("reserved headers")
+}
+
+func (c *legacyCore) ReserveStringHeader() HeaderMark {
+	c.unsupported("reserved headers")
+	return HeaderMark{}
+}
+
+func (c *legacyCore) PatchStringHeader(mark HeaderMark, length uint32) {
+	c.unsupported("reserved headers")
+}
+
+func (c *legacyCore) ReserveBinHeader() HeaderMark {
+	c.unsupported("reserved headers")
+	return HeaderMark{}
+}
+
+func (c *legacyCore) PatchBinHeader(mark HeaderMark, length uint32) {
+	c.unsupported("reserved headers")
+}
+
+func (c *legacyCore) Err() error {
+	return c.err
+}
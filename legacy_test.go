@@ -0,0 +1,112 @@
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// legacyWriter hides everything but the LegacyWriter methods, like older
+// generated code would.
+type legacyWriter struct{ msgpack.LegacyWriter }
+
+// The conformance table pins which Writer methods work through
+// UpgradeWriter. Those that work write what an Encoder writes.
+func TestUpgradeWriter(t *testing.T) {
+	b, i8, i16, i32, i64 := true, int8(-3), int16(-300), int32(-70000), int64(-5000000000)
+	u8, u16, u32, u64 := uint8(200), uint16(60000), uint32(70000), uint64(5000000000)
+	f32, f64, s := float32(1.5), 2.25, "text"
+	tm := time.Unix(1700000000, 0)
+
+	works := map[string]func(w msgpack.Writer){
+		"WriteNil":               func(w msgpack.Writer) { w.WriteNil() },
+		"WriteBool":              func(w msgpack.Writer) { w.WriteBool(true) },
+		"WriteInt8":              func(w msgpack.Writer) { w.WriteInt8(i8) },
+		"WriteInt16":             func(w msgpack.Writer) { w.WriteInt16(i16) },
+		"WriteInt32":             func(w msgpack.Writer) { w.WriteInt32(i32) },
+		"WriteInt64":             func(w msgpack.Writer) { w.WriteInt64(i64) },
+		"WriteUint8":             func(w msgpack.Writer) { w.WriteUint8(u8) },
+		"WriteUint16":            func(w msgpack.Writer) { w.WriteUint16(u16) },
+		"WriteUint32":            func(w msgpack.Writer) { w.WriteUint32(u32) },
+		"WriteUint64":            func(w msgpack.Writer) { w.WriteUint64(u64) },
+		"WriteFloat32":           func(w msgpack.Writer) { w.WriteFloat32(f32) },
+		"WriteFloat64":           func(w msgpack.Writer) { w.WriteFloat64(f64) },
+		"WriteString":            func(w msgpack.Writer) { w.WriteString(s) },
+		"WriteByteArray":         func(w msgpack.Writer) { w.WriteByteArray([]byte{1, 2}) },
+		"WriteArraySize":         func(w msgpack.Writer) { w.WriteArraySize(2); w.WriteNil(); w.WriteNil() },
+		"WriteMapSize":           func(w msgpack.Writer) { w.WriteMapSize(1); w.WriteString("k"); w.WriteNil() },
+		"WriteNillableBool":      func(w msgpack.Writer) { w.WriteNillableBool(&b); w.WriteNillableBool(nil) },
+		"WriteNillableInt8":      func(w msgpack.Writer) { w.WriteNillableInt8(&i8); w.WriteNillableInt8(nil) },
+		"WriteNillableInt16":     func(w msgpack.Writer) { w.WriteNillableInt16(&i16); w.WriteNillableInt16(nil) },
+		"WriteNillableInt32":     func(w msgpack.Writer) { w.WriteNillableInt32(&i32); w.WriteNillableInt32(nil) },
+		"WriteNillableInt64":     func(w msgpack.Writer) { w.WriteNillableInt64(&i64); w.WriteNillableInt64(nil) },
+		"WriteNillableUint8":     func(w msgpack.Writer) { w.WriteNillableUint8(&u8); w.WriteNillableUint8(nil) },
+		"WriteNillableUint16":    func(w msgpack.Writer) { w.WriteNillableUint16(&u16); w.WriteNillableUint16(nil) },
+		"WriteNillableUint32":    func(w msgpack.Writer) { w.WriteNillableUint32(&u32); w.WriteNillableUint32(nil) },
+		"WriteNillableUint64":    func(w msgpack.Writer) { w.WriteNillableUint64(&u64); w.WriteNillableUint64(nil) },
+		"WriteNillableFloat32":   func(w msgpack.Writer) { w.WriteNillableFloat32(&f32); w.WriteNillableFloat32(nil) },
+		"WriteNillableFloat64":   func(w msgpack.Writer) { w.WriteNillableFloat64(&f64); w.WriteNillableFloat64(nil) },
+		"WriteNillableString":    func(w msgpack.Writer) { w.WriteNillableString(&s); w.WriteNillableString(nil) },
+		"WriteNillableTime(nil)": func(w msgpack.Writer) { w.WriteNillableTime(nil) },
+		"WriteNillableByteArray": func(w msgpack.Writer) { w.WriteNillableByteArray([]byte{1}); w.WriteNillableByteArray(nil) },
+		"WriteByteArrayVec":      func(w msgpack.Writer) { w.WriteByteArrayVec([]byte{1}, []byte{2, 3}) },
+		"WriteStringVec":         func(w msgpack.Writer) { w.WriteStringVec("ab", "cd") },
+		"WriteAny":               func(w msgpack.Writer) { w.WriteAny([]any{int64(1), "two", map[string]any{"three": 3.0}}) },
+		"WriteStringAnyMap":      func(w msgpack.Writer) { w.WriteStringAnyMap(map[string]any{"k": []any{true}}) },
+		"WriteRaw":               func(w msgpack.Writer) { w.WriteRaw(msgpack.Raw{0x92, 0xc3, 0xa1, 'x'}) },
+	}
+	for name, write := range works {
+		t.Run(name, func(t *testing.T) {
+			want := encodeWith(t, write)
+			encoder := msgpack.NewEncoder(make([]byte, len(want)))
+			w := msgpack.UpgradeWriter(legacyWriter{&encoder})
+			write(w)
+			require.NoError(t, w.Err())
+			assert.Equal(t, want, encoder.Bytes())
+		})
+	}
+
+	fails := map[string]func(w msgpack.Writer){
+		"WriteTime":            func(w msgpack.Writer) { w.WriteTime(tm) },
+		"WriteNillableTime":    func(w msgpack.Writer) { w.WriteNillableTime(&tm) },
+		"WriteAny(time)":       func(w msgpack.Writer) { w.WriteAny(tm) },
+		"WriteRaw(time)":       func(w msgpack.Writer) { w.WriteRaw(encodeWith(t, func(w msgpack.Writer) { w.WriteTime(tm) })) },
+		"ReserveArraySize":     func(w msgpack.Writer) { w.PatchArraySize(w.ReserveArraySize(), 0) },
+		"ReserveMapSize":       func(w msgpack.Writer) { w.PatchMapSize(w.ReserveMapSize(), 0) },
+		"ReserveStringHeader":  func(w msgpack.Writer) { w.PatchStringHeader(w.ReserveStringHeader(), 0) },
+		"ReserveBinHeader":     func(w msgpack.Writer) { w.PatchBinHeader(w.ReserveBinHeader(), 0) },
+		"WriteRawBytes":        func(w msgpack.Writer) { w.WriteRawBytes([]byte{0xc0}) },
+		"WriteRaw(ext)":        func(w msgpack.Writer) { w.WriteRaw(msgpack.Raw{msgpack.FormatFixExt1, 5, 0}) },
+		"WriteStringAnyMap(t)": func(w msgpack.Writer) { w.WriteStringAnyMap(map[string]any{"at": tm}) },
+	}
+	for name, write := range fails {
+		t.Run(name, func(t *testing.T) {
+			encoder := msgpack.NewEncoder(make([]byte, 64))
+			w := msgpack.UpgradeWriter(legacyWriter{&encoder})
+			write(w)
+			var writeErr msgpack.WriteError
+			assert.ErrorAs(t, w.Err(), &writeErr)
+		})
+	}
+}
+
+func TestUpgradeWriterErrors(t *testing.T) {
+	var sizer msgpack.Sizer
+	w := msgpack.UpgradeWriter(legacyWriter{&sizer})
+	w.WriteArraySize(2)
+	w.WriteTime(time.Now())
+	w.PatchMapSize(w.ReserveMapSize(), 0)
+	assert.EqualError(t, w.Err(), "msgpack: a LegacyWriter cannot write times", "the first error is kept")
+	assert.Equal(t, uint32(1), sizer.Len(), "the time is not written")
+
+	w = msgpack.UpgradeWriter(legacyWriter{&sizer})
+	w.WriteRawBytes([]byte{0xc0})
+	assert.EqualError(t, w.Err(), "msgpack: the WriterCore cannot write raw bytes")
+	w = msgpack.UpgradeWriter(legacyWriter{&sizer})
+	w.ReserveBinHeader()
+	assert.EqualError(t, w.Err(), "msgpack: a LegacyWriter cannot write reserved headers")
+}
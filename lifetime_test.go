@@ -0,0 +1,79 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// These tests pin the aliasing behavior documented under "Lifetime" in the
+// package documentation. Changing the copy semantics of the decoder must
+// be a deliberate decision that updates them.
+
+// skipUnlessAliased skips tests of strings aliasing the input in builds
+// where the decoder copies them, such as purego ones.
+func skipUnlessAliased(t testing.TB, decoder *msgpack.Decoder) {
+	t.Helper()
+	if !decoder.AliasesInput() {
+		t.Skip("strings are copied from the input in this build")
+	}
+}
+
+func TestDecoderAliasesInput(t *testing.T) {
+	buffer := []byte{0xa3, 'f', 'o', 'o'}
+	decoder := msgpack.NewDecoder(buffer)
+	assert.Equal(t, &buffer[0], &decoder.InputBuffer()[0])
+	skipUnlessAliased(t, &decoder)
+	assert.True(t, decoder.AliasesInput())
+}
+
+func TestReadStringAliasesInput(t *testing.T) {
+	buffer := []byte{0xa3, 'f', 'o', 'o'}
+	decoder := msgpack.NewDecoder(buffer)
+	skipUnlessAliased(t, &decoder)
+	value, err := decoder.ReadString()
+	require.NoError(t, err)
+	buffer[1] = 'b'
+	assert.Equal(t, "boo", value)
+}
+
+func TestReadByteArrayAliasesInput(t *testing.T) {
+	buffer := []byte{msgpack.FormatBin8, 3, 1, 2, 3}
+	decoder := msgpack.NewDecoder(buffer)
+	value, err := decoder.ReadByteArray()
+	require.NoError(t, err)
+	buffer[2] = 9
+	assert.Equal(t, []byte{9, 2, 3}, value)
+}
+
+func TestReadRawAliasesInput(t *testing.T) {
+	buffer := []byte{0x91, 0x01}
+	decoder := msgpack.NewDecoder(buffer)
+	value, err := decoder.ReadRaw()
+	require.NoError(t, err)
+	buffer[1] = 0x02
+	assert.Equal(t, msgpack.Raw{0x91, 0x02}, value)
+}
+
+func TestReadAnyAliasesInput(t *testing.T) {
+	buffer := []byte{0x92, 0xa1, 'a', msgpack.FormatBin8, 1, 7}
+	decoder := msgpack.NewDecoder(buffer)
+	skipUnlessAliased(t, &decoder)
+	value, err := decoder.ReadAny()
+	require.NoError(t, err)
+	buffer[2] = 'z'
+	buffer[5] = 8
+	assert.Equal(t, []any{"z", []byte{8}}, value)
+}
+
+func TestReadRawCopyBoundedDetachesFromInput(t *testing.T) {
+	buffer := []byte{0x91, 0x01}
+	decoder := msgpack.NewDecoder(buffer)
+	value, err := decoder.ReadRawCopyBounded(2)
+	require.NoError(t, err)
+	buffer[1] = 0x02
+	assert.Equal(t, msgpack.Raw{0x91, 0x01}, value)
+}
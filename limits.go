@@ -0,0 +1,74 @@
+package msgpack
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrValueTooLarge is matched by the ValueTooLargeError returned for a
+// string or bin longer than the limit set with WithMaxStringLen or
+// WithMaxBinLen.
+var ErrValueTooLarge = errors.New("msgpack: value too large")
+
+// ValueTooLargeError reports a string or bin whose declared length is over
+// the decoder's limit, or too long for the buffer it is read into. It
+// matches ErrValueTooLarge with errors.Is.
+type ValueTooLargeError struct {
+	// Kind is "string" or "bin", or "raw" for a value read with
+	// ReadRawBounded.
+	Kind string
+	// Length is the length declared in the value's header.
+	Length uint32
+	// Limit is the configured maximum, or the size of the destination
+	// for ReadStringFixed and ReadByteArrayFixed.
+	Limit uint32
+	// Offset is the position of the value in the buffer.
+	Offset uint32
+}
+
+func (e ValueTooLargeError) Error() string {
+	return "msgpack: " + e.Kind + " of " + strconv.FormatUint(uint64(e.Length), 10) +
+		" bytes exceeds the limit of " + strconv.FormatUint(uint64(e.Limit), 10) +
+		" at offset " + strconv.FormatUint(uint64(e.Offset), 10)
+}
+
+func (e ValueTooLargeError) Unwrap() error {
+	return ErrValueTooLarge
+}
+
+// WithMaxStringLen makes the decoder reject strings longer than `n` bytes,
+// including strings it skips. The length is checked as soon as the header
+// is read. The default is no limit.
+func WithMaxStringLen(n uint32) DecOption {
+	return func(o *decOptions) {
+		o.maxStringLen = n
+	}
+}
+
+// WithMaxBinLen makes the decoder reject bin values longer than `n` bytes,
+// including values it skips. The length is checked as soon as the header
+// is read. The default is no limit.
+func WithMaxBinLen(n uint32) DecOption {
+	return func(o *decOptions) {
+		o.maxBinLen = n
+	}
+}
+
+const (
+	kindString = "string"
+	kindBin    = "bin"
+	kindRaw    = "raw"
+)
+
+// checkLen returns an error when a `kind` value of `length` bytes starting
+// at `offset` is over its limit.
+func (d *Decoder) checkLen(kind string, length, offset uint32) error {
+	limit := d.options.maxBinLen
+	if kind == kindString {
+		limit = d.options.maxStringLen
+	}
+	if limit == 0 || length <= limit {
+		return nil
+	}
+	return ValueTooLargeError{Kind: kind, Length: length, Limit: limit, Offset: offset}
+}
@@ -0,0 +1,115 @@
+package msgpack_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestMaxStringLen(t *testing.T) {
+	atLimit := encodeWith(t, func(w msgpack.Writer) { w.WriteString(strings.Repeat("x", 40)) })
+	overLimit := encodeWith(t, func(w msgpack.Writer) { w.WriteString(strings.Repeat("x", 41)) })
+
+	decoder := msgpack.NewDecoderWithOptions(atLimit, msgpack.WithMaxStringLen(40))
+	_, err := decoder.ReadString()
+	require.NoError(t, err)
+
+	decoder = msgpack.NewDecoderWithOptions(overLimit, msgpack.WithMaxStringLen(40))
+	_, err = decoder.ReadString()
+	assert.ErrorIs(t, err, msgpack.ErrValueTooLarge)
+	assert.EqualError(t, err, "msgpack: string of 41 bytes exceeds the limit of 40 at offset 0")
+
+	decoder = msgpack.NewDecoderWithOptions(overLimit, msgpack.WithMaxStringLen(40))
+	_, err = decoder.ReadAny()
+	assert.ErrorIs(t, err, msgpack.ErrValueTooLarge)
+
+	// Unlimited by default.
+	decoder = msgpack.NewDecoder(overLimit)
+	_, err = decoder.ReadString()
+	assert.NoError(t, err)
+}
+
+func TestMaxBinLen(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteByteArray(make([]byte, 300))
+		w.WriteByteArray(make([]byte, 301))
+	})
+	for _, read := range []func(d *msgpack.Decoder) error{
+		func(d *msgpack.Decoder) error { _, err := d.ReadByteArray(); return err },
+		func(d *msgpack.Decoder) error { _, err := d.ReadAny(); return err },
+		func(d *msgpack.Decoder) error { return d.Skip() },
+	} {
+		decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithMaxBinLen(300))
+		require.NoError(t, read(&decoder))
+		err := read(&decoder)
+		var tooLarge msgpack.ValueTooLargeError
+		require.ErrorAs(t, err, &tooLarge)
+		assert.Equal(t, msgpack.ValueTooLargeError{Kind: "bin", Length: 301, Limit: 300, Offset: 303}, tooLarge)
+	}
+}
+
+func TestMaxStringLenNestedAndSkipped(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("ignored")
+		w.WriteArraySize(1)
+		w.WriteString(strings.Repeat("x", 1000))
+		w.WriteString("wanted")
+		w.WriteString("ok")
+	})
+
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithMaxStringLen(100))
+	_, err := decoder.ReadAny()
+	assert.EqualError(t, err, "msgpack: string of 1000 bytes exceeds the limit of 100 at offset 10")
+
+	// A skipped field cannot hide the value.
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithMaxStringLen(100))
+	_, err = decoder.ReadMapSize()
+	require.NoError(t, err)
+	_, err = decoder.ReadString()
+	require.NoError(t, err)
+	assert.ErrorIs(t, decoder.Skip(), msgpack.ErrValueTooLarge)
+
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithMaxStringLen(100))
+	_, err = decoder.ReadRaw()
+	assert.ErrorIs(t, err, msgpack.ErrValueTooLarge)
+}
+
+func TestReadRawBounded(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteString("ab")
+		w.WriteArraySize(1)
+		w.WriteString(strings.Repeat("x", 40))
+		w.WriteInt64(1)
+	})
+	first := len(data) - 1
+
+	decoder := msgpack.NewDecoder(data)
+	_, err := decoder.ReadRawBounded(uint32(first - 1))
+	var tooLarge msgpack.ValueTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, msgpack.ValueTooLargeError{Kind: "raw", Length: uint32(first), Limit: uint32(first - 1), Offset: 0}, tooLarge)
+	assert.EqualError(t, err, "msgpack: raw of 47 bytes exceeds the limit of 46 at offset 0")
+
+	// The decoder is left at the start of the value and has no error.
+	assert.Equal(t, uint32(0), decoder.Offset())
+	require.NoError(t, decoder.Err())
+	raw, err := decoder.ReadRawBounded(uint32(first))
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.Raw(data[:first]), raw)
+	raw, err = decoder.ReadRawCopyBounded(1)
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.Raw{0x01}, raw)
+
+	// Errors reading the value are returned as ReadRaw returns them.
+	decoder = msgpack.NewDecoder(data[:10])
+	_, err = decoder.ReadRawBounded(100)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	_, err = decoder.ReadRawCopyBounded(100)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
@@ -0,0 +1,161 @@
+package msgpack
+
+import (
+	"math"
+	"strconv"
+)
+
+// ReadStringAnyMap reads a map whose keys are strings into a
+// map[string]any, reading each value with ReadAny. A nil value yields a nil
+// map. A key that is not a string is an error naming its offset when `r`
+// is a Decoder.
+func ReadStringAnyMap(r Reader) (map[string]any, error) {
+	isNil, err := readNil(r)
+	if err != nil || isNil {
+		return nil, err
+	}
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any, sizeHint(r, size))
+	for i := uint32(0); i < size; i++ {
+		key, err := readStringKey(r)
+		if err != nil {
+			return nil, err
+		}
+		if m[key], err = r.ReadAny(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ReadMapEntriesAny reads a map, decoding each key with ReadAny and calling
+// `fn` with the key and a Reader limited to the entry's value. `fn` may
+// decode the value, or ignore it to skip it. When `r` is a Decoder, offsets
+// reported by the value reader are positions in the original buffer.
+func ReadMapEntriesAny(r Reader, fn func(key any, valueReader Reader) error) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	d, isDecoder := r.(*Decoder)
+	for i := uint32(0); i < size; i++ {
+		key, err := r.ReadAny()
+		if err != nil {
+			return err
+		}
+		var start uint32
+		if isDecoder {
+			start = d.Offset()
+		}
+		value, err := r.ReadRaw()
+		if err != nil {
+			return err
+		}
+		var valueReader Decoder
+		if isDecoder {
+			valueReader = NewDecoderAt(d.InputBuffer(), start, uint32(len(value)))
+			valueReader.options = d.options
+		} else {
+			valueReader = NewDecoder(value)
+		}
+		if err := fn(key, &valueReader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFilteredMap reads a map whose keys are strings, keeping only the
+// entries for which `keep` returns true. Kept values are read with `read`
+// and the others are skipped without being decoded. The reader is left
+// after the whole map either way. A nil value yields a nil map. Keys, like
+// the strings ReadString returns, alias the input buffer.
+func ReadFilteredMap[V any](r Reader, keep func(key string) bool, read func(Reader) (V, error)) (map[string]V, error) {
+	return ReadMapLimit(r, keep, read, math.MaxUint32)
+}
+
+// ReadMapLimit is ReadFilteredMap that keeps at most `limit` entries. The
+// entries after the limit is reached are skipped without calling `keep`.
+func ReadMapLimit[V any](r Reader, keep func(key string) bool, read func(Reader) (V, error), limit uint32) (map[string]V, error) {
+	isNil, err := readNil(r)
+	if err != nil || isNil {
+		return nil, err
+	}
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]V)
+	for i := uint32(0); i < size; i++ {
+		if uint32(len(m)) >= limit {
+			if err := skipN(r, 2*(size-i)); err != nil {
+				return nil, err
+			}
+			break
+		}
+		key, err := readStringKey(r)
+		if err != nil {
+			return nil, err
+		}
+		if !keep(key) {
+			if err := r.Skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if m[key], err = read(r); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// skipN skips `n` values.
+func skipN(r Reader, n uint32) error {
+	for i := uint32(0); i < n; i++ {
+		if err := r.Skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readStringKey reads a map key that must be a string.
+func readStringKey(r Reader) (string, error) {
+	var offset uint32
+	d, isDecoder := r.(*Decoder)
+	if isDecoder {
+		offset = d.Offset()
+		prefix, err := d.PeekFormat()
+		if err != nil {
+			return "", err
+		}
+		// Read the key in place rather than through ReadRaw.
+		if isStringFormat(prefix) || d.options.stringTable && formatKind(prefix) == "ext" {
+			b, err := d.ReadStringBytes()
+			return bytesToString(b), err
+		}
+	}
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return "", err
+	}
+	c := raw[0]
+	if !isStringFormat(c) {
+		message := "msgpack: map key is not a string, found format 0x" + strconv.FormatUint(uint64(c), 16)
+		if isDecoder {
+			message += " at offset " + strconv.FormatUint(uint64(offset), 10)
+		}
+		return "", ReadError{message}
+	}
+	key := NewDecoder(raw)
+	return key.ReadString()
+}
+
+// isStringFormat reports whether `prefix` is one of the str formats.
+func isStringFormat(prefix byte) bool {
+	return isFixedString(prefix) || prefix == FormatString8 || prefix == FormatString16 || prefix == FormatString32
+}
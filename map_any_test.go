@@ -0,0 +1,241 @@
+package msgpack_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestStringAnyMap(t *testing.T) {
+	value := map[string]any{
+		"name":  "widget",
+		"count": int64(3),
+		"tags":  []any{"a", "b"},
+		"dims":  map[string]any{"w": 1.5, "h": 2.5},
+		"none":  nil,
+	}
+	direct := encodeWith(t, func(w msgpack.Writer) { w.WriteStringAnyMap(value) })
+	viaAny := encodeWith(t, func(w msgpack.Writer) { w.WriteAny(value) })
+	assert.Equal(t, len(viaAny), len(direct))
+
+	decoder := msgpack.NewDecoder(direct)
+	actual, err := msgpack.ReadStringAnyMap(&decoder)
+	require.NoError(t, err)
+	// Nested maps come back from ReadAny with any keys.
+	assert.Equal(t, map[string]any{
+		"name":  "widget",
+		"count": int64(3),
+		"tags":  []any{"a", "b"},
+		"dims":  map[any]any{"w": 1.5, "h": 2.5},
+		"none":  nil,
+	}, actual)
+
+	decoder = msgpack.NewDecoder([]byte{msgpack.FormatNil})
+	actual, err = msgpack.ReadStringAnyMap(&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+}
+
+func TestReadStringAnyMapNonStringKey(t *testing.T) {
+	data := []byte{0x82, 0xa1, 'a', 0x01, 0x02, 0x03}
+	decoder := msgpack.NewDecoder(data)
+	_, err := msgpack.ReadStringAnyMap(&decoder)
+	assert.EqualError(t, err, "msgpack: map key is not a string, found format 0x2 at offset 4")
+}
+
+func TestReadStringAnyMapTruncatedHeader(t *testing.T) {
+	// A map32 header claiming 0xffffffff entries fails without allocating
+	// for them.
+	decoder := msgpack.NewDecoder([]byte{0xdf, 0xff, 0xff, 0xff, 0xff})
+	_, err := msgpack.ReadStringAnyMap(&decoder)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
+
+func TestReadMapEntriesAny(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("id")
+		w.WriteInt64(42)
+		w.WriteInt64(7)
+		w.WriteAny([]any{"skipped"})
+		w.WriteString("name")
+		w.WriteString("gizmo")
+	})
+	decoder := msgpack.NewDecoder(data)
+	var id int64
+	var name string
+	var keys []any
+	err := msgpack.ReadMapEntriesAny(&decoder, func(key any, r msgpack.Reader) error {
+		keys = append(keys, key)
+		var err error
+		switch key {
+		case "id":
+			id, err = r.ReadInt64()
+		case "name":
+			name, err = r.ReadString()
+		}
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"id", int64(7), "name"}, keys)
+	assert.Equal(t, int64(42), id)
+	assert.Equal(t, "gizmo", name)
+	assert.Equal(t, uint32(len(data)), decoder.Offset())
+
+	// The value reader cannot read past its entry.
+	decoder = msgpack.NewDecoder(data)
+	err = msgpack.ReadMapEntriesAny(&decoder, func(key any, r msgpack.Reader) error {
+		r.Skip()
+		_, err := r.ReadAny()
+		return err
+	})
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
+
+func writeHeaders(w msgpack.Writer) {
+	w.WriteArraySize(2)
+	w.WriteMapSize(5)
+	w.WriteString("x-request-id")
+	w.WriteString("abc")
+	w.WriteString("content-type")
+	w.WriteString("text/plain")
+	w.WriteString("x-trace")
+	w.WriteString("t1")
+	w.WriteString("body")
+	w.WriteAny(map[string]any{"nested": []any{int64(1), "two"}})
+	w.WriteString("x-retry")
+	w.WriteString("3")
+	w.WriteString("sibling")
+}
+
+func hasXPrefix(key string) bool {
+	return strings.HasPrefix(key, "x-")
+}
+
+func readStringValue(r msgpack.Reader) (string, error) {
+	return r.ReadString()
+}
+
+func TestReadFilteredMap(t *testing.T) {
+	data := encodeWith(t, writeHeaders)
+	tests := []struct {
+		name     string
+		keep     func(string) bool
+		expected map[string]string
+	}{
+		{"none", func(string) bool { return false }, map[string]string{}},
+		{"some", hasXPrefix, map[string]string{"x-request-id": "abc", "x-trace": "t1", "x-retry": "3"}},
+		{"all but body", func(key string) bool { return key != "body" }, map[string]string{
+			"x-request-id": "abc", "content-type": "text/plain", "x-trace": "t1", "x-retry": "3",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := msgpack.NewDecoder(data)
+			_, err := decoder.ReadArraySize()
+			require.NoError(t, err)
+			m, err := msgpack.ReadFilteredMap(&decoder, tt.keep, readStringValue)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, m)
+
+			// The decoder is left after the map.
+			sibling, err := decoder.ReadString()
+			require.NoError(t, err)
+			assert.Equal(t, "sibling", sibling)
+		})
+	}
+
+	// All keys kept, with values of any type.
+	decoder := msgpack.NewDecoder(data)
+	_, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+	m, err := msgpack.ReadFilteredMap(&decoder, func(string) bool { return true }, msgpack.Reader.ReadAny)
+	require.NoError(t, err)
+	assert.Len(t, m, 5)
+
+	decoder = msgpack.NewDecoder([]byte{msgpack.FormatNil})
+	m, err = msgpack.ReadFilteredMap(&decoder, hasXPrefix, msgpack.Reader.ReadAny)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	decoder = msgpack.NewDecoder([]byte{0x81, 0x01, 0x02})
+	_, err = msgpack.ReadFilteredMap(&decoder, hasXPrefix, msgpack.Reader.ReadAny)
+	assert.EqualError(t, err, "msgpack: map key is not a string, found format 0x1 at offset 1")
+}
+
+func TestReadMapLimit(t *testing.T) {
+	data := encodeWith(t, writeHeaders)
+	for limit, expected := range map[uint32]map[string]string{
+		0: {},
+		1: {"x-request-id": "abc"},
+		2: {"x-request-id": "abc", "x-trace": "t1"},
+		9: {"x-request-id": "abc", "x-trace": "t1", "x-retry": "3"},
+	} {
+		var seen []string
+		keep := func(key string) bool {
+			seen = append(seen, key)
+			return hasXPrefix(key)
+		}
+		decoder := msgpack.NewDecoder(data)
+		_, err := decoder.ReadArraySize()
+		require.NoError(t, err)
+		m, err := msgpack.ReadMapLimit(&decoder, keep, readStringValue, limit)
+		require.NoError(t, err)
+		assert.Equal(t, expected, m, "limit %d", limit)
+		if limit == 1 {
+			assert.Equal(t, []string{"x-request-id"}, seen)
+		}
+
+		sibling, err := decoder.ReadString()
+		require.NoError(t, err)
+		assert.Equal(t, "sibling", sibling)
+	}
+
+	// The same through a ReaderAdapter.
+	decoder := msgpack.NewDecoder(data)
+	adapter := msgpack.ReaderAdapter{ReaderCore: &decoder}
+	_, err := adapter.ReadArraySize()
+	require.NoError(t, err)
+	m, err := msgpack.ReadMapLimit(adapter, hasXPrefix, readStringValue, 2)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"x-request-id": "abc", "x-trace": "t1"}, m)
+	sibling, err := adapter.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "sibling", sibling)
+}
+
+func TestReadFilteredMapSkipsWithoutAllocating(t *testing.T) {
+	decoder := msgpack.NewDecoder(nil)
+	skipUnlessAliased(t, &decoder)
+	allocs := func(data []byte) float64 {
+		return testing.AllocsPerRun(100, func() {
+			decoder := msgpack.NewDecoder(data)
+			_, _ = decoder.ReadArraySize()
+			_, _ = msgpack.ReadFilteredMap(&decoder, func(string) bool { return false }, msgpack.Reader.ReadAny)
+		})
+	}
+	// Skipping every entry allocates no more than reading an empty map.
+	empty := []byte{0x91, 0x80}
+	assert.Equal(t, allocs(empty), allocs(encodeWith(t, writeHeaders)))
+}
+
+func BenchmarkReadFilteredMap(b *testing.B) {
+	var sizer msgpack.Sizer
+	writeHeaders(&sizer)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	writeHeaders(&encoder)
+	data := encoder.Bytes()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		decoder := msgpack.NewDecoder(data)
+		_, _ = decoder.ReadArraySize()
+		if _, err := msgpack.ReadFilteredMap(&decoder, hasXPrefix, readStringValue); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
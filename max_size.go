@@ -0,0 +1,111 @@
+package msgpack
+
+// The largest number of bytes the Encoder writes for a value of each type,
+// for laying out buffers without sizing the values. Strings are bounded
+// without a string table.
+const (
+	// MaxIntSize bounds every signed and unsigned integer.
+	MaxIntSize = 9
+	// MaxFloat32Size bounds WriteFloat32.
+	MaxFloat32Size = 5
+	// MaxFloat64Size bounds WriteFloat64.
+	MaxFloat64Size = 9
+	// MaxTimeSize bounds WriteTime: an ext8 header, the type and the 12
+	// byte timestamp form.
+	MaxTimeSize = 15
+	// MaxHeaderSize bounds the header of a string, bin value, array or
+	// map.
+	MaxHeaderSize = 5
+	// MaxArrayHeaderSize bounds WriteArraySize.
+	MaxArrayHeaderSize = MaxHeaderSize
+	// MaxMapHeaderSize bounds WriteMapSize.
+	MaxMapHeaderSize = MaxHeaderSize
+)
+
+// MaxStringSize returns the largest size of a string of `n` bytes.
+func MaxStringSize(n uint32) uint32 {
+	return MaxHeaderSize + n
+}
+
+// MaxBinSize returns the largest size of a bin value of `n` bytes.
+func MaxBinSize(n uint32) uint32 {
+	return MaxHeaderSize + n
+}
+
+// WorstCase adds up the largest sizes of values given only their counts
+// and lengths, for buffers whose layout is fixed before the values are
+// known:
+//
+//	size := msgpack.WorstCase{}.Maps(2).Strings(3, 64).Ints(10).Total()
+//
+// It is a static form of UpperBoundSizer. Container headers are counted
+// with Arrays and Maps, and their elements with the other methods. The
+// total is a uint64 so that large counts cannot overflow it.
+type WorstCase struct {
+	total uint64
+}
+
+// Total returns the size counted so far.
+func (wc WorstCase) Total() uint64 {
+	return wc.total
+}
+
+// Nils counts `n` nil values.
+func (wc WorstCase) Nils(n uint32) WorstCase {
+	return wc.add(n, 1)
+}
+
+// Bools counts `n` booleans.
+func (wc WorstCase) Bools(n uint32) WorstCase {
+	return wc.add(n, 1)
+}
+
+// Ints counts `n` integers of any size, signed or unsigned.
+func (wc WorstCase) Ints(n uint32) WorstCase {
+	return wc.add(n, MaxIntSize)
+}
+
+// Float32s counts `n` values written with WriteFloat32.
+func (wc WorstCase) Float32s(n uint32) WorstCase {
+	return wc.add(n, MaxFloat32Size)
+}
+
+// Floats counts `n` values written with WriteFloat64.
+func (wc WorstCase) Floats(n uint32) WorstCase {
+	return wc.add(n, MaxFloat64Size)
+}
+
+// Times counts `n` times.
+func (wc WorstCase) Times(n uint32) WorstCase {
+	return wc.add(n, MaxTimeSize)
+}
+
+// Strings counts `n` strings of at most `maxLen` bytes each.
+func (wc WorstCase) Strings(n, maxLen uint32) WorstCase {
+	return wc.add(n, MaxHeaderSize+uint64(maxLen))
+}
+
+// Bins counts `n` bin values of at most `maxLen` bytes each.
+func (wc WorstCase) Bins(n, maxLen uint32) WorstCase {
+	return wc.add(n, MaxHeaderSize+uint64(maxLen))
+}
+
+// Arrays counts the headers of `n` arrays.
+func (wc WorstCase) Arrays(n uint32) WorstCase {
+	return wc.add(n, MaxArrayHeaderSize)
+}
+
+// Maps counts the headers of `n` maps.
+func (wc WorstCase) Maps(n uint32) WorstCase {
+	return wc.add(n, MaxMapHeaderSize)
+}
+
+// Bytes counts `n` bytes written as they are, such as with WriteRaw.
+func (wc WorstCase) Bytes(n uint32) WorstCase {
+	return wc.add(n, 1)
+}
+
+func (wc WorstCase) add(n uint32, size uint64) WorstCase {
+	wc.total += uint64(n) * size
+	return wc
+}
@@ -0,0 +1,75 @@
+package msgpack_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func encodedLen(t *testing.T, fn func(w msgpack.Writer)) uint32 {
+	return uint32(len(encodeWith(t, fn)))
+}
+
+// The constants are the sizes of the values that encode largest.
+func TestMaxSizeConstants(t *testing.T) {
+	assert.EqualValues(t, msgpack.MaxIntSize, encodedLen(t, func(w msgpack.Writer) { w.WriteInt64(math.MinInt64) }))
+	assert.EqualValues(t, msgpack.MaxIntSize, encodedLen(t, func(w msgpack.Writer) { w.WriteUint64(math.MaxUint64) }))
+	assert.EqualValues(t, msgpack.MaxFloat32Size, encodedLen(t, func(w msgpack.Writer) { w.WriteFloat32(math.MaxFloat32) }))
+	assert.EqualValues(t, msgpack.MaxFloat64Size, encodedLen(t, func(w msgpack.Writer) { w.WriteFloat64(math.MaxFloat64) }))
+	farFuture := time.Date(3000, 1, 1, 0, 0, 0, 999999999, time.UTC)
+	assert.EqualValues(t, msgpack.MaxTimeSize, encodedLen(t, func(w msgpack.Writer) { w.WriteTime(farFuture) }))
+	assert.EqualValues(t, msgpack.MaxArrayHeaderSize, encodedLen(t, func(w msgpack.Writer) { w.WriteArraySize(math.MaxUint32) }))
+	assert.EqualValues(t, msgpack.MaxMapHeaderSize, encodedLen(t, func(w msgpack.Writer) { w.WriteMapSize(math.MaxUint32) }))
+	assert.LessOrEqual(t, msgpack.MaxArrayHeaderSize, msgpack.MaxHeaderSize)
+	assert.LessOrEqual(t, msgpack.MaxMapHeaderSize, msgpack.MaxHeaderSize)
+}
+
+func TestMaxSizeBounds(t *testing.T) {
+	for _, n := range []uint32{0, 1, 31, 32, 255, 256, 65535, 65536} {
+		s := strings.Repeat("x", int(n))
+		assert.LessOrEqual(t, encodedLen(t, func(w msgpack.Writer) { w.WriteString(s) }), msgpack.MaxStringSize(n), n)
+		b := make([]byte, n)
+		assert.LessOrEqual(t, encodedLen(t, func(w msgpack.Writer) { w.WriteByteArray(b) }), msgpack.MaxBinSize(n), n)
+	}
+	// A string or bin value of 64 KiB or more takes the largest header.
+	assert.Equal(t, msgpack.MaxStringSize(65536), encodedLen(t, func(w msgpack.Writer) { w.WriteString(strings.Repeat("x", 65536)) }))
+	assert.Equal(t, msgpack.MaxBinSize(65536), encodedLen(t, func(w msgpack.Writer) { w.WriteByteArray(make([]byte, 65536)) }))
+
+	for _, v := range []int64{0, -1, 127, -33, math.MaxInt8, math.MinInt16, math.MaxInt32, math.MinInt64} {
+		assert.LessOrEqual(t, encodedLen(t, func(w msgpack.Writer) { w.WriteInt64(v) }), uint32(msgpack.MaxIntSize), v)
+	}
+	for _, tm := range []time.Time{time.Unix(0, 0), time.Unix(1<<32, 0), time.Unix(1<<34, 1), time.Unix(-1, 0)} {
+		assert.LessOrEqual(t, encodedLen(t, func(w msgpack.Writer) { w.WriteTime(tm) }), uint32(msgpack.MaxTimeSize), tm)
+	}
+}
+
+func TestWorstCase(t *testing.T) {
+	long := strings.Repeat("x", 65536)
+	farFuture := time.Date(3000, 1, 1, 0, 0, 0, 1, time.UTC)
+	size := encodedLen(t, func(w msgpack.Writer) {
+		w.WriteMapSize(70000)
+		w.WriteString(long)
+		w.WriteArraySize(70000)
+		w.WriteInt64(math.MinInt64)
+		w.WriteUint64(math.MaxUint64)
+		w.WriteFloat64(1.5)
+		w.WriteFloat32(1.5)
+		w.WriteTime(farFuture)
+		w.WriteByteArray(make([]byte, 65536))
+		w.WriteNil()
+		w.WriteBool(true)
+		w.WriteRaw(msgpack.Raw{0xc0, 0xc0})
+	})
+	wc := msgpack.WorstCase{}.
+		Maps(1).Strings(1, 65536).Arrays(1).Ints(2).Floats(1).Float32s(1).Times(1).
+		Bins(1, 65536).Nils(1).Bools(1).Bytes(2)
+	assert.Equal(t, uint64(size), wc.Total())
+
+	assert.Equal(t, uint64(3*(5+64)+10*9+2*5), msgpack.WorstCase{}.Strings(3, 64).Ints(10).Maps(2).Total())
+	assert.Equal(t, uint64(math.MaxUint32)*9, msgpack.WorstCase{}.Ints(math.MaxUint32).Total())
+}
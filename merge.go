@@ -0,0 +1,186 @@
+package msgpack
+
+import "strconv"
+
+type mergeOptions struct {
+	deep bool
+}
+
+// MergeOption configures MergeRaw.
+type MergeOption func(*mergeOptions)
+
+// WithDeepMerge makes MergeRaw merge nested maps recursively when both the
+// base and overlay values for a key are maps. Without it the overlay value
+// replaces the base value wholesale.
+func WithDeepMerge() MergeOption {
+	return func(o *mergeOptions) {
+		o.deep = true
+	}
+}
+
+// MergeRaw merges two encoded maps without decoding their values. The result
+// contains the base entries whose keys do not appear in overlay, in their
+// original order, followed by every overlay entry in its original order.
+// Entries are copied byte for byte, so formats are preserved.
+//
+// Keys are compared by value: strings match regardless of their string
+// format and integers match regardless of their integer format. Other keys
+// match only when their encodings are identical. If the same key appears
+// more than once within one input, the last occurrence is kept and the
+// earlier ones are dropped, which is what ReadAny does when it decodes such
+// a map.
+//
+// Both inputs must be a single map; nil is not accepted.
+func MergeRaw(base, overlay Raw, opts ...MergeOption) (Raw, error) {
+	var o mergeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return mergeRaw(base, overlay, &o)
+}
+
+type rawEntry struct {
+	id    string
+	key   Raw
+	value Raw
+}
+
+func mergeRaw(base, overlay Raw, o *mergeOptions) (Raw, error) {
+	baseEntries, err := readRawMap(base, "base")
+	if err != nil {
+		return nil, err
+	}
+	overlayEntries, err := readRawMap(overlay, "overlay")
+	if err != nil {
+		return nil, err
+	}
+
+	baseIndex := make(map[string]int, len(baseEntries))
+	for i, e := range baseEntries {
+		baseIndex[e.id] = i
+	}
+	overlayIndex := make(map[string]struct{}, len(overlayEntries))
+	for _, e := range overlayEntries {
+		overlayIndex[e.id] = struct{}{}
+	}
+
+	entries := make([]rawEntry, 0, len(baseEntries)+len(overlayEntries))
+	for _, e := range baseEntries {
+		if _, ok := overlayIndex[e.id]; !ok {
+			entries = append(entries, e)
+		}
+	}
+	for _, e := range overlayEntries {
+		if o.deep {
+			if i, ok := baseIndex[e.id]; ok && isRawMap(baseEntries[i].value) && isRawMap(e.value) {
+				merged, err := mergeRaw(baseEntries[i].value, e.value, o)
+				if err != nil {
+					return nil, err
+				}
+				e.value = merged
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	var sizer Sizer
+	writeRawEntries(&sizer, entries)
+	buffer := make([]byte, sizer.Len())
+	encoder := NewEncoder(buffer)
+	writeRawEntries(&encoder, entries)
+	if err := encoder.Err(); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+func writeRawEntries(w Writer, entries []rawEntry) {
+	w.WriteMapSize(uint32(len(entries)))
+	for _, e := range entries {
+		w.WriteRaw(e.key)
+		w.WriteRaw(e.value)
+	}
+}
+
+// readRawMap splits an encoded map into its entries, keeping only the last
+// occurrence of duplicate keys.
+func readRawMap(data Raw, name string) ([]rawEntry, error) {
+	if !isRawMap(data) {
+		message := "msgpack: merge " + name + ": expected map at offset 0"
+		if len(data) > 0 {
+			message += ", found format 0x" + strconv.FormatUint(uint64(data[0]), 16)
+		}
+		return nil, ReadError{message}
+	}
+	decoder := NewDecoder(data)
+	size, err := decoder.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	hint := sizeHint(&decoder, size)
+	entries := make([]rawEntry, 0, hint)
+	last := make(map[string]int, hint)
+	for i := uint32(0); i < size; i++ {
+		key, err := decoder.ReadRaw()
+		if err != nil {
+			return nil, err
+		}
+		value, err := decoder.ReadRaw()
+		if err != nil {
+			return nil, err
+		}
+		id, err := rawKeyID(key)
+		if err != nil {
+			return nil, err
+		}
+		last[id] = len(entries)
+		entries = append(entries, rawEntry{id: id, key: key, value: value})
+	}
+	if decoder.Remaining() != 0 {
+		return nil, ReadError{"msgpack: merge " + name + ": trailing data at offset " +
+			strconv.FormatUint(uint64(decoder.Offset()), 10)}
+	}
+
+	if len(last) == len(entries) {
+		return entries, nil
+	}
+	deduped := entries[:0]
+	for i, e := range entries {
+		if last[e.id] == i {
+			deduped = append(deduped, e)
+		}
+	}
+	return deduped, nil
+}
+
+// rawKeyID returns a string identifying the value of an encoded map key.
+func rawKeyID(key Raw) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	c := key[0]
+	decoder := NewDecoder(key)
+	switch {
+	case isFixedString(c) || c == FormatString8 || c == FormatString16 || c == FormatString32:
+		s, err := decoder.ReadString()
+		return "s" + s, err
+	case isFixedInt(c) || c == FormatUint8 || c == FormatUint16 || c == FormatUint32 || c == FormatUint64:
+		v, err := decoder.ReadUint64()
+		return "u" + strconv.FormatUint(v, 10), err
+	case isNegativeFixedInt(c) || c == FormatInt8 || c == FormatInt16 || c == FormatInt32 || c == FormatInt64:
+		v, err := decoder.ReadInt64()
+		if v >= 0 {
+			return "u" + strconv.FormatInt(v, 10), err
+		}
+		return "i" + strconv.FormatInt(v, 10), err
+	}
+	return "r" + string(key), nil
+}
+
+func isRawMap(data Raw) bool {
+	if len(data) == 0 {
+		return false
+	}
+	c := data[0]
+	return isFixedMap(c) || c == FormatMap16 || c == FormatMap32
+}
@@ -0,0 +1,121 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func decodeAny(t *testing.T, data []byte) any {
+	t.Helper()
+	decoder := msgpack.NewDecoder(data)
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	return v
+}
+
+func TestMergeRawShallow(t *testing.T) {
+	base := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("host")
+		w.WriteString("localhost")
+		w.WriteString("port")
+		w.WriteUint16(8080) // uint16 format is kept even though it fits in less
+		w.WriteString("tls")
+		w.WriteAny(map[string]any{"enabled": false, "cert": "a.pem"})
+	})
+	overlay := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("tls")
+		w.WriteAny(map[string]any{"enabled": true})
+		w.WriteString("debug")
+		w.WriteBool(true)
+	})
+
+	merged, err := msgpack.MergeRaw(base, overlay)
+	require.NoError(t, err)
+	assert.Equal(t, map[any]any{
+		"host":  "localhost",
+		"port":  uint16(8080),
+		"tls":   map[any]any{"enabled": true},
+		"debug": true,
+	}, decodeAny(t, merged))
+
+	// Untouched entries are copied verbatim, in order, after the new header.
+	expectedPrefix := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(4)
+		w.WriteString("host")
+		w.WriteString("localhost")
+		w.WriteString("port")
+		w.WriteUint16(8080)
+	})
+	assert.True(t, bytes.HasPrefix(merged, expectedPrefix))
+}
+
+func TestMergeRawDeep(t *testing.T) {
+	base := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("name")
+		w.WriteString("svc")
+		w.WriteString("tls")
+		w.WriteMapSize(2)
+		w.WriteString("enabled")
+		w.WriteBool(false)
+		w.WriteString("cert")
+		w.WriteString("a.pem")
+	})
+	overlay := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("tls")
+		w.WriteMapSize(1)
+		w.WriteString("enabled")
+		w.WriteBool(true)
+		w.WriteString("name")
+		w.WriteNil()
+	})
+
+	merged, err := msgpack.MergeRaw(base, overlay, msgpack.WithDeepMerge())
+	require.NoError(t, err)
+	assert.Equal(t, map[any]any{
+		"name": nil,
+		"tls":  map[any]any{"enabled": true, "cert": "a.pem"},
+	}, decodeAny(t, merged))
+}
+
+func TestMergeRawKeyFormats(t *testing.T) {
+	// Keys match by value across string and integer formats.
+	base := []byte{0x82, 0xa1, 'a', 0x01, 0x05, 0x02}
+	overlay := []byte{0x82, 0xd9, 0x01, 'a', 0x0a, 0xcc, 0x05, 0x14}
+	merged, err := msgpack.MergeRaw(base, overlay)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x82, 0xd9, 0x01, 'a', 0x0a, 0xcc, 0x05, 0x14}, []byte(merged))
+}
+
+func TestMergeRawDuplicateKeys(t *testing.T) {
+	// The last occurrence of a duplicate key wins within one input.
+	base := []byte{0x83, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02, 0xa1, 'a', 0x03}
+	overlay := []byte{0x80}
+	merged, err := msgpack.MergeRaw(base, overlay)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x82, 0xa1, 'b', 0x02, 0xa1, 'a', 0x03}, []byte(merged))
+}
+
+func TestMergeRawErrors(t *testing.T) {
+	_, err := msgpack.MergeRaw(msgpack.Raw{0x93, 0x01, 0x02, 0x03}, msgpack.Raw{0x80})
+	assert.EqualError(t, err, "msgpack: merge base: expected map at offset 0, found format 0x93")
+	_, err = msgpack.MergeRaw(msgpack.Raw{0x80}, msgpack.Raw{0xc0})
+	assert.EqualError(t, err, "msgpack: merge overlay: expected map at offset 0, found format 0xc0")
+	_, err = msgpack.MergeRaw(msgpack.Raw{0x80}, nil)
+	assert.EqualError(t, err, "msgpack: merge overlay: expected map at offset 0")
+	_, err = msgpack.MergeRaw(msgpack.Raw{0x80, 0x01}, msgpack.Raw{0x80})
+	assert.EqualError(t, err, "msgpack: merge base: trailing data at offset 1")
+	_, err = msgpack.MergeRaw(msgpack.Raw{0x81, 0x01}, msgpack.Raw{0x80})
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	// A truncated map32 header fails without allocating for its size.
+	_, err = msgpack.MergeRaw(msgpack.Raw{0x80}, msgpack.Raw{0xdf, 0xff, 0xff, 0xff, 0xff})
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
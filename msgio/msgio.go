@@ -0,0 +1,79 @@
+//go:build !tinygo
+// +build !tinygo
+
+// Package msgio bridges MessagePack values and io.Reader/io.Writer streams
+// for host builds.
+package msgio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// ErrTooLarge is returned by DecodeFrom for input longer than its maximum.
+var ErrTooLarge = errors.New("msgio: message too large")
+
+var buffers = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// EncodeTo encodes `value` and writes it to `w` with a single Write call,
+// returning the number of bytes written. The value is sized first and
+// encoded into a pooled buffer.
+func EncodeTo(w io.Writer, value msgpack.Encodable) (int, error) {
+	var sizer msgpack.Sizer
+	if err := value.Encode(&sizer); err != nil {
+		return 0, err
+	}
+	if err := sizer.Err(); err != nil {
+		return 0, err
+	}
+
+	bufp := buffers.Get().(*[]byte)
+	defer buffers.Put(bufp)
+	if uint32(cap(*bufp)) < sizer.Len() {
+		*bufp = make([]byte, sizer.Len())
+	}
+	encoder := msgpack.NewEncoder((*bufp)[:sizer.Len()])
+	if err := value.Encode(&encoder); err != nil {
+		return 0, err
+	}
+	if err := encoder.Err(); err != nil {
+		return 0, err
+	}
+	return w.Write(encoder.Bytes())
+}
+
+// DecodeFrom reads `r` to EOF and decodes the bytes into `target`. Reading
+// stops with ErrTooLarge once more than `maxSize` bytes arrive, so a peer
+// cannot make it buffer unbounded input. The buffer is not reused, so
+// strings and byte slices in `target` remain valid.
+func DecodeFrom(r io.Reader, target msgpack.Decodable, maxSize uint32) error {
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return err
+	}
+	if n > int64(maxSize) {
+		return &sizeError{maxSize}
+	}
+	decoder := msgpack.NewDecoder(buf.Bytes())
+	return target.Decode(&decoder)
+}
+
+type sizeError struct {
+	maxSize uint32
+}
+
+func (e *sizeError) Error() string {
+	return ErrTooLarge.Error() + ": more than " + strconv.FormatUint(uint64(e.maxSize), 10) + " bytes"
+}
+
+func (e *sizeError) Unwrap() error {
+	return ErrTooLarge
+}
@@ -0,0 +1,72 @@
+//go:build !tinygo
+// +build !tinygo
+
+package msgio_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/msgio"
+)
+
+type event struct {
+	Name  string
+	Count int64
+}
+
+func (e *event) Encode(w msgpack.Writer) error {
+	w.WriteArraySize(2)
+	w.WriteString(e.Name)
+	w.WriteInt64(e.Count)
+	return nil
+}
+
+func (e *event) Decode(r msgpack.Reader) error {
+	if _, err := r.ReadArraySize(); err != nil {
+		return err
+	}
+	var err error
+	if e.Name, err = r.ReadString(); err != nil {
+		return err
+	}
+	e.Count, err = r.ReadInt64()
+	return err
+}
+
+func TestPipeRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	sent := event{Name: "deploy", Count: 3}
+	written := make(chan int, 1)
+	go func() {
+		n, err := msgio.EncodeTo(client, &sent)
+		assert.NoError(t, err)
+		written <- n
+		client.Close()
+	}()
+
+	var received event
+	require.NoError(t, msgio.DecodeFrom(server, &received, 1024))
+	assert.Equal(t, sent, received)
+	assert.Equal(t, 1+7+1, <-written)
+}
+
+func TestDecodeFromMaxSize(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := msgio.EncodeTo(&buf, &event{Name: "a much longer event name", Count: 1})
+	require.NoError(t, err)
+	require.Equal(t, buf.Len(), n)
+
+	var received event
+	err = msgio.DecodeFrom(bytes.NewReader(buf.Bytes()), &received, uint32(n-1))
+	assert.ErrorIs(t, err, msgio.ErrTooLarge)
+	assert.EqualError(t, err, "msgio: message too large: more than 26 bytes")
+
+	require.NoError(t, msgio.DecodeFrom(bytes.NewReader(buf.Bytes()), &received, uint32(n)))
+	assert.Equal(t, "a much longer event name", received.Name)
+}
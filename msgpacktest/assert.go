@@ -0,0 +1,180 @@
+package msgpacktest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// TestingT is the part of testing.TB the assertions use.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertEncodes checks that `value` encodes to one of `wants`, and that a
+// Sizer counts as many bytes as the Encoder writes. Pass several encodings
+// when more than one is acceptable, such as for a map written in Go's map
+// order, or none to only check the Sizer. A mismatch is reported with
+// annotated hex dumps of the encoded and expected bytes. It reports
+// whether all checks passed.
+func AssertEncodes[T any, PT interface {
+	*T
+	msgpack.Codec
+}](t TestingT, value T, wants ...[]byte) bool {
+	t.Helper()
+	_, ok := assertEncodes[T, PT](t, value, wants)
+	return ok
+}
+
+// AssertDecodes checks that `data` decodes to `want`, compared with
+// reflect.DeepEqual, using all of its bytes. It reports whether all checks
+// passed.
+func AssertDecodes[T any, PT interface {
+	*T
+	msgpack.Codec
+}](t TestingT, data []byte, want T) bool {
+	t.Helper()
+	return assertDecodes[T, PT](t, "", data, want, deepEqual[T])
+}
+
+// AssertRoundTrip runs AssertEncodes and AssertDecodes for `value` and
+// each of `wants`, and checks that the bytes `value` encodes to decode
+// back to it, which catches a Decode that does not mirror Encode. Values
+// are compared with reflect.DeepEqual. It reports whether all checks
+// passed.
+func AssertRoundTrip[T any, PT interface {
+	*T
+	msgpack.Codec
+}](t TestingT, value T, wants ...[]byte) bool {
+	t.Helper()
+	return AssertRoundTripFunc[T, PT](t, value, deepEqual[T], wants...)
+}
+
+// AssertRoundTripFunc is AssertRoundTrip comparing values with `equal`.
+func AssertRoundTripFunc[T any, PT interface {
+	*T
+	msgpack.Codec
+}](t TestingT, value T, equal func(a, b T) bool, wants ...[]byte) bool {
+	t.Helper()
+	got, ok := assertEncodes[T, PT](t, value, wants)
+	for i, want := range wants {
+		label := ""
+		if len(wants) > 1 {
+			label = fmt.Sprintf(" %d of %d", i+1, len(wants))
+		}
+		ok = assertDecodes[T, PT](t, "expected encoding"+label, want, value, equal) && ok
+	}
+	if got != nil {
+		ok = assertDecodes[T, PT](t, "its own encoding; Encode and Decode disagree", got, value, equal) && ok
+	}
+	return ok
+}
+
+func deepEqual[T any](a, b T) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// assertEncodes encodes `value` and returns the bytes, or nil if it could
+// not be encoded.
+func assertEncodes[T any, PT interface {
+	*T
+	msgpack.Codec
+}](t TestingT, value T, wants [][]byte) ([]byte, bool) {
+	t.Helper()
+	var sizer msgpack.Sizer
+	if err := PT(&value).Encode(&sizer); err != nil {
+		t.Errorf("msgpacktest: sizing %T: %v", value, err)
+		return nil, false
+	}
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	if err := PT(&value).Encode(&encoder); err != nil {
+		t.Errorf("msgpacktest: encoding %T: %v", value, err)
+		return nil, false
+	}
+	if err := encoder.Err(); err != nil {
+		t.Errorf("msgpacktest: encoding %T: %v", value, err)
+		return nil, false
+	}
+	got := encoder.Bytes()
+	ok := true
+	if encoder.Len() != sizer.Len() {
+		t.Errorf("msgpacktest: the Sizer counted %d bytes for %T but the Encoder wrote %d", sizer.Len(), value, encoder.Len())
+		ok = false
+	}
+	if len(wants) == 0 {
+		return got, ok
+	}
+	for _, want := range wants {
+		if string(got) == string(want) {
+			return got, ok
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "msgpacktest: %T encoded to unexpected bytes\n", value)
+	for i, want := range wants {
+		if len(wants) > 1 {
+			fmt.Fprintf(&b, "against expected encoding %d of %d: ", i+1, len(wants))
+		}
+		fmt.Fprintf(&b, "first difference at offset %d\n", firstDifference(got, want))
+	}
+	b.WriteString("got:\n")
+	b.Write(msgpack.AppendHexDump(nil, got))
+	for i, want := range wants {
+		if len(wants) > 1 {
+			fmt.Fprintf(&b, "want %d of %d:\n", i+1, len(wants))
+		} else {
+			b.WriteString("want:\n")
+		}
+		b.Write(msgpack.AppendHexDump(nil, want))
+	}
+	t.Errorf("%s", strings.TrimSuffix(b.String(), "\n"))
+	return got, false
+}
+
+// assertDecodes decodes `data` and compares the result to `want`. `what`
+// names the bytes in failure messages.
+func assertDecodes[T any, PT interface {
+	*T
+	msgpack.Codec
+}](t TestingT, what string, data []byte, want T, equal func(a, b T) bool) bool {
+	t.Helper()
+	if what != "" {
+		what = " (" + what + ")"
+	}
+	decoder := msgpack.NewDecoder(data)
+	decoded, err := msgpack.Decode[T, PT](&decoder)
+	if err != nil {
+		t.Errorf("msgpacktest: decoding %T%s: %v\n%s", want, what, err, hexDump(data))
+		return false
+	}
+	if decoder.Remaining() != 0 {
+		t.Errorf("msgpacktest: decoding %T%s left %d of %d bytes unread\n%s", want, what,
+			decoder.Remaining(), len(data), hexDump(data))
+		return false
+	}
+	if !equal(decoded, want) {
+		t.Errorf("msgpacktest: decoding %T%s gave\n\t%#v\nwant\n\t%#v", want, what, decoded, want)
+		return false
+	}
+	return true
+}
+
+// hexDump returns the annotated hex dump of `data` without its final
+// newline.
+func hexDump(data []byte) string {
+	return strings.TrimSuffix(string(msgpack.AppendHexDump(nil, data)), "\n")
+}
+
+// firstDifference returns the offset of the first byte at which `a` and
+// `b` differ, or the length of the shorter one.
+func firstDifference(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,178 @@
+package msgpacktest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/msgpacktest"
+)
+
+// recordingT collects failures instead of failing the test.
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+type point struct {
+	X, Y int64
+}
+
+func (p *point) Encode(w msgpack.Writer) error {
+	w.WriteArraySize(2)
+	w.WriteInt64(p.X)
+	w.WriteInt64(p.Y)
+	return w.Err()
+}
+
+func (p *point) Decode(r msgpack.Reader) (err error) {
+	if _, err = r.ReadArraySize(); err != nil {
+		return err
+	}
+	if p.X, err = r.ReadInt64(); err != nil {
+		return err
+	}
+	p.Y, err = r.ReadInt64()
+	return err
+}
+
+// lossyPoint forgets Y when decoding.
+type lossyPoint struct {
+	point
+}
+
+func (p *lossyPoint) Decode(r msgpack.Reader) error {
+	err := p.point.Decode(r)
+	p.Y = 0
+	return err
+}
+
+// missizedPoint writes an extra nil that the Sizer does not count.
+type missizedPoint struct {
+	point
+}
+
+func (p *missizedPoint) Encode(w msgpack.Writer) error {
+	if _, ok := w.(*msgpack.Sizer); ok {
+		return p.point.Encode(w)
+	}
+	w.WriteArraySize(3)
+	w.WriteInt64(p.X)
+	w.WriteInt64(p.Y)
+	w.WriteNil()
+	return w.Err()
+}
+
+type pair struct {
+	M map[string]int64
+}
+
+func (p *pair) Encode(w msgpack.Writer) error {
+	w.WriteMapSize(uint32(len(p.M)))
+	for k, v := range p.M {
+		w.WriteString(k)
+		w.WriteInt64(v)
+	}
+	return w.Err()
+}
+
+func (p *pair) Decode(r msgpack.Reader) error {
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	p.M = make(map[string]int64, size)
+	for i := uint32(0); i < size; i++ {
+		k, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		if p.M[k], err = r.ReadInt64(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestAssertRoundTripPasses(t *testing.T) {
+	msgpacktest.AssertRoundTrip(t, point{X: 1, Y: -1}, []byte{0x92, 0x01, 0xff})
+	msgpacktest.AssertRoundTrip(t, point{X: 1, Y: 300})
+	msgpacktest.AssertRoundTrip(t, pair{M: map[string]int64{"a": 1, "b": 2}},
+		[]byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02},
+		[]byte{0x82, 0xa1, 'b', 0x02, 0xa1, 'a', 0x01})
+	msgpacktest.AssertEncodes(t, point{X: 2}, []byte{0x92, 0x02, 0x00})
+	msgpacktest.AssertDecodes(t, []byte{0x92, 0xd0, 0x02, 0x00}, point{X: 2})
+}
+
+func TestAssertEncodesMismatch(t *testing.T) {
+	var rt recordingT
+	ok := msgpacktest.AssertEncodes(&rt, point{X: 1, Y: 300}, []byte{0x92, 0x01, 0xcc, 0xff})
+	assert.False(t, ok)
+	require.Len(t, rt.errors, 1)
+	assert.Equal(t, `msgpacktest: msgpacktest_test.point encoded to unexpected bytes
+first difference at offset 2
+got:
+0000 92 | array(2)
+0001 01 |   fixint 1
+0002 d1 01 2c |   int16 300
+want:
+0000 92 | array(2)
+0001 01 |   fixint 1
+0002 cc ff |   uint8 255`, rt.errors[0])
+}
+
+func TestAssertEncodesMismatchOneOf(t *testing.T) {
+	var rt recordingT
+	ok := msgpacktest.AssertEncodes(&rt, point{X: 1, Y: 2}, []byte{0x92, 0x01, 0x03}, []byte{0x92, 0x02})
+	assert.False(t, ok)
+	require.Len(t, rt.errors, 1)
+	assert.Contains(t, rt.errors[0], "against expected encoding 1 of 2: first difference at offset 2\n")
+	assert.Contains(t, rt.errors[0], "against expected encoding 2 of 2: first difference at offset 1\n")
+	assert.Contains(t, rt.errors[0], "want 2 of 2:\n0000 92 | array(2)\n0001 02 |   fixint 2")
+}
+
+func TestAssertRoundTripAsymmetric(t *testing.T) {
+	var rt recordingT
+	value := lossyPoint{point{X: 1, Y: 2}}
+	ok := msgpacktest.AssertRoundTrip(&rt, value, []byte{0x92, 0x01, 0x02})
+	assert.False(t, ok)
+	require.Len(t, rt.errors, 2)
+	assert.Equal(t, "msgpacktest: decoding msgpacktest_test.lossyPoint (expected encoding) gave\n"+
+		"\tmsgpacktest_test.lossyPoint{point:msgpacktest_test.point{X:1, Y:0}}\n"+
+		"want\n"+
+		"\tmsgpacktest_test.lossyPoint{point:msgpacktest_test.point{X:1, Y:2}}", rt.errors[0])
+	assert.Contains(t, rt.errors[1], "(its own encoding; Encode and Decode disagree)")
+
+	// A custom equality can accept the loss.
+	rt = recordingT{}
+	sameX := func(a, b lossyPoint) bool { return a.X == b.X }
+	assert.True(t, msgpacktest.AssertRoundTripFunc(&rt, value, sameX, []byte{0x92, 0x01, 0x02}))
+	assert.Empty(t, rt.errors)
+}
+
+func TestAssertEncodesSizerMismatch(t *testing.T) {
+	var rt recordingT
+	ok := msgpacktest.AssertEncodes(&rt, missizedPoint{point{X: 1, Y: 2}})
+	assert.False(t, ok)
+	require.Len(t, rt.errors, 1)
+	assert.Equal(t, "msgpacktest: encoding msgpacktest_test.missizedPoint: range error at offset 3: requested 1 bytes, 0 available",
+		rt.errors[0])
+}
+
+func TestAssertDecodesErrors(t *testing.T) {
+	var rt recordingT
+	assert.False(t, msgpacktest.AssertDecodes(&rt, []byte{0x92, 0x01}, point{X: 1}))
+	assert.False(t, msgpacktest.AssertDecodes(&rt, []byte{0x92, 0x01, 0x02, 0xc0}, point{X: 1, Y: 2}))
+	require.Len(t, rt.errors, 2)
+	assert.Contains(t, rt.errors[0], "msgpacktest: decoding msgpacktest_test.point: ")
+	assert.Equal(t, "msgpacktest: decoding msgpacktest_test.point left 1 of 4 bytes unread\n"+
+		"0000 92 | array(2)\n0001 01 |   fixint 1\n0002 02 |   fixint 2\n0003 c0 | nil", rt.errors[1])
+}
@@ -0,0 +1,371 @@
+// Package msgpacktest helps test code built on msgpack.
+package msgpacktest
+
+import (
+	"math"
+	"math/rand"
+	"time"
+	"unicode/utf8"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Alphabet selects the characters of generated strings.
+type Alphabet int
+
+const (
+	// AlphabetASCII draws from printable ASCII.
+	AlphabetASCII Alphabet = iota
+	// AlphabetUnicode draws from valid UTF-8 across all planes.
+	AlphabetUnicode
+	// AlphabetBinary draws arbitrary bytes, so strings may not be valid
+	// UTF-8.
+	AlphabetBinary
+)
+
+type genOptions struct {
+	maxDepth        int
+	maxContainerLen int
+	alphabet        Alphabet
+	nilProbability  float64
+	times           bool
+	ext             bool
+}
+
+// GenOption configures a Generator.
+type GenOption func(*genOptions)
+
+// WithMaxDepth limits how deeply arrays and maps nest. A depth of 0
+// generates scalars only. The default is 3.
+func WithMaxDepth(depth int) GenOption {
+	return func(o *genOptions) {
+		o.maxDepth = depth
+	}
+}
+
+// WithMaxContainerLen limits the number of elements of arrays and maps and
+// the length of strings and byte slices. The default is 8.
+func WithMaxContainerLen(n int) GenOption {
+	return func(o *genOptions) {
+		o.maxContainerLen = n
+	}
+}
+
+// WithAlphabet selects the characters of generated strings. The default is
+// AlphabetASCII.
+func WithAlphabet(a Alphabet) GenOption {
+	return func(o *genOptions) {
+		o.alphabet = a
+	}
+}
+
+// WithNilProbability sets the chance, from 0 to 1, that a value is nil.
+// The default is 0.1.
+func WithNilProbability(p float64) GenOption {
+	return func(o *genOptions) {
+		o.nilProbability = p
+	}
+}
+
+// WithTimes includes time.Time values, written as timestamp extensions.
+func WithTimes() GenOption {
+	return func(o *genOptions) {
+		o.times = true
+	}
+}
+
+// WithExt includes extension values other than timestamps, generated as
+// msgpack.Raw.
+func WithExt() GenOption {
+	return func(o *genOptions) {
+		o.ext = true
+	}
+}
+
+// Generator produces pseudo-random values of the types WriteAny supports,
+// for property tests such as encoding a value, decoding it and comparing.
+// The values depend only on the seed and options, so a failure can be
+// reproduced from the seed. A Generator is not safe for concurrent use.
+//
+// Values include every integer type at the boundaries of each MessagePack
+// format, float32 and float64, strings, byte slices, []any, []string,
+// []int64, map[string]any and map[any]any with integer keys. Times and
+// extension values are left out unless enabled, as ReadAny does not read
+// them.
+type Generator struct {
+	rng  *rand.Rand
+	opts genOptions
+}
+
+// NewGenerator creates a generator for `seed`.
+func NewGenerator(seed int64, opts ...GenOption) *Generator {
+	o := genOptions{maxDepth: 3, maxContainerLen: 8, nilProbability: 0.1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Generator{rng: rand.New(rand.NewSource(seed)), opts: o}
+}
+
+// Next returns the next value.
+func (g *Generator) Next() any {
+	return toAny(g.value(0))
+}
+
+// NextRaw returns the encoding of the next value. Unlike encoding the
+// value from Next, whose maps are written in Go's random iteration order,
+// the bytes are the same for every run with the same seed.
+func (g *Generator) NextRaw() []byte {
+	v := g.value(0)
+	var sizer msgpack.Sizer
+	writeValue(&sizer, v)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	writeValue(&encoder, v)
+	return encoder.Bytes()
+}
+
+// orderedMap keeps map entries in the order they were generated, so they
+// can be encoded deterministically.
+type orderedMap struct {
+	keys, values []any
+	stringKeys   bool
+}
+
+const (
+	kindNil = iota
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindString
+	kindBytes
+	kindTime
+	kindExt
+	kindArray
+	kindTypedSlice
+	kindMap
+	kindCount
+)
+
+func (g *Generator) value(depth int) any {
+	if g.rng.Float64() < g.opts.nilProbability {
+		return nil
+	}
+	// Containers come after the scalar kinds.
+	kinds := kindArray
+	if depth < g.opts.maxDepth {
+		kinds = kindCount
+	}
+	for {
+		switch g.rng.Intn(kinds) {
+		case kindNil:
+			// nil is only produced with nilProbability.
+		case kindBool:
+			return g.rng.Intn(2) == 0
+		case kindInt:
+			return g.signed()
+		case kindUint:
+			return g.unsigned()
+		case kindFloat:
+			if g.rng.Intn(2) == 0 {
+				return float32(g.rng.NormFloat64() * 1e3)
+			}
+			return g.rng.NormFloat64() * math.Pow(10, float64(g.rng.Intn(40)-20))
+		case kindString:
+			return g.string()
+		case kindBytes:
+			b := make([]byte, g.rng.Intn(g.opts.maxContainerLen+1))
+			g.rng.Read(b)
+			return b
+		case kindTime:
+			if g.opts.times {
+				return time.Unix(g.rng.Int63n(1<<34)-1<<33, g.rng.Int63n(1e9)).UTC()
+			}
+		case kindExt:
+			if g.opts.ext {
+				return g.ext()
+			}
+		case kindArray:
+			a := make([]any, g.rng.Intn(g.opts.maxContainerLen+1))
+			for i := range a {
+				a[i] = g.value(depth + 1)
+			}
+			return a
+		case kindTypedSlice:
+			n := g.rng.Intn(g.opts.maxContainerLen + 1)
+			if g.rng.Intn(2) == 0 {
+				s := make([]string, n)
+				for i := range s {
+					s[i] = g.string()
+				}
+				return s
+			}
+			s := make([]int64, n)
+			for i := range s {
+				s[i] = g.rng.Int63() - g.rng.Int63()
+			}
+			return s
+		case kindMap:
+			return g.orderedMap(depth)
+		}
+	}
+}
+
+func (g *Generator) orderedMap(depth int) *orderedMap {
+	m := &orderedMap{stringKeys: g.rng.Intn(3) != 0}
+	n := g.rng.Intn(g.opts.maxContainerLen + 1)
+	seen := map[any]bool{}
+	for i := 0; i < n; i++ {
+		var key any
+		if m.stringKeys {
+			key = g.string()
+		} else {
+			key = g.rng.Int63n(1000) - 500
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		m.keys = append(m.keys, key)
+		m.values = append(m.values, g.value(depth+1))
+	}
+	return m
+}
+
+// intBoundaries are the edges of the MessagePack integer formats.
+var intBoundaries = []int64{
+	0, 1, -1, 127, 128, -32, -33, math.MaxInt8, math.MinInt8, math.MaxInt8 + 1, math.MinInt8 - 1,
+	math.MaxUint8, math.MaxUint8 + 1, math.MaxInt16, math.MinInt16, math.MaxUint16, math.MaxUint16 + 1,
+	math.MaxInt32, math.MinInt32, math.MaxUint32, math.MaxUint32 + 1, math.MaxInt64, math.MinInt64,
+}
+
+func (g *Generator) signed() any {
+	var v int64
+	if g.rng.Intn(2) == 0 {
+		v = intBoundaries[g.rng.Intn(len(intBoundaries))]
+	} else {
+		v = (g.rng.Int63() - g.rng.Int63()) >> uint(g.rng.Intn(63))
+	}
+	switch {
+	case v >= math.MinInt8 && v <= math.MaxInt8 && g.rng.Intn(2) == 0:
+		return int8(v)
+	case v >= math.MinInt16 && v <= math.MaxInt16 && g.rng.Intn(2) == 0:
+		return int16(v)
+	case v >= math.MinInt32 && v <= math.MaxInt32 && g.rng.Intn(2) == 0:
+		return int32(v)
+	case g.rng.Intn(4) == 0:
+		return int(v)
+	}
+	return v
+}
+
+func (g *Generator) unsigned() any {
+	var v uint64
+	if g.rng.Intn(2) == 0 {
+		b := intBoundaries[g.rng.Intn(len(intBoundaries))]
+		if b < 0 {
+			b = -(b + 1)
+		}
+		v = uint64(b)
+		if g.rng.Intn(4) == 0 {
+			v = math.MaxUint64 - v
+		}
+	} else {
+		v = g.rng.Uint64() >> uint(g.rng.Intn(64))
+	}
+	switch {
+	case v <= math.MaxUint8 && g.rng.Intn(2) == 0:
+		return uint8(v)
+	case v <= math.MaxUint16 && g.rng.Intn(2) == 0:
+		return uint16(v)
+	case v <= math.MaxUint32 && g.rng.Intn(2) == 0:
+		return uint32(v)
+	case g.rng.Intn(4) == 0:
+		return uint(v)
+	}
+	return v
+}
+
+func (g *Generator) string() string {
+	n := g.rng.Intn(g.opts.maxContainerLen + 1)
+	b := make([]byte, 0, n)
+	for i := 0; i < n; i++ {
+		switch g.opts.alphabet {
+		case AlphabetUnicode:
+			r := rune(g.rng.Intn(utf8.MaxRune + 1))
+			if !utf8.ValidRune(r) {
+				r = utf8.RuneError
+			}
+			b = utf8.AppendRune(b, r)
+		case AlphabetBinary:
+			b = append(b, byte(g.rng.Intn(256)))
+		default:
+			b = append(b, byte(' '+g.rng.Intn('~'-' '+1)))
+		}
+	}
+	return string(b)
+}
+
+// ext returns an extension value of a non-negative type, so it is never
+// mistaken for a timestamp.
+func (g *Generator) ext() msgpack.Raw {
+	sizes := []int{1, 2, 4, 8, 16}
+	formats := []byte{msgpack.FormatFixExt1, msgpack.FormatFixExt2, msgpack.FormatFixExt4,
+		msgpack.FormatFixExt8, msgpack.FormatFixExt16}
+	var raw msgpack.Raw
+	var n int
+	if i := g.rng.Intn(len(sizes) + 1); i < len(sizes) {
+		n = sizes[i]
+		raw = append(raw, formats[i])
+	} else {
+		n = g.rng.Intn(g.opts.maxContainerLen + 1)
+		raw = append(raw, msgpack.FormatExt8, byte(n))
+	}
+	raw = append(raw, byte(g.rng.Intn(128)))
+	payload := make([]byte, n)
+	g.rng.Read(payload)
+	return append(raw, payload...)
+}
+
+// toAny replaces the ordered maps in `v` by Go maps.
+func toAny(v any) any {
+	switch v := v.(type) {
+	case []any:
+		for i := range v {
+			v[i] = toAny(v[i])
+		}
+	case *orderedMap:
+		if v.stringKeys {
+			m := make(map[string]any, len(v.keys))
+			for i, k := range v.keys {
+				m[k.(string)] = toAny(v.values[i])
+			}
+			return m
+		}
+		m := make(map[any]any, len(v.keys))
+		for i, k := range v.keys {
+			m[k] = toAny(v.values[i])
+		}
+		return m
+	}
+	return v
+}
+
+// writeValue writes `v` as WriteAny writes the value toAny makes of it,
+// with map entries in generation order.
+func writeValue(w msgpack.Writer, v any) {
+	switch v := v.(type) {
+	case []any:
+		w.WriteArraySize(uint32(len(v)))
+		for _, e := range v {
+			writeValue(w, e)
+		}
+	case *orderedMap:
+		w.WriteMapSize(uint32(len(v.keys)))
+		for i, k := range v.keys {
+			w.WriteAny(k)
+			writeValue(w, v.values[i])
+		}
+	default:
+		w.WriteAny(v)
+	}
+}
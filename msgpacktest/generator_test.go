@@ -0,0 +1,151 @@
+package msgpacktest_test
+
+import (
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/msgpacktest"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	a := msgpacktest.NewGenerator(42, msgpacktest.WithTimes(), msgpacktest.WithExt())
+	b := msgpacktest.NewGenerator(42, msgpacktest.WithTimes(), msgpacktest.WithExt())
+	other := msgpacktest.NewGenerator(43, msgpacktest.WithTimes(), msgpacktest.WithExt())
+	differs := false
+	for i := 0; i < 100; i++ {
+		raw := a.NextRaw()
+		assert.Equal(t, raw, b.NextRaw())
+		if string(raw) != string(other.NextRaw()) {
+			differs = true
+		}
+	}
+	assert.True(t, differs)
+}
+
+func TestGeneratorNextMatchesNextRaw(t *testing.T) {
+	values := msgpacktest.NewGenerator(5)
+	raws := msgpacktest.NewGenerator(5)
+	for i := 0; i < 100; i++ {
+		v := values.Next()
+		var sizer msgpack.Sizer
+		sizer.WriteAny(v)
+		encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+		encoder.WriteAny(v)
+		require.NoError(t, encoder.Err())
+
+		diffs, err := msgpack.Diff(encoder.Bytes(), raws.NextRaw())
+		require.NoError(t, err)
+		assert.Empty(t, diffs)
+	}
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	g := msgpacktest.NewGenerator(1, msgpacktest.WithMaxDepth(0), msgpacktest.WithNilProbability(0))
+	for i := 0; i < 200; i++ {
+		v := g.Next()
+		assert.NotNil(t, v)
+		switch v.(type) {
+		case []any, []string, []int64, map[string]any, map[any]any:
+			t.Fatalf("container %#v at depth 0", v)
+		case time.Time, msgpack.Raw:
+			t.Fatalf("%#v without WithTimes or WithExt", v)
+		}
+	}
+
+	g = msgpacktest.NewGenerator(1, msgpacktest.WithNilProbability(1))
+	assert.Nil(t, g.Next())
+
+	g = msgpacktest.NewGenerator(1, msgpacktest.WithMaxDepth(2), msgpacktest.WithMaxContainerLen(3))
+	for i := 0; i < 200; i++ {
+		assert.LessOrEqual(t, depth(g.Next()), 2)
+	}
+
+	for _, tc := range []struct {
+		alphabet msgpacktest.Alphabet
+		check    func(s string) bool
+	}{
+		{msgpacktest.AlphabetASCII, func(s string) bool {
+			for i := 0; i < len(s); i++ {
+				if s[i] < ' ' || s[i] > '~' {
+					return false
+				}
+			}
+			return true
+		}},
+		{msgpacktest.AlphabetUnicode, utf8.ValidString},
+	} {
+		g = msgpacktest.NewGenerator(3, msgpacktest.WithAlphabet(tc.alphabet), msgpacktest.WithMaxDepth(0))
+		for i := 0; i < 200; i++ {
+			if s, ok := g.Next().(string); ok {
+				assert.True(t, tc.check(s), "%q", s)
+			}
+		}
+	}
+}
+
+func TestGeneratorCoversTypes(t *testing.T) {
+	g := msgpacktest.NewGenerator(9, msgpacktest.WithTimes(), msgpacktest.WithExt())
+	seen := map[string]bool{}
+	for i := 0; i < 2000; i++ {
+		switch g.Next().(type) {
+		case nil:
+			seen["nil"] = true
+		case int8, int16, int32, int64, int:
+			seen["int"] = true
+		case uint8, uint16, uint32, uint64, uint:
+			seen["uint"] = true
+		case float32, float64:
+			seen["float"] = true
+		case string:
+			seen["string"] = true
+		case []byte:
+			seen["bytes"] = true
+		case time.Time:
+			seen["time"] = true
+		case msgpack.Raw:
+			seen["ext"] = true
+		case []any, []string, []int64:
+			seen["array"] = true
+		case map[string]any, map[any]any:
+			seen["map"] = true
+		case bool:
+			seen["bool"] = true
+		}
+	}
+	assert.Len(t, seen, 11)
+}
+
+func depth(v any) int {
+	d := 0
+	switch v := v.(type) {
+	case []any:
+		for _, e := range v {
+			if n := depth(e); n > d {
+				d = n
+			}
+		}
+		return d + 1
+	case []string, []int64:
+		return 1
+	case map[string]any:
+		for _, e := range v {
+			if n := depth(e); n > d {
+				d = n
+			}
+		}
+		return d + 1
+	case map[any]any:
+		for _, e := range v {
+			if n := depth(e); n > d {
+				d = n
+			}
+		}
+		return d + 1
+	}
+	return 0
+}
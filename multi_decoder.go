@@ -0,0 +1,67 @@
+package msgpack
+
+// MultiDecoder reads a buffer holding a sequence of concatenated top level
+// values, such as a log of documents.
+type MultiDecoder struct {
+	buffer  []byte
+	offset  uint32
+	options decOptions
+}
+
+// NewMultiDecoder creates a MultiDecoder positioned at the start of `buffer`.
+func NewMultiDecoder(buffer []byte) MultiDecoder {
+	return MultiDecoder{buffer: buffer}
+}
+
+// NewMultiDecoderWithOptions creates a MultiDecoder whose decoders are
+// configured by `opts`.
+func NewMultiDecoderWithOptions(buffer []byte, opts ...DecOption) MultiDecoder {
+	m := NewMultiDecoder(buffer)
+	for _, opt := range opts {
+		opt(&m.options)
+	}
+	return m
+}
+
+// More reports whether any bytes remain after the current position. With
+// WithTrailingNilPadding, bytes that are all nil do not count.
+func (m *MultiDecoder) More() bool {
+	if uint64(m.offset) >= uint64(len(m.buffer)) {
+		return false
+	}
+	return !m.options.trailingNilPadding || !isNilPadding(m.buffer[m.offset:])
+}
+
+// Offset returns the position of the next value in the buffer.
+func (m *MultiDecoder) Offset() uint32 {
+	return m.offset
+}
+
+// Seek moves to `offset`, for instance to resume at a position returned by
+// FindNextValidOffset after a decode error.
+func (m *MultiDecoder) Seek(offset uint32) {
+	m.offset = offset
+}
+
+// Next returns a decoder limited to the next value and moves past it. The
+// decoder reports offsets relative to the whole buffer. When the next value
+// is malformed or truncated the position is left unchanged and the error is
+// returned.
+func (m *MultiDecoder) Next() (Decoder, error) {
+	if uint64(m.offset) > uint64(len(m.buffer)) {
+		return Decoder{}, RangeError{Offset: m.offset}
+	}
+	scan := NewDecoderAt(m.buffer, m.offset, uint32(len(m.buffer))-m.offset)
+	scan.options = m.options
+	if err := scan.Skip(); err != nil {
+		return Decoder{}, err
+	}
+	if err := scan.Err(); err != nil {
+		return Decoder{}, err
+	}
+	start := m.offset
+	m.offset = scan.Offset()
+	d := NewDecoderAt(m.buffer, start, m.offset-start)
+	d.options = m.options
+	return d, nil
+}
@@ -0,0 +1,273 @@
+// Package netaddr writes and reads the net/netip address types in a
+// compact binary form:
+//
+//   - an Addr is a bin of 4 bytes for IPv4 and 16 bytes for IPv6, or an
+//     array of that bin and the zone name when the address has a zone. The
+//     zero Addr is an empty bin.
+//   - an AddrPort is an array of the Addr and the port.
+//   - a Prefix is an array of the Addr and the number of prefix bits.
+//
+// An IPv4 address takes 6 bytes this way instead of up to 16 as a
+// string. The readers also accept the textual forms parsed by netip, so
+// values written as strings by older producers can still be read.
+package netaddr
+
+import (
+	"errors"
+	"net/netip"
+	"strconv"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// ErrInvalid is wrapped by the errors for values that are not an address,
+// address and port or prefix.
+var ErrInvalid = errors.New("netaddr: invalid value")
+
+type invalidError struct {
+	message string
+}
+
+func (e *invalidError) Error() string {
+	return "netaddr: " + e.message
+}
+
+func (e *invalidError) Unwrap() error {
+	return ErrInvalid
+}
+
+func invalid(message string) error {
+	return &invalidError{message}
+}
+
+// WriteAddr writes `a` as a bin of 4 or 16 bytes, wrapped in an array
+// with its zone if it has one.
+func WriteAddr(w msgpack.Writer, a netip.Addr) {
+	if zone := a.Zone(); zone != "" {
+		w.WriteArraySize(2)
+		writeAddrBytes(w, a)
+		w.WriteString(zone)
+		return
+	}
+	writeAddrBytes(w, a)
+}
+
+func writeAddrBytes(w msgpack.Writer, a netip.Addr) {
+	switch {
+	case a.Is4():
+		b := a.As4()
+		w.WriteByteArray(b[:])
+	case a.Is6():
+		b := a.As16()
+		w.WriteByteArray(b[:])
+	default:
+		w.WriteByteArray([]byte{})
+	}
+}
+
+// ReadAddr reads an address written by WriteAddr, or an address string
+// such as "192.0.2.1" or "fe80::1%eth0".
+func ReadAddr(r msgpack.Reader) (netip.Addr, error) {
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	v, err := msgpack.DecodeValue(raw)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addrFromValue(v)
+}
+
+func addrFromValue(v msgpack.Value) (netip.Addr, error) {
+	switch v.Kind() {
+	case msgpack.KindBin:
+		b, _ := v.Bytes()
+		return addrFromBytes(b)
+	case msgpack.KindArray:
+		if v.Len() != 2 {
+			return netip.Addr{}, invalid("address with zone must be an array of 2 elements, got " +
+				strconv.Itoa(v.Len()))
+		}
+		b, ok := v.Index(0).Bytes()
+		if !ok || len(b) != 16 {
+			return netip.Addr{}, invalid("address with zone must start with a bin of 16 bytes")
+		}
+		zone, ok := v.Index(1).Str()
+		if !ok {
+			return netip.Addr{}, invalid("address zone must be a string")
+		}
+		a, _ := addrFromBytes(b)
+		return a.WithZone(zone), nil
+	case msgpack.KindString:
+		s, _ := v.Str()
+		a, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Addr{}, invalid(err.Error())
+		}
+		return a, nil
+	}
+	return netip.Addr{}, invalid("expected address, got " + v.Kind().String())
+}
+
+func addrFromBytes(b []byte) (netip.Addr, error) {
+	switch len(b) {
+	case 0:
+		return netip.Addr{}, nil
+	case 4:
+		return netip.AddrFrom4(*(*[4]byte)(b)), nil
+	case 16:
+		return netip.AddrFrom16(*(*[16]byte)(b)), nil
+	}
+	return netip.Addr{}, invalid("address must be 4 or 16 bytes, got " + strconv.Itoa(len(b)))
+}
+
+// WriteAddrPort writes `ap` as an array of its address and port.
+func WriteAddrPort(w msgpack.Writer, ap netip.AddrPort) {
+	w.WriteArraySize(2)
+	WriteAddr(w, ap.Addr())
+	w.WriteUint16(ap.Port())
+}
+
+// ReadAddrPort reads an address and port written by WriteAddrPort, or a
+// string such as "192.0.2.1:80" or "[::1]:80".
+func ReadAddrPort(r msgpack.Reader) (netip.AddrPort, error) {
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	v, err := msgpack.DecodeValue(raw)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	switch v.Kind() {
+	case msgpack.KindArray:
+		if v.Len() != 2 {
+			return netip.AddrPort{}, invalid("address and port must be an array of 2 elements, got " +
+				strconv.Itoa(v.Len()))
+		}
+		a, err := addrFromValue(v.Index(0))
+		if err != nil {
+			return netip.AddrPort{}, err
+		}
+		port, ok := v.Index(1).Uint()
+		if !ok || port > 0xffff {
+			return netip.AddrPort{}, invalid("port must be an integer from 0 to 65535")
+		}
+		return netip.AddrPortFrom(a, uint16(port)), nil
+	case msgpack.KindString:
+		s, _ := v.Str()
+		ap, err := netip.ParseAddrPort(s)
+		if err != nil {
+			return netip.AddrPort{}, invalid(err.Error())
+		}
+		return ap, nil
+	}
+	return netip.AddrPort{}, invalid("expected address and port, got " + v.Kind().String())
+}
+
+// WritePrefix writes `p` as an array of its address and number of bits.
+func WritePrefix(w msgpack.Writer, p netip.Prefix) {
+	w.WriteArraySize(2)
+	WriteAddr(w, p.Addr())
+	w.WriteInt64(int64(p.Bits()))
+}
+
+// ReadPrefix reads a prefix written by WritePrefix, or a string in CIDR
+// notation such as "192.0.2.0/24".
+func ReadPrefix(r msgpack.Reader) (netip.Prefix, error) {
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	v, err := msgpack.DecodeValue(raw)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	switch v.Kind() {
+	case msgpack.KindArray:
+		if v.Len() != 2 {
+			return netip.Prefix{}, invalid("prefix must be an array of 2 elements, got " +
+				strconv.Itoa(v.Len()))
+		}
+		a, err := addrFromValue(v.Index(0))
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		bits, ok := v.Index(1).Int()
+		if !ok || bits < 0 && a.IsValid() || bits > int64(a.BitLen()) {
+			return netip.Prefix{}, invalid("prefix bits must be an integer from 0 to " +
+				strconv.Itoa(a.BitLen()))
+		}
+		if a.Zone() != "" {
+			return netip.Prefix{}, invalid("prefix address must not have a zone")
+		}
+		return netip.PrefixFrom(a, int(bits)), nil
+	case msgpack.KindString:
+		s, _ := v.Str()
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, invalid(err.Error())
+		}
+		return p, nil
+	}
+	return netip.Prefix{}, invalid("expected prefix, got " + v.Kind().String())
+}
+
+// WriteNillableAddr writes nil for a nil `a` and is otherwise WriteAddr.
+func WriteNillableAddr(w msgpack.Writer, a *netip.Addr) {
+	if a == nil {
+		w.WriteNil()
+		return
+	}
+	WriteAddr(w, *a)
+}
+
+// ReadNillableAddr reads nil as a nil pointer and is otherwise ReadAddr.
+func ReadNillableAddr(r msgpack.Reader) (*netip.Addr, error) {
+	return readNillable(r, ReadAddr)
+}
+
+// WriteNillableAddrPort writes nil for a nil `ap` and is otherwise
+// WriteAddrPort.
+func WriteNillableAddrPort(w msgpack.Writer, ap *netip.AddrPort) {
+	if ap == nil {
+		w.WriteNil()
+		return
+	}
+	WriteAddrPort(w, *ap)
+}
+
+// ReadNillableAddrPort reads nil as a nil pointer and is otherwise
+// ReadAddrPort.
+func ReadNillableAddrPort(r msgpack.Reader) (*netip.AddrPort, error) {
+	return readNillable(r, ReadAddrPort)
+}
+
+// WriteNillablePrefix writes nil for a nil `p` and is otherwise
+// WritePrefix.
+func WriteNillablePrefix(w msgpack.Writer, p *netip.Prefix) {
+	if p == nil {
+		w.WriteNil()
+		return
+	}
+	WritePrefix(w, *p)
+}
+
+// ReadNillablePrefix reads nil as a nil pointer and is otherwise
+// ReadPrefix.
+func ReadNillablePrefix(r msgpack.Reader) (*netip.Prefix, error) {
+	return readNillable(r, ReadPrefix)
+}
+
+func readNillable[T any](r msgpack.Reader, read func(msgpack.Reader) (T, error)) (*T, error) {
+	isNil, err := r.IsNextNil()
+	if isNil || err != nil {
+		return nil, err
+	}
+	v, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
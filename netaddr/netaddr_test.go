@@ -0,0 +1,217 @@
+package netaddr_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/netaddr"
+)
+
+func encode(t *testing.T, fn func(w msgpack.Writer)) []byte {
+	t.Helper()
+	var sizer msgpack.Sizer
+	fn(&sizer)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	fn(&encoder)
+	require.NoError(t, encoder.Err())
+	require.Equal(t, sizer.Len(), uint32(len(encoder.Bytes())))
+	return encoder.Bytes()
+}
+
+var testAddrs = []netip.Addr{
+	netip.MustParseAddr("192.0.2.1"),
+	netip.MustParseAddr("2001:db8::1"),
+	netip.MustParseAddr("fe80::1%eth0"),
+	netip.MustParseAddr("::ffff:192.0.2.1"),
+	{},
+}
+
+func TestAddrRoundTrip(t *testing.T) {
+	data := encode(t, func(w msgpack.Writer) {
+		for _, a := range testAddrs {
+			netaddr.WriteAddr(w, a)
+			netaddr.WriteNillableAddr(w, &a)
+		}
+		netaddr.WriteNillableAddr(w, nil)
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	for _, expected := range testAddrs {
+		a, err := netaddr.ReadAddr(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, a)
+		p, err := netaddr.ReadNillableAddr(&decoder)
+		require.NoError(t, err)
+		require.NotNil(t, p)
+		assert.Equal(t, expected, *p)
+	}
+	p, err := netaddr.ReadNillableAddr(&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, p)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+}
+
+func TestAddrPortAndPrefixRoundTrip(t *testing.T) {
+	addrPorts := []netip.AddrPort{
+		netip.MustParseAddrPort("192.0.2.1:8080"),
+		netip.MustParseAddrPort("[fe80::1%eth0]:53"),
+	}
+	prefixes := []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("0.0.0.0/0"),
+	}
+	data := encode(t, func(w msgpack.Writer) {
+		for _, ap := range addrPorts {
+			netaddr.WriteAddrPort(w, ap)
+			netaddr.WriteNillableAddrPort(w, &ap)
+		}
+		for _, p := range prefixes {
+			netaddr.WritePrefix(w, p)
+			netaddr.WriteNillablePrefix(w, &p)
+		}
+		netaddr.WriteNillableAddrPort(w, nil)
+		netaddr.WriteNillablePrefix(w, nil)
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	for _, expected := range addrPorts {
+		ap, err := netaddr.ReadAddrPort(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, ap)
+		p, err := netaddr.ReadNillableAddrPort(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, *p)
+	}
+	for _, expected := range prefixes {
+		p, err := netaddr.ReadPrefix(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, p)
+		ptr, err := netaddr.ReadNillablePrefix(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, *ptr)
+	}
+	ap, err := netaddr.ReadNillableAddrPort(&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, ap)
+	p, err := netaddr.ReadNillablePrefix(&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func TestReadAcceptsStrings(t *testing.T) {
+	data := encode(t, func(w msgpack.Writer) {
+		w.WriteString("192.0.2.1")
+		w.WriteString("fe80::1%eth0")
+		w.WriteString("[::1]:443")
+		w.WriteString("10.0.0.0/8")
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	a, err := netaddr.ReadAddr(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), a)
+	a, err = netaddr.ReadAddr(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("fe80::1%eth0"), a)
+	ap, err := netaddr.ReadAddrPort(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddrPort("[::1]:443"), ap)
+	p, err := netaddr.ReadPrefix(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), p)
+}
+
+func TestReadInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		write   func(w msgpack.Writer)
+		read    func(r msgpack.Reader) error
+		message string
+	}{
+		{"bin of 5 bytes", func(w msgpack.Writer) { w.WriteByteArray([]byte{1, 2, 3, 4, 5}) },
+			readAddr, "netaddr: address must be 4 or 16 bytes, got 5"},
+		{"bad string", func(w msgpack.Writer) { w.WriteString("192.0.2.300") },
+			readAddr, `netaddr: ParseAddr("192.0.2.300"): IPv4 field has value >255`},
+		{"int", func(w msgpack.Writer) { w.WriteInt64(1) },
+			readAddr, "netaddr: expected address, got int"},
+		{"zone on v4", func(w msgpack.Writer) {
+			w.WriteArraySize(2)
+			w.WriteByteArray([]byte{192, 0, 2, 1})
+			w.WriteString("eth0")
+		}, readAddr, "netaddr: address with zone must start with a bin of 16 bytes"},
+		{"port too large", func(w msgpack.Writer) {
+			w.WriteArraySize(2)
+			w.WriteByteArray([]byte{192, 0, 2, 1})
+			w.WriteUint32(70000)
+		}, readAddrPort, "netaddr: port must be an integer from 0 to 65535"},
+		{"too many bits", func(w msgpack.Writer) {
+			w.WriteArraySize(2)
+			w.WriteByteArray([]byte{192, 0, 2, 1})
+			w.WriteInt64(33)
+		}, readPrefix, "netaddr: prefix bits must be an integer from 0 to 32"},
+		{"bad cidr", func(w msgpack.Writer) { w.WriteString("10.0.0.0") },
+			readPrefix, `netaddr: netip.ParsePrefix("10.0.0.0"): no '/'`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			decoder := msgpack.NewDecoder(encode(t, tc.write))
+			err := tc.read(&decoder)
+			assert.EqualError(t, err, tc.message)
+			assert.ErrorIs(t, err, netaddr.ErrInvalid)
+		})
+	}
+}
+
+func readAddr(r msgpack.Reader) error {
+	_, err := netaddr.ReadAddr(r)
+	return err
+}
+
+func readAddrPort(r msgpack.Reader) error {
+	_, err := netaddr.ReadAddrPort(r)
+	return err
+}
+
+func readPrefix(r msgpack.Reader) error {
+	_, err := netaddr.ReadPrefix(r)
+	return err
+}
+
+// TestSizeAgainstStrings documents the savings over writing the textual
+// form:
+//
+//	192.0.2.1         6 bytes vs 10
+//	2001:db8::1      18 bytes vs 12
+//	2001:db8:85a3:8d3:1319:8a2e:370:7348
+//	                 18 bytes vs 38
+//	192.0.2.1:8080   10 bytes vs 15
+//	192.0.2.0/24      8 bytes vs 13
+//
+// Short IPv6 addresses are the one case where the string is smaller.
+func TestSizeAgainstStrings(t *testing.T) {
+	for _, tc := range []struct {
+		text          string
+		binary, asStr int
+		write         func(w msgpack.Writer, s string)
+	}{
+		{"192.0.2.1", 6, 10, func(w msgpack.Writer, s string) { netaddr.WriteAddr(w, netip.MustParseAddr(s)) }},
+		{"2001:db8::1", 18, 12, func(w msgpack.Writer, s string) { netaddr.WriteAddr(w, netip.MustParseAddr(s)) }},
+		{"2001:db8:85a3:8d3:1319:8a2e:370:7348", 18, 38, func(w msgpack.Writer, s string) {
+			netaddr.WriteAddr(w, netip.MustParseAddr(s))
+		}},
+		{"192.0.2.1:8080", 10, 15, func(w msgpack.Writer, s string) {
+			netaddr.WriteAddrPort(w, netip.MustParseAddrPort(s))
+		}},
+		{"192.0.2.0/24", 8, 13, func(w msgpack.Writer, s string) { netaddr.WritePrefix(w, netip.MustParsePrefix(s)) }},
+	} {
+		var binary, asString msgpack.Sizer
+		tc.write(&binary, tc.text)
+		asString.WriteString(tc.text)
+		assert.Equal(t, uint32(tc.binary), binary.Len(), tc.text)
+		assert.Equal(t, uint32(tc.asStr), asString.Len(), tc.text)
+	}
+}
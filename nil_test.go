@@ -0,0 +1,114 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestPeekIsNilAndConsumeNil(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{msgpack.FormatNil, 0x01})
+
+	isNil, err := decoder.PeekIsNil()
+	require.NoError(t, err)
+	assert.True(t, isNil)
+	assert.Equal(t, uint32(0), decoder.Offset())
+
+	require.NoError(t, decoder.ConsumeNil())
+	assert.Equal(t, uint32(1), decoder.Offset())
+
+	isNil, err = decoder.PeekIsNil()
+	require.NoError(t, err)
+	assert.False(t, isNil)
+	assert.Equal(t, uint32(1), decoder.Offset())
+
+	err = decoder.ConsumeNil()
+	assert.EqualError(t, err, "msgpack: expected nil, found int at offset 1")
+	assert.Equal(t, uint32(1), decoder.Offset())
+
+	v, err := decoder.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+
+	_, err = decoder.PeekIsNil()
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.ErrorIs(t, decoder.ConsumeNil(), msgpack.ErrRange)
+}
+
+func TestIsNextNilCompatibility(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{msgpack.FormatNil, 0x01})
+
+	isNil, err := decoder.IsNextNil()
+	require.NoError(t, err)
+	assert.True(t, isNil)
+	assert.Equal(t, uint32(1), decoder.Offset())
+
+	isNil, err = decoder.IsNextNil()
+	require.NoError(t, err)
+	assert.False(t, isNil)
+	assert.Equal(t, uint32(1), decoder.Offset())
+}
+
+func TestNillableReadOffsets(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{msgpack.FormatNil, 0xa2, 'h', 'i'})
+
+	s, err := decoder.ReadNillableString()
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	assert.Equal(t, uint32(1), decoder.Offset())
+
+	s, err = decoder.ReadNillableString()
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, "hi", *s)
+	assert.Equal(t, uint32(4), decoder.Offset())
+}
+
+func TestNilCollectionsAsError(t *testing.T) {
+	data := []byte{0x01, msgpack.FormatNil}
+
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, decoder.Skip())
+	size, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), size)
+
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithNilCollectionsAsError())
+	require.NoError(t, decoder.Skip())
+	_, err = decoder.ReadArraySize()
+	assert.EqualError(t, err, "msgpack: expected array, found nil at offset 1")
+
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithNilCollectionsAsError())
+	require.NoError(t, decoder.Skip())
+	_, err = decoder.ReadMapSize()
+	assert.EqualError(t, err, "msgpack: expected map, found nil at offset 1")
+
+	// Empty collections are still fine.
+	decoder = msgpack.NewDecoderWithOptions([]byte{0x90, 0x80}, msgpack.WithNilCollectionsAsError())
+	size, err = decoder.ReadArraySize()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), size)
+	size, err = decoder.ReadMapSize()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), size)
+}
+
+func TestNilCollectionsAsErrorNillablePaths(t *testing.T) {
+	decoder := msgpack.NewDecoderWithOptions([]byte{msgpack.FormatNil}, msgpack.WithNilCollectionsAsError())
+	message, err := msgpack.DecodeNillable[poolMessage](&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, message)
+
+	decoder = msgpack.NewDecoderWithOptions([]byte{msgpack.FormatNil}, msgpack.WithNilCollectionsAsError())
+	m, err := msgpack.ReadStringAnyMap(&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	// A codec that requires the collection reports the nil.
+	decoder = msgpack.NewDecoderWithOptions([]byte{msgpack.FormatNil}, msgpack.WithNilCollectionsAsError())
+	_, err = msgpack.Decode[poolMessage](&decoder)
+	assert.EqualError(t, err, "msgpack: expected array, found nil at offset 0")
+}
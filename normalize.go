@@ -0,0 +1,271 @@
+package msgpack
+
+import (
+	"math"
+	"reflect"
+	"time"
+)
+
+// NormalizeError reports a value NormalizeAny cannot convert.
+type NormalizeError struct {
+	// Path locates the value, as in Difference.Path.
+	Path []any
+	// Type is the Go type of the value.
+	Type string
+}
+
+func (e NormalizeError) Error() string {
+	return "msgpack: cannot normalize " + e.Type + " at " + formatDiffPath(e.Path)
+}
+
+type normalizeOptions struct {
+	integralFloatsAsInt bool
+}
+
+// NormalizeOption configures NormalizeAny.
+type NormalizeOption func(*normalizeOptions)
+
+// WithIntegralFloatsAsInt makes NormalizeAny convert floats that hold a
+// whole number in the range of an int64, such as the float64 values
+// encoding/json produces for every number, to int64.
+func WithIntegralFloatsAsInt() NormalizeOption {
+	return func(o *normalizeOptions) {
+		o.integralFloatsAsInt = true
+	}
+}
+
+// NormalizeAny converts a value tree built from the types WriteAny accepts,
+// or from json.Number values, to the types ReadAny returns for the same
+// data, so that trees holding the same data encode to the same bytes:
+//
+//   - integers become int64, or uint64 above math.MaxInt64
+//   - floats become float64, and json.Number values int64 or float64
+//   - byte arrays become []byte
+//   - slices become []any
+//   - maps whose keys are all strings become map[string]any, maps whose
+//     keys are all integers that fit an int64 become map[int64]any, and
+//     other maps map[any]any with normalized keys
+//
+// nil, bool, string, []byte, time.Time, Raw and Codec values are kept as
+// they are. A value of any other type returns a NormalizeError with the
+// path of the first one found. Encode the result with WithSortedStringMaps
+// and WithSortedIntMaps for output that does not depend on map order.
+func NormalizeAny(value any, opts ...NormalizeOption) (any, error) {
+	var o normalizeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return normalizeValue(value, nil, o)
+}
+
+// jsonNumber matches json.Number without importing encoding/json.
+type jsonNumber interface {
+	Int64() (int64, error)
+	Float64() (float64, error)
+	String() string
+}
+
+func normalizeValue(value any, path []any, o normalizeOptions) (any, error) {
+	switch v := value.(type) {
+	case nil, bool, string, []byte, time.Time, Raw, Codec:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return normalizeUint(uint64(v)), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return normalizeUint(v), nil
+	case float32:
+		return normalizeFloat(float64(v), o), nil
+	case float64:
+		return normalizeFloat(v, o), nil
+	case jsonNumber:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if f, err := v.Float64(); err == nil {
+			return normalizeFloat(f, o), nil
+		}
+	case [16]byte:
+		return v[:], nil
+	case [32]byte:
+		return v[:], nil
+	case []any:
+		return normalizeSlice(v, path, o)
+	case []string:
+		return normalizeSlice(v, path, o)
+	case []time.Time:
+		return normalizeSlice(v, path, o)
+	case []bool:
+		return normalizeSlice(v, path, o)
+	case []int:
+		return normalizeSlice(v, path, o)
+	case []int8:
+		return normalizeSlice(v, path, o)
+	case []int16:
+		return normalizeSlice(v, path, o)
+	case []int32:
+		return normalizeSlice(v, path, o)
+	case []int64:
+		return normalizeSlice(v, path, o)
+	case []uint:
+		return normalizeSlice(v, path, o)
+	case []uint16:
+		return normalizeSlice(v, path, o)
+	case []uint32:
+		return normalizeSlice(v, path, o)
+	case []uint64:
+		return normalizeSlice(v, path, o)
+	case []float32:
+		return normalizeSlice(v, path, o)
+	case []float64:
+		return normalizeSlice(v, path, o)
+	case map[string]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[string]string:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[string]time.Time:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[int]string:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[int]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[int8]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[int16]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[int32]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[int64]string:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[int64]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[uint]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[uint8]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[uint16]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[uint32]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[uint64]any:
+		return normalizeMap(mapEntries(v), path, o)
+	case map[any]any:
+		entries := make([][2]any, 0, len(v))
+		for key, item := range v {
+			entries = append(entries, [2]any{key, item})
+		}
+		return normalizeMap(entries, path, o)
+	}
+	return nil, NormalizeError{Path: copyPath(path), Type: reflect.TypeOf(value).String()}
+}
+
+func normalizeUint(v uint64) any {
+	if v > math.MaxInt64 {
+		return v
+	}
+	return int64(v)
+}
+
+func normalizeFloat(v float64, o normalizeOptions) any {
+	// -2^63 converts exactly; 2^63 is the first float64 above MaxInt64.
+	if o.integralFloatsAsInt && v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+		return int64(v)
+	}
+	return v
+}
+
+func normalizeSlice[T any](s []T, path []any, o normalizeOptions) (any, error) {
+	if s == nil {
+		return []any(nil), nil
+	}
+	result := make([]any, len(s))
+	for i, item := range s {
+		v, err := normalizeValue(item, append(path, i), o)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+// normalizeKey normalizes a map key, except for byte arrays, which would
+// become slices and could no longer be map keys.
+func normalizeKey(key any, path []any, o normalizeOptions) (any, error) {
+	switch key.(type) {
+	case [16]byte, [32]byte:
+		return key, nil
+	}
+	return normalizeValue(key, path, o)
+}
+
+// mapEntries returns the key and value pairs of `m`. Maps with keys of
+// type any are collected by hand, since any is not comparable in Go 1.18.
+func mapEntries[K comparable, V any](m map[K]V) [][2]any {
+	entries := make([][2]any, 0, len(m))
+	for key, item := range m {
+		entries = append(entries, [2]any{key, item})
+	}
+	return entries
+}
+
+func normalizeMap(entries [][2]any, path []any, o normalizeOptions) (any, error) {
+	keys := make([]any, 0, len(entries))
+	values := make([]any, 0, len(entries))
+	strings, integers := true, true
+	for _, entry := range entries {
+		key, err := normalizeKey(entry[0], path, o)
+		if err != nil {
+			return nil, err
+		}
+		value, err := normalizeValue(entry[1], append(path, key), o)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case string:
+			integers = false
+		case int64:
+			strings = false
+		default:
+			strings, integers = false, false
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	switch {
+	case strings:
+		result := make(map[string]any, len(keys))
+		for i, key := range keys {
+			result[key.(string)] = values[i]
+		}
+		return result, nil
+	case integers:
+		result := make(map[int64]any, len(keys))
+		for i, key := range keys {
+			result[key.(int64)] = values[i]
+		}
+		return result, nil
+	}
+	result := make(map[any]any, len(keys))
+	for i, key := range keys {
+		result[key] = values[i]
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+const normalizeJSON = `{"id": 42, "ratio": 0.5, "big": 1e3, "name": "ada",
+	"tags": ["a", "b"], "nested": {"ok": true, "none": null, "n": -7}}`
+
+func TestNormalizeAnyJSON(t *testing.T) {
+	var plain any
+	require.NoError(t, json.Unmarshal([]byte(normalizeJSON), &plain))
+	var numbers any
+	decoder := json.NewDecoder(bytes.NewReader([]byte(normalizeJSON)))
+	decoder.UseNumber()
+	require.NoError(t, decoder.Decode(&numbers))
+
+	expected := map[string]any{
+		"id":    int64(42),
+		"ratio": 0.5,
+		"big":   int64(1000),
+		"name":  "ada",
+		"tags":  []any{"a", "b"},
+		"nested": map[string]any{
+			"ok":   true,
+			"none": nil,
+			"n":    int64(-7),
+		},
+	}
+
+	normalized, err := msgpack.NormalizeAny(plain, msgpack.WithIntegralFloatsAsInt())
+	require.NoError(t, err)
+	assert.Equal(t, expected, normalized)
+
+	normalized, err = msgpack.NormalizeAny(numbers, msgpack.WithIntegralFloatsAsInt())
+	require.NoError(t, err)
+	assert.Equal(t, expected, normalized)
+
+	// Without the option, floats stay floats.
+	normalized, err = msgpack.NormalizeAny(plain)
+	require.NoError(t, err)
+	assert.Equal(t, 42.0, normalized.(map[string]any)["id"])
+	normalized, err = msgpack.NormalizeAny(numbers)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), normalized.(map[string]any)["id"])
+	assert.Equal(t, 1000.0, normalized.(map[string]any)["big"])
+}
+
+func TestNormalizeAnyStableEncoding(t *testing.T) {
+	when := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	messy := []any{
+		map[any]any{"a": int8(1), "b": []int{1, 2}, "c": uint16(3), "t": when},
+		map[string]any{"a": 1.0, "b": []any{json.Number("1"), uint(2)}, "c": float32(3), "t": when},
+		map[string]any{"a": json.Number("1"), "b": []int64{1, 2}, "c": int64(3), "t": when},
+	}
+	var encoded [][]byte
+	for _, value := range messy {
+		normalized, err := msgpack.NormalizeAny(value, msgpack.WithIntegralFloatsAsInt())
+		require.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			encoded = append(encoded, encodeWithOptions(t, func(w msgpack.Writer) {
+				w.WriteAny(normalized)
+			}, msgpack.WithSortedStringMaps()))
+		}
+	}
+	for _, data := range encoded[1:] {
+		assert.Equal(t, encoded[0], data)
+	}
+}
+
+func TestNormalizeAnyTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		expected any
+	}{
+		{"nil", nil, nil},
+		{"uint64 above int64", uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{"uint64 in range", uint64(5), int64(5)},
+		{"float32", float32(1.5), 1.5},
+		{"byte array", [16]byte{1}, []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{"raw", msgpack.Raw{0xc0}, msgpack.Raw{0xc0}},
+		{"nil slice", []string(nil), []any(nil)},
+		{"int keys", map[any]any{1: "a", uint8(2): "b"}, map[int64]any{1: "a", 2: "b"}},
+		{"mixed keys", map[any]any{1: "a", "b": "b"}, map[any]any{int64(1): "a", "b": "b"}},
+		{"int map", map[int32]any{3: []bool{true}}, map[int64]any{3: []any{true}}},
+		{"string map", map[string]string{"k": "v"}, map[string]any{"k": "v"}},
+		{"empty map", map[any]any{}, map[string]any{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, err := msgpack.NormalizeAny(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, normalized)
+		})
+	}
+
+	// Floats are only converted when they are whole and fit an int64.
+	for _, f := range []float64{0.5, math.NaN(), math.Inf(1), math.MaxInt64, 1e19} {
+		normalized, err := msgpack.NormalizeAny(f, msgpack.WithIntegralFloatsAsInt())
+		require.NoError(t, err)
+		assert.IsType(t, float64(0), normalized, "%v", f)
+	}
+	normalized, err := msgpack.NormalizeAny(float64(math.MinInt64), msgpack.WithIntegralFloatsAsInt())
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt64), normalized)
+}
+
+func TestNormalizeAnyError(t *testing.T) {
+	type point struct{ X int }
+	value := map[string]any{"items": []any{1, map[string]any{"p": point{1}}}}
+	_, err := msgpack.NormalizeAny(value)
+	var normalizeErr msgpack.NormalizeError
+	require.ErrorAs(t, err, &normalizeErr)
+	assert.Equal(t, []any{"items", 1, "p"}, normalizeErr.Path)
+	assert.EqualError(t, err, "msgpack: cannot normalize msgpack_test.point at items[1].p")
+
+	_, err = msgpack.NormalizeAny(json.Number("twelve"))
+	assert.EqualError(t, err, "msgpack: cannot normalize json.Number at $")
+}
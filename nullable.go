@@ -0,0 +1,23 @@
+package msgpack
+
+// ReadNullable reads a value that may be nil with `read`, for wrapper types
+// that carry a validity flag next to the value, such as the database/sql
+// Null types. When the value is nil, `valid` is false and `value` is zero.
+func ReadNullable[T any](r Reader, read func(Reader) (T, error)) (value T, valid bool, err error) {
+	isNil, err := readNil(r)
+	if isNil || err != nil {
+		return value, false, err
+	}
+	value, err = read(r)
+	return value, err == nil, err
+}
+
+// WriteNullable writes nil when `valid` is false, otherwise `value` with
+// `write`. It is the counterpart of ReadNullable.
+func WriteNullable[T any](w Writer, value T, valid bool, write func(Writer, T)) {
+	if !valid {
+		w.WriteNil()
+		return
+	}
+	write(w, value)
+}
@@ -0,0 +1,32 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestNullable(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteNullable(w, "set", true, msgpack.Writer.WriteString)
+		msgpack.WriteNullable(w, "ignored", false, msgpack.Writer.WriteString)
+		w.WriteBool(true)
+	})
+	assert.Equal(t, []byte{0xa3, 's', 'e', 't', 0xc0, 0xc3}, data)
+
+	decoder := msgpack.NewDecoder(data)
+	s, valid, err := msgpack.ReadNullable(&decoder, msgpack.Reader.ReadString)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.Equal(t, "set", s)
+	s, valid, err = msgpack.ReadNullable(&decoder, msgpack.Reader.ReadString)
+	require.NoError(t, err)
+	assert.False(t, valid)
+	assert.Equal(t, "", s)
+	_, valid, err = msgpack.ReadNullable(&decoder, msgpack.Reader.ReadString)
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
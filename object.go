@@ -0,0 +1,488 @@
+package msgpack
+
+import (
+	"strconv"
+	"time"
+)
+
+// ObjectCodec encodes and decodes values of type T as maps, from a field
+// list declared once with Object and Field. Fields are written in the
+// order they were declared. On decode, unknown keys are skipped, and
+// reported to the reporter set with WithUnknownKeyReporter if any. Missing
+// fields keep their current value unless marked Required or given a
+// Default.
+//
+// Sizing needs nothing extra: Encode against a Sizer.
+type ObjectCodec[T any] struct {
+	fields []FieldCodec[T]
+	index  map[string]int
+	// checkMissing is set when a field is required or has a default.
+	checkMissing bool
+}
+
+// FieldCodec encodes and decodes one field of a T. Create one with a kind
+// function such as StringField or ObjectField.
+type FieldCodec[T any] struct {
+	name     string
+	required bool
+	apply    func(v *T) error
+	encode   func(w Writer, v *T) error
+	decode   func(r Reader, v *T) error
+	// clear sets the field to its zero value.
+	clear func(v *T)
+}
+
+// FieldError wraps an error decoding the field with map key Field. In the
+// errors of a MultiError, Field is the path of keys from the outermost
+// object, joined with dots, and Offset is where the value starts, or where
+// the object ends for a field that is missing.
+type FieldError struct {
+	Field  string
+	Err    error
+	Offset uint32
+}
+
+func (e FieldError) Error() string {
+	return "msgpack: field " + strconv.Quote(e.Field) + ": " + e.Err.Error()
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Required makes decoding fail when the field is missing.
+func (f FieldCodec[T]) Required() FieldCodec[T] {
+	f.required = true
+	return f
+}
+
+// Default makes decoding call `apply` when the field is missing, once the
+// whole map has been read, so that messages from producers that predate
+// the field get a value other than the zero value:
+//
+//	Field("timeout_ms", msgpack.Int64Field(getTimeout).Default(func(c *Config) error {
+//		c.TimeoutMS = 5000
+//		return nil
+//	}))
+//
+// An error from `apply` is returned in a FieldError. A field cannot be
+// both required and defaulted.
+func (f FieldCodec[T]) Default(apply func(v *T) error) FieldCodec[T] {
+	f.apply = apply
+	return f
+}
+
+// Object starts an empty field list for T.
+func Object[T any]() *ObjectCodec[T] {
+	return &ObjectCodec[T]{index: map[string]int{}}
+}
+
+// Field adds a field stored under the map key `name`. Declaring the same
+// name twice, or a field that is both required and defaulted, panics.
+func (o *ObjectCodec[T]) Field(name string, field FieldCodec[T]) *ObjectCodec[T] {
+	if _, exists := o.index[name]; exists {
+		panic("msgpack: duplicate field " + strconv.Quote(name))
+	}
+	if field.required && field.apply != nil {
+		panic("msgpack: field " + strconv.Quote(name) + " is both required and defaulted")
+	}
+	field.name = name
+	o.index[name] = len(o.fields)
+	o.fields = append(o.fields, field)
+	o.checkMissing = o.checkMissing || field.required || field.apply != nil
+	return o
+}
+
+// Encode writes `v` as a map with one entry per field.
+func (o *ObjectCodec[T]) Encode(w Writer, v *T) error {
+	w.WriteMapSize(uint32(len(o.fields)))
+	for i := range o.fields {
+		w.WriteString(o.fields[i].name)
+		if err := o.fields[i].encode(w, v); err != nil {
+			return err
+		}
+	}
+	return w.Err()
+}
+
+// Decode reads a map into `v`. A nil value is read as an empty map, so it
+// leaves `v` unchanged unless a field is required or has a default. With
+// WithCollectErrors, the errors of all fields are returned together in a
+// MultiError.
+func (o *ObjectCodec[T]) Decode(r Reader, v *T) error {
+	if d, ok := r.(*Decoder); ok && d.options.collectErrors > 0 && d.collector == nil {
+		return decodeCollecting(d, func() error { return o.decode(r, v) })
+	}
+	return o.decode(r, v)
+}
+
+func (o *ObjectCodec[T]) decode(r Reader, v *T) error {
+	d, collector := collectorOf(r)
+	var size uint32
+	isNil, err := readNil(r)
+	if err != nil {
+		return err
+	}
+	if !isNil {
+		if size, err = r.ReadMapSize(); err != nil {
+			return err
+		}
+	}
+	seen := newFieldSet(len(o.fields))
+	for i := uint32(0); i < size; i++ {
+		key, err := readStringKey(r)
+		if err != nil {
+			return err
+		}
+		index, known := o.index[key]
+		if !known {
+			if err := decOptionsOf(r).unknownKeys.Skip(r, key); err != nil {
+				return err
+			}
+			continue
+		}
+		if collector != nil {
+			start, frames := d.reader.byteOffset, len(d.containers)
+			collector.path = append(collector.path, key)
+			err := o.fields[index].decode(r, v)
+			collector.path = collector.path[:len(collector.path)-1]
+			if err != nil {
+				if err := collector.skipFailed(d, key, start, frames, err); err != nil {
+					return err
+				}
+			}
+			// A field that failed is not also reported missing.
+			seen.add(index)
+			continue
+		}
+		if err := o.fields[index].decode(r, v); err != nil {
+			return FieldError{Field: key, Err: err}
+		}
+		seen.add(index)
+	}
+	if !o.checkMissing {
+		return nil
+	}
+	for i := range o.fields {
+		field := &o.fields[i]
+		if seen.has(i) {
+			continue
+		}
+		if field.required {
+			err := ReadError{"msgpack: missing required field " + strconv.Quote(field.name)}
+			if collector == nil {
+				return err
+			}
+			if err := collector.add(field.name, d.reader.byteOffset, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.apply != nil {
+			if err := field.apply(v); err != nil {
+				if collector == nil {
+					return FieldError{Field: field.name, Err: err}
+				}
+				if err := collector.add(field.name, d.reader.byteOffset, err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fieldSet records which fields of an ObjectCodec were read, by their
+// index. Objects of up to 64 fields need no allocation.
+type fieldSet struct {
+	small uint64
+	large []uint64
+}
+
+func newFieldSet(n int) fieldSet {
+	if n <= 64 {
+		return fieldSet{}
+	}
+	return fieldSet{large: make([]uint64, (n+63)/64)}
+}
+
+func (s *fieldSet) add(i int) {
+	if s.large == nil {
+		s.small |= 1 << i
+		return
+	}
+	s.large[i/64] |= 1 << (i % 64)
+}
+
+func (s *fieldSet) has(i int) bool {
+	if s.large == nil {
+		return s.small&(1<<i) != 0
+	}
+	return s.large[i/64]&(1<<(i%64)) != 0
+}
+
+// Bind returns a Codec that encodes and decodes `v`, for APIs such as
+// CodecPool that take one.
+func (o *ObjectCodec[T]) Bind(v *T) Codec {
+	return boundObject[T]{o, v}
+}
+
+type boundObject[T any] struct {
+	codec *ObjectCodec[T]
+	value *T
+}
+
+func (b boundObject[T]) Encode(w Writer) error {
+	return b.codec.Encode(w, b.value)
+}
+
+func (b boundObject[T]) Decode(r Reader) error {
+	return b.codec.Decode(r, b.value)
+}
+
+func scalarField[T, F any](get func(*T) *F, write func(Writer, F), read func(Reader) (F, error)) FieldCodec[T] {
+	return FieldCodec[T]{
+		encode: func(w Writer, v *T) error {
+			write(w, *get(v))
+			return nil
+		},
+		decode: func(r Reader, v *T) error {
+			value, err := read(r)
+			if err != nil {
+				return err
+			}
+			*get(v) = value
+			return nil
+		},
+		clear: func(v *T) {
+			var zero F
+			*get(v) = zero
+		},
+	}
+}
+
+func BoolField[T any](get func(*T) *bool) FieldCodec[T] {
+	return scalarField(get, Writer.WriteBool, Reader.ReadBool)
+}
+
+func Int8Field[T any](get func(*T) *int8) FieldCodec[T] {
+	return scalarField(get, Writer.WriteInt8, Reader.ReadInt8)
+}
+
+func Int16Field[T any](get func(*T) *int16) FieldCodec[T] {
+	return scalarField(get, Writer.WriteInt16, Reader.ReadInt16)
+}
+
+func Int32Field[T any](get func(*T) *int32) FieldCodec[T] {
+	return scalarField(get, Writer.WriteInt32, Reader.ReadInt32)
+}
+
+func Int64Field[T any](get func(*T) *int64) FieldCodec[T] {
+	return scalarField(get, Writer.WriteInt64, Reader.ReadInt64)
+}
+
+func Uint8Field[T any](get func(*T) *uint8) FieldCodec[T] {
+	return scalarField(get, Writer.WriteUint8, Reader.ReadUint8)
+}
+
+func Uint16Field[T any](get func(*T) *uint16) FieldCodec[T] {
+	return scalarField(get, Writer.WriteUint16, Reader.ReadUint16)
+}
+
+func Uint32Field[T any](get func(*T) *uint32) FieldCodec[T] {
+	return scalarField(get, Writer.WriteUint32, Reader.ReadUint32)
+}
+
+func Uint64Field[T any](get func(*T) *uint64) FieldCodec[T] {
+	return scalarField(get, Writer.WriteUint64, Reader.ReadUint64)
+}
+
+func Float32Field[T any](get func(*T) *float32) FieldCodec[T] {
+	return scalarField(get, Writer.WriteFloat32, Reader.ReadFloat32)
+}
+
+func Float64Field[T any](get func(*T) *float64) FieldCodec[T] {
+	return scalarField(get, Writer.WriteFloat64, Reader.ReadFloat64)
+}
+
+func StringField[T any](get func(*T) *string) FieldCodec[T] {
+	return scalarField(get, Writer.WriteString, Reader.ReadString)
+}
+
+func TimeField[T any](get func(*T) *time.Time) FieldCodec[T] {
+	return scalarField(get, Writer.WriteTime, Reader.ReadTime)
+}
+
+// BytesField reads a byte slice that aliases the decoder's buffer.
+func BytesField[T any](get func(*T) *[]byte) FieldCodec[T] {
+	return scalarField(get, Writer.WriteByteArray, Reader.ReadByteArray)
+}
+
+// RawField passes an already encoded value through unchanged. An empty Raw
+// is written as nil. A decoded Raw aliases the decoder's buffer.
+func RawField[T any](get func(*T) *Raw) FieldCodec[T] {
+	return scalarField(get, writeRawOrNil, Reader.ReadRaw)
+}
+
+func writeRawOrNil(w Writer, value Raw) {
+	if len(value) == 0 {
+		w.WriteNil()
+		return
+	}
+	w.WriteRaw(value)
+}
+
+func NillableBoolField[T any](get func(*T) **bool) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableBool, Reader.ReadNillableBool)
+}
+
+func NillableInt8Field[T any](get func(*T) **int8) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableInt8, Reader.ReadNillableInt8)
+}
+
+func NillableInt16Field[T any](get func(*T) **int16) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableInt16, Reader.ReadNillableInt16)
+}
+
+func NillableInt32Field[T any](get func(*T) **int32) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableInt32, Reader.ReadNillableInt32)
+}
+
+func NillableInt64Field[T any](get func(*T) **int64) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableInt64, Reader.ReadNillableInt64)
+}
+
+func NillableUint8Field[T any](get func(*T) **uint8) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableUint8, Reader.ReadNillableUint8)
+}
+
+func NillableUint16Field[T any](get func(*T) **uint16) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableUint16, Reader.ReadNillableUint16)
+}
+
+func NillableUint32Field[T any](get func(*T) **uint32) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableUint32, Reader.ReadNillableUint32)
+}
+
+func NillableUint64Field[T any](get func(*T) **uint64) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableUint64, Reader.ReadNillableUint64)
+}
+
+func NillableFloat32Field[T any](get func(*T) **float32) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableFloat32, Reader.ReadNillableFloat32)
+}
+
+func NillableFloat64Field[T any](get func(*T) **float64) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableFloat64, Reader.ReadNillableFloat64)
+}
+
+func NillableStringField[T any](get func(*T) **string) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableString, Reader.ReadNillableString)
+}
+
+func NillableTimeField[T any](get func(*T) **time.Time) FieldCodec[T] {
+	return scalarField(get, Writer.WriteNillableTime, Reader.ReadNillableTime)
+}
+
+// StringSliceField stores a []string as an array; nil is written as nil.
+func StringSliceField[T any](get func(*T) *[]string) FieldCodec[T] {
+	return sliceField(get, Writer.WriteString, Reader.ReadString)
+}
+
+// Int64SliceField stores a []int64 as an array; nil is written as nil.
+func Int64SliceField[T any](get func(*T) *[]int64) FieldCodec[T] {
+	return sliceField(get, Writer.WriteInt64, Reader.ReadInt64)
+}
+
+// Int32SliceField stores a []int32 as an array; nil is written as nil.
+func Int32SliceField[T any](get func(*T) *[]int32) FieldCodec[T] {
+	return sliceField(get, Writer.WriteInt32, Reader.ReadInt32)
+}
+
+func sliceField[T, E any](get func(*T) *[]E, write func(Writer, E), read func(Reader) (E, error)) FieldCodec[T] {
+	return FieldCodec[T]{
+		encode: func(w Writer, v *T) error {
+			values := *get(v)
+			if values == nil {
+				w.WriteNil()
+				return nil
+			}
+			w.WriteArraySize(uint32(len(values)))
+			for _, e := range values {
+				write(w, e)
+			}
+			return nil
+		},
+		decode: func(r Reader, v *T) error {
+			isNil, err := readNil(r)
+			if err != nil || isNil {
+				*get(v) = nil
+				return err
+			}
+			size, err := r.ReadArraySize()
+			if err != nil {
+				return err
+			}
+			values := make([]E, 0, sizeHint(r, size))
+			for i := uint32(0); i < size; i++ {
+				e, err := read(r)
+				if err != nil {
+					return err
+				}
+				values = append(values, e)
+			}
+			*get(v) = values
+			return nil
+		},
+		clear: func(v *T) {
+			*get(v) = nil
+		},
+	}
+}
+
+// ObjectField stores a nested U described by `codec`.
+func ObjectField[T, U any](get func(*T) *U, codec *ObjectCodec[U]) FieldCodec[T] {
+	return FieldCodec[T]{
+		encode: func(w Writer, v *T) error {
+			return codec.Encode(w, get(v))
+		},
+		decode: func(r Reader, v *T) error {
+			return codec.Decode(r, get(v))
+		},
+		clear: func(v *T) {
+			var zero U
+			*get(v) = zero
+		},
+	}
+}
+
+// NillableObjectField stores a nested *U described by `codec`, writing nil
+// for a nil pointer.
+func NillableObjectField[T, U any](get func(*T) **U, codec *ObjectCodec[U]) FieldCodec[T] {
+	return FieldCodec[T]{
+		encode: func(w Writer, v *T) error {
+			value := *get(v)
+			if value == nil {
+				w.WriteNil()
+				return nil
+			}
+			return codec.Encode(w, value)
+		},
+		decode: func(r Reader, v *T) error {
+			isNil, err := readNil(r)
+			if err != nil || isNil {
+				*get(v) = nil
+				return err
+			}
+			value := new(U)
+			if err := codec.Decode(r, value); err != nil {
+				return err
+			}
+			*get(v) = value
+			return nil
+		},
+		clear: func(v *T) {
+			*get(v) = nil
+		},
+	}
+}
@@ -0,0 +1,326 @@
+package msgpack_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type Address struct {
+	Street string
+	City   string
+}
+
+type Person struct {
+	Name     string
+	Age      int32
+	Email    *string
+	Tags     []string
+	Scores   []int64
+	Joined   time.Time
+	Home     Address
+	Work     *Address
+	Metadata msgpack.Raw
+}
+
+var addressCodec = msgpack.Object[Address]().
+	Field("street", msgpack.StringField(func(a *Address) *string { return &a.Street })).
+	Field("city", msgpack.StringField(func(a *Address) *string { return &a.City }))
+
+var personCodec = msgpack.Object[Person]().
+	Field("name", msgpack.StringField(func(p *Person) *string { return &p.Name }).Required()).
+	Field("age", msgpack.Int32Field(func(p *Person) *int32 { return &p.Age })).
+	Field("email", msgpack.NillableStringField(func(p *Person) **string { return &p.Email })).
+	Field("tags", msgpack.StringSliceField(func(p *Person) *[]string { return &p.Tags })).
+	Field("scores", msgpack.Int64SliceField(func(p *Person) *[]int64 { return &p.Scores })).
+	Field("joined", msgpack.TimeField(func(p *Person) *time.Time { return &p.Joined })).
+	Field("home", msgpack.ObjectField(func(p *Person) *Address { return &p.Home }, addressCodec)).
+	Field("work", msgpack.NillableObjectField(func(p *Person) **Address { return &p.Work }, addressCodec)).
+	Field("metadata", msgpack.RawField(func(p *Person) *msgpack.Raw { return &p.Metadata }))
+
+func ExampleObject() {
+	codec := msgpack.Object[Address]().
+		Field("street", msgpack.StringField(func(a *Address) *string { return &a.Street })).
+		Field("city", msgpack.StringField(func(a *Address) *string { return &a.City }))
+
+	in := Address{Street: "1 Main St", City: "Springfield"}
+	var sizer msgpack.Sizer
+	codec.Encode(&sizer, &in)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	codec.Encode(&encoder, &in)
+
+	var out Address
+	decoder := msgpack.NewDecoder(encoder.Bytes())
+	if err := codec.Decode(&decoder, &out); err != nil {
+		panic(err)
+	}
+	fmt.Printf("%d bytes: %+v\n", sizer.Len(), out)
+	// Output: 35 bytes: {Street:1 Main St City:Springfield}
+}
+
+func TestObjectRoundTrip(t *testing.T) {
+	email := "ada@example.com"
+	metadata := encodeWith(t, func(w msgpack.Writer) { w.WriteAny([]any{"x", int64(1)}) })
+	in := Person{
+		Name:     "Ada",
+		Age:      36,
+		Email:    &email,
+		Tags:     []string{"math", "engines"},
+		Scores:   []int64{1, -2, 300},
+		Joined:   time.Unix(1700000000, 500),
+		Home:     Address{"12 St James Sq", "London"},
+		Work:     &Address{"Analytical Rd", "London"},
+		Metadata: metadata,
+	}
+	data := encodeWith(t, func(w msgpack.Writer) { require.NoError(t, personCodec.Encode(w, &in)) })
+
+	var out Person
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, personCodec.Decode(&decoder, &out))
+	assert.True(t, in.Joined.Equal(out.Joined))
+	out.Joined = in.Joined
+	assert.Equal(t, in, out)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+
+	// Nil pointers and slices survive as nil.
+	in = Person{Name: "Bob"}
+	data = encodeWith(t, func(w msgpack.Writer) { require.NoError(t, personCodec.Encode(w, &in)) })
+	out = Person{}
+	decoder = msgpack.NewDecoder(data)
+	require.NoError(t, personCodec.Decode(&decoder, &out))
+	assert.Nil(t, out.Email)
+	assert.Nil(t, out.Tags)
+	assert.Nil(t, out.Work)
+	assert.Equal(t, msgpack.Raw{msgpack.FormatNil}, out.Metadata)
+}
+
+func TestObjectForwardCompatibility(t *testing.T) {
+	// A newer writer adds fields and reorders; an older reader skips them.
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(4)
+		w.WriteString("city")
+		w.WriteString("Paris")
+		w.WriteString("postcode")
+		w.WriteString("75001")
+		w.WriteString("geo")
+		w.WriteAny([]any{48.86, 2.34})
+		w.WriteString("street")
+		w.WriteString("Rue de Rivoli")
+	})
+	var out Address
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, addressCodec.Decode(&decoder, &out))
+	assert.Equal(t, Address{"Rue de Rivoli", "Paris"}, out)
+
+	// Missing optional fields are left alone; missing required ones fail.
+	data = encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("age")
+		w.WriteInt32(7)
+	})
+	p := Person{Name: "kept"}
+	decoder = msgpack.NewDecoder(data)
+	err := personCodec.Decode(&decoder, &p)
+	assert.EqualError(t, err, `msgpack: missing required field "name"`)
+	assert.Equal(t, int32(7), p.Age)
+}
+
+func TestObjectFieldError(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("home")
+		w.WriteMapSize(1)
+		w.WriteString("city")
+		w.WriteInt64(5)
+	})
+	var p Person
+	decoder := msgpack.NewDecoder(data)
+	err := personCodec.Decode(&decoder, &p)
+	assert.EqualError(t, err, `msgpack: field "home": msgpack: field "city": bad prefix for string length: got fixint(5) (0x05) at offset 12`)
+	var fieldErr msgpack.FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "home", fieldErr.Field)
+
+	assert.Panics(t, func() {
+		msgpack.Object[Address]().
+			Field("city", msgpack.StringField(func(a *Address) *string { return &a.City })).
+			Field("city", msgpack.StringField(func(a *Address) *string { return &a.Street }))
+	})
+}
+
+func TestObjectSliceFieldTruncated(t *testing.T) {
+	codec := msgpack.Object[Person]().
+		Field("l", msgpack.Int64SliceField(func(p *Person) *[]int64 { return &p.Scores }))
+	// The array claims 2^32-1 elements, with none following.
+	data := []byte{0x81, 0xa1, 'l', 0xdd, 0xff, 0xff, 0xff, 0xff}
+	var p Person
+	decoder := msgpack.NewDecoder(data)
+	err := codec.Decode(&decoder, &p)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.Nil(t, p.Scores)
+}
+
+func TestObjectBind(t *testing.T) {
+	pool := msgpack.NewCodecPool(16)
+	in := Address{"Main St", "Oslo"}
+	data, err := pool.Encode(addressCodec.Bind(&in))
+	require.NoError(t, err)
+	var out Address
+	require.NoError(t, pool.Decode(data, addressCodec.Bind(&out)))
+	assert.Equal(t, in, out)
+}
+
+type serviceConfig struct {
+	Name      string
+	TimeoutMS int64
+	Retries   int32
+}
+
+var serviceConfigCodec = msgpack.Object[serviceConfig]().
+	Field("name", msgpack.StringField(func(c *serviceConfig) *string { return &c.Name }).Required()).
+	Field("timeout_ms", msgpack.Int64Field(func(c *serviceConfig) *int64 { return &c.TimeoutMS }).
+		Default(func(c *serviceConfig) error {
+			c.TimeoutMS = 5000
+			return nil
+		})).
+	Field("retries", msgpack.Int32Field(func(c *serviceConfig) *int32 { return &c.Retries }).
+		Default(func(c *serviceConfig) error {
+			c.Retries++
+			return nil
+		}))
+
+func writeServiceConfig(w msgpack.Writer, name string, timeoutMS int64) {
+	if timeoutMS == 0 {
+		w.WriteMapSize(1)
+	} else {
+		w.WriteMapSize(2)
+		w.WriteString("timeout_ms")
+		w.WriteInt64(timeoutMS)
+	}
+	w.WriteString("name")
+	w.WriteString(name)
+}
+
+func TestObjectDefaults(t *testing.T) {
+	var c serviceConfig
+	decoder := msgpack.NewDecoder(encodeWith(t, func(w msgpack.Writer) {
+		writeServiceConfig(w, "api", 250)
+	}))
+	require.NoError(t, serviceConfigCodec.Decode(&decoder, &c))
+	assert.Equal(t, serviceConfig{Name: "api", TimeoutMS: 250, Retries: 1}, c,
+		"only the missing field is defaulted, once")
+
+	c = serviceConfig{}
+	decoder = msgpack.NewDecoder(encodeWith(t, func(w msgpack.Writer) {
+		writeServiceConfig(w, "api", 0)
+	}))
+	require.NoError(t, serviceConfigCodec.Decode(&decoder, &c))
+	assert.Equal(t, serviceConfig{Name: "api", TimeoutMS: 5000, Retries: 1}, c)
+
+	decoder = msgpack.NewDecoder(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString("timeout_ms")
+		w.WriteInt64(1)
+	}))
+	assert.EqualError(t, serviceConfigCodec.Decode(&decoder, &c), `msgpack: missing required field "name"`)
+}
+
+func TestObjectDefaultsReuse(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		timeout := int64(0)
+		if i%3 == 0 {
+			timeout = int64(i + 1)
+		}
+		decoder := msgpack.NewDecoder(encodeWith(t, func(w msgpack.Writer) {
+			writeServiceConfig(w, "svc", timeout)
+		}))
+		var c serviceConfig
+		require.NoError(t, serviceConfigCodec.Decode(&decoder, &c))
+		want := timeout
+		if want == 0 {
+			want = 5000
+		}
+		assert.Equal(t, serviceConfig{Name: "svc", TimeoutMS: want, Retries: 1}, c, "message %d", i)
+	}
+}
+
+func TestObjectDefaultNil(t *testing.T) {
+	codec := msgpack.Object[serviceConfig]().
+		Field("timeout_ms", msgpack.Int64Field(func(c *serviceConfig) *int64 { return &c.TimeoutMS }).
+			Default(func(c *serviceConfig) error {
+				c.TimeoutMS = 5000
+				return nil
+			}))
+	var c serviceConfig
+	decoder := msgpack.NewDecoder([]byte{msgpack.FormatNil})
+	require.NoError(t, codec.Decode(&decoder, &c))
+	assert.Equal(t, int64(5000), c.TimeoutMS)
+}
+
+func TestObjectDefaultError(t *testing.T) {
+	codec := msgpack.Object[serviceConfig]().
+		Field("name", msgpack.StringField(func(c *serviceConfig) *string { return &c.Name }).
+			Default(func(c *serviceConfig) error {
+				return fmt.Errorf("no default name")
+			}))
+	var c serviceConfig
+	decoder := msgpack.NewDecoder([]byte{0x80})
+	err := codec.Decode(&decoder, &c)
+	assert.EqualError(t, err, `msgpack: field "name": no default name`)
+	var fieldErr msgpack.FieldError
+	assert.ErrorAs(t, err, &fieldErr)
+}
+
+func TestObjectRequiredAndDefaultPanics(t *testing.T) {
+	field := msgpack.StringField(func(c *serviceConfig) *string { return &c.Name })
+	noop := func(*serviceConfig) error { return nil }
+	assert.PanicsWithValue(t, `msgpack: field "name" is both required and defaulted`, func() {
+		msgpack.Object[serviceConfig]().Field("name", field.Required().Default(noop))
+	})
+	assert.Panics(t, func() {
+		msgpack.Object[serviceConfig]().Field("name", field.Default(noop).Required())
+	})
+}
+
+func TestObjectManyFields(t *testing.T) {
+	// More fields than fit in one word of the set of fields read.
+	type wide struct {
+		Values [70]int64
+	}
+	codec := msgpack.Object[wide]()
+	for i := 0; i < 70; i++ {
+		i := i
+		field := msgpack.Int64Field(func(v *wide) *int64 { return &v.Values[i] })
+		if i%2 == 1 {
+			field = field.Default(func(v *wide) error {
+				v.Values[i] = -1
+				return nil
+			})
+		}
+		codec.Field(fmt.Sprint(i), field)
+	}
+	decoder := msgpack.NewDecoder(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("65")
+		w.WriteInt64(65)
+		w.WriteString("66")
+		w.WriteInt64(66)
+	}))
+	var v wide
+	require.NoError(t, codec.Decode(&decoder, &v))
+	for i, value := range v.Values {
+		switch {
+		case i == 65 || i == 66:
+			assert.Equal(t, int64(i), value)
+		case i%2 == 1:
+			assert.Equal(t, int64(-1), value, "field %d", i)
+		default:
+			assert.Equal(t, int64(0), value, "field %d", i)
+		}
+	}
+}
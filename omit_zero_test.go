@@ -0,0 +1,72 @@
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func writeNillableZeros(w msgpack.Writer) {
+	f, zero, s, tm := false, int64(0), "", time.Time{}
+	u8, fl := uint8(0), 0.0
+	w.WriteNillableBool(&f)
+	w.WriteNillableInt64(&zero)
+	w.WriteNillableUint8(&u8)
+	w.WriteNillableFloat64(&fl)
+	w.WriteNillableString(&s)
+	w.WriteNillableTime(&tm)
+	w.WriteNillableByteArray([]byte{})
+}
+
+func encodeWithOptions(t *testing.T, fn func(w msgpack.Writer), opts ...msgpack.EncOption) []byte {
+	sizer := msgpack.NewSizerWithOptions(opts...)
+	fn(&sizer)
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), opts...)
+	fn(&encoder)
+	require.NoError(t, encoder.Err())
+	require.Equal(t, sizer.Len(), encoder.Len())
+	return encoder.Bytes()
+}
+
+func TestOmitZeroAsNil(t *testing.T) {
+	data := encodeWithOptions(t, writeNillableZeros, msgpack.WithOmitZeroAsNil())
+	assert.Equal(t, []byte{0xc0, 0xc0, 0xc0, 0xc0, 0xc0, 0xc0, 0xc0}, data)
+
+	// Without the option false and 0 are written as values.
+	plain := encodeWithOptions(t, writeNillableZeros)
+	assert.Equal(t, encodeWith(t, writeNillableZeros), plain)
+	assert.Equal(t, []byte{msgpack.FormatFalse, 0x00, 0x00}, plain[:3])
+
+	// Non-zero values and the non-nillable writers are unaffected.
+	tr, one, s := true, int64(1), "x"
+	write := func(w msgpack.Writer) {
+		w.WriteNillableBool(&tr)
+		w.WriteNillableInt64(&one)
+		w.WriteNillableString(&s)
+		w.WriteBool(false)
+		w.WriteInt64(0)
+		w.WriteString("")
+	}
+	assert.Equal(t, encodeWith(t, write), encodeWithOptions(t, write, msgpack.WithOmitZeroAsNil()))
+}
+
+func TestWriteZeroAsNil(t *testing.T) {
+	writeInt := func(w msgpack.Writer, v int32) { w.WriteInt32(v) }
+	writeBool := func(w msgpack.Writer, v bool) { w.WriteBool(v) }
+	data := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteZeroAsNil(w, int32(0), writeInt)
+		msgpack.WriteZeroAsNil(w, int32(5), writeInt)
+		msgpack.WriteZeroAsNil(w, false, writeBool)
+		msgpack.WriteZeroAsNil(w, true, writeBool)
+	})
+	assert.Equal(t, []byte{0xc0, 0x05, 0xc0, msgpack.FormatTrue}, data)
+
+	decoder := msgpack.NewDecoder(data)
+	v, err := decoder.ReadNillableInt32()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
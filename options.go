@@ -0,0 +1,246 @@
+package msgpack
+
+import (
+	"strconv"
+	"time"
+)
+
+// encOptions holds encoder configuration. Its zero value is the wire
+// behavior of v0.1 of this package.
+type encOptions struct {
+	stringTable    bool
+	stringTableExt int8
+
+	omitZero         bool
+	sortedIntMaps    bool
+	sortedStringMaps bool
+
+	containerLenCheck bool
+	maxContainerLen   uint32
+
+	durationUnit     time.Duration
+	durationAsString bool
+}
+
+// EncOption configures an Encoder.
+type EncOption func(*encOptions)
+
+// NewEncoderWithOptions creates an encoder over `buffer` configured by
+// `opts`.
+func NewEncoderWithOptions(buffer []byte, opts ...EncOption) Encoder {
+	e := NewEncoder(buffer)
+	for _, opt := range opts {
+		opt(&e.options)
+	}
+	return e
+}
+
+// NewSizerWithOptions creates a sizer configured by `opts`. It must be given
+// the same options as the encoder it sizes for.
+func NewSizerWithOptions(opts ...EncOption) Sizer {
+	var s Sizer
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+	return s
+}
+
+// NewUpperBoundSizerWithOptions creates an UpperBoundSizer configured by
+// `opts`. It must be given the same options as the encoder it bounds.
+func NewUpperBoundSizerWithOptions(opts ...EncOption) UpperBoundSizer {
+	var s UpperBoundSizer
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+	return s
+}
+
+// encOptionsOf returns the options of `w` if it is an Encoder, Sizer,
+// UpperBoundSizer or the writer of ContentHash, and the defaults otherwise.
+func encOptionsOf(w Writer) encOptions {
+	switch w := w.(type) {
+	case *Encoder:
+		return w.options
+	case *Sizer:
+		return w.options
+	case *UpperBoundSizer:
+		return w.options
+	case *hashWriter:
+		return w.enc.options
+	}
+	return encOptions{}
+}
+
+// CompatV01 resets the encoder to the format choices made by v0.1 of this
+// package, so its output is byte-identical to what a v0.1 encoder writes
+// for the same calls. Options given after it still apply. The v0.1 choices are:
+// integers use the smallest format for their sign (WriteUint* never
+// produces a signed format and WriteInt* never an unsigned one), strings
+// are always str formats and byte slices always bin formats, an empty byte
+// slice is written as a bin8 of length 0, and times use the timestamp
+// extension type -1 in its 32, 64 or 96 bit form.
+func CompatV01() EncOption {
+	return func(o *encOptions) {
+		*o = encOptions{}
+	}
+}
+
+// WithOmitZeroAsNil makes the WriteNillable methods write nil for a value
+// that is the zero value of its type: false, 0, an empty string or byte
+// slice, or a time for which IsZero reports true. The other write methods
+// are not affected; use WriteZeroAsNil for fields that are not pointers.
+// The Sizer needs the option too.
+func WithOmitZeroAsNil() EncOption {
+	return func(o *encOptions) {
+		o.omitZero = true
+	}
+}
+
+// WithSortedIntMaps makes WriteAny write maps with integer keys, such as
+// map[int64]any, in ascending key order instead of Go's random map order,
+// so equal maps always encode to the same bytes. The Sizer needs the
+// option too.
+func WithSortedIntMaps() EncOption {
+	return func(o *encOptions) {
+		o.sortedIntMaps = true
+	}
+}
+
+// WithSortedStringMaps makes WriteAny, WriteStringAnyMap and WriteRawMap
+// write maps with string keys, such as map[string]any, in ascending byte
+// order of their keys, and Transcode sort maps whose keys are all strings.
+// The Sizer needs the option too.
+func WithSortedStringMaps() EncOption {
+	return func(o *encOptions) {
+		o.sortedStringMaps = true
+	}
+}
+
+// WriteZeroAsNil writes nil when `value` is the zero value of its type and
+// otherwise writes it with `write`, whatever the writer's options.
+func WriteZeroAsNil[T comparable](w Writer, value T, write func(Writer, T)) {
+	var zero T
+	if value == zero {
+		w.WriteNil()
+		return
+	}
+	write(w, value)
+}
+
+type decOptions struct {
+	timeStringDetection bool
+	maxStringLen        uint32
+	maxBinLen           uint32
+	stringTable         bool
+	stringTableExt      int8
+
+	nilCollectionsAsError bool
+
+	durationUnit time.Duration
+
+	anyDepthLimit int
+	maxDepth      uint32
+
+	trailingNilPadding bool
+	stringifiedMapKeys bool
+
+	timeStrictness TimeStrictness
+
+	stringToNumber bool
+	numberToString bool
+
+	unknownKeys *UnknownKeyReporter
+
+	terseErrors bool
+
+	collectErrors int
+}
+
+// DecOption configures a Decoder.
+type DecOption func(*decOptions)
+
+// NewDecoderWithOptions creates a decoder over `buffer` configured by `opts`.
+func NewDecoderWithOptions(buffer []byte, opts ...DecOption) Decoder {
+	d := NewDecoder(buffer)
+	for _, opt := range opts {
+		opt(&d.options)
+	}
+	return d
+}
+
+// decOptionsOf returns the options of `r` if it is a Decoder, and the
+// defaults otherwise.
+func decOptionsOf(r Reader) decOptions {
+	if d, ok := r.(*Decoder); ok {
+		return d.options
+	}
+	return decOptions{}
+}
+
+// WithTimeStringDetection makes ReadAny return a time.Time for strings that
+// are RFC 3339 timestamps. Only strings with the exact shape of a timestamp
+// (`2006-01-02T15:04:05` followed by optional fractional seconds and a `Z`
+// or numeric zone offset) are parsed, and strings that fail to parse are
+// returned unchanged. Any string with that shape is converted, so a
+// free-form text value that happens to be a timestamp will also come back
+// as a time.Time.
+func WithTimeStringDetection() DecOption {
+	return func(o *decOptions) {
+		o.timeStringDetection = true
+	}
+}
+
+// WithNilCollectionsAsError makes ReadArraySize and ReadMapSize return an
+// error for nil instead of a size of 0, so a nil sent where a collection is
+// required is caught where it is read. Use PeekIsNil, ConsumeNil or
+// DecodeNillable to accept nil on purpose.
+func WithNilCollectionsAsError() DecOption {
+	return func(o *decOptions) {
+		o.nilCollectionsAsError = true
+	}
+}
+
+// WithAnyDepthLimit makes ReadAny decode arrays and maps only down to
+// `depth` levels of nesting and return the ones below as Raw, so the top
+// of a document can be inspected without decoding the rest, which can be
+// passed on as it is with WriteAny or WriteRaw. A depth of 1 decodes the
+// top-level container and returns its container elements as Raw. Raw
+// values read with WithStringTableDecoding may refer to strings outside
+// them. `depth` must be at least 1.
+func WithAnyDepthLimit(depth int) DecOption {
+	if depth < 1 {
+		panic("msgpack: WithAnyDepthLimit needs a depth of at least 1")
+	}
+	return func(o *decOptions) {
+		o.anyDepthLimit = depth
+	}
+}
+
+// WithMaxDepth makes ReadAny, Skip and the reads built on them, such as
+// ReadRaw, return an error for arrays and maps nested more than `depth`
+// levels deep, counted from the value being read. A depth of 1 allows a
+// container of scalars. The default, 0, is no limit.
+func WithMaxDepth(depth uint32) DecOption {
+	return func(o *decOptions) {
+		o.maxDepth = depth
+	}
+}
+
+// checkDepth returns an error when the next value is a container and
+// `depth` containers already enclose it, the most the decoder allows.
+func (d *Decoder) checkDepth(depth int) error {
+	max := d.options.maxDepth
+	if max == 0 || uint64(depth) < uint64(max) {
+		return nil
+	}
+	prefix, err := d.reader.PeekUint8()
+	if err != nil || !isContainer(prefix) {
+		return nil
+	}
+	return ReadError{"msgpack: maximum depth of " + strconv.FormatUint(uint64(max), 10) +
+		" exceeded at offset " + strconv.FormatUint(uint64(d.reader.byteOffset), 10)}
+}
+
+func nilCollectionError(kind string, offset uint32) error {
+	return ReadError{"msgpack: expected " + kind + ", found nil at offset " + strconv.FormatUint(uint64(offset), 10)}
+}
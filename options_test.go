@@ -0,0 +1,118 @@
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// writeOptionSensitive writes values whose encoding depends on the encoder
+// options.
+func writeOptionSensitive(w msgpack.Writer) {
+	empty := ""
+	w.WriteArraySize(6)
+	w.WriteAny(map[string]any{"b": "repeated", "a": int64(1), "c": []any{"repeated"}})
+	w.WriteAny(map[int64]any{3: "x", 1: "y", 2: "z"})
+	w.WriteStringAnyMap(map[string]any{"repeated": true, "k": nil})
+	w.WriteNillableString(&empty)
+	msgpack.WriteDuration(w, 1500*time.Millisecond)
+	w.WriteString("repeated")
+}
+
+func TestEncoderOptionsAgreement(t *testing.T) {
+	options := map[string][]msgpack.EncOption{
+		"default":          nil,
+		"CompatV01":        {msgpack.CompatV01()},
+		"OmitZeroAsNil":    {msgpack.WithOmitZeroAsNil()},
+		"SortedIntMaps":    {msgpack.WithSortedIntMaps()},
+		"SortedStringMaps": {msgpack.WithSortedStringMaps()},
+		"StringTable":      {msgpack.WithStringTable(stringTableExt)},
+		"ContainerLen":     {msgpack.WithContainerLenCheck(16)},
+		"DurationUnit":     {msgpack.WithDurationUnit(time.Millisecond)},
+		"DurationAsString": {msgpack.WithDurationAsString()},
+		"all": {
+			msgpack.WithOmitZeroAsNil(), msgpack.WithSortedIntMaps(), msgpack.WithSortedStringMaps(),
+			msgpack.WithStringTable(stringTableExt), msgpack.WithContainerLenCheck(16),
+			msgpack.WithDurationAsString(),
+		},
+	}
+	for name, opts := range options {
+		t.Run(name, func(t *testing.T) {
+			// encodeWithOptions checks that the sizer and encoder agree.
+			encodeWithOptions(t, writeOptionSensitive, opts...)
+		})
+	}
+}
+
+func TestEncoderOptionsDefaults(t *testing.T) {
+	// The zero-option constructors are the option constructors without
+	// options.
+	write := func(w msgpack.Writer) {
+		w.WriteAny(map[string]any{"a": []any{int64(1), "b"}})
+		w.WriteAny(map[int64]any{1: "c"})
+		msgpack.WriteDuration(w, time.Second)
+	}
+	assert.Equal(t, encodeWith(t, write), encodeWithOptions(t, write))
+	assert.Equal(t, msgpack.NewSizer(), msgpack.NewSizerWithOptions())
+}
+
+func TestOptionsAreCopiedIntoCodecs(t *testing.T) {
+	opts := []msgpack.EncOption{msgpack.WithSortedIntMaps()}
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, 5), opts...)
+	opts[0] = msgpack.CompatV01()
+	encoder.WriteAny(map[int64]any{2: nil, 1: nil})
+	require.NoError(t, encoder.Err())
+	assert.Equal(t, []byte{0x82, 0x01, 0xc0, 0x02, 0xc0}, encoder.Bytes())
+
+	decOpts := []msgpack.DecOption{msgpack.WithMaxDepth(1)}
+	decoder := msgpack.NewDecoderWithOptions([]byte{0x91, 0x91, 0x01}, decOpts...)
+	decOpts[0] = msgpack.WithMaxDepth(0)
+	_, err := decoder.ReadAny()
+	assert.Error(t, err)
+}
+
+func TestMaxDepth(t *testing.T) {
+	// [[1, {}], 2] is three levels deep.
+	data := []byte{0x92, 0x92, 0x01, 0x80, 0x02}
+
+	for _, depth := range []uint32{0, 3, 4} {
+		decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithMaxDepth(depth))
+		value, err := decoder.ReadAny()
+		require.NoError(t, err)
+		assert.Equal(t, []any{[]any{int64(1), map[any]any{}}, int64(2)}, value)
+
+		decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithMaxDepth(depth))
+		require.NoError(t, decoder.Skip())
+		assert.Equal(t, uint32(0), decoder.Remaining())
+	}
+
+	reads := map[string]func(d *msgpack.Decoder) error{
+		"ReadAny": func(d *msgpack.Decoder) error { _, err := d.ReadAny(); return err },
+		"Skip":    func(d *msgpack.Decoder) error { return d.Skip() },
+		"ReadRaw": func(d *msgpack.Decoder) error { _, err := d.ReadRaw(); return err },
+	}
+	for name, read := range reads {
+		t.Run(name, func(t *testing.T) {
+			decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithMaxDepth(1))
+			err := read(&decoder)
+			assert.EqualError(t, err, "msgpack: maximum depth of 1 exceeded at offset 1")
+			decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithMaxDepth(2))
+			err = read(&decoder)
+			assert.EqualError(t, err, "msgpack: maximum depth of 2 exceeded at offset 3")
+
+			// Empty containers count too.
+			decoder = msgpack.NewDecoderWithOptions([]byte{0x91, 0x90}, msgpack.WithMaxDepth(1))
+			assert.Error(t, read(&decoder))
+
+			// Depth is counted from the value being read.
+			decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithMaxDepth(2))
+			_, err = decoder.ReadArraySize()
+			require.NoError(t, err)
+			require.NoError(t, read(&decoder))
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package msgpack
+
+import "strconv"
+
+// WithTrailingNilPadding makes ExpectEOF accept bytes left after the
+// message when every one of them is nil (0xc0), for hosts that pad
+// buffers to a block size with nil bytes. A MultiDecoder created with the
+// option reads such padding as the end of the buffer rather than as more
+// nil values.
+func WithTrailingNilPadding() DecOption {
+	return func(o *decOptions) {
+		o.trailingNilPadding = true
+	}
+}
+
+// ExpectEOF returns an error when bytes are left to read, for checking
+// that a message was consumed entirely. With WithTrailingNilPadding, bytes
+// that are all nil are not an error.
+func (d *Decoder) ExpectEOF() error {
+	if err := d.reader.Err(); err != nil {
+		return err
+	}
+	rest := d.reader.buffer[d.reader.byteOffset:]
+	if len(rest) == 0 || d.options.trailingNilPadding && isNilPadding(rest) {
+		return nil
+	}
+	return ReadError{"msgpack: " + strconv.Itoa(len(rest)) +
+		" trailing bytes at offset " + strconv.FormatUint(uint64(d.reader.byteOffset), 10)}
+}
+
+// TrimNilPadding returns `data` without the nil bytes that follow its first
+// value. The end of the value is found with Skip, so a value that itself
+// ends with nil keeps it. `data` is returned unchanged when its first value
+// cannot be read.
+func TrimNilPadding(data []byte) []byte {
+	d := NewDecoder(data)
+	if err := d.Skip(); err != nil || d.reader.Err() != nil {
+		return data
+	}
+	end := len(data)
+	for end > int(d.Offset()) && data[end-1] == FormatNil {
+		end--
+	}
+	return data[:end]
+}
+
+func isNilPadding(b []byte) bool {
+	for _, c := range b {
+		if c != FormatNil {
+			return false
+		}
+	}
+	return true
+}
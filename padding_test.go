@@ -0,0 +1,120 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// padded returns `data` padded to `size` bytes with nil.
+func padded(data []byte, size int) []byte {
+	out := append([]byte(nil), data...)
+	for len(out) < size {
+		out = append(out, msgpack.FormatNil)
+	}
+	return out
+}
+
+func TestExpectEOF(t *testing.T) {
+	message := []byte{0x92, 0x01, 0xa1, 'a'}
+	// A message that legitimately ends with nil.
+	endsWithNil := []byte{0x92, 0x01, msgpack.FormatNil}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		strict  string
+		padding string
+	}{
+		{"exact", message, "", ""},
+		{"padded", padded(message, 16), "msgpack: 12 trailing bytes at offset 4", ""},
+		{"ends with nil", padded(endsWithNil, 16), "msgpack: 13 trailing bytes at offset 3", ""},
+		{"garbage", append(padded(message, 8), 0x01, msgpack.FormatNil),
+			"msgpack: 6 trailing bytes at offset 4", "msgpack: 6 trailing bytes at offset 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, padding := range []bool{false, true} {
+				var decoder msgpack.Decoder
+				expected := tt.strict
+				if padding {
+					decoder = msgpack.NewDecoderWithOptions(tt.data, msgpack.WithTrailingNilPadding())
+					expected = tt.padding
+				} else {
+					decoder = msgpack.NewDecoder(tt.data)
+				}
+				require.NoError(t, decoder.Skip())
+				err := decoder.ExpectEOF()
+				if expected == "" {
+					assert.NoError(t, err)
+				} else {
+					assert.EqualError(t, err, expected)
+				}
+			}
+		})
+	}
+
+	// A read error is reported before any trailing bytes.
+	decoder := msgpack.NewDecoder([]byte{0x92, 0x01})
+	_, _ = decoder.ReadAny()
+	assert.ErrorIs(t, decoder.ExpectEOF(), msgpack.ErrRange)
+}
+
+func TestTrimNilPadding(t *testing.T) {
+	message := []byte{0x92, 0x01, 0xa1, 'a'}
+	endsWithNil := []byte{0x92, 0x01, msgpack.FormatNil}
+
+	assert.Equal(t, message, msgpack.TrimNilPadding(padded(message, 16)))
+	assert.Equal(t, message, msgpack.TrimNilPadding(message))
+	assert.Equal(t, endsWithNil, msgpack.TrimNilPadding(padded(endsWithNil, 16)))
+	assert.Equal(t, []byte{msgpack.FormatNil}, msgpack.TrimNilPadding(padded(nil, 8)))
+
+	// Only trailing nil bytes are removed.
+	garbage := append(padded(message, 8), 0x01, msgpack.FormatNil, msgpack.FormatNil)
+	assert.Equal(t, garbage[:9], msgpack.TrimNilPadding(garbage))
+
+	// Unreadable data is returned as it is.
+	truncated := []byte{0x93, 0x01, msgpack.FormatNil}
+	assert.Equal(t, truncated, msgpack.TrimNilPadding(truncated))
+}
+
+func TestMultiDecoderNilPadding(t *testing.T) {
+	log := append([]byte{0x01, 0x92, 0x02, msgpack.FormatNil}, msgpack.FormatNil, msgpack.FormatNil)
+
+	var values []any
+	reader := msgpack.NewMultiDecoderWithOptions(log, msgpack.WithTrailingNilPadding())
+	for reader.More() {
+		decoder, err := reader.Next()
+		require.NoError(t, err)
+		v, err := decoder.ReadAny()
+		require.NoError(t, err)
+		require.NoError(t, decoder.ExpectEOF())
+		values = append(values, v)
+	}
+	assert.Equal(t, []any{int64(1), []any{int64(2), nil}}, values)
+
+	// Without the option each padding byte is a document.
+	values = nil
+	reader = msgpack.NewMultiDecoder(log)
+	for reader.More() {
+		decoder, err := reader.Next()
+		require.NoError(t, err)
+		v, err := decoder.ReadAny()
+		require.NoError(t, err)
+		values = append(values, v)
+	}
+	assert.Equal(t, []any{int64(1), []any{int64(2), nil}, nil, nil}, values)
+
+	// Padding is only skipped at the end of the buffer.
+	reader = msgpack.NewMultiDecoderWithOptions([]byte{msgpack.FormatNil, 0x01}, msgpack.WithTrailingNilPadding())
+	require.True(t, reader.More())
+	decoder, err := reader.Next()
+	require.NoError(t, err)
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+	assert.True(t, reader.More())
+}
@@ -0,0 +1,57 @@
+package msgpack
+
+// MapPair is one entry for WriteMapFromPairs. Create one with Pair,
+// PairFunc or PairIf.
+type MapPair struct {
+	key   func(Writer)
+	value func(Writer)
+	skip  bool
+}
+
+// Pair is an entry with the string key `key`, whose value is written by
+// `value`.
+func Pair(key string, value func(Writer)) MapPair {
+	return MapPair{key: func(w Writer) { w.WriteString(key) }, value: value}
+}
+
+// PairFunc is an entry whose key is written by `key` and value by `value`,
+// for keys that are not strings.
+func PairFunc(key, value func(Writer)) MapPair {
+	return MapPair{key: key, value: value}
+}
+
+// PairIf is Pair when `cond` is true and otherwise an entry that
+// WriteMapFromPairs leaves out.
+func PairIf(cond bool, key string, value func(Writer)) MapPair {
+	if !cond {
+		return MapPair{skip: true}
+	}
+	return Pair(key, value)
+}
+
+// WriteMapFromPairs writes a map holding `pairs`, sizing the header from
+// the entries that are not left out.
+func WriteMapFromPairs(w Writer, pairs ...MapPair) {
+	size := uint32(0)
+	for i := range pairs {
+		if !pairs[i].skip {
+			size++
+		}
+	}
+	w.WriteMapSize(size)
+	for i := range pairs {
+		if !pairs[i].skip {
+			pairs[i].key(w)
+			pairs[i].value(w)
+		}
+	}
+}
+
+// WriteArrayFromFuncs writes an array with one element per function, each
+// written by calling it.
+func WriteArrayFromFuncs(w Writer, elems ...func(Writer)) {
+	w.WriteArraySize(uint32(len(elems)))
+	for _, elem := range elems {
+		elem(w)
+	}
+}
@@ -0,0 +1,72 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestWriteMapFromPairs(t *testing.T) {
+	for _, withEmail := range []bool{false, true} {
+		email := "ada@example.com"
+		actual := encodeWith(t, func(w msgpack.Writer) {
+			msgpack.WriteMapFromPairs(w,
+				msgpack.Pair("name", func(w msgpack.Writer) { w.WriteString("Ada") }),
+				msgpack.PairIf(withEmail, "email", func(w msgpack.Writer) { w.WriteString(email) }),
+				msgpack.PairFunc(
+					func(w msgpack.Writer) { w.WriteInt64(7) },
+					func(w msgpack.Writer) { w.WriteBool(true) }),
+			)
+		})
+		expected := encodeWith(t, func(w msgpack.Writer) {
+			n := uint32(2)
+			if withEmail {
+				n++
+			}
+			w.WriteMapSize(n)
+			w.WriteString("name")
+			w.WriteString("Ada")
+			if withEmail {
+				w.WriteString("email")
+				w.WriteString(email)
+			}
+			w.WriteInt64(7)
+			w.WriteBool(true)
+		})
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestWriteMapFromPairsEmpty(t *testing.T) {
+	actual := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteMapFromPairs(w,
+			msgpack.PairIf(false, "a", func(w msgpack.Writer) { t.Fatal("value written for a skipped pair") }),
+			msgpack.PairIf(false, "b", func(w msgpack.Writer) { t.Fatal("value written for a skipped pair") }),
+		)
+	})
+	assert.Equal(t, []byte{0x80}, actual)
+	assert.Equal(t, []byte{0x80}, encodeWith(t, func(w msgpack.Writer) { msgpack.WriteMapFromPairs(w) }))
+}
+
+func TestWriteArrayFromFuncs(t *testing.T) {
+	actual := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteArrayFromFuncs(w,
+			func(w msgpack.Writer) { w.WriteInt64(1) },
+			func(w msgpack.Writer) {
+				msgpack.WriteArrayFromFuncs(w, func(w msgpack.Writer) { w.WriteString("nested") })
+			},
+			msgpack.Writer.WriteNil,
+		)
+	})
+	expected := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(3)
+		w.WriteInt64(1)
+		w.WriteArraySize(1)
+		w.WriteString("nested")
+		w.WriteNil()
+	})
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, []byte{0x90}, encodeWith(t, func(w msgpack.Writer) { msgpack.WriteArrayFromFuncs(w) }))
+}
@@ -0,0 +1,392 @@
+package msgpack
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Patch operations, the values of the "op" key of an operation.
+const (
+	PatchAdd     = "add"
+	PatchReplace = "replace"
+	PatchRemove  = "remove"
+)
+
+// PatchError reports the operation ApplyPatch failed on.
+type PatchError struct {
+	// Index is the position of the operation in the patch.
+	Index int
+	// Err is a PathError naming the path segment that did not resolve, or
+	// describes why the operation is malformed.
+	Err error
+}
+
+func (e PatchError) Error() string {
+	return "msgpack: patch operation " + strconv.Itoa(e.Index) + ": " + e.Err.Error()
+}
+
+func (e PatchError) Unwrap() error {
+	return e.Err
+}
+
+// patchOp is one operation of a patch.
+type patchOp struct {
+	op    string
+	path  []any
+	value Raw
+}
+
+// ApplyPatch applies the operations of `patch` to `doc` in order and
+// returns the patched document. A patch is an array of operation maps, in
+// the manner of JSON Patch:
+//
+//	{"op": "add", "path": ["limits", "rps"], "value": 100}
+//	{"op": "replace", "path": ["hosts", 0], "value": "a.example.com"}
+//	{"op": "remove", "path": ["debug"]}
+//
+// A path is an array of string map keys and integer array indexes, and
+// the value is written in place, as any other value. "add" sets a map key,
+// whether or not it exists, or inserts into an array before the index,
+// which may be the length of the array to append. "replace" and "remove"
+// need the target to exist. An empty path is the whole document.
+//
+// Only the maps and arrays on the path of an operation are written again;
+// every other value, including the operation values, is copied byte for
+// byte. A failing operation is reported as a PatchError with its index,
+// wrapping a PathError when the path does not resolve. A nil patch has no
+// operations.
+func ApplyPatch(doc Raw, patch Raw) (Raw, error) {
+	if err := Validate(doc); err != nil {
+		return nil, err
+	}
+	ops, err := readPatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ops {
+		if doc, err = patchValue(doc, &ops[i], 0); err != nil {
+			return nil, PatchError{i, err}
+		}
+	}
+	return doc, nil
+}
+
+// readPatch reads the operations of `patch`.
+func readPatch(patch Raw) ([]patchOp, error) {
+	decoder := NewDecoder(patch)
+	isNil, err := decoder.IsNextNil()
+	if err != nil || isNil {
+		return nil, err
+	}
+	size, err := decoder.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]patchOp, 0, sizeHint(&decoder, size))
+	for i := 0; i < int(size); i++ {
+		op, err := readPatchOp(&decoder)
+		if err != nil {
+			return nil, PatchError{i, err}
+		}
+		ops = append(ops, op)
+	}
+	if decoder.Remaining() != 0 {
+		return nil, ReadError{"msgpack: patch has trailing data at offset " +
+			strconv.FormatUint(uint64(decoder.Offset()), 10)}
+	}
+	return ops, nil
+}
+
+func readPatchOp(d *Decoder) (patchOp, error) {
+	var (
+		op       patchOp
+		hasPath  bool
+		hasValue bool
+	)
+	size, err := d.ReadMapSize()
+	if err != nil {
+		return op, err
+	}
+	for i := uint32(0); i < size; i++ {
+		key, err := readStringKey(d)
+		if err != nil {
+			return op, err
+		}
+		switch key {
+		case "op":
+			op.op, err = d.ReadString()
+		case "path":
+			op.path, err = readPatchPath(d)
+			hasPath = err == nil
+		case "value":
+			op.value, err = d.ReadRaw()
+			hasValue = true
+		default:
+			err = d.Skip()
+		}
+		if err != nil {
+			return op, err
+		}
+	}
+	switch op.op {
+	case PatchAdd, PatchReplace:
+		if !hasValue {
+			return op, ReadError{"msgpack: " + op.op + " operation has no value"}
+		}
+	case PatchRemove:
+	default:
+		return op, ReadError{"msgpack: unknown patch op " + strconv.Quote(op.op)}
+	}
+	if !hasPath {
+		return op, ReadError{"msgpack: " + op.op + " operation has no path"}
+	}
+	return op, nil
+}
+
+// readPatchPath reads a path of string and int segments.
+func readPatchPath(d *Decoder) ([]any, error) {
+	size, err := d.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	path := make([]any, 0, sizeHint(d, size))
+	for i := 0; i < int(size); i++ {
+		prefix, err := d.PeekFormat()
+		if err != nil {
+			return nil, err
+		}
+		var segment any
+		switch formatKind(prefix) {
+		case "string":
+			segment, err = d.ReadString()
+		case "int":
+			var index int64
+			index, err = d.ReadInt64()
+			segment = int(index)
+		default:
+			return nil, ReadError{"msgpack: path segment " + strconv.Itoa(i) + " is " +
+				formatKind(prefix) + ", not a string or int"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, segment)
+	}
+	return path, nil
+}
+
+// patchValue applies `op` to `doc`, the value found at op.path[:depth].
+func patchValue(doc Raw, op *patchOp, depth int) (Raw, error) {
+	if depth == len(op.path) {
+		if op.op == PatchRemove {
+			return nil, ReadError{"msgpack: cannot remove the whole document"}
+		}
+		return op.value, nil
+	}
+	resolved, segment := op.path[:depth], op.path[depth]
+	last := depth == len(op.path)-1
+	kind := formatKind(doc[0])
+	switch segment := segment.(type) {
+	case string:
+		if kind != "map" {
+			return nil, PathError{resolved, segment, ReadError{"cannot look up a key in " + kind}}
+		}
+		entries, err := readRawMap(doc, "document")
+		if err != nil {
+			return nil, err
+		}
+		found := -1
+		for i, e := range entries {
+			if e.id == "s"+segment {
+				found = i
+			}
+		}
+		switch {
+		case last && op.op == PatchAdd && found < 0:
+			key, err := encodeRaw(func(w Writer) { w.WriteString(segment) })
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, rawEntry{key: key, value: op.value})
+		case found < 0:
+			return nil, PathError{resolved, segment, ErrNotFound}
+		case last && op.op == PatchRemove:
+			entries = append(entries[:found], entries[found+1:]...)
+		default:
+			if entries[found].value, err = patchValue(entries[found].value, op, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return encodeRaw(func(w Writer) { writeRawEntries(w, entries) })
+	case int:
+		if kind != "array" {
+			return nil, PathError{resolved, segment, ReadError{"cannot index " + kind}}
+		}
+		elements, err := readRawArray(doc)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case last && op.op == PatchAdd && segment >= 0 && segment <= len(elements):
+			elements = append(elements, nil)
+			copy(elements[segment+1:], elements[segment:])
+			elements[segment] = op.value
+		case segment < 0 || segment >= len(elements):
+			return nil, PathError{resolved, segment, ErrNotFound}
+		case last && op.op == PatchRemove:
+			elements = append(elements[:segment], elements[segment+1:]...)
+		default:
+			if elements[segment], err = patchValue(elements[segment], op, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return encodeRaw(func(w Writer) {
+			w.WriteArraySize(uint32(len(elements)))
+			for _, e := range elements {
+				w.WriteRaw(e)
+			}
+		})
+	}
+	return nil, PathError{resolved, segment, ReadError{"path segments must be string or int"}}
+}
+
+// readRawArray splits an encoded array into its elements.
+func readRawArray(data Raw) ([]Raw, error) {
+	decoder := NewDecoder(data)
+	size, err := decoder.ReadArraySize()
+	if err != nil {
+		return nil, err
+	}
+	elements := make([]Raw, size)
+	for i := range elements {
+		if elements[i], err = decoder.ReadRaw(); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+// DiffToPatch returns a patch with which ApplyPatch turns `before` into a
+// document that decodes to the same values as `after`. Maps with string
+// keys are compared key by key, so that the patch only holds the entries
+// that were added, removed or changed. Any other value that differs,
+// including an array, is replaced whole. Values are compared as Diff
+// does, so a value only written in another format is left alone.
+func DiffToPatch(before, after Raw) (Raw, error) {
+	for _, doc := range []Raw{before, after} {
+		if err := Validate(doc); err != nil {
+			return nil, err
+		}
+	}
+	var ops []patchOp
+	if err := diffToPatch(before, after, nil, &ops); err != nil {
+		return nil, err
+	}
+	return encodeRaw(func(w Writer) {
+		w.WriteArraySize(uint32(len(ops)))
+		for _, op := range ops {
+			writePatchOp(w, op)
+		}
+	})
+}
+
+func diffToPatch(before, after Raw, path []any, ops *[]patchOp) error {
+	if bytes.Equal(before, after) {
+		return nil
+	}
+	if isRawMap(before) && isRawMap(after) {
+		beforeEntries, err := readRawMap(before, "before")
+		if err != nil {
+			return err
+		}
+		afterEntries, err := readRawMap(after, "after")
+		if err != nil {
+			return err
+		}
+		if hasStringKeys(beforeEntries) && hasStringKeys(afterEntries) {
+			return diffMapToPatch(beforeEntries, afterEntries, path, ops)
+		}
+	}
+	diffs, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+	if !SemanticallyEqual(diffs) {
+		*ops = append(*ops, patchOp{op: PatchReplace, path: copyPath(path), value: after})
+	}
+	return nil
+}
+
+func diffMapToPatch(beforeEntries, afterEntries []rawEntry, path []any, ops *[]patchOp) error {
+	afterIndex := make(map[string]int, len(afterEntries))
+	for i, e := range afterEntries {
+		afterIndex[e.id] = i
+	}
+	beforeIndex := make(map[string]struct{}, len(beforeEntries))
+	for _, e := range beforeEntries {
+		beforeIndex[e.id] = struct{}{}
+		// The ids of string keys are the string after an "s".
+		keyPath := append(path, e.id[1:])
+		i, ok := afterIndex[e.id]
+		if !ok {
+			*ops = append(*ops, patchOp{op: PatchRemove, path: copyPath(keyPath)})
+			continue
+		}
+		if err := diffToPatch(e.value, afterEntries[i].value, keyPath, ops); err != nil {
+			return err
+		}
+	}
+	for _, e := range afterEntries {
+		if _, ok := beforeIndex[e.id]; !ok {
+			*ops = append(*ops, patchOp{op: PatchAdd, path: copyPath(append(path, e.id[1:])), value: e.value})
+		}
+	}
+	return nil
+}
+
+// hasStringKeys reports whether every key of `entries` is a string, so
+// that a path can name it.
+func hasStringKeys(entries []rawEntry) bool {
+	for _, e := range entries {
+		if e.id[0] != 's' {
+			return false
+		}
+	}
+	return true
+}
+
+func writePatchOp(w Writer, op patchOp) {
+	size := uint32(2)
+	if op.op != PatchRemove {
+		size++
+	}
+	w.WriteMapSize(size)
+	w.WriteString("op")
+	w.WriteString(op.op)
+	w.WriteString("path")
+	w.WriteArraySize(uint32(len(op.path)))
+	for _, segment := range op.path {
+		switch segment := segment.(type) {
+		case string:
+			w.WriteString(segment)
+		case int:
+			w.WriteInt64(int64(segment))
+		}
+	}
+	if op.op != PatchRemove {
+		w.WriteString("value")
+		w.WriteRaw(op.value)
+	}
+}
+
+// encodeRaw returns what `write` writes.
+func encodeRaw(write func(w Writer)) (Raw, error) {
+	var sizer Sizer
+	write(&sizer)
+	encoder := NewEncoder(make([]byte, sizer.Len()))
+	write(&encoder)
+	if err := encoder.Err(); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
@@ -0,0 +1,375 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/msgpacktest"
+)
+
+// patchOp writes one operation of a patch. A nil value is left out.
+func patchOp(w msgpack.Writer, op string, value msgpack.Raw, path ...any) {
+	size := uint32(2)
+	if value != nil {
+		size++
+	}
+	w.WriteMapSize(size)
+	w.WriteString("op")
+	w.WriteString(op)
+	w.WriteString("path")
+	w.WriteAny(path)
+	if value != nil {
+		w.WriteString("value")
+		w.WriteRaw(value)
+	}
+}
+
+func anyRaw(t *testing.T, v any) msgpack.Raw {
+	return encodeWith(t, func(w msgpack.Writer) { w.WriteAny(v) })
+}
+
+// assertSameValues checks that `a` and `b` decode to the same values.
+func assertSameValues(t *testing.T, a, b []byte) {
+	t.Helper()
+	diffs, err := msgpack.Diff(a, b)
+	require.NoError(t, err)
+	assert.True(t, msgpack.SemanticallyEqual(diffs), "%v", diffs)
+}
+
+func configDoc(t *testing.T) msgpack.Raw {
+	return encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("name")
+		w.WriteString("edge")
+		w.WriteString("limits")
+		w.WriteMapSize(2)
+		w.WriteString("rps")
+		// A uint16 where a fixint would do, which a patch keeps.
+		w.WriteRaw(msgpack.Raw{msgpack.FormatUint16, 0, 100})
+		w.WriteString("burst")
+		w.WriteFloat32(1.5)
+		w.WriteString("hosts")
+		w.WriteArraySize(2)
+		w.WriteString("a")
+		w.WriteString("b")
+	})
+}
+
+func TestApplyPatch(t *testing.T) {
+	doc := configDoc(t)
+	patch := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(7)
+		patchOp(w, msgpack.PatchAdd, anyRaw(t, true), "debug")
+		patchOp(w, msgpack.PatchReplace, anyRaw(t, "core"), "name")
+		patchOp(w, msgpack.PatchAdd, anyRaw(t, int64(10)), "limits", "conns")
+		patchOp(w, msgpack.PatchRemove, nil, "limits", "burst")
+		patchOp(w, msgpack.PatchAdd, anyRaw(t, "z"), "hosts", 0)
+		patchOp(w, msgpack.PatchAdd, anyRaw(t, "c"), "hosts", 3)
+		patchOp(w, msgpack.PatchRemove, nil, "hosts", 2)
+	})
+	patched, err := msgpack.ApplyPatch(doc, patch)
+	require.NoError(t, err)
+	assertSameValues(t, anyRaw(t, map[string]any{
+		"name":   "core",
+		"limits": map[string]any{"rps": int64(100), "conns": int64(10)},
+		"hosts":  []any{"z", "a", "c"},
+		"debug":  true,
+	}), patched)
+	// Untouched values keep their formats.
+	assert.True(t, bytes.Contains(patched, []byte{0xa3, 'r', 'p', 's', msgpack.FormatUint16, 0, 100}), "% x", patched)
+
+	// add sets an existing key, and an empty path is the whole document.
+	patch = encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(1)
+		patchOp(w, msgpack.PatchAdd, anyRaw(t, "x"), "name")
+	})
+	patched, err = msgpack.ApplyPatch(doc, patch)
+	require.NoError(t, err)
+	name, err := msgpack.GetString(patched, "name")
+	require.NoError(t, err)
+	assert.Equal(t, "x", name)
+
+	patch = encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(1)
+		patchOp(w, msgpack.PatchReplace, anyRaw(t, int64(7)))
+	})
+	patched, err = msgpack.ApplyPatch(doc, patch)
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.Raw{0x07}, patched)
+
+	// No operations leave the document as it is.
+	for _, empty := range []msgpack.Raw{{0x90}, {msgpack.FormatNil}} {
+		patched, err = msgpack.ApplyPatch(doc, empty)
+		require.NoError(t, err)
+		assert.Equal(t, doc, patched)
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	doc := configDoc(t)
+	for name, tc := range map[string]struct {
+		op       func(w msgpack.Writer)
+		message  string
+		resolved []any
+		segment  any
+	}{
+		"missing key": {
+			op:       func(w msgpack.Writer) { patchOp(w, msgpack.PatchReplace, anyRaw(t, 1), "limits", "conns") },
+			message:  `msgpack: patch operation 1: msgpack: path $.limits: key "conns": not found`,
+			resolved: []any{"limits"},
+			segment:  "conns",
+		},
+		"remove missing key": {
+			op:       func(w msgpack.Writer) { patchOp(w, msgpack.PatchRemove, nil, "verbose") },
+			message:  `msgpack: patch operation 1: msgpack: path $: key "verbose": not found`,
+			resolved: []any{},
+			segment:  "verbose",
+		},
+		"missing parent": {
+			op:       func(w msgpack.Writer) { patchOp(w, msgpack.PatchAdd, anyRaw(t, 1), "tls", "port") },
+			message:  `msgpack: patch operation 1: msgpack: path $: key "tls": not found`,
+			resolved: []any{},
+			segment:  "tls",
+		},
+		"index out of range": {
+			op:       func(w msgpack.Writer) { patchOp(w, msgpack.PatchAdd, anyRaw(t, "c"), "hosts", 3) },
+			message:  `msgpack: patch operation 1: msgpack: path $.hosts: index 3: not found`,
+			resolved: []any{"hosts"},
+			segment:  3,
+		},
+		"key in an array": {
+			op:       func(w msgpack.Writer) { patchOp(w, msgpack.PatchRemove, nil, "hosts", "a") },
+			message:  `msgpack: patch operation 1: msgpack: path $.hosts: key "a": cannot look up a key in array`,
+			resolved: []any{"hosts"},
+			segment:  "a",
+		},
+		"index into a string": {
+			op:       func(w msgpack.Writer) { patchOp(w, msgpack.PatchRemove, nil, "name", 0) },
+			message:  `msgpack: patch operation 1: msgpack: path $.name: index 0: cannot index string`,
+			resolved: []any{"name"},
+			segment:  0,
+		},
+		"remove the document": {
+			op:      func(w msgpack.Writer) { patchOp(w, msgpack.PatchRemove, nil) },
+			message: "msgpack: patch operation 1: msgpack: cannot remove the whole document",
+		},
+		"unknown op": {
+			op:      func(w msgpack.Writer) { patchOp(w, "move", nil, "name") },
+			message: `msgpack: patch operation 1: msgpack: unknown patch op "move"`,
+		},
+		"no value": {
+			op:      func(w msgpack.Writer) { patchOp(w, msgpack.PatchAdd, nil, "name") },
+			message: "msgpack: patch operation 1: msgpack: add operation has no value",
+		},
+		"no path": {
+			op: func(w msgpack.Writer) {
+				w.WriteMapSize(1)
+				w.WriteString("op")
+				w.WriteString(msgpack.PatchRemove)
+			},
+			message: "msgpack: patch operation 1: msgpack: remove operation has no path",
+		},
+		"bad path segment": {
+			op:      func(w msgpack.Writer) { patchOp(w, msgpack.PatchRemove, nil, true) },
+			message: "msgpack: patch operation 1: msgpack: path segment 0 is bool, not a string or int",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// The first operation applies, and the second fails.
+			patch := encodeWith(t, func(w msgpack.Writer) {
+				w.WriteArraySize(2)
+				patchOp(w, msgpack.PatchAdd, anyRaw(t, true), "debug")
+				tc.op(w)
+			})
+			patched, err := msgpack.ApplyPatch(doc, patch)
+			assert.Nil(t, patched)
+			assert.EqualError(t, err, tc.message)
+			var patchErr msgpack.PatchError
+			require.ErrorAs(t, err, &patchErr)
+			assert.Equal(t, 1, patchErr.Index)
+			var pathErr msgpack.PathError
+			if tc.segment == nil {
+				assert.False(t, errors.As(err, &pathErr))
+				return
+			}
+			require.ErrorAs(t, err, &pathErr)
+			assert.Equal(t, tc.resolved, pathErr.Resolved)
+			assert.Equal(t, tc.segment, pathErr.Segment)
+		})
+	}
+}
+
+func TestApplyPatchTruncatedHeaders(t *testing.T) {
+	// Sizes read from the patch fail without allocating for them.
+	for name, patch := range map[string]msgpack.Raw{
+		"array32 of operations": {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"map32 operation":       {0x91, 0xdf, 0xff, 0xff, 0xff, 0xff},
+		"array32 path":          {0x91, 0x81, 0xa4, 'p', 'a', 't', 'h', 0xdd, 0xff, 0xff, 0xff, 0xff},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := msgpack.ApplyPatch(msgpack.Raw{0x80}, patch)
+			assert.ErrorIs(t, err, msgpack.ErrRange)
+		})
+	}
+}
+
+func TestDiffToPatch(t *testing.T) {
+	before := configDoc(t)
+	after := anyRaw(t, map[string]any{
+		"name":   "edge",
+		"limits": map[string]any{"rps": int64(200), "burst": 1.5, "conns": int64(10)},
+		"hosts":  []any{"a", "b", "c"},
+	})
+	patch, err := msgpack.DiffToPatch(before, after)
+	require.NoError(t, err)
+
+	// Only what changed is in the patch; the array is replaced whole, and
+	// 1.5 written as a float64 is the same value.
+	assertSameValues(t, encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(3)
+		patchOp(w, msgpack.PatchReplace, anyRaw(t, int64(200)), "limits", "rps")
+		patchOp(w, msgpack.PatchAdd, anyRaw(t, int64(10)), "limits", "conns")
+		patchOp(w, msgpack.PatchReplace, anyRaw(t, []any{"a", "b", "c"}), "hosts")
+	}), patch)
+
+	patched, err := msgpack.ApplyPatch(before, patch)
+	require.NoError(t, err)
+	assertSameValues(t, after, patched)
+
+	// Equal documents need no operations.
+	patch, err = msgpack.DiffToPatch(before, before)
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.Raw{0x90}, patch)
+
+	// A map with other keys than strings is replaced whole.
+	before = anyRaw(t, map[any]any{int64(1): "a"})
+	after = anyRaw(t, map[any]any{int64(1): "b"})
+	patch, err = msgpack.DiffToPatch(before, after)
+	require.NoError(t, err)
+	assert.Equal(t, encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(1)
+		patchOp(w, msgpack.PatchReplace, after)
+	}), []byte(patch))
+
+	_, err = msgpack.DiffToPatch(before, after[:2])
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
+
+// mutate returns a copy of `v` with some map entries added, removed or
+// changed, at any depth, and some other values replaced.
+func mutate(rng *rand.Rand, g *msgpacktest.Generator, v any) any {
+	m, ok := v.(map[string]any)
+	if !ok {
+		if rng.Intn(4) == 0 {
+			return g.Next()
+		}
+		return v
+	}
+	mutated := make(map[string]any, len(m))
+	for k, value := range m {
+		switch rng.Intn(6) {
+		case 0:
+			// Removed.
+		case 1:
+			mutated[k] = g.Next()
+		default:
+			mutated[k] = mutate(rng, g, value)
+		}
+	}
+	for i := rng.Intn(3); i > 0; i-- {
+		mutated["new"+strconv.Itoa(rng.Intn(100))] = g.Next()
+	}
+	return mutated
+}
+
+// nestedMaps wraps generated values in maps with string keys, which are
+// what DiffToPatch diffs key by key.
+func nestedMaps(rng *rand.Rand, g *msgpacktest.Generator, depth int) any {
+	if depth == 0 || rng.Intn(4) == 0 {
+		return g.Next()
+	}
+	m := make(map[string]any)
+	for i := rng.Intn(6); i > 0; i-- {
+		m["k"+strconv.Itoa(rng.Intn(10))] = nestedMaps(rng, g, depth-1)
+	}
+	return m
+}
+
+// TestPatchRoundTripProperty checks that the patch DiffToPatch makes
+// between generated documents turns the first into the second.
+func TestPatchRoundTripProperty(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	g := msgpacktest.NewGenerator(1, msgpacktest.WithTimes(), msgpacktest.WithExt(), msgpacktest.WithMaxDepth(2))
+	for i := 0; i < 500; i++ {
+		before := nestedMaps(rng, g, 4)
+		after := mutate(rng, g, before)
+		if i%10 == 0 {
+			after = nestedMaps(rng, g, 4)
+		}
+		beforeRaw, afterRaw := anyRaw(t, before), anyRaw(t, after)
+		patch, err := msgpack.DiffToPatch(beforeRaw, afterRaw)
+		require.NoError(t, err)
+		require.NoError(t, msgpack.Validate(patch))
+		patched, err := msgpack.ApplyPatch(beforeRaw, patch)
+		require.NoError(t, err, "% x\n% x", beforeRaw, patch)
+		diffs, err := msgpack.Diff(afterRaw, patched)
+		require.NoError(t, err)
+		require.True(t, msgpack.SemanticallyEqual(diffs), "%v\n% x\n% x", diffs, beforeRaw, patch)
+	}
+}
+
+// TestPatchSize compares patches with the documents they update for
+// changes typical of a service configuration.
+func TestPatchSize(t *testing.T) {
+	newConfig := func() map[string]any {
+		return map[string]any{
+			"service": map[string]any{"name": "ingest", "version": "1.42.0", "region": "eu-west-1"},
+			"listen":  map[string]any{"host": "0.0.0.0", "port": int64(8443), "tls": true},
+			"limits": map[string]any{
+				"rps": int64(500), "burst": int64(1000), "maxBody": int64(1 << 20), "timeoutMs": int64(3000),
+			},
+			"upstreams": []any{"10.0.0.11:9000", "10.0.0.12:9000", "10.0.0.13:9000"},
+			"features":  map[string]any{"compression": true, "tracing": false, "rateLimit": true, "cache": true},
+			"logging":   map[string]any{"level": "info", "format": "json", "sample": 0.1},
+			"metadata":  map[string]any{"owner": "platform", "tier": "gold", "updated": "2024-05-01T12:00:00Z"},
+		}
+	}
+	updated := func(update func(c map[string]any)) map[string]any {
+		c := newConfig()
+		update(c)
+		return c
+	}
+	for name, after := range map[string]map[string]any{
+		"one setting": updated(func(c map[string]any) {
+			c["limits"].(map[string]any)["rps"] = int64(800)
+		}),
+		"feature flags": updated(func(c map[string]any) {
+			features := c["features"].(map[string]any)
+			features["tracing"] = true
+			features["canary"] = true
+			delete(features, "cache")
+		}),
+		"release": updated(func(c map[string]any) {
+			c["service"].(map[string]any)["version"] = "1.43.0"
+			c["metadata"].(map[string]any)["updated"] = "2024-05-08T12:00:00Z"
+			c["logging"].(map[string]any)["level"] = "debug"
+		}),
+	} {
+		before, afterRaw := anyRaw(t, newConfig()), anyRaw(t, after)
+		patch, err := msgpack.DiffToPatch(before, afterRaw)
+		require.NoError(t, err)
+		t.Logf("%s: patch of %d bytes for a document of %d bytes", name, len(patch), len(afterRaw))
+		assert.Less(t, len(patch)*2, len(afterRaw), name)
+
+		patched, err := msgpack.ApplyPatch(before, patch)
+		require.NoError(t, err)
+		assertSameValues(t, afterRaw, patched)
+	}
+}
@@ -0,0 +1,188 @@
+package msgpack
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrNotFound is returned, wrapped in a PathError, when a map key or array
+// index in a path does not exist.
+var ErrNotFound = errors.New("not found")
+
+// PathError reports why GetPath could not resolve a path.
+type PathError struct {
+	// Resolved is the leading part of the path that was found.
+	Resolved []any
+	// Segment is the path segment that could not be resolved.
+	Segment any
+	// Err describes why Segment could not be resolved.
+	Err error
+}
+
+func (e PathError) Error() string {
+	var segment string
+	switch v := e.Segment.(type) {
+	case string:
+		segment = "key " + strconv.Quote(v)
+	case int:
+		segment = "index " + strconv.Itoa(v)
+	default:
+		segment = "segment"
+	}
+	return "msgpack: path " + formatPath(e.Resolved) + ": " + segment + ": " + e.Err.Error()
+}
+
+func (e PathError) Unwrap() error {
+	return e.Err
+}
+
+// GetPath returns the encoded value found by following `path` through
+// `data`. Each segment is either a string map key or an int array index.
+// Entries that do not match are skipped over without being decoded, so
+// extracting one field is much cheaper than decoding the document. Map keys
+// that are not strings never match.
+//
+// A missing key or out of range index returns a PathError wrapping
+// ErrNotFound. Indexing into a value of the wrong kind returns a PathError
+// describing the mismatch.
+func GetPath(data []byte, path ...any) (Raw, error) {
+	d := NewDecoder(data)
+	for i, segment := range path {
+		prefix, err := d.PeekFormat()
+		if err != nil {
+			return nil, err
+		}
+		switch key := segment.(type) {
+		case string:
+			if !isFixedMap(prefix) && prefix != FormatMap16 && prefix != FormatMap32 {
+				return nil, PathError{path[:i], segment, ReadError{"cannot look up a key in " + formatKind(prefix)}}
+			}
+			found, err := seekMapKey(&d, key)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				return nil, PathError{path[:i], segment, ErrNotFound}
+			}
+		case int:
+			if !isFixedArray(prefix) && prefix != FormatArray16 && prefix != FormatArray32 {
+				return nil, PathError{path[:i], segment, ReadError{"cannot index " + formatKind(prefix)}}
+			}
+			size, err := d.ReadArraySize()
+			if err != nil {
+				return nil, err
+			}
+			if key < 0 || uint64(key) >= uint64(size) {
+				return nil, PathError{path[:i], segment, ErrNotFound}
+			}
+			for j := 0; j < key; j++ {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, PathError{path[:i], segment, ReadError{"path segments must be string or int"}}
+		}
+	}
+	return d.ReadRaw()
+}
+
+// GetString returns the string found by following `path` through `data`.
+func GetString(data []byte, path ...any) (string, error) {
+	raw, err := GetPath(data, path...)
+	if err != nil {
+		return "", err
+	}
+	d := NewDecoder(raw)
+	return d.ReadString()
+}
+
+// GetInt64 returns the integer found by following `path` through `data`.
+func GetInt64(data []byte, path ...any) (int64, error) {
+	raw, err := GetPath(data, path...)
+	if err != nil {
+		return 0, err
+	}
+	d := NewDecoder(raw)
+	return d.ReadInt64()
+}
+
+// seekMapKey reads the map header and entries up to the value for `key`,
+// leaving the decoder positioned at that value.
+func seekMapKey(d *Decoder, key string) (bool, error) {
+	size, err := d.ReadMapSize()
+	if err != nil {
+		return false, err
+	}
+	for i := uint32(0); i < size; i++ {
+		prefix, err := d.PeekFormat()
+		if err != nil {
+			return false, err
+		}
+		if isFixedString(prefix) || prefix == FormatString8 || prefix == FormatString16 || prefix == FormatString32 {
+			k, err := d.ReadStringBytes()
+			if err != nil {
+				return false, err
+			}
+			if string(k) == key {
+				return true, nil
+			}
+		} else if err := d.Skip(); err != nil {
+			return false, err
+		}
+		if err := d.Skip(); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func formatPath(path []any) string {
+	s := "$"
+	for _, segment := range path {
+		switch v := segment.(type) {
+		case string:
+			s += "." + v
+		case int:
+			s += "[" + strconv.Itoa(v) + "]"
+		}
+	}
+	return s
+}
+
+// formatKind names the kind of value that starts with `prefix`.
+func formatKind(prefix byte) string {
+	switch {
+	case isFixedInt(prefix) || isNegativeFixedInt(prefix):
+		return "int"
+	case isFixedString(prefix):
+		return "string"
+	case isFixedArray(prefix):
+		return "array"
+	case isFixedMap(prefix):
+		return "map"
+	}
+	switch prefix {
+	case FormatNil:
+		return "nil"
+	case FormatTrue, FormatFalse:
+		return "bool"
+	case FormatInt8, FormatInt16, FormatInt32, FormatInt64,
+		FormatUint8, FormatUint16, FormatUint32, FormatUint64:
+		return "int"
+	case FormatFloat32, FormatFloat64:
+		return "float"
+	case FormatString8, FormatString16, FormatString32:
+		return "string"
+	case FormatBin8, FormatBin16, FormatBin32:
+		return "bin"
+	case FormatArray16, FormatArray32:
+		return "array"
+	case FormatMap16, FormatMap32:
+		return "map"
+	case FormatFixExt1, FormatFixExt2, FormatFixExt4, FormatFixExt8, FormatFixExt16,
+		FormatExt8, FormatExt16, FormatExt32:
+		return "ext"
+	}
+	return "invalid format 0x" + strconv.FormatUint(uint64(prefix), 16)
+}
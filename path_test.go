@@ -0,0 +1,114 @@
+package msgpack_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func pathDocument(t testing.TB) []byte {
+	var sizer msgpack.Sizer
+	writePathDocument(&sizer)
+	buffer := make([]byte, sizer.Len())
+	encoder := msgpack.NewEncoder(buffer)
+	writePathDocument(&encoder)
+	require.NoError(t, encoder.Err())
+	return buffer
+}
+
+func writePathDocument(w msgpack.Writer) {
+	w.WriteMapSize(102)
+	for i := 0; i < 100; i++ {
+		w.WriteString("field_" + strconv.Itoa(i))
+		w.WriteAny(map[string]any{"n": int64(i), "s": "value " + strconv.Itoa(i)})
+	}
+	w.WriteInt64(7)
+	w.WriteString("integer key")
+	w.WriteString("tenant")
+	w.WriteMapSize(2)
+	w.WriteString("id")
+	w.WriteString("acme")
+	w.WriteString("users")
+	w.WriteAny([]any{"ann", "bob", map[string]any{"age": int64(30)}})
+}
+
+func TestGetPath(t *testing.T) {
+	data := pathDocument(t)
+
+	raw, err := msgpack.GetPath(data, "field_42")
+	require.NoError(t, err)
+	decoder := msgpack.NewDecoder(raw)
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, map[any]any{"n": int64(42), "s": "value 42"}, v)
+
+	tenant, err := msgpack.GetString(data, "tenant", "id")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tenant)
+
+	user, err := msgpack.GetString(data, "tenant", "users", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", user)
+
+	age, err := msgpack.GetInt64(data, "tenant", "users", 2, "age")
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), age)
+
+	raw, err = msgpack.GetPath(data)
+	require.NoError(t, err)
+	assert.Equal(t, data, []byte(raw))
+}
+
+func TestGetPathErrors(t *testing.T) {
+	data := pathDocument(t)
+
+	_, err := msgpack.GetPath(data, "tenant", "name")
+	assert.True(t, errors.Is(err, msgpack.ErrNotFound))
+	assert.EqualError(t, err, `msgpack: path $.tenant: key "name": not found`)
+
+	_, err = msgpack.GetPath(data, "tenant", "users", 3)
+	assert.True(t, errors.Is(err, msgpack.ErrNotFound))
+	assert.EqualError(t, err, "msgpack: path $.tenant.users: index 3: not found")
+
+	_, err = msgpack.GetPath(data, "tenant", "id", "x")
+	assert.False(t, errors.Is(err, msgpack.ErrNotFound))
+	var pathErr msgpack.PathError
+	require.True(t, errors.As(err, &pathErr))
+	assert.Equal(t, []any{"tenant", "id"}, pathErr.Resolved)
+	assert.EqualError(t, err, `msgpack: path $.tenant.id: key "x": cannot look up a key in string`)
+
+	_, err = msgpack.GetPath(data, "field_1", 0)
+	assert.EqualError(t, err, "msgpack: path $.field_1: index 0: cannot index map")
+
+	_, err = msgpack.GetPath(data, 1.5)
+	assert.EqualError(t, err, "msgpack: path $: segment: path segments must be string or int")
+
+	_, err = msgpack.GetPath(data[:len(data)-1], "tenant", "users", 2, "age")
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
+
+func BenchmarkGetPath(b *testing.B) {
+	data := pathDocument(b)
+	b.Run("GetPath", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := msgpack.GetString(data, "tenant", "id"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ReadAny", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			decoder := msgpack.NewDecoder(data)
+			v, err := decoder.ReadAny()
+			if err != nil {
+				b.Fatal(err)
+			}
+			_ = v.(map[any]any)["tenant"].(map[any]any)["id"].(string)
+		}
+	})
+}
@@ -0,0 +1,480 @@
+package msgpack
+
+import (
+	"math"
+	"time"
+)
+
+// EncodePathError is returned by PathWriter.Err for an error of the
+// wrapped writer, with the path of the value being written when it
+// failed.
+type EncodePathError struct {
+	// Path locates the value, as in Difference.Path, such as
+	// `records[3127].attributes.geo`.
+	Path string
+	Err  error
+}
+
+func (e EncodePathError) Error() string {
+	return "msgpack: encoding " + e.Path + ": " + e.Err.Error()
+}
+
+func (e EncodePathError) Unwrap() error {
+	return e.Err
+}
+
+// PathWriter wraps a Writer and follows the arrays and maps written
+// through it, so that CurrentPath can say which value is being written and
+// Err can say where the wrapped writer failed:
+//
+//	pw := msgpack.NewPathWriter(&encoder)
+//	value.Encode(pw)
+//	if err := pw.Err(); err != nil {
+//		// msgpack: encoding records[3127].attributes.geo: range error ...
+//	}
+//
+// Array elements are named by their index and map values by their key.
+// Field names the next value explicitly, for arrays that hold the fields
+// of a struct. Values written with WriteRaw, WriteAny and the other
+// methods that write a whole tree are not looked into.
+//
+// The path is only built when it is asked for. Following the containers
+// costs a few nanoseconds per value and no allocations besides growing
+// the stack to the nesting depth, which makes writing through a
+// PathWriter to an Encoder about two to three times slower than writing
+// to the Encoder directly; see BenchmarkPathWriter.
+type PathWriter struct {
+	w       Writer
+	stack   []pathFrame
+	payload bool
+	err     error
+}
+
+var _ Writer = &PathWriter{}
+
+// pathFrame is an array or map being written, or the top level.
+type pathFrame struct {
+	isMap bool
+	// open is set for the top level and for containers whose length
+	// is only known when their reserved header is patched.
+	open bool
+	// remaining counts the elements left, keys and values separately.
+	remaining uint64
+	// index is the index of the current array element or map entry.
+	index uint32
+	// expectKey is set in maps when the next element is a key.
+	expectKey bool
+	key       pathKey
+	field     string
+}
+
+type pathKeyKind uint8
+
+const (
+	pathKeyNone pathKeyKind = iota
+	pathKeyString
+	pathKeyInt
+	pathKeyUint
+	pathKeyFloat
+	pathKeyBool
+)
+
+// pathKey holds the last map key written without boxing it.
+type pathKey struct {
+	kind pathKeyKind
+	s    string
+	bits uint64
+}
+
+// NewPathWriter wraps `w`.
+func NewPathWriter(w Writer) *PathWriter {
+	p := &PathWriter{w: w, stack: make([]pathFrame, 1, 8)}
+	p.stack[0].open = true
+	return p
+}
+
+// CurrentPath returns the path of the value being written, or of the
+// container when a map key is being written. It is "$" at the top level.
+func (p *PathWriter) CurrentPath() string {
+	return formatDiffPath(p.path())
+}
+
+func (p *PathWriter) path() []any {
+	var path []any
+	for i := range p.stack {
+		frame := &p.stack[i]
+		switch {
+		case frame.field != "":
+			path = append(path, frame.field)
+		case i == 0:
+		case !frame.isMap:
+			path = append(path, int(frame.index))
+		case !frame.expectKey:
+			path = append(path, frame.key.value())
+		}
+	}
+	return path
+}
+
+func (k pathKey) value() any {
+	switch k.kind {
+	case pathKeyString:
+		return k.s
+	case pathKeyInt:
+		return int64(k.bits)
+	case pathKeyUint:
+		return k.bits
+	case pathKeyFloat:
+		return math.Float64frombits(k.bits)
+	case pathKeyBool:
+		return k.bits != 0
+	}
+	return nil
+}
+
+// Field names the next value at the current level `name` in paths,
+// in place of its array index or map key.
+func (p *PathWriter) Field(name string) {
+	p.stack[len(p.stack)-1].field = name
+}
+
+// Err returns the error of the wrapped writer, in an EncodePathError with
+// the path of the value being written when it first failed.
+func (p *PathWriter) Err() error {
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Err()
+}
+
+// check records the path if the wrapped writer has just failed.
+func (p *PathWriter) check() {
+	if p.err != nil {
+		return
+	}
+	if err := p.w.Err(); err != nil {
+		p.err = EncodePathError{Path: p.CurrentPath(), Err: err}
+	}
+}
+
+// done finishes writing a value and moves on to the next one, closing the
+// containers that it completes.
+func (p *PathWriter) done() {
+	p.check()
+	for len(p.stack) > 1 {
+		frame := &p.stack[len(p.stack)-1]
+		p.next(frame)
+		if frame.open || frame.remaining > 0 {
+			return
+		}
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+	p.next(&p.stack[0])
+}
+
+func (p *PathWriter) next(frame *pathFrame) {
+	if !frame.open && frame.remaining > 0 {
+		frame.remaining--
+	}
+	if frame.isMap {
+		if frame.expectKey {
+			frame.expectKey = false
+			return
+		}
+		frame.expectKey = true
+		frame.key = pathKey{}
+	}
+	frame.index++
+	frame.field = ""
+}
+
+// push starts a container of `remaining` elements.
+func (p *PathWriter) push(isMap, open bool, remaining uint64) {
+	p.check()
+	p.stack = append(p.stack, pathFrame{isMap: isMap, open: open, remaining: remaining, expectKey: isMap})
+	if !open && remaining == 0 {
+		p.pop()
+	}
+}
+
+// pop ends the current container, which completes a value of its parent.
+func (p *PathWriter) pop() {
+	p.stack = p.stack[:len(p.stack)-1]
+	p.done()
+}
+
+// setKey records `key` if a map key is being written.
+func (p *PathWriter) setKey(kind pathKeyKind, s string, bits uint64) {
+	frame := &p.stack[len(p.stack)-1]
+	if frame.isMap && frame.expectKey {
+		frame.key = pathKey{kind: kind, s: s, bits: bits}
+	}
+}
+
+func (p *PathWriter) WriteNil() {
+	p.w.WriteNil()
+	p.done()
+}
+
+func (p *PathWriter) WriteBool(value bool) {
+	p.w.WriteBool(value)
+	var bits uint64
+	if value {
+		bits = 1
+	}
+	p.setKey(pathKeyBool, "", bits)
+	p.done()
+}
+
+func (p *PathWriter) WriteInt8(value int8) {
+	p.w.WriteInt8(value)
+	p.setKey(pathKeyInt, "", uint64(value))
+	p.done()
+}
+
+func (p *PathWriter) WriteInt16(value int16) {
+	p.w.WriteInt16(value)
+	p.setKey(pathKeyInt, "", uint64(value))
+	p.done()
+}
+
+func (p *PathWriter) WriteInt32(value int32) {
+	p.w.WriteInt32(value)
+	p.setKey(pathKeyInt, "", uint64(value))
+	p.done()
+}
+
+func (p *PathWriter) WriteInt64(value int64) {
+	p.w.WriteInt64(value)
+	p.setKey(pathKeyInt, "", uint64(value))
+	p.done()
+}
+
+func (p *PathWriter) WriteUint8(value uint8) {
+	p.w.WriteUint8(value)
+	p.setKey(pathKeyUint, "", uint64(value))
+	p.done()
+}
+
+func (p *PathWriter) WriteUint16(value uint16) {
+	p.w.WriteUint16(value)
+	p.setKey(pathKeyUint, "", uint64(value))
+	p.done()
+}
+
+func (p *PathWriter) WriteUint32(value uint32) {
+	p.w.WriteUint32(value)
+	p.setKey(pathKeyUint, "", uint64(value))
+	p.done()
+}
+
+func (p *PathWriter) WriteUint64(value uint64) {
+	p.w.WriteUint64(value)
+	p.setKey(pathKeyUint, "", value)
+	p.done()
+}
+
+func (p *PathWriter) WriteFloat32(value float32) {
+	p.w.WriteFloat32(value)
+	p.setKey(pathKeyFloat, "", math.Float64bits(float64(value)))
+	p.done()
+}
+
+func (p *PathWriter) WriteFloat64(value float64) {
+	p.w.WriteFloat64(value)
+	p.setKey(pathKeyFloat, "", math.Float64bits(value))
+	p.done()
+}
+
+func (p *PathWriter) WriteString(value string) {
+	p.w.WriteString(value)
+	p.setKey(pathKeyString, value, 0)
+	p.done()
+}
+
+func (p *PathWriter) WriteTime(value time.Time) {
+	p.w.WriteTime(value)
+	p.done()
+}
+
+func (p *PathWriter) WriteByteArray(value []byte) {
+	p.w.WriteByteArray(value)
+	p.done()
+}
+
+func (p *PathWriter) WriteArraySize(length uint32) {
+	p.w.WriteArraySize(length)
+	p.push(false, false, uint64(length))
+}
+
+func (p *PathWriter) WriteMapSize(length uint32) {
+	p.w.WriteMapSize(length)
+	p.push(true, false, 2*uint64(length))
+}
+
+// ReserveArraySize starts an array that ends when its size is patched.
+func (p *PathWriter) ReserveArraySize() HeaderMark {
+	mark := p.w.ReserveArraySize()
+	p.push(false, true, 0)
+	return mark
+}
+
+func (p *PathWriter) PatchArraySize(mark HeaderMark, length uint32) {
+	p.w.PatchArraySize(mark, length)
+	p.patchContainer(false)
+}
+
+// ReserveMapSize starts a map that ends when its size is patched.
+func (p *PathWriter) ReserveMapSize() HeaderMark {
+	mark := p.w.ReserveMapSize()
+	p.push(true, true, 0)
+	return mark
+}
+
+func (p *PathWriter) PatchMapSize(mark HeaderMark, length uint32) {
+	p.w.PatchMapSize(mark, length)
+	p.patchContainer(true)
+}
+
+func (p *PathWriter) patchContainer(isMap bool) {
+	if frame := p.stack[len(p.stack)-1]; len(p.stack) > 1 && frame.open && frame.isMap == isMap {
+		p.pop()
+		return
+	}
+	p.check()
+}
+
+// ReserveStringHeader starts a string whose bytes are written with
+// WriteRawBytes and that ends when its header is patched.
+func (p *PathWriter) ReserveStringHeader() HeaderMark {
+	mark := p.w.ReserveStringHeader()
+	p.payload = true
+	p.check()
+	return mark
+}
+
+func (p *PathWriter) PatchStringHeader(mark HeaderMark, length uint32) {
+	p.w.PatchStringHeader(mark, length)
+	p.payload = false
+	p.done()
+}
+
+// ReserveBinHeader starts a bin value whose bytes are written with
+// WriteRawBytes and that ends when its header is patched.
+func (p *PathWriter) ReserveBinHeader() HeaderMark {
+	mark := p.w.ReserveBinHeader()
+	p.payload = true
+	p.check()
+	return mark
+}
+
+func (p *PathWriter) PatchBinHeader(mark HeaderMark, length uint32) {
+	p.w.PatchBinHeader(mark, length)
+	p.payload = false
+	p.done()
+}
+
+// WriteRawBytes writes the bytes of a string or bin value started with
+// ReserveStringHeader or ReserveBinHeader, and is otherwise taken to write
+// one whole value.
+func (p *PathWriter) WriteRawBytes(value []byte) {
+	p.w.WriteRawBytes(value)
+	if p.payload {
+		p.check()
+		return
+	}
+	p.done()
+}
+
+func (p *PathWriter) WriteNillableBool(value *bool) {
+	writeNillableWith(p, value, p.WriteBool)
+}
+
+func (p *PathWriter) WriteNillableInt8(value *int8) {
+	writeNillableWith(p, value, p.WriteInt8)
+}
+
+func (p *PathWriter) WriteNillableInt16(value *int16) {
+	writeNillableWith(p, value, p.WriteInt16)
+}
+
+func (p *PathWriter) WriteNillableInt32(value *int32) {
+	writeNillableWith(p, value, p.WriteInt32)
+}
+
+func (p *PathWriter) WriteNillableInt64(value *int64) {
+	writeNillableWith(p, value, p.WriteInt64)
+}
+
+func (p *PathWriter) WriteNillableUint8(value *uint8) {
+	writeNillableWith(p, value, p.WriteUint8)
+}
+
+func (p *PathWriter) WriteNillableUint16(value *uint16) {
+	writeNillableWith(p, value, p.WriteUint16)
+}
+
+func (p *PathWriter) WriteNillableUint32(value *uint32) {
+	writeNillableWith(p, value, p.WriteUint32)
+}
+
+func (p *PathWriter) WriteNillableUint64(value *uint64) {
+	writeNillableWith(p, value, p.WriteUint64)
+}
+
+func (p *PathWriter) WriteNillableFloat32(value *float32) {
+	writeNillableWith(p, value, p.WriteFloat32)
+}
+
+func (p *PathWriter) WriteNillableFloat64(value *float64) {
+	writeNillableWith(p, value, p.WriteFloat64)
+}
+
+func (p *PathWriter) WriteNillableString(value *string) {
+	writeNillableWith(p, value, p.WriteString)
+}
+
+func (p *PathWriter) WriteNillableTime(value *time.Time) {
+	writeNillableWith(p, value, p.WriteTime)
+}
+
+func (p *PathWriter) WriteNillableByteArray(value []byte) {
+	if value == nil {
+		p.WriteNil()
+		return
+	}
+	p.WriteByteArray(value)
+}
+
+func (p *PathWriter) WriteNillableRaw(value Raw) {
+	if value == nil {
+		p.WriteNil()
+		return
+	}
+	p.WriteRaw(value)
+}
+
+func (p *PathWriter) WriteByteArrayVec(segments ...[]byte) {
+	p.w.WriteByteArrayVec(segments...)
+	p.done()
+}
+
+func (p *PathWriter) WriteStringVec(segments ...string) {
+	p.w.WriteStringVec(segments...)
+	p.done()
+}
+
+func (p *PathWriter) WriteAny(value any) {
+	p.w.WriteAny(value)
+	p.done()
+}
+
+func (p *PathWriter) WriteStringAnyMap(value map[string]any) {
+	p.w.WriteStringAnyMap(value)
+	p.done()
+}
+
+func (p *PathWriter) WriteRaw(value Raw) {
+	p.w.WriteRaw(value)
+	p.done()
+}
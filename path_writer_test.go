@@ -0,0 +1,152 @@
+package msgpack_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// writeRecords writes {"records": [{"id": i, "attributes": {"geo": ...,
+// 7: ...}}, ...]} and calls `at`, if not nil, before some of the values
+// with their expected path.
+func writeRecords(w msgpack.Writer, count int, at func(path string)) {
+	check := func(i int, suffix string) {
+		if at != nil {
+			at("records[" + strconv.Itoa(i) + "]" + suffix)
+		}
+	}
+	w.WriteMapSize(1)
+	w.WriteString("records")
+	w.WriteArraySize(uint32(count))
+	for i := 0; i < count; i++ {
+		w.WriteMapSize(2)
+		w.WriteString("id")
+		check(i, ".id")
+		w.WriteInt64(int64(i))
+		w.WriteString("attributes")
+		w.WriteMapSize(2)
+		w.WriteString("geo")
+		check(i, ".attributes.geo")
+		w.WriteArraySize(2)
+		check(i, ".attributes.geo[0]")
+		w.WriteFloat64(52.5)
+		check(i, ".attributes.geo[1]")
+		w.WriteFloat64(13.4)
+		w.WriteInt64(7)
+		check(i, ".attributes[int64(7)]")
+		w.WriteString("seven")
+	}
+}
+
+func TestPathWriterCurrentPath(t *testing.T) {
+	var sizer msgpack.Sizer
+	pw := msgpack.NewPathWriter(&sizer)
+	assert.Equal(t, "$", pw.CurrentPath())
+	checked := 0
+	writeRecords(pw, 3, func(path string) {
+		assert.Equal(t, path, pw.CurrentPath())
+		checked++
+	})
+	assert.Equal(t, 15, checked)
+	assert.Equal(t, "$", pw.CurrentPath())
+	require.NoError(t, pw.Err())
+}
+
+func TestPathWriterReportsFailingPath(t *testing.T) {
+	// Find the offset of the value at records[2].attributes.geo[1] and
+	// cut the buffer off in the middle of it.
+	var sizer msgpack.Sizer
+	offset := -1
+	writeRecords(&sizer, 5, func(path string) {
+		if path == "records[2].attributes.geo[1]" {
+			offset = int(sizer.Len())
+		}
+	})
+	require.Positive(t, offset)
+
+	encoder := msgpack.NewEncoder(make([]byte, offset+3))
+	pw := msgpack.NewPathWriter(&encoder)
+	writeRecords(pw, 5, nil)
+
+	err := pw.Err()
+	var pathErr msgpack.EncodePathError
+	require.ErrorAs(t, err, &pathErr)
+	assert.Equal(t, "records[2].attributes.geo[1]", pathErr.Path)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.Equal(t, "msgpack: encoding records[2].attributes.geo[1]: "+encoder.Err().Error(), err.Error())
+}
+
+func TestPathWriterField(t *testing.T) {
+	var sizer msgpack.Sizer
+	pw := msgpack.NewPathWriter(&sizer)
+	pw.WriteArraySize(2)
+	pw.Field("name")
+	assert.Equal(t, "name", pw.CurrentPath())
+	pw.WriteString("ada")
+	assert.Equal(t, "[1]", pw.CurrentPath())
+	pw.Field("tags")
+	pw.WriteMapSize(1)
+	assert.Equal(t, "tags", pw.CurrentPath())
+	pw.WriteBool(true)
+	assert.Equal(t, "tags[true]", pw.CurrentPath())
+	pw.WriteNil()
+	assert.Equal(t, "$", pw.CurrentPath())
+}
+
+func TestPathWriterReservedHeaders(t *testing.T) {
+	var sizer msgpack.Sizer
+	pw := msgpack.NewPathWriter(&sizer)
+	pw.WriteMapSize(2)
+	pw.WriteString("items")
+	mark := pw.ReserveArraySize()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "items["+strconv.Itoa(i)+"]", pw.CurrentPath())
+		str := pw.ReserveStringHeader()
+		pw.WriteRawBytes([]byte("ab"))
+		pw.PatchStringHeader(str, 2)
+	}
+	pw.PatchArraySize(mark, 3)
+	pw.WriteString("raw")
+	assert.Equal(t, "raw", pw.CurrentPath())
+	pw.WriteRawBytes([]byte{0xc0})
+	assert.Equal(t, "$", pw.CurrentPath())
+
+	pw.WriteArraySize(0)
+	pw.WriteMapSize(0)
+	assert.Equal(t, "$", pw.CurrentPath())
+	require.NoError(t, pw.Err())
+}
+
+func TestPathWriterEncodesSameBytes(t *testing.T) {
+	plain := encodeWith(t, func(w msgpack.Writer) {
+		writeRecords(w, 3, nil)
+	})
+	wrapped := encodeWith(t, func(w msgpack.Writer) {
+		writeRecords(msgpack.NewPathWriter(w), 3, nil)
+	})
+	assert.Equal(t, plain, wrapped)
+}
+
+func BenchmarkPathWriter(b *testing.B) {
+	var sizer msgpack.Sizer
+	writeRecords(&sizer, 100, nil)
+	buffer := make([]byte, sizer.Len())
+	b.Run("Encoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encoder := msgpack.NewEncoder(buffer)
+			writeRecords(&encoder, 100, nil)
+		}
+	})
+	b.Run("PathWriter", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encoder := msgpack.NewEncoder(buffer)
+			writeRecords(msgpack.NewPathWriter(&encoder), 100, nil)
+		}
+	})
+}
@@ -0,0 +1,331 @@
+//go:build !tinygo
+// +build !tinygo
+
+package msgpack
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EncodePositional writes the struct pointed to by `v` as an array in which
+// each field tagged `msgpack:"N"` is element N. The array is sized to the
+// largest index plus one and indexes without a field are written as nil.
+// Fields without a tag, or tagged `msgpack:"-"`, are not written.
+//
+// Supported field types are booleans, integers, floats, strings, []byte,
+// time.Time, Raw, types implementing Codec through their pointer, pointers
+// to any of these (nil pointers are written as nil) and `any`, which is
+// written with WriteAny.
+//
+// EncodePositional uses reflection and is only available in host builds.
+func EncodePositional(w Writer, v any) error {
+	rv, layout, err := positionalTarget(v)
+	if err != nil {
+		return err
+	}
+	w.WriteArraySize(uint32(len(layout.fields)))
+	for _, field := range layout.fields {
+		if field == nil {
+			w.WriteNil()
+			continue
+		}
+		if err := encodePositionalValue(w, rv.FieldByIndex(field.index)); err != nil {
+			return positionalFieldError(layout, field, err)
+		}
+	}
+	return w.Err()
+}
+
+// DecodePositional reads an array written by EncodePositional into the
+// struct pointed to by `v`. Arrays written by older producers may be
+// shorter, in which case the missing fields are set to their zero values,
+// and arrays written by newer producers may be longer, in which case the
+// extra elements are skipped. A nil element sets the field to its zero
+// value.
+//
+// DecodePositional uses reflection and is only available in host builds.
+func DecodePositional(r Reader, v any) error {
+	rv, layout, err := positionalTarget(v)
+	if err != nil {
+		return err
+	}
+	size, err := r.ReadArraySize()
+	if err != nil {
+		return err
+	}
+	// Fields missing from an older producer's shorter array are zeroed.
+	for i := int(size); i < len(layout.fields); i++ {
+		if field := layout.fields[i]; field != nil {
+			fv := rv.FieldByIndex(field.index)
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+	for i := uint32(0); i < size; i++ {
+		if int(i) >= len(layout.fields) || layout.fields[i] == nil {
+			if err := r.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		field := layout.fields[i]
+		if err := decodePositionalValue(r, rv.FieldByIndex(field.index)); err != nil {
+			return positionalFieldError(layout, field, err)
+		}
+	}
+	return nil
+}
+
+type positionalField struct {
+	name  string
+	index []int
+}
+
+type positionalLayout struct {
+	typeName string
+	// fields is indexed by array position; gaps are nil.
+	fields []*positionalField
+}
+
+var positionalLayouts sync.Map // reflect.Type -> *positionalLayout
+
+func positionalTarget(v any) (reflect.Value, *positionalLayout, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, nil, ReadError{"msgpack: positional value must be a non-nil pointer to a struct"}
+	}
+	rv = rv.Elem()
+	layout, err := positionalLayoutOf(rv.Type())
+	return rv, layout, err
+}
+
+// positionalLayoutOf returns the layout of `t`, rejecting duplicate or
+// malformed indexes. Layouts are computed once per type.
+func positionalLayoutOf(t reflect.Type) (*positionalLayout, error) {
+	if cached, ok := positionalLayouts.Load(t); ok {
+		return cached.(*positionalLayout), nil
+	}
+	layout := &positionalLayout{typeName: t.String()}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("msgpack")
+		if !ok || tag == "-" {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return nil, ReadError{"msgpack: positional field " + layout.typeName + "." + sf.Name + " is not exported"}
+		}
+		index, err := strconv.ParseUint(tag, 10, 16)
+		if err != nil {
+			return nil, ReadError{"msgpack: invalid positional index " + strconv.Quote(tag) + " on " + layout.typeName + "." + sf.Name}
+		}
+		for int(index) >= len(layout.fields) {
+			layout.fields = append(layout.fields, nil)
+		}
+		if existing := layout.fields[index]; existing != nil {
+			return nil, ReadError{"msgpack: duplicate positional index " + tag + " on " +
+				layout.typeName + "." + existing.name + " and " + layout.typeName + "." + sf.Name}
+		}
+		layout.fields[index] = &positionalField{name: sf.Name, index: sf.Index}
+	}
+	actual, _ := positionalLayouts.LoadOrStore(t, layout)
+	return actual.(*positionalLayout), nil
+}
+
+func positionalFieldError(layout *positionalLayout, field *positionalField, err error) error {
+	prefix := "msgpack: " + layout.typeName + "." + field.name + ": "
+	switch e := err.(type) {
+	case ReadError:
+		return ReadError{prefix + err.Error()}
+	case TypeMismatchError:
+		// Keep the type so that errors.As still finds it.
+		e.context = prefix + e.context
+		return e
+	case OverflowError:
+		e.context = prefix + e.context
+		return e
+	case WriteError:
+		return WriteError{message: prefix + err.Error()}
+	}
+	return err
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	rawType   = reflect.TypeOf(Raw(nil))
+	codecType = reflect.TypeOf((*Codec)(nil)).Elem()
+)
+
+func encodePositionalValue(w Writer, fv reflect.Value) error {
+	t := fv.Type()
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(codecType) {
+		if fv.CanAddr() {
+			return fv.Addr().Interface().(Codec).Encode(w)
+		}
+	}
+	switch t {
+	case timeType:
+		w.WriteTime(fv.Interface().(time.Time))
+		return nil
+	case rawType:
+		w.WriteNillableRaw(fv.Interface().(Raw))
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		w.WriteBool(fv.Bool())
+	case reflect.Int8:
+		w.WriteInt8(int8(fv.Int()))
+	case reflect.Int16:
+		w.WriteInt16(int16(fv.Int()))
+	case reflect.Int32:
+		w.WriteInt32(int32(fv.Int()))
+	case reflect.Int, reflect.Int64:
+		w.WriteInt64(fv.Int())
+	case reflect.Uint8:
+		w.WriteUint8(uint8(fv.Uint()))
+	case reflect.Uint16:
+		w.WriteUint16(uint16(fv.Uint()))
+	case reflect.Uint32:
+		w.WriteUint32(uint32(fv.Uint()))
+	case reflect.Uint, reflect.Uint64:
+		w.WriteUint64(fv.Uint())
+	case reflect.Float32:
+		w.WriteFloat32(float32(fv.Float()))
+	case reflect.Float64:
+		w.WriteFloat64(fv.Float())
+	case reflect.String:
+		w.WriteString(fv.String())
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return WriteError{message: "unsupported field type " + t.String()}
+		}
+		w.WriteByteArray(fv.Bytes())
+	case reflect.Interface:
+		w.WriteAny(fv.Interface())
+	case reflect.Ptr:
+		if fv.IsNil() {
+			w.WriteNil()
+			return nil
+		}
+		if t.Implements(codecType) {
+			return fv.Interface().(Codec).Encode(w)
+		}
+		return encodePositionalValue(w, fv.Elem())
+	default:
+		return WriteError{message: "unsupported field type " + t.String()}
+	}
+	return nil
+}
+
+func decodePositionalValue(r Reader, fv reflect.Value) error {
+	isNil, err := readNil(r)
+	if err != nil {
+		return err
+	}
+	if isNil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+
+	t := fv.Type()
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(codecType) {
+		return fv.Addr().Interface().(Codec).Decode(r)
+	}
+	switch t {
+	case timeType:
+		v, err := r.ReadTime()
+		fv.Set(reflect.ValueOf(v))
+		return err
+	case rawType:
+		v, err := r.ReadRaw()
+		fv.Set(reflect.ValueOf(v))
+		return err
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		v, err := r.ReadBool()
+		fv.SetBool(v)
+		return err
+	case reflect.Int8:
+		v, err := r.ReadInt8()
+		fv.SetInt(int64(v))
+		return err
+	case reflect.Int16:
+		v, err := r.ReadInt16()
+		fv.SetInt(int64(v))
+		return err
+	case reflect.Int32:
+		v, err := r.ReadInt32()
+		fv.SetInt(int64(v))
+		return err
+	case reflect.Int, reflect.Int64:
+		v, err := r.ReadInt64()
+		fv.SetInt(v)
+		return err
+	case reflect.Uint8:
+		v, err := r.ReadUint8()
+		fv.SetUint(uint64(v))
+		return err
+	case reflect.Uint16:
+		v, err := r.ReadUint16()
+		fv.SetUint(uint64(v))
+		return err
+	case reflect.Uint32:
+		v, err := r.ReadUint32()
+		fv.SetUint(uint64(v))
+		return err
+	case reflect.Uint, reflect.Uint64:
+		v, err := r.ReadUint64()
+		fv.SetUint(v)
+		return err
+	case reflect.Float32:
+		v, err := r.ReadFloat32()
+		fv.SetFloat(float64(v))
+		return err
+	case reflect.Float64:
+		v, err := r.ReadFloat64()
+		fv.SetFloat(v)
+		return err
+	case reflect.String:
+		v, err := r.ReadString()
+		fv.SetString(v)
+		return err
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return ReadError{"unsupported field type " + t.String()}
+		}
+		v, err := r.ReadByteArray()
+		fv.SetBytes(v)
+		return err
+	case reflect.Interface:
+		v, err := r.ReadAny()
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			fv.Set(reflect.Zero(t))
+			return nil
+		}
+		value := reflect.ValueOf(v)
+		if !value.Type().AssignableTo(t) {
+			return ReadError{"cannot assign " + value.Type().String() + " to " + t.String()}
+		}
+		fv.Set(value)
+		return nil
+	case reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if t.Implements(codecType) {
+			if err := elem.Interface().(Codec).Decode(r); err != nil {
+				return err
+			}
+		} else if err := decodePositionalValue(r, elem.Elem()); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+	return ReadError{"unsupported field type " + t.String()}
+}
@@ -0,0 +1,156 @@
+//go:build !tinygo
+// +build !tinygo
+
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type eventV1 struct {
+	ID        uint64    `msgpack:"0"`
+	Timestamp time.Time `msgpack:"1"`
+	Source    string    `msgpack:"3"`
+	Cached    bool
+}
+
+type eventV2 struct {
+	ID        uint64    `msgpack:"0"`
+	Timestamp time.Time `msgpack:"1"`
+	Source    string    `msgpack:"3"`
+	Tags      []byte    `msgpack:"4"`
+	Parent    *int64    `msgpack:"5"`
+	Extra     any       `msgpack:"6"`
+}
+
+func encodePositional(t *testing.T, v any) []byte {
+	t.Helper()
+	var sizer msgpack.Sizer
+	require.NoError(t, msgpack.EncodePositional(&sizer, v))
+	buffer := make([]byte, sizer.Len())
+	encoder := msgpack.NewEncoder(buffer)
+	require.NoError(t, msgpack.EncodePositional(&encoder, v))
+	return buffer
+}
+
+func TestPositionalLayout(t *testing.T) {
+	ts := time.Unix(1622548800, 0)
+	data := encodePositional(t, &eventV1{ID: 7, Timestamp: ts, Source: "api", Cached: true})
+
+	decoder := msgpack.NewDecoder(data)
+	size, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(4), size)
+	id, err := decoder.ReadUint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), id)
+	actual, err := decoder.ReadTime()
+	require.NoError(t, err)
+	assert.Equal(t, ts, actual)
+	isNil, err := decoder.IsNextNil()
+	require.NoError(t, err)
+	assert.True(t, isNil, "index 2 has no field")
+	source, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "api", source)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+}
+
+func TestPositionalRoundTrip(t *testing.T) {
+	parent := int64(-3)
+	in := eventV2{
+		ID:        1,
+		Timestamp: time.Unix(1622548800, 0),
+		Source:    "worker",
+		Tags:      []byte{1, 2},
+		Parent:    &parent,
+		Extra:     "x",
+	}
+	data := encodePositional(t, &in)
+	var out eventV2
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, msgpack.DecodePositional(&decoder, &out))
+	assert.Equal(t, in, out)
+}
+
+func TestPositionalOlderProducer(t *testing.T) {
+	// A v1 producer writes a shorter array; the v2 fields become zero.
+	ts := time.Unix(1622548800, 0)
+	data := encodePositional(t, &eventV1{ID: 7, Timestamp: ts, Source: "api"})
+
+	parent := int64(9)
+	out := eventV2{Tags: []byte{1}, Parent: &parent, Extra: "stale"}
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, msgpack.DecodePositional(&decoder, &out))
+	assert.Equal(t, eventV2{ID: 7, Timestamp: ts, Source: "api"}, out)
+}
+
+func TestPositionalNewerProducer(t *testing.T) {
+	// A v2 producer writes a longer array; a v1 consumer skips the extras.
+	ts := time.Unix(1622548800, 0)
+	data := encodePositional(t, &eventV2{ID: 7, Timestamp: ts, Source: "api", Tags: []byte{1}, Extra: map[string]any{"a": 1}})
+
+	var out eventV1
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, msgpack.DecodePositional(&decoder, &out))
+	assert.Equal(t, eventV1{ID: 7, Timestamp: ts, Source: "api"}, out)
+	assert.Equal(t, uint32(len(data)), decoder.Offset())
+}
+
+func TestPositionalErrors(t *testing.T) {
+	type duplicate struct {
+		A int32 `msgpack:"1"`
+		B int32 `msgpack:"1"`
+	}
+	var sizer msgpack.Sizer
+	err := msgpack.EncodePositional(&sizer, &duplicate{})
+	assert.EqualError(t, err, "msgpack: duplicate positional index 1 on msgpack_test.duplicate.A and msgpack_test.duplicate.B")
+	decoder := msgpack.NewDecoder([]byte{0x90})
+	assert.Error(t, msgpack.DecodePositional(&decoder, &duplicate{}))
+
+	type badTag struct {
+		A int32 `msgpack:"first"`
+	}
+	err = msgpack.EncodePositional(&sizer, &badTag{})
+	assert.EqualError(t, err, `msgpack: invalid positional index "first" on msgpack_test.badTag.A`)
+
+	type unsupported struct {
+		A []string `msgpack:"0"`
+	}
+	err = msgpack.EncodePositional(&sizer, &unsupported{})
+	assert.EqualError(t, err, "msgpack: msgpack_test.unsupported.A: unsupported field type []string")
+
+	err = msgpack.EncodePositional(&sizer, eventV1{})
+	assert.EqualError(t, err, "msgpack: positional value must be a non-nil pointer to a struct")
+
+	decoder = msgpack.NewDecoder([]byte{0x91, 0xa1, 'x'})
+	err = msgpack.DecodePositional(&decoder, &eventV1{})
+	assert.EqualError(t, err, "msgpack: msgpack_test.eventV1.ID: bad prefix for uint: got fixstr(1) (0xa1) at offset 1")
+	var mismatch msgpack.TypeMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, msgpack.KindString, mismatch.Actual)
+}
+
+type optionalBlock struct {
+	ID    int64       `msgpack:"0"`
+	Block msgpack.Raw `msgpack:"1"`
+}
+
+// A positional Raw field that is nil is written as nil and read back as
+// nil.
+func TestPositionalNilRaw(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.EncodePositional(w, &optionalBlock{ID: 1}))
+	})
+	assert.Equal(t, []byte{0x92, 0x01, msgpack.FormatNil}, data)
+	decoder := msgpack.NewDecoder(data)
+	var out optionalBlock
+	require.NoError(t, msgpack.DecodePositional(&decoder, &out))
+	assert.Equal(t, optionalBlock{ID: 1}, out)
+}
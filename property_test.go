@@ -0,0 +1,45 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/msgpacktest"
+)
+
+// TestAnyRoundTripProperty reads generated documents with ReadAny and
+// writes them back with WriteAny, which must give the same document up to
+// the choice of formats and map order.
+func TestAnyRoundTripProperty(t *testing.T) {
+	for _, alphabet := range []msgpacktest.Alphabet{
+		msgpacktest.AlphabetASCII, msgpacktest.AlphabetUnicode, msgpacktest.AlphabetBinary,
+	} {
+		g := msgpacktest.NewGenerator(int64(alphabet), msgpacktest.WithAlphabet(alphabet))
+		for i := 0; i < 300; i++ {
+			data := g.NextRaw()
+			decoder := msgpack.NewDecoder(data)
+			v, err := decoder.ReadAny()
+			require.NoError(t, err, "% x", data)
+			reencoded := encodeWith(t, func(w msgpack.Writer) { w.WriteAny(v) })
+			diffs, err := msgpack.Diff(data, reencoded)
+			require.NoError(t, err)
+			require.True(t, msgpack.SemanticallyEqual(diffs), "%v\n% x\n% x", diffs, data, reencoded)
+		}
+	}
+}
+
+// TestGeneratedValueRoundTripProperty writes generated values with
+// WriteAny and checks the Sizer agrees and every value reads back whole.
+func TestGeneratedValueRoundTripProperty(t *testing.T) {
+	g := msgpacktest.NewGenerator(7, msgpacktest.WithTimes(), msgpacktest.WithExt(), msgpacktest.WithMaxDepth(4))
+	for i := 0; i < 300; i++ {
+		v := g.Next()
+		data := encodeWith(t, func(w msgpack.Writer) { w.WriteAny(v) })
+		decoder := msgpack.NewDecoder(data)
+		require.NoError(t, decoder.Skip())
+		require.Equal(t, uint32(0), decoder.Remaining())
+		require.NoError(t, msgpack.Validate(data))
+	}
+}
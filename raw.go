@@ -0,0 +1,169 @@
+package msgpack
+
+// Raw is a single, already encoded MessagePack value.
+//
+// A Raw returned by ReadRaw aliases the decoder's input buffer, so keeping
+// even a few bytes of it keeps the whole buffer alive. Code that stores Raw
+// values beyond the life of a message, such as an envelope's payload or a
+// cached Value, should read them with ReadRawCopyBounded or copy them.
+type Raw []byte
+
+// ReadRaw returns the encoded bytes of the next value without decoding it.
+func (d *Decoder) ReadRaw() (Raw, error) {
+	start := d.reader.byteOffset
+	if err := d.Skip(); err != nil {
+		return nil, err
+	}
+	// A truncated value must never come back as a shorter Raw.
+	if err := d.reader.Err(); err != nil {
+		return nil, err
+	}
+	return Raw(d.reader.buffer[start:d.reader.byteOffset]), nil
+}
+
+// ReadAnyWithRaw reads the next value like ReadAny and also returns its
+// encoded bytes, like ReadRaw, from a single pass over them. The Raw
+// aliases the input buffer. On error no Raw is returned and the decoder is
+// left where ReadAny would leave it.
+//
+// With WithStringTableDecoding, a Raw holding a reference to a string from
+// an earlier value only decodes again with the same table.
+func (d *Decoder) ReadAnyWithRaw() (any, Raw, error) {
+	start := d.reader.byteOffset
+	value, err := d.ReadAny()
+	if err == nil {
+		err = d.reader.Err()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, Raw(d.reader.buffer[start:d.reader.byteOffset]), nil
+}
+
+// DecodeWithRaw reads a T with its Decode method like Decode and also
+// returns the encoded bytes it consumed. From a Decoder, or a
+// SuspendableDecoder, the bytes are those the Decode method read; from
+// other readers the value is read with ReadRaw and decoded from it with a
+// Decoder, without the options of `r`. On error no Raw is returned.
+func DecodeWithRaw[T any, PT interface {
+	*T
+	Codec
+}](r Reader) (T, Raw, error) {
+	switch r := r.(type) {
+	case *Decoder:
+		start := r.reader.byteOffset
+		value, err := Decode[T, PT](r)
+		if err != nil {
+			return value, nil, err
+		}
+		return value, Raw(r.reader.buffer[start:r.reader.byteOffset]), nil
+	case *SuspendableDecoder:
+		var (
+			value T
+			raw   Raw
+		)
+		err := r.Try(func(r Reader) (err error) {
+			value, raw, err = DecodeWithRaw[T, PT](r)
+			return err
+		})
+		return value, raw, err
+	}
+	raw, err := r.ReadRaw()
+	if err != nil {
+		var zero T
+		return zero, nil, err
+	}
+	d := NewDecoder(raw)
+	value, err := Decode[T, PT](&d)
+	if err != nil {
+		return value, nil, err
+	}
+	return value, raw, nil
+}
+
+// ReadRawBounded returns the encoded bytes of the next value like ReadRaw,
+// but returns a ValueTooLargeError, which matches ErrValueTooLarge, when
+// the value is longer than `maxLen` bytes. The decoder is then left at the
+// start of the value, so it can still be skipped or read some other way.
+func (d *Decoder) ReadRawBounded(maxLen uint32) (Raw, error) {
+	start := d.reader.byteOffset
+	probe := *d
+	raw, err := probe.ReadRaw()
+	if err == nil && uint64(len(raw)) > uint64(maxLen) {
+		return nil, ValueTooLargeError{Kind: kindRaw, Length: uint32(len(raw)), Limit: maxLen, Offset: start}
+	}
+	*d = probe
+	return raw, err
+}
+
+// ReadRawCopyBounded reads the next value with ReadRawBounded and returns
+// a copy of it that does not keep the input buffer alive.
+func (d *Decoder) ReadRawCopyBounded(maxLen uint32) (Raw, error) {
+	raw, err := d.ReadRawBounded(maxLen)
+	if err != nil {
+		return nil, err
+	}
+	return append(Raw(nil), raw...), nil
+}
+
+// ReadNillableRaw reads nil as a nil Raw and any other value as ReadRaw
+// does. Unlike ReadRaw, which returns the nil value as Raw{FormatNil}, it
+// is the counterpart of WriteNillableRaw.
+func (d *Decoder) ReadNillableRaw() (Raw, error) {
+	isNil, err := readNil(d)
+	if isNil || err != nil {
+		return nil, err
+	}
+	return d.ReadRaw()
+}
+
+// errEmptyRaw is recorded by WriteRaw for an empty Raw, which would leave
+// the container it is written into a value short.
+var errEmptyRaw = WriteError{message: "msgpack: WriteRaw of an empty Raw, which is not a value; use WriteNillableRaw to write nil"}
+
+// WriteRaw copies an already encoded value into the buffer. An empty Raw
+// is never a value, so it records a WriteError rather than writing
+// nothing; write an optional Raw with WriteNillableRaw.
+func (e *Encoder) WriteRaw(value Raw) {
+	if len(value) == 0 {
+		if e.reader.err == nil {
+			e.reader.err = errEmptyRaw
+		}
+		return
+	}
+	e.reader.SetBytes(value)
+	if e.options.stringTable && e.reader.err == nil {
+		e.strings.addRaw(value, e.options.stringTableExt)
+	}
+}
+
+// WriteNillableRaw writes nil for a nil Raw and is otherwise WriteRaw.
+// ReadNillableRaw reads it back.
+func (e *Encoder) WriteNillableRaw(value Raw) {
+	if value == nil {
+		e.WriteNil()
+		return
+	}
+	e.WriteRaw(value)
+}
+
+func (s *Sizer) WriteRaw(value Raw) {
+	if len(value) == 0 {
+		if s.err == nil {
+			s.err = errEmptyRaw
+		}
+		return
+	}
+	s.length += uint32(len(value))
+	if s.options.stringTable {
+		s.strings.addRaw(value, s.options.stringTableExt)
+	}
+}
+
+func (s *Sizer) WriteNillableRaw(value Raw) {
+	if value == nil {
+		s.WriteNil()
+		return
+	}
+	s.WriteRaw(value)
+}
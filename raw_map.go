@@ -0,0 +1,147 @@
+package msgpack
+
+// RawEntry is one entry of a map written by WriteMapWithRawValues. The
+// value is written by Write when it is set, and is otherwise Raw, an
+// already encoded value such as a cached sub-object. A nil Raw is
+// written as nil, as WriteNillableRaw does, while an empty Raw that is not
+// nil is no value and an error, as for WriteRaw.
+type RawEntry struct {
+	Key   string
+	Raw   Raw
+	Write func(w Writer)
+}
+
+type rawMapOptions struct {
+	unvalidated bool
+}
+
+// RawMapOption configures WriteMapWithRawValues and WriteRawMap.
+type RawMapOption func(*rawMapOptions)
+
+// WithUnvalidatedRaw makes WriteMapWithRawValues and WriteRawMap write Raw
+// values without checking them first, for hot paths whose cached values
+// are known to be good. A Raw that is not exactly one complete value then
+// corrupts the map: the bytes are written as they are, so the map is still
+// detected as malformed by Validate or a reader, but not where the bad
+// value was written.
+func WithUnvalidatedRaw() RawMapOption {
+	return func(o *rawMapOptions) {
+		o.unvalidated = true
+	}
+}
+
+func rawMapOptionsOf(opts []RawMapOption) rawMapOptions {
+	var o rawMapOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WriteMapWithRawValues writes a map of `entries` in order, composing
+// already encoded values with fresh ones. Each Raw other than nil is first
+// checked with Validate, and one that is empty or not exactly one complete
+// value returns a FieldError naming its key before anything is written.
+// Against a Sizer, each Raw counts its length.
+func WriteMapWithRawValues(w Writer, entries []RawEntry, opts ...RawMapOption) error {
+	o := rawMapOptionsOf(opts)
+	if !o.unvalidated {
+		for i := range entries {
+			entry := &entries[i]
+			if entry.Write != nil || entry.Raw == nil {
+				continue
+			}
+			if len(entry.Raw) == 0 {
+				return FieldError{Field: entry.Key, Err: errEmptyRaw}
+			}
+			if err := Validate(entry.Raw); err != nil {
+				return FieldError{Field: entry.Key, Err: err}
+			}
+		}
+	}
+
+	w.WriteMapSize(uint32(len(entries)))
+	for i := range entries {
+		entry := &entries[i]
+		w.WriteString(entry.Key)
+		if entry.Write != nil {
+			entry.Write(w)
+			continue
+		}
+		w.WriteNillableRaw(entry.Raw)
+	}
+	return w.Err()
+}
+
+// ReadRawMap reads a map whose keys are strings into a map[string]Raw,
+// keeping each value as it was encoded, so that values an intermediary
+// does not understand can be written back byte for byte with WriteRawMap.
+// A nil value yields a nil map, and an empty map an empty one. A key that
+// is not a string is an error naming its offset when `r` is a Decoder.
+// Keys and values alias the input buffer, like those of ReadString and
+// ReadRaw.
+func ReadRawMap(r Reader) (map[string]Raw, error) {
+	isNil, err := readNil(r)
+	if err != nil || isNil {
+		return nil, err
+	}
+	size, err := r.ReadMapSize()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]Raw, sizeHint(r, size))
+	for i := uint32(0); i < size; i++ {
+		key, err := readStringKey(r)
+		if err != nil {
+			return nil, err
+		}
+		if m[key], err = r.ReadRaw(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// WriteRawMap writes `m` as a map of its keys to their Raw values, byte for
+// byte, and a nil map as nil. An empty Raw is written as nil. The keys are
+// in Go's random map order unless the writer has WithSortedStringMaps, so
+// that equal maps encode to the same bytes. Each Raw is first checked with
+// Validate, and one that is not exactly one complete value returns a
+// FieldError naming its key before anything is written.
+func WriteRawMap(w Writer, m map[string]Raw, opts ...RawMapOption) error {
+	if m == nil {
+		w.WriteNil()
+		return w.Err()
+	}
+	if o := rawMapOptionsOf(opts); !o.unvalidated {
+		for key, value := range m {
+			if len(value) == 0 {
+				continue
+			}
+			if err := Validate(value); err != nil {
+				return FieldError{Field: key, Err: err}
+			}
+		}
+	}
+	writeKeyedMap(w, m, encOptionsOf(w).sortedStringMaps, w.WriteString, func(value Raw) {
+		writeRawOrNil(w, value)
+	})
+	return w.Err()
+}
+
+// MergeRawMaps returns a map of the entries of `base` and `overlay`, with
+// the value from `overlay` for a key in both. The values are shared, not
+// copied. Either map may be nil, and the result is nil when both are.
+func MergeRawMaps(base, overlay map[string]Raw) map[string]Raw {
+	if base == nil && overlay == nil {
+		return nil
+	}
+	merged := make(map[string]Raw, len(base)+len(overlay))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		merged[key] = value
+	}
+	return merged
+}
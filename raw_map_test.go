@@ -0,0 +1,251 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func cachedProfile(t testing.TB, id int64) msgpack.Raw {
+	var sizer msgpack.Sizer
+	writeProfile(&sizer, id)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	writeProfile(&encoder, id)
+	require.NoError(t, encoder.Err())
+	return encoder.Bytes()
+}
+
+func writeProfile(w msgpack.Writer, id int64) {
+	w.WriteMapSize(3)
+	w.WriteString("id")
+	w.WriteInt64(id)
+	w.WriteString("name")
+	w.WriteString("profile name")
+	w.WriteString("tags")
+	w.WriteArraySize(3)
+	w.WriteString("admin")
+	w.WriteString("ops")
+	w.WriteString("oncall")
+}
+
+func TestWriteMapWithRawValues(t *testing.T) {
+	entries := []msgpack.RawEntry{
+		{Key: "owner", Raw: cachedProfile(t, 1)},
+		{Key: "reviewer", Raw: cachedProfile(t, 2)},
+		{Key: "none"},
+	}
+	got := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.WriteMapWithRawValues(w, entries))
+	})
+	want := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("owner")
+		writeProfile(w, 1)
+		w.WriteString("reviewer")
+		writeProfile(w, 2)
+		w.WriteString("none")
+		w.WriteNil()
+	})
+	assert.Equal(t, want, got)
+}
+
+func TestWriteMapWithRawValuesMixed(t *testing.T) {
+	entries := []msgpack.RawEntry{
+		{Key: "status", Write: func(w msgpack.Writer) { w.WriteString("ok") }},
+		{Key: "owner", Raw: cachedProfile(t, 1)},
+		{Key: "count", Raw: msgpack.Raw{0xff}, Write: func(w msgpack.Writer) { w.WriteInt64(2) }},
+	}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.WriteMapWithRawValues(w, entries))
+	})
+	decoder := msgpack.NewDecoder(data)
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, map[any]any{
+		"status": "ok",
+		"owner": map[any]any{
+			"id":   int64(1),
+			"name": "profile name",
+			"tags": []any{"admin", "ops", "oncall"},
+		},
+		"count": int64(2),
+	}, v, "Write takes precedence over Raw")
+}
+
+func TestWriteMapWithRawValuesInvalid(t *testing.T) {
+	truncated := cachedProfile(t, 1)
+	truncated = truncated[:len(truncated)-2]
+	entries := []msgpack.RawEntry{
+		{Key: "status", Write: func(w msgpack.Writer) { w.WriteString("ok") }},
+		{Key: "owner", Raw: truncated},
+	}
+
+	var sizer msgpack.Sizer
+	err := msgpack.WriteMapWithRawValues(&sizer, entries)
+	var fieldErr msgpack.FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "owner", fieldErr.Field)
+	assert.Zero(t, sizer.Len(), "nothing is written")
+
+	// Two values in one Raw are rejected too.
+	err = msgpack.WriteMapWithRawValues(&sizer, []msgpack.RawEntry{{Key: "pair", Raw: msgpack.Raw{0x01, 0x02}}})
+	assert.EqualError(t, err, `msgpack: field "pair": msgpack: 1 trailing bytes at offset 1`)
+
+	// Only a nil Raw is written as nil; an empty one is no value.
+	err = msgpack.WriteMapWithRawValues(&sizer, []msgpack.RawEntry{{Key: "empty", Raw: msgpack.Raw{}}})
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "empty", fieldErr.Field)
+	assert.Zero(t, sizer.Len(), "nothing is written")
+	encoder := msgpack.NewEncoder(make([]byte, 16))
+	err = msgpack.WriteMapWithRawValues(&encoder, []msgpack.RawEntry{{Key: "empty", Raw: msgpack.Raw{}}}, msgpack.WithUnvalidatedRaw())
+	var writeErr msgpack.WriteError
+	assert.ErrorAs(t, err, &writeErr)
+
+	// Unvalidated, the bytes are written as they are and the result no
+	// longer validates.
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.WriteMapWithRawValues(w, entries, msgpack.WithUnvalidatedRaw()))
+	})
+	assert.Error(t, msgpack.Validate(data))
+}
+
+func BenchmarkWriteMapWithRawValues(b *testing.B) {
+	const profiles = 8
+	entries := make([]msgpack.RawEntry, profiles)
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for i := range entries {
+		entries[i] = msgpack.RawEntry{Key: keys[i], Raw: cachedProfile(b, int64(i))}
+	}
+	buffer := make([]byte, 4096)
+
+	b.Run("Reencode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encoder := msgpack.NewEncoder(buffer)
+			encoder.WriteMapSize(profiles)
+			for j := 0; j < profiles; j++ {
+				encoder.WriteString(keys[j])
+				writeProfile(&encoder, int64(j))
+			}
+		}
+	})
+	b.Run("Raw", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encoder := msgpack.NewEncoder(buffer)
+			if err := msgpack.WriteMapWithRawValues(&encoder, entries); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("RawUnvalidated", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encoder := msgpack.NewEncoder(buffer)
+			if err := msgpack.WriteMapWithRawValues(&encoder, entries, msgpack.WithUnvalidatedRaw()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// opaqueMetadata is a map whose values use encodings an intermediary
+// would not produce itself, with its keys in order.
+var opaqueMetadata = []byte{
+	0x86,
+	0xa1, 'a', 0xd3, 0, 0, 0, 0, 0, 0, 0, 5, // int64 of a small value
+	0xa1, 'b', 0xcd, 0x00, 0x05, // uint16 of a small value
+	0xa1, 'c', 0xd6, 0x2a, 1, 2, 3, 4, // fixext4 of an unknown type
+	0xa1, 'd', 0xd9, 0x02, 'h', 'i', // str8 of a short string
+	0xa1, 'e', 0xdc, 0x00, 0x01, 0x01, // array16 of one element
+	0xa1, 'f', 0xca, 0x3f, 0x80, 0x00, 0x00, // float32
+}
+
+func TestRawMapRoundTrip(t *testing.T) {
+	decoder := msgpack.NewDecoder(opaqueMetadata)
+	m, err := msgpack.ReadRawMap(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+	assert.Len(t, m, 6)
+	assert.Equal(t, msgpack.Raw{0xd6, 0x2a, 1, 2, 3, 4}, m["c"])
+
+	data := encodeWithOptions(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.WriteRawMap(w, m))
+	}, msgpack.WithSortedStringMaps())
+	assert.Equal(t, opaqueMetadata, data)
+
+	// A hop adds a key and passes the rest through.
+	hop := encodeWith(t, func(w msgpack.Writer) { w.WriteString("edge-1") })
+	merged := msgpack.MergeRawMaps(m, map[string]msgpack.Raw{"via": hop})
+	data = encodeWithOptions(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.WriteRawMap(w, merged))
+	}, msgpack.WithSortedStringMaps())
+	expected := append([]byte{0x87}, opaqueMetadata[1:]...)
+	expected = append(append(expected, 0xa3, 'v', 'i', 'a'), hop...)
+	assert.Equal(t, expected, data)
+}
+
+func TestRawMapNil(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{msgpack.FormatNil})
+	m, err := msgpack.ReadRawMap(&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	decoder = msgpack.NewDecoder([]byte{0x80})
+	m, err = msgpack.ReadRawMap(&decoder)
+	require.NoError(t, err)
+	assert.NotNil(t, m)
+	assert.Empty(t, m)
+
+	assert.Equal(t, []byte{msgpack.FormatNil}, encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.WriteRawMap(w, nil))
+	}))
+	assert.Equal(t, []byte{0x80}, encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.WriteRawMap(w, map[string]msgpack.Raw{}))
+	}))
+	// An empty Raw is a nil value.
+	assert.Equal(t, []byte{0x81, 0xa1, 'k', msgpack.FormatNil}, encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.WriteRawMap(w, map[string]msgpack.Raw{"k": nil}))
+	}))
+
+	assert.Nil(t, msgpack.MergeRawMaps(nil, nil))
+	assert.Equal(t, map[string]msgpack.Raw{}, msgpack.MergeRawMaps(map[string]msgpack.Raw{}, nil))
+	assert.Equal(t, map[string]msgpack.Raw{"a": {0x01}}, msgpack.MergeRawMaps(nil, map[string]msgpack.Raw{"a": {0x01}}))
+	assert.Equal(t, map[string]msgpack.Raw{"a": {0x02}, "b": {0x03}},
+		msgpack.MergeRawMaps(map[string]msgpack.Raw{"a": {0x01}, "b": {0x03}}, map[string]msgpack.Raw{"a": {0x02}}))
+}
+
+func TestRawMapErrors(t *testing.T) {
+	data := []byte{0x82, 0xa1, 'a', 0x01, 0x07, 0x02}
+	decoder := msgpack.NewDecoder(data)
+	_, err := msgpack.ReadRawMap(&decoder)
+	assert.EqualError(t, err, "msgpack: map key is not a string, found format 0x7 at offset 4")
+
+	decoder = msgpack.NewDecoder([]byte{0x92, 0x01, 0x02})
+	_, err = msgpack.ReadRawMap(&decoder)
+	var mismatch msgpack.TypeMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+
+	decoder = msgpack.NewDecoder([]byte{0x81, 0xa1, 'a', 0xd9, 0x05, 'h'})
+	_, err = msgpack.ReadRawMap(&decoder)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+
+	// A truncated map32 header fails without allocating for its size.
+	decoder = msgpack.NewDecoder([]byte{0xdf, 0xff, 0xff, 0xff, 0xff})
+	_, err = msgpack.ReadRawMap(&decoder)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+
+	encoder := msgpack.NewEncoder(make([]byte, 64))
+	err = msgpack.WriteRawMap(&encoder, map[string]msgpack.Raw{"bad": {0x92, 0x01}})
+	var fieldErr msgpack.FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "bad", fieldErr.Field)
+	assert.Equal(t, uint32(0), encoder.Len())
+
+	// Unvalidated values are written as they are.
+	require.NoError(t, msgpack.WriteRawMap(&encoder, map[string]msgpack.Raw{"bad": {0x92, 0x01}}, msgpack.WithUnvalidatedRaw()))
+	assert.Equal(t, []byte{0x81, 0xa3, 'b', 'a', 'd', 0x92, 0x01}, encoder.Bytes())
+}
@@ -0,0 +1,97 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// A map declaring three entries with a nil Raw among its values used to
+// come out one value short, so that the key after it was read as a value.
+func TestWriteRawEmpty(t *testing.T) {
+	write := func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("a")
+		w.WriteInt64(1)
+		w.WriteString("cached")
+		w.WriteRaw(nil)
+		w.WriteString("c")
+		w.WriteInt64(3)
+	}
+	var sizer msgpack.Sizer
+	write(&sizer)
+	assert.EqualError(t, sizer.Err(), "msgpack: WriteRaw of an empty Raw, which is not a value; use WriteNillableRaw to write nil")
+
+	encoder := msgpack.NewEncoder(make([]byte, 64))
+	write(&encoder)
+	var writeErr msgpack.WriteError
+	assert.ErrorAs(t, encoder.Err(), &writeErr)
+	assert.Equal(t, []byte{0x83, 0xa1, 'a', 0x01, 0xa6, 'c', 'a', 'c', 'h', 'e', 'd'}, encoder.Bytes())
+
+	encoder = msgpack.NewEncoder(make([]byte, 64))
+	encoder.WriteRaw(msgpack.Raw{})
+	assert.ErrorAs(t, encoder.Err(), &writeErr)
+
+	encoder = msgpack.NewEncoder(make([]byte, 64))
+	adapter := &msgpack.WriterAdapter{WriterCore: coreWriter{&encoder}}
+	adapter.WriteRaw(nil)
+	assert.ErrorAs(t, adapter.Err(), &writeErr)
+}
+
+func TestNillableRaw(t *testing.T) {
+	cached := encodeWith(t, func(w msgpack.Writer) { w.WriteString("cached") })
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(3)
+		w.WriteNillableRaw(nil)
+		w.WriteNillableRaw(cached)
+		w.WriteAny(msgpack.Raw(nil))
+	})
+	assert.Equal(t, append(append([]byte{0x93, msgpack.FormatNil}, cached...), msgpack.FormatNil), data)
+
+	// A non-nil empty Raw is still not a value.
+	encoder := msgpack.NewEncoder(make([]byte, 64))
+	encoder.WriteNillableRaw(msgpack.Raw{})
+	var writeErr msgpack.WriteError
+	assert.ErrorAs(t, encoder.Err(), &writeErr)
+
+	readers := map[string]func() msgpack.Reader{
+		"Decoder": func() msgpack.Reader {
+			d := msgpack.NewDecoder(data)
+			return &d
+		},
+		"ReaderAdapter": func() msgpack.Reader {
+			d := msgpack.NewDecoder(data)
+			return msgpack.ReaderAdapter{ReaderCore: coreReader{&d}}
+		},
+		"SuspendableDecoder": func() msgpack.Reader {
+			s := msgpack.NewSuspendableDecoder()
+			s.AppendData(data)
+			return s
+		},
+	}
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			r := newReader()
+			size, err := r.ReadArraySize()
+			require.NoError(t, err)
+			assert.Equal(t, uint32(3), size)
+			raw, err := r.ReadNillableRaw()
+			require.NoError(t, err)
+			assert.Nil(t, raw)
+			raw, err = r.ReadNillableRaw()
+			require.NoError(t, err)
+			assert.Equal(t, msgpack.Raw(cached), raw)
+			// ReadRaw keeps the nil value itself.
+			raw, err = r.ReadRaw()
+			require.NoError(t, err)
+			assert.Equal(t, msgpack.Raw{msgpack.FormatNil}, raw)
+		})
+	}
+
+	decoder := msgpack.NewDecoder(nil)
+	_, err := decoder.ReadNillableRaw()
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+}
@@ -0,0 +1,72 @@
+package msgpack
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	// ErrOverflow is matched by an OverflowError with errors.Is.
+	ErrOverflow = errors.New("msgpack: integer overflow")
+	// ErrBadPrefix is matched by a TypeMismatchError with errors.Is.
+	ErrBadPrefix = errors.New("msgpack: bad prefix")
+)
+
+// OverflowError is returned by ReadInt8, ReadInt16, ReadInt32, ReadUint8,
+// ReadUint16 and ReadUint32 when the value read does not fit the type. It
+// matches ErrOverflow with errors.Is. Like RangeError it only holds the
+// details, and formats them when Error is called.
+type OverflowError struct {
+	// Int is the value of a signed read.
+	Int int64
+	// Uint is the value of an unsigned read.
+	Uint uint64
+	// Unsigned is set for the unsigned reads.
+	Unsigned bool
+	// Bits is the width of the type read: 8, 16 or 32.
+	Bits uint8
+	// Offset is the position of the value in the buffer.
+	Offset uint32
+
+	context string
+}
+
+func (e OverflowError) Error() string {
+	value := strconv.FormatInt(e.Int, 10)
+	if e.Unsigned {
+		value = strconv.FormatUint(e.Uint, 10)
+	}
+	// The misspelling is kept so that messages match earlier versions.
+	return e.context + "interger overflow: value = " + value + "; bits = " + strconv.Itoa(int(e.Bits))
+}
+
+func (e OverflowError) Unwrap() error {
+	return ErrOverflow
+}
+
+// WithTerseErrors makes reads return ErrOverflow in place of an
+// OverflowError and ErrBadPrefix in place of a TypeMismatchError, so that
+// these failures allocate nothing. Use it where errors are checked rather
+// than shown, as the messages lose the value, format and offset. A
+// decoder with a read hook or a coercion option still returns the
+// TypeMismatchError they act on.
+func WithTerseErrors() DecOption {
+	return func(o *decOptions) {
+		o.terseErrors = true
+	}
+}
+
+// overflow returns `e`, or ErrOverflow with WithTerseErrors.
+func (d *Decoder) overflow(e OverflowError) error {
+	if d.options.terseErrors {
+		return ErrOverflow
+	}
+	return e
+}
+
+// terseMismatch reports whether type mismatches are returned as
+// ErrBadPrefix.
+func (d *Decoder) terseMismatch() bool {
+	return d.options.terseErrors && d.readHook == nil &&
+		!d.options.stringToNumber && !d.options.numberToString
+}
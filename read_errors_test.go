@@ -0,0 +1,121 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestOverflowError(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		write   func(w msgpack.Writer)
+		read    func(d *msgpack.Decoder) error
+		message string
+	}{
+		{"int8", func(w msgpack.Writer) { w.WriteInt64(300) },
+			func(d *msgpack.Decoder) error { _, err := d.ReadInt8(); return err },
+			"interger overflow: value = 300; bits = 8"},
+		{"int16", func(w msgpack.Writer) { w.WriteInt64(-40000) },
+			func(d *msgpack.Decoder) error { _, err := d.ReadInt16(); return err },
+			"interger overflow: value = -40000; bits = 16"},
+		{"int32", func(w msgpack.Writer) { w.WriteInt64(-1 << 40) },
+			func(d *msgpack.Decoder) error { _, err := d.ReadInt32(); return err },
+			"interger overflow: value = -1099511627776; bits = 32"},
+		{"uint8", func(w msgpack.Writer) { w.WriteUint64(256) },
+			func(d *msgpack.Decoder) error { _, err := d.ReadUint8(); return err },
+			"interger overflow: value = 256; bits = 8"},
+		{"uint16", func(w msgpack.Writer) { w.WriteUint64(70000) },
+			func(d *msgpack.Decoder) error { _, err := d.ReadUint16(); return err },
+			"interger overflow: value = 70000; bits = 16"},
+		{"uint32", func(w msgpack.Writer) { w.WriteUint64(1<<64 - 1) },
+			func(d *msgpack.Decoder) error { _, err := d.ReadUint32(); return err },
+			"interger overflow: value = 18446744073709551615; bits = 32"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := encodeWith(t, func(w msgpack.Writer) {
+				w.WriteNil()
+				tc.write(w)
+			})
+			decoder := msgpack.NewDecoder(data)
+			require.NoError(t, decoder.ConsumeNil())
+			err := tc.read(&decoder)
+			assert.EqualError(t, err, tc.message)
+			assert.ErrorIs(t, err, msgpack.ErrOverflow)
+			var overflow msgpack.OverflowError
+			require.ErrorAs(t, err, &overflow)
+			assert.Equal(t, uint32(1), overflow.Offset)
+
+			decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithTerseErrors())
+			require.NoError(t, decoder.ConsumeNil())
+			assert.Equal(t, msgpack.ErrOverflow, tc.read(&decoder))
+		})
+	}
+}
+
+func TestTypeMismatchErrorIs(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{0xa1, 'a'})
+	_, err := decoder.ReadBool()
+	assert.ErrorIs(t, err, msgpack.ErrBadPrefix)
+	assert.EqualError(t, err, "bad prefix for bool: got fixstr(1) (0xa1) at offset 0")
+
+	decoder = msgpack.NewDecoderWithOptions([]byte{0xa1, 'a'}, msgpack.WithTerseErrors())
+	_, err = decoder.ReadBool()
+	assert.Equal(t, msgpack.ErrBadPrefix, err)
+	_, err = decoder.ReadArraySize()
+	assert.Equal(t, msgpack.ErrBadPrefix, err)
+}
+
+// Coercion and read hooks need the TypeMismatchError, so they work with
+// WithTerseErrors.
+func TestTerseErrorsWithHooks(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteString("42") })
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithTerseErrors(), msgpack.WithStringToNumberCoercion())
+	n, err := decoder.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithTerseErrors())
+	decoder.SetReadHook(func(requested, actual msgpack.ValueKind, r msgpack.ReadHookReader, result *msgpack.ReadHookResult) (bool, error) {
+		return false, nil
+	})
+	_, err = decoder.ReadInt64()
+	var mismatch msgpack.TypeMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+}
+
+// Without WithTerseErrors the only allocation is the error interface
+// holding the value; the message is not formatted until it is asked for.
+func TestReadErrorAllocations(t *testing.T) {
+	overflow := encodeWith(t, func(w msgpack.Writer) { w.WriteInt64(300) })
+	mismatch := encodeWith(t, func(w msgpack.Writer) { w.WriteString("a") })
+	var (
+		decoder msgpack.Decoder
+		err     error
+	)
+	readOverflow := func(start msgpack.Decoder) float64 {
+		return testing.AllocsPerRun(100, func() {
+			decoder = start
+			_, err = decoder.ReadInt8()
+		})
+	}
+	readMismatch := func(start msgpack.Decoder) float64 {
+		return testing.AllocsPerRun(100, func() {
+			decoder = start
+			_, err = decoder.ReadBool()
+		})
+	}
+
+	assert.Zero(t, readOverflow(msgpack.NewDecoderWithOptions(overflow, msgpack.WithTerseErrors())))
+	assert.Equal(t, msgpack.ErrOverflow, err)
+	assert.Zero(t, readMismatch(msgpack.NewDecoderWithOptions(mismatch, msgpack.WithTerseErrors())))
+	assert.Equal(t, msgpack.ErrBadPrefix, err)
+
+	assert.Equal(t, float64(1), readOverflow(msgpack.NewDecoder(overflow)))
+	assert.ErrorIs(t, err, msgpack.ErrOverflow)
+	assert.Equal(t, float64(1), readMismatch(msgpack.NewDecoder(mismatch)))
+	assert.ErrorIs(t, err, msgpack.ErrBadPrefix)
+}
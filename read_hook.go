@@ -0,0 +1,102 @@
+package msgpack
+
+// ReadHook lets a Decoder accept values that a typed read would reject,
+// for migration shims such as reading an int where a float is now
+// expected. It is called by ReadInt64, ReadUint64, ReadFloat32,
+// ReadFloat64, ReadString, ReadByteArray and the reads built on them when
+// they find a value of another kind, with the kind asked for and the kind
+// found.
+//
+// The hook reads the value from `r`, positioned at its start, stores the
+// converted value in the field of `result` for the kind asked for and
+// returns true. It returns false to decline, which makes the read fail
+// with the usual TypeMismatchError, and an error to fail the read with
+// it. The decoder only moves past what the hook read when it returns
+// true. Typed reads on `r` do not call the hook again. The coercions set
+// with WithStringToNumberCoercion and WithNumberToStringCoercion are tried
+// before the hook.
+type ReadHook func(requested, actual ValueKind, r ReadHookReader, result *ReadHookResult) (bool, error)
+
+// ReadHookReader is the part of a Decoder a ReadHook can read with.
+type ReadHookReader interface {
+	PeekFormat() (byte, error)
+	ReadBool() (bool, error)
+	ReadInt64() (int64, error)
+	ReadUint64() (uint64, error)
+	ReadFloat64() (float64, error)
+	ReadString() (string, error)
+	ReadByteArray() ([]byte, error)
+	Skip() error
+}
+
+// ReadHookResult holds the value a ReadHook converted. Only the field for
+// the kind asked for is used: Int for KindInt, Uint for KindUint, Float
+// for KindFloat, String for KindString and Bytes for KindBin.
+type ReadHookResult struct {
+	Int    int64
+	Uint   uint64
+	Float  float64
+	String string
+	Bytes  []byte
+}
+
+// SetReadHook installs `hook`, or removes the hook when it is nil. Without
+// a hook, reads only check for it after they have failed.
+func (d *Decoder) SetReadHook(hook ReadHook) {
+	d.readHook = hook
+}
+
+// hookRead returns the value the coercion options or the read hook give in
+// place of the value that made a typed read fail with `err`, or `err`.
+func hookRead[T any](d *Decoder, err error, pick func(*ReadHookResult) T) (T, error) {
+	var zero T
+	mismatch, ok := err.(TypeMismatchError)
+	if !ok {
+		return zero, err
+	}
+	if d.options.stringToNumber || d.options.numberToString {
+		result, err := d.runHook(coerce, mismatch)
+		if err != nil {
+			return zero, err
+		}
+		if result != nil {
+			return pick(result), nil
+		}
+	}
+	if d.readHook == nil {
+		return zero, err
+	}
+	result, err := d.runHook(d.readHook, mismatch)
+	if err != nil {
+		return zero, err
+	}
+	if result == nil {
+		return zero, mismatch
+	}
+	return pick(result), nil
+}
+
+// runHook calls `hook` for the value of `mismatch` and returns its result,
+// or nil if it declines.
+func (d *Decoder) runHook(hook ReadHook, mismatch TypeMismatchError) (*ReadHookResult, error) {
+	// The hook reads from a copy, so that a declined or failed
+	// conversion leaves the decoder where the failed read left it.
+	probe := *d
+	probe.readHook = nil
+	probe.reader.byteOffset = mismatch.Offset
+	var result ReadHookResult
+	handled, err := hook(mismatch.Expected, mismatch.Actual, &probe, &result)
+	if err != nil || !handled {
+		return nil, err
+	}
+	probe.readHook = d.readHook
+	*d = probe
+	return &result, nil
+}
+
+func hookInt(r *ReadHookResult) int64       { return r.Int }
+func hookUint(r *ReadHookResult) uint64     { return r.Uint }
+func hookFloat(r *ReadHookResult) float64   { return r.Float }
+func hookString(r *ReadHookResult) string   { return r.String }
+func hookBytes(r *ReadHookResult) []byte    { return r.Bytes }
+func hookFloat32(r *ReadHookResult) float32 { return float32(r.Float) }
@@ -0,0 +1,172 @@
+package msgpack_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// intToFloat reads integers written before a field became a float.
+func intToFloat(requested, actual msgpack.ValueKind, r msgpack.ReadHookReader, result *msgpack.ReadHookResult) (bool, error) {
+	if requested != msgpack.KindFloat {
+		return false, nil
+	}
+	switch actual {
+	case msgpack.KindInt:
+		v, err := r.ReadInt64()
+		result.Float = float64(v)
+		return err == nil, err
+	case msgpack.KindUint:
+		v, err := r.ReadUint64()
+		result.Float = float64(v)
+		return err == nil, err
+	}
+	return false, nil
+}
+
+// binToString reads binary values written before a field became a string.
+func binToString(requested, actual msgpack.ValueKind, r msgpack.ReadHookReader, result *msgpack.ReadHookResult) (bool, error) {
+	if requested != msgpack.KindString || actual != msgpack.KindBin {
+		return false, nil
+	}
+	v, err := r.ReadByteArray()
+	result.String = string(v)
+	return err == nil, err
+}
+
+func TestReadHookIntToFloat(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteInt64(-3)
+		w.WriteUint64(1 << 40)
+		w.WriteInt64(7)
+		w.WriteFloat64(1.5)
+		w.WriteString("after")
+	})
+	decoder := msgpack.NewDecoder(data)
+	decoder.SetReadHook(intToFloat)
+
+	f, err := decoder.ReadFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, -3.0, f)
+	f, err = decoder.ReadFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, float64(1<<40), f)
+	f32, err := decoder.ReadFloat32()
+	require.NoError(t, err)
+	assert.Equal(t, float32(7), f32)
+	f, err = decoder.ReadFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+	s, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "after", s)
+}
+
+func TestReadHookBinToString(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteByteArray([]byte("legacy"))
+		w.WriteString("current")
+		w.WriteInt64(1)
+	})
+	decoder := msgpack.NewDecoder(data)
+	decoder.SetReadHook(binToString)
+
+	s, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", s)
+	s, err = decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "current", s)
+	i, err := decoder.ReadInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), i)
+}
+
+func TestReadHookDeclined(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteString("x") })
+	decoder := msgpack.NewDecoder(data)
+	decoder.SetReadHook(func(requested, actual msgpack.ValueKind, r msgpack.ReadHookReader, result *msgpack.ReadHookResult) (bool, error) {
+		// Reads a declining hook makes are not kept.
+		_, err := r.ReadString()
+		return false, err
+	})
+
+	_, err := decoder.ReadFloat64()
+	var mismatch msgpack.TypeMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, msgpack.KindFloat, mismatch.Expected)
+	assert.Equal(t, msgpack.KindString, mismatch.Actual)
+	assert.EqualError(t, err, "bad prefix for float64: got fixstr(1) (0xa1) at offset 0")
+
+	decoder = msgpack.NewDecoder(data)
+	decoder.SetReadHook(intToFloat)
+	_, err = decoder.ReadFloat64()
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, uint32(0), mismatch.Offset)
+}
+
+func TestReadHookError(t *testing.T) {
+	errLegacy := errors.New("legacy values are no longer supported")
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteByteArray([]byte{1}) })
+	decoder := msgpack.NewDecoder(data)
+	decoder.SetReadHook(func(requested, actual msgpack.ValueKind, r msgpack.ReadHookReader, result *msgpack.ReadHookResult) (bool, error) {
+		return false, errLegacy
+	})
+	_, err := decoder.ReadString()
+	assert.ErrorIs(t, err, errLegacy)
+}
+
+func TestReadHookNotReentered(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteBool(true) })
+	calls := 0
+	decoder := msgpack.NewDecoder(data)
+	decoder.SetReadHook(func(requested, actual msgpack.ValueKind, r msgpack.ReadHookReader, result *msgpack.ReadHookResult) (bool, error) {
+		calls++
+		_, err := r.ReadInt64()
+		return err == nil, err
+	})
+	_, err := decoder.ReadInt64()
+	var mismatch msgpack.TypeMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, msgpack.KindBool, mismatch.Actual)
+	assert.Equal(t, 1, calls)
+
+	decoder = msgpack.NewDecoder(data)
+	decoder.SetReadHook(nil)
+	_, err = decoder.ReadInt64()
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, 1, calls)
+}
+
+func BenchmarkTypedReadsNoHook(b *testing.B) {
+	var sizer msgpack.Sizer
+	write := func(w msgpack.Writer) {
+		for i := 0; i < 16; i++ {
+			w.WriteInt64(int64(i) * 1000)
+			w.WriteUint64(uint64(i) * 100000)
+			w.WriteFloat64(float64(i) / 3)
+			w.WriteString("field name")
+			w.WriteByteArray([]byte{1, 2, 3, 4})
+		}
+	}
+	write(&sizer)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	write(&encoder)
+	data := encoder.Bytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decoder := msgpack.NewDecoder(data)
+		for j := 0; j < 16; j++ {
+			decoder.ReadInt64()
+			decoder.ReadUint64()
+			decoder.ReadFloat64()
+			decoder.ReadString()
+			decoder.ReadByteArray()
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+var withRawValues = []func(w msgpack.Writer){
+	func(w msgpack.Writer) { w.WriteNil() },
+	func(w msgpack.Writer) { w.WriteInt64(-300) },
+	func(w msgpack.Writer) { w.WriteUint64(1 << 40) },
+	func(w msgpack.Writer) { w.WriteFloat64(2.5) },
+	func(w msgpack.Writer) { w.WriteString("audit") },
+	func(w msgpack.Writer) { w.WriteByteArray([]byte{1, 2, 3}) },
+	func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("user")
+		w.WriteString("ada")
+		w.WriteString("roles")
+		w.WriteArraySize(2)
+		w.WriteString("admin")
+		w.WriteMapSize(1)
+		w.WriteString("scope")
+		w.WriteInt64(7)
+	},
+}
+
+func TestReadAnyWithRaw(t *testing.T) {
+	var values [][]byte
+	for _, write := range withRawValues {
+		values = append(values, encodeWith(t, write))
+	}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		for _, write := range withRawValues {
+			write(w)
+		}
+	})
+
+	readers := map[string]func() msgpack.Reader{
+		"Decoder": func() msgpack.Reader {
+			d := msgpack.NewDecoder(data)
+			return &d
+		},
+		"ReaderAdapter": func() msgpack.Reader {
+			d := msgpack.NewDecoder(data)
+			return msgpack.ReaderAdapter{ReaderCore: coreReader{&d}}
+		},
+		"SuspendableDecoder": func() msgpack.Reader {
+			s := msgpack.NewSuspendableDecoder()
+			s.AppendData(data)
+			return s
+		},
+	}
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			r := newReader()
+			plain := msgpack.NewDecoder(data)
+			for _, encoded := range values {
+				value, raw, err := r.ReadAnyWithRaw()
+				require.NoError(t, err)
+				assert.Equal(t, msgpack.Raw(encoded), raw)
+
+				expected, err := plain.ReadAny()
+				require.NoError(t, err)
+				assert.Equal(t, expected, value)
+
+				again := msgpack.NewDecoder(raw)
+				redecoded, err := again.ReadAny()
+				require.NoError(t, err)
+				assert.Equal(t, value, redecoded)
+			}
+		})
+	}
+}
+
+// A value that fails part way returns no Raw and leaves the decoder where
+// ReadAny does.
+func TestReadAnyWithRawErrors(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"bad prefix": {0x92, 0x01, 0xc1},
+		"truncated":  {0x93, 0x01, 0xa3, 'a'},
+	} {
+		t.Run(name, func(t *testing.T) {
+			plain := msgpack.NewDecoder(data)
+			_, expected := plain.ReadAny()
+			require.Error(t, expected)
+
+			decoder := msgpack.NewDecoder(data)
+			value, raw, err := decoder.ReadAnyWithRaw()
+			assert.Equal(t, expected, err)
+			assert.Nil(t, value)
+			assert.Nil(t, raw)
+			assert.Equal(t, plain.Offset(), decoder.Offset())
+		})
+	}
+}
+
+func TestDecodeWithRaw(t *testing.T) {
+	message := &poolMessage{ID: 7, Body: "signed"}
+	encoded, err := msgpack.ToBytes(message)
+	require.NoError(t, err)
+	data := append(append([]byte{}, encoded...), 0xc3)
+
+	check := func(t *testing.T, r msgpack.Reader) {
+		value, raw, err := msgpack.DecodeWithRaw[poolMessage](r)
+		require.NoError(t, err)
+		assert.Equal(t, *message, value)
+		assert.Equal(t, msgpack.Raw(encoded), raw)
+		next, err := r.ReadBool()
+		require.NoError(t, err)
+		assert.True(t, next)
+
+		again := msgpack.NewDecoder(raw)
+		redecoded, err := msgpack.Decode[poolMessage](&again)
+		require.NoError(t, err)
+		assert.Equal(t, value, redecoded)
+	}
+
+	decoder := msgpack.NewDecoder(data)
+	check(t, &decoder)
+	decoder = msgpack.NewDecoder(data)
+	check(t, msgpack.ReaderAdapter{ReaderCore: coreReader{&decoder}})
+	s := msgpack.NewSuspendableDecoder()
+	s.AppendData(data)
+	check(t, s)
+}
+
+func TestDecodeWithRawErrors(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		(&sloppyPair{A: 1, B: 1000}).Encode(w)
+	})
+
+	// A read error the Decode method dropped is still reported.
+	decoder := msgpack.NewDecoder(data[:len(data)-1])
+	_, raw, err := msgpack.DecodeWithRaw[sloppyPair](&decoder)
+	var unchecked msgpack.UncheckedReadError
+	assert.ErrorAs(t, err, &unchecked)
+	assert.Nil(t, raw)
+
+	decoder = msgpack.NewDecoder([]byte{0xc3})
+	_, raw, err = msgpack.DecodeWithRaw[poolMessage](&decoder)
+	assert.Error(t, err)
+	assert.Nil(t, raw)
+
+	// A SuspendableDecoder asks for the rest of the value.
+	s := msgpack.NewSuspendableDecoder()
+	s.AppendData(data[:len(data)-1])
+	_, raw, err = msgpack.DecodeWithRaw[sloppyPair](s)
+	assert.ErrorIs(t, err, msgpack.ErrNeedMore)
+	assert.Nil(t, raw)
+	s.AppendData(data[len(data)-1:])
+	pair, raw, err := msgpack.DecodeWithRaw[sloppyPair](s)
+	require.NoError(t, err)
+	assert.Equal(t, sloppyPair{A: 1, B: 1000}, pair)
+	assert.Equal(t, msgpack.Raw(data), raw)
+}
@@ -0,0 +1,161 @@
+package msgpack
+
+import (
+	"encoding/binary"
+)
+
+// HeaderMark records the position of a header reserved by one of the
+// Reserve methods so it can be filled in once the length is known.
+type HeaderMark struct {
+	offset uint32
+}
+
+// ReserveMapSize writes a placeholder map32 header to be completed by
+// PatchMapSize once the number of entries is known.
+func (e *Encoder) ReserveMapSize() HeaderMark {
+	return e.reserveHeader(FormatMap32)
+}
+
+// PatchMapSize fills in a header reserved with ReserveMapSize.
+func (e *Encoder) PatchMapSize(mark HeaderMark, length uint32) {
+	e.patchHeader(mark, FormatMap32, length)
+}
+
+// ReserveArraySize writes a placeholder array32 header to be completed by
+// PatchArraySize once the number of elements is known.
+func (e *Encoder) ReserveArraySize() HeaderMark {
+	return e.reserveHeader(FormatArray32)
+}
+
+// PatchArraySize fills in a header reserved with ReserveArraySize.
+func (e *Encoder) PatchArraySize(mark HeaderMark, length uint32) {
+	e.patchHeader(mark, FormatArray32, length)
+}
+
+// ReserveStringHeader writes a placeholder str32 header. The string bytes
+// are then written with WriteRawBytes and the length set with
+// PatchStringHeader.
+func (e *Encoder) ReserveStringHeader() HeaderMark {
+	if e.options.stringTable {
+		e.strings.reserve(true)
+	}
+	return e.reserveHeader(FormatString32)
+}
+
+// PatchStringHeader fills in a header reserved with ReserveStringHeader.
+func (e *Encoder) PatchStringHeader(mark HeaderMark, length uint32) {
+	e.patchHeader(mark, FormatString32, length)
+	if e.options.stringTable {
+		e.strings.patch()
+	}
+}
+
+// ReserveBinHeader writes a placeholder bin32 header. The payload is then
+// written with WriteRawBytes and the length set with PatchBinHeader.
+func (e *Encoder) ReserveBinHeader() HeaderMark {
+	if e.options.stringTable {
+		e.strings.reserve(false)
+	}
+	return e.reserveHeader(FormatBin32)
+}
+
+// PatchBinHeader fills in a header reserved with ReserveBinHeader.
+func (e *Encoder) PatchBinHeader(mark HeaderMark, length uint32) {
+	e.patchHeader(mark, FormatBin32, length)
+	if e.options.stringTable {
+		e.strings.patch()
+	}
+}
+
+// WriteRawBytes copies `value` into the buffer without any header. It is
+// used to stream the payload of a reserved string or bin header, or to
+// write values that are already encoded.
+func (e *Encoder) WriteRawBytes(value []byte) {
+	e.reader.SetBytes(value)
+	if e.options.stringTable && e.reader.err == nil {
+		e.strings.rawBytes(value, e.options.stringTableExt)
+	}
+}
+
+func (e *Encoder) reserveHeader(format byte) HeaderMark {
+	mark := HeaderMark{offset: e.reader.byteOffset}
+	e.write4(format, 0)
+	return mark
+}
+
+func (e *Encoder) patchHeader(mark HeaderMark, format byte, length uint32) {
+	if e.reader.err != nil {
+		return
+	}
+	if mark.offset+5 > e.reader.byteOffset || e.reader.buffer[mark.offset] != format {
+		e.reader.err = WriteError{message: "msgpack: invalid header mark"}
+		return
+	}
+	binary.BigEndian.PutUint32(e.reader.buffer[mark.offset+1:], length)
+}
+
+func (s *Sizer) ReserveMapSize() HeaderMark {
+	s.length += 5
+	return HeaderMark{}
+}
+
+func (s *Sizer) PatchMapSize(mark HeaderMark, length uint32) {}
+
+func (s *Sizer) ReserveArraySize() HeaderMark {
+	s.length += 5
+	return HeaderMark{}
+}
+
+func (s *Sizer) PatchArraySize(mark HeaderMark, length uint32) {}
+
+func (s *Sizer) ReserveStringHeader() HeaderMark {
+	if s.options.stringTable {
+		s.strings.reserve(true)
+	}
+	s.length += 5
+	return HeaderMark{}
+}
+
+func (s *Sizer) PatchStringHeader(mark HeaderMark, length uint32) {
+	if s.options.stringTable {
+		s.strings.patch()
+	}
+}
+
+func (s *Sizer) ReserveBinHeader() HeaderMark {
+	if s.options.stringTable {
+		s.strings.reserve(false)
+	}
+	s.length += 5
+	return HeaderMark{}
+}
+
+func (s *Sizer) PatchBinHeader(mark HeaderMark, length uint32) {
+	if s.options.stringTable {
+		s.strings.patch()
+	}
+}
+
+func (s *Sizer) WriteRawBytes(value []byte) {
+	s.length += uint32(len(value))
+	if s.options.stringTable {
+		s.strings.rawBytes(value, s.options.stringTableExt)
+	}
+}
+
+// SubEncoder returns an encoder over the next `n` bytes of the buffer and
+// moves this encoder past them, so a value can be encoded in place after a
+// header that already declares its size. The sub-encoder shares this
+// encoder's options but not its string table. If `n` bytes do not fit, a
+// range error is recorded and the sub-encoder has no room.
+func (e *Encoder) SubEncoder(n uint32) Encoder {
+	sub := Encoder{options: e.options}
+	if err := e.reader.checkBufferSize(n); err != nil {
+		sub.reader.err = err
+		return sub
+	}
+	start := e.reader.byteOffset
+	sub.reader.buffer = e.reader.buffer[start : start+n : start+n]
+	e.reader.byteOffset += n
+	return sub
+}
@@ -0,0 +1,98 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type filteredScores struct {
+	Scores map[string]int64
+	Min    int64
+}
+
+func (f *filteredScores) Encode(encoder msgpack.Writer) error {
+	mark := encoder.ReserveMapSize()
+	count := uint32(0)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		value, ok := f.Scores[key]
+		if !ok || value < f.Min {
+			continue
+		}
+		encoder.WriteString(key)
+		encoder.WriteInt64(value)
+		count++
+	}
+	encoder.PatchMapSize(mark, count)
+	return encoder.Err()
+}
+
+func (f *filteredScores) Decode(decoder msgpack.Reader) error {
+	size, err := decoder.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	f.Scores = make(map[string]int64, size)
+	for size > 0 {
+		size--
+		key, err := decoder.ReadString()
+		if err != nil {
+			return err
+		}
+		if f.Scores[key], err = decoder.ReadInt64(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestReserveMapSize(t *testing.T) {
+	value := filteredScores{
+		Scores: map[string]int64{"a": 1, "b": 10, "c": 5, "d": 20},
+		Min:    5,
+	}
+	data, err := msgpack.ToBytes(&value)
+	require.NoError(t, err)
+	assert.Equal(t, byte(msgpack.FormatMap32), data[0])
+
+	var decoded filteredScores
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, decoded.Decode(&decoder))
+	assert.Equal(t, map[string]int64{"b": 10, "c": 5, "d": 20}, decoded.Scores)
+}
+
+func TestReserveHeaders(t *testing.T) {
+	write := func(w msgpack.Writer) {
+		array := w.ReserveArraySize()
+		str := w.ReserveStringHeader()
+		w.WriteRawBytes([]byte("he"))
+		w.WriteRawBytes([]byte("llo"))
+		w.PatchStringHeader(str, 5)
+		bin := w.ReserveBinHeader()
+		w.WriteRawBytes([]byte{1, 2, 3})
+		w.PatchBinHeader(bin, 3)
+		w.PatchArraySize(array, 2)
+	}
+	var sizer msgpack.Sizer
+	write(&sizer)
+	buffer := make([]byte, sizer.Len())
+	encoder := msgpack.NewEncoder(buffer)
+	write(&encoder)
+	require.NoError(t, encoder.Err())
+	assert.Equal(t, sizer.Len(), encoder.Len())
+
+	decoder := msgpack.NewDecoder(encoder.Bytes())
+	value, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, []any{"hello", []byte{1, 2, 3}}, value)
+}
+
+func TestPatchWrongHeader(t *testing.T) {
+	encoder := msgpack.NewEncoder(make([]byte, 16))
+	mark := encoder.ReserveArraySize()
+	encoder.PatchMapSize(mark, 1)
+	assert.Error(t, encoder.Err())
+}
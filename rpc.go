@@ -0,0 +1,179 @@
+package msgpack
+
+import (
+	"strconv"
+)
+
+// RPCType is the message type tag at the head of every msgpack-RPC message.
+type RPCType uint8
+
+const (
+	RPCRequest      RPCType = 0
+	RPCResponse     RPCType = 1
+	RPCNotification RPCType = 2
+)
+
+func (t RPCType) String() string {
+	switch t {
+	case RPCRequest:
+		return "request"
+	case RPCResponse:
+		return "response"
+	case RPCNotification:
+		return "notification"
+	}
+	return "unknown(" + strconv.FormatUint(uint64(t), 10) + ")"
+}
+
+// RPCMessage is a decoded msgpack-RPC message. Only the fields that apply
+// to Type are populated. The payload positions are left encoded so callers
+// can decode them with their own codecs.
+type RPCMessage struct {
+	Type   RPCType
+	MsgID  uint32
+	Method string
+	Params Raw
+	Error  Raw
+	Result Raw
+}
+
+// EncodeRequest writes `[0, msgid, method, params]`. A nil `params`
+// writes an empty array.
+func EncodeRequest(w Writer, msgid uint32, method string, params func(Writer) error) error {
+	w.WriteArraySize(4)
+	w.WriteUint8(uint8(RPCRequest))
+	w.WriteUint32(msgid)
+	w.WriteString(method)
+	if err := writeRPCParams(w, params); err != nil {
+		return err
+	}
+	return w.Err()
+}
+
+// EncodeResponse writes `[1, msgid, error, result]`. A nil `errValue` or
+// `result` writes nil in that position.
+func EncodeResponse(w Writer, msgid uint32, errValue func(Writer) error, result func(Writer) error) error {
+	w.WriteArraySize(4)
+	w.WriteUint8(uint8(RPCResponse))
+	w.WriteUint32(msgid)
+	if err := writeRPCValue(w, errValue); err != nil {
+		return err
+	}
+	if err := writeRPCValue(w, result); err != nil {
+		return err
+	}
+	return w.Err()
+}
+
+// EncodeNotification writes `[2, method, params]`. A nil `params` writes
+// an empty array.
+func EncodeNotification(w Writer, method string, params func(Writer) error) error {
+	w.WriteArraySize(3)
+	w.WriteUint8(uint8(RPCNotification))
+	w.WriteString(method)
+	if err := writeRPCParams(w, params); err != nil {
+		return err
+	}
+	return w.Err()
+}
+
+func writeRPCParams(w Writer, params func(Writer) error) error {
+	if params == nil {
+		w.WriteArraySize(0)
+		return nil
+	}
+	return params(w)
+}
+
+func writeRPCValue(w Writer, value func(Writer) error) error {
+	if value == nil {
+		w.WriteNil()
+		return nil
+	}
+	return value(w)
+}
+
+// DecodeMessage reads any of the three msgpack-RPC message shapes.
+func DecodeMessage(r Reader) (RPCMessage, error) {
+	var msg RPCMessage
+	size, err := r.ReadArraySize()
+	if err != nil {
+		return msg, rpcError{"message must be an array", err}
+	}
+	if size == 0 {
+		return msg, rpcReadError("empty message array")
+	}
+	tag, err := r.ReadUint8()
+	if err != nil {
+		return msg, rpcError{"message type must be an integer", err}
+	}
+	msg.Type = RPCType(tag)
+
+	switch msg.Type {
+	case RPCRequest:
+		if size != 4 {
+			return msg, rpcSizeError(msg.Type, 4, size)
+		}
+		if msg.MsgID, err = r.ReadUint32(); err != nil {
+			return msg, rpcError{"bad msgid", err}
+		}
+		if msg.Method, err = r.ReadString(); err != nil {
+			return msg, rpcError{"bad method", err}
+		}
+		if msg.Params, err = r.ReadRaw(); err != nil {
+			return msg, rpcError{"bad params", err}
+		}
+	case RPCResponse:
+		if size != 4 {
+			return msg, rpcSizeError(msg.Type, 4, size)
+		}
+		if msg.MsgID, err = r.ReadUint32(); err != nil {
+			return msg, rpcError{"bad msgid", err}
+		}
+		if msg.Error, err = r.ReadRaw(); err != nil {
+			return msg, rpcError{"bad error", err}
+		}
+		if msg.Result, err = r.ReadRaw(); err != nil {
+			return msg, rpcError{"bad result", err}
+		}
+	case RPCNotification:
+		if size != 3 {
+			return msg, rpcSizeError(msg.Type, 3, size)
+		}
+		if msg.Method, err = r.ReadString(); err != nil {
+			return msg, rpcError{"bad method", err}
+		}
+		if msg.Params, err = r.ReadRaw(); err != nil {
+			return msg, rpcError{"bad params", err}
+		}
+	default:
+		return msg, rpcReadError("unknown message type " + msg.Type.String())
+	}
+
+	return msg, nil
+}
+
+// rpcError reports the part of a message that could not be read, wrapping
+// the error that reading it returned.
+type rpcError struct {
+	message string
+	err     error
+}
+
+func (e rpcError) Error() string {
+	return "msgpack-rpc: " + e.message + ": " + e.err.Error()
+}
+
+func (e rpcError) Unwrap() error {
+	return e.err
+}
+
+func rpcReadError(message string) error {
+	return ReadError{"msgpack-rpc: " + message}
+}
+
+func rpcSizeError(t RPCType, expected, actual uint32) error {
+	return rpcReadError(t.String() + " must have " +
+		strconv.FormatUint(uint64(expected), 10) + " elements, got " +
+		strconv.FormatUint(uint64(actual), 10))
+}
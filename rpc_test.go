@@ -0,0 +1,137 @@
+package msgpack_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// Fixtures built by hand from the msgpack-RPC specification, in the shape
+// of the messages neovim sends.
+var (
+	nvimRequest       = "9400" + "01" + "b5" + hex.EncodeToString([]byte("nvim_get_current_line")) + "90"
+	nvimResponse      = "9401" + "01" + "c0" + "a5" + hex.EncodeToString([]byte("hello"))
+	nvimErrorResponse = "9401" + "02" + "9200" + "ae" + hex.EncodeToString([]byte("Invalid method")) + "c0"
+	nvimNotification  = "9302" + "b4" + hex.EncodeToString([]byte("nvim_buf_lines_event")) + "920102"
+)
+
+func encodeRPC(t *testing.T, fn func(w msgpack.Writer) error) []byte {
+	t.Helper()
+	var sizer msgpack.Sizer
+	require.NoError(t, fn(&sizer))
+	buffer := make([]byte, sizer.Len())
+	encoder := msgpack.NewEncoder(buffer)
+	require.NoError(t, fn(&encoder))
+	return buffer
+}
+
+func TestRPCRequest(t *testing.T) {
+	data := encodeRPC(t, func(w msgpack.Writer) error {
+		return msgpack.EncodeRequest(w, 1, "nvim_get_current_line", nil)
+	})
+	assert.Equal(t, nvimRequest, hex.EncodeToString(data))
+
+	decoder := msgpack.NewDecoder(data)
+	msg, err := msgpack.DecodeMessage(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.RPCRequest, msg.Type)
+	assert.Equal(t, uint32(1), msg.MsgID)
+	assert.Equal(t, "nvim_get_current_line", msg.Method)
+	assert.Equal(t, msgpack.Raw{0x90}, msg.Params)
+}
+
+func TestRPCResponse(t *testing.T) {
+	data := encodeRPC(t, func(w msgpack.Writer) error {
+		return msgpack.EncodeResponse(w, 1, nil, func(w msgpack.Writer) error {
+			w.WriteString("hello")
+			return nil
+		})
+	})
+	assert.Equal(t, nvimResponse, hex.EncodeToString(data))
+
+	data, _ = hex.DecodeString(nvimErrorResponse)
+	decoder := msgpack.NewDecoder(data)
+	msg, err := msgpack.DecodeMessage(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.RPCResponse, msg.Type)
+	assert.Equal(t, uint32(2), msg.MsgID)
+	assert.Equal(t, msgpack.Raw{0xc0}, msg.Result)
+
+	errDecoder := msgpack.NewDecoder(msg.Error)
+	size, err := errDecoder.ReadArraySize()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), size)
+	require.NoError(t, errDecoder.Skip())
+	text, err := errDecoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "Invalid method", text)
+}
+
+func TestRPCNotification(t *testing.T) {
+	data := encodeRPC(t, func(w msgpack.Writer) error {
+		return msgpack.EncodeNotification(w, "nvim_buf_lines_event", func(w msgpack.Writer) error {
+			w.WriteArraySize(2)
+			w.WriteInt64(1)
+			w.WriteInt64(2)
+			return nil
+		})
+	})
+	assert.Equal(t, nvimNotification, hex.EncodeToString(data))
+
+	decoder := msgpack.NewDecoder(data)
+	msg, err := msgpack.DecodeMessage(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.RPCNotification, msg.Type)
+	assert.Equal(t, "nvim_buf_lines_event", msg.Method)
+	assert.Equal(t, msgpack.Raw{0x92, 0x01, 0x02}, msg.Params)
+}
+
+func TestRPCMalformed(t *testing.T) {
+	tests := map[string]struct {
+		data     string
+		contains string
+	}{
+		"not an array":      {"a3666f6f", "must be an array"},
+		"empty array":       {"90", "empty message array"},
+		"string type tag":   {"93a130a3666f6f90", "type must be an integer"},
+		"short request":     {"930001a3666f6f", "request must have 4 elements, got 3"},
+		"long notification": {"9402a3666f6f9090", "notification must have 3 elements, got 4"},
+		"unknown type":      {"9303a3666f6f90", "unknown message type unknown(3)"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.data)
+			require.NoError(t, err)
+			decoder := msgpack.NewDecoder(data)
+			_, err = msgpack.DecodeMessage(&decoder)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.contains)
+		})
+	}
+}
+
+func TestRPCMalformedWrapsCause(t *testing.T) {
+	// The msgid is a string.
+	decoder := msgpack.NewDecoder([]byte{0x94, 0x00, 0xa1, 'x', 0xa3, 'f', 'o', 'o', 0x90})
+	_, err := msgpack.DecodeMessage(&decoder)
+	var mismatch msgpack.TypeMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.ErrorIs(t, err, msgpack.ErrBadPrefix)
+	assert.Contains(t, err.Error(), "msgpack-rpc: bad msgid: ")
+
+	// The params are cut short.
+	decoder = msgpack.NewDecoder([]byte{0x94, 0x00, 0x01, 0xa3, 'f', 'o', 'o', 0x92, 0x01})
+	_, err = msgpack.DecodeMessage(&decoder)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.Contains(t, err.Error(), "msgpack-rpc: bad params: ")
+
+	// Errors without a cause are ReadErrors.
+	decoder = msgpack.NewDecoder([]byte{0x90})
+	_, err = msgpack.DecodeMessage(&decoder)
+	var readErr msgpack.ReadError
+	assert.ErrorAs(t, err, &readErr)
+}
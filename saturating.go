@@ -0,0 +1,227 @@
+package msgpack
+
+import "math"
+
+// The Saturating and Truncating reads below accept any integer format, of
+// either sign, and never fail for a value that does not fit the requested
+// type. Saturating reads clamp the value to the type's minimum or maximum;
+// Truncating reads keep its low bits in two's complement, as a Go
+// conversion would. The plain ReadIntN and ReadUintN methods still return
+// an error for such values.
+
+func (d *Decoder) ReadInt8Saturating() (int8, error) {
+	v, err := d.readSaturatingInt(math.MinInt8, math.MaxInt8)
+	return int8(v), err
+}
+
+func (d *Decoder) ReadInt16Saturating() (int16, error) {
+	v, err := d.readSaturatingInt(math.MinInt16, math.MaxInt16)
+	return int16(v), err
+}
+
+func (d *Decoder) ReadInt32Saturating() (int32, error) {
+	v, err := d.readSaturatingInt(math.MinInt32, math.MaxInt32)
+	return int32(v), err
+}
+
+func (d *Decoder) ReadInt64Saturating() (int64, error) {
+	return d.readSaturatingInt(math.MinInt64, math.MaxInt64)
+}
+
+func (d *Decoder) ReadUint8Saturating() (uint8, error) {
+	v, err := d.readSaturatingUint(math.MaxUint8)
+	return uint8(v), err
+}
+
+func (d *Decoder) ReadUint16Saturating() (uint16, error) {
+	v, err := d.readSaturatingUint(math.MaxUint16)
+	return uint16(v), err
+}
+
+func (d *Decoder) ReadUint32Saturating() (uint32, error) {
+	v, err := d.readSaturatingUint(math.MaxUint32)
+	return uint32(v), err
+}
+
+func (d *Decoder) ReadUint64Saturating() (uint64, error) {
+	return d.readSaturatingUint(math.MaxUint64)
+}
+
+func (d *Decoder) ReadInt8Truncating() (int8, error) {
+	v, err := d.readIntegerBits()
+	return int8(v), err
+}
+
+func (d *Decoder) ReadInt16Truncating() (int16, error) {
+	v, err := d.readIntegerBits()
+	return int16(v), err
+}
+
+func (d *Decoder) ReadInt32Truncating() (int32, error) {
+	v, err := d.readIntegerBits()
+	return int32(v), err
+}
+
+func (d *Decoder) ReadInt64Truncating() (int64, error) {
+	v, err := d.readIntegerBits()
+	return int64(v), err
+}
+
+func (d *Decoder) ReadUint8Truncating() (uint8, error) {
+	v, err := d.readIntegerBits()
+	return uint8(v), err
+}
+
+func (d *Decoder) ReadUint16Truncating() (uint16, error) {
+	v, err := d.readIntegerBits()
+	return uint16(v), err
+}
+
+func (d *Decoder) ReadUint32Truncating() (uint32, error) {
+	v, err := d.readIntegerBits()
+	return uint32(v), err
+}
+
+func (d *Decoder) ReadUint64Truncating() (uint64, error) {
+	return d.readIntegerBits()
+}
+
+func (d *Decoder) ReadNillableInt8Saturating() (*int8, error) {
+	return readNillableWith(d, d.ReadInt8Saturating)
+}
+
+func (d *Decoder) ReadNillableInt16Saturating() (*int16, error) {
+	return readNillableWith(d, d.ReadInt16Saturating)
+}
+
+func (d *Decoder) ReadNillableInt32Saturating() (*int32, error) {
+	return readNillableWith(d, d.ReadInt32Saturating)
+}
+
+func (d *Decoder) ReadNillableInt64Saturating() (*int64, error) {
+	return readNillableWith(d, d.ReadInt64Saturating)
+}
+
+func (d *Decoder) ReadNillableUint8Saturating() (*uint8, error) {
+	return readNillableWith(d, d.ReadUint8Saturating)
+}
+
+func (d *Decoder) ReadNillableUint16Saturating() (*uint16, error) {
+	return readNillableWith(d, d.ReadUint16Saturating)
+}
+
+func (d *Decoder) ReadNillableUint32Saturating() (*uint32, error) {
+	return readNillableWith(d, d.ReadUint32Saturating)
+}
+
+func (d *Decoder) ReadNillableUint64Saturating() (*uint64, error) {
+	return readNillableWith(d, d.ReadUint64Saturating)
+}
+
+func (d *Decoder) ReadNillableInt8Truncating() (*int8, error) {
+	return readNillableWith(d, d.ReadInt8Truncating)
+}
+
+func (d *Decoder) ReadNillableInt16Truncating() (*int16, error) {
+	return readNillableWith(d, d.ReadInt16Truncating)
+}
+
+func (d *Decoder) ReadNillableInt32Truncating() (*int32, error) {
+	return readNillableWith(d, d.ReadInt32Truncating)
+}
+
+func (d *Decoder) ReadNillableInt64Truncating() (*int64, error) {
+	return readNillableWith(d, d.ReadInt64Truncating)
+}
+
+func (d *Decoder) ReadNillableUint8Truncating() (*uint8, error) {
+	return readNillableWith(d, d.ReadUint8Truncating)
+}
+
+func (d *Decoder) ReadNillableUint16Truncating() (*uint16, error) {
+	return readNillableWith(d, d.ReadUint16Truncating)
+}
+
+func (d *Decoder) ReadNillableUint32Truncating() (*uint32, error) {
+	return readNillableWith(d, d.ReadUint32Truncating)
+}
+
+func (d *Decoder) ReadNillableUint64Truncating() (*uint64, error) {
+	return readNillableWith(d, d.ReadUint64Truncating)
+}
+
+func readNillableWith[T any](r ReaderCore, read func() (T, error)) (*T, error) {
+	isNil, err := readNil(r)
+	if isNil || err != nil {
+		return nil, err
+	}
+	val, err := read()
+	if err != nil {
+		return nil, err
+	}
+	return &val, nil
+}
+
+// readInteger reads an integer of any format. Values above math.MaxInt64
+// are returned in `u` with `unsigned` set, all others in `v`.
+func (d *Decoder) readInteger() (v int64, u uint64, unsigned bool, err error) {
+	prefix, err := d.reader.PeekUint8()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if prefix == FormatUint64 {
+		u, err = d.ReadUint64()
+		if u > math.MaxInt64 {
+			return 0, u, true, err
+		}
+		return int64(u), 0, false, err
+	}
+	switch prefix {
+	case FormatUint8, FormatUint16, FormatUint32:
+		u, err = d.ReadUint64()
+		return int64(u), 0, false, err
+	}
+	v, err = d.ReadInt64()
+	return v, 0, false, err
+}
+
+func (d *Decoder) readSaturatingInt(min, max int64) (int64, error) {
+	v, _, unsigned, err := d.readInteger()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case unsigned || v > max:
+		return max, nil
+	case v < min:
+		return min, nil
+	}
+	return v, nil
+}
+
+func (d *Decoder) readSaturatingUint(max uint64) (uint64, error) {
+	v, u, unsigned, err := d.readInteger()
+	if err != nil {
+		return 0, err
+	}
+	if !unsigned {
+		if v < 0 {
+			return 0, nil
+		}
+		u = uint64(v)
+	}
+	if u > max {
+		return max, nil
+	}
+	return u, nil
+}
+
+// readIntegerBits reads an integer of any format as its 64 bit two's
+// complement representation.
+func (d *Decoder) readIntegerBits() (uint64, error) {
+	v, u, unsigned, err := d.readInteger()
+	if unsigned {
+		return u, err
+	}
+	return uint64(v), err
+}
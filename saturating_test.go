@@ -0,0 +1,130 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestReadInt32SaturatingAndTruncating(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      func(w msgpack.Writer)
+		saturating int32
+		truncating int32
+		fits       bool
+	}{
+		{"max", func(w msgpack.Writer) { w.WriteInt64(math.MaxInt32) }, math.MaxInt32, math.MaxInt32, true},
+		{"max+1", func(w msgpack.Writer) { w.WriteInt64(math.MaxInt32 + 1) }, math.MaxInt32, math.MinInt32, false},
+		{"min", func(w msgpack.Writer) { w.WriteInt64(math.MinInt32) }, math.MinInt32, math.MinInt32, true},
+		{"min-1", func(w msgpack.Writer) { w.WriteInt64(math.MinInt32 - 1) }, math.MinInt32, math.MaxInt32, false},
+		{"uint max+1", func(w msgpack.Writer) { w.WriteUint64(math.MaxInt32 + 1) }, math.MaxInt32, math.MinInt32, false},
+		{"uint64 max", func(w msgpack.Writer) { w.WriteUint64(math.MaxUint64) }, math.MaxInt32, -1, false},
+		{"fixint", func(w msgpack.Writer) { w.WriteInt64(-5) }, -5, -5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeWith(t, tt.write)
+
+			decoder := msgpack.NewDecoder(data)
+			v, err := decoder.ReadInt32Saturating()
+			require.NoError(t, err)
+			assert.Equal(t, tt.saturating, v)
+
+			decoder = msgpack.NewDecoder(data)
+			v, err = decoder.ReadInt32Truncating()
+			require.NoError(t, err)
+			assert.Equal(t, tt.truncating, v)
+
+			// The default read is unchanged.
+			decoder = msgpack.NewDecoder(data)
+			v, err = decoder.ReadInt32()
+			if tt.fits {
+				require.NoError(t, err)
+				assert.Equal(t, tt.saturating, v)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestReadInt32OverflowErrorUnchanged(t *testing.T) {
+	decoder := msgpack.NewDecoder(encodeWith(t, func(w msgpack.Writer) { w.WriteInt64(math.MaxInt32 + 1) }))
+	_, err := decoder.ReadInt32()
+	assert.EqualError(t, err, "interger overflow: value = 2147483648; bits = 32")
+}
+
+func TestReadUintSaturatingAndTruncating(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(8)
+		w.WriteInt64(-1)
+		w.WriteInt64(-1)
+		w.WriteUint64(math.MaxUint8 + 1)
+		w.WriteUint64(math.MaxUint8 + 1)
+		w.WriteUint64(math.MaxUint64)
+		w.WriteInt64(math.MinInt64)
+		w.WriteUint64(math.MaxUint64)
+		w.WriteInt64(-2)
+	})
+	decoder := msgpack.NewDecoder(data)
+	_, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+
+	u8, err := decoder.ReadUint8Saturating()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(0), u8)
+	u8, err = decoder.ReadUint8Truncating()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(math.MaxUint8), u8)
+	u8, err = decoder.ReadUint8Saturating()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(math.MaxUint8), u8)
+	u8, err = decoder.ReadUint8Truncating()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(0), u8)
+	i64, err := decoder.ReadInt64Saturating()
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), i64)
+	u64, err := decoder.ReadUint64Saturating()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), u64)
+	i64, err = decoder.ReadInt64Truncating()
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), i64)
+	u64, err = decoder.ReadUint64Truncating()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64-1), u64)
+	assert.Equal(t, uint32(0), decoder.Remaining())
+}
+
+func TestReadNillableSaturating(t *testing.T) {
+	decoder := msgpack.NewDecoder(encodeWith(t, func(w msgpack.Writer) {
+		w.WriteNil()
+		w.WriteInt64(1 << 20)
+		w.WriteNil()
+		w.WriteInt64(1 << 20)
+	}))
+	v, err := decoder.ReadNillableInt16Saturating()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+	v, err = decoder.ReadNillableInt16Saturating()
+	require.NoError(t, err)
+	require.NotNil(t, v)
+	assert.Equal(t, int16(math.MaxInt16), *v)
+	v, err = decoder.ReadNillableInt16Truncating()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+	v, err = decoder.ReadNillableInt16Truncating()
+	require.NoError(t, err)
+	require.NotNil(t, v)
+	assert.Equal(t, int16(0), *v)
+
+	decoder = msgpack.NewDecoder([]byte{0xa1, 'x'})
+	_, err = decoder.ReadInt32Saturating()
+	assert.Error(t, err)
+}
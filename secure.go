@@ -0,0 +1,15 @@
+package msgpack
+
+import "crypto/subtle"
+
+// SecureCompareString reports whether `a` and `b` are equal in time that
+// depends only on their lengths, for checking decoded secrets such as API
+// tokens against an expected value.
+func SecureCompareString(a, b string) bool {
+	return subtle.ConstantTimeCompare(UnsafeBytes(a), UnsafeBytes(b)) == 1
+}
+
+// SecureCompareBytes is SecureCompareString for byte slices.
+func SecureCompareBytes(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
@@ -0,0 +1,31 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestSecureCompare(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("s3cr3t-token")
+		w.WriteByteArray([]byte{1, 2, 3})
+	})
+	decoder := msgpack.NewDecoder(data)
+	token, err := decoder.ReadString()
+	require.NoError(t, err)
+	key, err := decoder.ReadByteArray()
+	require.NoError(t, err)
+
+	assert.True(t, msgpack.SecureCompareString(token, "s3cr3t-token"))
+	assert.False(t, msgpack.SecureCompareString(token, "s3cr3t-tokem"))
+	assert.False(t, msgpack.SecureCompareString(token, "s3cr3t"))
+	assert.True(t, msgpack.SecureCompareString("", ""))
+
+	assert.True(t, msgpack.SecureCompareBytes(key, []byte{1, 2, 3}))
+	assert.False(t, msgpack.SecureCompareBytes(key, []byte{1, 2, 4}))
+	assert.False(t, msgpack.SecureCompareBytes(key, nil))
+}
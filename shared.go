@@ -0,0 +1,107 @@
+package msgpack
+
+import "sync"
+
+// SharedCodec memoizes the encoding of a Codec that appears several times
+// in a value graph, such as a large sub-object referenced from many
+// places. Sizing it with a Sizer computes and caches its size, encoding it
+// encodes it once into a buffer of its own, and later calls write the
+// cached bytes with WriteRaw, so both passes produce exactly the bytes of
+// a fresh encode.
+//
+// The cache is kept for the options of the writer it was built for and
+// rebuilt when a writer has other options. With a string table, whose
+// output depends on the strings written before, the value is encoded
+// afresh each time. Call Invalidate after changing the wrapped value;
+// Decode invalidates the cache itself.
+//
+// A SharedCodec is safe for concurrent use, but the wrapped value must
+// not change while it is encoded.
+type SharedCodec struct {
+	codec Codec
+
+	mu      sync.Mutex
+	options encOptions
+	sized   bool
+	size    uint32
+	encoded []byte
+}
+
+var _ Codec = &SharedCodec{}
+
+// Shared wraps `c` in a SharedCodec.
+func Shared(c Codec) *SharedCodec {
+	return &SharedCodec{codec: c}
+}
+
+// Invalidate drops the cached size and bytes.
+func (s *SharedCodec) Invalidate() {
+	s.mu.Lock()
+	s.invalidate()
+	s.mu.Unlock()
+}
+
+func (s *SharedCodec) invalidate() {
+	s.sized, s.size, s.encoded = false, 0, nil
+}
+
+// Encode writes the wrapped value, from the cache when it can.
+func (s *SharedCodec) Encode(w Writer) error {
+	options := encOptionsOf(w)
+	if options.stringTable {
+		return s.codec.Encode(w)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if options != s.options {
+		s.invalidate()
+		s.options = options
+	}
+	if sizer, ok := w.(*Sizer); ok && s.encoded == nil {
+		if err := s.computeSize(); err != nil {
+			return err
+		}
+		sizer.length += s.size
+		return nil
+	}
+	if s.encoded == nil {
+		if err := s.computeSize(); err != nil {
+			return err
+		}
+		encoder := NewEncoder(make([]byte, s.size))
+		encoder.options = s.options
+		if err := s.codec.Encode(&encoder); err != nil {
+			return err
+		}
+		if err := encoder.Err(); err != nil {
+			return err
+		}
+		s.encoded = encoder.Bytes()
+	}
+	w.WriteRaw(s.encoded)
+	return w.Err()
+}
+
+func (s *SharedCodec) computeSize() error {
+	if s.sized {
+		return nil
+	}
+	sizer := Sizer{options: s.options}
+	if err := s.codec.Encode(&sizer); err != nil {
+		return err
+	}
+	if err := sizer.Err(); err != nil {
+		return err
+	}
+	s.sized, s.size = true, sizer.Len()
+	return nil
+}
+
+// Decode reads into the wrapped value and invalidates the cache.
+func (s *SharedCodec) Decode(r Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invalidate()
+	return s.codec.Decode(r)
+}
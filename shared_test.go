@@ -0,0 +1,169 @@
+package msgpack_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// countingCodec encodes a map of labels and counts its Encode calls.
+type countingCodec struct {
+	labels  map[string]string
+	timeout time.Duration
+	encodes int
+}
+
+func (c *countingCodec) Encode(w msgpack.Writer) error {
+	c.encodes++
+	w.WriteArraySize(2)
+	w.WriteAny(c.labels)
+	msgpack.WriteDuration(w, c.timeout)
+	return w.Err()
+}
+
+func (c *countingCodec) Decode(r msgpack.Reader) error {
+	if _, err := r.ReadArraySize(); err != nil {
+		return err
+	}
+	labels, err := msgpack.ReadFilteredMap(r, func(string) bool { return true }, msgpack.Reader.ReadString)
+	if err != nil {
+		return err
+	}
+	c.labels = labels
+	c.timeout, err = msgpack.ReadDuration(r)
+	return err
+}
+
+// sharedGraph refers to the same value several times.
+type sharedGraph struct {
+	refs []msgpack.Codec
+}
+
+func (g sharedGraph) Encode(w msgpack.Writer) error {
+	w.WriteArraySize(uint32(len(g.refs)))
+	for _, ref := range g.refs {
+		if err := ref.Encode(w); err != nil {
+			return err
+		}
+	}
+	return w.Err()
+}
+
+func newSharedGraph(c msgpack.Codec, n int) sharedGraph {
+	refs := make([]msgpack.Codec, n)
+	for i := range refs {
+		refs[i] = c
+	}
+	return sharedGraph{refs}
+}
+
+func encodeGraph(t *testing.T, g sharedGraph, opts ...msgpack.EncOption) []byte {
+	t.Helper()
+	sizer := msgpack.NewSizerWithOptions(opts...)
+	require.NoError(t, g.Encode(&sizer))
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), opts...)
+	require.NoError(t, g.Encode(&encoder))
+	require.Equal(t, sizer.Len(), encoder.Len())
+	return encoder.Bytes()
+}
+
+func newCountingCodec() *countingCodec {
+	return &countingCodec{labels: map[string]string{"b": "2", "a": "1", "c": "3"}, timeout: 1500 * time.Millisecond}
+}
+
+func TestSharedCodecMatchesFreshEncode(t *testing.T) {
+	value := newCountingCodec()
+	shared := msgpack.Shared(value)
+	opts := []msgpack.EncOption{msgpack.WithSortedStringMaps()}
+
+	want := encodeGraph(t, newSharedGraph(value, 5), opts...)
+	value.encodes = 0
+	got := encodeGraph(t, newSharedGraph(shared, 5), opts...)
+	assert.Equal(t, want, got)
+	assert.Equal(t, 2, value.encodes, "sized once and encoded once")
+
+	// Later graphs come from the cache.
+	got = encodeGraph(t, newSharedGraph(shared, 3), opts...)
+	assert.Equal(t, encodeGraph(t, newSharedGraph(value, 3), opts...), got)
+	assert.Equal(t, 2+3*2, value.encodes)
+}
+
+func TestSharedCodecInvalidate(t *testing.T) {
+	value := newCountingCodec()
+	shared := msgpack.Shared(value)
+	before := encodeGraph(t, newSharedGraph(shared, 2), msgpack.WithSortedStringMaps())
+
+	value.labels["d"] = "a longer value than before"
+	assert.Equal(t, before, encodeGraph(t, newSharedGraph(shared, 2), msgpack.WithSortedStringMaps()),
+		"the cache is kept until invalidated")
+
+	shared.Invalidate()
+	after := encodeGraph(t, newSharedGraph(shared, 2), msgpack.WithSortedStringMaps())
+	assert.Equal(t, encodeGraph(t, newSharedGraph(value, 2), msgpack.WithSortedStringMaps()), after)
+	assert.NotEqual(t, before, after)
+}
+
+func TestSharedCodecOptions(t *testing.T) {
+	value := newCountingCodec()
+	shared := msgpack.Shared(value)
+	for _, opts := range [][]msgpack.EncOption{
+		{msgpack.WithSortedStringMaps()},
+		{msgpack.WithSortedStringMaps(), msgpack.WithDurationUnit(time.Millisecond)},
+		{msgpack.WithSortedStringMaps(), msgpack.WithDurationAsString()},
+		{msgpack.WithSortedStringMaps(), msgpack.WithStringTable(stringTableExt)},
+		{msgpack.WithSortedStringMaps()},
+	} {
+		assert.Equal(t, encodeGraph(t, newSharedGraph(value, 3), opts...),
+			encodeGraph(t, newSharedGraph(shared, 3), opts...))
+	}
+}
+
+func TestSharedCodecDecode(t *testing.T) {
+	value := newCountingCodec()
+	shared := msgpack.Shared(value)
+	old := encodeGraph(t, newSharedGraph(shared, 1), msgpack.WithSortedStringMaps())
+
+	replacement := &countingCodec{labels: map[string]string{"x": "y"}, timeout: time.Second}
+	data, err := msgpack.ToBytes(replacement)
+	require.NoError(t, err)
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, shared.Decode(&decoder))
+
+	got := encodeGraph(t, newSharedGraph(shared, 1), msgpack.WithSortedStringMaps())
+	assert.NotEqual(t, old, got)
+	assert.Equal(t, encodeGraph(t, newSharedGraph(replacement, 1)), got)
+}
+
+func TestSharedCodecConcurrent(t *testing.T) {
+	value := newCountingCodec()
+	shared := msgpack.Shared(value)
+	want := encodeGraph(t, newSharedGraph(value, 4), msgpack.WithSortedStringMaps())
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g := newSharedGraph(shared, 4)
+			sizer := msgpack.NewSizerWithOptions(msgpack.WithSortedStringMaps())
+			if err := g.Encode(&sizer); err != nil {
+				return
+			}
+			encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), msgpack.WithSortedStringMaps())
+			if err := g.Encode(&encoder); err != nil {
+				return
+			}
+			results[i] = encoder.Bytes()
+		}(i)
+	}
+	wg.Wait()
+	for _, got := range results {
+		assert.Equal(t, want, got)
+	}
+}
@@ -6,7 +6,10 @@ import (
 )
 
 type Sizer struct {
-	length uint32
+	length  uint32
+	options encOptions
+	strings stringTableWriter
+	err     error
 }
 
 func NewSizer() Sizer {
@@ -22,6 +25,12 @@ func (s *Sizer) WriteNil() {
 }
 
 func (s *Sizer) WriteString(value string) {
+	if s.options.stringTable {
+		if index, ok := s.strings.ref(value); ok {
+			s.length += stringRefSize(index)
+			return
+		}
+	}
 	buf := UnsafeBytes(value)
 	length := uint32(len(buf))
 	s.writeStringLength(length)
@@ -29,7 +38,7 @@ func (s *Sizer) WriteString(value string) {
 }
 
 func (s *Sizer) WriteNillableString(value *string) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == "" {
 		s.WriteNil()
 	} else {
 		s.WriteString(*value)
@@ -85,7 +94,7 @@ func (s *Sizer) encodeExtLen(l int) {
 }
 
 func (s *Sizer) WriteNillableTime(value *time.Time) {
-	if value == nil {
+	if value == nil || s.options.omitZero && value.IsZero() {
 		s.WriteNil()
 	} else {
 		s.WriteTime(*value)
@@ -97,7 +106,7 @@ func (s *Sizer) WriteBool(value bool) {
 }
 
 func (s *Sizer) WriteNillableBool(value *bool) {
-	if value == nil {
+	if value == nil || s.options.omitZero && !*value {
 		s.WriteNil()
 	} else {
 		s.WriteBool(*value)
@@ -105,17 +114,14 @@ func (s *Sizer) WriteNillableBool(value *bool) {
 }
 
 func (s *Sizer) WriteArraySize(length uint32) {
-	if length < 16 {
-		s.length++
-	} else if length <= math.MaxUint16 {
-		s.length += 3
-	} else {
-		s.length += 5
+	if !s.checkContainerLen("array", length, 1) {
+		return
 	}
+	s.length += containerHeaderSize(length)
 }
 
 func (s *Sizer) writeBinLength(length uint32) {
-	if length < math.MaxUint8 {
+	if length <= math.MaxUint8 {
 		s.length += 1
 	} else if length <= math.MaxUint16 {
 		s.length += 2
@@ -135,7 +141,7 @@ func (s *Sizer) WriteByteArray(value []byte) {
 }
 
 func (s *Sizer) WriteNillableByteArray(value []byte) {
-	if value == nil {
+	if value == nil || s.options.omitZero && len(value) == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteByteArray(value)
@@ -143,20 +149,17 @@ func (s *Sizer) WriteNillableByteArray(value []byte) {
 }
 
 func (s *Sizer) WriteMapSize(length uint32) {
-	if length < 16 {
-		s.length++
-	} else if length <= math.MaxUint16 {
-		s.length += 3
-	} else {
-		s.length += 5
+	if !s.checkContainerLen("map", length, 2) {
+		return
 	}
+	s.length += containerHeaderSize(length)
 }
 
 func (s *Sizer) WriteInt8(value int8) {
 	s.WriteInt64(int64(value))
 }
 func (s *Sizer) WriteNillableInt8(value *int8) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteInt8(*value)
@@ -166,7 +169,7 @@ func (s *Sizer) WriteInt16(value int16) {
 	s.WriteInt64(int64(value))
 }
 func (s *Sizer) WriteNillableInt16(value *int16) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteInt16(*value)
@@ -176,7 +179,7 @@ func (s *Sizer) WriteInt32(value int32) {
 	s.WriteInt64(int64(value))
 }
 func (s *Sizer) WriteNillableInt32(value *int32) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteInt32(*value)
@@ -196,7 +199,7 @@ func (s *Sizer) WriteInt64(value int64) {
 	}
 }
 func (s *Sizer) WriteNillableInt64(value *int64) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteInt64(*value)
@@ -207,7 +210,7 @@ func (s *Sizer) WriteUint8(value uint8) {
 	s.WriteUint64(uint64(value))
 }
 func (s *Sizer) WriteNillableUint8(value *uint8) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteUint8(*value)
@@ -217,7 +220,7 @@ func (s *Sizer) WriteUint16(value uint16) {
 	s.WriteUint64(uint64(value))
 }
 func (s *Sizer) WriteNillableUint16(value *uint16) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteUint16(*value)
@@ -227,7 +230,7 @@ func (s *Sizer) WriteUint32(value uint32) {
 	s.WriteUint64(uint64(value))
 }
 func (s *Sizer) WriteNillableUint32(value *uint32) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteUint32(*value)
@@ -247,7 +250,7 @@ func (s *Sizer) WriteUint64(value uint64) {
 	}
 }
 func (s *Sizer) WriteNillableUint64(value *uint64) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteUint64(*value)
@@ -258,7 +261,7 @@ func (s *Sizer) WriteFloat32(value float32) {
 	s.length += 5
 }
 func (s *Sizer) WriteNillableFloat32(value *float32) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteFloat32(*value)
@@ -268,7 +271,7 @@ func (s *Sizer) WriteFloat64(value float64) {
 	s.length += 9
 }
 func (s *Sizer) WriteNillableFloat64(value *float64) {
-	if value == nil {
+	if value == nil || s.options.omitZero && *value == 0 {
 		s.WriteNil()
 	} else {
 		s.WriteFloat64(*value)
@@ -276,9 +279,6 @@ func (s *Sizer) WriteNillableFloat64(value *float64) {
 }
 
 func (s *Sizer) WriteAny(value any) {
-	if value == nil {
-		s.WriteNil()
-	}
 	switch v := value.(type) {
 	case nil:
 		s.WriteNil()
@@ -314,8 +314,14 @@ func (s *Sizer) WriteAny(value any) {
 		s.WriteString(v)
 	case time.Time:
 		s.WriteTime(v)
+	case Raw:
+		s.WriteNillableRaw(v)
 	case []byte:
 		s.WriteByteArray(v)
+	case [16]byte:
+		s.WriteByteArray(v[:])
+	case [32]byte:
+		s.WriteByteArray(v[:])
 	case []interface{}:
 		size := uint32(len(v))
 		s.WriteArraySize(size)
@@ -397,89 +403,35 @@ func (s *Sizer) WriteAny(value any) {
 		}
 
 	case map[string]string:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteString(k)
-			s.WriteString(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedStringMaps, s.WriteString, s.WriteString)
 	case map[string]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteString(k)
-			s.WriteAny(v)
-		}
+		s.WriteStringAnyMap(v)
+	case map[string]time.Time:
+		writeKeyedMap(s, v, s.options.sortedStringMaps, s.WriteString, s.WriteTime)
+	case map[int]string:
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k int) { s.WriteInt64(int64(k)) }, s.WriteString)
 	case map[int]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteInt64(int64(k))
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k int) { s.WriteInt64(int64(k)) }, s.WriteAny)
 	case map[int8]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteInt8(k)
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k int8) { s.WriteInt8(k) }, s.WriteAny)
 	case map[int16]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteInt16(k)
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k int16) { s.WriteInt16(k) }, s.WriteAny)
 	case map[int32]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteInt32(k)
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k int32) { s.WriteInt32(k) }, s.WriteAny)
+	case map[int64]string:
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k int64) { s.WriteInt64(k) }, s.WriteString)
 	case map[int64]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteInt64(k)
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k int64) { s.WriteInt64(k) }, s.WriteAny)
 	case map[uint]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteUint64(uint64(k))
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k uint) { s.WriteUint64(uint64(k)) }, s.WriteAny)
 	case map[uint8]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteUint8(k)
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k uint8) { s.WriteUint8(k) }, s.WriteAny)
 	case map[uint16]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteUint16(k)
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k uint16) { s.WriteUint16(k) }, s.WriteAny)
 	case map[uint32]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteUint32(k)
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k uint32) { s.WriteUint32(k) }, s.WriteAny)
 	case map[uint64]interface{}:
-		size := uint32(len(v))
-		s.WriteMapSize(size)
-		for k, v := range v {
-			s.WriteUint64(k)
-			s.WriteAny(v)
-		}
+		writeKeyedMap(s, v, s.options.sortedIntMaps, func(k uint64) { s.WriteUint64(k) }, s.WriteAny)
 	case map[interface{}]interface{}:
 		size := uint32(len(v))
 		s.WriteMapSize(size)
@@ -487,9 +439,26 @@ func (s *Sizer) WriteAny(value any) {
 			s.WriteAny(k)
 			s.WriteAny(v)
 		}
+	default:
+		writeReflectedAny(s, value)
 	}
 }
 
+func (s *Sizer) WriteStringAnyMap(value map[string]any) {
+	writeKeyedMap(s, value, s.options.sortedStringMaps, s.WriteString, s.WriteAny)
+}
+
 func (s *Sizer) Err() error {
-	return nil
+	return s.err
+}
+
+// checkContainerLen records an error when a container of `length` elements
+// is over the configured limit or could not fit in any message, and
+// reports whether its header may be counted, as the Encoder's does.
+func (s *Sizer) checkContainerLen(kind string, length, minSize uint32) bool {
+	if !s.options.containerLenCheck || s.err != nil || length == 0 {
+		return true
+	}
+	s.err = s.options.checkContainerLen(kind, length, minSize, s.length+containerHeaderSize(length), math.MaxUint64)
+	return s.err == nil
 }
@@ -0,0 +1,140 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/msgpacktest"
+)
+
+// recursiveSkip is the recursive Skip that the iterative one replaced:
+// container headers are read on their own and every element is skipped by
+// a recursive call. Scalars never recurse, so Skip stands in for them.
+func recursiveSkip(d *msgpack.Decoder) error {
+	prefix, err := d.PeekFormat()
+	if err != nil {
+		return err
+	}
+	var size uint32
+	var n uint64
+	switch {
+	case prefix >= 0x90 && prefix <= 0x9f, prefix == msgpack.FormatArray16, prefix == msgpack.FormatArray32:
+		size, err = d.ReadArraySize()
+		n = uint64(size)
+	case prefix >= 0x80 && prefix <= 0x8f, prefix == msgpack.FormatMap16, prefix == msgpack.FormatMap32:
+		size, err = d.ReadMapSize()
+		n = 2 * uint64(size)
+	default:
+		return d.Skip()
+	}
+	if err != nil {
+		return err
+	}
+	for ; n > 0; n-- {
+		if err := recursiveSkip(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assertSkipMatches skips every value in `data` both ways and checks that
+// they stop at the same offset with the same error.
+func assertSkipMatches(t *testing.T, data []byte) {
+	t.Helper()
+	iterative := msgpack.NewDecoder(data)
+	recursive := msgpack.NewDecoder(data)
+	for iterative.Remaining() > 0 {
+		errI := iterative.Skip()
+		errR := recursiveSkip(&recursive)
+		require.Equal(t, recursive.Offset(), iterative.Offset(), "% x", data)
+		if errR != nil {
+			require.EqualError(t, errI, errR.Error(), "% x", data)
+			return
+		}
+		require.NoError(t, errI, "% x", data)
+	}
+}
+
+func TestSkipMatchesRecursiveOnCorpus(t *testing.T) {
+	for _, m := range compatMessages {
+		t.Run(m.name, func(t *testing.T) {
+			data := readCompatCorpus(t, m.name)
+			// Every truncation exercises the errors for cut-off input.
+			for n := 0; n <= len(data); n++ {
+				assertSkipMatches(t, data[:n])
+			}
+		})
+	}
+}
+
+func TestSkipMatchesRecursiveOnRandomDocuments(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	g := msgpacktest.NewGenerator(1, msgpacktest.WithTimes(), msgpacktest.WithExt(),
+		msgpacktest.WithMaxDepth(6), msgpacktest.WithMaxContainerLen(20))
+	for i := 0; i < 500; i++ {
+		data := g.NextRaw()
+		assertSkipMatches(t, data)
+		assertSkipMatches(t, data[:rng.Intn(len(data)+1)])
+		// Flip one byte to produce documents that are corrupt rather
+		// than merely short.
+		corrupt := append([]byte(nil), data...)
+		corrupt[rng.Intn(len(corrupt))] = byte(rng.Intn(256))
+		assertSkipMatches(t, corrupt)
+	}
+}
+
+func FuzzSkip(f *testing.F) {
+	for _, m := range compatMessages {
+		f.Add(readCompatCorpus(f, m.name))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		assertSkipMatches(t, data)
+	})
+}
+
+func TestSkipDeepNesting(t *testing.T) {
+	const depth = 10000
+	data := append(bytes.Repeat([]byte{0x91}, depth), 0xc0, 0x2a)
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, decoder.Skip())
+	assert.Equal(t, uint32(depth+1), decoder.Offset())
+
+	decoder = msgpack.NewDecoder(data)
+	raw, err := decoder.ReadRaw()
+	require.NoError(t, err)
+	assert.Len(t, raw, depth+1)
+
+	// Cut off at the innermost value.
+	decoder = msgpack.NewDecoder(data[:depth])
+	assert.ErrorIs(t, decoder.Skip(), msgpack.ErrRange)
+}
+
+func TestSkipLargeMap32(t *testing.T) {
+	// 2^31 entries are 2^32 values, which do not fit in a uint32 count.
+	data := []byte{0xdf, 0x80, 0x00, 0x00, 0x00}
+	decoder := msgpack.NewDecoder(data)
+	assert.ErrorIs(t, decoder.Skip(), msgpack.ErrRange)
+	decoder = msgpack.NewDecoder(data)
+	_, err := decoder.ReadRaw()
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.ErrorIs(t, msgpack.Validate(data), msgpack.ErrRange)
+	assertSkipMatches(t, data)
+}
+
+func TestSkipDoesNotAllocate(t *testing.T) {
+	data := append(bytes.Repeat([]byte{0x92, 0x81, 0xa1, 'k'}, 10), 0xc0)
+	data = append(data, bytes.Repeat([]byte{0xc3}, 20)...)
+	allocs := testing.AllocsPerRun(100, func() {
+		decoder := msgpack.NewDecoder(data)
+		if err := decoder.Skip(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Zero(t, allocs)
+}
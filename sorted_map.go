@@ -0,0 +1,33 @@
+package msgpack
+
+import "sort"
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+type mapKey interface {
+	integer | ~string
+}
+
+// writeKeyedMap writes `m`, in ascending key order when `sorted` is set.
+func writeKeyedMap[K mapKey, V any](w Writer, m map[K]V, sorted bool, writeKey func(K), writeValue func(V)) {
+	w.WriteMapSize(uint32(len(m)))
+	if !sorted {
+		for k, v := range m {
+			writeKey(k)
+			writeValue(v)
+		}
+		return
+	}
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, k := range keys {
+		writeKey(k)
+		writeValue(m[k])
+	}
+}
@@ -0,0 +1,138 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestWriteAnyStringTimeMap(t *testing.T) {
+	value := map[string]time.Time{
+		"created": time.Unix(1700000000, 0),
+		"updated": time.Unix(1700000500, 123),
+	}
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteAny(value) })
+
+	decoder := msgpack.NewDecoder(data)
+	size, err := decoder.ReadMapSize()
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), size)
+	for i := 0; i < 2; i++ {
+		key, err := decoder.ReadString()
+		require.NoError(t, err)
+		tm, err := decoder.ReadTime()
+		require.NoError(t, err)
+		assert.True(t, value[key].Equal(tm))
+	}
+}
+
+func TestSortedIntMaps(t *testing.T) {
+	value := make(map[int64]string, 50)
+	for i := int64(0); i < 50; i++ {
+		value[i*7919%1000-500] = "v" + strconv.FormatInt(i, 10)
+	}
+	write := func(w msgpack.Writer) { w.WriteAny(value) }
+	first := encodeWithOptions(t, write, msgpack.WithSortedIntMaps())
+	for i := 0; i < 20; i++ {
+		require.True(t, bytes.Equal(first, encodeWithOptions(t, write, msgpack.WithSortedIntMaps())))
+	}
+
+	decoder := msgpack.NewDecoder(first)
+	size, err := decoder.ReadMapSize()
+	require.NoError(t, err)
+	require.Equal(t, uint32(50), size)
+	previous := int64(-1 << 62)
+	for i := 0; i < 50; i++ {
+		key, err := decoder.ReadInt64()
+		require.NoError(t, err)
+		assert.Greater(t, key, previous)
+		previous = key
+		s, err := decoder.ReadString()
+		require.NoError(t, err)
+		assert.Equal(t, value[key], s)
+	}
+}
+
+func TestSortedIntMapsNested(t *testing.T) {
+	document := map[string]any{
+		"events": map[uint16]any{
+			3: map[string]time.Time{"at": time.Unix(1700000000, 0)},
+			1: map[int]string{20: "b", 10: "a"},
+			2: []any{map[int8]any{-1: true, -2: false}},
+		},
+	}
+	write := func(w msgpack.Writer) { w.WriteAny(document) }
+	data := encodeWithOptions(t, write, msgpack.WithSortedIntMaps())
+	for i := 0; i < 20; i++ {
+		require.Equal(t, data, encodeWithOptions(t, write, msgpack.WithSortedIntMaps()))
+	}
+
+	// The event keys come out in order, each followed by its value.
+	d := msgpack.NewDecoder(data)
+	_, err := d.ReadMapSize()
+	require.NoError(t, err)
+	key, err := d.ReadString()
+	require.NoError(t, err)
+	require.Equal(t, "events", key)
+	size, err := d.ReadMapSize()
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), size)
+	for expected := uint16(1); expected <= 3; expected++ {
+		k, err := d.ReadUint16()
+		require.NoError(t, err)
+		assert.Equal(t, expected, k)
+		if k == 1 {
+			inner, err := d.ReadAny()
+			require.NoError(t, err)
+			assert.Equal(t, map[any]any{int64(10): "a", int64(20): "b"}, inner)
+			continue
+		}
+		require.NoError(t, d.Skip())
+	}
+	assert.Equal(t, uint32(0), d.Remaining())
+}
+
+func TestSortedStringMaps(t *testing.T) {
+	value := make(map[string]any, 50)
+	for i := 0; i < 50; i++ {
+		value["k"+strconv.Itoa(i*7919%1000)] = map[string]string{"b": "2", "a": "1", "c": "3"}
+	}
+	write := func(w msgpack.Writer) { w.WriteAny(value) }
+	first := encodeWithOptions(t, write, msgpack.WithSortedStringMaps())
+	for i := 0; i < 20; i++ {
+		require.True(t, bytes.Equal(first, encodeWithOptions(t, write, msgpack.WithSortedStringMaps())))
+	}
+	// The string table refers back to earlier strings, so it needs a
+	// stable order too.
+	withTable := encodeWithOptions(t, write, msgpack.WithSortedStringMaps(), msgpack.WithStringTable(stringTableExt))
+	for i := 0; i < 5; i++ {
+		require.True(t, bytes.Equal(withTable, encodeWithOptions(t, write,
+			msgpack.WithSortedStringMaps(), msgpack.WithStringTable(stringTableExt))))
+	}
+
+	decoder := msgpack.NewDecoder(first)
+	size, err := decoder.ReadMapSize()
+	require.NoError(t, err)
+	require.Equal(t, uint32(50), size)
+	previous := ""
+	for i := 0; i < 50; i++ {
+		key, err := decoder.ReadString()
+		require.NoError(t, err)
+		assert.Greater(t, key, previous)
+		previous = key
+		inner, err := decoder.ReadMapSize()
+		require.NoError(t, err)
+		require.Equal(t, uint32(3), inner)
+		for _, expected := range []string{"a", "1", "b", "2", "c", "3"} {
+			s, err := decoder.ReadString()
+			require.NoError(t, err)
+			assert.Equal(t, expected, s)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Package sqlnull writes and reads the database/sql Null types, with nil
+// standing for a value that is not Valid. It is a separate package to keep
+// database/sql out of TinyGo builds.
+//
+// The strings read, like those ReadString returns, alias the input buffer.
+package sqlnull
+
+import (
+	"database/sql"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// WriteNullString writes `v.String`, or nil when `v` is not Valid.
+func WriteNullString(w msgpack.Writer, v sql.NullString) {
+	msgpack.WriteNullable(w, v.String, v.Valid, msgpack.Writer.WriteString)
+}
+
+// ReadNullString reads a string, or nil as a NullString that is not Valid.
+func ReadNullString(r msgpack.Reader) (sql.NullString, error) {
+	s, valid, err := msgpack.ReadNullable(r, msgpack.Reader.ReadString)
+	return sql.NullString{String: s, Valid: valid}, err
+}
+
+// WriteNullInt64 writes `v.Int64`, or nil when `v` is not Valid.
+func WriteNullInt64(w msgpack.Writer, v sql.NullInt64) {
+	msgpack.WriteNullable(w, v.Int64, v.Valid, msgpack.Writer.WriteInt64)
+}
+
+// ReadNullInt64 reads an integer, or nil as a NullInt64 that is not Valid.
+func ReadNullInt64(r msgpack.Reader) (sql.NullInt64, error) {
+	i, valid, err := msgpack.ReadNullable(r, msgpack.Reader.ReadInt64)
+	return sql.NullInt64{Int64: i, Valid: valid}, err
+}
+
+// WriteNullFloat64 writes `v.Float64`, or nil when `v` is not Valid.
+func WriteNullFloat64(w msgpack.Writer, v sql.NullFloat64) {
+	msgpack.WriteNullable(w, v.Float64, v.Valid, msgpack.Writer.WriteFloat64)
+}
+
+// ReadNullFloat64 reads a float, or nil as a NullFloat64 that is not
+// Valid.
+func ReadNullFloat64(r msgpack.Reader) (sql.NullFloat64, error) {
+	f, valid, err := msgpack.ReadNullable(r, msgpack.Reader.ReadFloat64)
+	return sql.NullFloat64{Float64: f, Valid: valid}, err
+}
+
+// WriteNullBool writes `v.Bool`, or nil when `v` is not Valid.
+func WriteNullBool(w msgpack.Writer, v sql.NullBool) {
+	msgpack.WriteNullable(w, v.Bool, v.Valid, msgpack.Writer.WriteBool)
+}
+
+// ReadNullBool reads a boolean, or nil as a NullBool that is not Valid.
+func ReadNullBool(r msgpack.Reader) (sql.NullBool, error) {
+	b, valid, err := msgpack.ReadNullable(r, msgpack.Reader.ReadBool)
+	return sql.NullBool{Bool: b, Valid: valid}, err
+}
+
+// WriteNullTime writes `v.Time`, or nil when `v` is not Valid.
+func WriteNullTime(w msgpack.Writer, v sql.NullTime) {
+	msgpack.WriteNullable(w, v.Time, v.Valid, msgpack.Writer.WriteTime)
+}
+
+// ReadNullTime reads a time, or nil as a NullTime that is not Valid.
+func ReadNullTime(r msgpack.Reader) (sql.NullTime, error) {
+	t, valid, err := msgpack.ReadNullable(r, msgpack.Reader.ReadTime)
+	return sql.NullTime{Time: t, Valid: valid}, err
+}
@@ -0,0 +1,148 @@
+package sqlnull_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+	"github.com/wapc/tinygo-msgpack/sqlnull"
+)
+
+func encode(t *testing.T, fn func(w msgpack.Writer)) []byte {
+	t.Helper()
+	var sizer msgpack.Sizer
+	fn(&sizer)
+	encoder := msgpack.NewEncoder(make([]byte, sizer.Len()))
+	fn(&encoder)
+	require.NoError(t, encoder.Err())
+	require.Equal(t, sizer.Len(), uint32(len(encoder.Bytes())))
+	return encoder.Bytes()
+}
+
+var testTime = time.Date(2022, 3, 4, 5, 6, 7, 800, time.UTC)
+
+func TestRoundTrip(t *testing.T) {
+	for _, valid := range []bool{true, false} {
+		str := sql.NullString{String: "text", Valid: valid}
+		i64 := sql.NullInt64{Int64: -42, Valid: valid}
+		f64 := sql.NullFloat64{Float64: 2.5, Valid: valid}
+		b := sql.NullBool{Bool: true, Valid: valid}
+		tm := sql.NullTime{Time: testTime, Valid: valid}
+		data := encode(t, func(w msgpack.Writer) {
+			sqlnull.WriteNullString(w, str)
+			sqlnull.WriteNullInt64(w, i64)
+			sqlnull.WriteNullFloat64(w, f64)
+			sqlnull.WriteNullBool(w, b)
+			sqlnull.WriteNullTime(w, tm)
+		})
+		if !valid {
+			assert.Equal(t, []byte{0xc0, 0xc0, 0xc0, 0xc0, 0xc0}, data)
+			str, i64, f64, b, tm = sql.NullString{}, sql.NullInt64{}, sql.NullFloat64{}, sql.NullBool{}, sql.NullTime{}
+		}
+
+		decoder := msgpack.NewDecoder(data)
+		gotStr, err := sqlnull.ReadNullString(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, str, gotStr)
+		gotI64, err := sqlnull.ReadNullInt64(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, i64, gotI64)
+		gotF64, err := sqlnull.ReadNullFloat64(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, f64, gotF64)
+		gotB, err := sqlnull.ReadNullBool(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, b, gotB)
+		gotTm, err := sqlnull.ReadNullTime(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, tm.Valid, gotTm.Valid)
+		assert.True(t, tm.Time.Equal(gotTm.Time))
+		assert.Equal(t, uint32(0), decoder.Remaining())
+	}
+}
+
+func TestReadError(t *testing.T) {
+	decoder := msgpack.NewDecoder([]byte{0xa1, 'x'})
+	v, err := sqlnull.ReadNullInt64(&decoder)
+	var mismatch msgpack.TypeMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.False(t, v.Valid)
+}
+
+// account has the shape of a row stored by a persistence layer.
+type account struct {
+	Name      sql.NullString
+	Balance   sql.NullInt64
+	Rate      sql.NullFloat64
+	Active    sql.NullBool
+	ClosedAt  sql.NullTime
+	Reference nullRef
+}
+
+// nullRef is a custom wrapper read with ReadNullable.
+type nullRef struct {
+	ID    uint64
+	Valid bool
+}
+
+func (a *account) Encode(w msgpack.Writer) error {
+	w.WriteArraySize(6)
+	sqlnull.WriteNullString(w, a.Name)
+	sqlnull.WriteNullInt64(w, a.Balance)
+	sqlnull.WriteNullFloat64(w, a.Rate)
+	sqlnull.WriteNullBool(w, a.Active)
+	sqlnull.WriteNullTime(w, a.ClosedAt)
+	msgpack.WriteNullable(w, a.Reference.ID, a.Reference.Valid, msgpack.Writer.WriteUint64)
+	return w.Err()
+}
+
+func (a *account) Decode(r msgpack.Reader) (err error) {
+	if _, err = r.ReadArraySize(); err != nil {
+		return err
+	}
+	if a.Name, err = sqlnull.ReadNullString(r); err != nil {
+		return err
+	}
+	if a.Balance, err = sqlnull.ReadNullInt64(r); err != nil {
+		return err
+	}
+	if a.Rate, err = sqlnull.ReadNullFloat64(r); err != nil {
+		return err
+	}
+	if a.Active, err = sqlnull.ReadNullBool(r); err != nil {
+		return err
+	}
+	if a.ClosedAt, err = sqlnull.ReadNullTime(r); err != nil {
+		return err
+	}
+	a.Reference.ID, a.Reference.Valid, err = msgpack.ReadNullable(r, msgpack.Reader.ReadUint64)
+	return err
+}
+
+func TestMixedStruct(t *testing.T) {
+	for _, want := range []account{
+		{
+			Name:    sql.NullString{String: "savings", Valid: true},
+			Balance: sql.NullInt64{Int64: 1200, Valid: true},
+			Active:  sql.NullBool{Bool: false, Valid: true},
+		},
+		{
+			Rate:      sql.NullFloat64{Float64: 0.015, Valid: true},
+			ClosedAt:  sql.NullTime{Time: testTime, Valid: true},
+			Reference: nullRef{ID: 7, Valid: true},
+		},
+		{},
+	} {
+		data := encode(t, func(w msgpack.Writer) { require.NoError(t, want.Encode(w)) })
+		var got account
+		decoder := msgpack.NewDecoder(data)
+		require.NoError(t, got.Decode(&decoder))
+		assert.True(t, want.ClosedAt.Time.Equal(got.ClosedAt.Time))
+		got.ClosedAt.Time = want.ClosedAt.Time
+		assert.Equal(t, want, got)
+	}
+}
@@ -0,0 +1,186 @@
+package msgpack
+
+import "strconv"
+
+// StructureError is returned by ReadArrayStrict and ReadMapStrict when the
+// values read do not line up with the sizes containers declare, such as
+// a producer that declares an array of 10 and writes 12 elements.
+type StructureError struct {
+	// Path locates the container or element, as in Difference.Path,
+	// relative to the outermost strict container.
+	Path string
+	// Offset is the position at which the mismatch was found.
+	Offset uint32
+	Reason string
+}
+
+func (e StructureError) Error() string {
+	return "msgpack: " + e.Path + ": " + e.Reason + " at offset " + strconv.FormatUint(uint64(e.Offset), 10)
+}
+
+// containerFrame is an array or map being read by readStrict.
+type containerFrame struct {
+	isMap bool
+	// index is the index of the element or entry being read, and start
+	// the offset at which it starts.
+	index uint32
+	start uint32
+}
+
+// ReadArrayStrict reads an array, calling `each` to read every element,
+// and checks that the elements line up with the declared size:
+//
+//   - each call of `each` reads exactly one element;
+//   - the data does not end before the declared number of elements;
+//   - when the array is the first value of the buffer, the data ends
+//     with it, as ExpectEOF checks.
+//
+// Any other case returns a StructureError naming the container or element.
+// The last check is what catches a producer that writes more elements
+// than it declares: the extra elements are otherwise read as the next
+// values of the parent, and the data then continues past the top-level
+// value. Arrays and maps read with ReadArrayStrict or ReadMapStrict inside
+// `each` are checked too, with paths that include the enclosing
+// containers. Each element is skipped a second time to find its end, so
+// strict reads are slower than plain ones. With a Reader other than a
+// *Decoder, the elements are read without the checks.
+func ReadArrayStrict(r Reader, each func(i uint32, r Reader) error) error {
+	return readStrict(r, false, each)
+}
+
+// ReadMapStrict is ReadArrayStrict for maps. Each call of `each` reads
+// exactly one key and its value.
+func ReadMapStrict(r Reader, each func(i uint32, r Reader) error) error {
+	return readStrict(r, true, each)
+}
+
+// ContainerDepth returns the number of arrays and maps being read with
+// ReadArrayStrict and ReadMapStrict.
+func (d *Decoder) ContainerDepth() int {
+	return len(d.containers)
+}
+
+// ContainerPath returns the path of the element being read in the arrays
+// and maps being read with ReadArrayStrict and ReadMapStrict, for naming
+// it in errors. Map entries are named by their key. It is "$" outside of
+// them.
+func (d *Decoder) ContainerPath() string {
+	return d.containerPath(len(d.containers))
+}
+
+// containerPath returns the path of the current elements of the first
+// `depth` frames.
+func (d *Decoder) containerPath(depth int) string {
+	path := make([]any, 0, depth)
+	for _, frame := range d.containers[:depth] {
+		if !frame.isMap {
+			path = append(path, int(frame.index))
+			continue
+		}
+		probe := *d
+		probe.reader.byteOffset = frame.start
+		key, err := probe.ReadAny()
+		if err != nil {
+			key = int(frame.index)
+		}
+		path = append(path, key)
+	}
+	return formatDiffPath(path)
+}
+
+func readStrict(r Reader, isMap bool, each func(i uint32, r Reader) error) error {
+	var size uint32
+	var err error
+	d, ok := r.(*Decoder)
+	if !ok {
+		if isMap {
+			size, err = r.ReadMapSize()
+		} else {
+			size, err = r.ReadArraySize()
+		}
+		for i := uint32(0); err == nil && i < size; i++ {
+			err = each(i, r)
+		}
+		return err
+	}
+
+	header := d.reader.byteOffset
+	if isMap {
+		size, err = d.ReadMapSize()
+	} else {
+		size, err = d.ReadArraySize()
+	}
+	if err != nil {
+		return err
+	}
+	d.containers = append(d.containers, containerFrame{isMap: isMap})
+	err = d.readStrictElements(size, each)
+	d.containers = d.containers[:len(d.containers)-1]
+	if err != nil || len(d.containers) > 0 || header != 0 {
+		return err
+	}
+	if err := d.ExpectEOF(); err != nil {
+		if _, trailing := err.(ReadError); !trailing {
+			return err
+		}
+		return StructureError{
+			Path:   "$",
+			Offset: d.reader.byteOffset,
+			Reason: "data continues after the " + strictCount(size, isMap),
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) readStrictElements(size uint32, each func(i uint32, r Reader) error) error {
+	depth := len(d.containers) - 1
+	isMap := d.containers[depth].isMap
+	for i := uint32(0); i < size; i++ {
+		start := d.reader.byteOffset
+		d.containers[depth].index, d.containers[depth].start = i, start
+		if d.reader.Remaining() == 0 {
+			return StructureError{
+				Path:   d.containerPath(depth),
+				Offset: start,
+				Reason: "data ends after " + strconv.FormatUint(uint64(i), 10) + " of " + strictCount(size, isMap),
+			}
+		}
+		if err := each(i, d); err != nil {
+			return err
+		}
+
+		probe := *d
+		probe.reader.byteOffset = start
+		err := probe.Skip()
+		if err == nil && isMap {
+			err = probe.Skip()
+		}
+		if err != nil {
+			return err
+		}
+		end, read := probe.reader.byteOffset, d.reader.byteOffset
+		if read == end {
+			continue
+		}
+		what := "element"
+		if isMap {
+			what = "entry"
+		}
+		reason := "reading the " + what + " went " + strconv.FormatUint(uint64(read-end), 10) + " bytes past its end"
+		if read < end {
+			reason = "only " + strconv.FormatUint(uint64(read-start), 10) + " of the " + what + "'s " +
+				strconv.FormatUint(uint64(end-start), 10) + " bytes were read"
+		}
+		return StructureError{Path: d.containerPath(depth + 1), Offset: start, Reason: reason}
+	}
+	return nil
+}
+
+// strictCount describes `size` declared elements or entries.
+func strictCount(size uint32, isMap bool) string {
+	noun := " declared elements"
+	if isMap {
+		noun = " declared entries"
+	}
+	return strconv.FormatUint(uint64(size), 10) + noun
+}
@@ -0,0 +1,158 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// writeItems writes {"items": [...], "name": "svc"} with an items array that
+// declares `declared` elements and holds `written`.
+func writeItems(t *testing.T, declared, written int) []byte {
+	return encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("items")
+		w.WriteArraySize(uint32(declared))
+		for i := 0; i < written; i++ {
+			w.WriteString("item" + strconv.Itoa(i))
+		}
+		w.WriteString("name")
+		w.WriteString("svc")
+	})
+}
+
+type itemsDoc struct {
+	items []string
+	name  string
+}
+
+func (doc *itemsDoc) decode(r msgpack.Reader) error {
+	return msgpack.ReadMapStrict(r, func(_ uint32, r msgpack.Reader) error {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "items":
+			return msgpack.ReadArrayStrict(r, func(_ uint32, r msgpack.Reader) error {
+				item, err := r.ReadString()
+				doc.items = append(doc.items, item)
+				return err
+			})
+		case "name":
+			doc.name, err = r.ReadString()
+			return err
+		}
+		return r.Skip()
+	})
+}
+
+func TestReadStrict(t *testing.T) {
+	data := writeItems(t, 3, 3)
+	decoder := msgpack.NewDecoder(data)
+	var doc itemsDoc
+	require.NoError(t, doc.decode(&decoder))
+	assert.Equal(t, itemsDoc{items: []string{"item0", "item1", "item2"}, name: "svc"}, doc)
+	assert.Equal(t, 0, decoder.ContainerDepth())
+}
+
+// A producer declared 10 elements and wrote 12. The two extra elements
+// are read as an entry of the map, and the declared entries end before
+// the data does.
+func TestReadStrictExtraElements(t *testing.T) {
+	data := writeItems(t, 10, 12)
+	decoder := msgpack.NewDecoder(data)
+	var doc itemsDoc
+	err := doc.decode(&decoder)
+
+	var structure msgpack.StructureError
+	require.ErrorAs(t, err, &structure)
+	nameAt := bytes.Index(data, []byte("\xa4name"))
+	assert.Equal(t, uint32(nameAt), structure.Offset)
+	assert.Equal(t, "$", structure.Path)
+	assert.EqualError(t, err, "msgpack: $: data continues after the 2 declared entries at offset "+strconv.Itoa(nameAt))
+	assert.Len(t, doc.items, 10)
+}
+
+func TestReadStrictMissingElements(t *testing.T) {
+	data := writeItems(t, 12, 10)
+	decoder := msgpack.NewDecoder(data)
+	var doc itemsDoc
+	err := doc.decode(&decoder)
+	assert.EqualError(t, err, "msgpack: $: data ends after 1 of 2 declared entries at offset "+strconv.Itoa(len(data)))
+
+	data = writeItems(t, 14, 10)
+	decoder = msgpack.NewDecoder(data)
+	err = (&itemsDoc{}).decode(&decoder)
+	assert.EqualError(t, err, "msgpack: items: data ends after 12 of 14 declared elements at offset "+strconv.Itoa(len(data)))
+}
+
+func TestReadStrictElementMisread(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(3)
+		w.WriteInt64(1)
+		w.WriteMapSize(1)
+		w.WriteString("id")
+		w.WriteArraySize(1)
+		w.WriteArraySize(2)
+		w.WriteInt64(2)
+		w.WriteInt64(3)
+		w.WriteInt64(4)
+	})
+
+	// Reading two values for one element.
+	decoder := msgpack.NewDecoder(data)
+	err := msgpack.ReadArrayStrict(&decoder, func(i uint32, r msgpack.Reader) error {
+		if i == 0 {
+			r.ReadInt64()
+		}
+		return r.Skip()
+	})
+	assert.EqualError(t, err, "msgpack: [0]: reading the element went 8 bytes past its end at offset 1")
+
+	// Reading part of an element.
+	decoder = msgpack.NewDecoder(data)
+	var paths []string
+	err = msgpack.ReadArrayStrict(&decoder, func(i uint32, r msgpack.Reader) error {
+		if i != 1 {
+			return r.Skip()
+		}
+		return msgpack.ReadMapStrict(r, func(uint32, msgpack.Reader) error {
+			if _, err := r.ReadString(); err != nil {
+				return err
+			}
+			paths = append(paths, decoder.ContainerPath())
+			return msgpack.ReadArrayStrict(r, func(uint32, msgpack.Reader) error {
+				paths = append(paths, decoder.ContainerPath())
+				_, err := r.ReadArraySize()
+				return err
+			})
+		})
+	})
+	assert.Equal(t, []string{"[1].id", "[1].id[0]"}, paths)
+	var structure msgpack.StructureError
+	require.ErrorAs(t, err, &structure)
+	assert.EqualError(t, err, "msgpack: [1].id[0]: only 1 of the element's 3 bytes were read at offset 7")
+}
+
+func TestReadStrictNotFirst(t *testing.T) {
+	// Only a container at the start of the buffer must end it.
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteNil()
+		w.WriteArraySize(1)
+		w.WriteNil()
+		w.WriteNil()
+	})
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, decoder.Skip())
+	require.NoError(t, msgpack.ReadArrayStrict(&decoder, func(_ uint32, r msgpack.Reader) error { return r.Skip() }))
+	assert.Equal(t, uint32(1), decoder.Remaining())
+
+	decoder = msgpack.NewDecoderWithOptions(data[1:], msgpack.WithTrailingNilPadding())
+	require.NoError(t, msgpack.ReadArrayStrict(&decoder, func(_ uint32, r msgpack.Reader) error { return r.Skip() }))
+}
@@ -0,0 +1,315 @@
+package msgpack
+
+import (
+	"strconv"
+	"strings"
+)
+
+// StringEnum maps a fixed set of strings to their indexes, so that string
+// values such as statuses are written as small integers while the Go API
+// keeps using strings:
+//
+//	var status = msgpack.NewStringEnum("pending", "active", "failed")
+//
+//	status.Write(w, "active") // writes 1
+//	s, err := status.Read(r)  // "active"
+//
+// Read accepts both the integer and the string form, so that readers
+// understand values written before and after a migration to the enum.
+// Values must only be appended to the set, as the index of each value is
+// its wire form.
+type StringEnum struct {
+	values        []string
+	index         map[string]uint32
+	writeUnknown  bool
+	acceptUnknown bool
+}
+
+// NewStringEnum returns the enum of `values`, written as their index. It
+// panics when a value is given twice.
+func NewStringEnum(values ...string) *StringEnum {
+	m := &StringEnum{values: values, index: make(map[string]uint32, len(values))}
+	for i, v := range values {
+		if _, ok := m.index[v]; ok {
+			panic("msgpack: enum value " + strconv.Quote(v) + " given twice")
+		}
+		m.index[v] = uint32(i)
+	}
+	return m
+}
+
+// WriteUnknownAsString makes Write write strings that are not in the set
+// as strings instead of returning an error. It returns `m`.
+func (m *StringEnum) WriteUnknownAsString() *StringEnum {
+	m.writeUnknown = true
+	return m
+}
+
+// AcceptUnknownStrings makes Read return strings that are not in the set
+// instead of an error, for values added by newer producers. It returns
+// `m`.
+func (m *StringEnum) AcceptUnknownStrings() *StringEnum {
+	m.acceptUnknown = true
+	return m
+}
+
+// Values returns the strings of the enum in the order of their indexes.
+func (m *StringEnum) Values() []string {
+	return append([]string(nil), m.values...)
+}
+
+// Write writes the index of `s`. A string that is not in the set is
+// written as a string with WriteUnknownAsString, and is otherwise an
+// error with nothing written.
+func (m *StringEnum) Write(w Writer, s string) error {
+	if i, ok := m.index[s]; ok {
+		w.WriteUint32(i)
+		return w.Err()
+	}
+	if !m.writeUnknown {
+		return WriteError{message: "msgpack: " + strconv.Quote(s) + " is not one of the " +
+			strconv.Itoa(len(m.values)) + " enum values"}
+	}
+	w.WriteString(s)
+	return w.Err()
+}
+
+// WriteNillable writes nil for a nil `s` and is otherwise Write.
+func (m *StringEnum) WriteNillable(w Writer, s *string) error {
+	if s == nil {
+		w.WriteNil()
+		return w.Err()
+	}
+	return m.Write(w, *s)
+}
+
+// Read reads an index and returns its string, or reads a string. An index
+// outside of the set is an error, and so is a string that is not in the
+// set unless AcceptUnknownStrings is set. Strings alias the input buffer,
+// as those ReadString returns do.
+func (m *StringEnum) Read(r Reader) (string, error) {
+	d, ok := r.(*Decoder)
+	if !ok {
+		raw, err := r.ReadRaw()
+		if err != nil {
+			return "", err
+		}
+		decoder := NewDecoder(raw)
+		d = &decoder
+	}
+	offset := d.reader.byteOffset
+	prefix, err := d.PeekFormat()
+	if err != nil {
+		return "", err
+	}
+	kind := prefixKind(prefix)
+	if kind == KindExt && d.options.stringTable {
+		kind = KindString
+	}
+	switch kind {
+	case KindInt, KindUint:
+		v, u, unsigned, err := d.readInteger()
+		if err != nil {
+			return "", err
+		}
+		if !unsigned && v >= 0 {
+			u, unsigned = uint64(v), true
+		}
+		if !unsigned || u >= uint64(len(m.values)) {
+			value := strconv.FormatInt(v, 10)
+			if unsigned {
+				value = strconv.FormatUint(u, 10)
+			}
+			return "", ReadError{"msgpack: enum value " + value + " at offset " +
+				strconv.FormatUint(uint64(offset), 10) + " is not in the range 0 to " +
+				strconv.Itoa(len(m.values)-1)}
+		}
+		return m.values[u], nil
+	case KindString:
+		s, err := d.ReadString()
+		if err != nil {
+			return "", err
+		}
+		if _, ok := m.index[s]; !ok && !m.acceptUnknown {
+			return "", ReadError{"msgpack: " + strconv.Quote(s) + " at offset " +
+				strconv.FormatUint(uint64(offset), 10) + " is not one of the " +
+				strconv.Itoa(len(m.values)) + " enum values"}
+		}
+		return s, nil
+	}
+	d.reader.byteOffset++
+	return "", d.typeMismatch("enum", KindString, prefix, offset)
+}
+
+// ReadNillable reads nil as a nil pointer and is otherwise Read.
+func (m *StringEnum) ReadNillable(r Reader) (*string, error) {
+	isNil, err := readNil(r)
+	if isNil || err != nil {
+		return nil, err
+	}
+	s, err := m.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ReadHook returns a ReadHook that reads the indexes of the enum where a
+// string is read, for decoders that read the field with ReadString and
+// predate the enum. Other values are left to the usual error.
+func (m *StringEnum) ReadHook() ReadHook {
+	return func(requested, actual ValueKind, r ReadHookReader, result *ReadHookResult) (bool, error) {
+		if requested != KindString || actual != KindInt && actual != KindUint {
+			return false, nil
+		}
+		i, err := r.ReadUint64()
+		if err != nil || i >= uint64(len(m.values)) {
+			return false, nil
+		}
+		result.String = m.values[i]
+		return true, nil
+	}
+}
+
+// ReadStringEnum reads a string that must be one of `allowed` and returns
+// the element of `allowed` it equals, which does not alias the input
+// buffer. From a Decoder the string is compared as the bytes read, so a
+// match allocates nothing. Any other string is an error that lists the
+// allowed values and, when one is within a few edits, suggests it:
+//
+//	msgpack: unknown value "actve" at offset 12; did you mean "active"? allowed: active, passive, off
+//
+// Unlike StringEnum, the value is only ever a string on the wire. It
+// panics when `allowed` is empty, as nothing could be read.
+func ReadStringEnum(r Reader, allowed ...string) (string, error) {
+	return readStringEnum(r, allowed, false)
+}
+
+// ReadStringEnumFold is ReadStringEnum comparing strings as
+// strings.EqualFold does. It returns the spelling in `allowed`.
+func ReadStringEnumFold(r Reader, allowed ...string) (string, error) {
+	return readStringEnum(r, allowed, true)
+}
+
+// ReadNillableStringEnum reads nil as a nil pointer and is otherwise
+// ReadStringEnum.
+func ReadNillableStringEnum(r Reader, allowed ...string) (*string, error) {
+	return readNillableStringEnum(r, allowed, false)
+}
+
+// ReadNillableStringEnumFold reads nil as a nil pointer and is otherwise
+// ReadStringEnumFold.
+func ReadNillableStringEnumFold(r Reader, allowed ...string) (*string, error) {
+	return readNillableStringEnum(r, allowed, true)
+}
+
+func readNillableStringEnum(r Reader, allowed []string, fold bool) (*string, error) {
+	if len(allowed) == 0 {
+		panic("msgpack: ReadNillableStringEnum needs at least one allowed value")
+	}
+	isNil, err := readNil(r)
+	if isNil || err != nil {
+		return nil, err
+	}
+	s, err := readStringEnum(r, allowed, fold)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func readStringEnum(r Reader, allowed []string, fold bool) (string, error) {
+	if len(allowed) == 0 {
+		panic("msgpack: ReadStringEnum needs at least one allowed value")
+	}
+	var (
+		s   string
+		err error
+	)
+	d, isDecoder := r.(*Decoder)
+	offset := uint32(0)
+	if isDecoder {
+		offset = d.reader.byteOffset
+		var b []byte
+		b, err = d.ReadStringBytes()
+		s = bytesToString(b)
+	} else {
+		s, err = r.ReadString()
+	}
+	if err != nil {
+		return "", err
+	}
+	// An exact match wins over one that only differs in case.
+	for _, a := range allowed {
+		if s == a {
+			return a, nil
+		}
+	}
+	if fold {
+		for _, a := range allowed {
+			if strings.EqualFold(s, a) {
+				return a, nil
+			}
+		}
+	}
+
+	message := "msgpack: unknown value " + strconv.Quote(s)
+	if isDecoder {
+		message += " at offset " + strconv.FormatUint(uint64(offset), 10)
+	}
+	if suggestion, ok := closestEnumValue(s, allowed, fold); ok {
+		message += "; did you mean " + strconv.Quote(suggestion) + "? allowed: "
+	} else {
+		message += "; allowed: "
+	}
+	return "", ReadError{message + strings.Join(allowed, ", ")}
+}
+
+// closestEnumValue returns the element of `allowed` with the fewest edits
+// from `s`, the first of them on a tie, if it is close enough to be a
+// typo: at most half of its bytes are edited.
+func closestEnumValue(s string, allowed []string, fold bool) (string, bool) {
+	best, bestDistance := "", -1
+	for _, a := range allowed {
+		distance := editDistance(s, a, fold)
+		if distance*2 <= len(a) && (bestDistance < 0 || distance < bestDistance) {
+			best, bestDistance = a, distance
+		}
+	}
+	return best, bestDistance >= 0
+}
+
+// editDistance returns the Levenshtein distance between the bytes of `a`
+// and `b`, ignoring ASCII case when `fold` is set.
+func editDistance(a, b string, fold bool) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 0; i < len(a); i++ {
+		diagonal := row[0]
+		row[0] = i + 1
+		for j := 0; j < len(b); j++ {
+			cost := 1
+			if a[i] == b[j] || fold && lowerASCII(a[i]) == lowerASCII(b[j]) {
+				cost = 0
+			}
+			next := diagonal + cost
+			if row[j]+1 < next {
+				next = row[j] + 1
+			}
+			if row[j+1]+1 < next {
+				next = row[j+1] + 1
+			}
+			diagonal, row[j+1] = row[j+1], next
+		}
+	}
+	return row[len(b)]
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
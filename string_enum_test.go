@@ -0,0 +1,330 @@
+package msgpack_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestStringEnumRoundTrip(t *testing.T) {
+	status := msgpack.NewStringEnum("pending", "active", "failed")
+	data := encodeWith(t, func(w msgpack.Writer) {
+		for _, s := range []string{"pending", "active", "failed"} {
+			require.NoError(t, status.Write(w, s))
+		}
+	})
+	assert.Equal(t, []byte{0x00, 0x01, 0x02}, data)
+
+	decoder := msgpack.NewDecoder(data)
+	for _, expected := range []string{"pending", "active", "failed"} {
+		s, err := status.Read(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, s)
+	}
+	assert.Equal(t, []string{"pending", "active", "failed"}, status.Values())
+}
+
+func TestStringEnumReadsStrings(t *testing.T) {
+	status := msgpack.NewStringEnum("pending", "active", "failed")
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("failed")
+		w.WriteUint8(1)
+		w.WriteString("retired")
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	s, err := status.Read(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", s)
+	s, err = status.Read(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, "active", s)
+	_, err = status.Read(&decoder)
+	assert.EqualError(t, err, `msgpack: "retired" at offset 8 is not one of the 3 enum values`)
+
+	decoder = msgpack.NewDecoder(data[8:])
+	s, err = status.AcceptUnknownStrings().Read(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, "retired", s)
+}
+
+func TestStringEnumReadsStringTableReferences(t *testing.T) {
+	status := msgpack.NewStringEnum("pending", "active")
+	data := encodeWithStringTable(t, func(w msgpack.Writer) {
+		w.WriteString("active")
+		w.WriteString("active")
+		require.NoError(t, status.Write(w, "pending"))
+	})
+
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithStringTableDecoding(stringTableExt))
+	for _, expected := range []string{"active", "active", "pending"} {
+		s, err := status.Read(&decoder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, s)
+	}
+}
+
+func TestStringEnumWriteUnknown(t *testing.T) {
+	status := msgpack.NewStringEnum("pending", "active")
+	encoder := msgpack.NewEncoder(make([]byte, 16))
+	err := status.Write(&encoder, "retired")
+	assert.EqualError(t, err, `msgpack: "retired" is not one of the 2 enum values`)
+	assert.Equal(t, uint32(0), encoder.Len())
+
+	status.WriteUnknownAsString()
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, status.Write(w, "retired"))
+		require.NoError(t, status.Write(w, "active"))
+	})
+	decoder := msgpack.NewDecoder(data)
+	s, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "retired", s)
+	i, err := decoder.ReadUint32()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), i)
+}
+
+func TestStringEnumReadErrors(t *testing.T) {
+	status := msgpack.NewStringEnum("pending", "active", "failed")
+
+	decoder := msgpack.NewDecoder([]byte{0x01, 0xcd, 0x01, 0x00})
+	_, err := status.Read(&decoder)
+	require.NoError(t, err)
+	_, err = status.Read(&decoder)
+	assert.EqualError(t, err, "msgpack: enum value 256 at offset 1 is not in the range 0 to 2")
+
+	decoder = msgpack.NewDecoder([]byte{0xff})
+	_, err = status.Read(&decoder)
+	assert.EqualError(t, err, "msgpack: enum value -1 at offset 0 is not in the range 0 to 2")
+
+	decoder = msgpack.NewDecoder([]byte{0xc3})
+	_, err = status.Read(&decoder)
+	var mismatch msgpack.TypeMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, msgpack.KindString, mismatch.Expected)
+	assert.Equal(t, msgpack.KindBool, mismatch.Actual)
+	assert.Equal(t, uint32(0), mismatch.Offset)
+}
+
+func TestStringEnumNillable(t *testing.T) {
+	status := msgpack.NewStringEnum("pending", "active")
+	active := "active"
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, status.WriteNillable(w, nil))
+		require.NoError(t, status.WriteNillable(w, &active))
+	})
+	assert.Equal(t, []byte{0xc0, 0x01}, data)
+
+	decoder := msgpack.NewDecoder(data)
+	s, err := status.ReadNillable(&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	s, err = status.ReadNillable(&decoder)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, "active", *s)
+}
+
+func TestStringEnumReaderAdapter(t *testing.T) {
+	status := msgpack.NewStringEnum("pending", "active")
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, status.Write(w, "active"))
+		w.WriteString("pending")
+		w.WriteBool(true)
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	reader := msgpack.ReaderAdapter{ReaderCore: coreReader{&decoder}}
+	s, err := status.Read(&reader)
+	require.NoError(t, err)
+	assert.Equal(t, "active", s)
+	s, err = status.Read(&reader)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", s)
+	b, err := reader.ReadBool()
+	require.NoError(t, err)
+	assert.True(t, b)
+}
+
+// A decoder that reads the field with ReadString keeps working once
+// producers switch to writing the enum.
+func TestStringEnumReadHook(t *testing.T) {
+	status := msgpack.NewStringEnum("pending", "active", "failed")
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("pending")
+		require.NoError(t, status.Write(w, "failed"))
+		w.WriteUint8(9)
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	decoder.SetReadHook(status.ReadHook())
+	s, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "pending", s)
+	s, err = decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "failed", s)
+	_, err = decoder.ReadString()
+	var mismatch msgpack.TypeMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+}
+
+func TestStringEnumDuplicate(t *testing.T) {
+	assert.PanicsWithValue(t, `msgpack: enum value "active" given twice`, func() {
+		msgpack.NewStringEnum("pending", "active", "active")
+	})
+}
+
+func TestReadStringEnum(t *testing.T) {
+	modes := []string{"active", "passive", "off"}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("passive")
+		w.WriteString("Active")
+		w.WriteString("actve")
+	})
+	readers := map[string]func() msgpack.Reader{
+		"Decoder": func() msgpack.Reader {
+			d := msgpack.NewDecoder(data)
+			return &d
+		},
+		"ReaderAdapter": func() msgpack.Reader {
+			d := msgpack.NewDecoder(data)
+			return msgpack.ReaderAdapter{ReaderCore: coreReader{&d}}
+		},
+	}
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			r := newReader()
+			s, err := msgpack.ReadStringEnum(r, modes...)
+			require.NoError(t, err)
+			assert.Equal(t, "passive", s)
+			_, err = msgpack.ReadStringEnum(r, modes...)
+			var readErr msgpack.ReadError
+			require.ErrorAs(t, err, &readErr)
+			assert.Contains(t, err.Error(), `unknown value "Active"`)
+			_, err = msgpack.ReadStringEnum(r, modes...)
+			assert.Contains(t, err.Error(), `did you mean "active"? allowed: active, passive, off`)
+		})
+	}
+
+	decoder := msgpack.NewDecoder(data)
+	_, err := decoder.ReadString()
+	require.NoError(t, err)
+	_, err = decoder.ReadString()
+	require.NoError(t, err)
+	_, err = msgpack.ReadStringEnum(&decoder, modes...)
+	assert.EqualError(t, err, `msgpack: unknown value "actve" at offset 15; did you mean "active"? allowed: active, passive, off`)
+
+	// Other values are the usual errors.
+	decoder = msgpack.NewDecoder(encodeWith(t, func(w msgpack.Writer) { w.WriteInt64(1) }))
+	_, err = msgpack.ReadStringEnum(&decoder, modes...)
+	assert.ErrorIs(t, err, msgpack.ErrBadPrefix)
+
+	assert.PanicsWithValue(t, "msgpack: ReadStringEnum needs at least one allowed value", func() {
+		decoder := msgpack.NewDecoder(data)
+		msgpack.ReadStringEnum(&decoder)
+	})
+	assert.PanicsWithValue(t, "msgpack: ReadNillableStringEnum needs at least one allowed value", func() {
+		decoder := msgpack.NewDecoder(data)
+		msgpack.ReadNillableStringEnumFold(&decoder)
+	})
+}
+
+func TestReadStringEnumFold(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteString("ACTIVE")
+		w.WriteString("off")
+		w.WriteString("Of")
+	})
+	decoder := msgpack.NewDecoder(data)
+	s, err := msgpack.ReadStringEnumFold(&decoder, "active", "passive", "off")
+	require.NoError(t, err)
+	assert.Equal(t, "active", s)
+	// An exact match wins over an earlier one in another case.
+	s, err = msgpack.ReadStringEnumFold(&decoder, "OFF", "off")
+	require.NoError(t, err)
+	assert.Equal(t, "off", s)
+	// Suggestions ignore case too.
+	_, err = msgpack.ReadStringEnumFold(&decoder, "active", "OFF")
+	assert.EqualError(t, err, `msgpack: unknown value "Of" at offset 11; did you mean "OFF"? allowed: active, OFF`)
+}
+
+func TestReadStringEnumSuggestion(t *testing.T) {
+	for _, tc := range []struct {
+		value      string
+		allowed    []string
+		suggestion string
+	}{
+		{"actve", []string{"active", "passive", "off"}, "active"},
+		{"pasive", []string{"active", "passive", "off"}, "passive"},
+		{"of", []string{"active", "passive", "off"}, "off"},
+		// The first of the closest values is suggested.
+		{"cat", []string{"car", "bat", "cut"}, "car"},
+		{"cat", []string{"bat", "car", "cut"}, "bat"},
+		// Nothing is suggested when every value is too far off.
+		{"xyz", []string{"active", "passive", "off"}, ""},
+		{"", []string{"active", "passive", "off"}, ""},
+	} {
+		t.Run(tc.value+"/"+strings.Join(tc.allowed, ","), func(t *testing.T) {
+			decoder := msgpack.NewDecoder(encodeWith(t, func(w msgpack.Writer) { w.WriteString(tc.value) }))
+			_, err := msgpack.ReadStringEnum(&decoder, tc.allowed...)
+			expected := "msgpack: unknown value " + strconv.Quote(tc.value) + " at offset 0; "
+			if tc.suggestion != "" {
+				expected += "did you mean " + strconv.Quote(tc.suggestion) + "? "
+			}
+			assert.EqualError(t, err, expected+"allowed: "+strings.Join(tc.allowed, ", "))
+		})
+	}
+}
+
+func TestReadNillableStringEnum(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteNil()
+		w.WriteString("off")
+		w.WriteNil()
+		w.WriteString("Off")
+		w.WriteString("of")
+	})
+	decoder := msgpack.NewDecoder(data)
+	s, err := msgpack.ReadNillableStringEnum(&decoder, "on", "off")
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	s, err = msgpack.ReadNillableStringEnum(&decoder, "on", "off")
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, "off", *s)
+	s, err = msgpack.ReadNillableStringEnumFold(&decoder, "on", "off")
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	s, err = msgpack.ReadNillableStringEnumFold(&decoder, "on", "off")
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, "off", *s)
+	s, err = msgpack.ReadNillableStringEnum(&decoder, "on", "off")
+	assert.Nil(t, s)
+	assert.Error(t, err)
+}
+
+// A match read from a Decoder does not create a string.
+func TestReadStringEnumAllocations(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteString("passive") })
+	start := msgpack.NewDecoder(data)
+	var (
+		decoder msgpack.Decoder
+		s       string
+		err     error
+	)
+	allowed := []string{"active", "passive", "off"}
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		decoder = start
+		s, err = msgpack.ReadStringEnumFold(&decoder, allowed...)
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "passive", s)
+}
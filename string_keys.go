@@ -0,0 +1,108 @@
+package msgpack
+
+import (
+	"encoding/hex"
+	"strconv"
+)
+
+// WithStringifiedMapKeys makes ReadAny return every map as a
+// map[string]any, as JSON objects need, converting keys that are not
+// strings by one rule:
+//
+//   - integers in base 10, such as "-1" and "18446744073709551615"
+//   - floats in the shortest form that reads back as the same float32 or
+//     float64, as strconv.FormatFloat with precision -1 gives
+//   - bools as "true" and "false"
+//   - bin values as "0x" followed by lowercase hex, such as "0x01ff"
+//
+// String keys are kept as they are, even with WithTimeStringDetection.
+// Values are not changed. A nil key, a key of any other kind, and two
+// keys of one map that give the same string, such as 1 and "1" or a
+// repeated key, return an error naming the offset of the key.
+func WithStringifiedMapKeys() DecOption {
+	return func(o *decOptions) {
+		o.stringifiedMapKeys = true
+	}
+}
+
+// readAnyMap reads the `length` entries of a map for ReadAny.
+func (d *Decoder) readAnyMap(length uint32, depth int) (any, error) {
+	if d.options.stringifiedMapKeys {
+		m := make(map[string]any, sizeHint(d, length))
+		err := d.readStringifiedMap(m, length, depth)
+		return m, err
+	}
+	m := make(map[any]any, sizeHint(d, length))
+	err := d.readMap(m, length, depth)
+	return m, err
+}
+
+func (d *Decoder) readStringifiedMap(m map[string]any, length uint32, depth int) error {
+	for i := uint32(0); i < length; i++ {
+		offset := d.reader.byteOffset
+		key, err := d.readStringifiedKey(depth + 1)
+		if err != nil {
+			return err
+		}
+		if _, ok := m[key]; ok {
+			return ReadError{"msgpack: map key " + strconv.Quote(key) + " at offset " +
+				strconv.FormatUint(uint64(offset), 10) + " repeats an earlier key"}
+		}
+		value, err := d.readAny(depth + 1)
+		if err != nil {
+			return err
+		}
+		m[key] = value
+	}
+	return nil
+}
+
+// readStringifiedKey reads a map key and converts it to a string as
+// documented on WithStringifiedMapKeys.
+func (d *Decoder) readStringifiedKey(depth int) (string, error) {
+	offset := d.reader.byteOffset
+	prefix, err := d.reader.PeekUint8()
+	if err != nil {
+		return "", err
+	}
+	timeStringDetection := d.options.timeStringDetection
+	d.options.timeStringDetection = false
+	key, err := d.readAny(depth)
+	d.options.timeStringDetection = timeStringDetection
+	if err != nil {
+		return "", err
+	}
+
+	switch k := key.(type) {
+	case string:
+		return k, nil
+	case bool:
+		return strconv.FormatBool(k), nil
+	case int8:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int64:
+		return strconv.FormatInt(k, 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint64:
+		return strconv.FormatUint(k, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(k), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(k, 'g', -1, 64), nil
+	case []byte:
+		return "0x" + hex.EncodeToString(k), nil
+	case nil:
+		return "", ReadError{"msgpack: nil map key at offset " + strconv.FormatUint(uint64(offset), 10)}
+	}
+	return "", ReadError{"msgpack: map key at offset " + strconv.FormatUint(uint64(offset), 10) +
+		" is " + formatKind(prefix) + " and cannot be converted to a string"}
+}
@@ -0,0 +1,153 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func readStringified(t *testing.T, data []byte, opts ...msgpack.DecOption) (any, error) {
+	t.Helper()
+	decoder := msgpack.NewDecoderWithOptions(data, append(opts, msgpack.WithStringifiedMapKeys())...)
+	return decoder.ReadAny()
+}
+
+func TestStringifiedMapKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w msgpack.Writer)
+		want  string
+	}{
+		{"string", func(w msgpack.Writer) { w.WriteString("name") }, "name"},
+		{"fixint", func(w msgpack.Writer) { w.WriteInt64(7) }, "7"},
+		{"negative", func(w msgpack.Writer) { w.WriteInt64(-300) }, "-300"},
+		{"min int64", func(w msgpack.Writer) { w.WriteInt64(math.MinInt64) }, "-9223372036854775808"},
+		{"max uint64", func(w msgpack.Writer) { w.WriteUint64(math.MaxUint64) }, "18446744073709551615"},
+		{"float32", func(w msgpack.Writer) { w.WriteFloat32(0.1) }, "0.1"},
+		{"float64", func(w msgpack.Writer) { w.WriteFloat64(0.1) }, "0.1"},
+		{"large float", func(w msgpack.Writer) { w.WriteFloat64(1e21) }, "1e+21"},
+		{"true", func(w msgpack.Writer) { w.WriteBool(true) }, "true"},
+		{"false", func(w msgpack.Writer) { w.WriteBool(false) }, "false"},
+		{"bin", func(w msgpack.Writer) { w.WriteByteArray([]byte{0x01, 0xff}) }, "0x01ff"},
+		{"empty bin", func(w msgpack.Writer) { w.WriteByteArray([]byte{}) }, "0x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeWith(t, func(w msgpack.Writer) {
+				w.WriteMapSize(1)
+				tt.write(w)
+				w.WriteBool(true)
+			})
+			value, err := readStringified(t, data)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{tt.want: true}, value)
+		})
+	}
+}
+
+func TestStringifiedMapKeysNested(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteMapSize(1)
+		w.WriteInt64(1)
+		w.WriteMapSize(2)
+		w.WriteInt64(2)
+		w.WriteString("two")
+		w.WriteString("list")
+		w.WriteArraySize(1)
+		w.WriteMapSize(1)
+		w.WriteBool(false)
+		w.WriteNil()
+		w.WriteMapSize(0)
+	})
+	value, err := readStringified(t, data)
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		map[string]any{"1": map[string]any{
+			"2":    "two",
+			"list": []any{map[string]any{"false": nil}},
+		}},
+		map[string]any{},
+	}, value)
+}
+
+func TestStringifiedMapKeysKeepStrings(t *testing.T) {
+	key := "2024-01-02T03:04:05Z"
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteString(key)
+		w.WriteString(key)
+	})
+	value, err := readStringified(t, data, msgpack.WithTimeStringDetection())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{key: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}, value)
+}
+
+func TestStringifiedMapKeysErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(w msgpack.Writer)
+		message string
+	}{
+		{"int and string collide", func(w msgpack.Writer) {
+			w.WriteMapSize(2)
+			w.WriteInt64(1)
+			w.WriteNil()
+			w.WriteString("1")
+			w.WriteNil()
+		}, `msgpack: map key "1" at offset 3 repeats an earlier key`},
+		{"bool and string collide", func(w msgpack.Writer) {
+			w.WriteMapSize(2)
+			w.WriteString("true")
+			w.WriteNil()
+			w.WriteBool(true)
+			w.WriteNil()
+		}, `msgpack: map key "true" at offset 7 repeats an earlier key`},
+		{"repeated key", func(w msgpack.Writer) {
+			w.WriteMapSize(2)
+			w.WriteString("a")
+			w.WriteNil()
+			w.WriteString("a")
+			w.WriteNil()
+		}, `msgpack: map key "a" at offset 4 repeats an earlier key`},
+		{"nil key", func(w msgpack.Writer) {
+			w.WriteArraySize(1)
+			w.WriteMapSize(1)
+			w.WriteNil()
+			w.WriteNil()
+		}, "msgpack: nil map key at offset 2"},
+		{"array key", func(w msgpack.Writer) {
+			w.WriteMapSize(1)
+			w.WriteArraySize(0)
+			w.WriteNil()
+		}, "msgpack: map key at offset 1 is array and cannot be converted to a string"},
+		{"map key", func(w msgpack.Writer) {
+			w.WriteMapSize(1)
+			w.WriteMapSize(0)
+			w.WriteNil()
+		}, "msgpack: map key at offset 1 is map and cannot be converted to a string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readStringified(t, encodeWith(t, tt.write))
+			assert.EqualError(t, err, tt.message)
+		})
+	}
+}
+
+func TestStringifiedMapKeysOff(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(1)
+		w.WriteInt64(1)
+		w.WriteString("one")
+	})
+	decoder := msgpack.NewDecoder(data)
+	value, err := decoder.ReadAny()
+	require.NoError(t, err)
+	assert.Equal(t, map[any]any{int64(1): "one"}, value)
+}
@@ -0,0 +1,253 @@
+package msgpack
+
+import "strconv"
+
+// WithStringTable makes WriteString replace a string that was already
+// written in the same message with a reference to its first occurrence,
+// stored as an ext value of type `extType`. The table is implicit: the
+// distinct strings written in full are numbered in the order they appear,
+// and a reference holds that number as a 1, 2 or 4 byte big-endian fixext
+// payload. A reference is only used when it is shorter than the string.
+//
+// Messages written this way are not standard MessagePack: other decoders
+// see ext values where strings belong. They must be decoded by a Decoder
+// created with WithStringTableDecoding and the same ext type, which fails
+// with an error rather than returning wrong strings when the option is
+// missing. The Sizer used with the encoder needs the option too.
+//
+// The strings of values written with WriteRaw or WriteRawBytes, and those
+// streamed after ReserveStringHeader, are never replaced with references,
+// but they enter the table as the Decoder reading them enters them, so
+// that the references after them stay in step.
+func WithStringTable(extType int8) EncOption {
+	return func(o *encOptions) {
+		o.stringTable = true
+		o.stringTableExt = extType
+	}
+}
+
+// WithStringTableDecoding resolves the string references written by an
+// encoder using WithStringTable with the same `extType`. Strings that are
+// skipped are still entered into the table, so references after a skipped
+// field resolve correctly.
+func WithStringTableDecoding(extType int8) DecOption {
+	return func(o *decOptions) {
+		o.stringTable = true
+		o.stringTableExt = extType
+	}
+}
+
+type stringTableWriter struct {
+	index map[string]uint32
+	order []string
+
+	// payload is set between a Reserve*Header call and its Patch call,
+	// while WriteRawBytes writes a payload rather than encoded values.
+	payload bool
+	// streamed collects the bytes of a string streamed after
+	// ReserveStringHeader, which is entered when its header is patched.
+	streamed []byte
+	inString bool
+}
+
+// ref returns the index of `value` and whether a reference should be
+// written in place of the string. Strings seen for the first time are
+// added to the table.
+func (t *stringTableWriter) ref(value string) (uint32, bool) {
+	if index, ok := t.index[value]; ok {
+		return index, stringRefSize(index) < stringSize(uint32(len(value)))
+	}
+	if t.index == nil {
+		t.index = make(map[string]uint32)
+	}
+	t.index[value] = uint32(len(t.index))
+	t.order = append(t.order, value)
+	return 0, false
+}
+
+// truncate forgets the strings added after the first `n`.
+func (t *stringTableWriter) truncate(n int) {
+	for _, s := range t.order[n:] {
+		delete(t.index, s)
+	}
+	t.order = t.order[:n]
+}
+
+// addRaw enters the strings of the encoded values in `value` into the
+// table, in the order a Decoder reading them enters them.
+func (t *stringTableWriter) addRaw(value []byte, extType int8) {
+	d := NewDecoder(value)
+	d.options.stringTable = true
+	d.options.stringTableExt = extType
+	for d.Remaining() > 0 && d.Skip() == nil {
+	}
+	if d.strings == nil {
+		return
+	}
+	for _, s := range d.strings.strings {
+		// The strings alias `value`, which the caller may reuse.
+		t.ref(string([]byte(s)))
+	}
+}
+
+// reserve starts the payload of a reserved string or bin header.
+func (t *stringTableWriter) reserve(isString bool) {
+	t.payload = true
+	t.inString = isString
+	t.streamed = t.streamed[:0]
+}
+
+// rawBytes accounts for bytes written with WriteRawBytes, which are either
+// part of a reserved payload or whole encoded values.
+func (t *stringTableWriter) rawBytes(value []byte, extType int8) {
+	switch {
+	case t.inString:
+		t.streamed = append(t.streamed, value...)
+	case !t.payload:
+		t.addRaw(value, extType)
+	}
+}
+
+// patch ends a reserved payload, entering a streamed string.
+func (t *stringTableWriter) patch() {
+	if t.inString {
+		t.ref(string(t.streamed))
+	}
+	t.payload = false
+	t.inString = false
+}
+
+func stringRefSize(index uint32) uint32 {
+	if index <= 0xff {
+		return 3
+	} else if index <= 0xffff {
+		return 4
+	}
+	return 6
+}
+
+func stringSize(length uint32) uint32 {
+	if length < 32 {
+		return 1 + length
+	} else if length <= 0xff {
+		return 2 + length
+	} else if length <= 0xffff {
+		return 3 + length
+	}
+	return 5 + length
+}
+
+func (e *Encoder) writeStringRef(index uint32) {
+	if index <= 0xff {
+		e.reader.SetUint8(FormatFixExt1)
+		e.reader.SetInt8(e.options.stringTableExt)
+		e.reader.SetUint8(uint8(index))
+	} else if index <= 0xffff {
+		e.reader.SetUint8(FormatFixExt2)
+		e.reader.SetInt8(e.options.stringTableExt)
+		e.reader.SetUint16(uint16(index))
+	} else {
+		e.reader.SetUint8(FormatFixExt4)
+		e.reader.SetInt8(e.options.stringTableExt)
+		e.reader.SetUint32(index)
+	}
+}
+
+type stringTableReader struct {
+	strings []string
+	seen    map[string]struct{}
+}
+
+func (t *stringTableReader) add(value string) {
+	if _, ok := t.seen[value]; ok {
+		return
+	}
+	t.seen[value] = struct{}{}
+	t.strings = append(t.strings, value)
+}
+
+// truncate forgets the strings added after the first `n`.
+func (t *stringTableReader) truncate(n int) {
+	for _, s := range t.strings[n:] {
+		delete(t.seen, s)
+	}
+	t.strings = t.strings[:n]
+}
+
+func (d *Decoder) stringTable() *stringTableReader {
+	if d.strings == nil {
+		d.strings = &stringTableReader{seen: make(map[string]struct{})}
+	}
+	return d.strings
+}
+
+// skipString discards a string of `length` bytes, entering it into the
+// string table when references are enabled.
+func (d *Decoder) skipString(length uint32) error {
+	if !d.options.stringTable {
+		return d.reader.Discard(length)
+	}
+	b, err := d.reader.GetBytes(length)
+	if err == nil {
+		d.stringTable().add(bytesToString(b))
+	}
+	return err
+}
+
+// readStringRef reads a string reference if one is next.
+func (d *Decoder) readStringRef() (string, bool, error) {
+	prefix, err := d.reader.PeekUint8()
+	if err != nil {
+		return "", false, err
+	}
+	mark := d.reader.byteOffset
+	d.reader.Discard(1)
+	str, ok, err := d.resolveStringRef(prefix)
+	if !ok && err == nil {
+		d.reader.byteOffset = mark
+	}
+	return str, ok, err
+}
+
+// resolveStringRef resolves a string reference whose prefix byte has
+// already been read.
+func (d *Decoder) resolveStringRef(prefix byte) (string, bool, error) {
+	if prefix != FormatFixExt1 && prefix != FormatFixExt2 && prefix != FormatFixExt4 {
+		return "", false, nil
+	}
+	start := d.reader.byteOffset - 1
+	extType, err := d.reader.PeekUint8()
+	if err != nil {
+		return "", false, err
+	}
+	if int8(extType) != d.options.stringTableExt {
+		return "", false, nil
+	}
+	d.reader.Discard(1)
+
+	var index uint32
+	switch prefix {
+	case FormatFixExt1:
+		v, err := d.reader.GetUint8()
+		if err != nil {
+			return "", false, err
+		}
+		index = uint32(v)
+	case FormatFixExt2:
+		v, err := d.reader.GetUint16()
+		if err != nil {
+			return "", false, err
+		}
+		index = uint32(v)
+	default:
+		if index, err = d.reader.GetUint32(); err != nil {
+			return "", false, err
+		}
+	}
+	table := d.stringTable()
+	if uint64(index) >= uint64(len(table.strings)) {
+		return "", false, ReadError{"msgpack: string table reference " + strconv.FormatUint(uint64(index), 10) +
+			" out of range at offset " + strconv.FormatUint(uint64(start), 10)}
+	}
+	return table.strings[index], true, nil
+}
@@ -0,0 +1,238 @@
+package msgpack_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+const stringTableExt = 42
+
+func encodeWithStringTable(t testing.TB, fn func(w msgpack.Writer)) []byte {
+	sizer := msgpack.NewSizerWithOptions(msgpack.WithStringTable(stringTableExt))
+	fn(&sizer)
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, sizer.Len()), msgpack.WithStringTable(stringTableExt))
+	fn(&encoder)
+	require.NoError(t, encoder.Err())
+	require.Equal(t, sizer.Len(), encoder.Len())
+	return encoder.Bytes()
+}
+
+func telemetryBatch(w msgpack.Writer, rows int) {
+	statuses := []string{"ok", "error", "timeout"}
+	regions := []string{"us-east-1", "eu-west-1", "ap-southeast-2"}
+	w.WriteArraySize(uint32(rows))
+	for i := 0; i < rows; i++ {
+		w.WriteMapSize(4)
+		w.WriteString("seq")
+		w.WriteInt64(int64(i))
+		w.WriteString("status")
+		w.WriteString(statuses[i%len(statuses)])
+		w.WriteString("region")
+		w.WriteString(regions[i%len(regions)])
+		w.WriteString("host")
+		w.WriteString("host-" + strconv.Itoa(i%20) + ".internal.example.com")
+	}
+}
+
+func TestStringTableRoundTrip(t *testing.T) {
+	// Enough distinct strings to need 1, 2 and 4 byte references.
+	for _, distinct := range []int{1, 127, 128, 256, 257, 65536, 65537} {
+		t.Run(strconv.Itoa(distinct), func(t *testing.T) {
+			values := make([]string, distinct)
+			for i := range values {
+				values[i] = "string value number " + strconv.Itoa(i)
+			}
+			write := func(w msgpack.Writer) {
+				w.WriteArraySize(uint32(2 * distinct))
+				for _, v := range values {
+					w.WriteString(v)
+				}
+				for i := len(values) - 1; i >= 0; i-- {
+					w.WriteString(values[i])
+				}
+			}
+			data := encodeWithStringTable(t, write)
+			plain := encodeWith(t, write)
+			assert.Less(t, len(data), len(plain))
+
+			decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithStringTableDecoding(stringTableExt))
+			size, err := decoder.ReadArraySize()
+			require.NoError(t, err)
+			require.Equal(t, uint32(2*distinct), size)
+			for _, v := range values {
+				s, err := decoder.ReadString()
+				require.NoError(t, err)
+				require.Equal(t, v, s)
+			}
+			for i := len(values) - 1; i >= 0; i-- {
+				s, err := decoder.ReadString()
+				require.NoError(t, err)
+				require.Equal(t, values[i], s)
+			}
+			assert.Equal(t, uint32(0), decoder.Remaining())
+		})
+	}
+}
+
+func TestStringTableShortStrings(t *testing.T) {
+	// A reference is never longer than the string it replaces.
+	data := encodeWithStringTable(t, func(w msgpack.Writer) {
+		w.WriteArraySize(4)
+		w.WriteString("a")
+		w.WriteString("a")
+		w.WriteString("abc")
+		w.WriteString("abc")
+	})
+	assert.Equal(t, []byte{0x94, 0xa1, 'a', 0xa1, 'a', 0xa3, 'a', 'b', 'c', 0xd4, stringTableExt, 0x01}, data)
+}
+
+func TestStringTableReadAnyAndSkip(t *testing.T) {
+	data := encodeWithStringTable(t, func(w msgpack.Writer) { telemetryBatch(w, 10) })
+
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithStringTableDecoding(stringTableExt))
+	v, err := decoder.ReadAny()
+	require.NoError(t, err)
+	rows := v.([]any)
+	assert.Equal(t, map[any]any{
+		"seq":    int64(9),
+		"status": "ok",
+		"region": "us-east-1",
+		"host":   "host-9.internal.example.com",
+	}, rows[9])
+
+	// Strings inside skipped values still enter the table.
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithStringTableDecoding(stringTableExt))
+	_, err = decoder.ReadArraySize()
+	require.NoError(t, err)
+	require.NoError(t, decoder.Skip())
+	size, err := decoder.ReadMapSize()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(4), size)
+	key, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "seq", key)
+}
+
+func TestStringTableWithoutOption(t *testing.T) {
+	data := encodeWithStringTable(t, func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteString("repeated")
+		w.WriteString("repeated")
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	_, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+	_, err = decoder.ReadString()
+	require.NoError(t, err)
+	_, err = decoder.ReadString()
+	assert.Error(t, err)
+
+	decoder = msgpack.NewDecoder(data)
+	_, err = decoder.ReadAny()
+	assert.Error(t, err)
+
+	// A different ext type is not mistaken for a reference.
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithStringTableDecoding(stringTableExt+1))
+	_, err = decoder.ReadAny()
+	assert.Error(t, err)
+}
+
+func TestStringTableRawValues(t *testing.T) {
+	hello := msgpack.Raw{0xa5, 'h', 'e', 'l', 'l', 'o'}
+	for name, tc := range map[string]struct {
+		write    func(w msgpack.Writer)
+		expected []any
+	}{
+		"WriteRaw": {
+			write: func(w msgpack.Writer) {
+				w.WriteArraySize(3)
+				w.WriteRaw(hello)
+				w.WriteString("world")
+				w.WriteString("world")
+			},
+			expected: []any{"hello", "world", "world"},
+		},
+		"WriteRawBytes": {
+			write: func(w msgpack.Writer) {
+				w.WriteArraySize(4)
+				w.WriteRawBytes([]byte{0x92, 0xa1, 'a', 0xa1, 'b'})
+				w.WriteString("world")
+				w.WriteString("world")
+				w.WriteString("b")
+			},
+			expected: []any{[]any{"a", "b"}, "world", "world", "b"},
+		},
+		"streamed string": {
+			write: func(w msgpack.Writer) {
+				w.WriteArraySize(4)
+				mark := w.ReserveStringHeader()
+				w.WriteRawBytes([]byte("hel"))
+				w.WriteRawBytes([]byte("lo"))
+				w.PatchStringHeader(mark, 5)
+				bin := w.ReserveBinHeader()
+				w.WriteRawBytes([]byte{0xa1, 'x'})
+				w.PatchBinHeader(bin, 2)
+				w.WriteString("world")
+				w.WriteString("world")
+			},
+			expected: []any{"hello", []byte{0xa1, 'x'}, "world", "world"},
+		},
+		"WriteMapWithRawValues": {
+			write: func(w msgpack.Writer) {
+				w.WriteArraySize(3)
+				msgpack.WriteMapWithRawValues(w, []msgpack.RawEntry{{Key: "greeting", Raw: hello}})
+				w.WriteString("world")
+				w.WriteString("world")
+			},
+			expected: []any{map[any]any{"greeting": "hello"}, "world", "world"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := encodeWithStringTable(t, tc.write)
+			decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithStringTableDecoding(stringTableExt))
+			v, err := decoder.ReadAny()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestStringTableBadReference(t *testing.T) {
+	decoder := msgpack.NewDecoderWithOptions([]byte{0x92, 0xa1, 'a', 0xd4, stringTableExt, 0x05},
+		msgpack.WithStringTableDecoding(stringTableExt))
+	_, err := decoder.ReadAny()
+	assert.EqualError(t, err, "msgpack: string table reference 5 out of range at offset 3")
+}
+
+func BenchmarkStringTable(b *testing.B) {
+	write := func(w msgpack.Writer) { telemetryBatch(w, 1000) }
+	var plain msgpack.Sizer
+	write(&plain)
+	tabled := msgpack.NewSizerWithOptions(msgpack.WithStringTable(stringTableExt))
+	write(&tabled)
+	b.Logf("telemetry batch: %d bytes plain, %d bytes with string table", plain.Len(), tabled.Len())
+
+	b.Run("encode", func(b *testing.B) {
+		buffer := make([]byte, tabled.Len())
+		for i := 0; i < b.N; i++ {
+			encoder := msgpack.NewEncoderWithOptions(buffer, msgpack.WithStringTable(stringTableExt))
+			write(&encoder)
+		}
+		b.ReportMetric(float64(tabled.Len())/float64(plain.Len()), "size-ratio")
+	})
+	b.Run("decode", func(b *testing.B) {
+		data := encodeWithStringTable(b, write)
+		for i := 0; i < b.N; i++ {
+			decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithStringTableDecoding(stringTableExt))
+			if _, err := decoder.ReadAny(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package msgpack
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNeedMore is returned by a SuspendableDecoder when the value being read
+// continues past the data appended so far.
+var ErrNeedMore = errors.New("msgpack: need more data")
+
+// SuspendableDecoder decodes a message that arrives in chunks. When a read
+// runs past the data appended so far it returns ErrNeedMore and consumes
+// nothing, so the same read can be retried after AppendData and then
+// succeeds as if the data had been contiguous. Each read either completes
+// or has no effect, so a caller reading a map or array entry by entry can
+// stop at any element and continue once more data arrives. Use Try to
+// retry a whole multi-value read, such as a Codec's Decode, as one unit.
+//
+// Errors are returned from each call and never latch, so Err always
+// returns nil. Strings and byte slices returned by reads remain valid
+// after AppendData.
+type SuspendableDecoder struct {
+	decoder Decoder
+	buffer  []byte
+	offset  uint32
+	base    uint32
+}
+
+// NewSuspendableDecoder creates a decoder with no data, configured by
+// `opts`.
+func NewSuspendableDecoder(opts ...DecOption) *SuspendableDecoder {
+	s := &SuspendableDecoder{}
+	for _, opt := range opts {
+		opt(&s.decoder.options)
+	}
+	return s
+}
+
+// AppendData adds the next chunk of the message. The bytes are copied, and
+// data that has already been consumed is released.
+func (s *SuspendableDecoder) AppendData(more []byte) {
+	pending := s.buffer[s.offset:]
+	buffer := make([]byte, 0, len(pending)+len(more))
+	buffer = append(buffer, pending...)
+	s.buffer = append(buffer, more...)
+	s.base += s.offset
+	s.offset = 0
+}
+
+// Offset returns the number of bytes consumed since the decoder was
+// created.
+func (s *SuspendableDecoder) Offset() uint32 {
+	return s.base + s.offset
+}
+
+// Buffered returns the number of appended bytes not yet consumed.
+func (s *SuspendableDecoder) Buffered() uint32 {
+	return uint32(len(s.buffer)) - s.offset
+}
+
+// Try runs `fn` against the unconsumed data. If `fn` fails with a range
+// error, everything it read is rolled back and ErrNeedMore is returned so
+// it can be run again after AppendData.
+func (s *SuspendableDecoder) Try(fn func(r Reader) error) error {
+	_, err := suspend(s, func(d *Decoder) (struct{}, error) {
+		return struct{}{}, fn(d)
+	})
+	return err
+}
+
+// suspend runs `read` from the current offset, committing what it consumed
+// on success and rolling it back when it ran out of data.
+func suspend[T any](s *SuspendableDecoder, read func(*Decoder) (T, error)) (T, error) {
+	s.decoder.reader = DataReader{buffer: s.buffer, byteOffset: s.offset}
+	tableLen := 0
+	if s.decoder.strings != nil {
+		tableLen = len(s.decoder.strings.strings)
+	}
+	value, err := read(&s.decoder)
+	if err == nil {
+		err = s.decoder.reader.err
+	}
+	if errors.Is(err, ErrRange) {
+		if s.decoder.strings != nil {
+			s.decoder.strings.truncate(tableLen)
+		}
+		var zero T
+		return zero, ErrNeedMore
+	}
+	s.offset = s.decoder.reader.byteOffset
+	return value, err
+}
+
+func (s *SuspendableDecoder) IsNextNil() (bool, error) {
+	return readNil(s)
+}
+
+func (s *SuspendableDecoder) PeekIsNil() (bool, error) {
+	return suspend(s, (*Decoder).PeekIsNil)
+}
+
+func (s *SuspendableDecoder) ConsumeNil() error {
+	return s.Try(func(r Reader) error { return r.ConsumeNil() })
+}
+
+func (s *SuspendableDecoder) ReadBool() (bool, error) {
+	return suspend(s, (*Decoder).ReadBool)
+}
+
+func (s *SuspendableDecoder) ReadNillableBool() (*bool, error) {
+	return suspend(s, (*Decoder).ReadNillableBool)
+}
+
+func (s *SuspendableDecoder) ReadInt8() (int8, error) {
+	return suspend(s, (*Decoder).ReadInt8)
+}
+
+func (s *SuspendableDecoder) ReadNillableInt8() (*int8, error) {
+	return suspend(s, (*Decoder).ReadNillableInt8)
+}
+
+func (s *SuspendableDecoder) ReadInt16() (int16, error) {
+	return suspend(s, (*Decoder).ReadInt16)
+}
+
+func (s *SuspendableDecoder) ReadNillableInt16() (*int16, error) {
+	return suspend(s, (*Decoder).ReadNillableInt16)
+}
+
+func (s *SuspendableDecoder) ReadInt32() (int32, error) {
+	return suspend(s, (*Decoder).ReadInt32)
+}
+
+func (s *SuspendableDecoder) ReadNillableInt32() (*int32, error) {
+	return suspend(s, (*Decoder).ReadNillableInt32)
+}
+
+func (s *SuspendableDecoder) ReadInt64() (int64, error) {
+	return suspend(s, (*Decoder).ReadInt64)
+}
+
+func (s *SuspendableDecoder) ReadNillableInt64() (*int64, error) {
+	return suspend(s, (*Decoder).ReadNillableInt64)
+}
+
+func (s *SuspendableDecoder) ReadUint8() (uint8, error) {
+	return suspend(s, (*Decoder).ReadUint8)
+}
+
+func (s *SuspendableDecoder) ReadNillableUint8() (*uint8, error) {
+	return suspend(s, (*Decoder).ReadNillableUint8)
+}
+
+func (s *SuspendableDecoder) ReadUint16() (uint16, error) {
+	return suspend(s, (*Decoder).ReadUint16)
+}
+
+func (s *SuspendableDecoder) ReadNillableUint16() (*uint16, error) {
+	return suspend(s, (*Decoder).ReadNillableUint16)
+}
+
+func (s *SuspendableDecoder) ReadUint32() (uint32, error) {
+	return suspend(s, (*Decoder).ReadUint32)
+}
+
+func (s *SuspendableDecoder) ReadNillableUint32() (*uint32, error) {
+	return suspend(s, (*Decoder).ReadNillableUint32)
+}
+
+func (s *SuspendableDecoder) ReadUint64() (uint64, error) {
+	return suspend(s, (*Decoder).ReadUint64)
+}
+
+func (s *SuspendableDecoder) ReadNillableUint64() (*uint64, error) {
+	return suspend(s, (*Decoder).ReadNillableUint64)
+}
+
+func (s *SuspendableDecoder) ReadFloat32() (float32, error) {
+	return suspend(s, (*Decoder).ReadFloat32)
+}
+
+func (s *SuspendableDecoder) ReadNillableFloat32() (*float32, error) {
+	return suspend(s, (*Decoder).ReadNillableFloat32)
+}
+
+func (s *SuspendableDecoder) ReadFloat64() (float64, error) {
+	return suspend(s, (*Decoder).ReadFloat64)
+}
+
+func (s *SuspendableDecoder) ReadNillableFloat64() (*float64, error) {
+	return suspend(s, (*Decoder).ReadNillableFloat64)
+}
+
+func (s *SuspendableDecoder) ReadString() (string, error) {
+	return suspend(s, (*Decoder).ReadString)
+}
+
+func (s *SuspendableDecoder) ReadNillableString() (*string, error) {
+	return suspend(s, (*Decoder).ReadNillableString)
+}
+
+func (s *SuspendableDecoder) ReadTime() (time.Time, error) {
+	return suspend(s, (*Decoder).ReadTime)
+}
+
+func (s *SuspendableDecoder) ReadNillableTime() (*time.Time, error) {
+	return suspend(s, (*Decoder).ReadNillableTime)
+}
+
+func (s *SuspendableDecoder) ReadByteArray() ([]byte, error) {
+	return suspend(s, (*Decoder).ReadByteArray)
+}
+
+func (s *SuspendableDecoder) ReadNillableByteArray() ([]byte, error) {
+	return suspend(s, (*Decoder).ReadNillableByteArray)
+}
+
+func (s *SuspendableDecoder) ReadNillableRaw() (Raw, error) {
+	return suspend(s, (*Decoder).ReadNillableRaw)
+}
+
+func (s *SuspendableDecoder) ReadArraySize() (uint32, error) {
+	return suspend(s, (*Decoder).ReadArraySize)
+}
+
+func (s *SuspendableDecoder) ReadMapSize() (uint32, error) {
+	return suspend(s, (*Decoder).ReadMapSize)
+}
+
+func (s *SuspendableDecoder) ReadAny() (any, error) {
+	return suspend(s, (*Decoder).ReadAny)
+}
+
+func (s *SuspendableDecoder) ReadAnyWithRaw() (value any, raw Raw, err error) {
+	err = s.Try(func(r Reader) error {
+		value, raw, err = r.ReadAnyWithRaw()
+		return err
+	})
+	return value, raw, err
+}
+
+func (s *SuspendableDecoder) ReadRaw() (Raw, error) {
+	return suspend(s, (*Decoder).ReadRaw)
+}
+
+func (s *SuspendableDecoder) ReadNillableByteArrayStrict() (value []byte, isNil bool, err error) {
+	err = s.Try(func(r Reader) error {
+		value, isNil, err = r.ReadNillableByteArrayStrict()
+		return err
+	})
+	return value, isNil, err
+}
+
+func (s *SuspendableDecoder) Skip() error {
+	return s.Try(func(r Reader) error { return r.Skip() })
+}
+
+func (s *SuspendableDecoder) Err() error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package msgpack_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type readStep func(r msgpack.Reader) (any, error)
+
+var suspendableSteps = []struct {
+	write func(w msgpack.Writer)
+	read  readStep
+}{
+	{func(w msgpack.Writer) { w.WriteNil() }, func(r msgpack.Reader) (any, error) { return nil, r.ConsumeNil() }},
+	{func(w msgpack.Writer) { w.WriteBool(true) }, func(r msgpack.Reader) (any, error) { return r.ReadBool() }},
+	{func(w msgpack.Writer) { w.WriteInt8(-100) }, func(r msgpack.Reader) (any, error) { return r.ReadInt8() }},
+	{func(w msgpack.Writer) { w.WriteInt16(math.MinInt16) }, func(r msgpack.Reader) (any, error) { return r.ReadInt16() }},
+	{func(w msgpack.Writer) { w.WriteInt32(math.MaxInt32) }, func(r msgpack.Reader) (any, error) { return r.ReadInt32() }},
+	{func(w msgpack.Writer) { w.WriteInt64(math.MinInt64) }, func(r msgpack.Reader) (any, error) { return r.ReadInt64() }},
+	{func(w msgpack.Writer) { w.WriteUint8(200) }, func(r msgpack.Reader) (any, error) { return r.ReadUint8() }},
+	{func(w msgpack.Writer) { w.WriteUint16(math.MaxUint16) }, func(r msgpack.Reader) (any, error) { return r.ReadUint16() }},
+	{func(w msgpack.Writer) { w.WriteUint32(math.MaxUint32) }, func(r msgpack.Reader) (any, error) { return r.ReadUint32() }},
+	{func(w msgpack.Writer) { w.WriteUint64(math.MaxUint64) }, func(r msgpack.Reader) (any, error) { return r.ReadUint64() }},
+	{func(w msgpack.Writer) { w.WriteFloat32(1.5) }, func(r msgpack.Reader) (any, error) { return r.ReadFloat32() }},
+	{func(w msgpack.Writer) { w.WriteFloat64(math.Pi) }, func(r msgpack.Reader) (any, error) { return r.ReadFloat64() }},
+	{func(w msgpack.Writer) { w.WriteString("short") }, func(r msgpack.Reader) (any, error) { return r.ReadString() }},
+	{
+		func(w msgpack.Writer) { w.WriteString(strings.Repeat("long string ", 30)) },
+		func(r msgpack.Reader) (any, error) { return r.ReadString() },
+	},
+	{
+		func(w msgpack.Writer) { w.WriteTime(time.Unix(1700000000, 123456789)) },
+		func(r msgpack.Reader) (any, error) {
+			tm, err := r.ReadTime()
+			return tm.UnixNano(), err
+		},
+	},
+	{func(w msgpack.Writer) { w.WriteByteArray([]byte{1, 2, 3, 4}) }, func(r msgpack.Reader) (any, error) { return r.ReadByteArray() }},
+	{func(w msgpack.Writer) { w.WriteArraySize(20) }, func(r msgpack.Reader) (any, error) { return r.ReadArraySize() }},
+	{func(w msgpack.Writer) { w.WriteMapSize(70000) }, func(r msgpack.Reader) (any, error) { return r.ReadMapSize() }},
+}
+
+// readChunked runs `steps` against `s`, appending the next chunk and
+// retrying whenever a step needs more data.
+func readChunked(t *testing.T, s *msgpack.SuspendableDecoder, chunks [][]byte, steps []readStep) []any {
+	values := make([]any, 0, len(steps))
+	for _, step := range steps {
+		for {
+			v, err := step(s)
+			if errors.Is(err, msgpack.ErrNeedMore) {
+				require.NotEmpty(t, chunks, "ran out of data")
+				s.AppendData(chunks[0])
+				chunks = chunks[1:]
+				continue
+			}
+			require.NoError(t, err)
+			values = append(values, v)
+			break
+		}
+	}
+	return values
+}
+
+func TestSuspendableDecoderEveryBoundary(t *testing.T) {
+	var steps []readStep
+	data := encodeWith(t, func(w msgpack.Writer) {
+		for _, s := range suspendableSteps {
+			s.write(w)
+		}
+	})
+	for _, s := range suspendableSteps {
+		steps = append(steps, s.read)
+	}
+	plain := msgpack.NewDecoder(data)
+	var expected []any
+	for _, step := range steps {
+		v, err := step(&plain)
+		require.NoError(t, err)
+		expected = append(expected, v)
+	}
+
+	for split := 0; split <= len(data); split++ {
+		s := msgpack.NewSuspendableDecoder()
+		s.AppendData(data[:split])
+		values := readChunked(t, s, [][]byte{data[split:]}, steps)
+		require.Equal(t, expected, values, "split at %d", split)
+		assert.Equal(t, uint32(len(data)), s.Offset())
+		assert.Equal(t, uint32(0), s.Buffered())
+	}
+}
+
+func TestSuspendableDecoderNeedMoreConsumesNothing(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteString("hello world") })
+	s := msgpack.NewSuspendableDecoder()
+	s.AppendData(data[:4])
+	for i := 0; i < 3; i++ {
+		_, err := s.ReadString()
+		assert.ErrorIs(t, err, msgpack.ErrNeedMore)
+		assert.Equal(t, uint32(0), s.Offset())
+		assert.Equal(t, uint32(4), s.Buffered())
+		assert.NoError(t, s.Err())
+	}
+	s.AppendData(data[4:])
+	str, err := s.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", str)
+}
+
+func TestSuspendableDecoderMapAcrossThreeChunks(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(3)
+		w.WriteString("id")
+		w.WriteInt64(123456789)
+		w.WriteString("name")
+		w.WriteString("three chunks")
+		w.WriteString("tags")
+		w.WriteAny([]any{"a", "b"})
+	})
+	read := func(r msgpack.Reader) (any, error) {
+		size, err := r.ReadMapSize()
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]any, size)
+		for i := uint32(0); i < size; i++ {
+			key, err := r.ReadString()
+			if err != nil {
+				return nil, err
+			}
+			if m[key], err = r.ReadAny(); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	expected := map[string]any{"id": int32(123456789), "name": "three chunks", "tags": []any{"a", "b"}}
+
+	for i := 0; i <= len(data); i++ {
+		for j := i; j <= len(data); j++ {
+			chunks := [][]byte{data[i:j], data[j:]}
+
+			// Entry by entry: each read resumes where the last one stopped.
+			s := msgpack.NewSuspendableDecoder()
+			s.AppendData(data[:i])
+			steps := []readStep{func(r msgpack.Reader) (any, error) { return r.ReadMapSize() }}
+			for k := 0; k < 3; k++ {
+				steps = append(steps,
+					func(r msgpack.Reader) (any, error) { return r.ReadString() },
+					func(r msgpack.Reader) (any, error) { return r.ReadAny() })
+			}
+			values := readChunked(t, s, chunks, steps)
+			assert.Equal(t, []any{uint32(3), "id", int32(123456789), "name", "three chunks",
+				"tags", []any{"a", "b"}}, values)
+
+			// The whole map as one unit with Try.
+			s = msgpack.NewSuspendableDecoder()
+			s.AppendData(data[:i])
+			var m any
+			values = readChunked(t, s, chunks, []readStep{func(r msgpack.Reader) (any, error) {
+				var err error
+				err = r.(*msgpack.SuspendableDecoder).Try(func(r msgpack.Reader) error {
+					m, err = read(r)
+					return err
+				})
+				return m, err
+			}})
+			require.Equal(t, expected, values[0], "chunks at %d, %d", i, j)
+		}
+	}
+}
+
+func TestSuspendableDecoderStringTable(t *testing.T) {
+	data := encodeWithStringTable(t, func(w msgpack.Writer) {
+		w.WriteArraySize(3)
+		w.WriteString("repeated value")
+		w.WriteString("repeated value")
+		w.WriteString("other")
+	})
+	for split := 0; split <= len(data); split++ {
+		s := msgpack.NewSuspendableDecoder(msgpack.WithStringTableDecoding(stringTableExt))
+		s.AppendData(data[:split])
+		values := readChunked(t, s, [][]byte{data[split:]}, []readStep{
+			func(r msgpack.Reader) (any, error) { return r.ReadAny() },
+		})
+		assert.Equal(t, []any{"repeated value", "repeated value", "other"}, values[0])
+	}
+}
@@ -0,0 +1,35 @@
+package msgpack
+
+import "encoding/base64"
+
+// EncodeToBase64 encodes `value` and returns it as standard base64, for
+// pasting into tickets and tools.
+func EncodeToBase64(value Encodable) (string, error) {
+	var sizer Sizer
+	if err := value.Encode(&sizer); err != nil {
+		return "", err
+	}
+	encoder := NewEncoder(make([]byte, sizer.Len()))
+	if err := value.Encode(&encoder); err != nil {
+		return "", err
+	}
+	if err := encoder.Err(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encoder.Bytes()), nil
+}
+
+// DecodeFromBase64 decodes a T from a document in standard base64, as
+// produced by EncodeToBase64.
+func DecodeFromBase64[T any, PT interface {
+	*T
+	Codec
+}](s string) (T, error) {
+	var inst T
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return inst, err
+	}
+	decoder := NewDecoder(data)
+	return Decode[T, PT](&decoder)
+}
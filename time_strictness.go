@@ -0,0 +1,63 @@
+package msgpack
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// TimeStrictness selects the encodings ReadTime accepts.
+type TimeStrictness int
+
+const (
+	// TimeExtOrString accepts timestamp extensions and RFC 3339 strings.
+	// It is the default.
+	TimeExtOrString TimeStrictness = iota
+	// TimeExtOnly accepts only timestamp extensions, for schemas in which
+	// a string time is a producer bug.
+	TimeExtOnly
+	// TimeAny also accepts integers, read as seconds since the Unix
+	// epoch.
+	TimeAny
+)
+
+func (s TimeStrictness) String() string {
+	switch s {
+	case TimeExtOnly:
+		return "ext"
+	case TimeAny:
+		return "ext, string or integer"
+	}
+	return "ext or string"
+}
+
+// WithTimeFormatStrict sets the encodings ReadTime and the reads built on
+// it accept. Any other value returns a TypeMismatchError whose message
+// lists the accepted encodings.
+func WithTimeFormatStrict(strictness TimeStrictness) DecOption {
+	return func(o *decOptions) {
+		o.timeStrictness = strictness
+	}
+}
+
+// readUnixTime reads an integer time for TimeAny.
+func (d *Decoder) readUnixTime(prefix byte) (time.Time, error) {
+	switch prefix {
+	case FormatUint8, FormatUint16, FormatUint32, FormatUint64:
+		offset := d.reader.byteOffset
+		secs, err := d.ReadUint64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if secs > math.MaxInt64 {
+			return time.Time{}, ReadError{"msgpack: time of " + strconv.FormatUint(secs, 10) +
+				" seconds is out of range at offset " + strconv.FormatUint(uint64(offset), 10)}
+		}
+		return time.Unix(int64(secs), 0), nil
+	}
+	secs, err := d.ReadInt64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(secs, 0), nil
+}
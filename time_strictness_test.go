@@ -0,0 +1,90 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestTimeStrictness(t *testing.T) {
+	tm := time.Date(2021, 6, 1, 12, 30, 45, 0, time.UTC)
+	ext := encodeWith(t, func(w msgpack.Writer) { w.WriteTime(tm) })
+	str := encodeWith(t, func(w msgpack.Writer) { w.WriteString(tm.Format(time.RFC3339)) })
+	integer := encodeWith(t, func(w msgpack.Writer) { w.WriteInt64(tm.Unix()) })
+	unsigned := encodeWith(t, func(w msgpack.Writer) { w.WriteUint64(uint64(tm.Unix())) })
+
+	accepts := map[msgpack.TimeStrictness][]bool{
+		// ext, string, int, uint
+		msgpack.TimeExtOrString: {true, true, false, false},
+		msgpack.TimeExtOnly:     {true, false, false, false},
+		msgpack.TimeAny:         {true, true, true, true},
+	}
+	for strictness, accepted := range accepts {
+		t.Run(strictness.String(), func(t *testing.T) {
+			for i, data := range [][]byte{ext, str, integer, unsigned} {
+				decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithTimeFormatStrict(strictness))
+				got, err := decoder.ReadTime()
+				if !accepted[i] {
+					assert.EqualError(t, err, "bad prefix for time ("+strictness.String()+"): got "+
+						msgpack.FormatName(data[0])+" (0x"+hexByte(data[0])+") at offset 0")
+					continue
+				}
+				require.NoError(t, err, "encoding %d", i)
+				assert.True(t, tm.Equal(got), "encoding %d: %v", i, got)
+			}
+		})
+	}
+}
+
+func hexByte(b byte) string {
+	const digits = "0123456789abcdef"
+	return string([]byte{digits[b>>4], digits[b&0x0f]})
+}
+
+func TestTimeStrictnessErrors(t *testing.T) {
+	decoder := msgpack.NewDecoderWithOptions([]byte{0xa1, 'x'}, msgpack.WithTimeFormatStrict(msgpack.TimeExtOnly))
+	_, err := decoder.ReadTime()
+	assert.EqualError(t, err, "bad prefix for time (ext): got fixstr(1) (0xa1) at offset 0")
+	var mismatch msgpack.TypeMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, msgpack.KindTime, mismatch.Expected)
+	assert.Equal(t, msgpack.KindString, mismatch.Actual)
+
+	decoder = msgpack.NewDecoderWithOptions([]byte{0xc0}, msgpack.WithTimeFormatStrict(msgpack.TimeAny))
+	_, err = decoder.ReadTime()
+	assert.EqualError(t, err, "bad prefix for time (ext, string or integer): got nil (0xc0) at offset 0")
+
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteUint64(math.MaxUint64) })
+	decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithTimeFormatStrict(msgpack.TimeAny))
+	_, err = decoder.ReadTime()
+	assert.EqualError(t, err, "msgpack: time of 18446744073709551615 seconds is out of range at offset 0")
+}
+
+// Arrays used to be read as string times and failed with a parse error.
+// They are now rejected as a type mismatch in every mode, including the
+// default.
+func TestReadTimeRejectsArrays(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x91, 0xa1, 'x'},
+		{msgpack.FormatArray16, 0x00, 0x00},
+		{msgpack.FormatArray32, 0x00, 0x00, 0x00, 0x00},
+	} {
+		for _, strictness := range []msgpack.TimeStrictness{msgpack.TimeExtOrString, msgpack.TimeExtOnly, msgpack.TimeAny} {
+			decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithTimeFormatStrict(strictness))
+			_, err := decoder.ReadTime()
+			var mismatch msgpack.TypeMismatchError
+			require.ErrorAs(t, err, &mismatch, "%s %x", strictness, data)
+			assert.Equal(t, msgpack.KindArray, mismatch.Actual)
+		}
+
+		decoder := msgpack.NewDecoder(data)
+		_, err := decoder.ReadTime()
+		assert.EqualError(t, err, "bad prefix for time (ext or string): got "+
+			msgpack.FormatName(data[0])+" (0x"+hexByte(data[0])+") at offset 0")
+	}
+}
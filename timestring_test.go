@@ -0,0 +1,80 @@
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestTimeStringDetection(t *testing.T) {
+	tests := map[string]bool{
+		"2021-06-01T12:30:45Z":                true,
+		"2021-06-01T12:30:45.123456789Z":      true,
+		"2021-06-01T12:30:45+05:30":           true,
+		"2021-06-01T12:30:45.5-07:00":         true,
+		"2021-13-45T99:99:99Z":                false,
+		"2021-06-01 12:30:45Z":                false,
+		"2021-06-01T12:30:45":                 false,
+		"2021-06-01T12:30:45.Z":               false,
+		"2021-06-01T12:30:45+0530":            false,
+		"hello world, this is not a time":     false,
+		"2021-06-01T12:30:45.123456789+05:30": true,
+	}
+	for value, isTime := range tests {
+		t.Run(value, func(t *testing.T) {
+			data := encodeWith(t, func(w msgpack.Writer) { w.WriteString(value) })
+			decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithTimeStringDetection())
+			result, err := decoder.ReadAny()
+			require.NoError(t, err)
+			if isTime {
+				expected, err := time.Parse(time.RFC3339Nano, value)
+				require.NoError(t, err)
+				assert.Equal(t, expected, result)
+			} else {
+				assert.Equal(t, value, result)
+			}
+
+			decoder = msgpack.NewDecoder(data)
+			result, err = decoder.ReadAny()
+			require.NoError(t, err)
+			assert.Equal(t, value, result, "strings are untouched without the option")
+		})
+	}
+}
+
+func TestWriteAnyTime(t *testing.T) {
+	tm := time.Date(2021, 6, 1, 12, 30, 45, 0, time.UTC)
+	data := encodeWith(t, func(w msgpack.Writer) { w.WriteAny([]any{tm, []time.Time{tm}}) })
+	decoder := msgpack.NewDecoder(data)
+	size, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), size)
+	actual, err := decoder.ReadTime()
+	require.NoError(t, err)
+	assert.True(t, tm.Equal(actual))
+}
+
+func BenchmarkReadAnyString(b *testing.B) {
+	var sizer msgpack.Sizer
+	sizer.WriteString("2021-06-01T12:30:45.123456789Z")
+	data := make([]byte, sizer.Len())
+	encoder := msgpack.NewEncoder(data)
+	encoder.WriteString("2021-06-01T12:30:45.123456789Z")
+
+	b.Run("default", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			decoder := msgpack.NewDecoder(data)
+			decoder.ReadAny()
+		}
+	})
+	b.Run("detection", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithTimeStringDetection())
+			decoder.ReadAny()
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package msgpack
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Transcode rewrites the MessagePack values in `data` in the canonical
+// form of an encoder configured by `opts`, changing only their
+// representation: integers take the smallest format that holds them,
+// signed formats only for negative values, timestamps take the smallest
+// form of extension type -1, and with WithSortedStringMaps or
+// WithSortedIntMaps, maps whose keys are all strings or all integers are
+// sorted. Strings stay strings and bins stay bins, floats keep their
+// precision, and other extension values are copied unchanged.
+//
+// Values are passed from a Decoder to a Sizer and then an Encoder one at a
+// time, without building them in memory as ReadAny does.
+func Transcode(data []byte, opts ...EncOption) ([]byte, error) {
+	sizer := NewSizerWithOptions(opts...)
+	if err := transcodeAll(&sizer, data); err != nil {
+		return nil, err
+	}
+	encoder := NewEncoderWithOptions(make([]byte, sizer.Len()), opts...)
+	if err := transcodeAll(&encoder, data); err != nil {
+		return nil, err
+	}
+	if err := encoder.Err(); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
+
+func transcodeAll(w Writer, data []byte) error {
+	o := encOptionsOf(w)
+	d := NewDecoder(data)
+	for d.Remaining() > 0 {
+		if err := transcodeValue(w, &d, o); err != nil {
+			return err
+		}
+	}
+	return w.Err()
+}
+
+func transcodeValue(w Writer, d *Decoder, o encOptions) error {
+	prefix, err := d.PeekFormat()
+	if err != nil {
+		return err
+	}
+	switch formatKind(prefix) {
+	case "int":
+		v, u, unsigned, err := d.readInteger()
+		switch {
+		case unsigned:
+			w.WriteUint64(u)
+		case v >= 0:
+			w.WriteUint64(uint64(v))
+		default:
+			w.WriteInt64(v)
+		}
+		return err
+	case "string":
+		b, err := d.ReadStringBytes()
+		if o.stringTable {
+			// The string table keeps the strings it has seen.
+			w.WriteString(string(b))
+		} else {
+			w.WriteString(UnsafeString(b))
+		}
+		return err
+	case "array":
+		size, err := d.ReadArraySize()
+		if err != nil {
+			return err
+		}
+		w.WriteArraySize(size)
+		for i := uint32(0); i < size; i++ {
+			if err := transcodeValue(w, d, o); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "map":
+		return transcodeMap(w, d, o)
+	case "ext":
+		if isTimestamp(d) {
+			t, err := d.ReadTime()
+			w.WriteTime(t)
+			return err
+		}
+		raw, err := d.ReadRaw()
+		w.WriteRaw(raw)
+		return err
+	}
+	// nil, bool, float and bin are copied as they are.
+	return copyValue(w, d)
+}
+
+// isTimestamp reports whether the ext value at the current position is a
+// timestamp.
+func isTimestamp(d *Decoder) bool {
+	return d.valueAt().Kind() == KindTime
+}
+
+func transcodeMap(w Writer, d *Decoder, o encOptions) error {
+	size, err := d.ReadMapSize()
+	if err != nil {
+		return err
+	}
+	w.WriteMapSize(size)
+	if !o.sortedStringMaps && !o.sortedIntMaps {
+		for i := uint64(0); i < 2*uint64(size); i++ {
+			if err := transcodeValue(w, d, o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	type entry struct {
+		key, value Raw
+		// str and num hold the key decoded for sorting.
+		str []byte
+		num any
+	}
+	entries := make([]entry, 0, sizeHint(d, size))
+	stringKeys, intKeys := true, true
+	for i := uint32(0); i < size; i++ {
+		entries = append(entries, entry{})
+		e := &entries[i]
+		if e.key, err = d.ReadRaw(); err != nil {
+			return err
+		}
+		if e.value, err = d.ReadRaw(); err != nil {
+			return err
+		}
+		k := NewDecoder(e.key)
+		switch formatKind(e.key[0]) {
+		case "string":
+			e.str, err = k.ReadStringBytes()
+			intKeys = false
+		case "int":
+			e.num, err = readNormalizedValue(&k)
+			stringKeys = false
+		default:
+			stringKeys, intKeys = false, false
+		}
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case stringKeys && o.sortedStringMaps:
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].str, entries[j].str) < 0 })
+	case intKeys && o.sortedIntMaps:
+		sort.Slice(entries, func(i, j int) bool { return integerLess(entries[i].num, entries[j].num) })
+	}
+	for _, e := range entries {
+		for _, raw := range []Raw{e.key, e.value} {
+			inner := NewDecoder(raw)
+			if err := transcodeValue(w, &inner, o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// integerLess orders the int64 and uint64 values of readNormalizedValue.
+func integerLess(a, b any) bool {
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return a < b
+		}
+		return true
+	case uint64:
+		if b, ok := b.(uint64); ok {
+			return a < b
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestTranscodeCanonical(t *testing.T) {
+	input := []byte{
+		0xde, 0x00, 0x07, // map16(7)
+		0xa1, 'i', // "i": array16 of non-minimal integers
+		0xdc, 0x00, 0x05,
+		0xd3, 0, 0, 0, 0, 0, 0, 0, 1, // int64 1
+		0xcd, 0x00, 0x05, // uint16 5
+		0xd2, 0, 0, 0, 200, // int32 200
+		0xd1, 0xff, 0xfb, // int16 -5
+		0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // uint64 max
+		0xd9, 0x01, 'e', // str8 "e": map with unsorted keys
+		0x82, 0xa1, 'b', 0x01, 0xa1, 'a', 0x02,
+		0xa1, 'b', // "b": bin stays bin
+		0xc5, 0x00, 0x04, 't', 'e', 'x', 't',
+		0xa1, 't', // "t": 96 bit timestamp of a 32 bit time
+		0xc7, 0x0c, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10,
+		0xa1, 'x', // "x": unknown ext copied as it is
+		0xc7, 0x04, 0x05, 1, 2, 3, 4,
+		0xa1, 'f', // "f": float32 stays float32
+		0xca, 0x3f, 0xc0, 0x00, 0x00,
+		0xa1, 'n', // "n": map with unsorted integer keys
+		0x82, 0xd0, 0x05, 0xc0, 0xff, 0xc3,
+	}
+	expected := []byte{
+		0x87,
+		0xa1, 'b',
+		0xc4, 0x04, 't', 'e', 'x', 't',
+		0xa1, 'e',
+		0x82, 0xa1, 'a', 0x02, 0xa1, 'b', 0x01,
+		0xa1, 'f',
+		0xca, 0x3f, 0xc0, 0x00, 0x00,
+		0xa1, 'i',
+		0x95, 0x01, 0x05, 0xcc, 200, 0xfb,
+		0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xa1, 'n',
+		0x82, 0xff, 0xc3, 0x05, 0xc0,
+		0xa1, 't',
+		0xd6, 0xff, 0, 0, 0, 10,
+		0xa1, 'x',
+		0xc7, 0x04, 0x05, 1, 2, 3, 4,
+	}
+
+	output, err := msgpack.Transcode(input, msgpack.WithSortedStringMaps(), msgpack.WithSortedIntMaps())
+	require.NoError(t, err)
+	assert.Equal(t, expected, output)
+
+	diffs, err := msgpack.Diff(input, output)
+	require.NoError(t, err)
+	assert.True(t, msgpack.SemanticallyEqual(diffs), "%v", diffs)
+	for _, d := range diffs {
+		assert.Equal(t, msgpack.DiffFormat, d.Kind, "%v", d)
+	}
+
+	v, err := msgpack.DecodeValue(output)
+	require.NoError(t, err)
+	ts, _ := v.MapIndex("t")
+	tm, ok := ts.Time()
+	require.True(t, ok)
+	assert.True(t, time.Unix(10, 0).Equal(tm))
+}
+
+func TestTranscodeKeepsMapOrderUnlessSorting(t *testing.T) {
+	input := []byte{0x82, 0xa1, 'b', 0x01, 0xa1, 'a', 0x02, 0xc0}
+	output, err := msgpack.Transcode(input)
+	require.NoError(t, err)
+	assert.Equal(t, input, output)
+
+	// Keys of mixed types are never sorted.
+	input = []byte{0x82, 0xa1, 'b', 0x01, 0x01, 0x02}
+	output, err = msgpack.Transcode(input, msgpack.WithSortedStringMaps(), msgpack.WithSortedIntMaps())
+	require.NoError(t, err)
+	assert.Equal(t, input, output)
+}
+
+func TestTranscodeErrors(t *testing.T) {
+	_, err := msgpack.Transcode([]byte{0x92, 0x01})
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	_, err = msgpack.Transcode([]byte{0xc1})
+	assert.Error(t, err)
+}
+
+func TestTranscodeTruncatedMaps(t *testing.T) {
+	// The maps claim far more entries than the input holds; 2^31 entries
+	// are 2^32 values.
+	for _, data := range [][]byte{
+		{0xdf, 0xff, 0xff, 0xff, 0xff},
+		{0xdf, 0x80, 0x00, 0x00, 0x00},
+	} {
+		_, err := msgpack.Transcode(data)
+		assert.ErrorIs(t, err, msgpack.ErrRange, "% x", data)
+		_, err = msgpack.Transcode(data, msgpack.WithSortedStringMaps())
+		assert.ErrorIs(t, err, msgpack.ErrRange, "% x", data)
+	}
+}
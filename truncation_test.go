@@ -0,0 +1,47 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestSkipAndReadRawTruncated(t *testing.T) {
+	tests := map[string][]byte{
+		"fixstr":   {0xa3, 'a', 'b'},
+		"str8":     {0xd9, 0x03, 'a'},
+		"bin8":     {0xc4, 0x02, 0x01},
+		"float32":  {0xca, 0x3f, 0x80},
+		"float64":  {0xcb, 0x3f, 0xf0, 0x00, 0x00},
+		"uint8":    {0xcc},
+		"int16":    {0xd1, 0x01},
+		"uint32":   {0xce, 0x00, 0x00, 0x01},
+		"int64":    {0xd3, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		"fixext1":  {0xd4, 0x01},
+		"fixext4":  {0xd6, 0x01, 0x00, 0x00},
+		"fixext16": {0xd8, 0x01, 0x00},
+		"ext8":     {0xc7, 0x04, 0x01, 0x00},
+		"fixarray": {0x92, 0x01},
+		"map16":    {0xde, 0x00, 0x01, 0xa1, 'k'},
+		"nested":   {0x91, 0x81, 0xa1, 'k', 0xcb, 0x00},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			decoder := msgpack.NewDecoder(data)
+			assert.ErrorIs(t, decoder.Skip(), msgpack.ErrRange)
+
+			decoder = msgpack.NewDecoder(data)
+			raw, err := decoder.ReadRaw()
+			assert.ErrorIs(t, err, msgpack.ErrRange)
+			assert.Nil(t, raw)
+
+			// Truncated in an enclosing array.
+			decoder = msgpack.NewDecoder(append([]byte{0x92, 0x01}, data...))
+			raw, err = decoder.ReadRaw()
+			assert.ErrorIs(t, err, msgpack.ErrRange)
+			assert.Nil(t, raw)
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package msgpack
+
+import "strconv"
+
+// readTupleHeader reads the array header of a tuple and checks that it has
+// `arity` elements.
+func readTupleHeader(r Reader, arity uint32) error {
+	size, err := r.ReadArraySize()
+	if err != nil {
+		return err
+	}
+	if size != arity {
+		return ReadError{"msgpack: expected tuple of " + strconv.FormatUint(uint64(arity), 10) +
+			" elements, got " + strconv.FormatUint(uint64(size), 10)}
+	}
+	return nil
+}
+
+// ReadTuple2 reads an array of exactly two elements of different types,
+// reading each with the function for its position.
+func ReadTuple2[A, B any](r Reader,
+	ra func(Reader) (A, error),
+	rb func(Reader) (B, error)) (a A, b B, err error) {
+	if err = readTupleHeader(r, 2); err != nil {
+		return
+	}
+	if a, err = ra(r); err != nil {
+		return
+	}
+	b, err = rb(r)
+	return
+}
+
+// ReadTuple3 reads an array of exactly three elements. See ReadTuple2.
+func ReadTuple3[A, B, C any](r Reader,
+	ra func(Reader) (A, error),
+	rb func(Reader) (B, error),
+	rc func(Reader) (C, error)) (a A, b B, c C, err error) {
+	if err = readTupleHeader(r, 3); err != nil {
+		return
+	}
+	if a, err = ra(r); err != nil {
+		return
+	}
+	if b, err = rb(r); err != nil {
+		return
+	}
+	c, err = rc(r)
+	return
+}
+
+// ReadTuple4 reads an array of exactly four elements. See ReadTuple2.
+func ReadTuple4[A, B, C, D any](r Reader,
+	ra func(Reader) (A, error),
+	rb func(Reader) (B, error),
+	rc func(Reader) (C, error),
+	rd func(Reader) (D, error)) (a A, b B, c C, d D, err error) {
+	if err = readTupleHeader(r, 4); err != nil {
+		return
+	}
+	if a, err = ra(r); err != nil {
+		return
+	}
+	if b, err = rb(r); err != nil {
+		return
+	}
+	if c, err = rc(r); err != nil {
+		return
+	}
+	d, err = rd(r)
+	return
+}
+
+// ReadTuple5 reads an array of exactly five elements. See ReadTuple2.
+func ReadTuple5[A, B, C, D, E any](r Reader,
+	ra func(Reader) (A, error),
+	rb func(Reader) (B, error),
+	rc func(Reader) (C, error),
+	rd func(Reader) (D, error),
+	re func(Reader) (E, error)) (a A, b B, c C, d D, e E, err error) {
+	if err = readTupleHeader(r, 5); err != nil {
+		return
+	}
+	if a, err = ra(r); err != nil {
+		return
+	}
+	if b, err = rb(r); err != nil {
+		return
+	}
+	if c, err = rc(r); err != nil {
+		return
+	}
+	if d, err = rd(r); err != nil {
+		return
+	}
+	e, err = re(r)
+	return
+}
+
+// ReadNillableTuple2 is ReadTuple2 for a tuple that may be nil as a whole,
+// in which case `isNil` is set and the elements are zero.
+func ReadNillableTuple2[A, B any](r Reader,
+	ra func(Reader) (A, error),
+	rb func(Reader) (B, error)) (a A, b B, isNil bool, err error) {
+	if isNil, err = readNil(r); isNil || err != nil {
+		return
+	}
+	a, b, err = ReadTuple2(r, ra, rb)
+	return
+}
+
+// ReadNillableTuple3 is ReadTuple3 for a tuple that may be nil. See
+// ReadNillableTuple2.
+func ReadNillableTuple3[A, B, C any](r Reader,
+	ra func(Reader) (A, error),
+	rb func(Reader) (B, error),
+	rc func(Reader) (C, error)) (a A, b B, c C, isNil bool, err error) {
+	if isNil, err = readNil(r); isNil || err != nil {
+		return
+	}
+	a, b, c, err = ReadTuple3(r, ra, rb, rc)
+	return
+}
+
+// ReadNillableTuple4 is ReadTuple4 for a tuple that may be nil. See
+// ReadNillableTuple2.
+func ReadNillableTuple4[A, B, C, D any](r Reader,
+	ra func(Reader) (A, error),
+	rb func(Reader) (B, error),
+	rc func(Reader) (C, error),
+	rd func(Reader) (D, error)) (a A, b B, c C, d D, isNil bool, err error) {
+	if isNil, err = readNil(r); isNil || err != nil {
+		return
+	}
+	a, b, c, d, err = ReadTuple4(r, ra, rb, rc, rd)
+	return
+}
+
+// ReadNillableTuple5 is ReadTuple5 for a tuple that may be nil. See
+// ReadNillableTuple2.
+func ReadNillableTuple5[A, B, C, D, E any](r Reader,
+	ra func(Reader) (A, error),
+	rb func(Reader) (B, error),
+	rc func(Reader) (C, error),
+	rd func(Reader) (D, error),
+	re func(Reader) (E, error)) (a A, b B, c C, d D, e E, isNil bool, err error) {
+	if isNil, err = readNil(r); isNil || err != nil {
+		return
+	}
+	a, b, c, d, e, err = ReadTuple5(r, ra, rb, rc, rd, re)
+	return
+}
+
+// WriteTuple2 writes `a` and `b` as an array of two elements, writing
+// each with the function for its position.
+func WriteTuple2[A, B any](w Writer,
+	a A, wa func(Writer, A),
+	b B, wb func(Writer, B)) {
+	w.WriteArraySize(2)
+	wa(w, a)
+	wb(w, b)
+}
+
+// WriteTuple3 writes an array of three elements. See WriteTuple2.
+func WriteTuple3[A, B, C any](w Writer,
+	a A, wa func(Writer, A),
+	b B, wb func(Writer, B),
+	c C, wc func(Writer, C)) {
+	w.WriteArraySize(3)
+	wa(w, a)
+	wb(w, b)
+	wc(w, c)
+}
+
+// WriteTuple4 writes an array of four elements. See WriteTuple2.
+func WriteTuple4[A, B, C, D any](w Writer,
+	a A, wa func(Writer, A),
+	b B, wb func(Writer, B),
+	c C, wc func(Writer, C),
+	d D, wd func(Writer, D)) {
+	w.WriteArraySize(4)
+	wa(w, a)
+	wb(w, b)
+	wc(w, c)
+	wd(w, d)
+}
+
+// WriteTuple5 writes an array of five elements. See WriteTuple2.
+func WriteTuple5[A, B, C, D, E any](w Writer,
+	a A, wa func(Writer, A),
+	b B, wb func(Writer, B),
+	c C, wc func(Writer, C),
+	d D, wd func(Writer, D),
+	e E, we func(Writer, E)) {
+	w.WriteArraySize(5)
+	wa(w, a)
+	wb(w, b)
+	wc(w, c)
+	wd(w, d)
+	we(w, e)
+}
@@ -0,0 +1,114 @@
+package msgpack_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type position struct {
+	lat, lon float64
+	alt      int32
+}
+
+func writePosition(w msgpack.Writer, p position) {
+	msgpack.WriteTuple3(w,
+		p.lat, msgpack.Writer.WriteFloat64,
+		p.lon, msgpack.Writer.WriteFloat64,
+		p.alt, msgpack.Writer.WriteInt32)
+}
+
+func readPosition(r msgpack.Reader) (position, error) {
+	lat, lon, alt, err := msgpack.ReadTuple3(r,
+		msgpack.Reader.ReadFloat64,
+		msgpack.Reader.ReadFloat64,
+		msgpack.Reader.ReadInt32)
+	return position{lat, lon, alt}, err
+}
+
+func TestTupleRoundTrip(t *testing.T) {
+	positions := []position{{51.5, -0.12, 35}, {48.85, 2.35, -4}}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(uint32(len(positions)))
+		for _, p := range positions {
+			writePosition(w, p)
+		}
+	})
+	decoder := msgpack.NewDecoder(data)
+	size, err := decoder.ReadArraySize()
+	require.NoError(t, err)
+	decoded := make([]position, size)
+	for i := range decoded {
+		decoded[i], err = readPosition(&decoder)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, positions, decoded)
+
+	data = encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteTuple2(w, "a", msgpack.Writer.WriteString, true, msgpack.Writer.WriteBool)
+		msgpack.WriteTuple4(w, int8(1), msgpack.Writer.WriteInt8, uint16(2), msgpack.Writer.WriteUint16,
+			"c", msgpack.Writer.WriteString, []byte{4}, msgpack.Writer.WriteByteArray)
+		msgpack.WriteTuple5(w, int64(1), msgpack.Writer.WriteInt64, int64(2), msgpack.Writer.WriteInt64,
+			int64(3), msgpack.Writer.WriteInt64, int64(4), msgpack.Writer.WriteInt64, "e", msgpack.Writer.WriteString)
+	})
+	decoder = msgpack.NewDecoder(data)
+	s, b, err := msgpack.ReadTuple2(&decoder, msgpack.Reader.ReadString, msgpack.Reader.ReadBool)
+	require.NoError(t, err)
+	assert.Equal(t, "a", s)
+	assert.True(t, b)
+	i8, u16, c, bin, err := msgpack.ReadTuple4(&decoder, msgpack.Reader.ReadInt8, msgpack.Reader.ReadUint16,
+		msgpack.Reader.ReadString, msgpack.Reader.ReadByteArray)
+	require.NoError(t, err)
+	assert.Equal(t, int8(1), i8)
+	assert.Equal(t, uint16(2), u16)
+	assert.Equal(t, "c", c)
+	assert.Equal(t, []byte{4}, bin)
+	_, _, _, n4, e, err := msgpack.ReadTuple5(&decoder, msgpack.Reader.ReadInt64, msgpack.Reader.ReadInt64,
+		msgpack.Reader.ReadInt64, msgpack.Reader.ReadInt64, msgpack.Reader.ReadString)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n4)
+	assert.Equal(t, "e", e)
+}
+
+func TestTupleWrongArity(t *testing.T) {
+	for _, size := range []uint32{2, 4} {
+		data := encodeWith(t, func(w msgpack.Writer) {
+			w.WriteArraySize(size)
+			for i := uint32(0); i < size; i++ {
+				w.WriteFloat64(1)
+			}
+		})
+		decoder := msgpack.NewDecoder(data)
+		_, err := readPosition(&decoder)
+		assert.EqualError(t, err, "msgpack: expected tuple of 3 elements, got "+strconv.Itoa(int(size)))
+	}
+}
+
+func TestTupleNil(t *testing.T) {
+	data := []byte{msgpack.FormatNil, 0x92, 0x01, 0xa1, 'x'}
+	decoder := msgpack.NewDecoder(data)
+	for _, expectNil := range []bool{true, false} {
+		n, s, isNil, err := msgpack.ReadNillableTuple2(&decoder, msgpack.Reader.ReadInt64, msgpack.Reader.ReadString)
+		require.NoError(t, err)
+		assert.Equal(t, expectNil, isNil)
+		if !expectNil {
+			assert.Equal(t, int64(1), n)
+			assert.Equal(t, "x", s)
+		}
+	}
+
+	// Without the nillable variant nil is the wrong arity.
+	decoder = msgpack.NewDecoder(data)
+	_, _, err := msgpack.ReadTuple2(&decoder, msgpack.Reader.ReadInt64, msgpack.Reader.ReadString)
+	assert.EqualError(t, err, "msgpack: expected tuple of 2 elements, got 0")
+
+	decoder = msgpack.NewDecoder([]byte{msgpack.FormatNil})
+	_, _, _, _, _, isNil, err := msgpack.ReadNillableTuple5(&decoder, msgpack.Reader.ReadInt64, msgpack.Reader.ReadInt64,
+		msgpack.Reader.ReadInt64, msgpack.Reader.ReadInt64, msgpack.Reader.ReadInt64)
+	require.NoError(t, err)
+	assert.True(t, isNil)
+}
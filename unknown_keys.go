@@ -0,0 +1,169 @@
+package msgpack
+
+import "sync"
+
+// OverflowKey is the key under which a SkipAggregator counts the unknown
+// keys past its cap.
+const OverflowKey = "(other)"
+
+// SkipStats counts the values skipped under one unknown key.
+type SkipStats struct {
+	// Count is the number of values skipped.
+	Count uint64
+	// Bytes is the size of the values skipped, not counting their keys.
+	Bytes uint64
+}
+
+func (s *SkipStats) add(o SkipStats) {
+	s.Count += o.Count
+	s.Bytes += o.Bytes
+}
+
+// UnknownKeyReporter records the map keys decoders skip because they do
+// not know them, with the number of bytes skipped, to find producers that
+// send fields no consumer reads. ObjectCodec reports to the reporter set
+// with WithUnknownKeyReporter, and hand-written Decode methods report with
+// Skip or Unknown:
+//
+//	default:
+//		if err := reporter.Skip(r, key); err != nil {
+//			return err
+//		}
+//
+// A reporter covers the messages decoded since it was last reset. It is
+// not safe for concurrent use; merge the reports of several decoders with
+// a SkipAggregator.
+type UnknownKeyReporter struct {
+	// keys holds pointers so that counting a key again does not store
+	// the key again, as it may alias a message.
+	keys map[string]*SkipStats
+}
+
+// WithUnknownKeyReporter makes ObjectCodec report the keys it skips to
+// `reporter`. The default is to skip them silently.
+func WithUnknownKeyReporter(reporter *UnknownKeyReporter) DecOption {
+	return func(o *decOptions) {
+		o.unknownKeys = reporter
+	}
+}
+
+// Unknown records a value of `size` bytes skipped under `key`. A nil
+// reporter records nothing.
+func (rep *UnknownKeyReporter) Unknown(key string, size uint32) {
+	if rep == nil {
+		return
+	}
+	if rep.keys == nil {
+		rep.keys = map[string]*SkipStats{}
+	}
+	stats, ok := rep.keys[key]
+	if !ok {
+		// Keys read from a message alias its buffer.
+		owned := make([]byte, len(key))
+		copy(owned, key)
+		stats = &SkipStats{}
+		rep.keys[string(owned)] = stats
+	}
+	stats.add(SkipStats{Count: 1, Bytes: uint64(size)})
+}
+
+// Skip skips the value of the unknown `key` and records it. A nil reporter
+// only skips it.
+func (rep *UnknownKeyReporter) Skip(r Reader, key string) error {
+	if rep == nil {
+		return r.Skip()
+	}
+	size, err := skipMeasured(r)
+	if err != nil {
+		return err
+	}
+	rep.Unknown(key, size)
+	return nil
+}
+
+// Stats returns the keys recorded since the last reset. The map is a copy.
+func (rep *UnknownKeyReporter) Stats() map[string]SkipStats {
+	stats := make(map[string]SkipStats, len(rep.keys))
+	for key, s := range rep.keys {
+		stats[key] = *s
+	}
+	return stats
+}
+
+// Reset forgets the keys recorded, to start the report of another message.
+func (rep *UnknownKeyReporter) Reset() {
+	for key := range rep.keys {
+		delete(rep.keys, key)
+	}
+}
+
+// skipMeasured skips a value and returns its size.
+func skipMeasured(r Reader) (uint32, error) {
+	if d, ok := r.(*Decoder); ok {
+		start := d.reader.byteOffset
+		err := d.Skip()
+		return d.reader.byteOffset - start, err
+	}
+	raw, err := r.ReadRaw()
+	return uint32(len(raw)), err
+}
+
+// SkipAggregator merges the reports of UnknownKeyReporters across the
+// messages of a service. As the keys come from producers, which may send
+// any number of them, it keeps at most a fixed number of keys and counts
+// the others under OverflowKey. It is safe for concurrent use.
+type SkipAggregator struct {
+	mu       sync.Mutex
+	maxKeys  int
+	keys     map[string]SkipStats
+	overflow SkipStats
+}
+
+// NewSkipAggregator returns an aggregator that keeps up to `maxKeys` keys.
+func NewSkipAggregator(maxKeys int) *SkipAggregator {
+	return &SkipAggregator{maxKeys: maxKeys, keys: map[string]SkipStats{}}
+}
+
+// Add merges the keys recorded by `rep`, typically before resetting it for
+// the next message.
+func (a *SkipAggregator) Add(rep *UnknownKeyReporter) {
+	if rep == nil || len(rep.keys) == 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, s := range rep.keys {
+		stats, ok := a.keys[key]
+		if !ok && len(a.keys) >= a.maxKeys {
+			a.overflow.add(*s)
+			continue
+		}
+		stats.add(*s)
+		a.keys[key] = stats
+	}
+}
+
+// Stats returns the keys counted so far. Keys past the cap are counted
+// together under OverflowKey, which is only present when there were some.
+func (a *SkipAggregator) Stats() map[string]SkipStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := make(map[string]SkipStats, len(a.keys)+1)
+	for key, s := range a.keys {
+		stats[key] = s
+	}
+	if a.overflow.Count > 0 {
+		overflow := stats[OverflowKey]
+		overflow.add(a.overflow)
+		stats[OverflowKey] = overflow
+	}
+	return stats
+}
+
+// Reset forgets the keys counted so far.
+func (a *SkipAggregator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys = map[string]SkipStats{}
+	a.overflow = SkipStats{}
+}
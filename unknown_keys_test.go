@@ -0,0 +1,176 @@
+package msgpack_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+// driftedAddress is an address from a producer that added two fields.
+func driftedAddress(w msgpack.Writer) {
+	w.WriteMapSize(5)
+	w.WriteString("street")
+	w.WriteString("1 Main St")
+	w.WriteString("zip")
+	w.WriteString("12345") // 6 bytes
+	w.WriteString("city")
+	w.WriteString("Springfield")
+	w.WriteString("geo")
+	w.WriteArraySize(2) // 1 + 9 + 9 bytes
+	w.WriteFloat64(51.5)
+	w.WriteFloat64(-0.1)
+	w.WriteString("zip")
+	w.WriteNil() // 1 byte
+}
+
+func TestUnknownKeyReporterObject(t *testing.T) {
+	data := encodeWith(t, driftedAddress)
+	var reporter msgpack.UnknownKeyReporter
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithUnknownKeyReporter(&reporter))
+
+	var address Address
+	require.NoError(t, addressCodec.Decode(&decoder, &address))
+	assert.Equal(t, Address{Street: "1 Main St", City: "Springfield"}, address)
+	assert.Equal(t, map[string]msgpack.SkipStats{
+		"zip": {Count: 2, Bytes: 7},
+		"geo": {Count: 1, Bytes: 19},
+	}, reporter.Stats())
+
+	reporter.Reset()
+	assert.Empty(t, reporter.Stats())
+	decoder = msgpack.NewDecoderWithOptions(encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, addressCodec.Encode(w, &address))
+	}), msgpack.WithUnknownKeyReporter(&reporter))
+	require.NoError(t, addressCodec.Decode(&decoder, &address))
+	assert.Empty(t, reporter.Stats())
+}
+
+// Keys are copied, as the strings read alias the message.
+func TestUnknownKeyReporterKeepsKeys(t *testing.T) {
+	data := encodeWith(t, driftedAddress)
+	var reporter msgpack.UnknownKeyReporter
+	decoder := msgpack.NewDecoderWithOptions(data, msgpack.WithUnknownKeyReporter(&reporter))
+	var address Address
+	require.NoError(t, addressCodec.Decode(&decoder, &address))
+	for i := range data {
+		data[i] = 0
+	}
+	assert.Contains(t, reporter.Stats(), "zip")
+	assert.Contains(t, reporter.Stats(), "geo")
+}
+
+func TestUnknownKeyReporterHandWritten(t *testing.T) {
+	data := encodeWith(t, driftedAddress)
+	var reporter msgpack.UnknownKeyReporter
+	decode := func(r msgpack.Reader, a *Address) error {
+		size, err := r.ReadMapSize()
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i < size; i++ {
+			key, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			switch key {
+			case "street":
+				a.Street, err = r.ReadString()
+			case "city":
+				a.City, err = r.ReadString()
+			default:
+				err = reporter.Skip(r, key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var address Address
+	decoder := msgpack.NewDecoder(data)
+	require.NoError(t, decode(&decoder, &address))
+	decoder = msgpack.NewDecoder(data)
+	require.NoError(t, decode(msgpack.ReaderAdapter{ReaderCore: coreReader{&decoder}}, &address))
+	assert.Equal(t, map[string]msgpack.SkipStats{
+		"zip": {Count: 4, Bytes: 14},
+		"geo": {Count: 2, Bytes: 38},
+	}, reporter.Stats())
+
+	reporter.Unknown("legacy", 3)
+	assert.Equal(t, msgpack.SkipStats{Count: 1, Bytes: 3}, reporter.Stats()["legacy"])
+
+	var none *msgpack.UnknownKeyReporter
+	none.Unknown("legacy", 3)
+	decoder = msgpack.NewDecoder(data)
+	_, err := decoder.ReadMapSize()
+	require.NoError(t, err)
+	key, err := decoder.ReadString()
+	require.NoError(t, err)
+	require.NoError(t, none.Skip(&decoder, key))
+	s, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "zip", s)
+}
+
+func TestSkipAggregator(t *testing.T) {
+	aggregator := msgpack.NewSkipAggregator(2)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var reporter msgpack.UnknownKeyReporter
+			for i := 0; i < 10; i++ {
+				reporter.Unknown("zip", 6)
+				aggregator.Add(&reporter)
+				reporter.Reset()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[string]msgpack.SkipStats{"zip": {Count: 40, Bytes: 240}}, aggregator.Stats())
+
+	// Keys chosen by a producer cannot grow the aggregator past its cap.
+	var reporter msgpack.UnknownKeyReporter
+	for i := 0; i < 100; i++ {
+		reporter.Unknown("key-"+strconv.Itoa(i), 1)
+	}
+	aggregator.Add(&reporter)
+	stats := aggregator.Stats()
+	assert.Len(t, stats, 3)
+	assert.Equal(t, msgpack.SkipStats{Count: 40, Bytes: 240}, stats["zip"])
+	assert.Equal(t, msgpack.SkipStats{Count: 99, Bytes: 99}, stats[msgpack.OverflowKey])
+
+	aggregator.Reset()
+	assert.Empty(t, aggregator.Stats())
+}
+
+func BenchmarkObjectDecodeUnknownKeys(b *testing.B) {
+	data := encodeWith(&testing.T{}, driftedAddress)
+	var reporter msgpack.UnknownKeyReporter
+	for _, bench := range []struct {
+		name string
+		opts []msgpack.DecOption
+	}{
+		{"NoReporter", nil},
+		{"Reporter", []msgpack.DecOption{msgpack.WithUnknownKeyReporter(&reporter)}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var address Address
+			for i := 0; i < b.N; i++ {
+				decoder := msgpack.NewDecoderWithOptions(data, bench.opts...)
+				if err := addressCodec.Decode(&decoder, &address); err != nil {
+					b.Fatal(err)
+				}
+				reporter.Reset()
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+//go:build (purego || appengine) && !wasm && !tinygo.wasm && !wasi
+// +build purego appengine
+// +build !wasm
+// +build !tinygo.wasm
+// +build !wasi
+
+package msgpack
+
+// unsafeStrings reports whether UnsafeString and UnsafeBytes alias their
+// argument in this build.
+const unsafeStrings = false
+
+// UnsafeString returns a copy of the byte slice as a string. Builds tagged
+// purego or appengine, which TinyGo sets for targets other than
+// WebAssembly, do not use package unsafe, so the conversion copies.
+func UnsafeString(b []byte) string {
+	return string(b)
+}
+
+// UnsafeBytes returns a copy of the string as a byte slice. See
+// UnsafeString.
+func UnsafeBytes(s string) []byte {
+	return []byte(s)
+}
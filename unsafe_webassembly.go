@@ -4,17 +4,19 @@
 package msgpack
 
 import (
-	"reflect"
 	"unsafe"
 )
 
+// unsafeStrings reports whether UnsafeString and UnsafeBytes alias their
+// argument in this build.
+const unsafeStrings = true
+
 // UnsafeString returns the byte slice as a volatile string
 // THIS SHOULD ONLY BE USED BY THE CODE GENERATOR.
 // THIS IS EVIL CODE.
 // YOU HAVE BEEN WARNED.
 func UnsafeString(b []byte) string {
-	sh := (*reflect.SliceHeader)(unsafe.Pointer(&b))
-	return *(*string)(unsafe.Pointer(&reflect.StringHeader{Data: sh.Data, Len: sh.Len}))
+	return *(*string)(unsafe.Pointer(&b))
 }
 
 // UnsafeBytes returns the string as a byte slice
@@ -22,9 +24,8 @@ func UnsafeString(b []byte) string {
 // THIS IS EVIL CODE.
 // YOU HAVE BEEN WARNED.
 func UnsafeBytes(s string) []byte {
-	return *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{
-		Len:  uintptr(len(s)),
-		Cap:  uintptr(len(s)),
-		Data: (*(*reflect.StringHeader)(unsafe.Pointer(&s))).Data,
-	}))
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		Cap uintptr
+	}{s, uintptr(len(s))}))
 }
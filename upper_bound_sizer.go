@@ -0,0 +1,214 @@
+package msgpack
+
+import (
+	"time"
+)
+
+// UpperBoundSizer is a Writer that computes a size that is never smaller
+// than what the Encoder writes for the same calls. Every call adds the
+// largest size its value could encode to without inspecting the value, so
+// it is cheaper than Sizer at the cost of over-allocating. Trim the result
+// with the Encoder's Bytes method. Values whose form depends on the
+// encoder options, such as durations written with WithDurationAsString,
+// are only bounded when the UpperBoundSizer is created with
+// NewUpperBoundSizerWithOptions and the options of the encoder.
+type UpperBoundSizer struct {
+	length  uint32
+	options encOptions
+}
+
+func NewUpperBoundSizer() UpperBoundSizer {
+	return UpperBoundSizer{}
+}
+
+func (s *UpperBoundSizer) Len() uint32 {
+	return s.length
+}
+
+func (s *UpperBoundSizer) WriteNil() {
+	s.length++
+}
+
+func (s *UpperBoundSizer) WriteBool(value bool) {
+	s.length++
+}
+
+func (s *UpperBoundSizer) WriteNillableBool(value *bool) {
+	s.length++
+}
+
+func (s *UpperBoundSizer) WriteInt8(value int8) {
+	s.length += 2
+}
+
+func (s *UpperBoundSizer) WriteNillableInt8(value *int8) {
+	s.length += 2
+}
+
+func (s *UpperBoundSizer) WriteInt16(value int16) {
+	s.length += 3
+}
+
+func (s *UpperBoundSizer) WriteNillableInt16(value *int16) {
+	s.length += 3
+}
+
+func (s *UpperBoundSizer) WriteInt32(value int32) {
+	s.length += 5
+}
+
+func (s *UpperBoundSizer) WriteNillableInt32(value *int32) {
+	s.length += 5
+}
+
+func (s *UpperBoundSizer) WriteInt64(value int64) {
+	s.length += 9
+}
+
+func (s *UpperBoundSizer) WriteNillableInt64(value *int64) {
+	s.length += 9
+}
+
+func (s *UpperBoundSizer) WriteUint8(value uint8) {
+	s.length += 2
+}
+
+func (s *UpperBoundSizer) WriteNillableUint8(value *uint8) {
+	s.length += 2
+}
+
+func (s *UpperBoundSizer) WriteUint16(value uint16) {
+	s.length += 3
+}
+
+func (s *UpperBoundSizer) WriteNillableUint16(value *uint16) {
+	s.length += 3
+}
+
+func (s *UpperBoundSizer) WriteUint32(value uint32) {
+	s.length += 5
+}
+
+func (s *UpperBoundSizer) WriteNillableUint32(value *uint32) {
+	s.length += 5
+}
+
+func (s *UpperBoundSizer) WriteUint64(value uint64) {
+	s.length += 9
+}
+
+func (s *UpperBoundSizer) WriteNillableUint64(value *uint64) {
+	s.length += 9
+}
+
+func (s *UpperBoundSizer) WriteFloat32(value float32) {
+	s.length += 5
+}
+
+func (s *UpperBoundSizer) WriteNillableFloat32(value *float32) {
+	s.length += 5
+}
+
+func (s *UpperBoundSizer) WriteFloat64(value float64) {
+	s.length += 9
+}
+
+func (s *UpperBoundSizer) WriteNillableFloat64(value *float64) {
+	s.length += 9
+}
+
+func (s *UpperBoundSizer) WriteString(value string) {
+	s.length += 5 + uint32(len(value))
+}
+
+func (s *UpperBoundSizer) WriteNillableString(value *string) {
+	if value == nil {
+		s.length++
+	} else {
+		s.WriteString(*value)
+	}
+}
+
+func (s *UpperBoundSizer) WriteTime(value time.Time) {
+	// ext8 header, type and the 12 byte timestamp form.
+	s.length += 15
+}
+
+func (s *UpperBoundSizer) WriteNillableTime(value *time.Time) {
+	s.length += 15
+}
+
+func (s *UpperBoundSizer) WriteByteArray(value []byte) {
+	s.length += 5 + uint32(len(value))
+}
+
+func (s *UpperBoundSizer) WriteNillableByteArray(value []byte) {
+	s.WriteByteArray(value)
+}
+
+func (s *UpperBoundSizer) WriteNillableRaw(value Raw) {
+	s.length += 1 + uint32(len(value))
+}
+
+func (s *UpperBoundSizer) WriteArraySize(length uint32) {
+	s.length += 5
+}
+
+func (s *UpperBoundSizer) WriteMapSize(length uint32) {
+	s.length += 5
+}
+
+func (s *UpperBoundSizer) ReserveArraySize() HeaderMark {
+	s.length += 5
+	return HeaderMark{}
+}
+
+func (s *UpperBoundSizer) PatchArraySize(mark HeaderMark, length uint32) {}
+
+func (s *UpperBoundSizer) ReserveMapSize() HeaderMark {
+	s.length += 5
+	return HeaderMark{}
+}
+
+func (s *UpperBoundSizer) PatchMapSize(mark HeaderMark, length uint32) {}
+
+func (s *UpperBoundSizer) ReserveStringHeader() HeaderMark {
+	s.length += 5
+	return HeaderMark{}
+}
+
+func (s *UpperBoundSizer) PatchStringHeader(mark HeaderMark, length uint32) {}
+
+func (s *UpperBoundSizer) ReserveBinHeader() HeaderMark {
+	s.length += 5
+	return HeaderMark{}
+}
+
+func (s *UpperBoundSizer) PatchBinHeader(mark HeaderMark, length uint32) {}
+
+func (s *UpperBoundSizer) WriteRawBytes(value []byte) {
+	s.length += uint32(len(value))
+}
+
+func (s *UpperBoundSizer) WriteRaw(value Raw) {
+	s.length += uint32(len(value))
+}
+
+// WriteAny adds the exact size of `value`, which is always a valid bound.
+// A string the encoder replaces with a string table reference is counted
+// in full.
+func (s *UpperBoundSizer) WriteAny(value any) {
+	sizer := Sizer{options: s.options}
+	sizer.WriteAny(value)
+	s.length += sizer.Len()
+}
+
+func (s *UpperBoundSizer) WriteStringAnyMap(value map[string]any) {
+	sizer := Sizer{options: s.options}
+	sizer.WriteStringAnyMap(value)
+	s.length += sizer.Len()
+}
+
+func (s *UpperBoundSizer) Err() error {
+	return nil
+}
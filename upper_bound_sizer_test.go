@@ -0,0 +1,147 @@
+package msgpack_test
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type sizedWriter interface {
+	msgpack.Writer
+	Len() uint32
+}
+
+func randomWrites(rng *rand.Rand, n int) []func(w msgpack.Writer) {
+	ints := []int64{0, 1, 127, 128, 255, 256, -1, -32, -33, -128, -129,
+		math.MaxInt16, math.MinInt16 - 1, math.MaxInt32, math.MinInt32, math.MaxInt64, math.MinInt64}
+	uints := []uint64{0, 127, 128, 255, 256, math.MaxUint16, math.MaxUint16 + 1, math.MaxUint32, math.MaxUint64}
+	lengths := []int{0, 1, 31, 32, 255, 256, 65535, 65536}
+
+	ops := make([]func(w msgpack.Writer), 0, n)
+	for i := 0; i < n; i++ {
+		var op func(w msgpack.Writer)
+		switch rng.Intn(14) {
+		case 0:
+			op = func(w msgpack.Writer) { w.WriteNil() }
+		case 1:
+			v := rng.Intn(2) == 0
+			op = func(w msgpack.Writer) { w.WriteBool(v) }
+		case 2:
+			v := ints[rng.Intn(len(ints))]
+			op = func(w msgpack.Writer) { w.WriteInt64(v) }
+		case 3:
+			v := int8(ints[rng.Intn(len(ints))])
+			op = func(w msgpack.Writer) { w.WriteInt8(v) }
+		case 4:
+			v := int32(ints[rng.Intn(len(ints))])
+			op = func(w msgpack.Writer) { w.WriteNillableInt32(&v) }
+		case 5:
+			v := uints[rng.Intn(len(uints))]
+			op = func(w msgpack.Writer) { w.WriteUint64(v) }
+		case 6:
+			v := uint16(uints[rng.Intn(len(uints))])
+			op = func(w msgpack.Writer) { w.WriteUint16(v) }
+		case 7:
+			v := rng.Float64()
+			op = func(w msgpack.Writer) { w.WriteFloat64(v) }
+		case 8:
+			v := float32(rng.Float64())
+			op = func(w msgpack.Writer) { w.WriteFloat32(v) }
+		case 9:
+			v := strings.Repeat("x", lengths[rng.Intn(len(lengths))])
+			op = func(w msgpack.Writer) { w.WriteString(v) }
+		case 10:
+			v := make([]byte, lengths[rng.Intn(len(lengths))])
+			op = func(w msgpack.Writer) { w.WriteByteArray(v) }
+		case 11:
+			v := time.Unix(rng.Int63n(1<<40)-1<<39, rng.Int63n(1e9))
+			op = func(w msgpack.Writer) { w.WriteTime(v) }
+		case 12:
+			v := uint32(lengths[rng.Intn(len(lengths))])
+			op = func(w msgpack.Writer) { w.WriteArraySize(v) }
+		case 13:
+			v := uint32(lengths[rng.Intn(len(lengths))])
+			op = func(w msgpack.Writer) { w.WriteMapSize(v) }
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+func TestUpperBoundSizer(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 200; round++ {
+		ops := randomWrites(rng, 1+rng.Intn(20))
+		var sizer msgpack.Sizer
+		upper := msgpack.NewUpperBoundSizer()
+		for _, w := range []sizedWriter{&sizer, &upper} {
+			for _, op := range ops {
+				op(w)
+			}
+		}
+		buffer := make([]byte, upper.Len())
+		encoder := msgpack.NewEncoder(buffer)
+		for _, op := range ops {
+			op(&encoder)
+		}
+		require.NoError(t, encoder.Err())
+		require.Equal(t, sizer.Len(), encoder.Len(), "round %d", round)
+		require.GreaterOrEqual(t, upper.Len(), encoder.Len(), "round %d", round)
+	}
+}
+
+func TestUpperBoundSizerWithOptions(t *testing.T) {
+	opts := []msgpack.EncOption{
+		msgpack.WithDurationAsString(),
+		msgpack.WithStringTable(5),
+		msgpack.WithOmitZeroAsNil(),
+	}
+	empty := ""
+	write := func(w msgpack.Writer) {
+		w.WriteArraySize(5)
+		msgpack.WriteDuration(w, math.MinInt64)
+		w.WriteString("sensor")
+		w.WriteString("sensor")
+		w.WriteNillableString(&empty)
+		w.WriteAny([]any{"sensor", int64(1)})
+	}
+	upper := msgpack.NewUpperBoundSizerWithOptions(opts...)
+	write(&upper)
+	encoder := msgpack.NewEncoderWithOptions(make([]byte, upper.Len()), opts...)
+	write(&encoder)
+	require.NoError(t, encoder.Err())
+	require.GreaterOrEqual(t, upper.Len(), encoder.Len())
+
+	// Without the options the duration is bounded as an int64, which is
+	// shorter than its string form.
+	plain := msgpack.NewUpperBoundSizer()
+	msgpack.WriteDuration(&plain, math.MinInt64)
+	require.Less(t, plain.Len(), uint32(len(time.Duration(math.MinInt64).String())))
+}
+
+func BenchmarkSizing(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	ops := randomWrites(rng, 10000)
+	b.Run("Sizer", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var sizer msgpack.Sizer
+			for _, op := range ops {
+				op(&sizer)
+			}
+		}
+	})
+	b.Run("UpperBoundSizer", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var sizer msgpack.UpperBoundSizer
+			for _, op := range ops {
+				op(&sizer)
+			}
+		}
+	})
+}
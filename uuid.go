@@ -0,0 +1,211 @@
+package msgpack
+
+import (
+	"strconv"
+)
+
+type uuidOptions struct {
+	asString  bool
+	extType   int8
+	extSet    bool
+	int64Pair bool
+}
+
+// UUIDOption configures how UUIDs are written and which wire forms are
+// accepted when reading them.
+type UUIDOption func(*uuidOptions)
+
+// WithUUIDString writes UUIDs as the 36 character canonical string
+// instead of a 16 byte bin.
+func WithUUIDString() UUIDOption {
+	return func(o *uuidOptions) {
+		o.asString = true
+	}
+}
+
+// WithUUIDExtType accepts UUIDs stored as a 16 byte ext value of type `t`.
+func WithUUIDExtType(t int8) UUIDOption {
+	return func(o *uuidOptions) {
+		o.extType = t
+		o.extSet = true
+	}
+}
+
+// WithUUIDInt64Pair accepts UUIDs stored as an array of two int64 values
+// holding the most and least significant bits, as some Java producers do.
+func WithUUIDInt64Pair() UUIDOption {
+	return func(o *uuidOptions) {
+		o.int64Pair = true
+	}
+}
+
+func applyUUIDOptions(opts []UUIDOption) uuidOptions {
+	var o uuidOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WriteUUID writes `id` as a 16 byte bin, or as a string when
+// WithUUIDString is given.
+func WriteUUID(w Writer, id [16]byte, opts ...UUIDOption) {
+	o := applyUUIDOptions(opts)
+	if o.asString {
+		var buf [36]byte
+		w.WriteString(string(formatUUID(buf[:0], id)))
+		return
+	}
+	w.WriteByteArray(id[:])
+}
+
+// WriteNillableUUID writes nil when `id` is nil, otherwise as WriteUUID.
+func WriteNillableUUID(w Writer, id *[16]byte, opts ...UUIDOption) {
+	if id == nil {
+		w.WriteNil()
+	} else {
+		WriteUUID(w, *id, opts...)
+	}
+}
+
+// ReadUUID reads a UUID stored as a 16 byte bin or as a 32 or 36
+// character hex string in either case. Ext and int64 pair encodings are
+// accepted when enabled by the matching options.
+func ReadUUID(r Reader, opts ...UUIDOption) ([16]byte, error) {
+	var id [16]byte
+	raw, err := r.ReadRaw()
+	if err != nil {
+		return id, err
+	}
+	return parseUUIDRaw(raw, applyUUIDOptions(opts))
+}
+
+// ReadNillableUUID reads a UUID that may be nil. When it is, `ok` is false
+// and the zero UUID is returned.
+func ReadNillableUUID(r Reader, opts ...UUIDOption) (id [16]byte, ok bool, err error) {
+	isNil, err := readNil(r)
+	if isNil || err != nil {
+		return id, false, err
+	}
+	id, err = ReadUUID(r, opts...)
+	return id, err == nil, err
+}
+
+func parseUUIDRaw(raw Raw, o uuidOptions) ([16]byte, error) {
+	var id [16]byte
+	d := NewDecoder(raw)
+	prefix := raw[0]
+	switch {
+	case prefix == FormatBin8 || prefix == FormatBin16 || prefix == FormatBin32:
+		b, err := d.ReadByteArray()
+		if err != nil {
+			return id, err
+		}
+		if len(b) != 16 {
+			return id, ReadError{"msgpack: uuid bin must be 16 bytes, got " + strconv.Itoa(len(b))}
+		}
+		copy(id[:], b)
+		return id, nil
+	case isFixedString(prefix) || prefix == FormatString8 ||
+		prefix == FormatString16 || prefix == FormatString32:
+		s, err := d.ReadString()
+		if err != nil {
+			return id, err
+		}
+		return parseUUIDString(s)
+	case prefix >= FormatFixExt1 && prefix <= FormatFixExt16 ||
+		prefix >= FormatExt8 && prefix <= FormatExt32:
+		d.reader.Discard(1)
+		extID, extLen, err := d.extHeader(prefix)
+		if err != nil {
+			return id, err
+		}
+		if !o.extSet || extID != o.extType {
+			return id, ReadError{"msgpack: uuid ext type " + strconv.Itoa(int(extID)) + " is not registered"}
+		}
+		if extLen != 16 {
+			return id, ReadError{"msgpack: uuid ext must be 16 bytes, got " + strconv.FormatUint(uint64(extLen), 10)}
+		}
+		b, err := d.reader.GetBytes(extLen)
+		if err != nil {
+			return id, err
+		}
+		copy(id[:], b)
+		return id, nil
+	case isFixedArray(prefix) || prefix == FormatArray16 || prefix == FormatArray32:
+		if !o.int64Pair {
+			return id, ReadError{"msgpack: uuid encoded as an array; use WithUUIDInt64Pair to accept [msb, lsb] int64 pairs"}
+		}
+		size, err := d.ReadArraySize()
+		if err != nil {
+			return id, err
+		}
+		if size != 2 {
+			return id, ReadError{"msgpack: uuid int64 pair must have 2 elements, got " + strconv.FormatUint(uint64(size), 10)}
+		}
+		for i := 0; i < 2; i++ {
+			v, err := d.ReadInt64()
+			if err != nil {
+				return id, err
+			}
+			for j := 0; j < 8; j++ {
+				id[i*8+j] = byte(uint64(v) >> (56 - 8*j))
+			}
+		}
+		return id, nil
+	}
+	return id, ReadError{"msgpack: bad prefix for uuid"}
+}
+
+func parseUUIDString(s string) ([16]byte, error) {
+	var id [16]byte
+	switch len(s) {
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return id, ReadError{"msgpack: invalid uuid string " + strconv.Quote(s)}
+		}
+	case 32:
+	default:
+		return id, ReadError{"msgpack: uuid string must be 32 or 36 characters, got " + strconv.Itoa(len(s))}
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if len(s) == 36 && (i == 8 || i == 13 || i == 18 || i == 23) {
+			continue
+		}
+		v := fromHexChar(s[i])
+		if v > 0x0f {
+			return id, ReadError{"msgpack: invalid uuid string " + strconv.Quote(s)}
+		}
+		if n%2 == 0 {
+			id[n/2] = v << 4
+		} else {
+			id[n/2] |= v
+		}
+		n++
+	}
+	return id, nil
+}
+
+func formatUUID(dst []byte, id [16]byte) []byte {
+	const hexDigits = "0123456789abcdef"
+	for i, b := range id {
+		if i == 4 || i == 6 || i == 8 || i == 10 {
+			dst = append(dst, '-')
+		}
+		dst = append(dst, hexDigits[b>>4], hexDigits[b&0x0f])
+	}
+	return dst
+}
+
+func fromHexChar(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10
+	}
+	return 0xff
+}
@@ -0,0 +1,147 @@
+package msgpack_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+var testUUID = [16]byte{
+	0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3,
+	0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00,
+}
+
+func encodeWith(t *testing.T, fn func(w msgpack.Writer)) []byte {
+	t.Helper()
+	var sizer msgpack.Sizer
+	fn(&sizer)
+	buffer := make([]byte, sizer.Len())
+	encoder := msgpack.NewEncoder(buffer)
+	fn(&encoder)
+	require.NoError(t, encoder.Err())
+	require.Equal(t, sizer.Len(), encoder.Len())
+	return buffer
+}
+
+func TestUUIDRoundTrip(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) { msgpack.WriteUUID(w, testUUID) })
+	assert.Equal(t, "c410123e4567e89b12d3a456426614174000", hex.EncodeToString(data))
+	decoder := msgpack.NewDecoder(data)
+	id, err := msgpack.ReadUUID(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, testUUID, id)
+
+	data = encodeWith(t, func(w msgpack.Writer) { msgpack.WriteUUID(w, testUUID, msgpack.WithUUIDString()) })
+	decoder = msgpack.NewDecoder(data)
+	text, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", text)
+	decoder = msgpack.NewDecoder(data)
+	id, err = msgpack.ReadUUID(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, testUUID, id)
+}
+
+func TestReadUUIDForms(t *testing.T) {
+	tests := map[string]struct {
+		write func(w msgpack.Writer)
+		opts  []msgpack.UUIDOption
+	}{
+		"upper case dashed": {
+			write: func(w msgpack.Writer) { w.WriteString("123E4567-E89B-12D3-A456-426614174000") },
+		},
+		"no dashes": {
+			write: func(w msgpack.Writer) { w.WriteString("123e4567e89b12d3a456426614174000") },
+		},
+		"ext": {
+			write: func(w msgpack.Writer) {
+				w.WriteRaw(append(msgpack.Raw{msgpack.FormatFixExt16, 0x25}, testUUID[:]...))
+			},
+			opts: []msgpack.UUIDOption{msgpack.WithUUIDExtType(0x25)},
+		},
+		"java int64 pair": {
+			write: func(w msgpack.Writer) {
+				w.WriteArraySize(2)
+				w.WriteInt64(0x123e4567e89b12d3)
+				w.WriteInt64(-0x5ba9bd99ebe8c000)
+			},
+			opts: []msgpack.UUIDOption{msgpack.WithUUIDInt64Pair()},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := encodeWith(t, tt.write)
+			decoder := msgpack.NewDecoder(data)
+			id, err := msgpack.ReadUUID(&decoder, tt.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, testUUID, id)
+		})
+	}
+}
+
+func TestReadUUIDErrors(t *testing.T) {
+	tests := map[string]struct {
+		write    func(w msgpack.Writer)
+		contains string
+	}{
+		"short bin": {
+			write:    func(w msgpack.Writer) { w.WriteByteArray(make([]byte, 15)) },
+			contains: "16 bytes, got 15",
+		},
+		"short string": {
+			write:    func(w msgpack.Writer) { w.WriteString("123e4567") },
+			contains: "32 or 36 characters",
+		},
+		"bad hex": {
+			write:    func(w msgpack.Writer) { w.WriteString("123e4567-e89b-12d3-a456-42661417400g") },
+			contains: "invalid uuid string",
+		},
+		"misplaced dash": {
+			write:    func(w msgpack.Writer) { w.WriteString("123e4567e-89b-12d3-a456-426614174000") },
+			contains: "invalid uuid string",
+		},
+		"unregistered ext": {
+			write: func(w msgpack.Writer) {
+				w.WriteRaw(append(msgpack.Raw{msgpack.FormatFixExt16, 0x25}, testUUID[:]...))
+			},
+			contains: "not registered",
+		},
+		"java pair without option": {
+			write: func(w msgpack.Writer) {
+				w.WriteArraySize(2)
+				w.WriteInt64(1)
+				w.WriteInt64(2)
+			},
+			contains: "WithUUIDInt64Pair",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := encodeWith(t, tt.write)
+			decoder := msgpack.NewDecoder(data)
+			_, err := msgpack.ReadUUID(&decoder)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.contains)
+		})
+	}
+}
+
+func TestNillableUUID(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteNillableUUID(w, nil)
+		msgpack.WriteNillableUUID(w, &testUUID)
+	})
+	decoder := msgpack.NewDecoder(data)
+	id, ok, err := msgpack.ReadNillableUUID(&decoder)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, [16]byte{}, id)
+	id, ok, err = msgpack.ReadNillableUUID(&decoder)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, testUUID, id)
+}
@@ -0,0 +1,287 @@
+package msgpack
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrNoValidOffset is returned by FindNextValidOffset when no position in
+// the scanned range holds a value that passes validation.
+var ErrNoValidOffset = errors.New("msgpack: no valid value found")
+
+const defaultValidateMaxDepth = 512
+
+type validateOptions struct {
+	maxDepth      uint32
+	maxElements   uint32
+	minMapEntries uint32
+	requireMap    bool
+}
+
+// ValidateOption configures Validate and FindNextValidOffset.
+type ValidateOption func(*validateOptions)
+
+// WithValidateMaxDepth limits how deeply arrays and maps may nest. The
+// default is 512.
+func WithValidateMaxDepth(depth uint32) ValidateOption {
+	return func(o *validateOptions) {
+		o.maxDepth = depth
+	}
+}
+
+// WithValidateMaxElements limits the number of values, counting containers
+// and their contents, that one validation may inspect. Validation fails once
+// the budget is used up. The default is no limit.
+func WithValidateMaxElements(elements uint32) ValidateOption {
+	return func(o *validateOptions) {
+		o.maxElements = elements
+	}
+}
+
+// WithMinMapEntries requires the top level value to be a map with at least
+// `entries` entries. It is a plausibility filter for FindNextValidOffset:
+// almost any byte is a valid fixint, so without it a scan stops at the first
+// position after the corruption.
+func WithMinMapEntries(entries uint32) ValidateOption {
+	return func(o *validateOptions) {
+		o.requireMap = true
+		o.minMapEntries = entries
+	}
+}
+
+// Validate checks that `data` holds exactly one complete, well-formed value.
+// Every length is checked against the buffer, reserved formats are rejected
+// and timestamp extensions must have one of the three standard lengths.
+// Nothing is decoded or allocated.
+func Validate(data []byte, opts ...ValidateOption) error {
+	o := newValidateOptions(opts)
+	v := validator{reader: NewDataReader(data), options: &o}
+	if err := v.top(); err != nil {
+		return err
+	}
+	if remaining := v.reader.Remaining(); remaining != 0 {
+		return ReadError{"msgpack: " + strconv.FormatUint(uint64(remaining), 10) +
+			" trailing bytes at offset " + strconv.FormatUint(uint64(v.reader.byteOffset), 10)}
+	}
+	return nil
+}
+
+// FindNextValidOffset scans `data` forward from `from` one byte at a time and
+// returns the first offset at which a complete, well-formed value starts.
+// The value need not extend to the end of `data`, so the result is where a
+// MultiDecoder can resume after corruption. It returns ErrNoValidOffset when
+// no offset qualifies.
+//
+// The scan is best effort: bytes inside the corrupted region can happen to
+// form a valid value. Use WithMinMapEntries to reject implausible matches.
+//
+// Each attempt inspects at most the element budget set with
+// WithValidateMaxElements, which defaults to 4096 here, so the scan performs
+// at most (len(data)-from) × budget element checks. A document with more
+// elements than the budget is never reported as valid.
+func FindNextValidOffset(data []byte, from uint32, opts ...ValidateOption) (uint32, error) {
+	o := validateOptions{maxDepth: defaultValidateMaxDepth, maxElements: 4096}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for offset := uint64(from); offset < uint64(len(data)); offset++ {
+		v := validator{reader: NewDataReader(data), options: &o}
+		v.reader.byteOffset = uint32(offset)
+		if v.top() == nil {
+			return uint32(offset), nil
+		}
+	}
+	return 0, ErrNoValidOffset
+}
+
+func newValidateOptions(opts []ValidateOption) validateOptions {
+	o := validateOptions{maxDepth: defaultValidateMaxDepth}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type validator struct {
+	reader   DataReader
+	options  *validateOptions
+	elements uint32
+}
+
+func (v *validator) top() error {
+	if v.options.requireMap {
+		start := v.reader.byteOffset
+		prefix, err := v.reader.PeekUint8()
+		if err != nil {
+			return err
+		}
+		entries, ok, err := v.mapHeader(prefix)
+		if err != nil {
+			return err
+		}
+		if !ok || entries < v.options.minMapEntries {
+			return v.errorAt(start, "top level value is not a map with at least "+
+				strconv.FormatUint(uint64(v.options.minMapEntries), 10)+" entries")
+		}
+		v.reader.byteOffset = start
+	}
+	return v.value(0)
+}
+
+// mapHeader returns the entry count when `prefix` starts a map, leaving the
+// reader after the header.
+func (v *validator) mapHeader(prefix byte) (uint32, bool, error) {
+	switch {
+	case isFixedMap(prefix):
+		v.reader.Discard(1)
+		return uint32(prefix & FormatFourLeastSigBitsInByte), true, nil
+	case prefix == FormatMap16:
+		v.reader.Discard(1)
+		n, err := v.reader.GetUint16()
+		return uint32(n), true, err
+	case prefix == FormatMap32:
+		v.reader.Discard(1)
+		n, err := v.reader.GetUint32()
+		return n, true, err
+	}
+	return 0, false, nil
+}
+
+func (v *validator) value(depth uint32) error {
+	start := v.reader.byteOffset
+	if v.options.maxElements > 0 && v.elements >= v.options.maxElements {
+		return v.errorAt(start, "element budget exceeded")
+	}
+	v.elements++
+
+	prefix, err := v.reader.GetUint8()
+	if err != nil {
+		return err
+	}
+	switch {
+	case isFixedInt(prefix) || isNegativeFixedInt(prefix):
+		return nil
+	case isFixedString(prefix):
+		return v.reader.Discard(uint32(prefix & 0x1f))
+	case isFixedArray(prefix):
+		return v.container(start, uint64(prefix&FormatFourLeastSigBitsInByte), depth)
+	case isFixedMap(prefix):
+		return v.container(start, 2*uint64(prefix&FormatFourLeastSigBitsInByte), depth)
+	}
+
+	switch prefix {
+	case FormatNil, FormatTrue, FormatFalse:
+		return nil
+	case FormatUint8, FormatInt8:
+		return v.reader.Discard(1)
+	case FormatUint16, FormatInt16:
+		return v.reader.Discard(2)
+	case FormatUint32, FormatInt32, FormatFloat32:
+		return v.reader.Discard(4)
+	case FormatUint64, FormatInt64, FormatFloat64:
+		return v.reader.Discard(8)
+	case FormatString8, FormatBin8:
+		n, err := v.reader.GetUint8()
+		if err != nil {
+			return err
+		}
+		return v.reader.Discard(uint32(n))
+	case FormatString16, FormatBin16:
+		n, err := v.reader.GetUint16()
+		if err != nil {
+			return err
+		}
+		return v.reader.Discard(uint32(n))
+	case FormatString32, FormatBin32:
+		n, err := v.reader.GetUint32()
+		if err != nil {
+			return err
+		}
+		return v.reader.Discard(n)
+	case FormatArray16:
+		n, err := v.reader.GetUint16()
+		if err != nil {
+			return err
+		}
+		return v.container(start, uint64(n), depth)
+	case FormatArray32:
+		n, err := v.reader.GetUint32()
+		if err != nil {
+			return err
+		}
+		return v.container(start, uint64(n), depth)
+	case FormatMap16:
+		n, err := v.reader.GetUint16()
+		if err != nil {
+			return err
+		}
+		return v.container(start, 2*uint64(n), depth)
+	case FormatMap32:
+		n, err := v.reader.GetUint32()
+		if err != nil {
+			return err
+		}
+		return v.container(start, 2*uint64(n), depth)
+	case FormatFixExt1:
+		return v.ext(start, 1)
+	case FormatFixExt2:
+		return v.ext(start, 2)
+	case FormatFixExt4:
+		return v.ext(start, 4)
+	case FormatFixExt8:
+		return v.ext(start, 8)
+	case FormatFixExt16:
+		return v.ext(start, 16)
+	case FormatExt8:
+		n, err := v.reader.GetUint8()
+		if err != nil {
+			return err
+		}
+		return v.ext(start, uint32(n))
+	case FormatExt16:
+		n, err := v.reader.GetUint16()
+		if err != nil {
+			return err
+		}
+		return v.ext(start, uint32(n))
+	case FormatExt32:
+		n, err := v.reader.GetUint32()
+		if err != nil {
+			return err
+		}
+		return v.ext(start, n)
+	}
+	return v.errorAt(start, "invalid format 0x"+strconv.FormatUint(uint64(prefix), 16))
+}
+
+func (v *validator) container(start uint32, count uint64, depth uint32) error {
+	if depth >= v.options.maxDepth {
+		return v.errorAt(start, "maximum depth exceeded")
+	}
+	// Every element takes at least one byte, which rejects absurd counts
+	// without walking them.
+	if remaining := v.reader.Remaining(); count > uint64(remaining) {
+		return RangeError{Offset: v.reader.byteOffset, Requested: uint32(count), Available: remaining}
+	}
+	for i := uint64(0); i < count; i++ {
+		if err := v.value(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) ext(start, length uint32) error {
+	extType, err := v.reader.GetInt8()
+	if err != nil {
+		return err
+	}
+	if extType == -1 && length != 4 && length != 8 && length != 12 {
+		return v.errorAt(start, "invalid timestamp length "+strconv.FormatUint(uint64(length), 10))
+	}
+	return v.reader.Discard(length)
+}
+
+func (v *validator) errorAt(offset uint32, message string) error {
+	return ReadError{"msgpack: " + message + " at offset " + strconv.FormatUint(uint64(offset), 10)}
+}
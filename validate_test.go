@@ -0,0 +1,167 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestValidate(t *testing.T) {
+	valid := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(2)
+		w.WriteString("a")
+		w.WriteAny([]any{int64(1), "two", 3.0, true})
+		w.WriteString("b")
+		w.WriteByteArray([]byte{1, 2, 3})
+	})
+	assert.NoError(t, msgpack.Validate(valid))
+	assert.NoError(t, msgpack.Validate(valid, msgpack.WithMinMapEntries(2)))
+	assert.EqualError(t, msgpack.Validate(valid, msgpack.WithMinMapEntries(3)),
+		"msgpack: top level value is not a map with at least 3 entries at offset 0")
+	assert.EqualError(t, msgpack.Validate(valid, msgpack.WithValidateMaxDepth(1)),
+		"msgpack: maximum depth exceeded at offset 3")
+	assert.EqualError(t, msgpack.Validate(valid, msgpack.WithValidateMaxElements(5)),
+		"msgpack: element budget exceeded at offset 9")
+
+	tests := map[string]struct {
+		data    []byte
+		message string
+	}{
+		"truncated":      {valid[:len(valid)-1], "range error at offset 23: requested 3 bytes, 2 available"},
+		"trailing":       {append(append([]byte{}, valid...), 0x01), "msgpack: 1 trailing bytes at offset 26"},
+		"reserved":       {[]byte{0x91, 0xc1}, "msgpack: invalid format 0xc1 at offset 1"},
+		"timestamp":      {[]byte{0xd5, 0xff, 0x00, 0x00}, "msgpack: invalid timestamp length 2 at offset 0"},
+		"absurd count":   {[]byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0x01}, "range error at offset 5: requested 4294967295 bytes, 1 available"},
+		"empty":          {nil, "range error at offset 0: requested 1 bytes, 0 available"},
+		"truncated head": {[]byte{0xcd, 0x01}, "range error at offset 1: requested 2 bytes, 1 available"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.EqualError(t, msgpack.Validate(tt.data), tt.message)
+		})
+	}
+}
+
+func logDocument(t *testing.T, i int) []byte {
+	return encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(4)
+		w.WriteString("seq")
+		w.WriteInt64(int64(i))
+		w.WriteString("level")
+		w.WriteString("info")
+		w.WriteString("msg")
+		w.WriteString("reading " + strconv.Itoa(i))
+		w.WriteString("values")
+		w.WriteAny([]any{int64(i), float64(i) / 3})
+	})
+}
+
+func TestFindNextValidOffsetRecovery(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	const documents = 500
+
+	var log []byte
+	var docs [][]byte
+	corrupted := make(map[int]bool)
+	for i := 0; i < documents; i++ {
+		doc := logDocument(t, i)
+		docs = append(docs, doc)
+		doc = append([]byte{}, doc...)
+		if rng.Intn(10) == 0 {
+			corrupted[i] = true
+			for n := 1 + rng.Intn(3); n > 0; n-- {
+				doc[rng.Intn(len(doc))] = byte(rng.Intn(256))
+			}
+		}
+		log = append(log, doc...)
+	}
+
+	recovered := 0
+	reader := msgpack.NewMultiDecoder(log)
+	for reader.More() {
+		decoder, err := reader.Next()
+		if err == nil {
+			raw, err := decoder.ReadRaw()
+			require.NoError(t, err)
+			for _, doc := range docs {
+				if bytes.Equal(doc, raw) {
+					recovered++
+					break
+				}
+			}
+			continue
+		}
+		offset, err := msgpack.FindNextValidOffset(log, reader.Offset()+1, msgpack.WithMinMapEntries(4))
+		if errors.Is(err, msgpack.ErrNoValidOffset) {
+			break
+		}
+		require.NoError(t, err)
+		reader.Seek(offset)
+	}
+
+	intact := documents - len(corrupted)
+	rate := float64(recovered) / float64(intact)
+	t.Logf("recovered %d of %d intact documents (%.1f%%)", recovered, intact, rate*100)
+	assert.Greater(t, rate, 0.9)
+}
+
+func TestFindNextValidOffset(t *testing.T) {
+	doc := logDocument(t, 1)
+	data := append([]byte{0xc1, 0xc1, 0x05}, doc...)
+
+	offset, err := msgpack.FindNextValidOffset(data, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), offset, "a fixint is a valid value")
+
+	offset, err = msgpack.FindNextValidOffset(data, 0, msgpack.WithMinMapEntries(1))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), offset)
+
+	_, err = msgpack.FindNextValidOffset(data, 0, msgpack.WithMinMapEntries(1), msgpack.WithValidateMaxElements(4))
+	assert.ErrorIs(t, err, msgpack.ErrNoValidOffset, "documents over the element budget are rejected")
+
+	_, err = msgpack.FindNextValidOffset(data, uint32(len(data)))
+	assert.ErrorIs(t, err, msgpack.ErrNoValidOffset)
+}
+
+func TestFindNextValidOffsetBudget(t *testing.T) {
+	// Nested fixarrays that never complete are the worst case: every offset
+	// starts a deep, wide walk that only fails at the end of the buffer.
+	data := bytes.Repeat([]byte{0x9f}, 1<<12)
+	_, err := msgpack.FindNextValidOffset(data, 0, msgpack.WithValidateMaxElements(64))
+	assert.ErrorIs(t, err, msgpack.ErrNoValidOffset)
+}
+
+func TestMultiDecoder(t *testing.T) {
+	var log []byte
+	for i := 0; i < 3; i++ {
+		log = append(log, logDocument(t, i)...)
+	}
+	reader := msgpack.NewMultiDecoder(log)
+	for i := 0; i < 3; i++ {
+		require.True(t, reader.More())
+		decoder, err := reader.Next()
+		require.NoError(t, err)
+		v, err := decoder.ReadAny()
+		require.NoError(t, err)
+		assert.Equal(t, int64(i), v.(map[any]any)["seq"])
+		_, err = decoder.ReadAny()
+		assert.ErrorIs(t, err, msgpack.ErrRange, "the decoder stops at the end of the document")
+	}
+	assert.False(t, reader.More())
+
+	reader = msgpack.NewMultiDecoder(log[:len(log)-1])
+	reader.Next()
+	reader.Next()
+	offset := reader.Offset()
+	_, err := reader.Next()
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.Equal(t, offset, reader.Offset(), "a failed Next does not move")
+}
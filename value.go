@@ -0,0 +1,340 @@
+package msgpack
+
+import (
+	"time"
+)
+
+// ValueKind is the kind of a Value.
+type ValueKind int
+
+const (
+	// KindInvalid is the kind of the zero Value and of a Value that could
+	// not be read.
+	KindInvalid ValueKind = iota
+	KindNil
+	KindBool
+	// KindInt is a signed integer format or a fixint.
+	KindInt
+	// KindUint is an unsigned integer format.
+	KindUint
+	KindFloat
+	KindString
+	KindBin
+	KindArray
+	KindMap
+	// KindTime is a timestamp extension of type -1.
+	KindTime
+	// KindExt is any other extension.
+	KindExt
+)
+
+func (k ValueKind) String() string {
+	switch k {
+	case KindNil:
+		return "nil"
+	case KindBool:
+		return "bool"
+	case KindInt:
+		return "int"
+	case KindUint:
+		return "uint"
+	case KindFloat:
+		return "float"
+	case KindString:
+		return "string"
+	case KindBin:
+		return "bin"
+	case KindArray:
+		return "array"
+	case KindMap:
+		return "map"
+	case KindTime:
+		return "time"
+	case KindExt:
+		return "ext"
+	}
+	return "invalid"
+}
+
+// Value is an encoded value that is decoded on access, as a typed
+// alternative to the map[any]any and []any returned by ReadAny. Reaching an
+// element with Index, MapIndex or Range only skips over the values before
+// it, so reading a few fields of a large document does not pay for the
+// rest, and a corrupt value is only noticed when it is reached.
+//
+// Accessors never panic. One used on a value of another kind, or on a
+// value that turns out to be corrupt, returns the zero value and false, and
+// a missing element is the zero Value, of kind KindInvalid. Err reports
+// why a Value is invalid.
+type Value struct {
+	// data starts with the value and may run on past its end.
+	data []byte
+	err  error
+}
+
+// DecodeValue returns the Value at the start of `data`. Only the first
+// byte is checked; the rest is read as it is accessed. `data` is not
+// copied, so Bytes and Raw alias it.
+func DecodeValue(data []byte) (Value, error) {
+	v := Value{data: data}
+	if v.Kind() == KindInvalid {
+		_, err := v.decoder().PeekFormat()
+		if err == nil {
+			err = ReadError{"msgpack: " + formatKind(data[0])}
+		}
+		return Value{}, err
+	}
+	return v, nil
+}
+
+// Err returns the error that made the value invalid, if any.
+func (v Value) Err() error {
+	return v.err
+}
+
+func (v Value) decoder() *Decoder {
+	d := NewDecoder(v.data)
+	return &d
+}
+
+// Kind returns the kind of the value, read from its format byte.
+func (v Value) Kind() ValueKind {
+	if v.err != nil || len(v.data) == 0 {
+		return KindInvalid
+	}
+	prefix := v.data[0]
+	kind := prefixKind(prefix)
+	if kind == KindExt {
+		d := v.decoder()
+		d.reader.Discard(1)
+		if extType, length, err := d.extHeader(prefix); err == nil && extType == -1 &&
+			(length == 4 || length == 8 || length == 12) {
+			return KindTime
+		}
+	}
+	return kind
+}
+
+// prefixKind returns the kind of a value from its format byte alone, so
+// extensions, timestamps included, are KindExt.
+func prefixKind(prefix byte) ValueKind {
+	switch formatKind(prefix) {
+	case "nil":
+		return KindNil
+	case "bool":
+		return KindBool
+	case "int":
+		switch prefix {
+		case FormatUint8, FormatUint16, FormatUint32, FormatUint64:
+			return KindUint
+		}
+		return KindInt
+	case "float":
+		return KindFloat
+	case "string":
+		return KindString
+	case "bin":
+		return KindBin
+	case "array":
+		return KindArray
+	case "map":
+		return KindMap
+	case "ext":
+		return KindExt
+	}
+	return KindInvalid
+}
+
+// Int returns the value of an integer that fits in an int64.
+func (v Value) Int() (int64, bool) {
+	if k := v.Kind(); k != KindInt && k != KindUint {
+		return 0, false
+	}
+	n, err := readNormalizedValue(v.decoder())
+	i, ok := n.(int64)
+	return i, ok && err == nil
+}
+
+// Uint returns the value of a non-negative integer.
+func (v Value) Uint() (uint64, bool) {
+	if k := v.Kind(); k != KindInt && k != KindUint {
+		return 0, false
+	}
+	n, err := readNormalizedValue(v.decoder())
+	if err != nil {
+		return 0, false
+	}
+	switch n := n.(type) {
+	case int64:
+		if n >= 0 {
+			return uint64(n), true
+		}
+	case uint64:
+		return n, true
+	}
+	return 0, false
+}
+
+// Float returns the value of a float32 or float64.
+func (v Value) Float() (float64, bool) {
+	if v.Kind() != KindFloat {
+		return 0, false
+	}
+	n, err := readNormalizedValue(v.decoder())
+	f, ok := n.(float64)
+	return f, ok && err == nil
+}
+
+// Str returns the value of a string.
+func (v Value) Str() (string, bool) {
+	if v.Kind() != KindString {
+		return "", false
+	}
+	s, err := v.decoder().ReadString()
+	return s, err == nil
+}
+
+// Bytes returns the value of a bin. The slice aliases the decoded data.
+func (v Value) Bytes() ([]byte, bool) {
+	if v.Kind() != KindBin {
+		return nil, false
+	}
+	b, err := v.decoder().ReadByteArray()
+	return b, err == nil
+}
+
+// Bool returns the value of a bool.
+func (v Value) Bool() (bool, bool) {
+	if v.Kind() != KindBool {
+		return false, false
+	}
+	return v.data[0] == FormatTrue, true
+}
+
+// Time returns the value of a timestamp.
+func (v Value) Time() (time.Time, bool) {
+	if v.Kind() != KindTime {
+		return time.Time{}, false
+	}
+	t, err := v.decoder().ReadTime()
+	return t, err == nil
+}
+
+// Raw returns the encoding of the value. Getting it skips over the whole
+// value, so an error in any part of it is reported.
+func (v Value) Raw() (Raw, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	return v.decoder().ReadRaw()
+}
+
+// Len returns the number of elements of an array, entries of a map or
+// bytes of a string or bin, and 0 for any other kind.
+func (v Value) Len() int {
+	d := v.decoder()
+	var n uint32
+	var err error
+	switch v.Kind() {
+	case KindArray:
+		n, err = d.ReadArraySize()
+	case KindMap:
+		n, err = d.ReadMapSize()
+	case KindString:
+		n, err = d.readStringLength()
+	case KindBin:
+		n, err = d.readBinLength()
+	}
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// Index returns element `i` of an array.
+func (v Value) Index(i int) Value {
+	if v.Kind() != KindArray || i < 0 {
+		return Value{}
+	}
+	d := v.decoder()
+	size, err := d.ReadArraySize()
+	if err != nil {
+		return Value{err: err}
+	}
+	if uint64(i) >= uint64(size) {
+		return Value{}
+	}
+	for ; i > 0; i-- {
+		if err := d.Skip(); err != nil {
+			return Value{err: err}
+		}
+	}
+	return d.valueAt()
+}
+
+// MapIndex returns the value stored under the string key `key` in a map.
+// Keys that are not strings never match.
+func (v Value) MapIndex(key string) (Value, bool) {
+	if v.Kind() != KindMap {
+		return Value{}, false
+	}
+	d := v.decoder()
+	found, err := seekMapKey(d, key)
+	if err != nil {
+		return Value{err: err}, false
+	}
+	if !found {
+		return Value{}, false
+	}
+	return d.valueAt(), true
+}
+
+// Range calls `fn` with each entry of a map, or with each element of an
+// array and the zero Value as key, until `fn` returns false. It returns
+// the error met while stepping to the next entry, if any.
+func (v Value) Range(fn func(key, value Value) bool) error {
+	d := v.decoder()
+	var size uint32
+	var err error
+	isMap := false
+	switch v.Kind() {
+	case KindArray:
+		size, err = d.ReadArraySize()
+	case KindMap:
+		size, err = d.ReadMapSize()
+		isMap = true
+	default:
+		return v.err
+	}
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		var key Value
+		if isMap {
+			key = d.valueAt()
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		}
+		value := d.valueAt()
+		if !fn(key, value) {
+			return nil
+		}
+		if i+1 < size {
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// valueAt returns the Value at the current position, or an invalid Value
+// holding the error if there is none.
+func (d *Decoder) valueAt() Value {
+	if _, err := d.PeekFormat(); err != nil {
+		return Value{err: err}
+	}
+	return Value{data: d.reader.buffer[d.reader.byteOffset:]}
+}
@@ -0,0 +1,205 @@
+package msgpack_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestValueAccessors(t *testing.T) {
+	when := time.Unix(1700000000, 5).UTC()
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteMapSize(11)
+		w.WriteString("name")
+		w.WriteString("Ada")
+		w.WriteString("age")
+		w.WriteInt64(36)
+		w.WriteString("delta")
+		w.WriteInt64(-5)
+		w.WriteString("big")
+		w.WriteUint64(math.MaxUint64)
+		w.WriteString("score")
+		w.WriteFloat32(1.5)
+		w.WriteString("ok")
+		w.WriteBool(true)
+		w.WriteString("blob")
+		w.WriteByteArray([]byte{1, 2, 3})
+		w.WriteString("when")
+		w.WriteTime(when)
+		w.WriteString("tags")
+		w.WriteArraySize(2)
+		w.WriteString("a")
+		w.WriteString("b")
+		w.WriteString("missing")
+		w.WriteNil()
+		w.WriteString("ext")
+		w.WriteRaw(msgpack.Raw{msgpack.FormatFixExt1, 7, 0})
+	})
+	v, err := msgpack.DecodeValue(data)
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.KindMap, v.Kind())
+	assert.Equal(t, 11, v.Len())
+
+	get := func(key string) msgpack.Value {
+		field, ok := v.MapIndex(key)
+		require.True(t, ok, key)
+		return field
+	}
+
+	s, ok := get("name").Str()
+	assert.True(t, ok)
+	assert.Equal(t, "Ada", s)
+	assert.Equal(t, 3, get("name").Len())
+
+	i, ok := get("age").Int()
+	assert.True(t, ok)
+	assert.Equal(t, int64(36), i)
+	u, ok := get("age").Uint()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(36), u)
+	_, ok = get("delta").Uint()
+	assert.False(t, ok)
+	assert.Equal(t, msgpack.KindUint, get("big").Kind())
+	_, ok = get("big").Int()
+	assert.False(t, ok)
+	u, ok = get("big").Uint()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(math.MaxUint64), u)
+
+	f, ok := get("score").Float()
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, f)
+	b, ok := get("ok").Bool()
+	assert.True(t, ok)
+	assert.True(t, b)
+	blob, ok := get("blob").Bytes()
+	assert.True(t, ok)
+	assert.Equal(t, []byte{1, 2, 3}, blob)
+	assert.Equal(t, msgpack.KindTime, get("when").Kind())
+	tm, ok := get("when").Time()
+	assert.True(t, ok)
+	assert.True(t, when.Equal(tm))
+	assert.Equal(t, msgpack.KindNil, get("missing").Kind())
+	assert.Equal(t, msgpack.KindExt, get("ext").Kind())
+
+	tags := get("tags")
+	assert.Equal(t, msgpack.KindArray, tags.Kind())
+	assert.Equal(t, 2, tags.Len())
+	s, _ = tags.Index(1).Str()
+	assert.Equal(t, "b", s)
+
+	var keys []string
+	require.NoError(t, v.Range(func(key, value msgpack.Value) bool {
+		k, _ := key.Str()
+		keys = append(keys, k)
+		return k != "ok"
+	}))
+	assert.Equal(t, []string{"name", "age", "delta", "big", "score", "ok"}, keys)
+}
+
+func TestValueWrongKindAndMissing(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(1)
+		w.WriteString("x")
+	})
+	v, err := msgpack.DecodeValue(data)
+	require.NoError(t, err)
+
+	_, ok := v.Str()
+	assert.False(t, ok)
+	_, ok = v.Int()
+	assert.False(t, ok)
+	_, ok = v.MapIndex("x")
+	assert.False(t, ok)
+	assert.Equal(t, msgpack.KindInvalid, v.Index(1).Kind())
+	assert.Equal(t, msgpack.KindInvalid, v.Index(-1).Kind())
+	assert.NoError(t, v.Index(1).Err())
+
+	missing := v.Index(5).Index(0)
+	assert.Equal(t, msgpack.KindInvalid, missing.Kind())
+	_, ok = missing.Time()
+	assert.False(t, ok)
+	assert.Equal(t, 0, missing.Len())
+
+	_, err = msgpack.DecodeValue(nil)
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	_, err = msgpack.DecodeValue([]byte{0xc1})
+	assert.EqualError(t, err, "msgpack: invalid format 0xc1")
+}
+
+func TestReadAnyTruncatedContainers(t *testing.T) {
+	// Each container claims far more elements than the input holds.
+	for _, data := range [][]byte{
+		{0xdc, 0xff, 0xff},             // array16
+		{0xdd, 0xff, 0xff, 0xff, 0xff}, // array32
+		{0xde, 0xff, 0xff},             // map16
+		{0xdf, 0xff, 0xff, 0xff, 0xff}, // map32
+	} {
+		decoder := msgpack.NewDecoder(data)
+		_, err := decoder.ReadAny()
+		assert.ErrorIs(t, err, msgpack.ErrRange, "% x", data)
+		decoder = msgpack.NewDecoderWithOptions(data, msgpack.WithStringifiedMapKeys())
+		_, err = decoder.ReadAny()
+		assert.ErrorIs(t, err, msgpack.ErrRange, "% x", data)
+
+		v, err := msgpack.DecodeValue(data)
+		require.NoError(t, err)
+		err = v.Range(func(key, value msgpack.Value) bool { return true })
+		assert.ErrorIs(t, err, msgpack.ErrRange, "% x", data)
+	}
+}
+
+func TestValueLazy(t *testing.T) {
+	// The last element claims 255 bytes that are not there.
+	data := []byte{
+		0x93,            // array(3)
+		0x01,            // 1
+		0x81, 0xa1, 'a', // {"a":
+		0x02,            //   2}
+		0xd9, 0xff, 'x', // str8 cut short
+	}
+	decoder := msgpack.NewDecoder(data)
+	_, err := decoder.ReadAny()
+	require.Error(t, err)
+
+	v, err := msgpack.DecodeValue(data)
+	require.NoError(t, err)
+	i, ok := v.Index(0).Int()
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), i)
+	a, ok := v.Index(1).MapIndex("a")
+	require.True(t, ok)
+	i, ok = a.Int()
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), i)
+
+	// The corrupt value is only noticed when it is read.
+	last := v.Index(2)
+	assert.Equal(t, msgpack.KindString, last.Kind())
+	_, ok = last.Str()
+	assert.False(t, ok)
+	_, err = last.Raw()
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	_, err = v.Raw()
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+
+	// Stepping over it is an error too.
+	data = []byte{0x92, 0xd9, 0xff, 'x', 0x01}
+	v, err = msgpack.DecodeValue(data)
+	require.NoError(t, err)
+	second := v.Index(1)
+	assert.Equal(t, msgpack.KindInvalid, second.Kind())
+	assert.ErrorIs(t, second.Err(), msgpack.ErrRange)
+	count := 0
+	err = v.Range(func(_, _ msgpack.Value) bool {
+		count++
+		return true
+	})
+	assert.ErrorIs(t, err, msgpack.ErrRange)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,113 @@
+package msgpack
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// vecLen returns the total length of `segments`, or false if it does not
+// fit in a MessagePack length.
+func vecLen[S []byte | string](segments []S) (uint32, bool) {
+	var total uint64
+	for _, segment := range segments {
+		total += uint64(len(segment))
+	}
+	return uint32(total), total <= math.MaxUint32
+}
+
+func vecTooLongError(kind string, segments int) error {
+	return WriteError{message: "msgpack: " + kind + " from " + strconv.Itoa(segments) +
+		" segments is longer than " + strconv.FormatUint(math.MaxUint32, 10) + " bytes"}
+}
+
+// WriteByteArrayVec writes the concatenation of `segments` as one bin,
+// copying each segment straight into the buffer instead of joining them
+// first. No segments write an empty bin.
+func (e *Encoder) WriteByteArrayVec(segments ...[]byte) {
+	length, ok := vecLen(segments)
+	if !ok {
+		if e.reader.err == nil {
+			e.reader.err = vecTooLongError("bin", len(segments))
+		}
+		return
+	}
+	if length == 0 {
+		e.WriteByteArray(nil)
+		return
+	}
+	if !e.fits(binSize(length)) {
+		return
+	}
+	e.writeBinLength(length)
+	for _, segment := range segments {
+		e.reader.SetBytes(segment)
+	}
+}
+
+// WriteStringVec writes the concatenation of `segments` as one string,
+// copying each segment straight into the buffer instead of joining them
+// first. With WithStringTable the segments are joined, since the table
+// looks strings up whole.
+func (e *Encoder) WriteStringVec(segments ...string) {
+	length, ok := vecLen(segments)
+	if !ok {
+		if e.reader.err == nil {
+			e.reader.err = vecTooLongError("string", len(segments))
+		}
+		return
+	}
+	if e.options.stringTable {
+		e.WriteString(strings.Join(segments, ""))
+		return
+	}
+	if !e.fits(stringSize(length)) {
+		return
+	}
+	e.writeStringLength(length)
+	for _, segment := range segments {
+		e.reader.SetBytes(UnsafeBytes(segment))
+	}
+}
+
+func (s *Sizer) WriteByteArrayVec(segments ...[]byte) {
+	length, ok := vecLen(segments)
+	if !ok {
+		if s.err == nil {
+			s.err = vecTooLongError("bin", len(segments))
+		}
+		return
+	}
+	if length == 0 {
+		s.WriteByteArray(nil)
+		return
+	}
+	s.writeBinLength(length)
+	s.length += length + 1
+}
+
+func (s *Sizer) WriteStringVec(segments ...string) {
+	length, ok := vecLen(segments)
+	if !ok {
+		if s.err == nil {
+			s.err = vecTooLongError("string", len(segments))
+		}
+		return
+	}
+	if s.options.stringTable {
+		s.WriteString(strings.Join(segments, ""))
+		return
+	}
+	s.writeStringLength(length)
+	s.length += length
+}
+
+func (s *UpperBoundSizer) WriteByteArrayVec(segments ...[]byte) {
+	length, _ := vecLen(segments)
+	s.length += 5 + length
+}
+
+func (s *UpperBoundSizer) WriteStringVec(segments ...string) {
+	length, _ := vecLen(segments)
+	s.length += 5 + length
+}
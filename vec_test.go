@@ -0,0 +1,91 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestWriteByteArrayVec(t *testing.T) {
+	for _, segments := range [][][]byte{
+		nil,
+		{{}},
+		{[]byte("head"), {}, []byte("tail")},
+		{[]byte("head"), bytes.Repeat([]byte{7}, 300), []byte("tail")},
+		{bytes.Repeat([]byte{1}, 40000), bytes.Repeat([]byte{2}, 40000)},
+	} {
+		actual := encodeWith(t, func(w msgpack.Writer) { w.WriteByteArrayVec(segments...) })
+		expected := encodeWith(t, func(w msgpack.Writer) { w.WriteByteArray(bytes.Join(segments, nil)) })
+		assert.Equal(t, expected, actual)
+
+		var upper msgpack.UpperBoundSizer
+		upper.WriteByteArrayVec(segments...)
+		assert.GreaterOrEqual(t, upper.Len(), uint32(len(actual)))
+	}
+}
+
+func TestWriteStringVec(t *testing.T) {
+	for _, segments := range [][]string{
+		nil,
+		{"a", "", "b"},
+		{"prefix-", strings.Repeat("x", 40), "-suffix"},
+	} {
+		actual := encodeWith(t, func(w msgpack.Writer) { w.WriteStringVec(segments...) })
+		expected := encodeWith(t, func(w msgpack.Writer) { w.WriteString(strings.Join(segments, "")) })
+		assert.Equal(t, expected, actual)
+	}
+
+	// The string table sees the joined string.
+	actual := encodeWithStringTable(t, func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteString("abcdef")
+		w.WriteStringVec("abc", "def")
+	})
+	expected := encodeWithStringTable(t, func(w msgpack.Writer) {
+		w.WriteArraySize(2)
+		w.WriteString("abcdef")
+		w.WriteString("abcdef")
+	})
+	assert.Equal(t, expected, actual)
+}
+
+func TestWriteByteArrayVecTooLong(t *testing.T) {
+	segment := make([]byte, 1<<26)
+	segments := make([][]byte, 65)
+	for i := range segments {
+		segments[i] = segment
+	}
+
+	var sizer msgpack.Sizer
+	sizer.WriteByteArrayVec(segments...)
+	assert.EqualError(t, sizer.Err(), "msgpack: bin from 65 segments is longer than 4294967295 bytes")
+
+	encoder := msgpack.NewEncoder(make([]byte, 16))
+	encoder.WriteByteArrayVec(segments...)
+	require.Error(t, encoder.Err())
+	assert.Equal(t, uint32(0), encoder.Len())
+}
+
+func BenchmarkWriteByteArrayVec(b *testing.B) {
+	segments := [][]byte{make([]byte, 1024), make([]byte, 62*1024), make([]byte, 1024)}
+	buffer := make([]byte, 70*1024)
+	b.Run("concat", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encoder := msgpack.NewEncoder(buffer)
+			encoder.WriteByteArray(bytes.Join(segments, nil))
+		}
+	})
+	b.Run("vec", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encoder := msgpack.NewEncoder(buffer)
+			encoder.WriteByteArrayVec(segments...)
+		}
+	})
+}
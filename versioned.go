@@ -0,0 +1,85 @@
+package msgpack
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// ErrUnsupportedVersion is matched by the UnsupportedVersionError returned
+// by DecodeVersioned for a version it has no handler for.
+var ErrUnsupportedVersion = errors.New("msgpack: unsupported version")
+
+// UnsupportedVersionError reports a versioned envelope whose version has
+// no handler. It matches ErrUnsupportedVersion with errors.Is.
+type UnsupportedVersionError struct {
+	// Version is the version read from the envelope.
+	Version uint32
+	// Known lists the versions that have handlers, in ascending order.
+	Known []uint32
+}
+
+func (e UnsupportedVersionError) Error() string {
+	msg := "msgpack: unsupported version " + strconv.FormatUint(uint64(e.Version), 10)
+	if len(e.Known) == 0 {
+		return msg
+	}
+	msg += ", known versions are "
+	for i, v := range e.Known {
+		if i > 0 {
+			msg += ", "
+		}
+		msg += strconv.FormatUint(uint64(v), 10)
+	}
+	return msg
+}
+
+func (e UnsupportedVersionError) Unwrap() error {
+	return ErrUnsupportedVersion
+}
+
+// EncodeVersioned writes a versioned envelope: an array of `version` and
+// the value written by `body`, which must write exactly one value,
+// usually a map.
+func EncodeVersioned(w Writer, version uint32, body func(Writer) error) error {
+	w.WriteArraySize(2)
+	w.WriteUint32(version)
+	if err := body(w); err != nil {
+		return err
+	}
+	return w.Err()
+}
+
+// DecodeVersioned reads an envelope written by EncodeVersioned and decodes
+// its body with the handler for its version. For a version without a
+// handler, `fallback` is called with the version and must read or Skip the
+// body; when `fallback` is nil the body is skipped and an
+// UnsupportedVersionError is returned.
+func DecodeVersioned(r Reader, handlers map[uint32]func(Reader) error, fallback func(version uint32, r Reader) error) error {
+	size, err := r.ReadArraySize()
+	if err != nil {
+		return err
+	}
+	if size != 2 {
+		return ReadError{"msgpack: versioned envelope must have 2 elements, got " + strconv.FormatUint(uint64(size), 10)}
+	}
+	version, err := r.ReadUint32()
+	if err != nil {
+		return err
+	}
+	if handler, ok := handlers[version]; ok {
+		return checkDecoded(r, handler(r))
+	}
+	if fallback != nil {
+		return checkDecoded(r, fallback(version, r))
+	}
+	if err := r.Skip(); err != nil {
+		return err
+	}
+	known := make([]uint32, 0, len(handlers))
+	for v := range handlers {
+		known = append(known, v)
+	}
+	sort.Slice(known, func(i, j int) bool { return known[i] < known[j] })
+	return UnsupportedVersionError{Version: version, Known: known}
+}
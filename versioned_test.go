@@ -0,0 +1,113 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+type reading struct {
+	Celsius float64
+}
+
+func writeReadingBody(raw int64) func(msgpack.Writer) error {
+	return func(w msgpack.Writer) error {
+		w.WriteMapSize(1)
+		w.WriteString("temp")
+		w.WriteInt64(raw)
+		return nil
+	}
+}
+
+// readingHandlers decode the same body differently: version 1 stores
+// tenths of a degree and version 2 hundredths.
+func readingHandlers(target *reading) map[uint32]func(msgpack.Reader) error {
+	scaled := func(scale float64) func(msgpack.Reader) error {
+		return func(r msgpack.Reader) error {
+			m, err := msgpack.ReadFilteredMap(r, func(key string) bool { return key == "temp" }, msgpack.Reader.ReadInt64)
+			if err != nil {
+				return err
+			}
+			target.Celsius = float64(m["temp"]) / scale
+			return nil
+		}
+	}
+	return map[uint32]func(msgpack.Reader) error{1: scaled(10), 2: scaled(100)}
+}
+
+func TestVersionedMigration(t *testing.T) {
+	for version, expected := range map[uint32]float64{1: 21.5, 2: 2.15} {
+		data := encodeWith(t, func(w msgpack.Writer) {
+			require.NoError(t, msgpack.EncodeVersioned(w, version, writeReadingBody(215)))
+		})
+		assert.Equal(t, byte(version), data[1])
+
+		var r reading
+		decoder := msgpack.NewDecoder(data)
+		require.NoError(t, msgpack.DecodeVersioned(&decoder, readingHandlers(&r), nil))
+		assert.Equal(t, expected, r.Celsius)
+		assert.Equal(t, uint32(0), decoder.Remaining())
+	}
+}
+
+func TestVersionedUnknownVersion(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		require.NoError(t, msgpack.EncodeVersioned(w, 3, writeReadingBody(215)))
+		w.WriteString("next")
+	})
+
+	// Without a fallback the body is skipped.
+	var r reading
+	decoder := msgpack.NewDecoder(data)
+	err := msgpack.DecodeVersioned(&decoder, readingHandlers(&r), nil)
+	assert.ErrorIs(t, err, msgpack.ErrUnsupportedVersion)
+	assert.EqualError(t, err, "msgpack: unsupported version 3, known versions are 1, 2")
+	next, err := decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "next", next)
+
+	decoder = msgpack.NewDecoder(data)
+	err = msgpack.DecodeVersioned(&decoder, nil, nil)
+	assert.EqualError(t, err, "msgpack: unsupported version 3")
+
+	// A fallback can degrade gracefully.
+	var seen uint32
+	decoder = msgpack.NewDecoder(data)
+	err = msgpack.DecodeVersioned(&decoder, readingHandlers(&r), func(version uint32, r msgpack.Reader) error {
+		seen = version
+		return r.Skip()
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), seen)
+	next, err = decoder.ReadString()
+	require.NoError(t, err)
+	assert.Equal(t, "next", next)
+}
+
+func TestVersionedMalformed(t *testing.T) {
+	var r reading
+	tests := []struct {
+		name  string
+		data  []byte
+		error string
+	}{
+		{"not an array", []byte{0x81, 0x01, 0x02}, "bad prefix for array length: got fixmap(1) (0x81) at offset 0"},
+		{"wrong arity", []byte{0x93, 0x01, 0x80, 0x80}, "msgpack: versioned envelope must have 2 elements, got 3"},
+		{"negative version", []byte{0x92, 0xff, 0x80}, "bad prefix for uint: got negfixint(-1) (0xff) at offset 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := msgpack.NewDecoder(tt.data)
+			err := msgpack.DecodeVersioned(&decoder, readingHandlers(&r), nil)
+			assert.EqualError(t, err, tt.error)
+		})
+	}
+
+	// Errors from a handler are returned as they are.
+	decoder := msgpack.NewDecoder([]byte{0x92, 0x01, 0xa1, 'x'})
+	err := msgpack.DecodeVersioned(&decoder, readingHandlers(&r), nil)
+	assert.Error(t, err)
+}
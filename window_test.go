@@ -0,0 +1,50 @@
+package msgpack_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestDecoderAtWindow(t *testing.T) {
+	// 4 byte envelope header, a 2 element array payload, then trailing bytes.
+	buffer := []byte{0xde, 0xad, 0xbe, 0xef, 0x92, 0xa2, 'h', 'i', 0x07, 0xff, 0xff}
+	decoder := msgpack.NewDecoderAt(buffer, 4, 5)
+	assert.Equal(t, uint32(4), decoder.Offset())
+
+	raw, err := decoder.ReadRaw()
+	require.NoError(t, err)
+	assert.Equal(t, msgpack.Raw{0x92, 0xa2, 'h', 'i', 0x07}, raw)
+	assert.Equal(t, uint32(9), decoder.Offset())
+	assert.Equal(t, len(raw), cap(raw))
+	_ = append(raw, 0x00)
+	assert.Equal(t, byte(0xff), buffer[9], "appending to a returned slice must not write past the window")
+
+	// The window ends here even though the buffer continues.
+	_, err = decoder.ReadAny()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, msgpack.ErrRange))
+}
+
+func TestDecoderAtTruncatedPayload(t *testing.T) {
+	// The payload declares a 5 byte string but the window only holds 3.
+	buffer := []byte{0x00, 0x00, 0xa5, 'h', 'e', 'l', 'l', 'o'}
+	decoder := msgpack.NewDecoderAt(buffer, 2, 4)
+	_, err := decoder.ReadString()
+	var rangeErr msgpack.RangeError
+	require.True(t, errors.As(err, &rangeErr))
+	assert.Equal(t, uint32(3), rangeErr.Offset)
+	assert.Equal(t, uint32(5), rangeErr.Requested)
+	assert.Equal(t, uint32(3), rangeErr.Available)
+	assert.Equal(t, "range error at offset 3: requested 5 bytes, 3 available", err.Error())
+}
+
+func TestDecoderAtOutOfBounds(t *testing.T) {
+	decoder := msgpack.NewDecoderAt([]byte{0x01, 0x02}, 1, 2)
+	_, err := decoder.ReadInt64()
+	assert.True(t, errors.Is(err, msgpack.ErrRange))
+}
@@ -0,0 +1,109 @@
+package msgpack
+
+import (
+	"strconv"
+	"time"
+)
+
+// WriteZonedTime writes `t` together with its time zone, which the
+// timestamp extension leaves out. The layout is not part of the
+// MessagePack spec, so other implementations see a plain array of three
+// elements:
+//
+//	[timestamp ext, zone name, offset in seconds east of UTC]
+//
+// The zone name is empty for UTC, the IANA name such as "America/Chicago"
+// for zones loaded from the zone database, and the offset as "+05:30" for
+// fixed zones and time.Local. The offset is the one in effect at `t`, so a
+// reader without the zone database can still show the original wall
+// clock. ReadTime reads the first element on its own.
+func WriteZonedTime(w Writer, t time.Time) {
+	name, offset := zoneOf(t)
+	w.WriteArraySize(3)
+	w.WriteTime(t)
+	w.WriteString(name)
+	w.WriteInt32(int32(offset))
+}
+
+// WriteNillableZonedTime writes nil for a nil `t` and is otherwise
+// WriteZonedTime.
+func WriteNillableZonedTime(w Writer, t *time.Time) {
+	if t == nil {
+		w.WriteNil()
+		return
+	}
+	WriteZonedTime(w, *t)
+}
+
+// ReadZonedTime reads a time written by WriteZonedTime in its original
+// zone. A zone name that time.LoadLocation cannot find, as happens in
+// TinyGo builds without a zone database, becomes a fixed zone with the
+// same name and the recorded offset.
+func ReadZonedTime(r Reader) (time.Time, error) {
+	if err := readTupleHeader(r, 3); err != nil {
+		return time.Time{}, err
+	}
+	t, err := r.ReadTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	name, err := r.ReadString()
+	if err != nil {
+		return time.Time{}, err
+	}
+	offset, err := r.ReadInt32()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if name == "" {
+		return t.UTC(), nil
+	}
+	if name[0] != '+' && name[0] != '-' {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return t.In(loc), nil
+		}
+	}
+	return t.In(time.FixedZone(name, int(offset))), nil
+}
+
+// ReadNillableZonedTime reads nil as a nil pointer and is otherwise
+// ReadZonedTime.
+func ReadNillableZonedTime(r Reader) (*time.Time, error) {
+	return readNillableWith(r, func() (time.Time, error) { return ReadZonedTime(r) })
+}
+
+// zoneOf returns the zone name WriteZonedTime records for `t` and its
+// offset.
+func zoneOf(t time.Time) (string, int) {
+	_, offset := t.Zone()
+	loc := t.Location()
+	if loc == time.UTC {
+		return "", 0
+	}
+	if name := loc.String(); loc != time.Local && name != "" {
+		return name, offset
+	}
+	return formatOffset(offset), offset
+}
+
+// formatOffset formats an offset in seconds as "+hh:mm".
+func formatOffset(offset int) string {
+	sign := byte('+')
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	minutes := offset / 60
+	b := []byte{sign}
+	b = appendTwoDigits(b, minutes/60)
+	b = append(b, ':')
+	b = appendTwoDigits(b, minutes%60)
+	return string(b)
+}
+
+func appendTwoDigits(b []byte, n int) []byte {
+	if n < 10 {
+		b = append(b, '0')
+	}
+	return strconv.AppendInt(b, int64(n), 10)
+}
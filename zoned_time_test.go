@@ -0,0 +1,122 @@
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	msgpack "github.com/wapc/tinygo-msgpack"
+)
+
+func TestZonedTimeRoundTrip(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	require.NoError(t, err)
+	values := []time.Time{
+		time.Date(2024, 3, 1, 9, 0, 0, 0, chicago),
+		time.Date(2024, 7, 1, 9, 0, 0, 0, chicago),
+		time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 9, 0, 0, 500, time.FixedZone("", 5*3600+30*60)),
+		time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("", -3*3600)),
+	}
+	data := encodeWith(t, func(w msgpack.Writer) {
+		for _, v := range values {
+			msgpack.WriteZonedTime(w, v)
+		}
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	for _, expected := range values {
+		actual, err := msgpack.ReadZonedTime(&decoder)
+		require.NoError(t, err)
+		assert.True(t, expected.Equal(actual))
+		assert.Equal(t, expected.Format(time.RFC3339Nano), actual.Format(time.RFC3339Nano))
+		_, expectedOffset := expected.Zone()
+		_, actualOffset := actual.Zone()
+		assert.Equal(t, expectedOffset, actualOffset)
+	}
+	assert.Equal(t, uint32(0), decoder.Remaining())
+
+	decoder = msgpack.NewDecoder(data)
+	actual, err := msgpack.ReadZonedTime(&decoder)
+	require.NoError(t, err)
+	assert.Equal(t, chicago, actual.Location())
+}
+
+func TestZonedTimeLayout(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	require.NoError(t, err)
+	instant := time.Date(2024, 3, 1, 9, 0, 0, 0, chicago)
+	data := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteZonedTime(w, instant)
+		msgpack.WriteZonedTime(w, instant.In(time.FixedZone("", 5*3600+30*60)))
+		msgpack.WriteZonedTime(w, instant.UTC())
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	for _, zone := range []struct {
+		name   string
+		offset int32
+	}{{"America/Chicago", -6 * 3600}, {"+05:30", 5*3600 + 30*60}, {"", 0}} {
+		size, err := decoder.ReadArraySize()
+		require.NoError(t, err)
+		assert.Equal(t, uint32(3), size)
+		// A reader that does not know the layout still gets the instant.
+		ts, err := decoder.ReadTime()
+		require.NoError(t, err)
+		assert.True(t, instant.Equal(ts))
+		name, err := decoder.ReadString()
+		require.NoError(t, err)
+		assert.Equal(t, zone.name, name)
+		offset, err := decoder.ReadInt32()
+		require.NoError(t, err)
+		assert.Equal(t, zone.offset, offset)
+	}
+}
+
+func TestZonedTimeUnknownZoneFallsBackToOffset(t *testing.T) {
+	instant := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteArraySize(3)
+		w.WriteTime(instant)
+		w.WriteString("Mars/Olympus_Mons")
+		w.WriteInt32(-6 * 3600)
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	actual, err := msgpack.ReadZonedTime(&decoder)
+	require.NoError(t, err)
+	assert.True(t, instant.Equal(actual))
+	assert.Equal(t, 9, actual.Hour())
+	name, offset := actual.Zone()
+	assert.Equal(t, "Mars/Olympus_Mons", name)
+	assert.Equal(t, -6*3600, offset)
+}
+
+func TestNillableZonedTime(t *testing.T) {
+	value := time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("", 3600))
+	data := encodeWith(t, func(w msgpack.Writer) {
+		msgpack.WriteNillableZonedTime(w, nil)
+		msgpack.WriteNillableZonedTime(w, &value)
+	})
+
+	decoder := msgpack.NewDecoder(data)
+	actual, err := msgpack.ReadNillableZonedTime(&decoder)
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+	actual, err = msgpack.ReadNillableZonedTime(&decoder)
+	require.NoError(t, err)
+	require.NotNil(t, actual)
+	assert.Equal(t, value.Format(time.RFC3339), actual.Format(time.RFC3339))
+}
+
+func TestReadZonedTimeWrongLayout(t *testing.T) {
+	data := encodeWith(t, func(w msgpack.Writer) {
+		w.WriteTime(time.Unix(0, 0))
+	})
+	decoder := msgpack.NewDecoder(data)
+	_, err := msgpack.ReadZonedTime(&decoder)
+	assert.Error(t, err)
+}